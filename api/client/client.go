@@ -0,0 +1,179 @@
+// Package client is a Go client for the HTTP API of a running ipfs daemon.
+//
+// Its requests are built from the command definitions the daemon serves,
+// so options, arguments and outputs are encoded the way the daemon expects
+// and the outputs come back as the commands' own types.
+package client
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	corecommands "github.com/ipfs/go-ipfs/core/commands"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// Client sends commands to the API of a daemon.
+type Client struct {
+	client cmdsHttp.Client
+}
+
+// New returns a client for the API listening at address, given as
+// host:port, such as "127.0.0.1:5001".
+func New(address string) *Client {
+	return &Client{client: cmdsHttp.NewClient(address)}
+}
+
+// NewWithAuthorization returns a client for the API at address that sends
+// authorization as the Authorization header, for the daemons which require
+// one of their API.Authorizations.
+func NewWithAuthorization(address, authorization string) *Client {
+	return &Client{client: cmdsHttp.NewAuthClient(address, authorization)}
+}
+
+// send runs the command at path on the daemon. The returned response has
+// no error set, unless it is a stream that fails later.
+func (c *Client) send(ctx context.Context, path []string, opts cmds.OptMap, args []string, file files.File) (cmds.Response, error) {
+	cmd, err := corecommands.Root.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	optDefs, err := corecommands.Root.GetOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := cmds.NewRequest(path, opts, args, file, cmd, optDefs)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.SetRootContext(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	if e := res.Error(); e != nil {
+		res.Close()
+		return nil, e
+	}
+	return res, nil
+}
+
+// Add adds the content of r, and returns its hash.
+func (c *Client) Add(ctx context.Context, r io.Reader) (string, error) {
+	rf := files.NewReaderFile("", "", ioutil.NopCloser(r), nil)
+	file := files.NewSliceFile("", "", []files.File{rf})
+	res, err := c.send(ctx, []string{"add"}, nil, nil, file)
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	out, ok := res.Output().(<-chan interface{})
+	if !ok {
+		return "", u.ErrCast()
+	}
+	var hash string
+	for v := range out {
+		added, ok := v.(*corecommands.AddedObject)
+		if !ok {
+			return "", u.ErrCast()
+		}
+		if added.Hash != "" {
+			hash = added.Hash
+		}
+	}
+	if e := res.Error(); e != nil {
+		return "", e
+	}
+	if hash == "" {
+		return "", errors.New("the daemon returned no hash")
+	}
+	return hash, nil
+}
+
+// Cat returns the content of the file at the ipfs path p. The reader has
+// to be closed.
+func (c *Client) Cat(ctx context.Context, p string) (io.ReadCloser, error) {
+	res, err := c.send(ctx, []string{"cat"}, nil, []string{p}, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := res.Output().(io.Reader)
+	if !ok {
+		res.Close()
+		return nil, u.ErrCast()
+	}
+	return &responseReader{r, res}, nil
+}
+
+type responseReader struct {
+	io.Reader
+	res cmds.Response
+}
+
+func (r *responseReader) Close() error {
+	return r.res.Close()
+}
+
+// PinAdd pins the object at the ipfs path p, and everything it links to
+// with recursive. It returns the hashes of the pinned objects.
+func (c *Client) PinAdd(ctx context.Context, p string, recursive bool) ([]string, error) {
+	opts := cmds.OptMap{"recursive": recursive}
+	res, err := c.send(ctx, []string{"pin", "add"}, opts, []string{p}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	out, ok := res.Output().(*corecommands.PinOutput)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+	hashes := make([]string, len(out.Pinned))
+	for i, k := range out.Pinned {
+		hashes[i] = k.B58String()
+	}
+	return hashes, nil
+}
+
+// FilesWrite writes the content of r to the file at the files path p,
+// starting at offset. The file is created with create, and emptied first
+// with truncate.
+func (c *Client) FilesWrite(ctx context.Context, p string, r io.Reader, offset int, create, truncate bool) error {
+	opts := cmds.OptMap{
+		"offset":   offset,
+		"create":   create,
+		"truncate": truncate,
+	}
+	rf := files.NewReaderFile("", "", ioutil.NopCloser(r), nil)
+	file := files.NewSliceFile("", "", []files.File{rf})
+	res, err := c.send(ctx, []string{"files", "write"}, opts, []string{p}, file)
+	if err != nil {
+		return err
+	}
+	return res.Close()
+}
+
+// NameResolve returns the path an ipns name points to.
+func (c *Client) NameResolve(ctx context.Context, name string) (string, error) {
+	res, err := c.send(ctx, []string{"name", "resolve"}, nil, []string{name}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	out, ok := res.Output().(*corecommands.ResolvedPath)
+	if !ok {
+		return "", u.ErrCast()
+	}
+	return out.Path.String(), nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	core "github.com/ipfs/go-ipfs/core"
+	corecommands "github.com/ipfs/go-ipfs/core/commands"
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	path "github.com/ipfs/go-ipfs/path"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+func TestClient(t *testing.T) {
+	cctx, err := coremock.MockCmdsCtx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(cmdsHttp.NewHandler(cctx, corecommands.Root, cmdsHttp.NewServerConfig()))
+	defer server.Close()
+
+	c := New(strings.TrimPrefix(server.URL, "http://"))
+	ctx := context.Background()
+
+	data := []byte("some content for the api client")
+	hash, err := c.Add(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Cat(ctx, "/ipfs/"+hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("cat returned %q, expected %q", got, data)
+	}
+
+	pinned, err := c.PinAdd(ctx, "/ipfs/"+hash, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pinned) != 1 || pinned[0] != hash {
+		t.Fatalf("expected %s to be pinned, got %v", hash, pinned)
+	}
+
+	if _, err := c.Cat(ctx, "/ipfs/notahash"); err == nil {
+		t.Fatal("expected an error for an invalid path")
+	}
+
+	n, err := cctx.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a name published through the node resolves to the same path
+	sk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), sk)
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), nil, namesys.Opts{})
+	p := path.FromString("/ipfs/" + hash)
+	if err := n.Namesys.Publish(ctx, sk, p); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := c.NameResolve(ctx, "/ipns/"+id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != p.String() {
+		t.Fatalf("resolved %s, expected %s", resolved, p)
+	}
+
+	// a file written through the api reads back from the node
+	if err := c.FilesWrite(ctx, "/afile", strings.NewReader("hello files"), 0, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FilesWrite(ctx, "/afile", strings.NewReader("world"), 6, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FilesWrite(ctx, "/nofile", strings.NewReader("data"), 0, false, false); err == nil {
+		t.Fatal("expected an error writing to a missing file without create")
+	}
+	expectFile(t, n, "/afile", "hello world")
+
+	if err := c.FilesWrite(ctx, "/afile", strings.NewReader("short"), 0, false, true); err != nil {
+		t.Fatal(err)
+	}
+	expectFile(t, n, "/afile", "short")
+}
+
+// expectFile checks the content of the file at the files path p of n.
+func expectFile(t *testing.T, n *core.IpfsNode, p string, exp string) {
+	fsn, err := mfs.Lookup(n.FilesRoot, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := fsn.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := uio.NewDagReader(context.Background(), nd, n.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != exp {
+		t.Fatalf("%s has %q, expected %q", p, got, exp)
+	}
+}
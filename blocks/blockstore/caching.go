@@ -0,0 +1,193 @@
+package blockstore
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/hashicorp/golang-lru"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/bloom"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// CacheOpts configures the caches of a CachedBlockstore. A size of zero
+// disables the corresponding cache.
+type CacheOpts struct {
+	// HasBloomFilterSize is the size of the bloom filter in bytes.
+	HasBloomFilterSize int
+
+	// HasLRUSize is the number of keys whose presence is remembered, in an
+	// LRU cache filled by Has, Get and Put.
+	HasLRUSize int
+}
+
+// CachedBlockstore returns a blockstore that answers Has calls, and Get calls
+// for missing blocks, from memory where it can. A bloom filter of all keys
+// gives definite answers for blocks that are not stored, and whether the
+// recently used keys are present is kept in an LRU cache. Blocks themselves
+// are not cached. The bloom filter is filled from the keys already
+// in bs in the background, and is only consulted once that is done.
+func CachedBlockstore(bs Blockstore, ctx context.Context, opts CacheOpts) (Blockstore, error) {
+	cb := &cachedbs{blockstore: bs}
+
+	if opts.HasLRUSize > 0 {
+		c, err := lru.New(opts.HasLRUSize)
+		if err != nil {
+			return nil, err
+		}
+		cb.cache = c
+	}
+
+	if opts.HasBloomFilterSize > 0 {
+		cb.bloom = bloom.NewFilter(opts.HasBloomFilterSize)
+		keys, err := bs.AllKeysChan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		go cb.fillBloom(ctx, keys)
+	}
+
+	return cb, nil
+}
+
+type cachedbs struct {
+	blockstore Blockstore
+
+	cache *lru.Cache // of key.Key -> bool, may be nil
+
+	bloomLk     sync.Mutex   // the filter keeps hashing state, even for lookups
+	bloom       bloom.Filter // may be nil
+	bloomActive int32        // set once the bloom filter holds all keys
+}
+
+func (b *cachedbs) bloomAdd(k key.Key) {
+	b.bloomLk.Lock()
+	b.bloom.Add([]byte(k))
+	b.bloomLk.Unlock()
+}
+
+func (b *cachedbs) bloomFind(k key.Key) bool {
+	b.bloomLk.Lock()
+	defer b.bloomLk.Unlock()
+	return b.bloom.Find([]byte(k))
+}
+
+func (b *cachedbs) fillBloom(ctx context.Context, keys <-chan key.Key) {
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				atomic.StoreInt32(&b.bloomActive, 1)
+				log.Debug("blockstore bloom filter ready")
+				return
+			}
+			b.bloomAdd(k)
+		case <-ctx.Done():
+			log.Warning("blockstore bloom filter was not filled: ", ctx.Err())
+			return
+		}
+	}
+}
+
+// hasCached returns whether the presence of k is known without asking the
+// underlying blockstore, and if so whether it is present.
+func (b *cachedbs) hasCached(k key.Key) (has, ok bool) {
+	if b.bloom != nil && atomic.LoadInt32(&b.bloomActive) == 1 && !b.bloomFind(k) {
+		return false, true
+	}
+	if b.cache != nil {
+		if v, ok := b.cache.Get(k); ok {
+			return v.(bool), true
+		}
+	}
+	return false, false
+}
+
+func (b *cachedbs) setCached(k key.Key, has bool) {
+	if b.cache != nil {
+		b.cache.Add(k, has)
+	}
+	if has && b.bloom != nil {
+		b.bloomAdd(k)
+	}
+}
+
+func (b *cachedbs) DeleteBlock(k key.Key) error {
+	err := b.blockstore.DeleteBlock(k)
+	if err == nil || err == ErrNotFound {
+		if b.cache != nil {
+			b.cache.Add(k, false)
+		}
+	}
+	return err
+}
+
+func (b *cachedbs) Has(k key.Key) (bool, error) {
+	if has, ok := b.hasCached(k); ok {
+		return has, nil
+	}
+
+	has, err := b.blockstore.Has(k)
+	if err != nil {
+		return false, err
+	}
+	b.setCached(k, has)
+	return has, nil
+}
+
+func (b *cachedbs) Get(k key.Key) (*blocks.Block, error) {
+	if has, ok := b.hasCached(k); ok && !has {
+		return nil, ErrNotFound
+	}
+
+	blk, err := b.blockstore.Get(k)
+	switch err {
+	case nil:
+		b.setCached(k, true)
+	case ErrNotFound:
+		b.setCached(k, false)
+	}
+	return blk, err
+}
+
+func (b *cachedbs) Put(blk *blocks.Block) error {
+	if has, ok := b.hasCached(blk.Key()); ok && has {
+		return nil
+	}
+
+	// add to the bloom filter before the block is written, so a concurrent
+	// Has never sees a false negative.
+	if b.bloom != nil {
+		b.bloomAdd(blk.Key())
+	}
+	if err := b.blockstore.Put(blk); err != nil {
+		return err
+	}
+	b.setCached(blk.Key(), true)
+	return nil
+}
+
+func (b *cachedbs) PutMany(bs []*blocks.Block) error {
+	var good []*blocks.Block
+	for _, blk := range bs {
+		if has, ok := b.hasCached(blk.Key()); !ok || !has {
+			good = append(good, blk)
+			if b.bloom != nil {
+				b.bloomAdd(blk.Key())
+			}
+		}
+	}
+
+	if err := b.blockstore.PutMany(good); err != nil {
+		return err
+	}
+	for _, blk := range good {
+		b.setCached(blk.Key(), true)
+	}
+	return nil
+}
+
+func (b *cachedbs) AllKeysChan(ctx context.Context) (<-chan key.Key, error) {
+	return b.blockstore.AllKeysChan(ctx)
+}
@@ -0,0 +1,97 @@
+package blockstore
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	syncds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/blocks"
+)
+
+func waitForBloom(t *testing.T, bs Blockstore) {
+	cb := bs.(*cachedbs)
+	for i := 0; i < 100; i++ {
+		if atomic.LoadInt32(&cb.bloomActive) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("bloom filter was never filled")
+}
+
+func TestCachedBlockstoreBloom(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	existing := blocks.NewBlock([]byte("existing"))
+	cd := &callbackDatastore{f: func() {}, ds: ds.NewMapDatastore()}
+	bs := NewBlockstore(syncds.MutexWrap(cd))
+	if err := bs.Put(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := CacheOpts{HasBloomFilterSize: 1024}
+	cbs, err := CachedBlockstore(bs, ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForBloom(t, cbs)
+
+	if has, err := cbs.Has(existing.Key()); err != nil || !has {
+		t.Fatal("block present before the cache was created not found")
+	}
+
+	hitDatastore := false
+	cd.SetFunc(func() { hitDatastore = true })
+
+	missing := blocks.NewBlock([]byte("missing"))
+	if has, _ := cbs.Has(missing.Key()); has {
+		t.Fatal("found block that was never added")
+	}
+	if _, err := cbs.Get(missing.Key()); err != ErrNotFound {
+		t.Fatal("expected ErrNotFound, got", err)
+	}
+	if hitDatastore {
+		t.Fatal("bloom filter should have answered for a missing block")
+	}
+
+	if err := cbs.Put(missing); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := cbs.Has(missing.Key()); !has {
+		t.Fatal("added block not found")
+	}
+}
+
+func TestCachedBlockstoreHasCache(t *testing.T) {
+	b := blocks.NewBlock([]byte("foo"))
+	cd := &callbackDatastore{f: func() {}, ds: ds.NewMapDatastore()}
+	bs := NewBlockstore(syncds.MutexWrap(cd))
+
+	cbs, err := CachedBlockstore(bs, context.Background(), CacheOpts{HasLRUSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cbs.Put(b); err != nil {
+		t.Fatal(err)
+	}
+
+	hitDatastore := false
+	cd.SetFunc(func() { hitDatastore = true })
+	if has, _ := cbs.Has(b.Key()); !has {
+		t.Fatal("added block not found")
+	}
+	if hitDatastore {
+		t.Fatal("Has should have been answered from the cache")
+	}
+
+	if err := cbs.DeleteBlock(b.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := cbs.Has(b.Key()); has {
+		t.Fatal("deleted block still reported as present")
+	}
+}
@@ -0,0 +1,180 @@
+package blockstore
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsns "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/namespace"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/syndtr/gosnappy/snappy"
+)
+
+// Compressed blocks are stored with a header made of compressMagic and a
+// byte naming the codec. Blocks are keyed by the hash of their uncompressed
+// data, so compression does not change their keys, and repos can hold a mix
+// of compressed and uncompressed blocks.
+const compressMagic = "\x00ipz"
+
+const (
+	codecNone   byte = 0
+	codecSnappy byte = 1
+	codecZlib   byte = 2
+)
+
+var codecsByName = map[string]byte{
+	"":       codecNone,
+	"none":   codecNone,
+	"snappy": codecSnappy,
+	"zlib":   codecZlib,
+}
+
+var errUnknownCodec = errors.New("blockstore: block compressed with an unknown codec")
+
+// NewCompressedBlockstore is like NewBlockstore, but compresses the data of
+// the blocks it stores with the named codec: "snappy", "zlib", or "none".
+// Compressed blocks are read back whichever codec is set, so compression
+// can be turned on or off for an existing repo.
+func NewCompressedBlockstore(d ds.ThreadSafeDatastore, codec string) (Blockstore, error) {
+	c, ok := codecsByName[codec]
+	if !ok {
+		return nil, fmt.Errorf("unknown block compression '%s'", codec)
+	}
+
+	return &blockstore{
+		datastore: &compressds{
+			Batching: dsns.Wrap(d, BlockPrefix),
+			codec:    c,
+		},
+	}, nil
+}
+
+// compressds compresses the values stored in a datastore.
+type compressds struct {
+	ds.Batching
+	codec byte
+}
+
+func compress(codec byte, data []byte) ([]byte, error) {
+	var out []byte
+	switch codec {
+	case codecNone:
+	case codecSnappy:
+		enc, err := snappy.Encode(nil, data)
+		if err != nil {
+			return nil, err
+		}
+		out = enc
+	case codecZlib:
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	default:
+		return nil, errUnknownCodec
+	}
+
+	header := len(compressMagic) + 1
+	if out == nil || len(out)+header >= len(data) {
+		// not worth compressing. the data is still wrapped if it could
+		// be mistaken for a header.
+		if !bytes.HasPrefix(data, []byte(compressMagic)) {
+			return data, nil
+		}
+		out, codec = data, codecNone
+	}
+
+	wrapped := make([]byte, 0, header+len(out))
+	wrapped = append(wrapped, compressMagic...)
+	wrapped = append(wrapped, codec)
+	return append(wrapped, out...), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	header := len(compressMagic) + 1
+	if len(data) < header || !bytes.HasPrefix(data, []byte(compressMagic)) {
+		return data, nil
+	}
+
+	body := data[header:]
+	switch data[header-1] {
+	case codecNone:
+		return body, nil
+	case codecSnappy:
+		return snappy.Decode(nil, body)
+	case codecZlib:
+		r, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	default:
+		return nil, errUnknownCodec
+	}
+}
+
+func (c *compressds) Put(k ds.Key, v interface{}) error {
+	data, ok := v.([]byte)
+	if !ok {
+		return ds.ErrInvalidType
+	}
+	out, err := compress(c.codec, data)
+	if err != nil {
+		return err
+	}
+	return c.Batching.Put(k, out)
+}
+
+func (c *compressds) Get(k ds.Key) (interface{}, error) {
+	v, err := c.Batching.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, ValueTypeMismatch
+	}
+	return decompress(data)
+}
+
+// Query only supports listing keys, which is all the blockstore needs.
+func (c *compressds) Query(q dsq.Query) (dsq.Results, error) {
+	if !q.KeysOnly {
+		return nil, errors.New("blockstore: compressed datastore can only be queried for keys")
+	}
+	return c.Batching.Query(q)
+}
+
+func (c *compressds) Batch() (ds.Batch, error) {
+	b, err := c.Batching.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &compressBatch{Batch: b, codec: c.codec}, nil
+}
+
+type compressBatch struct {
+	ds.Batch
+	codec byte
+}
+
+func (b *compressBatch) Put(k ds.Key, v interface{}) error {
+	data, ok := v.([]byte)
+	if !ok {
+		return ds.ErrInvalidType
+	}
+	out, err := compress(b.codec, data)
+	if err != nil {
+		return err
+	}
+	return b.Batch.Put(k, out)
+}
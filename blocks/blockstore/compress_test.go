@@ -0,0 +1,71 @@
+package blockstore
+
+import (
+	"bytes"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsns "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/namespace"
+	ds_sync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+)
+
+func TestCompressedBlockstore(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	raw := dsns.Wrap(d, BlockPrefix)
+
+	compressible := blocks.NewBlock(bytes.Repeat([]byte("compress me "), 100))
+	tiny := blocks.NewBlock([]byte("tiny"))
+	lookalike := blocks.NewBlock([]byte(compressMagic + "\x07not a header"))
+	plain := blocks.NewBlock([]byte("stored before compression was enabled"))
+
+	if err := NewBlockstore(d).Put(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, codec := range []string{"snappy", "zlib"} {
+		bs, err := NewCompressedBlockstore(d, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		all := []*blocks.Block{compressible, tiny, lookalike}
+		if err := bs.PutMany(all); err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := raw.Get(compressible.Key().DsKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(v.([]byte)) >= len(compressible.Data) {
+			t.Fatalf("%s: block was not compressed", codec)
+		}
+
+		// reading works with compression turned off
+		off, err := NewCompressedBlockstore(d, "none")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range append(all, plain) {
+			got, err := off.Get(b.Key())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Data, b.Data) {
+				t.Fatalf("%s: block %s read back wrong", codec, b.Key())
+			}
+		}
+
+		for _, b := range all {
+			if err := bs.DeleteBlock(b.Key()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if _, err := NewCompressedBlockstore(d, "lzma"); err == nil {
+		t.Fatal("expected an unknown codec to be rejected")
+	}
+}
@@ -0,0 +1,79 @@
+package blockstore
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Unlocker releases a lock taken on a GCLocker.
+type Unlocker interface {
+	Unlock()
+}
+
+// GCLocker coordinates garbage collection with operations that add blocks
+// and then pin them. Blocks written while holding a pin lock are safe from
+// collection until the lock is released, by which time they are expected to
+// be pinned.
+type GCLocker interface {
+	// GCLock locks the blockstore for garbage collection. No operations
+	// that expect to finish with a pin may run while it is held.
+	GCLock() Unlocker
+
+	// PinLock locks the blockstore against garbage collection for the
+	// duration of an operation that writes blocks and then pins them.
+	PinLock() Unlocker
+
+	// GCRequested returns whether a garbage collection is waiting for the
+	// pin locks to be released, so long running operations can yield.
+	GCRequested() bool
+}
+
+// GCBlockstore is a blockstore that can be safely garbage collected.
+type GCBlockstore interface {
+	Blockstore
+	GCLocker
+}
+
+// NewGCBlockstore returns a blockstore that coordinates garbage collection
+// through the given locker.
+func NewGCBlockstore(bs Blockstore, gcl GCLocker) GCBlockstore {
+	return gcBlockstore{Blockstore: bs, GCLocker: gcl}
+}
+
+type gcBlockstore struct {
+	Blockstore
+	GCLocker
+}
+
+// NewGCLocker returns a GCLocker that lets any number of pin locks be held
+// at once, but only a single GC lock, exclusive of all pin locks.
+func NewGCLocker() GCLocker {
+	return new(gclocker)
+}
+
+type gclocker struct {
+	lk    sync.RWMutex
+	gcreq int32
+}
+
+type unlocker func()
+
+func (u unlocker) Unlock() {
+	u()
+}
+
+func (g *gclocker) GCLock() Unlocker {
+	atomic.AddInt32(&g.gcreq, 1)
+	g.lk.Lock()
+	atomic.AddInt32(&g.gcreq, -1)
+	return unlocker(g.lk.Unlock)
+}
+
+func (g *gclocker) PinLock() Unlocker {
+	g.lk.RLock()
+	return unlocker(g.lk.RUnlock)
+}
+
+func (g *gclocker) GCRequested() bool {
+	return atomic.LoadInt32(&g.gcreq) > 0
+}
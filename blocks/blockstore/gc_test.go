@@ -0,0 +1,47 @@
+package blockstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGCLockWaitsForPinLock(t *testing.T) {
+	gcl := NewGCLocker()
+
+	pinned := gcl.PinLock()
+	if gcl.GCRequested() {
+		t.Fatal("no gc should be requested yet")
+	}
+
+	locked := make(chan Unlocker)
+	go func() {
+		locked <- gcl.GCLock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("gc lock taken while a pin lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !gcl.GCRequested() {
+		t.Fatal("expected a pending gc to be reported")
+	}
+
+	pinned.Unlock()
+	var gc Unlocker
+	select {
+	case gc = <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("gc lock not taken after the pin lock was released")
+	}
+	if gcl.GCRequested() {
+		t.Fatal("gc request should be cleared once the lock is held")
+	}
+	gc.Unlock()
+
+	// pin locks don't exclude each other
+	a := gcl.PinLock()
+	b := gcl.PinLock()
+	a.Unlock()
+	b.Unlock()
+}
@@ -0,0 +1,380 @@
+package blockstore
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// statsDatastoreKey is where the stats are saved when the blockstore is
+// closed.
+var statsDatastoreKey = ds.NewKey("/local/blockstore/stats")
+
+// HistogramBuckets is the number of buckets in Stat.SizeHistogram.
+const HistogramBuckets = 22
+
+// Stat summarizes the blocks held by a blockstore.
+type Stat struct {
+	NumBlocks uint64
+	TotalSize uint64
+
+	// SizeHistogram counts blocks by size. Bucket i holds the blocks of
+	// more than 2^(i-1) and at most 2^i bytes, the last bucket also holds
+	// all larger blocks.
+	SizeHistogram []uint64
+
+	// Counting is set while the blocks are being counted, after the
+	// blockstore was not closed cleanly. The counts are too low until then.
+	Counting bool `json:"-"`
+}
+
+// counters hold the stats, updated atomically so that writes to different
+// blocks don't contend on a lock.
+type counters struct {
+	numBlocks uint64
+	totalSize uint64
+	hist      [HistogramBuckets]uint64
+}
+
+func (c *counters) add(size int, sign int) {
+	b := 0
+	for b < HistogramBuckets-1 && size > 1<<uint(b) {
+		b++
+	}
+
+	n := uint64(size)
+	if sign > 0 {
+		atomic.AddUint64(&c.numBlocks, 1)
+		atomic.AddUint64(&c.totalSize, n)
+		atomic.AddUint64(&c.hist[b], 1)
+	} else {
+		// adding ^(n-1) subtracts n
+		atomic.AddUint64(&c.numBlocks, ^uint64(0))
+		atomic.AddUint64(&c.totalSize, ^(n - 1))
+		atomic.AddUint64(&c.hist[b], ^uint64(0))
+	}
+}
+
+func (c *counters) load() Stat {
+	st := Stat{
+		NumBlocks:     atomic.LoadUint64(&c.numBlocks),
+		TotalSize:     atomic.LoadUint64(&c.totalSize),
+		SizeHistogram: make([]uint64, HistogramBuckets),
+	}
+	for i := range c.hist {
+		st.SizeHistogram[i] = atomic.LoadUint64(&c.hist[i])
+	}
+	return st
+}
+
+func (c *counters) store(st Stat) {
+	atomic.StoreUint64(&c.numBlocks, st.NumBlocks)
+	atomic.StoreUint64(&c.totalSize, st.TotalSize)
+	for i := range c.hist {
+		var v uint64
+		if i < len(st.SizeHistogram) {
+			v = st.SizeHistogram[i]
+		}
+		atomic.StoreUint64(&c.hist[i], v)
+	}
+}
+
+// savedStats is the stat record kept in the datastore. Clean is only set
+// when the stats were saved on close, and are known to be accurate.
+type savedStats struct {
+	Stat
+	Clean bool
+}
+
+// StatBlockstore is a blockstore that keeps track of the number and size
+// of the blocks it holds as they are added and removed.
+type StatBlockstore interface {
+	Blockstore
+
+	// Stat returns the current block counts and sizes.
+	Stat() Stat
+
+	// Close saves the stats, so they don't need to be recounted the next
+	// time the blockstore is opened.
+	Close() error
+}
+
+// NewStatBlockstore returns a blockstore that counts the blocks in bs,
+// saving the counts in d when closed. If d holds no counts saved by a clean
+// close, the blocks are counted again in the background, see Stat.Counting.
+func NewStatBlockstore(bs Blockstore, d ds.Datastore) (StatBlockstore, error) {
+	s := &statbs{
+		Blockstore: bs,
+		d:          d,
+		counted:    make(chan struct{}),
+	}
+
+	saved, err := loadStats(d)
+	if err != nil {
+		return nil, err
+	}
+
+	// until closed, the saved stats can't be trusted
+	if err := s.save(false); err != nil {
+		return nil, err
+	}
+
+	if saved != nil && saved.Clean {
+		s.stat.store(saved.Stat)
+		close(s.counted)
+	} else {
+		for i := range s.written {
+			s.written[i] = make(map[key.Key]struct{})
+		}
+		var ctx context.Context
+		ctx, s.cancel = context.WithCancel(context.Background())
+		log.Info("counting the blocks in the blockstore in the background")
+		go s.recount(ctx)
+	}
+	return s, nil
+}
+
+func loadStats(d ds.Datastore) (*savedStats, error) {
+	v, err := d.Get(statsDatastoreKey)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf, ok := v.([]byte)
+	if !ok {
+		return nil, ValueTypeMismatch
+	}
+
+	saved := new(savedStats)
+	if err := json.Unmarshal(buf, saved); err != nil {
+		log.Warningf("discarding malformed blockstore stats: %s", err)
+		return nil, nil
+	}
+	return saved, nil
+}
+
+// statLocks is the number of locks writes to the blockstore are spread over.
+const statLocks = 64
+
+type statbs struct {
+	// stat is first to keep its counters 64-bit aligned
+	stat counters
+
+	Blockstore
+	d ds.Datastore
+
+	// locks serialize the writes to the same block, so it is never counted
+	// twice when added concurrently. a block is guarded by the lock of its
+	// stripe, see stripe.
+	locks [statLocks]sync.Mutex
+
+	// written holds, per stripe, the blocks added while the blocks are
+	// counted, which the count must skip as they are counted already. nil
+	// once counted.
+	written [statLocks]map[key.Key]struct{}
+
+	// inexact is set when the counts may be wrong, for them to be
+	// recounted on the next open. accessed atomically.
+	inexact int32
+
+	counted chan struct{} // closed once the blocks are counted
+	cancel  func()        // stops the count, nil if not counting
+}
+
+// stripe returns the index of the lock guarding the writes to k.
+func stripe(k key.Key) int {
+	if len(k) == 0 {
+		return 0
+	}
+	return int(k[len(k)-1]) % statLocks
+}
+
+// recount counts the blocks in the background. The keys are listed without
+// their blocks, each block is then read for its size: the blockstore may
+// store it compressed.
+func (s *statbs) recount(ctx context.Context) {
+	defer close(s.counted)
+	defer func() {
+		for i := range s.written {
+			s.locks[i].Lock()
+			s.written[i] = nil
+			s.locks[i].Unlock()
+		}
+	}()
+
+	keys, err := s.Blockstore.AllKeysChan(ctx)
+	if err != nil {
+		log.Errorf("counting blocks: %s", err)
+		s.setInexact()
+		return
+	}
+
+	for k := range keys {
+		s.countBlock(k)
+	}
+
+	if ctx.Err() != nil {
+		s.setInexact()
+	}
+}
+
+func (s *statbs) countBlock(k key.Key) {
+	i := stripe(k)
+	s.locks[i].Lock()
+	defer s.locks[i].Unlock()
+
+	if _, ok := s.written[i][k]; ok {
+		return
+	}
+
+	blk, err := s.Blockstore.Get(k)
+	switch err {
+	case nil:
+		s.stat.add(len(blk.Data), 1)
+	case ErrNotFound:
+	default:
+		log.Warningf("counting block %s: %s", k, err)
+		s.setInexact()
+	}
+}
+
+func (s *statbs) setInexact() {
+	atomic.StoreInt32(&s.inexact, 1)
+}
+
+// added counts a block added to the blockstore. must be called with the
+// lock of its stripe held.
+func (s *statbs) added(k key.Key, size int) {
+	s.stat.add(size, 1)
+	if w := s.written[stripe(k)]; w != nil {
+		w[k] = struct{}{}
+	}
+}
+
+// removed uncounts a block removed from the blockstore. must be called with
+// the lock of its stripe held.
+func (s *statbs) removed(k key.Key, size int) {
+	if w := s.written[stripe(k)]; w != nil {
+		if _, ok := w[k]; !ok {
+			// the count may or may not have seen the block yet
+			s.setInexact()
+			return
+		}
+		delete(w, k)
+	}
+	s.stat.add(size, -1)
+}
+
+func (s *statbs) Stat() Stat {
+	st := s.stat.load()
+	select {
+	case <-s.counted:
+	default:
+		st.Counting = true
+	}
+	return st
+}
+
+func (s *statbs) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	<-s.counted
+
+	return s.save(atomic.LoadInt32(&s.inexact) == 0)
+}
+
+func (s *statbs) save(clean bool) error {
+	buf, err := json.Marshal(&savedStats{Stat: s.stat.load(), Clean: clean})
+	if err != nil {
+		return err
+	}
+	return s.d.Put(statsDatastoreKey, buf)
+}
+
+func (s *statbs) Put(b *blocks.Block) error {
+	k := b.Key()
+	mu := &s.locks[stripe(k)]
+	mu.Lock()
+	defer mu.Unlock()
+
+	has, err := s.Blockstore.Has(k)
+	if err != nil {
+		return err
+	}
+	if err := s.Blockstore.Put(b); err != nil {
+		return err
+	}
+	if !has {
+		s.added(k, len(b.Data))
+	}
+	return nil
+}
+
+func (s *statbs) PutMany(bs []*blocks.Block) error {
+	// take the locks of all the blocks, in order
+	var stripes [statLocks]bool
+	for _, b := range bs {
+		stripes[stripe(b.Key())] = true
+	}
+	for i, ok := range stripes {
+		if ok {
+			s.locks[i].Lock()
+			defer s.locks[i].Unlock()
+		}
+	}
+
+	var added []*blocks.Block
+	seen := make(map[key.Key]struct{})
+	for _, b := range bs {
+		if _, ok := seen[b.Key()]; ok {
+			continue
+		}
+		seen[b.Key()] = struct{}{}
+
+		has, err := s.Blockstore.Has(b.Key())
+		if err != nil {
+			return err
+		}
+		if !has {
+			added = append(added, b)
+		}
+	}
+
+	if err := s.Blockstore.PutMany(bs); err != nil {
+		return err
+	}
+	for _, b := range added {
+		s.added(b.Key(), len(b.Data))
+	}
+	return nil
+}
+
+func (s *statbs) DeleteBlock(k key.Key) error {
+	mu := &s.locks[stripe(k)]
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the size of the block is needed to update the stats. corrupt blocks
+	// must still be deletable, leaving the stats to be recounted.
+	blk, err := s.Blockstore.Get(k)
+	if err != nil && err != ErrHashMismatch {
+		return err
+	}
+	if err := s.Blockstore.DeleteBlock(k); err != nil {
+		return err
+	}
+	if blk != nil {
+		s.removed(k, len(blk.Data))
+	} else {
+		s.setInexact()
+	}
+	return nil
+}
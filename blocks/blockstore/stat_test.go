@@ -0,0 +1,150 @@
+package blockstore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	ds_sync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+)
+
+func checkStat(t *testing.T, st Stat, num, size uint64) {
+	if st.NumBlocks != num || st.TotalSize != size {
+		t.Fatalf("expected %d blocks of %d bytes, got %d blocks of %d bytes", num, size, st.NumBlocks, st.TotalSize)
+	}
+	var total uint64
+	for _, c := range st.SizeHistogram {
+		total += c
+	}
+	if total != num {
+		t.Fatalf("histogram counts %d blocks, expected %d", total, num)
+	}
+}
+
+// waitCounted waits for the blocks to be counted in the background.
+func waitCounted(sbs StatBlockstore) {
+	<-sbs.(*statbs).counted
+}
+
+func TestStatBlockstore(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	// blocks already stored are counted when first opened
+	if err := bs.Put(blocks.NewBlock([]byte("existing"))); err != nil {
+		t.Fatal(err)
+	}
+
+	sbs, err := NewStatBlockstore(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCounted(sbs)
+	checkStat(t, sbs.Stat(), 1, 8)
+
+	a := blocks.NewBlock(make([]byte, 100))
+	b := blocks.NewBlock(make([]byte, 3000))
+	if err := sbs.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := sbs.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := sbs.PutMany([]*blocks.Block{a, b, b}); err != nil {
+		t.Fatal(err)
+	}
+	checkStat(t, sbs.Stat(), 3, 3108)
+
+	hist := sbs.Stat().SizeHistogram
+	if hist[3] != 1 || hist[7] != 1 || hist[12] != 1 {
+		t.Fatalf("unexpected histogram %v", hist)
+	}
+
+	if err := sbs.DeleteBlock(a.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sbs.DeleteBlock(a.Key()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	checkStat(t, sbs.Stat(), 2, 3008)
+
+	if err := sbs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// change the blocks behind the stats' back: a clean close means the
+	// saved stats are trusted, and nothing is recounted
+	if err := bs.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	sbs, err = NewStatBlockstore(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStat(t, sbs.Stat(), 2, 3008)
+
+	// without a clean close, the blocks are recounted
+	sbs, err = NewStatBlockstore(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCounted(sbs)
+	if sbs.Stat().Counting {
+		t.Fatal("still counting once counted")
+	}
+	checkStat(t, sbs.Stat(), 3, 3108)
+}
+
+func TestStatBlockstoreWriteWhileCounting(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bs := NewBlockstore(d)
+
+	var size uint64
+	var existing []*blocks.Block
+	for i := 0; i < 100; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("existing block %d", i)))
+		if err := bs.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		existing = append(existing, b)
+		size += uint64(len(b.Data))
+	}
+
+	sbs, err := NewStatBlockstore(bs, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// blocks added while counting, new or not, are counted once
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("new block %d", i)))
+		size += uint64(len(b.Data))
+		wg.Add(1)
+		go func(b *blocks.Block) {
+			defer wg.Done()
+			for _, b := range []*blocks.Block{b, existing[len(b.Data)%len(existing)], b} {
+				if err := sbs.Put(b); err != nil {
+					t.Error(err)
+				}
+			}
+		}(b)
+	}
+	wg.Wait()
+	waitCounted(sbs)
+	checkStat(t, sbs.Stat(), 200, size)
+
+	if err := sbs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := loadStats(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Clean {
+		t.Fatal("exact counts were not saved as clean")
+	}
+}
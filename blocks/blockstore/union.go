@@ -0,0 +1,114 @@
+package blockstore
+
+import (
+	"errors"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+var ErrReadOnly = errors.New("blockstore: read-only")
+
+// NewReadOnlyBlockstore returns a blockstore that serves the blocks of bs,
+// but refuses all writes with ErrReadOnly.
+func NewReadOnlyBlockstore(bs Blockstore) Blockstore {
+	return &readonlybs{bs}
+}
+
+type readonlybs struct {
+	Blockstore
+}
+
+func (*readonlybs) Put(*blocks.Block) error {
+	return ErrReadOnly
+}
+
+func (*readonlybs) PutMany([]*blocks.Block) error {
+	return ErrReadOnly
+}
+
+func (*readonlybs) DeleteBlock(key.Key) error {
+	return ErrReadOnly
+}
+
+// NewUnionBlockstore returns a blockstore that reads blocks from each of
+// the given stores in turn, and writes to the first. Blocks already held
+// by any of the stores are not written again, so a local store can be
+// layered over a shared archive of blocks, such as a read-only network
+// mount, without duplicating it.
+//
+// Only blocks in the first store belong to the union: AllKeysChan lists
+// those, and DeleteBlock removes them. Blocks in the other stores are
+// shared, and are left alone.
+func NewUnionBlockstore(first Blockstore, rest ...Blockstore) Blockstore {
+	return &unionbs{stores: append([]Blockstore{first}, rest...)}
+}
+
+type unionbs struct {
+	stores []Blockstore
+}
+
+func (u *unionbs) Has(k key.Key) (bool, error) {
+	for _, bs := range u.stores {
+		has, err := bs.Has(k)
+		if err != nil || has {
+			return has, err
+		}
+	}
+	return false, nil
+}
+
+func (u *unionbs) Get(k key.Key) (*blocks.Block, error) {
+	for _, bs := range u.stores {
+		blk, err := bs.Get(k)
+		if err != ErrNotFound {
+			return blk, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// hasShared returns whether any store but the first holds k.
+func (u *unionbs) hasShared(k key.Key) (bool, error) {
+	for _, bs := range u.stores[1:] {
+		has, err := bs.Has(k)
+		if err != nil || has {
+			return has, err
+		}
+	}
+	return false, nil
+}
+
+func (u *unionbs) Put(b *blocks.Block) error {
+	has, err := u.hasShared(b.Key())
+	if err != nil || has {
+		return err
+	}
+	return u.stores[0].Put(b)
+}
+
+func (u *unionbs) PutMany(bs []*blocks.Block) error {
+	var missing []*blocks.Block
+	for _, b := range bs {
+		has, err := u.hasShared(b.Key())
+		if err != nil {
+			return err
+		}
+		if !has {
+			missing = append(missing, b)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return u.stores[0].PutMany(missing)
+}
+
+func (u *unionbs) DeleteBlock(k key.Key) error {
+	return u.stores[0].DeleteBlock(k)
+}
+
+func (u *unionbs) AllKeysChan(ctx context.Context) (<-chan key.Key, error) {
+	return u.stores[0].AllKeysChan(ctx)
+}
@@ -0,0 +1,94 @@
+package blockstore
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	ds_sync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+)
+
+func newMapBlockstore() Blockstore {
+	return NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore()))
+}
+
+func TestReadOnlyBlockstore(t *testing.T) {
+	bs := newMapBlockstore()
+	blk := blocks.NewBlock([]byte("archived"))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	ro := NewReadOnlyBlockstore(bs)
+	if _, err := ro.Get(blk.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ro.Put(blocks.NewBlock([]byte("new"))); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+	if err := ro.DeleteBlock(blk.Key()); err != ErrReadOnly {
+		t.Fatalf("expected ErrReadOnly, got %v", err)
+	}
+}
+
+func TestUnionBlockstore(t *testing.T) {
+	local := newMapBlockstore()
+	archive := newMapBlockstore()
+
+	shared := blocks.NewBlock([]byte("shared"))
+	if err := archive.Put(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	u := NewUnionBlockstore(local, NewReadOnlyBlockstore(archive))
+
+	own := blocks.NewBlock([]byte("own"))
+	if err := u.PutMany([]*blocks.Block{own, shared}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Put(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []*blocks.Block{own, shared} {
+		got, err := u.Get(b.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.Data) != string(b.Data) {
+			t.Fatal("wrong block data")
+		}
+	}
+
+	// the shared block was not copied into the local store
+	if has, _ := local.Has(shared.Key()); has {
+		t.Fatal("shared block should not be written to the local store")
+	}
+
+	keys, err := u.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed int
+	for k := range keys {
+		if k != own.Key() {
+			t.Fatalf("unexpected key %s", k)
+		}
+		listed++
+	}
+	if listed != 1 {
+		t.Fatalf("expected 1 key, got %d", listed)
+	}
+
+	if err := u.DeleteBlock(own.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := u.Has(own.Key()); has {
+		t.Fatal("deleted block still present")
+	}
+	if has, _ := u.Has(shared.Key()); !has {
+		t.Fatal("shared block should still be present")
+	}
+}
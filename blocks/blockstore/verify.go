@@ -0,0 +1,44 @@
+package blockstore
+
+import (
+	"errors"
+
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+var ErrHashMismatch = errors.New("blockstore: block data does not match its hash")
+
+// NewVerifyingBlockstore returns a blockstore that re-hashes the data of
+// every block it reads, returning ErrHashMismatch when it no longer matches
+// the block's key. This catches corruption of the underlying storage at the
+// cost of hashing every block read.
+func NewVerifyingBlockstore(bs Blockstore) Blockstore {
+	return &verifyingbs{bs}
+}
+
+type verifyingbs struct {
+	Blockstore
+}
+
+func (bs *verifyingbs) Get(k key.Key) (*blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := mh.Decode(blk.Multihash)
+	if err != nil {
+		return nil, err
+	}
+	chk, err := mh.Sum(blk.Data, dec.Code, dec.Length)
+	if err != nil {
+		return nil, err
+	}
+	if string(chk) != string(blk.Multihash) {
+		log.Errorf("block %s is corrupt", k)
+		return nil, ErrHashMismatch
+	}
+	return blk, nil
+}
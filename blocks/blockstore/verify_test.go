@@ -0,0 +1,38 @@
+package blockstore
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsns "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/namespace"
+	ds_sync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+)
+
+func TestVerifyingBlockstore(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bs := NewVerifyingBlockstore(NewBlockstore(d))
+
+	good := blocks.NewBlock([]byte("intact"))
+	if err := bs.Put(good); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.Get(good.Key()); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := blocks.NewBlock([]byte("original"))
+	if err := bs.Put(bad); err != nil {
+		t.Fatal(err)
+	}
+	// corrupt the stored data behind the blockstore's back
+	err := dsns.Wrap(d, BlockPrefix).Put(bad.Key().DsKey(), []byte("corrupted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bs.Get(bad.Key()); err != ErrHashMismatch {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+}
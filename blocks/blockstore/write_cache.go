@@ -52,7 +52,17 @@ func (w *writecache) PutMany(bs []*blocks.Block) error {
 			good = append(good, b)
 		}
 	}
-	return w.blockstore.PutMany(good)
+	if len(good) == 0 {
+		return nil
+	}
+
+	if err := w.blockstore.PutMany(good); err != nil {
+		return err
+	}
+	for _, b := range good {
+		w.cache.Add(b.Key(), struct{}{})
+	}
+	return nil
 }
 
 func (w *writecache) AllKeysChan(ctx context.Context) (<-chan key.Key, error) {
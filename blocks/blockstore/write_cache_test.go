@@ -57,6 +57,27 @@ func TestElideDuplicateWrite(t *testing.T) {
 	cachedbs.Put(b1)
 }
 
+func TestElideDuplicatePutMany(t *testing.T) {
+	cd := &callbackDatastore{f: func() {}, ds: ds.NewMapDatastore()}
+	bs := NewBlockstore(syncds.MutexWrap(cd))
+	cachedbs, err := WriteCached(bs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b1 := blocks.NewBlock([]byte("foo"))
+	b2 := blocks.NewBlock([]byte("bar"))
+
+	if err := cachedbs.PutMany([]*blocks.Block{b1, b2}); err != nil {
+		t.Fatal(err)
+	}
+	cd.SetFunc(func() {
+		t.Fatal("write hit the datastore")
+	})
+	cachedbs.Put(b1)
+	cachedbs.PutMany([]*blocks.Block{b1, b2})
+}
+
 type callbackDatastore struct {
 	f  func()
 	ds ds.Datastore
@@ -5,6 +5,7 @@ package blockservice
 
 import (
 	"errors"
+	"sync"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	blocks "github.com/ipfs/go-ipfs/blocks"
@@ -18,6 +19,81 @@ var log = logging.Logger("blockservice")
 
 var ErrNotFound = errors.New("blockservice: key not found")
 
+type localOnlyKey struct{}
+
+// LocalOnly returns a context under which blocks are only read from the
+// local blockstore. Blocks that aren't stored locally are reported as not
+// found right away, instead of being looked for through the exchange.
+func LocalOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localOnlyKey{}, true)
+}
+
+func isLocalOnly(ctx context.Context) bool {
+	local, _ := ctx.Value(localOnlyKey{}).(bool)
+	return local
+}
+
+type sessionKey struct{}
+
+type session struct {
+	ctx context.Context
+
+	lk     sync.Mutex
+	closed bool
+	x      exchange.Interface
+	f      exchange.Session
+}
+
+// WithSession returns a context under which blocks fetched through the
+// exchange are fetched as part of a single exchange session, and a function
+// ending the session, to call once the blocks are fetched. A context that
+// already belongs to a session is returned as is, along with a function that
+// does nothing, so its blocks keep being fetched within that session.
+// Sessions have no effect if the exchange doesn't support them.
+func WithSession(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(sessionKey{}).(*session); ok {
+		return ctx, func() {}
+	}
+	sess := &session{ctx: ctx}
+	return context.WithValue(ctx, sessionKey{}, sess), sess.close
+}
+
+func (sess *session) close() {
+	sess.lk.Lock()
+	defer sess.lk.Unlock()
+	sess.closed = true
+	if sess.f != nil {
+		sess.f.Close()
+	}
+}
+
+// fetcher returns the exchange session for ctx, if any, or the exchange.
+func (s *BlockService) fetcher(ctx context.Context) exchange.Fetcher {
+	sess, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return s.Exchange
+	}
+	sx, ok := s.Exchange.(exchange.SessionExchange)
+	if !ok {
+		return s.Exchange
+	}
+
+	sess.lk.Lock()
+	defer sess.lk.Unlock()
+	if sess.closed {
+		return s.Exchange
+	}
+	if sess.f == nil {
+		sess.x = sx
+		sess.f = sx.NewSession(sess.ctx)
+	}
+	if sess.x != s.Exchange {
+		// the session was started by another block service
+		return s.Exchange
+	}
+	return sess.f
+}
+
 // BlockService is a hybrid block datastore. It stores data in a local
 // datastore and may retrieve data from a remote Exchange.
 // It uses an internal `datastore.Datastore` instance to store values.
@@ -78,11 +154,11 @@ func (s *BlockService) GetBlock(ctx context.Context, k key.Key) (*blocks.Block,
 		return block, nil
 	}
 
-	if err == blockstore.ErrNotFound && s.Exchange != nil {
+	if err == blockstore.ErrNotFound && s.Exchange != nil && !isLocalOnly(ctx) {
 		// TODO be careful checking ErrNotFound. If the underlying
 		// implementation changes, this will break.
 		log.Debug("Blockservice: Searching bitswap.")
-		blk, err := s.Exchange.GetBlock(ctx, k)
+		blk, err := s.fetcher(ctx).GetBlock(ctx, k)
 		if err != nil {
 			if err == blockstore.ErrNotFound {
 				return nil, ErrNotFound
@@ -122,7 +198,11 @@ func (s *BlockService) GetBlocks(ctx context.Context, ks []key.Key) <-chan *bloc
 			}
 		}
 
-		rblocks, err := s.Exchange.GetBlocks(ctx, misses)
+		if len(misses) == 0 || isLocalOnly(ctx) {
+			return
+		}
+
+		rblocks, err := s.fetcher(ctx).GetBlocks(ctx, misses)
 		if err != nil {
 			log.Debugf("Error with GetBlocks: %s", err)
 			return
@@ -0,0 +1,170 @@
+package blockservice
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	pq "github.com/ipfs/go-ipfs/thirdparty/pq"
+)
+
+// PrefetchTimeout bounds how long a worker spends fetching a single block.
+var PrefetchTimeout = time.Minute
+
+var ErrPrefetcherClosed = errors.New("blockservice: prefetcher is closed")
+
+// Prefetcher fetches blocks through the exchange in the background, so they
+// are stored locally by the time they are needed. Keys are fetched highest
+// priority first, and in the order they were queued among equal priorities.
+// A key is only queued once; queueing it again can raise its priority.
+//
+// At most maxQueued keys are waiting or being fetched at once. Callers of
+// Prefetch block until there is room, so a caller can't queue keys faster
+// than they are fetched.
+type Prefetcher struct {
+	bs *BlockService
+
+	ctx    context.Context
+	cancel func()
+
+	lk     sync.Mutex
+	queue  pq.PQ
+	tasks  map[key.Key]*prefetchTask // queued or being fetched
+	nextID uint64
+
+	slots chan struct{} // one per queued or running task
+	wake  chan struct{} // one per queued task
+}
+
+type prefetchTask struct {
+	key      key.Key
+	priority int
+	id       uint64
+	index    int
+	queued   bool
+}
+
+func (t *prefetchTask) SetIndex(i int) { t.index = i }
+func (t *prefetchTask) Index() int     { return t.index }
+
+func higherPriority(a, b pq.Elem) bool {
+	ta, tb := a.(*prefetchTask), b.(*prefetchTask)
+	if ta.priority != tb.priority {
+		return ta.priority > tb.priority
+	}
+	return ta.id < tb.id
+}
+
+// NewPrefetcher starts a prefetcher fetching blocks for bs with the given
+// number of workers, until ctx is cancelled or it is closed.
+func NewPrefetcher(ctx context.Context, bs *BlockService, workers, maxQueued int) *Prefetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Prefetcher{
+		bs:     bs,
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  pq.New(higherPriority),
+		tasks:  make(map[key.Key]*prefetchTask),
+		slots:  make(chan struct{}, maxQueued),
+		wake:   make(chan struct{}, maxQueued),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Prefetch queues k to be fetched with the given priority, higher values
+// being fetched first. Keys already stored locally are ignored. If the
+// queue is full, Prefetch waits for room until ctx is cancelled.
+func (p *Prefetcher) Prefetch(ctx context.Context, k key.Key, priority int) error {
+	if p.raise(k, priority) {
+		return nil
+	}
+	if has, err := p.bs.Blockstore.Has(k); err == nil && has {
+		return nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return ErrPrefetcherClosed
+	}
+
+	p.lk.Lock()
+	if _, ok := p.tasks[k]; ok {
+		// queued concurrently
+		p.lk.Unlock()
+		<-p.slots
+		p.raise(k, priority)
+		return nil
+	}
+	t := &prefetchTask{key: k, priority: priority, id: p.nextID, queued: true}
+	p.nextID++
+	p.tasks[k] = t
+	p.queue.Push(t)
+	p.lk.Unlock()
+
+	p.wake <- struct{}{}
+	return nil
+}
+
+// raise updates the priority of k if it is already queued, and returns
+// whether it was queued or being fetched.
+func (p *Prefetcher) raise(k key.Key, priority int) bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	t, ok := p.tasks[k]
+	if !ok {
+		return false
+	}
+	if t.queued && priority > t.priority {
+		t.priority = priority
+		p.queue.Update(t.index)
+	}
+	return true
+}
+
+// Queued returns the number of keys waiting or being fetched.
+func (p *Prefetcher) Queued() int {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return len(p.tasks)
+}
+
+func (p *Prefetcher) worker() {
+	for {
+		select {
+		case <-p.wake:
+		case <-p.ctx.Done():
+			return
+		}
+
+		p.lk.Lock()
+		t := p.queue.Pop().(*prefetchTask)
+		t.queued = false
+		p.lk.Unlock()
+
+		ctx, cancel := context.WithTimeout(p.ctx, PrefetchTimeout)
+		if _, err := p.bs.GetBlock(ctx, t.key); err != nil {
+			log.Debugf("prefetching %s failed: %s", t.key, err)
+		}
+		cancel()
+
+		p.lk.Lock()
+		delete(p.tasks, t.key)
+		p.lk.Unlock()
+		<-p.slots
+	}
+}
+
+// Close stops the prefetcher. Queued keys are dropped.
+func (p *Prefetcher) Close() error {
+	p.cancel()
+	return nil
+}
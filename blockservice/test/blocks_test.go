@@ -91,3 +91,88 @@ func TestGetBlocksSequential(t *testing.T) {
 		}
 	}
 }
+
+func TestLocalOnly(t *testing.T) {
+	var servs = Mocks(2)
+	for _, s := range servs {
+		defer s.Close()
+	}
+	bg := blocksutil.NewBlockGenerator()
+	remote := bg.Next()
+	local := bg.Next()
+	servs[0].AddBlock(remote)
+	servs[1].AddBlock(local)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	ctx = LocalOnly(ctx)
+
+	start := time.Now()
+	if _, err := servs[1].GetBlock(ctx, remote.Key()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := servs[1].GetBlock(ctx, local.Key()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*blocks.Block
+	for blk := range servs[1].GetBlocks(ctx, []key.Key{remote.Key(), local.Key()}) {
+		got = append(got, blk)
+	}
+	if len(got) != 1 || got[0].Key() != local.Key() {
+		t.Fatalf("expected only the local block, got %v", got)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatal("local only reads should not wait on the exchange")
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	var servs = Mocks(2)
+	for _, s := range servs {
+		defer s.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	p := NewPrefetcher(ctx, servs[1], 2, 4)
+	defer p.Close()
+
+	bg := blocksutil.NewBlockGenerator()
+	var keys []key.Key
+	for i := 0; i < 10; i++ {
+		blk := bg.Next()
+		servs[0].AddBlock(blk)
+		keys = append(keys, blk.Key())
+	}
+
+	for i, k := range keys {
+		if err := p.Prefetch(ctx, k, i); err != nil {
+			t.Fatal(err)
+		}
+		// queueing a key again is a no-op
+		if err := p.Prefetch(ctx, k, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for p.Queued() > 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("prefetching did not finish")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	for _, k := range keys {
+		has, err := servs[1].Blockstore.Has(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Fatalf("block %s was not prefetched", k)
+		}
+	}
+}
@@ -10,32 +10,47 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/codahale/metrics/runtime"
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
 	commands "github.com/ipfs/go-ipfs/core/commands"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	"github.com/ipfs/go-ipfs/core/corerouting"
+	fusemount "github.com/ipfs/go-ipfs/fuse/mount"
 	conn "github.com/ipfs/go-ipfs/p2p/net/conn"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
-	util "github.com/ipfs/go-ipfs/util"
+	passphrase "github.com/ipfs/go-ipfs/thirdparty/passphrase"
 )
 
 const (
 	initOptionKwd             = "init"
+	initProfileKwd            = "init-profile"
+	initApiAddrKwd            = "init-api"
+	initGatewayAddrKwd        = "init-gateway"
+	initSwarmAddrsKwd         = "init-swarm"
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
+	routingOptionDelegatedKwd = "delegated"
+	routingOptionComposedKwd  = "composed"
 	mountKwd                  = "mount"
 	writableKwd               = "writable"
 	ipfsMountKwd              = "mount-ipfs"
 	ipnsMountKwd              = "mount-ipns"
+	filesMountKwd             = "mount-files"
 	unrestrictedApiAccessKwd  = "unrestricted-api"
 	unencryptTransportKwd     = "disable-transport-encryption"
+	overrideConfigKwd         = "override-config"
+	keyPassphraseFileKwd      = "key-passphrase-file"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm"
 )
@@ -72,6 +87,19 @@ control your node remotely. If you need to control the node remotely,
 make sure to protect the port as you would other services or database
 (firewall, authenticated proxy, etc).
 
+API Authorization
+
+The API can require credentials, each allowed some of its paths only:
+
+	ipfs config --json API.Authorizations.reader '{
+	  "AuthSecret": "basic:alice:secret",
+	  "AllowedPaths": ["/api/v0/cat", "/api/v0/ls"]
+	}'
+
+The AuthSecret is "bearer:<token>" or "basic:<user>:<password>". Once there
+is an authorization, requests without one of them are refused. The ipfs
+command sends the one in the IPFS_API_AUTH environment variable.
+
 HTTP Headers
 
 IPFS supports passing arbitrary headers to the API and Gateway. You can
@@ -85,14 +113,132 @@ Note that the value of the keys is an _array_ of strings. This is because
 headers can have more than one value, and it is convenient to pass through
 to other libraries.
 
-CORS Headers (for API)
+CORS Headers
 
 You can setup CORS headers the same way:
 
 	ipfs config --json API.HTTPHeaders.Access-Control-Allow-Origin '["*"]'
 	ipfs config --json API.HTTPHeaders.Access-Control-Allow-Methods '["PUT", "GET", "POST"]'
+	ipfs config --json API.HTTPHeaders.Access-Control-Allow-Headers '["Authorization"]'
 	ipfs config --json API.HTTPHeaders.Access-Control-Allow-Credentials '["true"]'
 
+The API refuses the requests whose Origin or Referer is not an allowed
+origin; by default only the localhost ones are. The gateway takes its CORS
+headers from Gateway.HTTPHeaders. Its content can be read from any origin
+unless the allowed origins are set, and a writable gateway only takes writes
+from the allowed origins and its own.
+
+
+Gateway Metrics
+
+The gateway counts its requests, the bytes it sends and its latency in the
+ipfs_http_gw metrics at /debug/metrics/prometheus on the API. With
+
+	ipfs config --bool Gateway.AccessLog true
+
+it also logs a gatewayRequest event for every request, which 'ipfs log tail'
+shows.
+
+Mounting
+
+With --mount, or when Mounts.AutoMount is set in the config, the daemon
+mounts /ipfs, /ipns and the files root (Mounts.Files) as it starts:
+
+	ipfs config --bool Mounts.AutoMount true
+
+Mountpoints left over by a daemon that crashed are unmounted first. The
+daemon then checks the mounts every Mounts.CheckPeriod (a minute by
+default, "0s" disables the checks), and mounts them again when one was
+unmounted or stops answering.
+
+Delegated Routing
+
+Instead of taking part in the DHT, the daemon can send its routing queries
+to the API of another node it trusts:
+
+	ipfs config --json Routing.Delegated '["/ip4/10.0.0.1/tcp/5001"]'
+	ipfs config Routing.Type delegated
+
+The content the daemon provides is then only known to the routers.
+
+Routing systems can also be combined, e.g. asking the connected peers and
+a static list of providers before the DHT, with Routing.Type composed:
+
+	ipfs config --json Routing.Routers '{"Type": "tiered", "Routers": [
+	  {"Type": "local"}, {"Type": "static", "Providers": {"*": ["<addr>"]}},
+	  {"Type": "dht", "Timeout": "30s"}]}'
+	ipfs config Routing.Type composed
+
+A node behind a NAT, which peers cannot dial, should only be a client of
+the DHT. With Routing.DHTMode auto, the daemon starts as a client, and
+becomes a server once peers confirm they can dial it back:
+
+	ipfs config Routing.DHTMode auto
+	ipfs dht mode
+
+
+Gateway API
+
+The gateway serves the read-only commands of the API, such as cat, ls and
+refs, under /api/v0. They can be limited to some of them, or turned off:
+
+	ipfs config --json Gateway.APICommands '["cat", "object/get"]'
+	ipfs config --bool Gateway.NoAPI true
+
+
+Changing the Config of a Running Daemon
+
+Changes made with 'ipfs config' while the daemon runs take effect without a
+restart for the API.HTTPHeaders, API.Authorizations, Gateway.HTTPHeaders,
+Gateway.DirIndexTemplate, Gateway.PublicGateways, Gateway.AccessLog,
+Gateway.NoAPI, Gateway.APICommands, Swarm.AddrFilters and Bitswap keys. A
+change of Addresses.Gateway moves the gateway to the new address, and one
+of Gateway.Writable or Gateway.RootRedirect restarts it; the requests in
+flight are let finish. The other keys, and changes made with
+'ipfs config edit', need the daemon to be restarted.
+
+
+Overriding the Config
+
+Config values can be set for a single run of the daemon, without changing
+the config file, with environment variables named after the key:
+
+	IPFS_CONFIG_ADDRESSES_API=/ip4/0.0.0.0/tcp/5001 ipfs daemon
+
+or with --override-config, which takes precedence over the environment:
+
+	ipfs daemon --override-config='Discovery.MDNS.Enabled=false;Bootstrap=[]'
+
+
+First Run
+
+With --init, the daemon initializes the repo first if there is none yet,
+as 'ipfs init' would, so that an empty volume goes to a running node in
+one command. The config it writes can be adjusted with config profiles,
+and the addresses, and so the ports, to listen on:
+
+	ipfs daemon --init --init-profile=server \
+		--init-api=/ip4/0.0.0.0/tcp/5001 \
+		--init-gateway=/ip4/0.0.0.0/tcp/8080 \
+		--init-swarm=/ip4/0.0.0.0/tcp/4001,/ip6/::/tcp/4001
+
+These options are ignored once the repo is initialized.
+
+
+Offline Mode
+
+With --offline, the daemon does not connect to the network. It only serves
+the content and the names in its repo, and the commands looking for others
+fail right away instead of waiting for the network. The commands sent to a
+daemon which is online can also be run offline with --offline.
+
+
+Encrypted Private Key
+
+The private key in the config can be encrypted with a passphrase, with
+'ipfs config passphrase'. The daemon then prompts for the passphrase when
+it starts, or reads it from the file given with --key-passphrase-file.
+
 
 DEPRECATION NOTICE
 
@@ -107,13 +253,20 @@ future version, along with this notice. Please move to setting the HTTP Headers.
 
 	Options: []cmds.Option{
 		cmds.BoolOption(initOptionKwd, "Initialize IPFS with default settings if not already initialized"),
-		cmds.StringOption(routingOptionKwd, "Overrides the routing option (dht, supernode)"),
+		cmds.StringOption(initProfileKwd, "Config profiles to apply when initializing with --init, separated by commas"),
+		cmds.StringOption(initApiAddrKwd, "Address for the API when initializing with --init"),
+		cmds.StringOption(initGatewayAddrKwd, "Address for the gateway when initializing with --init"),
+		cmds.StringOption(initSwarmAddrsKwd, "Addresses for the swarm when initializing with --init, separated by commas"),
+		cmds.StringOption(routingOptionKwd, "Overrides the routing option (dht, supernode, delegated, composed)"),
 		cmds.BoolOption(mountKwd, "Mounts IPFS to the filesystem"),
 		cmds.BoolOption(writableKwd, "Enable writing objects (with POST, PUT and DELETE)"),
 		cmds.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount)"),
 		cmds.StringOption(ipnsMountKwd, "Path to the mountpoint for IPNS (if using --mount)"),
+		cmds.StringOption(filesMountKwd, "Path to the mountpoint for the files root (if using --mount)"),
 		cmds.BoolOption(unrestrictedApiAccessKwd, "Allow API access to unlisted hashes"),
 		cmds.BoolOption(unencryptTransportKwd, "Disable transport encryption (for debugging protocols)"),
+		cmds.StringOption(overrideConfigKwd, "Config values to use instead of those in the config file, as key=value pairs separated by ';'"),
+		cmds.StringOption(keyPassphraseFileKwd, "File holding the passphrase of the encrypted private key"),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmds.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -163,28 +316,49 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
-	if initialize {
-
-		// now, FileExists is our best method of detecting whether IPFS is
-		// configured. Consider moving this into a config helper method
-		// `IsInitialized` where the quality of the signal can be improved over
-		// time, and many call-sites can benefit.
-		if !util.FileExists(req.InvocContext().ConfigRoot) {
-			err := initWithDefaults(os.Stdout, req.InvocContext().ConfigRoot)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
-			}
+	if initialize && !fsrepo.IsInitialized(req.InvocContext().ConfigRoot) {
+		profiles, addrs, err := initOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+		if err := initWithDefaults(os.Stdout, req.InvocContext().ConfigRoot, profiles, addrs); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
 		}
 	}
 
+	override, _, err := req.Option(overrideConfigKwd).String()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+	overrides, err := fsrepo.ParseOverrides(override)
+	if err != nil {
+		res.SetError(err, cmds.ErrClient)
+		return
+	}
+
+	keyPassphrase, err := readKeyPassphrase(req)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
 	// acquire the repo lock _before_ constructing a node. we need to make
 	// sure we are permitted to access the resources (datastore, etc.)
-	repo, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+	repo, err := fsrepo.OpenWithOptions(req.InvocContext().ConfigRoot, fsrepo.Options{
+		Overrides:     overrides,
+		KeyPassphrase: keyPassphrase,
+	})
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
+	// the config from here on is the repo's, with the overrides applied
+	ctx.LoadConfig = func(string) (*config.Config, error) {
+		return repo.Config()
+	}
 
 	cfg, err := ctx.GetConfig()
 	if err != nil {
@@ -192,18 +366,44 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
+	offline, _, err := req.Option(cmds.OfflineOpt).Bool()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		repo.Close()
+		return
+	}
+
 	// Start assembling node config
 	ncfg := &core.BuildCfg{
-		Online: true,
+		Online: !offline,
 		Repo:   repo,
 	}
 
-	routingOption, _, err := req.Option(routingOptionKwd).String()
+	routingOption, found, err := req.Option(routingOptionKwd).String()
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
-	if routingOption == routingOptionSupernodeKwd {
+	if !found {
+		routingOption = cfg.Routing.Type
+	}
+	switch routingOption {
+	case routingOptionDelegatedKwd:
+		routers, auth, err := corerouting.DelegatedRouters(cfg.Routing)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			repo.Close() // because ownership hasn't been transferred to the node
+			return
+		}
+		ncfg.Routing = corerouting.DelegatedClient(routers, auth)
+	case routingOptionComposedKwd:
+		ncfg.Routing, err = corerouting.Composed(cfg.Routing)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			repo.Close() // because ownership hasn't been transferred to the node
+			return
+		}
+	case routingOptionSupernodeKwd:
 		servers, err := cfg.SupernodeRouting.ServerIPFSAddrs()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -228,7 +428,22 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
-	printSwarmAddrs(node)
+	if offline {
+		if err := node.SetupOfflineRouting(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			node.Close()
+			return
+		}
+		fmt.Println("Running offline, without connecting to the network")
+	} else {
+		printSwarmAddrs(node)
+	}
+
+	go func() {
+		if err := corerepo.PeriodicGC(req.Context(), node); err != nil {
+			log.Error("automatic garbage collection disabled: ", err)
+		}
+	}()
 
 	defer func() {
 		// We wait for the node to close first, as the node has children
@@ -253,24 +468,21 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return
 	}
 
-	// construct http gateway - if it is set in the config
-	var gwErrc <-chan error
-	if len(cfg.Addresses.Gateway) > 0 {
-		var err error
-		err, gwErrc = serveHTTPGateway(req)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
+	// construct http gateway - it listens if an address is set in the config
+	err, gwErrc := serveHTTPGateway(req)
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
 	}
 
-	// construct fuse mountpoints - if the user provided the --mount flag
+	// construct fuse mountpoints - if the user provided the --mount flag,
+	// or the config asks for them
 	mount, _, err := req.Option(mountKwd).Bool()
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
-	if mount {
+	if mount || cfg.Mounts.AutoMount {
 		if err := mountFuse(req); err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -288,6 +500,47 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 	}
 }
 
+// initOptions returns the config profiles and the addresses to initialize
+// the repo with, given with the --init-* options.
+func initOptions(req cmds.Request) ([]string, config.Addresses, error) {
+	var profiles []string
+	var addrs config.Addresses
+
+	p, _, err := req.Option(initProfileKwd).String()
+	if err != nil {
+		return nil, addrs, err
+	}
+	if p != "" {
+		profiles = strings.Split(p, ",")
+	}
+
+	addrs.API, _, err = req.Option(initApiAddrKwd).String()
+	if err != nil {
+		return nil, addrs, err
+	}
+	addrs.Gateway, _, err = req.Option(initGatewayAddrKwd).String()
+	if err != nil {
+		return nil, addrs, err
+	}
+	swarm, _, err := req.Option(initSwarmAddrsKwd).String()
+	if err != nil {
+		return nil, addrs, err
+	}
+	if swarm != "" {
+		addrs.Swarm = strings.Split(swarm, ",")
+	}
+
+	for _, a := range append([]string{addrs.API, addrs.Gateway}, addrs.Swarm...) {
+		if a == "" {
+			continue
+		}
+		if _, err := ma.NewMultiaddr(a); err != nil {
+			return nil, addrs, fmt.Errorf("invalid address %q: %s", a, err)
+		}
+	}
+	return profiles, addrs, nil
+}
+
 // serveHTTPApi collects options, creates listener, prints status message and starts serving requests
 func serveHTTPApi(req cmds.Request) (error, <-chan error) {
 	cfg, err := req.InvocContext().GetConfig()
@@ -383,62 +636,110 @@ func printSwarmAddrs(node *core.IpfsNode) {
 	}
 }
 
-// serveHTTPGateway collects options, creates listener, prints status message and starts serving requests
+// serveHTTPGateway collects options, creates listener, prints status message and starts serving requests.
+// The gateway follows the changes of its address in the config, and is
+// restarted when its options change.
 func serveHTTPGateway(req cmds.Request) (error, <-chan error) {
 	cfg, err := req.InvocContext().GetConfig()
 	if err != nil {
 		return fmt.Errorf("serveHTTPGateway: GetConfig() failed: %s", err), nil
 	}
 
-	gatewayMaddr, err := ma.NewMultiaddr(cfg.Addresses.Gateway)
+	writable, writableOptionFound, err := req.Option(writableKwd).Bool()
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: invalid gateway address: %q (err: %s)", cfg.Addresses.Gateway, err), nil
+		return fmt.Errorf("serveHTTPGateway: req.Option(%s) failed: %s", writableKwd, err), nil
+	}
+	gatewayWritable := func(cfg *config.Config) bool {
+		if writableOptionFound {
+			return writable
+		}
+		return cfg.Gateway.Writable
 	}
 
-	writable, writableOptionFound, err := req.Option(writableKwd).Bool()
+	node, err := req.InvocContext().ConstructNode()
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: req.Option(%s) failed: %s", writableKwd, err), nil
+		return fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err), nil
+	}
+
+	gw := corehttp.NewServer(node, gatewayOptions(req, cfg, gatewayWritable(cfg))...)
+	if len(cfg.Addresses.Gateway) > 0 {
+		if err := listenGateway(gw, cfg.Addresses.Gateway, gatewayWritable(cfg)); err != nil {
+			return err, nil
+		}
 	}
-	if !writableOptionFound {
-		writable = cfg.Gateway.Writable
+
+	repo.NotifyConfig(node.Repo, func(old, updated *config.Config) {
+		if old.Addresses.Gateway != updated.Addresses.Gateway {
+			addrs := gw.Addrs()
+			if len(updated.Addresses.Gateway) > 0 {
+				if err := listenGateway(gw, updated.Addresses.Gateway, gatewayWritable(updated)); err != nil {
+					log.Errorf("keeping the gateway address: %s", err)
+					return
+				}
+			}
+			for _, addr := range addrs {
+				if err := gw.Remove(addr, corehttp.ShutdownTimeout); err != nil {
+					log.Error(err)
+				}
+			}
+		}
+
+		if gatewayWritable(old) != gatewayWritable(updated) ||
+			old.Gateway.RootRedirect != updated.Gateway.RootRedirect {
+			log.Info("Gateway options changed, restarting the gateway")
+			if err := gw.Restart(gatewayOptions(req, updated, gatewayWritable(updated))...); err != nil {
+				log.Errorf("keeping the gateway options: %s", err)
+			}
+		}
+	})
+	return nil, gw.Errors()
+}
+
+// listenGateway starts serving the gateway at addr
+func listenGateway(gw *corehttp.Server, addr string, writable bool) error {
+	gatewayMaddr, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return fmt.Errorf("serveHTTPGateway: invalid gateway address: %q (err: %s)", addr, err)
 	}
 
-	gwLis, err := manet.Listen(gatewayMaddr)
+	gatewayMaddr, err = gw.Listen(gatewayMaddr)
 	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err), nil
+		return fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", addr, err)
 	}
-	// we might have listened to /tcp/0 - lets see what we are listing on
-	gatewayMaddr = gwLis.Multiaddr()
 
 	if writable {
 		fmt.Printf("Gateway (writable) server listening on %s\n", gatewayMaddr)
 	} else {
 		fmt.Printf("Gateway (readonly) server listening on %s\n", gatewayMaddr)
 	}
+	return nil
+}
 
+func gatewayOptions(req cmds.Request, cfg *config.Config, writable bool) []corehttp.ServeOption {
 	var opts = []corehttp.ServeOption{
 		corehttp.PrometheusCollectorOption("gateway"),
 		corehttp.CommandsROOption(*req.InvocContext()),
 		corehttp.VersionOption(),
-		corehttp.IPNSHostnameOption(),
+		corehttp.HostnameOption(),
 		corehttp.GatewayOption(writable),
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
+	return opts
+}
 
-	node, err := req.InvocContext().ConstructNode()
-	if err != nil {
-		return fmt.Errorf("serveHTTPGateway: ConstructNode() failed: %s", err), nil
-	}
+// defaultMountCheckPeriod is how often the mounts are checked, unless
+// Mounts.CheckPeriod says otherwise.
+const defaultMountCheckPeriod = time.Minute
 
-	errc := make(chan error)
-	go func() {
-		errc <- corehttp.Serve(node, gwLis.NetListener(), opts...)
-		close(errc)
-	}()
-	return nil, errc
+// mountCheckTimeout is how long a mountpoint has to answer a check.
+const mountCheckTimeout = 10 * time.Second
+
+// mountpoints are where the daemon mounts ipfs, ipns and the files root.
+type mountpoints struct {
+	ipfs, ipns, files string
 }
 
 //collects options and opens the fuse mountpoint
@@ -464,20 +765,108 @@ func mountFuse(req cmds.Request) error {
 		nsdir = cfg.Mounts.IPNS
 	}
 
+	filesdir, found, err := req.Option(filesMountKwd).String()
+	if err != nil {
+		return fmt.Errorf("mountFuse: req.Option(%s) failed: %s", filesMountKwd, err)
+	}
+	if !found {
+		filesdir = cfg.Mounts.Files
+	}
+
 	node, err := req.InvocContext().ConstructNode()
 	if err != nil {
 		return fmt.Errorf("mountFuse: ConstructNode() failed: %s", err)
 	}
 
-	err = commands.Mount(node, fsdir, nsdir)
+	mps := mountpoints{ipfs: fsdir, ipns: nsdir, files: filesdir}
+	if err := cleanStaleMounts(mps); err != nil {
+		return err
+	}
+
+	err = commands.Mount(node, fsdir, nsdir, filesdir)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("IPFS mounted at: %s\n", fsdir)
 	fmt.Printf("IPNS mounted at: %s\n", nsdir)
+	if filesdir != "" {
+		fmt.Printf("Files mounted at: %s\n", filesdir)
+	}
+
+	period := defaultMountCheckPeriod
+	if cfg.Mounts.CheckPeriod != "" {
+		period, err = time.ParseDuration(cfg.Mounts.CheckPeriod)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Mounts.CheckPeriod: %s", err)
+		}
+	}
+	if period > 0 {
+		go keepMounted(req.Context(), node, mps, period)
+	}
 	return nil
 }
 
+// cleanStaleMounts unmounts the mountpoints left over by a daemon that
+// crashed, which cannot be mounted again until then.
+func cleanStaleMounts(mps mountpoints) error {
+	for _, dir := range []string{mps.ipfs, mps.ipns, mps.files} {
+		if dir == "" || !fusemount.IsStale(dir) {
+			continue
+		}
+		log.Warningf("%s was left mounted by a previous daemon, unmounting it", dir)
+		if err := fusemount.ForceUnmountPath(dir); err != nil {
+			return fmt.Errorf("failed to unmount the stale mountpoint %s: %s", dir, err)
+		}
+	}
+	return nil
+}
+
+// checkMounts returns an error unless all the mounts of node are there and
+// answering.
+func checkMounts(node *core.IpfsNode, mps mountpoints) error {
+	mounts := []fusemount.Mount{node.Mounts.Ipfs, node.Mounts.Ipns}
+	if mps.files != "" {
+		mounts = append(mounts, node.Mounts.Files)
+	}
+	for _, m := range mounts {
+		if m == nil {
+			return fmt.Errorf("a mountpoint is not mounted")
+		}
+		if err := fusemount.Check(m, mountCheckTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepMounted checks the mounts of node every period, and mounts them all
+// again when one stops answering, such as after it was unmounted from
+// outside, so the mounts survive unattended operation.
+func keepMounted(ctx context.Context, node *core.IpfsNode, mps mountpoints, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := checkMounts(node, mps)
+			if err == nil {
+				continue
+			}
+			log.Warningf("mounting again: %s", err)
+
+			if err := cleanStaleMounts(mps); err != nil {
+				log.Errorf("mounting again failed: %s", err)
+				continue
+			}
+			if err := commands.Mount(node, mps.ipfs, mps.ipns, mps.files); err != nil {
+				log.Errorf("mounting again failed, next try in %s: %s", period, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // merge does fan-in of multiple read-only error channels
 // taken from http://blog.golang.org/pipelines
 func merge(cs ...<-chan error) <-chan error {
@@ -507,3 +896,30 @@ func merge(cs ...<-chan error) <-chan error {
 	}()
 	return out
 }
+
+// readKeyPassphrase returns the passphrase that decrypts the private key, read
+// from the file given, or else prompted for if the key is encrypted.
+func readKeyPassphrase(req cmds.Request) (string, error) {
+	file, _, err := req.Option(keyPassphraseFileKwd).String()
+	if err != nil {
+		return "", err
+	}
+	if file != "" {
+		p, err := passphrase.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the key passphrase: %s", err)
+		}
+		return p, nil
+	}
+
+	cfg, err := fsrepo.ConfigAt(req.InvocContext().ConfigRoot)
+	if err != nil || !cfg.Identity.Encrypted() {
+		// opening the repo reports config errors
+		return "", nil
+	}
+	p, err := passphrase.Prompt("Enter the passphrase of the private key: ")
+	if err != nil {
+		return "", fmt.Errorf("the private key is encrypted, and %s (use --%s)", err, keyPassphraseFileKwd)
+	}
+	return p, nil
+}
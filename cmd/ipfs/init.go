@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"strings"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	assets "github.com/ipfs/go-ipfs/assets"
@@ -28,6 +29,7 @@ var initCmd = &cmds.Command{
 		cmds.IntOption("bits", "b", fmt.Sprintf("Number of bits to use in the generated RSA private key (defaults to %d)", nBitsForKeypairDefault)),
 		cmds.BoolOption("force", "f", "Overwrite existing config (if it exists)"),
 		cmds.BoolOption("empty-repo", "e", "Don't add and pin help files to the local storage"),
+		cmds.StringOption("profile", "p", "Apply config profiles, separated by commas (see 'ipfs config profile')"),
 
 		// TODO need to decide whether to expose the override as a file or a
 		// directory. That is: should we allow the user to also specify the
@@ -76,7 +78,15 @@ var initCmd = &cmds.Command{
 			nBitsForKeypair = nBitsForKeypairDefault
 		}
 
-		if err := doInit(os.Stdout, req.InvocContext().ConfigRoot, force, empty, nBitsForKeypair); err != nil {
+		var profiles []string
+		if p, found, err := req.Option("profile").String(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		} else if found && p != "" {
+			profiles = strings.Split(p, ",")
+		}
+
+		if err := doInit(os.Stdout, req.InvocContext().ConfigRoot, force, empty, nBitsForKeypair, profiles, config.Addresses{}); err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
@@ -88,11 +98,15 @@ Reinitializing would overwrite your keys.
 (use -f to force overwrite)
 `)
 
-func initWithDefaults(out io.Writer, repoRoot string) error {
-	return doInit(out, repoRoot, false, false, nBitsForKeypairDefault)
+// initWithDefaults initializes the repo at repoRoot with the default config,
+// to which profiles are applied, and the addresses set in addrs.
+func initWithDefaults(out io.Writer, repoRoot string, profiles []string, addrs config.Addresses) error {
+	return doInit(out, repoRoot, false, false, nBitsForKeypairDefault, profiles, addrs)
 }
 
-func doInit(out io.Writer, repoRoot string, force bool, empty bool, nBitsForKeypair int) error {
+// doInit initializes the repo at repoRoot. The addresses set in addrs
+// replace the default ones, after profiles are applied.
+func doInit(out io.Writer, repoRoot string, force bool, empty bool, nBitsForKeypair int, profiles []string, addrs config.Addresses) error {
 	if _, err := fmt.Fprintf(out, "initializing ipfs node at %s\n", repoRoot); err != nil {
 		return err
 	}
@@ -110,6 +124,20 @@ func doInit(out io.Writer, repoRoot string, force bool, empty bool, nBitsForKeyp
 		return err
 	}
 
+	if err := config.ApplyProfiles(conf, profiles...); err != nil {
+		return err
+	}
+
+	if addrs.API != "" {
+		conf.Addresses.API = addrs.API
+	}
+	if addrs.Gateway != "" {
+		conf.Addresses.Gateway = addrs.Gateway
+	}
+	if len(addrs.Swarm) > 0 {
+		conf.Addresses.Swarm = addrs.Swarm
+	}
+
 	if fsrepo.IsInitialized(repoRoot) {
 		if err := fsrepo.Remove(repoRoot); err != nil {
 			return err
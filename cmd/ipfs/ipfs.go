@@ -100,12 +100,17 @@ var cmdDetailsMap = map[*cmds.Command]cmdDetails{
 
 	// daemonCmd allows user to initialize the config. Thus, it may be called
 	// without using the config as input
-	daemonCmd:                  {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
-	commandsClientCmd:          {doesNotUseRepo: true},
-	commands.CommandsDaemonCmd: {doesNotUseRepo: true},
-	commands.VersionCmd:        {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
-	commands.UpdateCmd:         {preemptsAutoUpdate: true, cannotRunOnDaemon: true},
-	commands.UpdateCheckCmd:    {preemptsAutoUpdate: true},
-	commands.UpdateLogCmd:      {preemptsAutoUpdate: true},
-	commands.LogCmd:            {cannotRunOnClient: true},
+	daemonCmd:                    {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	commandsClientCmd:            {doesNotUseRepo: true},
+	commands.CommandsDaemonCmd:   {doesNotUseRepo: true},
+	commands.VersionCmd:          {doesNotUseConfigAsInput: true, doesNotUseRepo: true}, // must be permitted to run before init
+	commands.UpdateCmd:           {preemptsAutoUpdate: true, cannotRunOnDaemon: true},
+	commands.UpdateCheckCmd:      {preemptsAutoUpdate: true},
+	commands.UpdateLogCmd:        {preemptsAutoUpdate: true},
+	commands.LogCmd:              {cannotRunOnClient: true},
+	commands.ConfigRestoreCmd:    {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true},
+	commands.RepoMigrateCmd:      {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	commands.ConfigPassphraseCmd: {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	commands.RepoExportCmd:       {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
+	commands.RepoImportCmd:       {doesNotUseConfigAsInput: true, cannotRunOnDaemon: true, doesNotUseRepo: true},
 }
@@ -41,6 +41,7 @@ var (
 
 const (
 	EnvEnableProfiling = "IPFS_PROF"
+	EnvAPIAuth         = "IPFS_API_AUTH" // an API.Authorizations AuthSecret
 	cpuProfile         = "ipfs.cpuprof"
 	heapProfile        = "ipfs.memprof"
 	errorFormat        = "ERROR: %v\n\n"
@@ -653,6 +654,9 @@ func doVersionRequest(client cmdsHttp.Client) (*coreCmds.VersionOutput, error) {
 		}
 		return nil, err
 	}
+	if res.Error() != nil {
+		return nil, res.Error()
+	}
 
 	ver, ok := res.Output().(*coreCmds.VersionOutput)
 	if !ok {
@@ -667,6 +671,13 @@ func apiClientForAddr(addr ma.Multiaddr) (cmdsHttp.Client, error) {
 		return nil, err
 	}
 
+	if secret := os.Getenv(EnvAPIAuth); secret != "" {
+		auth, err := config.AuthorizationHeader(secret)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", EnvAPIAuth, err)
+		}
+		return cmdsHttp.NewAuthClient(host, auth), nil
+	}
 	return cmdsHttp.NewClient(host), nil
 }
 
@@ -1,6 +1,10 @@
 package commands
 
-import "testing"
+import (
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+)
 
 func noop(req Request, res Response) {
 	return
@@ -155,3 +159,74 @@ func TestResolving(t *testing.T) {
 		t.Error("Returned command path is different than expected", cmds)
 	}
 }
+
+func TestInfo(t *testing.T) {
+	cmdB := &Command{
+		Helptext: HelpText{Tagline: "does b"},
+		Options: []Option{
+			BoolOption("verbose", "v", "be verbose"),
+		},
+		Arguments: []Argument{
+			FileArg("path", true, true, "files to take").EnableRecursive(),
+		},
+	}
+	cmdA := &Command{}
+	cmd := &Command{
+		Options: []Option{
+			StringOption("config", "c", "config file"),
+		},
+		Subcommands: map[string]*Command{
+			"b": cmdB,
+			"a": cmdA,
+		},
+	}
+
+	info := cmd.Info("root")
+	if len(info.Subcommands) != 2 || info.Subcommands[0].Name != "a" || info.Subcommands[1].Name != "b" {
+		t.Fatal("subcommands should be sorted by name", info.Subcommands)
+	}
+
+	b := info.Subcommands[1]
+	if b.Tagline != "does b" {
+		t.Error("unexpected tagline", b.Tagline)
+	}
+	if len(b.Options) != 2+len(globalOptions) {
+		t.Fatal("options should include the inherited and global ones", b.Options)
+	}
+	if b.Options[0].Names[0] != "verbose" || b.Options[0].Type != "bool" {
+		t.Error("own options should come first", b.Options[0])
+	}
+	if b.Options[1].Names[0] != "config" || b.Options[1].Type != "string" {
+		t.Error("inherited options should follow", b.Options[1])
+	}
+	if len(b.Arguments) != 1 || b.Arguments[0].Type != "file" || !b.Arguments[0].Recursive {
+		t.Error("unexpected arguments", b.Arguments)
+	}
+}
+
+func TestOfflineOption(t *testing.T) {
+	cmd := &Command{Run: noop}
+	opts, err := cmd.GetOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := NewRequest(nil, nil, nil, nil, cmd, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetRootContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if req.InvocContext().Offline {
+		t.Error("requests should not be offline by default")
+	}
+
+	req.SetOption(OfflineOpt, true)
+	if err := req.SetRootContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !req.InvocContext().Offline {
+		t.Error("the offline option should make the request offline")
+	}
+}
@@ -0,0 +1,370 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	fp "path/filepath"
+	"strings"
+)
+
+// SymlinkMode tells a Filter what to do with symbolic links.
+type SymlinkMode int
+
+const (
+	// SymlinksKeep keeps the links themselves.
+	SymlinksKeep SymlinkMode = iota
+	// SymlinksFollow replaces the links by the files they point to.
+	SymlinksFollow
+	// SymlinksSkip leaves the links out.
+	SymlinksSkip
+)
+
+// ParseSymlinkMode returns the SymlinkMode named "keep", "follow" or "skip".
+func ParseSymlinkMode(s string) (SymlinkMode, error) {
+	switch s {
+	case "", "keep":
+		return SymlinksKeep, nil
+	case "follow":
+		return SymlinksFollow, nil
+	case "skip":
+		return SymlinksSkip, nil
+	default:
+		return 0, fmt.Errorf("unknown symlink mode %q, expected keep, follow or skip", s)
+	}
+}
+
+// Filter selects the files of the directories read from the OS filesystem.
+//
+// The patterns of Include and Exclude, and the lines of the ignore files,
+// follow the .gitignore syntax: a pattern without a slash matches names at
+// any depth, one with a slash matches paths relative to the directory it
+// applies to, "**" matches any number of directories, a trailing slash only
+// matches directories and a leading "!" takes a file back in.
+type Filter struct {
+	// Include, when not empty, restricts the files to the ones which match
+	// one of its patterns. Directories are kept, so the tree keeps its shape.
+	Include []string
+	// Exclude leaves out the files and directories which match.
+	Exclude []string
+	// IgnoreFiles are the names of the files, such as .gitignore, whose
+	// rules apply to the directory they are in and below.
+	IgnoreFiles []string
+	// Hidden keeps the hidden files.
+	Hidden bool
+	// Symlinks tells what to do with symbolic links.
+	Symlinks SymlinkMode
+
+	parsed  bool
+	include []ignoreRule
+	exclude []ignoreRule
+}
+
+// Wrap returns f so that the directories read from it skip the files the
+// filter leaves out. Paths are matched relative to f, which is kept even
+// if the filter would leave it out.
+func (flt *Filter) Wrap(f File) (File, error) {
+	var err error
+	if !flt.parsed {
+		if flt.include, err = parseRules(flt.Include, ""); err != nil {
+			return nil, err
+		}
+		if flt.exclude, err = parseRules(flt.Exclude, ""); err != nil {
+			return nil, err
+		}
+		flt.parsed = true
+	}
+
+	if s, ok := f.(*Symlink); ok && flt.Symlinks == SymlinksFollow {
+		if f, err = followLink(s); err != nil {
+			return nil, err
+		}
+	}
+	if !f.IsDirectory() {
+		return f, nil
+	}
+	return flt.wrapDir(f, "", nil, nil)
+}
+
+// wrapDir wraps the directory f, at rel under the root of the filter, with
+// the rules of the directories above it and of its own ignore files.
+func (flt *Filter) wrapDir(f File, rel string, rules []ignoreRule, links []string) (File, error) {
+	own, err := loadIgnoreFiles(f.FullPath(), rel, flt.IgnoreFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(own) > 0 {
+		rules = append(append([]ignoreRule(nil), rules...), own...)
+	}
+	return &filterDir{File: f, flt: flt, rel: rel, rules: rules, links: links}, nil
+}
+
+// filterDir is a directory whose NextFile skips the files its Filter
+// leaves out.
+type filterDir struct {
+	File
+
+	flt   *Filter
+	rel   string
+	rules []ignoreRule
+
+	// links are the real paths of the followed links above the directory,
+	// to stop at cycles.
+	links []string
+}
+
+func (d *filterDir) NextFile() (File, error) {
+	for {
+		f, err := d.File.NextFile()
+		if err != nil {
+			return nil, err
+		}
+		f, err = d.filter(f)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			return f, nil
+		}
+	}
+}
+
+// filter returns the child f as it is to be added, or nil if it is left out.
+func (d *filterDir) filter(f File) (File, error) {
+	rel := path.Join(d.rel, path.Base(fp.ToSlash(f.FileName())))
+	if !d.flt.Hidden && IsHidden(f) {
+		return nil, nil
+	}
+
+	links := d.links
+	if s, ok := f.(*Symlink); ok {
+		switch d.flt.Symlinks {
+		case SymlinksSkip:
+			return nil, nil
+		case SymlinksFollow:
+			real, err := fp.EvalSymlinks(s.FullPath())
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range links {
+				if l == real {
+					return nil, fmt.Errorf("%s: symlink cycle", f.FileName())
+				}
+			}
+			if f, err = followLink(s); err != nil {
+				return nil, err
+			}
+			links = append(append([]string(nil), links...), real)
+		}
+	}
+
+	dir := f.IsDirectory()
+	if matchRules(d.rules, rel, dir) || matchRules(d.flt.exclude, rel, dir) {
+		return nil, nil
+	}
+	if dir {
+		return d.flt.wrapDir(f, rel, d.rules, links)
+	}
+	if len(d.flt.include) > 0 && !matchRules(d.flt.include, rel, false) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Size returns the size of the files the filter keeps, by reading the
+// directory again.
+func (d *filterDir) Size() (int64, error) {
+	stat, err := os.Stat(d.FullPath())
+	if err != nil {
+		return 0, err
+	}
+	f, err := NewSerialFile(d.FileName(), d.FullPath(), stat)
+	if err != nil {
+		return 0, err
+	}
+	again := &filterDir{File: f, flt: d.flt, rel: d.rel, rules: d.rules, links: d.links}
+	defer again.Close()
+
+	var size int64
+	for {
+		child, err := again.NextFile()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch child := child.(type) {
+		case *filterDir:
+			s, err := child.Size()
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		case StatFile:
+			if child.Stat() != nil && child.Stat().Mode().IsRegular() {
+				size += child.Stat().Size()
+			}
+		}
+	}
+}
+
+// followLink returns the file that s points to, under the name of s.
+func followLink(s *Symlink) (File, error) {
+	stat, err := os.Stat(s.FullPath())
+	if err != nil {
+		return nil, err
+	}
+	return NewSerialFile(s.FileName(), s.FullPath(), stat)
+}
+
+// ignoreRule is a parsed line of an ignore file, or a pattern of a Filter.
+type ignoreRule struct {
+	base     string   // the directory the rule applies to, relative to the root
+	segments []string // the pattern, split at the slashes
+	anchored bool     // whether the pattern matches paths rather than names
+	negate   bool
+	dirOnly  bool
+}
+
+// parseRules parses the patterns which apply to the directory base.
+func parseRules(patterns []string, base string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, p := range patterns {
+		r, ok, err := parseRule(p, base)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// parseRule parses a pattern, and returns false for blank lines and
+// comments.
+func parseRule(p, base string) (ignoreRule, bool, error) {
+	r := ignoreRule{base: base}
+
+	p = strings.TrimRight(p, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return r, false, nil
+	}
+	if strings.HasPrefix(p, "!") {
+		r.negate = true
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		r.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if strings.Contains(p, "/") {
+		r.anchored = true
+		p = strings.TrimLeft(p, "/")
+	}
+	if p == "" {
+		return r, false, nil
+	}
+
+	r.segments = strings.Split(p, "/")
+	for _, s := range r.segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return r, false, fmt.Errorf("invalid pattern %q: %s", p, err)
+		}
+	}
+	return r, true, nil
+}
+
+// loadIgnoreFiles reads the rules of the ignore files of the directory at
+// dirPath, which is at rel under the root.
+func loadIgnoreFiles(dirPath, rel string, names []string) ([]ignoreRule, error) {
+	if dirPath == "" {
+		return nil, nil
+	}
+
+	var rules []ignoreRule
+	for _, name := range names {
+		fi, err := os.Open(fp.Join(dirPath, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var lines []string
+		scan := bufio.NewScanner(fi)
+		for scan.Scan() {
+			lines = append(lines, scan.Text())
+		}
+		fi.Close()
+		if err := scan.Err(); err != nil {
+			return nil, err
+		}
+
+		r, err := parseRules(lines, rel)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", fp.Join(dirPath, name), err)
+		}
+		rules = append(rules, r...)
+	}
+	return rules, nil
+}
+
+// matchRules tells whether rel is matched by rules, the last matching rule
+// deciding.
+func matchRules(rules []ignoreRule, rel string, dir bool) bool {
+	matched := false
+	for _, r := range rules {
+		if r.match(rel, dir) {
+			matched = !r.negate
+		}
+	}
+	return matched
+}
+
+func (r ignoreRule) match(rel string, dir bool) bool {
+	if r.dirOnly && !dir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches the path segments parts against the pattern
+// segments pat, where "**" matches any number of segments.
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
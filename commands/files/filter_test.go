@@ -0,0 +1,190 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func makeTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "filter-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// walkNames returns the names of the files read from f, relative to it.
+func walkNames(t *testing.T, f File) []string {
+	var names []string
+	var walk func(dir File)
+	walk = func(dir File) {
+		for {
+			child, err := dir.NextFile()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, err := filepath.Rel(f.FileName(), child.FileName())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if child.IsDirectory() {
+				names = append(names, filepath.ToSlash(rel)+"/")
+				walk(child)
+			} else {
+				names = append(names, filepath.ToSlash(rel))
+			}
+		}
+	}
+	walk(f)
+	sort.Strings(names)
+	return names
+}
+
+func filterTree(t *testing.T, root string, flt *Filter) []string {
+	stat, err := os.Lstat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewSerialFile("root", root, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := flt.Wrap(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return walkNames(t, wrapped)
+}
+
+func TestFilter(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		".gitignore":          "*.log\nbuild/\n!keep.log\n/top.txt\n",
+		".hidden":             "h",
+		"top.txt":             "t",
+		"a.go":                "a",
+		"debug.log":           "d",
+		"keep.log":            "k",
+		"build/out":           "o",
+		"src/top.txt":         "t",
+		"src/b.go":            "b",
+		"src/.gitignore":      "gen/**/*.go\n",
+		"src/gen/x/y.go":      "y",
+		"src/gen/z.txt":       "z",
+		"src/vendor/lib/c.go": "c",
+	})
+	defer os.RemoveAll(root)
+
+	cases := []struct {
+		name   string
+		filter Filter
+		expect []string
+	}{
+		{"none", Filter{}, []string{
+			"a.go", "build/", "build/out", "debug.log", "keep.log",
+			"src/", "src/b.go", "src/gen/", "src/gen/x/", "src/gen/x/y.go",
+			"src/gen/z.txt", "src/top.txt", "src/vendor/", "src/vendor/lib/",
+			"src/vendor/lib/c.go", "top.txt",
+		}},
+		{"hidden", Filter{Hidden: true, Exclude: []string{"src"}}, []string{
+			".gitignore", ".hidden", "a.go", "build/", "build/out",
+			"debug.log", "keep.log", "top.txt",
+		}},
+		{"ignore files", Filter{IgnoreFiles: []string{".gitignore"}}, []string{
+			"a.go", "keep.log", "src/", "src/b.go", "src/gen/", "src/gen/x/",
+			"src/gen/z.txt", "src/top.txt", "src/vendor/", "src/vendor/lib/",
+			"src/vendor/lib/c.go",
+		}},
+		{"exclude", Filter{Exclude: []string{"src/vendor", "*.log", "**/x"}}, []string{
+			"a.go", "build/", "build/out", "src/", "src/b.go", "src/gen/",
+			"src/gen/z.txt", "src/top.txt", "top.txt",
+		}},
+		{"include", Filter{Include: []string{"*.go"}, Exclude: []string{"vendor/"}}, []string{
+			"a.go", "build/", "src/", "src/b.go", "src/gen/", "src/gen/x/",
+			"src/gen/x/y.go",
+		}},
+	}
+	for _, c := range cases {
+		if names := filterTree(t, root, &c.filter); !reflect.DeepEqual(names, c.expect) {
+			t.Errorf("%s: got %q, expected %q", c.name, names, c.expect)
+		}
+	}
+
+	if _, err := (&Filter{Exclude: []string{"[a"}}).Wrap(NewSliceFile("", "", nil)); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestFilterSymlinks(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"dir/file": "f",
+	})
+	defer os.RemoveAll(root)
+	if err := os.Symlink("dir", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		mode   SymlinkMode
+		expect []string
+	}{
+		{SymlinksKeep, []string{"dir/", "dir/file", "link"}},
+		{SymlinksFollow, []string{"dir/", "dir/file", "link/", "link/file"}},
+		{SymlinksSkip, []string{"dir/", "dir/file"}},
+	}
+	for _, c := range cases {
+		if names := filterTree(t, root, &Filter{Symlinks: c.mode}); !reflect.DeepEqual(names, c.expect) {
+			t.Errorf("mode %d: got %q, expected %q", c.mode, names, c.expect)
+		}
+	}
+
+	// a link to a directory above is followed once, then refused
+	if err := os.Symlink("..", filepath.Join(root, "dir", "up")); err != nil {
+		t.Fatal(err)
+	}
+	stat, _ := os.Lstat(root)
+	f, err := NewSerialFile("root", root, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := (&Filter{Symlinks: SymlinksFollow}).Wrap(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walk func(dir File) error
+	walk = func(dir File) error {
+		for {
+			child, err := dir.NextFile()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if child.IsDirectory() {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := walk(wrapped); err == nil {
+		t.Error("expected an error for the symlink cycle")
+	}
+}
@@ -101,6 +101,7 @@ func (f *serialFile) Close() error {
 	// close the current file if there is one
 	if f.current != nil {
 		err := (*f.current).Close()
+		f.current = nil
 		// ignore EINVAL error, the file might have already been closed
 		if err != nil && err != syscall.EINVAL {
 			return err
@@ -30,6 +30,7 @@ type Client interface {
 
 type client struct {
 	serverAddress string
+	authorization string
 	httpClient    http.Client
 }
 
@@ -47,6 +48,14 @@ func NewClient(address string) Client {
 	}
 }
 
+// NewAuthClient returns a client that sends authorization as the
+// Authorization header of its requests.
+func NewAuthClient(address, authorization string) Client {
+	c := NewClient(address).(*client)
+	c.authorization = authorization
+	return c
+}
+
 func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 
 	if req.Context() == nil {
@@ -102,6 +111,9 @@ func (c *client) Send(req cmds.Request) (cmds.Response, error) {
 	}
 	version := config.CurrentVersionNumber
 	httpReq.Header.Set(uaHeader, fmt.Sprintf("/go-ipfs/%s/", version))
+	if c.authorization != "" {
+		httpReq.Header.Set("Authorization", c.authorization)
+	}
 
 	ec := make(chan error, 1)
 	rc := make(chan cmds.Response, 1)
@@ -195,6 +207,18 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 	rr := &httpResponseReader{httpRes}
 	res.SetCloser(rr)
 
+	if httpRes.StatusCode == http.StatusUnauthorized || httpRes.StatusCode == http.StatusForbidden {
+		// refused by the API.Authorizations of the server, before any
+		// command ran
+		mes, err := ioutil.ReadAll(rr)
+		if err != nil {
+			return nil, err
+		}
+		e := cmds.Error{Message: strings.TrimSpace(string(mes)), Code: cmds.ErrClient}
+		res.SetError(e, e.Code)
+		return res, nil
+	}
+
 	if contentType != applicationJson {
 		// for all non json output types, just stream back the output
 		res.SetOutput(rr)
@@ -204,7 +228,7 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 		// if output is coming from a channel, decode each chunk
 		outChan := make(chan interface{})
 
-		go readStreamedJson(req, rr, outChan)
+		go readStreamedJson(req, rr, outChan, res)
 
 		res.SetOutput((<-chan interface{})(outChan))
 		return res, nil
@@ -255,9 +279,10 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 	return res, nil
 }
 
-// read json objects off of the given stream, and write the objects out to
-// the 'out' channel
-func readStreamedJson(req cmds.Request, rr io.Reader, out chan<- interface{}) {
+// readStreamedJson decodes the ndjson stream of a channel output into out,
+// value by value. An error ending the stream early, such as the one the
+// server sends in the trailer, is set on res before out is closed.
+func readStreamedJson(req cmds.Request, rr io.Reader, out chan<- interface{}, res cmds.Response) {
 	defer close(out)
 	dec := json.NewDecoder(rr)
 	outputType := reflect.TypeOf(req.Command().Type)
@@ -269,6 +294,7 @@ func readStreamedJson(req cmds.Request, rr io.Reader, out chan<- interface{}) {
 		if err != nil {
 			if err != io.EOF {
 				log.Error(err)
+				res.SetError(err, cmds.ErrNormal)
 			}
 			return
 		}
@@ -32,7 +32,20 @@ type internalHandler struct {
 // with CORS while keeping our fields.
 type Handler struct {
 	internalHandler
-	corsHandler http.Handler
+	cors *CORSHandler
+}
+
+// CORSHandler wraps a handler with the CORS handling of a ServerConfig,
+// following the changes to its options.
+type CORSHandler struct {
+	cfg  *ServerConfig
+	next http.Handler
+
+	// handler is rebuilt when the CORS options change, version is the
+	// version of the options it was built with
+	mu      sync.Mutex
+	handler http.Handler
+	version uint64
 }
 
 var ErrNotFound = errors.New("404 page not found")
@@ -56,6 +69,7 @@ const (
 	ACAOrigin      = "Access-Control-Allow-Origin"
 	ACAMethods     = "Access-Control-Allow-Methods"
 	ACACredentials = "Access-Control-Allow-Credentials"
+	ACAHeaders     = "Access-Control-Allow-Headers"
 )
 
 var mimeTypes = map[string]string{
@@ -65,17 +79,23 @@ var mimeTypes = map[string]string{
 }
 
 type ServerConfig struct {
-	// Headers is an optional map of headers that is written out.
+	// Headers is an optional map of headers that is written out. Change it
+	// with SetHeaders once the server is running.
 	Headers map[string][]string
 
 	// cORSOpts is a set of options for CORS headers.
 	cORSOpts *cors.Options
 
-	// cORSOptsRWMutex is a RWMutex for read/write CORSOpts
+	// cORSVersion counts the changes to cORSOpts.
+	cORSVersion uint64
+
+	// cORSOptsRWMutex is a RWMutex for read/write CORSOpts and Headers
 	cORSOptsRWMutex sync.RWMutex
 }
 
-func skipAPIHeader(h string) bool {
+// IsCORSHeader reports whether h is one of the headers that the CORS
+// handler writes, rather than the user's headers.
+func IsCORSHeader(h string) bool {
 	switch h {
 	case "Access-Control-Allow-Origin":
 		return true
@@ -83,6 +103,8 @@ func skipAPIHeader(h string) bool {
 		return true
 	case "Access-Control-Allow-Credentials":
 		return true
+	case "Access-Control-Allow-Headers":
+		return true
 	default:
 		return false
 	}
@@ -96,13 +118,36 @@ func NewHandler(ctx cmds.Context, root *cmds.Command, cfg *ServerConfig) *Handle
 	// Wrap the internal handler with CORS handling-middleware.
 	// Create a handler for the API.
 	internal := internalHandler{ctx, root, cfg}
-	c := cors.New(*cfg.cORSOpts)
-	return &Handler{internal, c.Handler(internal)}
+	return &Handler{internalHandler: internal, cors: NewCORSHandler(cfg, internal)}
 }
 
-func (i Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (i *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Call the CORS handler which wraps the internal handler.
-	i.corsHandler.ServeHTTP(w, r)
+	i.cors.ServeHTTP(w, r)
+}
+
+// NewCORSHandler returns a handler answering the CORS preflight requests,
+// and adding the CORS headers to the responses of next, as configured in
+// cfg.
+func NewCORSHandler(cfg *ServerConfig, next http.Handler) *CORSHandler {
+	return &CORSHandler{cfg: cfg, next: next}
+}
+
+func (c *CORSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.current().ServeHTTP(w, r)
+}
+
+// current returns the CORS handler for the current CORS options.
+func (c *CORSHandler) current() http.Handler {
+	opts, version := c.cfg.corsOptions()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handler == nil || c.version != version {
+		c.handler = cors.New(opts).Handler(c.next)
+		c.version = version
+	}
+	return c.handler
 }
 
 func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -118,7 +163,7 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	if !allowOrigin(r, i.cfg) || !allowReferer(r, i.cfg) {
+	if !AllowedOrigin(r, i.cfg) {
 		w.WriteHeader(http.StatusForbidden)
 		w.Write([]byte("403 - Forbidden"))
 		log.Warningf("API blocked request to %s. (possible CSRF)", r.URL)
@@ -160,8 +205,8 @@ func (i internalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	res := i.root.Call(req)
 
 	// set user's headers first.
-	for k, v := range i.cfg.Headers {
-		if !skipAPIHeader(k) {
+	for k, v := range i.cfg.headers() {
+		if !IsCORSHeader(k) {
 			w.Header()[k] = v
 		}
 	}
@@ -265,29 +310,32 @@ func flushCopy(w io.Writer, r io.Reader) error {
 		_, err := io.Copy(w, r)
 		return err
 	}
+	// channel outputs are read one value at a time, so flushing after every
+	// read sends each value of the stream as soon as it is produced
 	for {
-		n, err := r.Read(buf)
-		switch err {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			nw, err := w.Write(buf[:n])
+			if err != nil {
+				return err
+			}
+
+			if nw != n {
+				return fmt.Errorf("http write failed to write full amount: %d != %d", nw, n)
+			}
+
+			f.Flush()
+		}
+
+		switch rerr {
 		case io.EOF:
 			return nil
 		case nil:
 			// continue
 		default:
-			return err
-		}
-
-		nw, err := w.Write(buf[:n])
-		if err != nil {
-			return err
-		}
-
-		if nw != n {
-			return fmt.Errorf("http write failed to write full amount: %d != %d", nw, n)
+			return rerr
 		}
-
-		f.Flush()
 	}
-	return nil
 }
 
 func sanitizedErrStr(err error) string {
@@ -303,6 +351,26 @@ func NewServerConfig() *ServerConfig {
 	return cfg
 }
 
+// SetHeaders replaces the headers written out with every response.
+func (cfg *ServerConfig) SetHeaders(headers map[string][]string) {
+	cfg.cORSOptsRWMutex.Lock()
+	defer cfg.cORSOptsRWMutex.Unlock()
+	cfg.Headers = headers
+}
+
+func (cfg *ServerConfig) headers() map[string][]string {
+	cfg.cORSOptsRWMutex.RLock()
+	defer cfg.cORSOptsRWMutex.RUnlock()
+	return cfg.Headers
+}
+
+// corsOptions returns a copy of the CORS options, and their version.
+func (cfg *ServerConfig) corsOptions() (cors.Options, uint64) {
+	cfg.cORSOptsRWMutex.RLock()
+	defer cfg.cORSOptsRWMutex.RUnlock()
+	return *cfg.cORSOpts, cfg.cORSVersion
+}
+
 func (cfg ServerConfig) AllowedOrigins() []string {
 	cfg.cORSOptsRWMutex.RLock()
 	defer cfg.cORSOptsRWMutex.RUnlock()
@@ -313,12 +381,14 @@ func (cfg *ServerConfig) SetAllowedOrigins(origins ...string) {
 	cfg.cORSOptsRWMutex.Lock()
 	defer cfg.cORSOptsRWMutex.Unlock()
 	cfg.cORSOpts.AllowedOrigins = origins
+	cfg.cORSVersion++
 }
 
 func (cfg *ServerConfig) AppendAllowedOrigins(origins ...string) {
 	cfg.cORSOptsRWMutex.Lock()
 	defer cfg.cORSOptsRWMutex.Unlock()
 	cfg.cORSOpts.AllowedOrigins = append(cfg.cORSOpts.AllowedOrigins, origins...)
+	cfg.cORSVersion++
 }
 
 func (cfg ServerConfig) AllowedMethods() []string {
@@ -334,12 +404,28 @@ func (cfg *ServerConfig) SetAllowedMethods(methods ...string) {
 		cfg.cORSOpts = new(cors.Options)
 	}
 	cfg.cORSOpts.AllowedMethods = methods
+	cfg.cORSVersion++
+}
+
+func (cfg *ServerConfig) SetAllowedHeaders(headers ...string) {
+	cfg.cORSOptsRWMutex.Lock()
+	defer cfg.cORSOptsRWMutex.Unlock()
+	cfg.cORSOpts.AllowedHeaders = headers
+	cfg.cORSVersion++
 }
 
 func (cfg *ServerConfig) SetAllowCredentials(flag bool) {
 	cfg.cORSOptsRWMutex.Lock()
 	defer cfg.cORSOptsRWMutex.Unlock()
 	cfg.cORSOpts.AllowCredentials = flag
+	cfg.cORSVersion++
+}
+
+// AllowedOrigin reports whether both the Origin and the Referer of r, when
+// it has them, are allowed origins of cfg. Browsers send them with cross
+// site requests, so this guards against CSRF.
+func AllowedOrigin(r *http.Request, cfg *ServerConfig) bool {
+	return allowOrigin(r, cfg) && allowReferer(r, cfg)
 }
 
 // allowOrigin just stops the request if the origin is not allowed.
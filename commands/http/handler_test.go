@@ -1,9 +1,12 @@
 package http
 
 import (
+	"bufio"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
@@ -346,3 +349,139 @@ func TestAllowedMethod(t *testing.T) {
 		tc.test(t)
 	}
 }
+
+func TestChangeCORSWhileServing(t *testing.T) {
+	cmdsCtx, err := coremock.MockCmdsCtx()
+	if err != nil {
+		t.Fatal("failure to initialize mock cmds ctx", err)
+	}
+	cmdRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"version": ipfscmd.VersionCmd,
+		},
+	}
+	cfg := originCfg(defaultOrigins)
+	server := httptest.NewServer(NewHandler(cmdsCtx, cmdRoot, cfg))
+	defer server.Close()
+
+	get := func() *http.Response {
+		req, err := http.NewRequest("GET", server.URL+"/api/v0/version", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Origin", "http://barbaz.com")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	assertStatus(t, get().StatusCode, http.StatusForbidden)
+
+	cfg.SetAllowedOrigins("http://barbaz.com")
+	cfg.SetHeaders(map[string][]string{"X-Special": {"yes"}})
+	res := get()
+	assertStatus(t, res.StatusCode, http.StatusOK)
+	assertHeaders(t, res.Header, map[string]string{
+		ACAOrigin:   "http://barbaz.com",
+		"X-Special": "yes",
+	})
+}
+
+type streamItem struct {
+	N int
+}
+
+var streamCmd = &cmds.Command{
+	Options: []cmds.Option{
+		cmds.BoolOption("fail", "end the stream with an error"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		fail, _, _ := req.Option("fail").Bool()
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+		go func() {
+			defer close(out)
+			for i := 0; i < 3; i++ {
+				out <- &streamItem{N: i}
+			}
+			if fail {
+				res.SetError(errors.New("stream failed"), cmds.ErrNormal)
+			}
+		}()
+	},
+	Type: streamItem{},
+}
+
+func TestStreamedChannelOutput(t *testing.T) {
+	cmdsCtx, err := coremock.MockCmdsCtx()
+	if err != nil {
+		t.Fatal("failure to initialize mock cmds ctx", err)
+	}
+	cmdRoot := &cmds.Command{
+		Subcommands: map[string]*cmds.Command{
+			"stream": streamCmd,
+		},
+	}
+	server := httptest.NewServer(NewHandler(cmdsCtx, cmdRoot, originCfg(defaultOrigins)))
+	defer server.Close()
+
+	// one compact json value per line
+	res, err := http.Get(server.URL + "/api/v0/stream?stream-channels=true&encoding=json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStatus(t, res.StatusCode, http.StatusOK)
+	assertHeaders(t, res.Header, map[string]string{channelHeader: "1"})
+	var lines []string
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	res.Body.Close()
+	if strings.Join(lines, "\n") != "{\"N\":0}\n{\"N\":1}\n{\"N\":2}" {
+		t.Fatalf("unexpected stream: %q", lines)
+	}
+
+	send := func(fail bool) ([]int, error) {
+		opts := cmds.OptMap{}
+		if fail {
+			opts["fail"] = true
+		}
+		optDefs, err := cmdRoot.GetOptions([]string{"stream"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := cmds.NewRequest([]string{"stream"}, opts, nil, nil, streamCmd, optDefs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cres, err := NewClient(strings.TrimPrefix(server.URL, "http://")).Send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for v := range cres.Output().(<-chan interface{}) {
+			got = append(got, v.(*streamItem).N)
+		}
+		if e := cres.Error(); e != nil {
+			return got, e
+		}
+		return got, nil
+	}
+
+	got, err := send(false)
+	if err != nil || len(got) != 3 || got[2] != 2 {
+		t.Fatalf("expected the three values, got %v (%v)", got, err)
+	}
+
+	got, err = send(true)
+	if len(got) != 3 {
+		t.Fatalf("expected the three values before the error, got %v", got)
+	}
+	if err == nil || !strings.Contains(err.Error(), "stream failed") {
+		t.Fatalf("expected the stream error, got %v", err)
+	}
+}
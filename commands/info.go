@@ -0,0 +1,90 @@
+package commands
+
+import "sort"
+
+// CommandInfo describes a command and its subcommands, for the tools built
+// on the command tree, such as shell completion.
+type CommandInfo struct {
+	Name        string
+	Tagline     string
+	Options     []OptionInfo
+	Arguments   []ArgumentInfo
+	Subcommands []CommandInfo
+}
+
+// OptionInfo describes an option. Type is the name of the kind of its
+// value, such as "bool" or "string".
+type OptionInfo struct {
+	Names       []string
+	Type        string
+	Description string
+}
+
+// ArgumentInfo describes an argument. Type is "string" or "file".
+type ArgumentInfo struct {
+	Name          string
+	Type          string
+	Required      bool
+	Variadic      bool
+	SupportsStdin bool
+	Recursive     bool
+	Description   string
+}
+
+func (t ArgumentType) String() string {
+	switch t {
+	case ArgString:
+		return "string"
+	case ArgFile:
+		return "file"
+	default:
+		return "unknown"
+	}
+}
+
+// Info describes the command, called name, and its subcommands, sorted by
+// name. The options of every command are its own, followed by those it
+// inherits from the commands above it and the global options, which are
+// all the options it takes, as in GetOptions.
+func (c *Command) Info(name string) CommandInfo {
+	return c.info(name, globalOptions)
+}
+
+func (c *Command) info(name string, inherited []Option) CommandInfo {
+	options := make([]Option, 0, len(c.Options)+len(inherited))
+	options = append(options, c.Options...)
+	options = append(options, inherited...)
+
+	info := CommandInfo{
+		Name:    name,
+		Tagline: c.Helptext.Tagline,
+	}
+	for _, opt := range options {
+		info.Options = append(info.Options, OptionInfo{
+			Names:       opt.Names(),
+			Type:        opt.Type().String(),
+			Description: opt.Description(),
+		})
+	}
+	for _, arg := range c.Arguments {
+		info.Arguments = append(info.Arguments, ArgumentInfo{
+			Name:          arg.Name,
+			Type:          arg.Type.String(),
+			Required:      arg.Required,
+			Variadic:      arg.Variadic,
+			SupportsStdin: arg.SupportsStdin,
+			Recursive:     arg.Recursive,
+			Description:   arg.Description,
+		})
+	}
+
+	names := make([]string, 0, len(c.Subcommands))
+	for sub := range c.Subcommands {
+		names = append(names, sub)
+	}
+	sort.Strings(names)
+	for _, sub := range names {
+		info.Subcommands = append(info.Subcommands, c.Subcommands[sub].info(sub, options))
+	}
+	return info
+}
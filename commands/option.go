@@ -160,6 +160,7 @@ const (
 	RecLong    = "recursive"
 	ChanOpt    = "stream-channels"
 	TimeoutOpt = "timeout"
+	OfflineOpt = "offline"
 )
 
 // options that are used by this package
@@ -167,12 +168,14 @@ var OptionEncodingType = StringOption(EncLong, EncShort, "The encoding type the
 var OptionRecursivePath = BoolOption(RecLong, RecShort, "Add directory paths recursively")
 var OptionStreamChannels = BoolOption(ChanOpt, "Stream channel output")
 var OptionTimeout = StringOption(TimeoutOpt, "set a global timeout on the command")
+var OptionOffline = BoolOption(OfflineOpt, "run the command offline, with the content and records in the local repo only")
 
 // global options, added to every command
 var globalOptions = []Option{
 	OptionEncodingType,
 	OptionStreamChannels,
 	OptionTimeout,
+	OptionOffline,
 }
 
 // the above array of Options, wrapped in a Command
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	"github.com/ipfs/go-ipfs/commands/files"
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/repo/config"
@@ -22,6 +23,11 @@ type Context struct {
 	Online     bool
 	ConfigRoot string
 
+	// Offline makes GetNode return an offline view of the node, see
+	// core.IpfsNode.Offline. It is set from the offline option.
+	Offline     bool
+	offlineNode *core.IpfsNode
+
 	config     *config.Config
 	LoadConfig func(path string) (*config.Config, error)
 
@@ -51,6 +57,15 @@ func (c *Context) GetNode() (*core.IpfsNode, error) {
 			return nil, errors.New("nil ConstructNode function")
 		}
 		c.node, err = c.ConstructNode()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Offline {
+		if c.offlineNode == nil {
+			c.offlineNode, err = c.node.Offline()
+		}
+		return c.offlineNode, err
 	}
 	return c.node, err
 }
@@ -136,6 +151,12 @@ func (r *request) SetRootContext(ctx context.Context) error {
 		return err
 	}
 
+	offline, _, err := r.Option(OfflineOpt).Bool()
+	if err != nil {
+		return err
+	}
+	r.ctx.Offline = offline
+
 	r.rctx = ctx
 	return nil
 }
@@ -206,6 +227,12 @@ func getContext(base context.Context, req Request) (context.Context, error) {
 		cctx, _ := context.WithCancel(base)
 		ctx = cctx
 	}
+
+	if offline, _, _ := req.Option(OfflineOpt).Bool(); offline {
+		// even the blocks read through services which were set up
+		// online are not looked for on the network
+		ctx = bserv.LocalOnly(ctx)
+	}
 	return ctx, nil
 }
 
@@ -51,13 +51,26 @@ func marshalJson(value interface{}) (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
+// marshalJsonLine encodes value on a single line, for the newline delimited
+// JSON (ndjson) streams of channel outputs.
+func marshalJsonLine(value interface{}) (io.Reader, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	return bytes.NewReader(b), nil
+}
+
 var marshallers = map[EncodingType]Marshaler{
+	// channel outputs are streamed as ndjson, one value per line, so each
+	// value can be sent and decoded as soon as it is produced
 	JSON: func(res Response) (io.Reader, error) {
 		ch, ok := res.Output().(<-chan interface{})
 		if ok {
 			return &ChannelMarshaler{
 				Channel:   ch,
-				Marshaler: marshalJson,
+				Marshaler: marshalJsonLine,
 				Res:       res,
 			}, nil
 		}
@@ -12,7 +12,10 @@ import (
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
+	deferred "github.com/ipfs/go-ipfs/exchange/deferred"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	provider "github.com/ipfs/go-ipfs/exchange/provider"
+	filestore "github.com/ipfs/go-ipfs/filestore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
@@ -20,6 +23,7 @@ import (
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
 	cfg "github.com/ipfs/go-ipfs/repo/config"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
 )
 
 type BuildCfg struct {
@@ -85,10 +89,7 @@ func defaultRepo(dstore ds.ThreadSafeDatastore) (repo.Repo, error) {
 	c.Identity.PeerID = key.Key(data).B58String()
 	c.Identity.PrivKey = base64.StdEncoding.EncodeToString(privkeyb)
 
-	return &repo.Mock{
-		D: dstore,
-		C: c,
-	}, nil
+	return mockrepo.NewWithDatastore(c, dstore), nil
 }
 
 func NewNode(ctx context.Context, cfg *BuildCfg) (*IpfsNode, error) {
@@ -128,27 +129,55 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		return err
 	}
 
-	var err error
-	n.Blockstore, err = bstore.WriteCached(bstore.NewBlockstore(n.Repo.Datastore()), kSizeBlockstoreWriteCache)
+	rcfg, err := n.Repo.Config()
 	if err != nil {
 		return err
 	}
 
+	bs, err := bstore.NewCompressedBlockstore(n.Repo.Datastore(), rcfg.Datastore.BlockCompression)
+	if err != nil {
+		return err
+	}
+	if rcfg.Datastore.HashOnRead {
+		bs = bstore.NewVerifyingBlockstore(bs)
+	}
+	bs, err = bstore.WriteCached(bs, kSizeBlockstoreWriteCache)
+	if err != nil {
+		return err
+	}
+
+	copts := bstore.CacheOpts{
+		HasBloomFilterSize: rcfg.Datastore.BloomFilterSize,
+		HasLRUSize:         rcfg.Datastore.HasLRUSize,
+	}
+	cbs, err := bstore.CachedBlockstore(bs, ctx, copts)
+	if err != nil {
+		return err
+	}
+	n.BlockStats, err = bstore.NewStatBlockstore(cbs, n.Repo.Datastore())
+	if err != nil {
+		return err
+	}
+	n.Filestore = filestore.NewFilestore(n.BlockStats, n.Repo.Datastore())
+	n.Blockstore = bstore.NewGCBlockstore(n.Filestore, bstore.NewGCLocker())
+
 	if cfg.Online {
-		rcfg, err := n.Repo.Config()
-		if err != nil {
-			return err
-		}
 		do := setupDiscoveryOption(rcfg.Discovery)
 		if err := n.startOnlineServices(ctx, cfg.Routing, cfg.Host, do); err != nil {
 			return err
 		}
+	} else if rcfg.Offline.DeferWants {
+		n.Exchange = deferred.Exchange(n.Blockstore, deferred.NewQueue(n.Repo.Datastore()))
 	} else {
 		n.Exchange = offline.Exchange(n.Blockstore)
 	}
 
 	n.Blocks = bserv.New(n.Blockstore, n.Exchange)
-	n.DAG = dag.NewDAGService(n.Blocks)
+	n.Prefetcher = bserv.NewPrefetcher(ctx, n.Blocks, kPrefetchWorkers, kPrefetchQueueSize)
+	n.DAG, err = dag.NewCachedDAGService(dag.NewDAGService(n.Blocks), kSizeDagNodeCache)
+	if err != nil {
+		return err
+	}
 	n.Pinning, err = pin.LoadPinner(n.Repo.Datastore(), n.DAG)
 	if err != nil {
 		// TODO: we should move towards only running 'NewPinner' explicity on
@@ -157,7 +186,25 @@ func setupNode(ctx context.Context, n *IpfsNode, cfg *BuildCfg) error {
 		// this is kinda sketchy and could cause data loss
 		n.Pinning = pin.NewPinner(n.Repo.Datastore(), n.DAG)
 	}
+	if n.Provider != nil {
+		s, err := provider.ParseStrategy(rcfg.Bitswap.ProvideStrategy)
+		if err != nil {
+			return err
+		}
+		n.Provider.SetStrategy(s)
+		if s == provider.Roots || s == provider.Pinned {
+			// pinned blocks are announced once the pins are flushed
+			n.Pinning.SetAnnouncer(n.Provider)
+		}
+		if err := n.setupReprovider(ctx); err != nil {
+			return err
+		}
+	}
+	if cfg.Online {
+		go pin.SweepExpiredEvery(ctx, n.Pinning, kPinSweepFrequency)
+		go n.drainDeferredWants(ctx)
+	}
 	n.Resolver = &path.Resolver{DAG: n.DAG}
 
-	return nil
+	return n.setupFilesRoot(ctx)
 }
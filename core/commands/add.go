@@ -3,7 +3,10 @@ package commands
 import (
 	"fmt"
 	"io"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/cheggaaa/pb"
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
@@ -15,6 +18,7 @@ import (
 	cmds "github.com/ipfs/go-ipfs/commands"
 	files "github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	offline "github.com/ipfs/go-ipfs/exchange/offline"
 	importer "github.com/ipfs/go-ipfs/importer"
 	"github.com/ipfs/go-ipfs/importer/chunk"
@@ -39,6 +43,11 @@ const (
 	hiddenOptionName   = "hidden"
 	onlyHashOptionName = "only-hash"
 	chunkerOptionName  = "chunker"
+	noCopyOptionName   = "nocopy"
+	includeOptionName  = "include"
+	excludeOptionName  = "exclude"
+	ignoreOptionName   = "ignore-file"
+	symlinksOptionName = "symlinks"
 )
 
 type AddedObject struct {
@@ -55,6 +64,30 @@ Adds contents of <path> to ipfs. Use -r to add directories.
 Note that directories are added recursively, to form the ipfs
 MerkleDAG. A smarter partial add with a staging area (like git)
 remains to be implemented.
+`,
+		LongDescription: `
+Adds contents of <path> to ipfs. Use -r to add directories.
+Note that directories are added recursively, to form the ipfs
+MerkleDAG. A smarter partial add with a staging area (like git)
+remains to be implemented.
+
+The files of the directories can be selected, with patterns in the
+.gitignore syntax, matched against the paths relative to each <path>:
+
+  --include   only add the files which match one of these patterns
+  --exclude   leave out the files and directories which match
+  --ignore-file
+              read more patterns from the files by these names, which
+              apply to the directory they are in and below, e.g.
+              --ignore-file=.gitignore,.ipfsignore
+
+Patterns are separated by commas. Hidden files are left out unless -H is
+given, though their patterns are still read. Symbolic links are added as
+links, or with --symlinks=follow replaced by what they point to, or with
+--symlinks=skip left out. These options apply to the files read from the
+local filesystem by the command line.
+
+  > ipfs add -r --exclude='*.o,build/' --ignore-file=.gitignore src
 `,
 	},
 
@@ -70,8 +103,17 @@ remains to be implemented.
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object"),
 		cmds.BoolOption(hiddenOptionName, "H", "Include files that are hidden"),
 		cmds.StringOption(chunkerOptionName, "s", "chunking algorithm to use"),
+		cmds.BoolOption(noCopyOptionName, "Reference the file data in place instead of copying it into the repo, only without a running daemon"),
+		cmds.StringOption(includeOptionName, "Only add the files matching these comma separated patterns"),
+		cmds.StringOption(excludeOptionName, "Leave out the files matching these comma separated patterns"),
+		cmds.StringOption(ignoreOptionName, "Read patterns of files to leave out from the files with these comma separated names"),
+		cmds.StringOption(symlinksOptionName, "What to do with symlinks: keep (default), follow or skip"),
 	},
 	PreRun: func(req cmds.Request) error {
+		if err := filterFiles(req); err != nil {
+			return err
+		}
+
 		if quiet, _, _ := req.Option(quietOptionName).Bool(); quiet {
 			return nil
 		}
@@ -107,6 +149,7 @@ remains to be implemented.
 		hash, _, _ := req.Option(onlyHashOptionName).Bool()
 		hidden, _, _ := req.Option(hiddenOptionName).Bool()
 		chunker, _, _ := req.Option(chunkerOptionName).String()
+		nocopy, _, _ := req.Option(noCopyOptionName).Bool()
 
 		e := dagutils.NewDagEditor(NewMemoryDagService(), newDirNode())
 		if hash {
@@ -120,6 +163,27 @@ remains to be implemented.
 				return
 			}
 			n = nilnode
+		} else {
+			// make room for the data, or refuse it if there is none. The
+			// size is only known when adding without the daemon, so the
+			// data is also counted as it is read, see storageLimitReader.
+			size, _ := req.Values()["size"].(int64)
+			if err := corerepo.ConditionalGC(req.Context(), n, uint64(size)); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		var storageLeft *uint64
+		if !hash {
+			free, limited, err := corerepo.FreeStorage(n)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if limited {
+				storageLeft = &free
+			}
 		}
 
 		outChan := make(chan interface{}, 8)
@@ -135,6 +199,9 @@ remains to be implemented.
 			hidden:   hidden,
 			trickle:  trickle,
 			wrap:     wrap,
+			nocopy:   nocopy && !hash,
+
+			storageLeft: storageLeft,
 		}
 
 		// addAllFiles loops over a convenience slice file to
@@ -191,6 +258,7 @@ remains to be implemented.
 
 		go func() {
 			defer close(outChan)
+			defer n.Blockstore.PinLock().Unlock()
 			if err := addAllAndPin(req.Files()); err != nil {
 				res.SetError(err, cmds.ErrNormal)
 				return
@@ -284,6 +352,60 @@ remains to be implemented.
 	Type: AddedObject{},
 }
 
+// filterFiles wraps the files of req with the filter made of its options.
+// It runs on the client, where the files are read from the filesystem.
+func filterFiles(req cmds.Request) error {
+	include, _, _ := req.Option(includeOptionName).String()
+	exclude, _, _ := req.Option(excludeOptionName).String()
+	ignore, _, _ := req.Option(ignoreOptionName).String()
+	symlinks, _, _ := req.Option(symlinksOptionName).String()
+	hidden, _, _ := req.Option(hiddenOptionName).Bool()
+
+	mode, err := files.ParseSymlinkMode(symlinks)
+	if err != nil {
+		return err
+	}
+	flt := &files.Filter{
+		Include:     splitList(include),
+		Exclude:     splitList(exclude),
+		IgnoreFiles: splitList(ignore),
+		Hidden:      hidden,
+		Symlinks:    mode,
+	}
+
+	args := req.Files()
+	if args == nil {
+		return nil
+	}
+	var wrapped []files.File
+	for {
+		f, err := args.NextFile()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if f, err = flt.Wrap(f); err != nil {
+			return err
+		}
+		wrapped = append(wrapped, f)
+	}
+	req.SetFiles(files.NewSliceFile(args.FileName(), args.FullPath(), wrapped))
+	return nil
+}
+
+// splitList splits the comma separated list s, dropping the empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func NewMemoryDagService() dag.DAGService {
 	// build mem-datastore for editor's intermediary nodes
 	bs := bstore.NewBlockstore(syncds.MutexWrap(ds.NewMapDatastore()))
@@ -301,20 +423,33 @@ type adder struct {
 	hidden   bool
 	trickle  bool
 	wrap     bool
+	nocopy   bool
 	chunker  string
 
+	// storageLeft is the number of bytes the files added may still take
+	// up under Datastore.StorageMax, nil if there is no limit.
+	storageLeft *uint64
+
 	nextUntitled int
 }
 
-// Perform the actual add & pin locally, outputting results to reader
-func add(n *core.IpfsNode, reader io.Reader, useTrickle bool, chunker string) (*dag.Node, error) {
+// Perform the actual add & pin locally, outputting results to reader.
+// If fpath is set, the file data is referenced there instead of copied.
+func add(n *core.IpfsNode, reader io.Reader, useTrickle bool, chunker string, fpath string) (*dag.Node, error) {
 	chnk, err := chunk.FromString(reader, chunker)
 	if err != nil {
 		return nil, err
 	}
 
 	var node *dag.Node
-	if useTrickle {
+	if fpath != "" {
+		mp := n.Pinning.GetManual()
+		if useTrickle {
+			node, err = importer.BuildTrickleDagFromReaderNoCopy(n.DAG, n.Filestore, fpath, chnk, importer.PinIndirectCB(mp))
+		} else {
+			node, err = importer.BuildDagFromReaderNoCopy(n.DAG, n.Filestore, fpath, chnk, importer.PinIndirectCB(mp))
+		}
+	} else if useTrickle {
 		node, err = importer.BuildTrickleDagFromReader(
 			n.DAG,
 			chnk,
@@ -405,10 +540,30 @@ func (params *adder) addFile(file files.File) (*dag.Node, error) {
 		reader = &progressReader{file: file, out: params.out}
 	}
 
-	dagnode, err := add(params.node, reader, params.trickle, params.chunker)
+	var fpath string
+	if params.nocopy {
+		var err error
+		fpath, err = localFilePath(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the data referenced in place takes up no room in the repo
+	var limit *storageLimitReader
+	if params.storageLeft != nil && fpath == "" {
+		limit = &storageLimitReader{r: reader, left: params.storageLeft}
+		reader = limit
+	}
+
+	dagnode, err := add(params.node, reader, params.trickle, params.chunker, fpath)
 	if err != nil {
 		return nil, err
 	}
+	if limit != nil && limit.exceeded {
+		// the importer stops at the read error, but doesn't return it
+		return nil, corerepo.ErrMaxStorageExceeded
+	}
 
 	// patch it into the root
 	log.Infof("adding file: %s", file.FileName())
@@ -461,6 +616,28 @@ func (params *adder) addDir(file files.File) (*dag.Node, error) {
 	return tree, nil
 }
 
+// localFilePath returns the absolute path of a file to be added without
+// copying. The file must be readable at that path by the node, so files
+// streamed from elsewhere, such as stdin or a client of the API, can't be
+// added this way.
+func localFilePath(file files.File) (string, error) {
+	if _, ok := file.(*files.MultipartFile); ok {
+		return "", fmt.Errorf("cannot add %s without copying through the API: --%s only works when add runs without a daemon", file.FileName(), noCopyOptionName)
+	}
+	if file.FullPath() == "" {
+		return "", fmt.Errorf("cannot add %s without copying: it has no path", file.FileName())
+	}
+	fpath, err := filepath.Abs(file.FullPath())
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(fpath)
+	if err != nil || !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("cannot add %s without copying: not a local file", file.FileName())
+	}
+	return fpath, nil
+}
+
 // outputDagnode sends dagnode info over the output channel
 func outputDagnode(out chan interface{}, name string, dn *dag.Node) error {
 	o, err := getOutput(dn)
@@ -514,6 +691,24 @@ func (i *progressReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// storageLimitReader fails once more bytes are read than there are left,
+// for the data not to take the repo past Datastore.StorageMax.
+type storageLimitReader struct {
+	r        io.Reader
+	left     *uint64
+	exceeded bool
+}
+
+func (l *storageLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if uint64(n) > *l.left {
+		l.exceeded = true
+		return 0, corerepo.ErrMaxStorageExceeded
+	}
+	*l.left -= uint64(n)
+	return n, err
+}
+
 // TODO: generalize this to more than unix-fs nodes.
 func newDirNode() *dag.Node {
 	return &dag.Node{Data: ft.FolderPBData()}
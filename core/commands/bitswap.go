@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
@@ -10,19 +11,25 @@ import (
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
+var errNoBitswap = errors.New("this node is not using bitswap")
+
 var BitswapCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "A set of commands to manipulate the bitswap agent",
 		ShortDescription: ``,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"wantlist": showWantlistCmd,
-		"stat":     bitswapStatCmd,
-		"unwant":   unwantCmd,
+		"wantlist":  showWantlistCmd,
+		"stat":      bitswapStatCmd,
+		"unwant":    unwantCmd,
+		"ledger":    ledgerCmd,
+		"limit":     bitswapLimitCmd,
+		"reprovide": reprovideCmd,
 	},
 }
 
@@ -45,9 +52,9 @@ var unwantCmd = &cmds.Command{
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
-			res.SetError(u.ErrCast(), cmds.ErrNormal)
+		bs := nd.Bitswap()
+		if bs == nil {
+			res.SetError(errNoBitswap, cmds.ErrNormal)
 			return
 		}
 
@@ -66,6 +73,34 @@ var unwantCmd = &cmds.Command{
 	},
 }
 
+var reprovideCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Announce the provided blocks to the network again, now",
+		ShortDescription: `
+The daemon announces again the blocks chosen by Reprovider.Strategy every
+Reprovider.Interval. 'ipfs bitswap reprovide' does it right away, and
+returns once it is done.
+`,
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() || nd.Reprovider == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		if err := nd.Reprovider.Trigger(req.Context()); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
 var showWantlistCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show blocks currently on the wantlist",
@@ -88,9 +123,9 @@ Print out all blocks currently on the bitswap wantlist for the local peer`,
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
-			res.SetError(u.ErrCast(), cmds.ErrNormal)
+		bs := nd.Bitswap()
+		if bs == nil {
+			res.SetError(errNoBitswap, cmds.ErrNormal)
 			return
 		}
 
@@ -133,9 +168,9 @@ var bitswapStatCmd = &cmds.Command{
 			return
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
-		if !ok {
-			res.SetError(u.ErrCast(), cmds.ErrNormal)
+		bs := nd.Bitswap()
+		if bs == nil {
+			res.SetError(errNoBitswap, cmds.ErrNormal)
 			return
 		}
 
@@ -158,7 +193,9 @@ var bitswapStatCmd = &cmds.Command{
 			fmt.Fprintf(buf, "\tprovides buffer: %d / %d\n", out.ProvideBufLen, bitswap.HasBlockBufferSize)
 			fmt.Fprintf(buf, "\tblocks received: %d\n", out.BlocksReceived)
 			fmt.Fprintf(buf, "\tdup blocks received: %d\n", out.DupBlksReceived)
+			fmt.Fprintf(buf, "\tdata received: %s\n", humanize.Bytes(out.DataReceived))
 			fmt.Fprintf(buf, "\tdup data received: %s\n", humanize.Bytes(out.DupDataReceived))
+			fmt.Fprintf(buf, "\trecent dup rate: %.2f\n", out.DupRate)
 			fmt.Fprintf(buf, "\twantlist [%d keys]\n", len(out.Wantlist))
 			for _, k := range out.Wantlist {
 				fmt.Fprintf(buf, "\t\t%s\n", k.B58String())
@@ -171,3 +208,156 @@ var bitswapStatCmd = &cmds.Command{
 		},
 	},
 }
+
+var ledgerCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the current ledger for a peer",
+		ShortDescription: `
+The Bitswap decision engine tracks the number of bytes exchanged between IPFS
+nodes, and stores this information as a collection of ledgers. This command
+prints the ledger associated with a given peer.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "the PeerID (B58) of the ledger to inspect"),
+	},
+	Type: decision.Receipt{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs := nd.Bitswap()
+		if bs == nil {
+			res.SetError(errNoBitswap, cmds.ErrNormal)
+			return
+		}
+
+		pid, err := peer.IDB58Decode(req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		rcpt := bs.LedgerForPeer(pid)
+		if rcpt == nil {
+			rcpt = &decision.Receipt{Peer: pid.Pretty()}
+		}
+		res.SetOutput(rcpt)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*decision.Receipt)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Ledger for %s\n", out.Peer)
+			fmt.Fprintf(buf, "\tdebt ratio: %f\n", out.Value)
+			fmt.Fprintf(buf, "\texchanges: %d\n", out.Exchanged)
+			fmt.Fprintf(buf, "\tbytes sent: %s\n", humanize.Bytes(out.Sent))
+			fmt.Fprintf(buf, "\tbytes received: %s\n", humanize.Bytes(out.Recv))
+			fmt.Fprintf(buf, "\twantlist: %d keys\n", out.Wants)
+			return buf, nil
+		},
+	},
+}
+
+var bitswapLimitCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show or change the bitswap bandwidth limits",
+		ShortDescription: `
+Prints the bandwidth limits on blocks sent and received by bitswap, after
+applying any changes given as options. Limits are sizes per second, such as
+'500kB' or '2MiB'. A limit of 0 means unlimited.
+
+Changes last until the daemon exits. To keep them, set the limits in the
+Bitswap section of the config.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("up", "limit on blocks sent to all peers"),
+		cmds.StringOption("down", "limit on blocks received from all peers"),
+		cmds.StringOption("peer-up", "limit on blocks sent to each peer"),
+		cmds.StringOption("peer-down", "limit on blocks received from each peer"),
+	},
+	Type: bitswap.RateLimits{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		bs := nd.Bitswap()
+		if bs == nil {
+			res.SetError(errNoBitswap, cmds.ErrNormal)
+			return
+		}
+
+		limits := bs.RateLimits()
+		changed := false
+		for _, opt := range []struct {
+			name string
+			dst  *uint64
+		}{
+			{"up", &limits.Upload},
+			{"down", &limits.Download},
+			{"peer-up", &limits.PeerUpload},
+			{"peer-down", &limits.PeerDownload},
+		} {
+			val, found, err := req.Option(opt.name).String()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if !found {
+				continue
+			}
+			v, err := humanize.ParseBytes(val)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid %s limit: %s", opt.name, err), cmds.ErrClient)
+				return
+			}
+			*opt.dst = v
+			changed = true
+		}
+
+		if changed {
+			bs.SetRateLimits(limits)
+		}
+		res.SetOutput(&limits)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*bitswap.RateLimits)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			rate := func(v uint64) string {
+				if v == 0 {
+					return "unlimited"
+				}
+				return humanize.Bytes(v) + "/s"
+			}
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "upload: %s\n", rate(out.Upload))
+			fmt.Fprintf(buf, "download: %s\n", rate(out.Download))
+			fmt.Fprintf(buf, "upload per peer: %s\n", rate(out.PeerUpload))
+			fmt.Fprintf(buf, "download per peer: %s\n", rate(out.PeerDownload))
+			return buf, nil
+		},
+	},
+}
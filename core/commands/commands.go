@@ -54,6 +54,9 @@ func CommandsCmd(root *cmds.Command) *cmds.Command {
 			},
 		},
 		Type: Command{},
+		Subcommands: map[string]*cmds.Command{
+			"completion": completionCmd(root),
+		},
 	}
 }
 
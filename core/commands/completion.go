@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// completionGenerators write the completion script of a shell for the
+// commands described by the given info.
+var completionGenerators = map[string]func(w io.Writer, info cmds.CommandInfo){
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// completionCmd returns the command generating the shell completion scripts
+// of root.
+func completionCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Generate shell completion scripts",
+			ShortDescription: `
+Outputs a script completing the ipfs commands, their flags and the files
+they take in the given shell, one of bash, zsh and fish. The script is
+generated from the commands of this version of ipfs, so it is best
+regenerated after updating.
+
+For example:
+
+    ipfs commands completion bash > /etc/bash_completion.d/ipfs
+    ipfs commands completion zsh > "${fpath[1]}/_ipfs"
+    ipfs commands completion fish > ~/.config/fish/completions/ipfs.fish
+`,
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("shell", true, false, "The shell to complete in: bash, zsh or fish"),
+		},
+		Run: func(req cmds.Request, res cmds.Response) {
+			shell := req.Arguments()[0]
+			gen, ok := completionGenerators[shell]
+			if !ok {
+				res.SetError(fmt.Errorf("unknown shell %q, not one of bash, zsh and fish", shell), cmds.ErrClient)
+				return
+			}
+
+			buf := new(bytes.Buffer)
+			gen(buf, root.Info("ipfs"))
+			res.SetOutput(&MessageOutput{buf.String()})
+		},
+		Marshalers: cmds.MarshalerMap{
+			cmds.Text: func(res cmds.Response) (io.Reader, error) {
+				out, ok := res.Output().(*MessageOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				return strings.NewReader(out.Message), nil
+			},
+		},
+		Type: MessageOutput{},
+	}
+}
+
+// completionEntry is what is completed after the words of a command path.
+type completionEntry struct {
+	path        string // the subcommand names after ipfs, separated by spaces
+	subcommands []cmds.CommandInfo
+	options     []cmds.OptionInfo
+	files       bool // whether the command takes files as arguments
+}
+
+// completionEntries returns the entries of info and of its subcommands,
+// depth first.
+func completionEntries(info cmds.CommandInfo) []completionEntry {
+	var entries []completionEntry
+
+	var walk func(path string, info cmds.CommandInfo)
+	walk = func(path string, info cmds.CommandInfo) {
+		e := completionEntry{
+			path:        path,
+			subcommands: info.Subcommands,
+			options:     info.Options,
+		}
+		for _, arg := range info.Arguments {
+			if arg.Type == cmds.ArgFile.String() {
+				e.files = true
+			}
+		}
+		entries = append(entries, e)
+
+		for _, sub := range info.Subcommands {
+			walk(strings.TrimPrefix(path+" "+sub.Name, " "), sub)
+		}
+	}
+	walk("", info)
+	return entries
+}
+
+// completionFlag returns the option name as a command line flag, with a
+// single dash only when it is one letter long, as the parser wants.
+func completionFlag(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// words returns the subcommand names and the flags of e.
+func (e completionEntry) words() (subcommands, flags []string) {
+	for _, sub := range e.subcommands {
+		subcommands = append(subcommands, sub.Name)
+	}
+	for _, opt := range e.options {
+		for _, name := range opt.Names {
+			flags = append(flags, completionFlag(name))
+		}
+	}
+	return subcommands, flags
+}
+
+// writeShTables writes the _ipfs_subcommands, _ipfs_flags and
+// _ipfs_file_args functions, which bash and zsh both understand, looking up
+// the entry of the command path given as their argument.
+func writeShTables(w io.Writer, entries []completionEntry) {
+	fmt.Fprintln(w, "_ipfs_subcommands()\n{\n    case \"$1\" in")
+	for _, e := range entries {
+		if subs, _ := e.words(); len(subs) > 0 {
+			fmt.Fprintf(w, "    \"%s\") echo \"%s\" ;;\n", e.path, strings.Join(subs, " "))
+		}
+	}
+	fmt.Fprint(w, "    esac\n}\n\n")
+
+	fmt.Fprintln(w, "_ipfs_flags()\n{\n    case \"$1\" in")
+	for _, e := range entries {
+		_, flags := e.words()
+		fmt.Fprintf(w, "    \"%s\") echo \"%s\" ;;\n", e.path, strings.Join(flags, " "))
+	}
+	fmt.Fprint(w, "    esac\n}\n\n")
+
+	fmt.Fprintln(w, "_ipfs_file_args()\n{\n    case \"$1\" in")
+	for _, e := range entries {
+		if e.files {
+			fmt.Fprintf(w, "    \"%s\") return 0 ;;\n", e.path)
+		}
+	}
+	fmt.Fprint(w, "    esac\n    return 1\n}\n\n")
+}
+
+func bashCompletion(w io.Writer, info cmds.CommandInfo) {
+	fmt.Fprint(w, "# bash completion for ipfs, generated by 'ipfs commands completion bash'\n\n")
+	writeShTables(w, completionEntries(info))
+	fmt.Fprint(w, `_ipfs()
+{
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmdpath="" word i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ " $(_ipfs_subcommands "$cmdpath") " == *" $word "* ]]; then
+            cmdpath="${cmdpath:+$cmdpath }$word"
+        fi
+    done
+
+    local subcommands="$(_ipfs_subcommands "$cmdpath")"
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=( $(compgen -W "$(_ipfs_flags "$cmdpath")" -- "$cur") )
+    elif [[ -n "$subcommands" ]]; then
+        COMPREPLY=( $(compgen -W "$subcommands" -- "$cur") )
+    elif _ipfs_file_args "$cmdpath"; then
+        COMPREPLY=( $(compgen -f -- "$cur") )
+    fi
+}
+
+complete -o filenames -F _ipfs ipfs
+`)
+}
+
+func zshCompletion(w io.Writer, info cmds.CommandInfo) {
+	fmt.Fprintln(w, "#compdef ipfs")
+	fmt.Fprint(w, "# zsh completion for ipfs, generated by 'ipfs commands completion zsh'\n\n")
+	writeShTables(w, completionEntries(info))
+	fmt.Fprint(w, `_ipfs()
+{
+    local cmdpath="" word i
+    local -a subcommands
+    for ((i = 2; i < CURRENT; i++)); do
+        word="${words[i]}"
+        subcommands=(${=$(_ipfs_subcommands "$cmdpath")})
+        if (( ${subcommands[(Ie)$word]} )); then
+            cmdpath="${cmdpath:+$cmdpath }$word"
+        fi
+    done
+
+    subcommands=(${=$(_ipfs_subcommands "$cmdpath")})
+    if [[ "${words[CURRENT]}" == -* ]]; then
+        compadd -- ${=$(_ipfs_flags "$cmdpath")}
+    elif (( ${#subcommands} )); then
+        compadd -- $subcommands
+    elif _ipfs_file_args "$cmdpath"; then
+        _files
+    fi
+}
+
+if [[ "$funcstack[1]" == "_ipfs" ]]; then
+    _ipfs "$@"
+else
+    compdef _ipfs ipfs
+fi
+`)
+}
+
+// fishQuote quotes s for fish, in single quotes.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+func fishCompletion(w io.Writer, info cmds.CommandInfo) {
+	entries := completionEntries(info)
+
+	fmt.Fprint(w, "# fish completion for ipfs, generated by 'ipfs commands completion fish'\n\n")
+	fmt.Fprintln(w, "function __fish_ipfs_subcommands\n    switch $argv[1]")
+	for _, e := range entries {
+		if subs, _ := e.words(); len(subs) > 0 {
+			fmt.Fprintf(w, "        case %s\n            echo %s\n", fishQuote(e.path), fishQuote(strings.Join(subs, " ")))
+		}
+	}
+	fmt.Fprint(w, `    end
+end
+
+function __fish_ipfs_path
+    set -l cmdpath ''
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    for word in $tokens
+        if contains -- $word (__fish_ipfs_subcommands "$cmdpath" | string split ' ')
+            set cmdpath (string trim -- "$cmdpath $word")
+        end
+    end
+    echo $cmdpath
+end
+
+function __fish_ipfs_at
+    set -l cmdpath (__fish_ipfs_path)
+    test "$cmdpath" = "$argv[1]"
+end
+
+`)
+
+	for _, e := range entries {
+		cond := fishQuote("__fish_ipfs_at " + fishQuote(e.path))
+		if !e.files {
+			fmt.Fprintf(w, "complete -c ipfs -f -n %s\n", cond)
+		}
+		for _, sub := range e.subcommands {
+			fmt.Fprintf(w, "complete -c ipfs -f -n %s -a %s -d %s\n", cond, sub.Name, fishQuote(sub.Tagline))
+		}
+		for _, opt := range e.options {
+			fmt.Fprintf(w, "complete -c ipfs -n %s", cond)
+			for _, name := range opt.Names {
+				if len(name) == 1 {
+					fmt.Fprintf(w, " -s %s", name)
+				} else {
+					fmt.Fprintf(w, " -l %s", name)
+				}
+			}
+			if opt.Type != "bool" {
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(opt.Description))
+		}
+	}
+}
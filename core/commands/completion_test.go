@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+)
+
+func TestCompletion(t *testing.T) {
+	root := &cmds.Command{
+		Options: []cmds.Option{
+			cmds.StringOption("config", "c", "Path to the configuration file to use"),
+		},
+		Subcommands: map[string]*cmds.Command{
+			"add": &cmds.Command{
+				Options: []cmds.Option{
+					cmds.BoolOption("recursive", "r", "Add directory paths recursively"),
+				},
+				Arguments: []cmds.Argument{
+					cmds.FileArg("path", true, true, "The path to a file to be added"),
+				},
+			},
+			"pin": &cmds.Command{
+				Subcommands: map[string]*cmds.Command{
+					"ls":  &cmds.Command{},
+					"add": &cmds.Command{},
+				},
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	bashCompletion(buf, root.Info("ipfs"))
+	script := buf.String()
+	for _, line := range []string{
+		`"") echo "add pin" ;;`,
+		`"pin") echo "add ls" ;;`,
+		`"add") echo "--recursive -r --config -c --encoding --enc`,
+		`"add") return 0 ;;`,
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("bash completion lacks %q:\n%s", line, script)
+		}
+	}
+
+	buf.Reset()
+	fishCompletion(buf, root.Info("ipfs"))
+	script = buf.String()
+	for _, line := range []string{
+		`complete -c ipfs -f -n '__fish_ipfs_at \'pin\'' -a ls -d ''`,
+		`complete -c ipfs -n '__fish_ipfs_at \'add\'' -l recursive -s r -d 'Add directory paths recursively'`,
+		`complete -c ipfs -n '__fish_ipfs_at \'\'' -l config -s c -r -d 'Path to the configuration file to use'`,
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("fish completion lacks %q:\n%s", line, script)
+		}
+	}
+}
@@ -9,11 +9,14 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
+	serialize "github.com/ipfs/go-ipfs/repo/fsrepo/serialize"
+	passphrase "github.com/ipfs/go-ipfs/thirdparty/passphrase"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -31,6 +34,7 @@ ipfs config <key> <value>  - Set value of <key> to <value>
 ipfs config show           - Show config file
 ipfs config edit           - Edit config file in $EDITOR
 ipfs config replace <file> - Replaces the config file with <file>
+ipfs config profile        - Apply or revert groups of settings
 `,
 		ShortDescription: `
 ipfs config controls configuration variables. It works like 'git config'.
@@ -125,9 +129,12 @@ Set the value of the 'datastore.path' key:
 	},
 	Type: ConfigField{},
 	Subcommands: map[string]*cmds.Command{
-		"show":    configShowCmd,
-		"edit":    configEditCmd,
-		"replace": configReplaceCmd,
+		"show":       configShowCmd,
+		"edit":       configEditCmd,
+		"replace":    configReplaceCmd,
+		"restore":    ConfigRestoreCmd,
+		"profile":    configProfileCmd,
+		"passphrase": ConfigPassphraseCmd,
 	},
 }
 
@@ -162,6 +169,9 @@ var configEditCmd = &cmds.Command{
 		ShortDescription: `
 To use 'ipfs config edit', you must have the $EDITOR environment
 variable set to your preferred text editor.
+
+The config may be annotated with // and /* */ comments. They are dropped
+when ipfs changes the config, and kept in the backup of the previous one.
 `,
 	},
 
@@ -214,6 +224,211 @@ can't be undone.
 	},
 }
 
+// ConfigRestoreCmd is exported so it can be marked as not needing a
+// readable config to run.
+var ConfigRestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restores the previous version of the config",
+		ShortDescription: `
+A copy of the config is kept each time it is changed. 'ipfs config restore'
+replaces the config with that copy, undoing the last change, or recovering
+from a config that was corrupted.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		err := fsrepo.RestoreConfigBackup(req.InvocContext().ConfigRoot)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
+
+// ConfigPassphraseCmd is exported so it can be marked as taking the repo
+// lock itself.
+var ConfigPassphraseCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Encrypts the private key with a passphrase, or changes it",
+		ShortDescription: `
+'ipfs config passphrase' encrypts the private key in the config with a new
+passphrase, after decrypting it with the current one if it was encrypted.
+The passphrases are prompted for, unless read from files with --old-file and
+--new-file. Use --remove to store the key in the clear again.
+
+Once the key is encrypted, 'ipfs daemon' needs the passphrase to start.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.StringOption("old-file", "File holding the current passphrase"),
+		cmds.StringOption("new-file", "File holding the new passphrase"),
+		cmds.BoolOption("remove", "Decrypt the private key, and store it in the clear"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root := req.InvocContext().ConfigRoot
+		cfg, err := fsrepo.ConfigAt(root)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var oldPass string
+		if cfg.Identity.Encrypted() {
+			oldPass, err = readPassphrase(req, "old-file", false, "Current passphrase: ")
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		remove, _, err := req.Option("remove").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		var newPass string
+		if !remove {
+			newPass, err = readPassphrase(req, "new-file", true, "New passphrase: ")
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if newPass == "" {
+				res.SetError(errors.New("the new passphrase is empty, use --remove to store the key in the clear"), cmds.ErrClient)
+				return
+			}
+		}
+
+		if err := fsrepo.ChangeKeyPassphrase(root, oldPass, newPass); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
+
+// readPassphrase reads a passphrase from the file named by the given
+// option, or else prompts for it.
+func readPassphrase(req cmds.Request, fileOpt string, isNew bool, prompt string) (string, error) {
+	file, _, err := req.Option(fileOpt).String()
+	if err != nil {
+		return "", err
+	}
+	if file != "" {
+		return passphrase.ReadFile(file)
+	}
+	if isNew {
+		return passphrase.PromptNew(prompt)
+	}
+	return passphrase.Prompt(prompt)
+}
+
+var configProfileCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Apply or revert profiles, which change groups of settings",
+		ShortDescription: `
+Profiles change the settings that suit a kind of deployment. They can be
+applied when the repo is created, with 'ipfs init --profile', or later.
+'ipfs config profile' lists them.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		var out []ConfigProfile
+		for _, n := range config.ProfileNames() {
+			out = append(out, ConfigProfile{Name: n, Description: config.Profiles[n].Description})
+		}
+		res.SetOutput(&ConfigProfiles{out})
+	},
+	Type: ConfigProfiles{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			ps, ok := res.Output().(*ConfigProfiles)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			for _, p := range ps.Profiles {
+				fmt.Fprintf(buf, "%s:\n", p.Name)
+				for _, l := range strings.Split(p.Description, "\n") {
+					fmt.Fprintf(buf, "  %s\n", l)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Subcommands: map[string]*cmds.Command{
+		"apply":  configProfileApplyCmd,
+		"revert": configProfileRevertCmd,
+	},
+}
+
+type ConfigProfile struct {
+	Name        string
+	Description string
+}
+
+type ConfigProfiles struct {
+	Profiles []ConfigProfile
+}
+
+var configProfileApplyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Apply profiles to the config",
+		ShortDescription: `
+Changes the settings of the given profiles. Settings not part of the
+profiles are left alone.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("profile", true, true, "The profiles to apply"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		err := transformConfig(req, config.ApplyProfiles)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
+
+var configProfileRevertCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Revert profiles applied to the config",
+		ShortDescription: `
+Sets the settings of the given profiles back to their defaults.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("profile", true, true, "The profiles to revert"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		err := transformConfig(req, config.RevertProfiles)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
+
+// transformConfig applies fn, with the request's arguments, to the config
+// of the repo, and saves it.
+func transformConfig(req cmds.Request, fn func(*config.Config, ...string) error) error {
+	r, err := fsrepo.Open(req.InvocContext().ConfigRoot)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	updated := *cfg
+	if err := fn(&updated, req.Arguments()...); err != nil {
+		return err
+	}
+	return r.SetConfig(&updated)
+}
+
 func getConfig(r repo.Repo, key string) (*ConfigField, error) {
 	value, err := r.GetConfigKey(key)
 	if err != nil {
@@ -256,8 +471,12 @@ func editConfig(filename string) error {
 }
 
 func replaceConfig(r repo.Repo, file io.Reader) error {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
 	var cfg config.Config
-	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
+	if err := json.Unmarshal(serialize.StripComments(data), &cfg); err != nil {
 		return errors.New("Failed to decode file as config")
 	}
 
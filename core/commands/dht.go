@@ -2,17 +2,19 @@ package commands
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
-	"time"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
-	ipdht "github.com/ipfs/go-ipfs/routing/dht"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -20,8 +22,12 @@ var ErrNotDHT = errors.New("routing service is not a DHT")
 
 var DhtCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Issue commands directly through the DHT",
-		ShortDescription: ``,
+		Tagline: "Issue commands directly through the DHT",
+		ShortDescription: `
+With --verbose, the queries trace their progress as they run: the peers
+queried, what they answered and how long they took, and once done, the
+closest peers that answered.
+`,
 	},
 
 	Subcommands: map[string]*cmds.Command{
@@ -30,6 +36,8 @@ var DhtCmd = &cmds.Command{
 		"findpeer":  findPeerDhtCmd,
 		"get":       getValueDhtCmd,
 		"put":       putValueDhtCmd,
+		"provide":   provideDhtCmd,
+		"mode":      modeDhtCmd,
 	},
 }
 
@@ -52,8 +60,8 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -104,23 +112,14 @@ var queryDhtCmd = &cmds.Command{
 
 				buf := new(bytes.Buffer)
 				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
 				}
 				switch obj.Type {
 				case notif.FinalPeer:
 					fmt.Fprintf(buf, "%s\n", obj.ID)
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
 				case notif.QueryError:
 					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
 				default:
@@ -160,8 +159,8 @@ FindProviders will return a list of peers who are able to provide the value requ
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -210,7 +209,10 @@ FindProviders will return a list of peers who are able to provide the value requ
 
 				buf := new(bytes.Buffer)
 				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
 				}
 				switch obj.Type {
 				case notif.FinalPeer:
@@ -228,18 +230,6 @@ FindProviders will return a list of peers who are able to provide the value requ
 							fmt.Fprintf(buf, "\t%s\n", a)
 						}
 					}
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
 				case notif.QueryError:
 					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
 				default:
@@ -267,6 +257,9 @@ var findPeerDhtCmd = &cmds.Command{
 	Arguments: []cmds.Argument{
 		cmds.StringArg("peerID", true, true, "The peer to search for"),
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "v", "Write extra information"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
 		if err != nil {
@@ -274,8 +267,8 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -323,6 +316,8 @@ var findPeerDhtCmd = &cmds.Command{
 				return nil, u.ErrCast()
 			}
 
+			verbose, _, _ := res.Request().Option("v").Bool()
+
 			marshal := func(v interface{}) (io.Reader, error) {
 				obj, ok := v.(*notif.QueryEvent)
 				if !ok {
@@ -330,7 +325,12 @@ var findPeerDhtCmd = &cmds.Command{
 				}
 
 				buf := new(bytes.Buffer)
-				fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
+				if verbose {
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
+				}
 				switch obj.Type {
 				case notif.FinalPeer:
 					pi := obj.Responses[0]
@@ -338,14 +338,6 @@ var findPeerDhtCmd = &cmds.Command{
 					for _, a := range pi.Addrs {
 						fmt.Fprintf(buf, "\t%s\n", a)
 					}
-				case notif.PeerResponse:
-					fmt.Fprintf(buf, "* %s says use ", obj.ID)
-					for _, p := range obj.Responses {
-						fmt.Fprintf(buf, "%s ", p.ID)
-					}
-					fmt.Fprintln(buf)
-				case notif.SendingQuery:
-					fmt.Fprintf(buf, "* querying %s\n", obj.ID)
 				case notif.QueryError:
 					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
 				default:
@@ -377,6 +369,7 @@ GetValue will return the value stored in the dht at the given key.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("verbose", "v", "Write extra information"),
+		cmds.StringOption("value-encoding", "Encoding of the value: 'raw' (default) or 'base64', for binary values"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -385,8 +378,8 @@ GetValue will return the value stored in the dht at the given key.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -403,6 +396,21 @@ GetValue will return the value stored in the dht at the given key.
 			return
 		}
 
+		encoding, _, err := req.Option("value-encoding").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		encode := func(val []byte) string { return string(val) }
+		switch encoding {
+		case "", "raw":
+		case "base64":
+			encode = base64.StdEncoding.EncodeToString
+		default:
+			res.SetError(fmt.Errorf("unknown value encoding %q", encoding), cmds.ErrClient)
+			return
+		}
+
 		go func() {
 			defer close(outChan)
 			for e := range events {
@@ -421,7 +429,7 @@ GetValue will return the value stored in the dht at the given key.
 			} else {
 				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
 					Type:  notif.Value,
-					Extra: string(val),
+					Extra: encode(val),
 				})
 			}
 		}()
@@ -443,21 +451,12 @@ GetValue will return the value stored in the dht at the given key.
 
 				buf := new(bytes.Buffer)
 				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
 				}
 				switch obj.Type {
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
 				case notif.Value:
 					if verbose {
 						fmt.Fprintf(buf, "got value: '%s'\n", obj.Extra)
@@ -504,8 +503,8 @@ PutValue will store the given key value pair in the dht.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
-		if !ok {
+		dht := n.DHT
+		if dht == nil {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
 		}
@@ -559,25 +558,16 @@ PutValue will store the given key value pair in the dht.
 
 				buf := new(bytes.Buffer)
 				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
 				}
 				switch obj.Type {
 				case notif.FinalPeer:
 					if verbose {
 						fmt.Fprintf(buf, "* closest peer %s\n", obj.ID)
 					}
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
 				case notif.QueryError:
 					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
 				case notif.Value:
@@ -598,6 +588,217 @@ PutValue will store the given key value pair in the dht.
 	Type: notif.QueryEvent{},
 }
 
+// writeQueryTrace writes the events tracing the progress of a query, which
+// --verbose shows, and reports whether obj is one of them.
+func writeQueryTrace(buf io.Writer, obj *notif.QueryEvent, verbose bool) bool {
+	switch {
+	case obj.Type == notif.SendingQuery:
+		if verbose {
+			fmt.Fprintf(buf, "* querying %s\n", obj.ID)
+		}
+	case obj.Type == notif.PeerResponse:
+		if verbose {
+			fmt.Fprintf(buf, "* %s says use ", obj.ID)
+			for _, p := range obj.Responses {
+				fmt.Fprintf(buf, "%s ", p.ID)
+			}
+			fmt.Fprintf(buf, "(%s)\n", obj.Duration)
+		}
+	case obj.Type == notif.QueryError && obj.ID != "":
+		// a single peer failed, the query goes on
+		if verbose {
+			fmt.Fprintf(buf, "* %s failed after %s: %s\n", obj.ID, obj.Duration, obj.Extra)
+		}
+	case obj.Type == notif.QueryDone:
+		if verbose {
+			fmt.Fprintf(buf, "* query done in %s, %s, closest peers:\n", obj.Duration, obj.Extra)
+			for _, p := range obj.Responses {
+				fmt.Fprintf(buf, "\t%s\n", p.ID)
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+var provideDhtCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Announce to the network that keys are provided",
+		ShortDescription: `
+Provide announces that this node provides the given keys to the peers
+closest to them in the DHT.
+
+With --peer, the keys are provided by another peer, reachable at --addrs.
+The records are not announced, peers only accept them from the providers
+themselves, but this node gives them out to the peers asking it for the
+providers of the keys. Nodes delegating their routing to this one provide
+their content this way.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("key", true, true, "The keys to provide"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "v", "Write extra information"),
+		cmds.StringOption("peer", "The peer providing the keys, instead of this node"),
+		cmds.StringOption("addrs", "The comma separated addresses of --peer"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		dht := n.DHT
+		if dht == nil {
+			res.SetError(ErrNotDHT, cmds.ErrNormal)
+			return
+		}
+
+		pi := peer.PeerInfo{ID: n.Identity}
+		pidstr, found, err := req.Option("peer").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if found {
+			pi.ID, err = peer.IDB58Decode(pidstr)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+		addrs, _, err := req.Option("addrs").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		for _, s := range splitList(addrs) {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			pi.Addrs = append(pi.Addrs, a)
+		}
+		if pi.ID != n.Identity && len(pi.Addrs) == 0 {
+			res.SetError(errors.New("the addresses of --peer are required"), cmds.ErrClient)
+			return
+		}
+
+		var keys []key.Key
+		for _, arg := range req.Arguments() {
+			k := key.B58KeyDecode(arg)
+			if _, err := mh.Cast([]byte(k)); err != nil {
+				res.SetError(fmt.Errorf("incorrectly formatted key: %s", arg), cmds.ErrClient)
+				return
+			}
+			keys = append(keys, k)
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		events := make(chan *notif.QueryEvent)
+		ctx := notif.RegisterForQueryEvents(req.Context(), events)
+
+		go func() {
+			defer close(outChan)
+			for e := range events {
+				outChan <- e
+			}
+		}()
+
+		go func() {
+			defer close(events)
+			for _, k := range keys {
+				if pi.ID != n.Identity {
+					dht.AddProvider(ctx, k, pi)
+					continue
+				}
+				if err := dht.Provide(ctx, k); err != nil {
+					notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+						Type:  notif.QueryError,
+						Extra: err.Error(),
+					})
+					return
+				}
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			verbose, _, _ := res.Request().Option("v").Bool()
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				obj, ok := v.(*notif.QueryEvent)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				if verbose {
+					fmt.Fprintf(buf, "%s: ", obj.Time.Format("15:04:05.000"))
+				}
+				if writeQueryTrace(buf, obj, verbose) {
+					return buf, nil
+				}
+				switch obj.Type {
+				case notif.QueryError:
+					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
+				default:
+					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+	Type: notif.QueryEvent{},
+}
+
+var modeDhtCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show whether the node is a server or a client of the DHT",
+		ShortDescription: `
+A server answers the queries of other peers, a client only sends queries.
+With Routing.DHTMode auto, the node is a client until peers confirm they
+can dial it back, and is a server from then on.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if n.DHT == nil {
+			res.SetError(ErrNotDHT, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(&MessageOutput{n.DHT.Mode().String() + "\n"})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: MessageTextMarshaler,
+	},
+	Type: MessageOutput{},
+}
+
 func escapeDhtKey(s string) (key.Key, error) {
 	parts := strings.Split(s, "/")
 	switch len(parts) {
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	util "github.com/ipfs/go-ipfs/util"
 )
@@ -43,6 +44,14 @@ The resolver will give:
   /dns/ipfs.io
   > ipfs dns --recursive
   /ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy
+
+The record may also be set on the _dnslink subdomain of the name, which
+takes precedence:
+
+  _dnslink.ipfs.io. TXT "dnslink=/ipfs/QmRzTuh2Lpuz7Gr39stNr6mTFdqAghsZec1JoUnfySUzcy"
+
+The records are looked up with the system resolver, unless the DNS.Servers
+or DNS.HTTPSEndpoint config settings name other DNS servers.
 `,
 	},
 
@@ -56,13 +65,11 @@ The resolver will give:
 
 		recursive, _, _ := req.Option("recursive").Bool()
 		name := req.Arguments()[0]
-		resolver := namesys.NewDNSResolver()
-
-		depth := 1
-		if recursive {
-			depth = namesys.DefaultDepthLimit
+		var lookup namesys.LookupTXTFunc
+		if cfg, err := req.InvocContext().GetConfig(); err == nil {
+			lookup = namesys.NewLookupTXT(cfg.DNS.Servers, cfg.DNS.HTTPSEndpoint)
 		}
-		output, err := resolver.ResolveN(req.Context(), name, depth)
+		output, err := core.ResolveDNS(req.Context(), lookup, name, recursive)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
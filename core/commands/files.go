@@ -0,0 +1,629 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+var FilesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manipulate unixfs files",
+		ShortDescription: `
+Files is an API for manipulating a mutable tree of unixfs files, as if it
+was a regular filesystem. Its root is kept by the node across restarts.
+`,
+		LongDescription: `
+Files is an API for manipulating a mutable tree of unixfs files, as if it
+was a regular filesystem. Its root is kept by the node across restarts,
+and the tree is pinned best effort, so garbage collection keeps the parts
+of it that are stored locally.
+
+Changes are written to the root after a short delay; 'ipfs files flush'
+writes them at once.
+
+Examples:
+
+  > ipfs files mkdir -p /docs/notes
+  > echo hello | ipfs files write -e /docs/notes/hello.txt
+  > ipfs files cp /ipfs/QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH /docs/logo
+  > ipfs files ls -l /docs
+  notes	QmXRhTMVLNLYU2R6NS9a7xW3BQXBrUzGHrAhLxj8Bx3HF5	0
+  logo	QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH	0
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"mkdir": filesMkdirCmd,
+		"ls":    filesLsCmd,
+		"read":  filesReadCmd,
+		"write": filesWriteCmd,
+		"rm":    filesRmCmd,
+		"mv":    filesMvCmd,
+		"cp":    filesCpCmd,
+		"stat":  filesStatCmd,
+		"flush": filesFlushCmd,
+	},
+}
+
+// filesRoot returns the files root of the node of req
+func filesRoot(req cmds.Request) (*core.IpfsNode, *mfs.Root, error) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.FilesRoot == nil {
+		return nil, nil, errors.New("the node has no files root")
+	}
+	return n, n.FilesRoot, nil
+}
+
+// filesErr returns err with the files path p it is about
+func filesErr(p string, err error) error {
+	if err == os.ErrNotExist {
+		return fmt.Errorf("%s: file does not exist", p)
+	}
+	if err == os.ErrExist {
+		return fmt.Errorf("%s: file already exists", p)
+	}
+	return err
+}
+
+var filesMkdirCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Make directories",
+		ShortDescription: `
+Creates the directory at <path>. With --parents, the missing directories
+above it are created too, and an existing directory is not an error.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the directory to make"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("parents", "p", "No error if existing, make parent directories as needed"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		parents, _, _ := req.Option("parents").Bool()
+		p := req.Arguments()[0]
+		if err := mfs.Mkdir(root, p, parents); err != nil {
+			res.SetError(filesErr(p, err), cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+type FilesEntry struct {
+	Name string
+	Type int
+	Size int64
+	Hash string
+}
+
+type FilesLsOutput struct {
+	Entries []FilesEntry
+}
+
+var filesLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List directories",
+		ShortDescription: `
+Lists the entries of the directory at <path>, or the root. With -l, their
+hashes and sizes are listed too.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", false, false, "Path of the directory to list, defaults to /"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("l", "Use long listing format"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p := "/"
+		if len(req.Arguments()) > 0 {
+			p = req.Arguments()[0]
+		}
+		long, _, _ := req.Option("l").Bool()
+
+		fsn, err := mfs.Lookup(root, p)
+		if err != nil {
+			res.SetError(filesErr(p, err), cmds.ErrNormal)
+			return
+		}
+
+		var entries []FilesEntry
+		switch fsn := fsn.(type) {
+		case *mfs.Directory:
+			for _, name := range fsn.List() {
+				e := FilesEntry{Name: name}
+				if long {
+					child, err := fsn.Child(name)
+					if err != nil {
+						res.SetError(filesErr(gopath.Join(p, name), err), cmds.ErrNormal)
+						return
+					}
+					if err := fillFilesEntry(&e, child); err != nil {
+						res.SetError(err, cmds.ErrNormal)
+						return
+					}
+				}
+				entries = append(entries, e)
+			}
+		default:
+			e := FilesEntry{Name: gopath.Base(p)}
+			if long {
+				if err := fillFilesEntry(&e, fsn); err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+			}
+			entries = append(entries, e)
+		}
+		res.SetOutput(&FilesLsOutput{Entries: entries})
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*FilesLsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			long, _, _ := res.Request().Option("l").Bool()
+
+			buf := new(bytes.Buffer)
+			for _, e := range out.Entries {
+				if long {
+					fmt.Fprintf(buf, "%s\t%s\t%d\n", e.Name, e.Hash, e.Size)
+				} else {
+					fmt.Fprintf(buf, "%s\n", e.Name)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: FilesLsOutput{},
+}
+
+// fillFilesEntry sets the type, size and hash of e from fsn
+func fillFilesEntry(e *FilesEntry, fsn mfs.FSNode) error {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return err
+	}
+	k, err := nd.Key()
+	if err != nil {
+		return err
+	}
+	e.Hash = k.B58String()
+	e.Type = int(fsn.Type())
+	if fi, ok := fsn.(*mfs.File); ok {
+		e.Size, err = fi.Size()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var filesReadCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Read a file",
+		ShortDescription: `
+Outputs the content of the file at <path>, from --offset and up to --count
+bytes.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the file to read"),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to begin reading from"),
+		cmds.IntOption("count", "n", "Maximum number of bytes to read"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p := req.Arguments()[0]
+		fsn, err := mfs.Lookup(root, p)
+		if err != nil {
+			res.SetError(filesErr(p, err), cmds.ErrNormal)
+			return
+		}
+		fi, ok := fsn.(*mfs.File)
+		if !ok {
+			res.SetError(fmt.Errorf("%s is a directory", p), cmds.ErrNormal)
+			return
+		}
+
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		count, countFound, err := req.Option("count").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 || count < 0 {
+			res.SetError(errors.New("offset and count cannot be negative"), cmds.ErrClient)
+			return
+		}
+
+		// read from a reader of its own, rather than from the shared
+		// offset of the file
+		nd, err := fi.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		dr, err := uio.NewDagReader(req.Context(), nd, n.DAG)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if _, err := dr.Seek(int64(offset), os.SEEK_SET); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var r io.Reader = dr
+		length := int64(dr.Size()) - int64(offset)
+		if length < 0 {
+			length = 0
+		}
+		if countFound && int64(count) < length {
+			r = io.LimitReader(dr, int64(count))
+			length = int64(count)
+		}
+		res.SetLength(uint64(length))
+		res.SetOutput(r)
+	},
+}
+
+var filesWriteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write to a file",
+		ShortDescription: `
+Writes the data read from stdin, or from <data>, to the file at <path>,
+starting at --offset. The file is created with --create, and emptied first
+with --truncate.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the file to write to"),
+		cmds.FileArg("data", true, false, "The data to write").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to begin writing at"),
+		cmds.BoolOption("create", "e", "Create the file if it does not exist"),
+		cmds.BoolOption("truncate", "t", "Truncate the file to size zero before writing"),
+		cmds.IntOption("count", "n", "Maximum number of bytes to write"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p := req.Arguments()[0]
+		create, _, _ := req.Option("create").Bool()
+		trunc, _, _ := req.Option("truncate").Bool()
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		count, countFound, err := req.Option("count").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 || count < 0 {
+			res.SetError(errors.New("offset and count cannot be negative"), cmds.ErrClient)
+			return
+		}
+
+		fsn, err := mfs.Lookup(root, p)
+		if err == os.ErrNotExist && create {
+			empty := &dag.Node{Data: ft.FilePBData(nil, 0)}
+			if err := mfs.PutNode(root, p, empty); err != nil {
+				res.SetError(filesErr(p, err), cmds.ErrNormal)
+				return
+			}
+			fsn, err = mfs.Lookup(root, p)
+		}
+		if err != nil {
+			res.SetError(filesErr(p, err), cmds.ErrNormal)
+			return
+		}
+		fi, ok := fsn.(*mfs.File)
+		if !ok {
+			res.SetError(fmt.Errorf("%s is a directory", p), cmds.ErrNormal)
+			return
+		}
+
+		data, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer data.Close()
+
+		var r io.Reader = data
+		if countFound {
+			r = io.LimitReader(data, int64(count))
+		}
+		if err := writeFile(fi, r, int64(offset), trunc); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+// writeFile writes r to fi at offset, and closes fi to propagate the change
+// up to the root.
+func writeFile(fi *mfs.File, r io.Reader, offset int64, trunc bool) error {
+	if trunc {
+		if err := fi.Truncate(0); err != nil {
+			return err
+		}
+	}
+	size, err := fi.Size()
+	if err != nil {
+		return err
+	}
+	if offset > size {
+		return fmt.Errorf("offset %d is past the end of the file (%d bytes)", offset, size)
+	}
+	if _, err := fi.Seek(offset, os.SEEK_SET); err != nil {
+		return err
+	}
+	if _, err := io.Copy(fi, r); err != nil {
+		fi.Close()
+		return err
+	}
+	return fi.Close()
+}
+
+var filesRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a file",
+		ShortDescription: `
+Removes the file at <path>, or the directory with --recursive.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, true, "Path of the file to remove"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("recursive", "r", "Recursively remove directories"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		recursive, _, _ := req.Option("recursive").Bool()
+		for _, p := range req.Arguments() {
+			if err := mfs.Remove(root, p, recursive); err != nil {
+				res.SetError(filesErr(p, err), cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
+
+var filesMvCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Move a file",
+		ShortDescription: `
+Moves the file or directory at <source> to <dest>, or into <dest> if it is
+an existing directory.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("source", true, false, "Path of the file to move"),
+		cmds.StringArg("dest", true, false, "Path to move the file to"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		src, dst := req.Arguments()[0], req.Arguments()[1]
+		if err := mfs.Mv(root, src, dst); err != nil {
+			res.SetError(filesErr(src, err), cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var filesCpCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Copy a file",
+		ShortDescription: `
+Copies the file or directory at <source>, which is a path of the files
+tree or an /ipfs/ or /ipns/ path, to <dest>. Only the link is copied, the
+content is shared.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("source", true, false, "Path of the file to copy"),
+		cmds.StringArg("dest", true, false, "Path to copy the file to"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		src, dst := req.Arguments()[0], req.Arguments()[1]
+		var nd *dag.Node
+		if strings.HasPrefix(src, "/ipfs/") || strings.HasPrefix(src, "/ipns/") {
+			nd, err = core.Resolve(req.Context(), n, path.Path(src))
+		} else {
+			var fsn mfs.FSNode
+			fsn, err = mfs.Lookup(root, src)
+			if err == nil {
+				nd, err = fsn.GetNode()
+			}
+		}
+		if err != nil {
+			res.SetError(filesErr(src, err), cmds.ErrNormal)
+			return
+		}
+
+		if err := mfs.PutNode(root, dst, nd.Copy()); err != nil {
+			res.SetError(filesErr(dst, err), cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+type FilesStatOutput struct {
+	Hash           string
+	Size           uint64
+	CumulativeSize uint64
+	Blocks         int
+	Type           string
+}
+
+var filesStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Display file status",
+		ShortDescription: `
+Shows the hash, size, cumulative size, number of child blocks and type of
+the file or directory at <path>.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the file to stat"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p := req.Arguments()[0]
+		fsn, err := mfs.Lookup(root, p)
+		if err != nil {
+			res.SetError(filesErr(p, err), cmds.ErrNormal)
+			return
+		}
+		nd, err := fsn.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		k, err := nd.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		stat, err := nd.Stat()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pbn, err := ft.FromBytes(nd.Data)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &FilesStatOutput{
+			Hash:           k.B58String(),
+			CumulativeSize: uint64(stat.CumulativeSize),
+			Blocks:         stat.NumLinks,
+			Type:           "file",
+		}
+		if fsn.Type() == mfs.TDir {
+			out.Type = "directory"
+		} else {
+			out.Size = pbn.GetFilesize()
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*FilesStatOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "%s\n", out.Hash)
+			fmt.Fprintf(buf, "Size: %d\n", out.Size)
+			fmt.Fprintf(buf, "CumulativeSize: %d\n", out.CumulativeSize)
+			fmt.Fprintf(buf, "ChildBlocks: %d\n", out.Blocks)
+			fmt.Fprintf(buf, "Type: %s\n", out.Type)
+			return buf, nil
+		},
+	},
+	Type: FilesStatOutput{},
+}
+
+var filesFlushCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Flush the files tree",
+		ShortDescription: `
+Writes the current root of the files tree at once, rather than after the
+short delay that batches changes.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		_, root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if err := root.Publish(req.Context()); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
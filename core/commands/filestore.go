@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	cmds "github.com/ipfs/go-ipfs/commands"
+	filestore "github.com/ipfs/go-ipfs/filestore"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+var FilestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Maintain data referenced outside the repo",
+		ShortDescription: `
+Files added with 'ipfs add --nocopy' are not copied into the repo. Their
+data is read from the original files, which must stay in place and
+unchanged. These commands check and clean up those references.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"verify": filestoreVerifyCmd,
+		"clean":  filestoreCleanCmd,
+	},
+}
+
+var filestoreVerifyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Check that referenced file data is intact",
+		ShortDescription: `
+'ipfs filestore verify' reads the data behind every file reference, and
+prints its status: 'ok', 'changed' if the file no longer holds the data,
+'missing' if the file is gone, or 'error' if it could not be read.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.BoolOption("quiet", "q", "Only list references that are not ok"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runFilestore(req, res, (*filestore.Filestore).Verify)
+	},
+	Type:       filestore.RefStatus{},
+	Marshalers: filestoreMarshalers,
+}
+
+var filestoreCleanCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove references to missing or changed files",
+		ShortDescription: `
+'ipfs filestore clean' removes the references to file data that is
+missing or has changed, listing each one it removes. The blocks they
+referenced are no longer available from this node afterwards.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.BoolOption("quiet", "q", "Write minimal output"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		runFilestore(req, res, (*filestore.Filestore).Clean)
+	},
+	Type:       filestore.RefStatus{},
+	Marshalers: filestoreMarshalers,
+}
+
+func runFilestore(req cmds.Request, res cmds.Response, op func(*filestore.Filestore, context.Context) (<-chan *filestore.RefStatus, error)) {
+	n, err := req.InvocContext().GetNode()
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+	if n.Filestore == nil {
+		res.SetError(errors.New("filestore is not available"), cmds.ErrNormal)
+		return
+	}
+
+	statuses, err := op(n.Filestore, req.Context())
+	if err != nil {
+		res.SetError(err, cmds.ErrNormal)
+		return
+	}
+
+	out := make(chan interface{})
+	res.SetOutput((<-chan interface{})(out))
+	go func() {
+		defer close(out)
+		for st := range statuses {
+			out <- st
+		}
+	}()
+}
+
+var filestoreMarshalers = cmds.MarshalerMap{
+	cmds.Text: func(res cmds.Response) (io.Reader, error) {
+		outChan, ok := res.Output().(<-chan interface{})
+		if !ok {
+			return nil, u.ErrCast()
+		}
+
+		quiet, _, err := res.Request().Option("quiet").Bool()
+		if err != nil {
+			return nil, err
+		}
+
+		marshal := func(v interface{}) (io.Reader, error) {
+			st, ok := v.(*filestore.RefStatus)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			switch {
+			case quiet && st.Status == filestore.StatusOK:
+			case quiet:
+				fmt.Fprintln(buf, st.Key.B58String())
+			case st.Error != "":
+				fmt.Fprintf(buf, "%-8s %s %s: %s\n", st.Status, st.Key.B58String(), st.FilePath, st.Error)
+			default:
+				fmt.Fprintf(buf, "%-8s %s %s %d\n", st.Status, st.Key.B58String(), st.FilePath, st.Offset)
+			}
+			return buf, nil
+		}
+
+		return &cmds.ChannelMarshaler{
+			Channel:   outChan,
+			Marshaler: marshal,
+			Res:       res,
+		}, nil
+	},
+}
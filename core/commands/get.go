@@ -12,10 +12,8 @@ import (
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/cheggaaa/pb"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
-	core "github.com/ipfs/go-ipfs/core"
-	path "github.com/ipfs/go-ipfs/path"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	tar "github.com/ipfs/go-ipfs/thirdparty/tar"
-	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
 )
 
 var ErrInvalidCompressionLevel = errors.New("Compression level must be between 1 and 9")
@@ -32,7 +30,13 @@ can be specified with '--output=<path>' or '-o=<path>'.
 To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
-may also specify the level of compression by specifying '-l=<1-9>'.
+may also specify the level of compression by specifying '-l=<1-9>'. A
+directory is only compressed as an archive, with '-a -C'.
+
+With '--output=-', the TAR archive, which is also what is sent without
+'--archive', or the compressed file, is written to stdout instead:
+
+  > ipfs get -a -C -o - /ipns/example.com/docs > docs.tar.gz
 `,
 	},
 
@@ -61,16 +65,13 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
-		p := path.Path(req.Arguments()[0])
-		ctx := req.Context()
-		dn, err := core.Resolve(ctx, node, p)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
 
 		archive, _, _ := req.Option("archive").Bool()
-		reader, err := uarchive.DagArchive(ctx, dn, p.String(), node.DAG, archive, cmplvl)
+		reader, err := coreunix.Get(req.Context(), node, req.Arguments()[0], archive, cmplvl)
+		if err == coreunix.ErrCompressDir {
+			res.SetError(fmt.Errorf("%s, use --archive with --compress", err), cmds.ErrClient)
+			return
+		}
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -98,9 +99,17 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 
 		archive, _, _ := req.Option("archive").Bool()
 
+		if outPath == "-" {
+			// the stream is a tar, or the compressed file, as is
+			if _, err := io.Copy(res.Stdout(), outReader); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+			}
+			return
+		}
+
 		gw := getWriter{
-			Out:         os.Stdout,
-			Err:         os.Stderr,
+			Out:         res.Stdout(),
+			Err:         res.Stderr(),
 			Archive:     archive,
 			Compression: cmplvl,
 		}
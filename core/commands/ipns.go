@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 
@@ -37,6 +39,13 @@ Resolve the value of another name:
   > ipfs name resolve QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
   QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+Resolved names are cached for as long as their records allow, up to the
+Ipns.ResolveCacheSize config setting. Use --nocache to look the record up
+again:
+
+  > ipfs name resolve --nocache QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
 `,
 	},
 
@@ -45,6 +54,7 @@ Resolve the value of another name:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Resolve until the result is not an IPNS name"),
+		cmds.BoolOption("nocache", "Do not use cached entries"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -62,9 +72,10 @@ Resolve the value of another name:
 			}
 		}
 
-		router := n.Routing
+		resolver := n.Namesys
 		if local, _, _ := req.Option("local").Bool(); local {
-			router = offline.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+			router := offline.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+			resolver = namesys.NewNameSystem(router, n.Repo.Datastore(), nil, namesys.Opts{})
 		}
 
 		var name string
@@ -86,8 +97,9 @@ Resolve the value of another name:
 			depth = namesys.DefaultDepthLimit
 		}
 
-		resolver := namesys.NewRoutingResolver(router)
-		output, err := resolver.ResolveN(req.Context(), name, depth)
+		nocache, _, _ := req.Option("nocache").Bool()
+		opts := namesys.ResolveOpts{Depth: depth, NoCache: nocache}
+		output, err := namesys.Resolve(req.Context(), resolver, name, opts)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -108,3 +120,117 @@ Resolve the value of another name:
 	},
 	Type: ResolvedPath{},
 }
+
+type IpnsRecord struct {
+	Name     string
+	Value    string
+	Sequence uint64
+	EOL      string
+	TTL      string `json:",omitempty"`
+	Expired  bool
+}
+
+var IpnsInspectCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the IPNS record published at a name",
+		ShortDescription: `
+Fetches the signed record of an IPNS name from the routing system, even if
+it has expired, and shows its value, sequence number, end of life and ttl.
+Useful to find out why a name resolves to a stale value. The default value
+of <name> is your own identity public key.
+`,
+		LongDescription: `
+Fetches the signed record of an IPNS name from the routing system, even if
+it has expired, and shows its value, sequence number, end of life and ttl.
+Useful to find out why a name resolves to a stale value. The default value
+of <name> is your own identity public key.
+
+Examples:
+
+  > ipfs name inspect QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  Name:     QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  Value:    /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Sequence: 4
+  EOL:      2016-02-03T12:00:00.000000000Z (expired)
+  TTL:      5m0s
+
+The record is only shown if it is signed by the key of the name.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, false, "The IPNS name to inspect. Defaults to your node's peerID.").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !n.OnlineMode() {
+			err := n.SetupOfflineRouting()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+
+		router := n.Routing
+		if local, _, _ := req.Option("local").Bool(); local {
+			router = offline.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+		}
+
+		var name string
+		if len(req.Arguments()) == 0 {
+			if n.Identity == "" {
+				res.SetError(errors.New("Identity not loaded!"), cmds.ErrNormal)
+				return
+			}
+			name = n.Identity.Pretty()
+		} else {
+			name = req.Arguments()[0]
+		}
+
+		rec, err := namesys.GetRecord(req.Context(), router, name)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &IpnsRecord{
+			Name:     rec.Name.Pretty(),
+			Value:    rec.Value.String(),
+			Sequence: rec.Sequence,
+			EOL:      u.FormatRFC3339(rec.EOL),
+			Expired:  rec.Expired(),
+		}
+		if rec.TTL > 0 {
+			out.TTL = rec.TTL.String()
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			rec, ok := res.Output().(*IpnsRecord)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "Name:     %s\n", rec.Name)
+			fmt.Fprintf(buf, "Value:    %s\n", rec.Value)
+			fmt.Fprintf(buf, "Sequence: %d\n", rec.Sequence)
+			if rec.Expired {
+				fmt.Fprintf(buf, "EOL:      %s (expired)\n", rec.EOL)
+			} else {
+				fmt.Fprintf(buf, "EOL:      %s\n", rec.EOL)
+			}
+			if rec.TTL != "" {
+				fmt.Fprintf(buf, "TTL:      %s\n", rec.TTL)
+			}
+			return buf, nil
+		},
+	},
+	Type: IpnsRecord{},
+}
@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+type KeyOutput struct {
+	Name string
+	Id   string
+}
+
+type KeyOutputList struct {
+	Keys []KeyOutput
+}
+
+var KeyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create and manage the keypairs of the node",
+		Synopsis: `
+ipfs key gen <name> [--type=rsa] [--size=2048] - Create a new keypair
+ipfs key list                                  - List all the keypairs
+ipfs key rename <name> <new-name>              - Rename a keypair
+ipfs key rm <name>...                          - Remove keypairs
+`,
+		ShortDescription: `
+Besides its identity, a node can hold more keypairs in the keystore of its
+repo, for instance to publish IPNS names with. The identity is listed as
+'self'.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gen":    keyGenCmd,
+		"list":   keyListCmd,
+		"rename": keyRenameCmd,
+		"rm":     keyRmCmd,
+	},
+}
+
+var keyGenCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create a new keypair",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key to create"),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("type", "t", "Type of the key to create (rsa), default: rsa"),
+		cmds.IntOption("size", "s", "Size of the key to create, default: 2048"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		typ, found, err := req.Option("type").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if found && strings.ToLower(typ) != "rsa" {
+			res.SetError(fmt.Errorf("unsupported key type %q", typ), cmds.ErrClient)
+			return
+		}
+
+		size, found, err := req.Option("size").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			size = 2048
+		}
+
+		name := req.Arguments()[0]
+		sk, err := keystore.Create(n.Repo.Keystore(), name, ci.RSA, size)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeyOutput{Name: name, Id: pid.Pretty()})
+	},
+	Type: KeyOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			k, ok := res.Output().(*KeyOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(k.Id + "\n"), nil
+		},
+	},
+}
+
+var keyListCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List all the keypairs",
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("l", "Show the ids of the keys as well"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ks := n.Repo.Keystore()
+		names, err := ks.List()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		list := []KeyOutput{{Name: keystore.SelfKey, Id: n.Identity.Pretty()}}
+		for _, name := range names {
+			sk, err := ks.Get(name)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			pid, err := peer.IDFromPrivateKey(sk)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			list = append(list, KeyOutput{Name: name, Id: pid.Pretty()})
+		}
+
+		res.SetOutput(&KeyOutputList{list})
+	},
+	Type: KeyOutputList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: keyListMarshaler,
+	},
+}
+
+var keyRenameCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Rename a keypair",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the key to rename"),
+		cmds.StringArg("new-name", true, false, "New name of the key"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ks := n.Repo.Keystore()
+		oldName, newName := req.Arguments()[0], req.Arguments()[1]
+		if oldName == keystore.SelfKey {
+			res.SetError(fmt.Errorf("cannot rename the %q key", keystore.SelfKey), cmds.ErrClient)
+			return
+		}
+		if err := keystore.Rename(ks, oldName, newName); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		sk, err := ks.Get(newName)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&KeyOutput{Name: newName, Id: pid.Pretty()})
+	},
+	Type: KeyOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			k, ok := res.Output().(*KeyOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+			return strings.NewReader(fmt.Sprintf("renamed to %s: %s\n", k.Name, k.Id)), nil
+		},
+	},
+}
+
+var keyRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove keypairs",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, true, "Names of the keys to remove").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ks := n.Repo.Keystore()
+		var removed []KeyOutput
+		for _, name := range req.Arguments() {
+			if name == keystore.SelfKey {
+				res.SetError(fmt.Errorf("cannot remove the %q key", keystore.SelfKey), cmds.ErrClient)
+				return
+			}
+			sk, err := ks.Get(name)
+			if err != nil {
+				res.SetError(fmt.Errorf("%s: %s", name, err), cmds.ErrNormal)
+				return
+			}
+			pid, err := peer.IDFromPrivateKey(sk)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if err := ks.Delete(name); err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			removed = append(removed, KeyOutput{Name: name, Id: pid.Pretty()})
+		}
+
+		res.SetOutput(&KeyOutputList{removed})
+	},
+	Type: KeyOutputList{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: keyListMarshaler,
+	},
+}
+
+func keyListMarshaler(res cmds.Response) (io.Reader, error) {
+	list, ok := res.Output().(*KeyOutputList)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	withID, _, _ := res.Request().Option("l").Bool()
+	buf := new(bytes.Buffer)
+	for _, k := range list.Keys {
+		if withID {
+			fmt.Fprintf(buf, "%s %s\n", k.Id, k.Name)
+		} else {
+			fmt.Fprintln(buf, k.Name)
+		}
+	}
+	return buf, nil
+}
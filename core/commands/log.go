@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	u "github.com/ipfs/go-ipfs/util"
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
 
@@ -68,21 +72,127 @@ output of a running daemon.
 	Type: MessageOutput{},
 }
 
+// LogEntry is an entry of the event log, such as
+// {"event": "...", "system": "...", "time": "...", ...}.
+type LogEntry map[string]interface{}
+
 var logTailCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Read the logs",
 		ShortDescription: `
 'ipfs log tail' is a utility command used to read log output as it is written.
+Every entry of the event log is a JSON object with at least its event, the
+system which logged it and its time. '--system' only keeps the entries of
+the given systems, and '--event' those of the given events, both separated
+by commas. With '--encoding=json', the entries are streamed as JSON objects
+over the API, for remote debugging.
 `,
 	},
 
+	Options: []cmds.Option{
+		cmds.StringOption("system", "s", "only show the entries of these systems, separated by commas"),
+		cmds.StringOption("event", "e", "only show the entries of these events, separated by commas"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
+		systems, _, err := req.Option("system").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		events, _, err := req.Option("event").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		keep := logFilter("system", systems).and(logFilter("event", events))
+
+		ctx := req.Context()
 		r, w := io.Pipe()
 		logging.WriterGroup.AddWriter(w)
 		go func() {
-			<-req.Context().Done()
+			<-ctx.Done()
 			w.Close()
 		}()
-		res.SetOutput(r)
+
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			// closing the reader makes the writer fail, which drops it
+			// from the writer group
+			defer r.Close()
+
+			dec := json.NewDecoder(r)
+			for {
+				var e LogEntry
+				if err := dec.Decode(&e); err != nil {
+					if err != io.EOF && err != io.ErrClosedPipe {
+						log.Debugf("log tail: %s", err)
+					}
+					return
+				}
+				if !keep(e) {
+					continue
+				}
+				select {
+				case out <- &e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		res.SetOutput((<-chan interface{})(out))
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outCh, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				e, ok := v.(*LogEntry)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+				buf := new(bytes.Buffer)
+				if err := json.NewEncoder(buf).Encode(e); err != nil {
+					return nil, err
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outCh,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
 	},
+	Type: LogEntry{},
+}
+
+// entryFilter tells whether to keep a log entry.
+type entryFilter func(LogEntry) bool
+
+// logFilter keeps the entries whose field is one of the comma separated
+// values, or all of them if there are none.
+func logFilter(field, values string) entryFilter {
+	if values == "" {
+		return func(LogEntry) bool { return true }
+	}
+	set := make(map[string]bool)
+	for _, v := range strings.Split(values, ",") {
+		set[strings.TrimSpace(v)] = true
+	}
+	return func(e LogEntry) bool {
+		v, _ := e[field].(string)
+		return set[v]
+	}
+}
+
+// and keeps the entries both f and g keep.
+func (f entryFilter) and(g entryFilter) entryFilter {
+	return func(e LogEntry) bool {
+		return f(e) && g(e)
+	}
 }
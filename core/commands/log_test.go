@@ -0,0 +1,26 @@
+package commands
+
+import "testing"
+
+func TestLogFilter(t *testing.T) {
+	keep := logFilter("system", "dht, bitswap").and(logFilter("event", ""))
+
+	for _, c := range []struct {
+		entry LogEntry
+		kept  bool
+	}{
+		{LogEntry{"system": "dht", "event": "findPeer"}, true},
+		{LogEntry{"system": "bitswap", "event": "sendMessage"}, true},
+		{LogEntry{"system": "swarm2", "event": "dial"}, false},
+		{LogEntry{"event": "noSystem"}, false},
+	} {
+		if keep(c.entry) != c.kept {
+			t.Errorf("expected kept to be %t for %v", c.kept, c.entry)
+		}
+	}
+
+	keep = logFilter("system", "").and(logFilter("event", "dial"))
+	if !keep(LogEntry{"system": "swarm2", "event": "dial"}) || keep(LogEntry{"system": "dht", "event": "findPeer"}) {
+		t.Error("expected only the dial events to be kept")
+	}
+}
@@ -24,6 +24,6 @@ For the latest instructions, please check the project's repository:
 	},
 }
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
+func Mount(node *core.IpfsNode, fsdir, nsdir, filesdir string) error {
 	return errors.New("not compiled in")
 }
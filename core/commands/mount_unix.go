@@ -37,7 +37,7 @@ var MountCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Mounts IPFS to the filesystem (read-only)",
 		Synopsis: `
-ipfs mount [-f <ipfs mount path>] [-n <ipns mount path>]
+ipfs mount [-f <ipfs mount path>] [-n <ipns mount path>] [--files-path <files mount path>]
 `,
 		ShortDescription: `
 Mount ipfs at a read-only mountpoint on the OS (default: /ipfs and /ipns).
@@ -50,6 +50,10 @@ You may have to create /ipfs and /ipns before using 'ipfs mount':
 > sudo chown ` + "`" + `whoami` + "`" + ` /ipfs /ipns
 > ipfs daemon &
 > ipfs mount
+
+With --files-path, or Mounts.Files set in the config, the files root is
+mounted too, writable, so the tree of the 'ipfs files' commands can be
+worked on with the usual tools.
 `,
 		LongDescription: `
 Mount ipfs at a read-only mountpoint on the OS (default: /ipfs and /ipns).
@@ -90,11 +94,22 @@ baz
 baz
 > cat /ipfs/QmWLdkp93sNxGRjnFHPaYg8tCQ35NBY3XPn6KiETd3Z4WR
 baz
+
+# the files root
+> mkdir /files
+> ipfs mount --files-path=/files
+IPFS mounted at: /ipfs
+IPNS mounted at: /ipns
+Files mounted at: /files
+> echo "baz" > /files/bar
+> ipfs files ls /
+bar
 `,
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("ipfs-path", "f", "The path where IPFS should be mounted"),
 		cmds.StringOption("ipns-path", "n", "The path where IPNS should be mounted"),
+		cmds.StringOption("files-path", "The path where the files root should be mounted"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		cfg, err := req.InvocContext().GetConfig()
@@ -134,7 +149,16 @@ baz
 			nsdir = cfg.Mounts.IPNS // NB: be sure to not redeclare!
 		}
 
-		err = Mount(node, fsdir, nsdir)
+		filesdir, found, err := req.Option("files-path").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			filesdir = cfg.Mounts.Files
+		}
+
+		err = Mount(node, fsdir, nsdir, filesdir)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -143,6 +167,7 @@ baz
 		var output config.Mounts
 		output.IPFS = fsdir
 		output.IPNS = nsdir
+		output.Files = filesdir
 		res.SetOutput(&output)
 	},
 	Type: config.Mounts{},
@@ -151,12 +176,17 @@ baz
 			v := res.Output().(*config.Mounts)
 			s := fmt.Sprintf("IPFS mounted at: %s\n", v.IPFS)
 			s += fmt.Sprintf("IPNS mounted at: %s\n", v.IPNS)
+			if v.Files != "" {
+				s += fmt.Sprintf("Files mounted at: %s\n", v.Files)
+			}
 			return strings.NewReader(s), nil
 		},
 	},
 }
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
+// Mount mounts ipfs and ipns at fsdir and nsdir, and the files root at
+// filesdir, unless it is empty.
+func Mount(node *core.IpfsNode, fsdir, nsdir, filesdir string) error {
 	// check if we already have live mounts.
 	// if the user said "Mount", then there must be something wrong.
 	// so, close them and try again.
@@ -166,6 +196,10 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 	if node.Mounts.Ipns != nil {
 		node.Mounts.Ipns.Unmount()
 	}
+	if node.Mounts.Files != nil {
+		node.Mounts.Files.Unmount()
+		node.Mounts.Files = nil
+	}
 
 	if err := platformFuseChecks(node); err != nil {
 		return err
@@ -176,24 +210,37 @@ func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
 		return err
 	}
 
+	if filesdir != "" {
+		filesmount, err := ipns.MountFiles(node, filesdir)
+		if err != nil {
+			log.Errorf("error mounting the files root: %s", err)
+			node.Mounts.Ipfs.Unmount()
+			node.Mounts.Ipns.Unmount()
+			return fmtFuseErr(err, filesdir)
+		}
+		node.Mounts.Files = filesmount
+	}
+
 	return nil
 }
 
-func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
-	fmtFuseErr := func(err error, mountpoint string) error {
-		s := err.Error()
-		if strings.Contains(s, fuseNoDirectory) {
-			s = strings.Replace(s, `fusermount: "fusermount:`, "", -1)
-			s = strings.Replace(s, `\n", exit status 1`, "", -1)
-			return cmds.ClientError(s)
-		}
-		if s == fuseExitStatus1 {
-			s = fmt.Sprintf("fuse failed to access mountpoint %s", mountpoint)
-			return cmds.ClientError(s)
-		}
-		return err
+// fmtFuseErr turns the errors of fusermount about the mountpoint into
+// client errors.
+func fmtFuseErr(err error, mountpoint string) error {
+	s := err.Error()
+	if strings.Contains(s, fuseNoDirectory) {
+		s = strings.Replace(s, `fusermount: "fusermount:`, "", -1)
+		s = strings.Replace(s, `\n", exit status 1`, "", -1)
+		return cmds.ClientError(s)
 	}
+	if s == fuseExitStatus1 {
+		s = fmt.Sprintf("fuse failed to access mountpoint %s", mountpoint)
+		return cmds.ClientError(s)
+	}
+	return err
+}
 
+func doMount(node *core.IpfsNode, fsdir, nsdir string) error {
 	// this sync stuff is so that both can be mounted simultaneously.
 	var fsmount mount.Mount
 	var nsmount mount.Mount
@@ -1,25 +1,137 @@
 package commands
 
 import (
-	"errors"
+	"fmt"
+	"io"
+	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
-	"github.com/ipfs/go-ipfs/core"
+	core "github.com/ipfs/go-ipfs/core"
+	webdav "github.com/ipfs/go-ipfs/fuse/webdav"
+	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
 var MountCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Not yet implemented on Windows",
-		ShortDescription: "Not yet implemented on Windows. :(",
-	},
+		Tagline: "Mounts IPFS to the filesystem (read-only)",
+		Synopsis: `
+ipfs mount [-f <ipfs drive>] [-n <ipns drive>]
+`,
+		ShortDescription: `
+Mount ipfs and ipns as read-only network drives. Windows has no fuse, so
+the daemon serves them with WebDAV on a local port, and maps the shares to
+the given drive letters with 'net use'. All ipfs objects are accessible
+under the ipfs drive. Note that the root will not be listable, as it is
+virtual. Access known paths directly.
+
+The mountpoints are drive letters, set them in the config once:
 
+> ipfs config Mounts.IPFS I:
+> ipfs config Mounts.IPNS N:
+> ipfs daemon &
+> ipfs mount
+
+The WebClient service of Windows has to be running, and limits the size
+of the files it reads to 50MB unless configured otherwise.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("ipfs-path", "f", "The drive where IPFS should be mounted"),
+		cmds.StringOption("ipns-path", "n", "The drive where IPNS should be mounted"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
-		res.SetError(errors.New("Mount isn't compatible with Windows yet"), cmds.ErrNormal)
+		cfg, err := req.InvocContext().GetConfig()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		node, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		// error if we aren't running node in online mode
+		if !node.OnlineMode() {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		fsdir, found, err := req.Option("f").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			fsdir = cfg.Mounts.IPFS
+		}
+
+		nsdir, found, err := req.Option("n").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			nsdir = cfg.Mounts.IPNS
+		}
+
+		err = Mount(node, fsdir, nsdir, cfg.Mounts.Files)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var output config.Mounts
+		output.IPFS = fsdir
+		output.IPNS = nsdir
+		res.SetOutput(&output)
+	},
+	Type: config.Mounts{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			v := res.Output().(*config.Mounts)
+			s := fmt.Sprintf("IPFS mounted at: %s\n", v.IPFS)
+			s += fmt.Sprintf("IPNS mounted at: %s\n", v.IPNS)
+			return strings.NewReader(s), nil
+		},
 	},
 }
 
-func Mount(node *core.IpfsNode, fsdir, nsdir string) error {
-	// TODO
-	// currently a no-op, but we don't want to return an error
+// Mount maps the ipfs and ipns namespaces of node to the drives fsdir and
+// nsdir, served with WebDAV instead of fuse. The shares are read-only, so
+// the files root cannot be mounted, filesdir has to be empty.
+func Mount(node *core.IpfsNode, fsdir, nsdir, filesdir string) error {
+	if filesdir != "" {
+		return cmds.ClientError("mounting the files root is not supported on Windows")
+	}
+
+	// as with fuse, mounting again replaces the live mounts
+	if node.Mounts.Ipfs != nil {
+		node.Mounts.Ipfs.Unmount()
+	}
+	if node.Mounts.Ipns != nil {
+		node.Mounts.Ipns.Unmount()
+	}
+
+	for _, drive := range []string{fsdir, nsdir} {
+		if len(drive) != 2 || drive[1] != ':' {
+			return cmds.ClientError(fmt.Sprintf("mountpoint %q is not a drive letter, such as I:", drive))
+		}
+	}
+
+	fsmount, err := webdav.Mount(node, "/ipfs", fsdir)
+	if err != nil {
+		return err
+	}
+	nsmount, err := webdav.Mount(node, "/ipns", nsdir)
+	if err != nil {
+		fsmount.Unmount()
+		return err
+	}
+
+	// setup node state, so that it can be cancelled
+	node.Mounts.Ipfs = fsmount
+	node.Mounts.Ipns = nsmount
 	return nil
 }
@@ -57,6 +57,7 @@ ipfs object links <key>     - Outputs links pointed to by object
 ipfs object stat <key>      - Outputs statistics of object
 ipfs object new <template>  - Create new ipfs objects
 ipfs object patch <args>    - Create new object from old ones
+ipfs object diff <a> <b>    - Outputs the changes from one object to another
 `,
 	},
 
@@ -68,6 +69,7 @@ ipfs object patch <args>    - Create new object from old ones
 		"stat":  objectStatCmd,
 		"new":   objectNewCmd,
 		"patch": objectPatchCmd,
+		"diff":  objectDiffCmd,
 	},
 }
 
@@ -441,10 +443,10 @@ can also set the data inside a node with 'set-data' and append to that data as
 well with 'append-data'.
 
 Patch commands:
-    add-link <name> <ref>     - adds a link to a node
-    rm-link <name>            - removes a link from a node
-    set-data                  - sets a nodes data from stdin
-    append-data               - appends to a nodes data from stdin
+    add-link <name> <ref>         - adds a link to a node
+    rm-link <name>                - removes a link from a node
+    set-data [<path>] <data>      - sets a nodes data
+    append-data [<path>] <data>   - appends to a nodes data
 
 Examples:
 
@@ -465,6 +467,11 @@ The data inside the node can be modified as well:
     ipfs object patch $FOO_BAR set-data < file.dat
     ipfs object patch $FOO_BAR append-data < file.dat
 
+The data is read from stdin when it is the only argument. Given a path as
+well, they modify the data of the node below the root at that path:
+
+    ipfs object patch $FOO_BAR set-data foo "$(cat file.dat)"
+
 `,
 	},
 	Options: []cmds.Option{
@@ -547,9 +554,149 @@ The data inside the node can be modified as well:
 	},
 }
 
+// ObjectChange is a change between two objects, at Path below them. Type is
+// one of "add", "remove" and "mod", and Before and After are the hashes of
+// the objects at Path before and after the change, if any.
+type ObjectChange struct {
+	Type   string
+	Path   string
+	Before string `json:",omitempty"`
+	After  string `json:",omitempty"`
+}
+
+// ObjectChanges are the changes from one object to another.
+type ObjectChanges struct {
+	Changes []ObjectChange
+}
+
+var objectDiffCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Outputs the changes from one object to another",
+		ShortDescription: `
+'ipfs object diff <a> <b>' is a plumbing command comparing the DAGs of two
+objects, such as two versions of a published directory. It outputs the
+paths below them that were added, removed or changed to turn <a> into <b>.
+Unchanged links are not walked, so only the differing parts are fetched.
+`,
+		LongDescription: `
+'ipfs object diff <a> <b>' is a plumbing command comparing the DAGs of two
+objects, such as two versions of a published directory. It outputs the
+paths below them that were added, removed or changed to turn <a> into <b>.
+Unchanged links are not walked, so only the differing parts are fetched.
+
+Every change is printed on a line of its own:
+
+    + <path> <hash>               - <path> was added
+    - <path> <hash>               - <path> was removed
+    ~ <path> <before> -> <after>  - the object at <path> changed
+
+A change of <a> itself, which has no path, is printed with the path '.'.
+Use '--encoding=json' for a machine-readable list of the changes.
+
+Example:
+
+    > ipfs object diff /ipns/example.com /ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD
+    + images/logo.png QmWnDCkixGJZKVyXF6yjyyH4qHYQEWkfUEnESYkbp4Xbse
+    ~ index.html QmUmb1Pkpx3fqw8C3FUtBDxoE1sKKsSAEyDNdM8RVXdTGL -> QmNjCCYuVpzKWLJpg2sNEG5y8CTEzbcqjkXnN5G9sXQH1X
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("obj_a", true, false, "the object to diff from"),
+		cmds.StringArg("obj_b", true, false, "the object to diff to"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ctx := req.Context()
+		a, err := core.Resolve(ctx, n, path.Path(req.Arguments()[0]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		b, err := core.Resolve(ctx, n, path.Path(req.Arguments()[1]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		changes, err := dagutils.Diff(ctx, n.DAG, a, b)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &ObjectChanges{Changes: make([]ObjectChange, len(changes))}
+		for i, c := range changes {
+			oc := ObjectChange{Path: c.Path}
+			switch c.Type {
+			case dagutils.Add:
+				oc.Type = "add"
+			case dagutils.Remove:
+				oc.Type = "remove"
+			case dagutils.Mod:
+				oc.Type = "mod"
+			}
+			if c.Before != "" {
+				oc.Before = c.Before.B58String()
+			}
+			if c.After != "" {
+				oc.After = c.After.B58String()
+			}
+			out.Changes[i] = oc
+		}
+		res.SetOutput(out)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*ObjectChanges)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			for _, c := range out.Changes {
+				p := c.Path
+				if p == "" {
+					p = "."
+				}
+				switch c.Type {
+				case "add":
+					fmt.Fprintf(buf, "+ %s %s\n", p, c.After)
+				case "remove":
+					fmt.Fprintf(buf, "- %s %s\n", p, c.Before)
+				default:
+					fmt.Fprintf(buf, "~ %s %s -> %s\n", p, c.Before, c.After)
+				}
+			}
+			return buf, nil
+		},
+	},
+	Type: ObjectChanges{},
+}
+
+// dataArgs returns the path and the data given to set-data or append-data,
+// the path being optional.
+func dataArgs(req cmds.Request, cmd string) (string, []byte, error) {
+	switch args := req.Arguments(); len(args) {
+	case 3:
+		return "", []byte(args[2]), nil
+	case 4:
+		return args[2], []byte(args[3]), nil
+	case 2:
+		return "", nil, fmt.Errorf("not enough arguments for %s", cmd)
+	default:
+		return "", nil, fmt.Errorf("too many arguments for %s", cmd)
+	}
+}
+
 func appendDataCaller(req cmds.Request, root *dag.Node) (key.Key, error) {
-	if len(req.Arguments()) < 3 {
-		return "", fmt.Errorf("not enough arguments for set-data")
+	path, data, err := dataArgs(req, "append-data")
+	if err != nil {
+		return "", err
 	}
 
 	nd, err := req.InvocContext().GetNode()
@@ -557,19 +704,20 @@ func appendDataCaller(req cmds.Request, root *dag.Node) (key.Key, error) {
 		return "", err
 	}
 
-	root.Data = append(root.Data, []byte(req.Arguments()[2])...)
+	e := dagutils.NewDagEditor(nd.DAG, root)
 
-	newkey, err := nd.DAG.Add(root)
+	err = e.AppendDataAtPath(req.Context(), path, data)
 	if err != nil {
 		return "", err
 	}
 
-	return newkey, nil
+	return e.GetNode().Key()
 }
 
 func setDataCaller(req cmds.Request, root *dag.Node) (key.Key, error) {
-	if len(req.Arguments()) < 3 {
-		return "", fmt.Errorf("not enough arguments for set-data")
+	path, data, err := dataArgs(req, "set-data")
+	if err != nil {
+		return "", err
 	}
 
 	nd, err := req.InvocContext().GetNode()
@@ -577,14 +725,14 @@ func setDataCaller(req cmds.Request, root *dag.Node) (key.Key, error) {
 		return "", err
 	}
 
-	root.Data = []byte(req.Arguments()[2])
+	e := dagutils.NewDagEditor(nd.DAG, root)
 
-	newkey, err := nd.DAG.Add(root)
+	err = e.SetDataAtPath(req.Context(), path, data)
 	if err != nil {
 		return "", err
 	}
 
-	return newkey, nil
+	return e.GetNode().Key()
 }
 
 func rmLinkCaller(req cmds.Request, root *dag.Node) (key.Key, error) {
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	pin "github.com/ipfs/go-ipfs/pin"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -17,16 +20,21 @@ var PinCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"add": addPinCmd,
-		"rm":  rmPinCmd,
-		"ls":  listPinCmd,
+		"add":    addPinCmd,
+		"rm":     rmPinCmd,
+		"ls":     listPinCmd,
+		"verify": verifyPinCmd,
 	},
 }
 
 type PinOutput struct {
-	Pinned []key.Key
+	Pinned   []key.Key
+	Progress int `json:",omitempty"`
 }
 
+// how often to send progress updates for 'pin add --progress'
+const pinProgressInterval = 500 * time.Millisecond
+
 var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Pins objects to local storage",
@@ -41,6 +49,10 @@ on disk.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Recursively pin the object linked to by the specified object(s)"),
+		cmds.StringOption("name", "A name to remember the pin(s) by"),
+		cmds.StringOption("labels", "Comma separated key=value labels to attach to the pin(s)"),
+		cmds.StringOption("expire-in", "Remove the pin(s) automatically after the given duration, e.g. '1h30m'"),
+		cmds.BoolOption("progress", "Show the number of nodes fetched while pinning"),
 	},
 	Type: PinOutput{},
 	Run: func(req cmds.Request, res cmds.Response) {
@@ -60,21 +72,92 @@ on disk.
 			recursive = true
 		}
 
-		added, err := corerepo.Pin(n, req.Context(), req.Arguments(), recursive)
+		info, err := pinInfoFromOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		expireIn, found, err := req.Option("expire-in").String()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		if found {
+			d, err := time.ParseDuration(expireIn)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid expire-in duration: %s", err), cmds.ErrClient)
+				return
+			}
+			if info == nil {
+				info = new(pin.PinInfo)
+			}
+			expires := time.Now().Add(d)
+			info.Expires = &expires
+		}
+
+		showProgress, _, err := req.Option("progress").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !showProgress {
+			added, err := corerepo.PinWithInfo(n, req.Context(), req.Arguments(), recursive, info)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+
+			res.SetOutput(&PinOutput{Pinned: added})
+			return
+		}
+
+		pt := new(pin.ProgressTracker)
+		ctx := pin.ContextWithProgress(req.Context(), pt)
+
+		type pinResult struct {
+			added []key.Key
+			err   error
+		}
+		done := make(chan pinResult, 1)
+		go func() {
+			added, err := corerepo.PinWithInfo(n, ctx, req.Arguments(), recursive, info)
+			done <- pinResult{added, err}
+		}()
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+			ticker := time.NewTicker(pinProgressInterval)
+			defer ticker.Stop()
 
-		res.SetOutput(&PinOutput{added})
+			for {
+				select {
+				case <-ticker.C:
+					select {
+					case out <- &PinOutput{Progress: pt.Value()}:
+					case <-req.Context().Done():
+						return
+					}
+				case r := <-done:
+					if r.err != nil {
+						res.SetError(r.err, cmds.ErrNormal)
+						return
+					}
+					select {
+					case out <- &PinOutput{Pinned: r.added, Progress: pt.Value()}:
+					case <-req.Context().Done():
+					}
+					return
+				}
+			}
+		}()
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			added, ok := res.Output().(*PinOutput)
-			if !ok {
-				return nil, u.ErrCast()
-			}
-
 			var pintype string
 			rec, found, _ := res.Request().Option("recursive").Bool()
 			if rec || !found {
@@ -83,11 +166,37 @@ on disk.
 				pintype = "directly"
 			}
 
-			buf := new(bytes.Buffer)
-			for _, k := range added.Pinned {
-				fmt.Fprintf(buf, "pinned %s %s\n", k, pintype)
+			marshal := func(v interface{}) (io.Reader, error) {
+				added, ok := v.(*PinOutput)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				if added.Pinned == nil {
+					fmt.Fprintf(buf, "\033[2K\rFetched/Processed %d nodes", added.Progress)
+					return buf, nil
+				}
+
+				if added.Progress > 0 {
+					fmt.Fprint(buf, "\033[2K\r")
+				}
+				for _, k := range added.Pinned {
+					fmt.Fprintf(buf, "pinned %s %s\n", k, pintype)
+				}
+				return buf, nil
 			}
-			return buf, nil
+
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return marshal(res.Output())
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
 		},
 	},
 }
@@ -131,7 +240,7 @@ collected if needed. (By default, recursively. Use -r=false for direct pins)
 			return
 		}
 
-		res.SetOutput(&PinOutput{removed})
+		res.SetOutput(&PinOutput{Pinned: removed})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -176,6 +285,8 @@ Example:
 		cmds.StringOption("type", "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\". Defaults to \"recursive\""),
 		cmds.BoolOption("count", "n", "Show refcount when listing indirect pins"),
 		cmds.BoolOption("quiet", "q", "Write just hashes of objects"),
+		cmds.StringOption("name", "Only list direct and recursive pins with the given name"),
+		cmds.StringOption("labels", "Only list direct and recursive pins with all of the given comma separated key=value labels"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.InvocContext().GetNode()
@@ -198,19 +309,51 @@ Example:
 		default:
 			err = fmt.Errorf("Invalid type '%s', must be one of {direct, indirect, recursive, all}", typeStr)
 			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		filter, err := pinInfoFromOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		if filter != nil && typeStr == "indirect" {
+			res.SetError(fmt.Errorf("indirect pins have no name or labels"), cmds.ErrClient)
+			return
 		}
 
 		keys := make(map[string]RefKeyObject)
+		addKey := func(k key.Key, typ string) {
+			info, _ := n.Pinning.GetPinInfo(k)
+			if filter != nil && (info == nil || !info.Matches(filter.Name, filter.Labels)) {
+				return
+			}
+
+			obj := RefKeyObject{
+				Type:  typ,
+				Count: 1,
+			}
+			if info != nil {
+				obj.Name = info.Name
+				obj.Labels = info.Labels
+			}
+			keys[k.B58String()] = obj
+		}
+
 		if typeStr == "direct" || typeStr == "all" {
 			for _, k := range n.Pinning.DirectKeys() {
-				keys[k.B58String()] = RefKeyObject{
-					Type:  "direct",
-					Count: 1,
-				}
+				addKey(k, "direct")
 			}
 		}
-		if typeStr == "indirect" || typeStr == "all" {
-			for k, v := range n.Pinning.IndirectKeys() {
+		// indirect pins never carry metadata, so they can't match a filter
+		if (typeStr == "indirect" || typeStr == "all") && filter == nil {
+			indirect, err := n.Pinning.IndirectKeys(req.Context())
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			for k, v := range indirect {
 				keys[k.B58String()] = RefKeyObject{
 					Type:  "indirect",
 					Count: v,
@@ -219,10 +362,7 @@ Example:
 		}
 		if typeStr == "recursive" || typeStr == "all" {
 			for _, k := range n.Pinning.RecursiveKeys() {
-				keys[k.B58String()] = RefKeyObject{
-					Type:  "recursive",
-					Count: 1,
-				}
+				addKey(k, "recursive")
 			}
 		}
 
@@ -261,9 +401,12 @@ Example:
 				}
 			} else {
 				for k, v := range keys.Keys {
-					if quiet {
+					switch {
+					case quiet:
 						fmt.Fprintf(out, "%s\n", k)
-					} else {
+					case v.Name != "":
+						fmt.Fprintf(out, "%s %s %s\n", k, v.Type, v.Name)
+					default:
 						fmt.Fprintf(out, "%s %s\n", k, v.Type)
 					}
 				}
@@ -273,11 +416,163 @@ Example:
 	},
 }
 
+var verifyPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify that recursive pins are complete",
+		ShortDescription: `
+Checks that every block referenced by a recursive pin is stored locally,
+reporting pins with missing or corrupt blocks. Blocks are never fetched
+from the network.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.BoolOption("verbose", "Also write the hashes of pins that are intact"),
+		cmds.BoolOption("check-hashes", "Rehash the data of every block to detect corruption"),
+		cmds.BoolOption("quiet", "q", "Write just hashes of broken pins"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		verbose, _, err := req.Option("verbose").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		checkHashes, _, err := req.Option("check-hashes").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		opts := pin.VerifyOptions{
+			VerifyHashes: checkHashes,
+			IncludeOk:    verbose,
+		}
+
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
+
+		go func() {
+			defer close(out)
+			for st := range n.Pinning.Verify(req.Context(), n.Blockstore, opts) {
+				out <- pinStatusToOutput(st)
+			}
+		}()
+	},
+	Type: PinVerifyRes{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			quiet, _, err := res.Request().Option("quiet").Bool()
+			if err != nil {
+				return nil, err
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				st, ok := v.(*PinVerifyRes)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				switch {
+				case quiet:
+					if !st.Ok {
+						fmt.Fprintf(buf, "%s\n", st.Key)
+					}
+				case st.Ok:
+					fmt.Fprintf(buf, "%s ok\n", st.Key)
+				default:
+					fmt.Fprintf(buf, "%s broken\n", st.Key)
+					for _, bad := range st.BadNodes {
+						fmt.Fprintf(buf, "  %s: %s\n", bad.Key, bad.Err)
+					}
+				}
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+				Res:       res,
+			}, nil
+		},
+	},
+}
+
+type PinVerifyRes struct {
+	Key      string
+	Ok       bool
+	BadNodes []BadNodeOutput `json:",omitempty"`
+}
+
+type BadNodeOutput struct {
+	Key string
+	Err string
+}
+
+func pinStatusToOutput(st *pin.PinStatus) *PinVerifyRes {
+	out := &PinVerifyRes{
+		Key: st.Key.B58String(),
+		Ok:  st.Ok,
+	}
+	for _, bad := range st.BadNodes {
+		out.BadNodes = append(out.BadNodes, BadNodeOutput{
+			Key: bad.Key.B58String(),
+			Err: bad.Err.Error(),
+		})
+	}
+	return out
+}
+
 type RefKeyObject struct {
-	Type  string
-	Count int
+	Type   string
+	Count  int
+	Name   string            `json:",omitempty"`
+	Labels map[string]string `json:",omitempty"`
 }
 
 type RefKeyList struct {
 	Keys map[string]RefKeyObject
 }
+
+// pinInfoFromOptions builds pin metadata from the 'name' and 'labels'
+// options of a request, returning nil if neither was given.
+func pinInfoFromOptions(req cmds.Request) (*pin.PinInfo, error) {
+	name, nameFound, err := req.Option("name").String()
+	if err != nil {
+		return nil, err
+	}
+
+	labelStr, labelsFound, err := req.Option("labels").String()
+	if err != nil {
+		return nil, err
+	}
+
+	if !nameFound && !labelsFound {
+		return nil, nil
+	}
+
+	info := &pin.PinInfo{Name: name}
+	if labelStr != "" {
+		info.Labels = make(map[string]string)
+		for _, kv := range strings.Split(labelStr, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("invalid label '%s', expected key=value", kv)
+			}
+			info.Labels[parts[0]] = parts[1]
+		}
+	}
+	return info, nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -34,7 +35,9 @@ Send pings to a peer using the routing system to discover its address
 		ShortDescription: `
 ipfs ping is a tool to test sending data to other nodes. It finds nodes
 via the routing system, send pings, wait for pongs, and print out round-
-trip latency information.
+trip latency information. '--count' sets how many pings are sent, and
+'--interval' how long to wait between them. The latencies measured are
+kept in the peerstore, where routing uses them to prefer closer peers.
 		`,
 	},
 	Arguments: []cmds.Argument{
@@ -42,6 +45,7 @@ trip latency information.
 	},
 	Options: []cmds.Option{
 		cmds.IntOption("count", "n", "number of ping messages to send"),
+		cmds.StringOption("interval", "i", "time to wait between ping messages (default: 1s)"),
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -110,13 +114,31 @@ trip latency information.
 			numPings = val
 		}
 
-		outChan := pingPeer(ctx, n, peerID, numPings)
+		interval := time.Second
+		ival, found, err := req.Option("interval").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if found {
+			interval, err = time.ParseDuration(ival)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+			if interval < 0 {
+				res.SetError(errors.New("interval must not be negative"), cmds.ErrClient)
+				return
+			}
+		}
+
+		outChan := pingPeer(ctx, n, peerID, numPings, interval)
 		res.SetOutput(outChan)
 	},
 	Type: PingResult{},
 }
 
-func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int) <-chan interface{} {
+func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int, interval time.Duration) <-chan interface{} {
 	outChan := make(chan interface{})
 	go func() {
 		defer close(outChan)
@@ -139,7 +161,7 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 
 		outChan <- &PingResult{Text: fmt.Sprintf("PING %s.", pid.Pretty())}
 
-		ctx, cancel := context.WithTimeout(ctx, kPingTimeout*time.Duration(numPings))
+		ctx, cancel := context.WithTimeout(ctx, (kPingTimeout+interval)*time.Duration(numPings))
 		defer cancel()
 		pings, err := n.Ping.Ping(ctx, pid)
 		if err != nil {
@@ -150,6 +172,7 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 
 		var done bool
 		var total time.Duration
+		var received int
 		for i := 0; i < numPings && !done; i++ {
 			select {
 			case <-ctx.Done():
@@ -166,10 +189,17 @@ func pingPeer(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int)
 					Time:    t,
 				}
 				total += t
-				time.Sleep(time.Second)
+				received++
+				if i < numPings-1 {
+					time.Sleep(interval)
+				}
 			}
 		}
-		averagems := total.Seconds() * 1000 / float64(numPings)
+		if received == 0 {
+			outChan <- &PingResult{Text: "No pongs received"}
+			return
+		}
+		averagems := total.Seconds() * 1000 / float64(received)
 		outChan <- &PingResult{
 			Text: fmt.Sprintf("Average latency: %.2fms", averagems),
 		}
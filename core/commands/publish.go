@@ -12,6 +12,8 @@ import (
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	crypto "github.com/ipfs/go-ipfs/p2p/crypto"
 	path "github.com/ipfs/go-ipfs/path"
 )
@@ -24,12 +26,14 @@ var PublishCmd = &cmds.Command{
 		ShortDescription: `
 IPNS is a PKI namespace, where names are the hashes of public keys, and
 the private key enables publishing new (signed) values. In publish, the
-default value of <name> is your own identity public key.
+default value of <name> is your own identity public key; use --key to
+publish to the name of another key in the keystore.
 `,
 		LongDescription: `
 IPNS is a PKI namespace, where names are the hashes of public keys, and
 the private key enables publishing new (signed) values. In publish, the
-default value of <name> is your own identity public key.
+default value of <name> is your own identity public key; use --key to
+publish to the name of another key in the keystore.
 
 Examples:
 
@@ -38,11 +42,27 @@ Publish an <ipfs-path> to your identity name:
   > ipfs name publish /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
-Publish an <ipfs-path> to another public key (not implemented):
+Publish a record valid for a week, which resolvers may cache for an hour:
 
-  > ipfs name publish /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  > ipfs name publish --lifetime=168h --ttl=1h /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   Published to QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+The defaults of --lifetime and --ttl are the Ipns.RecordLifetime and
+Ipns.RecordTTL config settings.
+
+Publish an <ipfs-path> to the name of another key, created with
+'ipfs key gen':
+
+  > ipfs key gen mykey
+  QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd
+  > ipfs name publish --key=mykey /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  Published to QmSrPmbaUKA3ZodhzPWZnpFgcPMFWF4QsxXbkWfEptTBJd: /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
+Without the daemon, the record is stored locally, and the daemon announces
+it once it is connected. While the daemon has no peers, publishing fails,
+unless the Ipns.OfflinePublish config setting is true, in which case the
+record is likewise stored locally and announced once connected.
+
 `,
 	},
 
@@ -52,6 +72,8 @@ Publish an <ipfs-path> to another public key (not implemented):
 	Options: []cmds.Option{
 		cmds.BoolOption("resolve", "resolve given path before publishing (default=true)"),
 		cmds.StringOption("lifetime", "t", "time duration that the record will be valid for (default: 24hrs)"),
+		cmds.StringOption("ttl", "time duration that resolvers may cache the record for"),
+		cmds.StringOption("key", "k", "name of the key to publish with, see 'ipfs key list' (default: self)"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		log.Debug("Begin Publish")
@@ -76,16 +98,26 @@ Publish an <ipfs-path> to another public key (not implemented):
 			return
 		}
 
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		popts := &publishOpts{
 			verifyExists: true,
-			pubValidTime: time.Hour * 24,
+			pubValidTime: namesys.DefaultRecordLifetime,
 		}
 
 		verif, found, _ := req.Option("resolve").Bool()
 		if found {
 			popts.verifyExists = verif
 		}
+
 		validtime, found, _ := req.Option("lifetime").String()
+		if !found && cfg.Ipns.RecordLifetime != "" {
+			validtime, found = cfg.Ipns.RecordLifetime, true
+		}
 		if found {
 			d, err := time.ParseDuration(validtime)
 			if err != nil {
@@ -96,7 +128,31 @@ Publish an <ipfs-path> to another public key (not implemented):
 			popts.pubValidTime = d
 		}
 
-		output, err := publish(req.Context(), n, n.PrivateKey, path.Path(pstr), popts)
+		ttl, found, _ := req.Option("ttl").String()
+		if !found && cfg.Ipns.RecordTTL != "" {
+			ttl, found = cfg.Ipns.RecordTTL, true
+		}
+		if found {
+			d, err := time.ParseDuration(ttl)
+			if err != nil {
+				res.SetError(fmt.Errorf("error parsing ttl option: %s", err), cmds.ErrNormal)
+				return
+			}
+
+			popts.ttl = d
+		}
+
+		kname, found, _ := req.Option("key").String()
+		if !found {
+			kname = keystore.SelfKey
+		}
+		k, err := n.Keystore().Get(kname)
+		if err != nil {
+			res.SetError(fmt.Errorf("key %q: %s", kname, err), cmds.ErrNormal)
+			return
+		}
+
+		output, err := publish(req.Context(), n, k, path.Path(pstr), popts)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -116,6 +172,7 @@ Publish an <ipfs-path> to another public key (not implemented):
 type publishOpts struct {
 	verifyExists bool
 	pubValidTime time.Duration
+	ttl          time.Duration
 }
 
 func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.Path, opts *publishOpts) (*IpnsEntry, error) {
@@ -129,7 +186,7 @@ func publish(ctx context.Context, n *core.IpfsNode, k crypto.PrivKey, ref path.P
 	}
 
 	eol := time.Now().Add(opts.pubValidTime)
-	err := n.Namesys.PublishWithEOL(ctx, k, ref, eol)
+	err := n.Namesys.PublishWithTTL(ctx, k, ref, eol, opts.ttl)
 	if err != nil {
 		return nil, err
 	}
@@ -3,16 +3,12 @@ package commands
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"strings"
 
-	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	cmds "github.com/ipfs/go-ipfs/commands"
-	"github.com/ipfs/go-ipfs/core"
-	dag "github.com/ipfs/go-ipfs/merkledag"
-	path "github.com/ipfs/go-ipfs/path"
+	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -41,6 +37,21 @@ hashes it contains, with the following format:
   <link base58 hash>
 
 Note: list all refs recursively with -r.
+`,
+		LongDescription: `
+Retrieves the object named by <ipfs-path> and displays the link
+hashes it contains, with the following format:
+
+  <link base58 hash>
+
+List all refs recursively with -r, down to --max-depth, and only once
+each with -u. The refs are streamed as they are found.
+
+The --format option emits each ref with the given format, where the
+tokens <src>, <dst> and <linkname> are replaced by the hash of the
+object linking, the hash linked to and the name of the link:
+
+  > ipfs refs -r --format='<src> <linkname> <dst>' <ipfs-path>
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -54,6 +65,7 @@ Note: list all refs recursively with -r.
 		cmds.BoolOption("edges", "e", "Emit edge format: `<from> -> <to>`"),
 		cmds.BoolOption("unique", "u", "Omit duplicate refs from output"),
 		cmds.BoolOption("recursive", "r", "Recursively list links of child nodes"),
+		cmds.IntOption("max-depth", "Only for recursive refs, limits fetch and listing to the given depth"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		ctx := req.Context()
@@ -87,7 +99,20 @@ Note: list all refs recursively with -r.
 			return
 		}
 
-		objs, err := objectsForPaths(ctx, n, req.Arguments())
+		maxDepth, _, err := req.Option("max-depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		opts := corerepo.RefsOptions{
+			Recursive: recursive,
+			Unique:    unique,
+			MaxDepth:  maxDepth,
+			Edges:     edges,
+			Format:    format,
+		}
+		refs, err := corerepo.Refs(ctx, n, req.Arguments(), opts)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -99,51 +124,21 @@ Note: list all refs recursively with -r.
 		go func() {
 			defer close(out)
 
-			rw := RefWriter{
-				out:       out,
-				DAG:       n.DAG,
-				Ctx:       ctx,
-				Unique:    unique,
-				PrintEdge: edges,
-				PrintFmt:  format,
-				Recursive: recursive,
-			}
-
-			for _, o := range objs {
-				if _, err := rw.WriteRefs(o); err != nil {
-					out <- &RefWrapper{Err: err.Error()}
+			for r := range refs {
+				if r.Err != nil {
+					out <- &RefWrapper{Err: r.Err.Error()}
+					return
+				}
+				select {
+				case out <- &RefWrapper{Ref: corerepo.FormatRef(r, opts)}:
+				case <-ctx.Done():
 					return
 				}
 			}
 		}()
 	},
 	Marshalers: cmds.MarshalerMap{
-		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-			outChan, ok := res.Output().(<-chan interface{})
-			if !ok {
-				return nil, u.ErrCast()
-			}
-
-			marshal := func(v interface{}) (io.Reader, error) {
-				obj, ok := v.(*RefWrapper)
-				if !ok {
-					fmt.Println("%#v", v)
-					return nil, u.ErrCast()
-				}
-
-				if obj.Err != "" {
-					return nil, errors.New(obj.Err)
-				}
-
-				return strings.NewReader(obj.Ref + "\n"), nil
-			}
-
-			return &cmds.ChannelMarshaler{
-				Channel:   outChan,
-				Marshaler: marshal,
-				Res:       res,
-			}, nil
-		},
+		cmds.Text: refsTextMarshaler,
 	},
 	Type: RefWrapper{},
 }
@@ -152,7 +147,8 @@ var RefsLocalCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Lists all local references",
 		ShortDescription: `
-Displays the hashes of all local objects.
+Displays the hashes of all local objects, streamed as they are read from
+the blockstore.
 `,
 	},
 
@@ -164,166 +160,60 @@ Displays the hashes of all local objects.
 			return
 		}
 
-		// todo: make async
-		allKeys, err := n.Blockstore.AllKeysChan(ctx)
+		keys, err := corerepo.LocalRefs(ctx, n)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		piper, pipew := io.Pipe()
+		out := make(chan interface{})
+		res.SetOutput((<-chan interface{})(out))
 
 		go func() {
-			defer pipew.Close()
+			defer close(out)
 
-			for k := range allKeys {
-				s := k.Pretty() + "\n"
-				if _, err := pipew.Write([]byte(s)); err != nil {
-					log.Error("pipe write error: ", err)
+			for k := range keys {
+				select {
+				case out <- &RefWrapper{Ref: k.Pretty()}:
+				case <-ctx.Done():
 					return
 				}
 			}
 		}()
-
-		res.SetOutput(piper)
 	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: refsTextMarshaler,
+	},
+	Type: RefWrapper{},
 }
 
-func objectsForPaths(ctx context.Context, n *core.IpfsNode, paths []string) ([]*dag.Node, error) {
-	objects := make([]*dag.Node, len(paths))
-	for i, p := range paths {
-		o, err := core.Resolve(ctx, n, path.Path(p))
-		if err != nil {
-			return nil, err
-		}
-		objects[i] = o
-	}
-	return objects, nil
-}
-
-type RefWrapper struct {
-	Ref string
-	Err string
-}
-
-type RefWriter struct {
-	out chan interface{}
-	DAG dag.DAGService
-	Ctx context.Context
-
-	Unique    bool
-	Recursive bool
-	PrintEdge bool
-	PrintFmt  string
-
-	seen map[key.Key]struct{}
-}
-
-// WriteRefs writes refs of the given object to the underlying writer.
-func (rw *RefWriter) WriteRefs(n *dag.Node) (int, error) {
-	if rw.Recursive {
-		return rw.writeRefsRecursive(n)
-	}
-	return rw.writeRefsSingle(n)
-}
-
-func (rw *RefWriter) writeRefsRecursive(n *dag.Node) (int, error) {
-	nkey, err := n.Key()
-	if err != nil {
-		return 0, err
-	}
-
-	var count int
-	for i, ng := range rw.DAG.GetDAG(rw.Ctx, n) {
-		lk := key.Key(n.Links[i].Hash)
-		if rw.skip(lk) {
-			continue
-		}
-
-		if err := rw.WriteEdge(nkey, lk, n.Links[i].Name); err != nil {
-			return count, err
-		}
-
-		nd, err := ng.Get(rw.Ctx)
-		if err != nil {
-			return count, err
-		}
-
-		c, err := rw.writeRefsRecursive(nd)
-		count += c
-		if err != nil {
-			return count, err
-		}
-	}
-	return count, nil
-}
-
-func (rw *RefWriter) writeRefsSingle(n *dag.Node) (int, error) {
-	nkey, err := n.Key()
-	if err != nil {
-		return 0, err
-	}
-
-	if rw.skip(nkey) {
-		return 0, nil
+func refsTextMarshaler(res cmds.Response) (io.Reader, error) {
+	outChan, ok := res.Output().(<-chan interface{})
+	if !ok {
+		return nil, u.ErrCast()
 	}
 
-	count := 0
-	for _, l := range n.Links {
-		lk := key.Key(l.Hash)
-
-		if rw.skip(lk) {
-			continue
+	marshal := func(v interface{}) (io.Reader, error) {
+		obj, ok := v.(*RefWrapper)
+		if !ok {
+			return nil, u.ErrCast()
 		}
 
-		if err := rw.WriteEdge(nkey, lk, l.Name); err != nil {
-			return count, err
+		if obj.Err != "" {
+			return nil, errors.New(obj.Err)
 		}
-		count++
-	}
-	return count, nil
-}
-
-// skip returns whether to skip a key
-func (rw *RefWriter) skip(k key.Key) bool {
-	if !rw.Unique {
-		return false
-	}
 
-	if rw.seen == nil {
-		rw.seen = make(map[key.Key]struct{})
+		return strings.NewReader(obj.Ref + "\n"), nil
 	}
 
-	_, found := rw.seen[k]
-	if !found {
-		rw.seen[k] = struct{}{}
-	}
-	return found
+	return &cmds.ChannelMarshaler{
+		Channel:   outChan,
+		Marshaler: marshal,
+		Res:       res,
+	}, nil
 }
 
-// Write one edge
-func (rw *RefWriter) WriteEdge(from, to key.Key, linkname string) error {
-	if rw.Ctx != nil {
-		select {
-		case <-rw.Ctx.Done(): // just in case.
-			return rw.Ctx.Err()
-		default:
-		}
-	}
-
-	var s string
-	switch {
-	case rw.PrintFmt != "":
-		s = rw.PrintFmt
-		s = strings.Replace(s, "<src>", from.Pretty(), -1)
-		s = strings.Replace(s, "<dst>", to.Pretty(), -1)
-		s = strings.Replace(s, "<linkname>", linkname, -1)
-	case rw.PrintEdge:
-		s = from.Pretty() + " -> " + to.Pretty()
-	default:
-		s += to.Pretty()
-	}
-
-	rw.out <- &RefWrapper{Ref: s}
-	return nil
+type RefWrapper struct {
+	Ref string
+	Err string
 }
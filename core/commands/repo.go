@@ -2,11 +2,14 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -19,7 +22,11 @@ var RepoCmd = &cmds.Command{
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"gc": repoGcCmd,
+		"gc":      repoGcCmd,
+		"stat":    repoStatCmd,
+		"migrate": RepoMigrateCmd,
+		"export":  RepoExportCmd,
+		"import":  RepoImportCmd,
 	},
 }
 
@@ -95,3 +102,219 @@ order to reclaim hard disk space.
 		},
 	},
 }
+
+type RepoStat struct {
+	NumBlocks     uint64
+	RepoSize      uint64
+	SizeHistogram []uint64 `json:",omitempty"`
+	Counting      bool     `json:",omitempty"`
+}
+
+var repoStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the number and total size of stored blocks",
+		ShortDescription: `
+'ipfs repo stat' shows the number of blocks stored in the repo, and their
+total size in bytes. The counts are kept up to date as blocks are added
+and removed, so the repo is not walked. After the daemon was not shut down
+cleanly, the blocks are counted again in the background, and the counts are
+too low until done.
+
+With --histogram, the blocks are also counted by size, in power of two
+buckets: each line shows the upper bound of a bucket, and the number of
+blocks in it.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.BoolOption("histogram", "Also show the blocks by size"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.InvocContext().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if n.BlockStats == nil {
+			res.SetError(errors.New("block stats are not available"), cmds.ErrNormal)
+			return
+		}
+
+		hist, _, _ := req.Option("histogram").Bool()
+
+		st := n.BlockStats.Stat()
+		out := &RepoStat{
+			NumBlocks: st.NumBlocks,
+			RepoSize:  st.TotalSize,
+			Counting:  st.Counting,
+		}
+		if hist {
+			out.SizeHistogram = st.SizeHistogram
+		}
+		res.SetOutput(out)
+	},
+	Type: RepoStat{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			st, ok := res.Output().(*RepoStat)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			fmt.Fprintf(buf, "NumBlocks: %d\n", st.NumBlocks)
+			fmt.Fprintf(buf, "RepoSize: %d\n", st.RepoSize)
+			if st.Counting {
+				fmt.Fprintln(buf, "(still counting blocks)")
+			}
+			for i, c := range st.SizeHistogram {
+				if c == 0 {
+					continue
+				}
+				bound := fmt.Sprintf("<= %d", uint64(1)<<uint(i))
+				if i == len(st.SizeHistogram)-1 {
+					bound = fmt.Sprintf("> %d", uint64(1)<<uint(i-1))
+				}
+				fmt.Fprintf(buf, "  %-12s %d\n", bound, c)
+			}
+			return buf, nil
+		},
+	},
+}
+
+type RepoMigrations struct {
+	Migrations []string
+}
+
+// RepoMigrateCmd is exported so it can be marked as running without the
+// repo open.
+var RepoMigrateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Upgrade or downgrade the repo to another version",
+		ShortDescription: `
+'ipfs repo migrate' changes the layout of the repo to the one used by the
+given version, the current one by default. Older repos are upgraded
+automatically when opened, so this is mostly useful to see what would
+change, with --dry-run, or to go back to an older version.
+
+The repo, apart from its blocks, is backed up inside it before migrating,
+unless --backup=false is given.
+`,
+	},
+
+	Options: []cmds.Option{
+		cmds.StringOption("to", "The repo version to migrate to"),
+		cmds.BoolOption("dry-run", "Only show the migrations that would run"),
+		cmds.BoolOption("backup", "Back up the repo before migrating (default: true)"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		to := fsrepo.RepoVersion
+		if s, found, err := req.Option("to").String(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		} else if found {
+			to = s
+		}
+		v, err := strconv.Atoi(to)
+		if err != nil {
+			res.SetError(fmt.Errorf("invalid repo version %q", to), cmds.ErrClient)
+			return
+		}
+
+		dryRun, _, err := req.Option("dry-run").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		backup, found, err := req.Option("backup").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			backup = true
+		}
+
+		steps, err := fsrepo.Migrate(req.InvocContext().ConfigRoot, v, fsrepo.MigrateOptions{
+			DryRun: dryRun,
+			Backup: backup,
+		})
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &RepoMigrations{Migrations: make([]string, 0, len(steps))}
+		for _, s := range steps {
+			out.Migrations = append(out.Migrations, s.String())
+		}
+		res.SetOutput(out)
+	},
+	Type: RepoMigrations{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out, ok := res.Output().(*RepoMigrations)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			buf := new(bytes.Buffer)
+			if len(out.Migrations) == 0 {
+				fmt.Fprintln(buf, "repo is already at the requested version")
+			}
+			for _, m := range out.Migrations {
+				fmt.Fprintln(buf, m)
+			}
+			return buf, nil
+		},
+	},
+}
+
+// RepoExportCmd is exported so it can be marked as taking the repo lock
+// itself.
+var RepoExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write an archive of the whole repo",
+		ShortDescription: `
+'ipfs repo export' writes an archive of the repo to stdout: its config,
+including the private key, its pins, and all its blocks. 'ipfs repo import'
+builds a repo from the archive, to move the node to another machine or to
+restore it from a backup. The daemon must not be running.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(fsrepo.Export(req.InvocContext().ConfigRoot, pw))
+		}()
+		res.SetOutput(pr)
+	},
+}
+
+// RepoImportCmd is exported so it can be marked as not needing a repo.
+var RepoImportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Build the repo from an archive written by 'ipfs repo export'",
+		ShortDescription: `
+'ipfs repo import' creates the repo from an archive written by
+'ipfs repo export'. There must not be a repo already.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.FileArg("archive", true, false, "The archive to import").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		if err := fsrepo.Import(req.InvocContext().ConfigRoot, file); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+		}
+	},
+}
@@ -6,6 +6,7 @@ import (
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
 	u "github.com/ipfs/go-ipfs/util"
 )
@@ -59,6 +60,7 @@ Resolve the value of an IPFS DAG path:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption("recursive", "r", "Resolve until the result is an IPFS name"),
+		cmds.BoolOption("nocache", "Do not use cached IPNS entries"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
@@ -76,40 +78,28 @@ Resolve the value of an IPFS DAG path:
 			}
 		}
 
+		ctx := req.Context()
+		nocache, _, _ := req.Option("nocache").Bool()
+		if nocache {
+			ctx = namesys.WithoutCache(ctx)
+		}
+
 		name := req.Arguments()[0]
 		recursive, _, _ := req.Option("recursive").Bool()
 
-		// the case when ipns is resolved step by step
-		if strings.HasPrefix(name, "/ipns/") && !recursive {
-			p, err := n.Namesys.ResolveN(req.Context(), name, 1)
-			if err != nil {
-				res.SetError(err, cmds.ErrNormal)
-				return
-			}
-			res.SetOutput(&ResolvedPath{p})
-			return
-		}
-
-		// else, ipfs path or ipns with recursive flag
 		p, err := path.ParsePath(name)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		node, err := core.Resolve(req.Context(), n, p)
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
-		}
-
-		key, err := node.Key()
+		output, err := core.ResolvePath(ctx, n, p, recursive)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
 
-		res.SetOutput(&ResolvedPath{path.FromKey(key)})
+		res.SetOutput(&ResolvedPath{output})
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
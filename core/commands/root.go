@@ -48,9 +48,11 @@ ADVANCED COMMANDS
     mount         Mount an ipfs read-only mountpoint
     resolve       Resolve any type of name
     name          Publish or resolve IPNS names
+    key           Create and manage the keypairs of the node
     dns           Resolve DNS links
     pin           Pin objects to local storage
     repo gc       Garbage collect unpinned objects
+    filestore     Maintain data referenced outside the repo
 
 NETWORK COMMANDS
 
@@ -94,8 +96,11 @@ var rootSubcommands = map[string]*cmds.Command{
 	"dht":       DhtCmd,
 	"diag":      DiagCmd,
 	"dns":       DNSCmd,
+	"files":     FilesCmd,
+	"filestore": FilestoreCmd,
 	"get":       GetCmd,
 	"id":        IDCmd,
+	"key":       KeyCmd,
 	"log":       LogCmd,
 	"ls":        LsCmd,
 	"mount":     MountCmd,
@@ -144,6 +149,7 @@ var rootROSubcommands = map[string]*cmds.Command{
 			"links": objectLinksCmd,
 			"get":   objectGetCmd,
 			"stat":  objectStatCmd,
+			"diff":  objectDiffCmd,
 		},
 	},
 	"refs": RefsROCmd,
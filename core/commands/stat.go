@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"text/tabwriter"
 	"time"
 
 	humanize "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/dustin/go-humanize"
@@ -27,14 +29,28 @@ var StatsCmd = &cmds.Command{
 	},
 }
 
+// BandwidthStats are the bandwidth totals, or those of a peer or protocol,
+// and their breakdown by peer or protocol when asked for.
+type BandwidthStats struct {
+	metrics.Stats
+
+	Peers     map[string]metrics.Stats `json:",omitempty"`
+	Protocols map[string]metrics.Stats `json:",omitempty"`
+}
+
 var statBwCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline:          "Print ipfs bandwidth information",
-		ShortDescription: ``,
+		Tagline: "Print ipfs bandwidth information",
+		ShortDescription: `
+Prints the bytes sent and received by the node, and their rates, in total
+or for a given peer or protocol. '--by=peer' and '--by=proto' list them
+for every peer or protocol traffic went to, to see what it is made of.
+`,
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("peer", "p", "specify a peer to print bandwidth for"),
 		cmds.StringOption("proto", "t", "specify a protocol to print bandwidth for"),
+		cmds.StringOption("by", "b", "list the bandwidth by peer or by proto"),
 		cmds.BoolOption("poll", "print bandwidth at an interval"),
 		cmds.StringOption("interval", "i", "time interval to wait between updating output"),
 	},
@@ -68,6 +84,16 @@ var statBwCmd = &cmds.Command{
 			return
 		}
 
+		by, _, err := req.Option("by").String()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if by != "" && by != "peer" && by != "proto" {
+			res.SetError(fmt.Errorf("cannot list the bandwidth by %q, only by peer or proto", by), cmds.ErrClient)
+			return
+		}
+
 		var pid peer.ID
 		if pfound {
 			checkpid, err := peer.IDB58Decode(pstr)
@@ -105,17 +131,28 @@ var statBwCmd = &cmds.Command{
 		go func() {
 			defer close(out)
 			for {
+				var stats BandwidthStats
 				if pfound {
-					stats := nd.Reporter.GetBandwidthForPeer(pid)
-					out <- &stats
+					stats.Stats = nd.Reporter.GetBandwidthForPeer(pid)
 				} else if tfound {
 					protoId := protocol.ID(tstr)
-					stats := nd.Reporter.GetBandwidthForProtocol(protoId)
-					out <- &stats
+					stats.Stats = nd.Reporter.GetBandwidthForProtocol(protoId)
 				} else {
-					totals := nd.Reporter.GetBandwidthTotals()
-					out <- &totals
+					stats.Stats = nd.Reporter.GetBandwidthTotals()
 				}
+				switch by {
+				case "peer":
+					stats.Peers = make(map[string]metrics.Stats)
+					for p, s := range nd.Reporter.GetBandwidthByPeer() {
+						stats.Peers[p.Pretty()] = s
+					}
+				case "proto":
+					stats.Protocols = make(map[string]metrics.Stats)
+					for p, s := range nd.Reporter.GetBandwidthByProtocol() {
+						stats.Protocols[string(p)] = s
+					}
+				}
+				out <- &stats
 				if !doPoll {
 					return
 				}
@@ -127,7 +164,7 @@ var statBwCmd = &cmds.Command{
 			}
 		}()
 	},
-	Type: metrics.Stats{},
+	Type: BandwidthStats{},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
 			outCh, ok := res.Output().(<-chan interface{})
@@ -142,13 +179,18 @@ var statBwCmd = &cmds.Command{
 
 			first := true
 			marshal := func(v interface{}) (io.Reader, error) {
-				bs, ok := v.(*metrics.Stats)
+				bs, ok := v.(*BandwidthStats)
 				if !ok {
 					return nil, u.ErrCast()
 				}
 				out := new(bytes.Buffer)
-				if !polling {
-					printStats(out, bs)
+				if bs.Peers != nil || bs.Protocols != nil {
+					if polling {
+						fmt.Fprintln(out)
+					}
+					printStatsBy(out, bs)
+				} else if !polling {
+					printStats(out, &bs.Stats)
 				} else {
 					if first {
 						fmt.Fprintln(out, "Total Up\t Total Down\t Rate Up\t Rate Down")
@@ -180,3 +222,48 @@ func printStats(out io.Writer, bs *metrics.Stats) {
 	fmt.Fprintf(out, "RateIn: %s/s\n", humanize.Bytes(uint64(bs.RateIn)))
 	fmt.Fprintf(out, "RateOut: %s/s\n", humanize.Bytes(uint64(bs.RateOut)))
 }
+
+// printStatsBy prints the stats of every peer or protocol, the busiest
+// first.
+func printStatsBy(out io.Writer, bs *BandwidthStats) {
+	by, title := bs.Peers, "Peer"
+	if bs.Protocols != nil {
+		by, title = bs.Protocols, "Protocol"
+	}
+
+	names := make([]string, 0, len(by))
+	for name := range by {
+		names = append(names, name)
+	}
+	sort.Sort(byTotal{names, by})
+
+	w := tabwriter.NewWriter(out, 4, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tTotal Up\tTotal Down\tRate Up\tRate Down\n", title)
+	for _, name := range names {
+		s := by[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s/s\t%s/s\n", name,
+			humanize.Bytes(uint64(s.TotalOut)), humanize.Bytes(uint64(s.TotalIn)),
+			humanize.Bytes(uint64(s.RateOut)), humanize.Bytes(uint64(s.RateIn)))
+	}
+	fmt.Fprintf(w, "Total\t%s\t%s\t%s/s\t%s/s\n",
+		humanize.Bytes(uint64(bs.TotalOut)), humanize.Bytes(uint64(bs.TotalIn)),
+		humanize.Bytes(uint64(bs.RateOut)), humanize.Bytes(uint64(bs.RateIn)))
+	w.Flush()
+}
+
+// byTotal sorts names by decreasing traffic, then by name.
+type byTotal struct {
+	names []string
+	stats map[string]metrics.Stats
+}
+
+func (b byTotal) Len() int      { return len(b.names) }
+func (b byTotal) Swap(i, j int) { b.names[i], b.names[j] = b.names[j], b.names[i] }
+func (b byTotal) Less(i, j int) bool {
+	si, sj := b.stats[b.names[i]], b.stats[b.names[j]]
+	ti, tj := si.TotalIn+si.TotalOut, sj.TotalIn+sj.TotalOut
+	if ti != tj {
+		return ti > tj
+	}
+	return b.names[i] < b.names[j]
+}
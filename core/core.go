@@ -16,6 +16,7 @@ import (
 	"net"
 	"time"
 
+	humanize "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/dustin/go-humanize"
 	b58 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
@@ -32,37 +33,54 @@ import (
 	swarm "github.com/ipfs/go-ipfs/p2p/net/swarm"
 	addrutil "github.com/ipfs/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	dialback "github.com/ipfs/go-ipfs/p2p/protocol/dialback"
 	ping "github.com/ipfs/go-ipfs/p2p/protocol/ping"
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 
 	routing "github.com/ipfs/go-ipfs/routing"
+	compose "github.com/ipfs/go-ipfs/routing/compose"
 	dht "github.com/ipfs/go-ipfs/routing/dht"
 	nilrouting "github.com/ipfs/go-ipfs/routing/none"
 	offroute "github.com/ipfs/go-ipfs/routing/offline"
 
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
 	bserv "github.com/ipfs/go-ipfs/blockservice"
 	exchange "github.com/ipfs/go-ipfs/exchange"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	decision "github.com/ipfs/go-ipfs/exchange/bitswap/decision"
 	bsnet "github.com/ipfs/go-ipfs/exchange/bitswap/network"
+	deferred "github.com/ipfs/go-ipfs/exchange/deferred"
+	httpexchange "github.com/ipfs/go-ipfs/exchange/httpexchange"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	provider "github.com/ipfs/go-ipfs/exchange/provider"
 	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
+	filestore "github.com/ipfs/go-ipfs/filestore"
 
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	ipnsfs "github.com/ipfs/go-ipfs/ipnsfs"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ipnsrp "github.com/ipfs/go-ipfs/namesys/republisher"
 	path "github.com/ipfs/go-ipfs/path"
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
 const IpnsValidatorTag = "ipns"
 const kSizeBlockstoreWriteCache = 100
+const kSizeDagNodeCache = 256
 const kReprovideFrequency = time.Hour * 12
+const kPinSweepFrequency = time.Minute
+const kPrefetchWorkers = 8
+const kPrefetchQueueSize = 256
 const discoveryConnTimeout = time.Second * 30
+const kReachabilityFrequency = time.Minute * 30
 
 var log = logging.Logger("core")
 
@@ -89,11 +107,14 @@ type IpfsNode struct {
 	PrivateKey ic.PrivKey // the local node's private Key
 
 	// Services
-	Peerstore  peer.Peerstore       // storage for other Peer instances
-	Blockstore bstore.Blockstore    // the block store (lower level)
-	Blocks     *bserv.BlockService  // the block service, get/add blocks.
-	DAG        merkledag.DAGService // the merkle dag service, get/add objects.
-	Resolver   *path.Resolver       // the path resolution system
+	Peerstore  peer.Peerstore        // storage for other Peer instances
+	Blockstore bstore.GCBlockstore   // the block store (lower level)
+	Filestore  *filestore.Filestore  // references to file data outside the repo
+	BlockStats bstore.StatBlockstore // counts of the blocks in the repo
+	Blocks     *bserv.BlockService   // the block service, get/add blocks.
+	Prefetcher *bserv.Prefetcher     // fetches blocks in the background
+	DAG        merkledag.DAGService  // the merkle dag service, get/add objects.
+	Resolver   *path.Resolver        // the path resolution system
 	Reporter   metrics.Reporter
 	Discovery  discovery.Service
 
@@ -101,15 +122,21 @@ type IpfsNode struct {
 	PeerHost     p2phost.Host        // the network host (server+client)
 	Bootstrapper io.Closer           // the periodic bootstrapper
 	Routing      routing.IpfsRouting // the routing system. recommend ipfs-dht
+	DHT          *dht.IpfsDHT        // the DHT of the routing system, if it has one
+	Provider     *provider.Filter    // announces the blocks chosen by the provider strategy
 	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
 	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
 	Diagnostics  *diag.Diagnostics   // the diagnostics service
 	Ping         *ping.PingService
-	Reprovider   *rp.Reprovider // the value reprovider system
+	Dialback     *dialback.Service // checks whether peers can dial the node
+	Reprovider   *rp.Reprovider    // the value reprovider system
 	IpnsRepub    *ipnsrp.Republisher
 
 	IpnsFs *ipnsfs.Filesystem
 
+	// FilesRoot is the root of the mutable tree of the files commands
+	FilesRoot *mfs.Root
+
 	proc goprocess.Process
 	ctx  context.Context
 
@@ -120,8 +147,9 @@ type IpfsNode struct {
 // perhaps be moved to the daemon or mount. It's here because
 // it needs to be accessible across daemon requests.
 type Mounts struct {
-	Ipfs mount.Mount
-	Ipns mount.Mount
+	Ipfs  mount.Mount
+	Ipns  mount.Mount
+	Files mount.Mount
 }
 
 func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption) error {
@@ -166,9 +194,6 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
-	n.Reprovider = rp.NewReprovider(n.Routing, n.Blockstore)
-	go n.Reprovider.ProvideEvery(ctx, kReprovideFrequency)
-
 	// setup local discovery
 	if do != nil {
 		service, err := do(n.PeerHost)
@@ -180,6 +205,8 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		}
 	}
 
+	n.watchConfig()
+
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
@@ -210,6 +237,7 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	// setup diagnostics service
 	n.Diagnostics = diag.NewDiagnostics(n.Identity, host)
 	n.Ping = ping.NewPingService(host)
+	n.Dialback = dialback.NewService(host)
 
 	// setup routing service
 	r, err := routingOption(ctx, host, n.Repo.Datastore())
@@ -217,17 +245,24 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 		return err
 	}
 	n.Routing = r
+	n.DHT = findDHT(r)
+	if err := n.setupDHTMode(); err != nil {
+		return err
+	}
+	n.Provider = provider.NewFilter(r)
 
 	// Wrap standard peer host with routing system to allow unknown peer lookups
 	n.PeerHost = rhost.Wrap(host, n.Routing)
 
 	// setup exchange service
-	const alwaysSendToPeer = true // use YesManStrategy
-	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Routing)
-	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
+	if err := n.setupExchange(ctx); err != nil {
+		return err
+	}
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore())
+	if err := n.setupNamesys(); err != nil {
+		return err
+	}
 
 	// setup ipns republishing
 	err = n.setupIpnsRepublisher()
@@ -238,13 +273,127 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	return nil
 }
 
+// setupDHTMode makes the DHT a server or a client of the network, as
+// Routing.DHTMode says.
+func (n *IpfsNode) setupDHTMode() error {
+	if n.DHT == nil {
+		return nil
+	}
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	switch cfg.Routing.DHTMode {
+	case "client":
+		n.DHT.SetMode(dht.ModeClient)
+	case "auto":
+		n.DHT.AutoMode(n.Dialback.Reachable, kReachabilityFrequency)
+	}
+	return nil
+}
+
+func (n *IpfsNode) setupExchange(ctx context.Context) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	hcfg := cfg.HTTPRetrieval
+	if hcfg.Only && len(hcfg.URLs) > 0 {
+		n.Exchange = httpexchange.New(n.Blockstore, hcfg.URLs)
+		return nil
+	}
+
+	const alwaysSendToPeer = true // use YesManStrategy
+	bitswapNetwork := bsnet.NewFromIpfsHost(n.PeerHost, n.Provider)
+	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
+	if err := n.setupBitswap(); err != nil {
+		return err
+	}
+
+	if len(hcfg.URLs) > 0 {
+		n.Exchange = httpexchange.NewLayered(n.Exchange, httpexchange.New(n.Blockstore, hcfg.URLs))
+	}
+	return nil
+}
+
+// drainDeferredWants fetches the blocks queued while the node was offline,
+// for as long as the node runs.
+func (n *IpfsNode) drainDeferredWants(ctx context.Context) {
+	q := deferred.NewQueue(n.Repo.Datastore())
+	if err := q.Drain(ctx, n.Exchange); err != nil && err != ctx.Err() {
+		log.Errorf("fetching deferred blocks: %s", err)
+	}
+}
+
+// Bitswap returns the node's bitswap exchange, or nil if it isn't using one.
+func (n *IpfsNode) Bitswap() *bitswap.Bitswap {
+	ex := n.Exchange
+	if l, ok := ex.(*httpexchange.Layered); ok {
+		ex = l.Primary
+	}
+	bs, _ := ex.(*bitswap.Bitswap)
+	return bs
+}
+
+func (n *IpfsNode) setupBitswap() error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	bs := n.Bitswap()
+	if bs == nil {
+		return nil
+	}
+
+	limits, err := ParseBitswapLimits(cfg.Bitswap)
+	if err != nil {
+		return err
+	}
+	bs.SetRateLimits(limits)
+
+	strategy, err := decision.StrategyByName(cfg.Bitswap.Strategy)
+	if err != nil {
+		return fmt.Errorf("failure to parse config setting Bitswap.Strategy: %s", err)
+	}
+	bs.SetStrategy(strategy)
+	return nil
+}
+
+// ParseBitswapLimits parses the rate limits in the bitswap config.
+func ParseBitswapLimits(cfg config.Bitswap) (bitswap.RateLimits, error) {
+	var limits bitswap.RateLimits
+	for _, l := range []struct {
+		name string
+		val  string
+		dst  *uint64
+	}{
+		{"UploadLimit", cfg.UploadLimit, &limits.Upload},
+		{"DownloadLimit", cfg.DownloadLimit, &limits.Download},
+		{"PeerUploadLimit", cfg.PeerUploadLimit, &limits.PeerUpload},
+		{"PeerDownloadLimit", cfg.PeerDownloadLimit, &limits.PeerDownload},
+	} {
+		if l.val == "" {
+			continue
+		}
+		v, err := humanize.ParseBytes(l.val)
+		if err != nil {
+			return limits, fmt.Errorf("failure to parse config setting Bitswap.%s: %s", l.name, err)
+		}
+		*l.dst = v
+	}
+	return limits, nil
+}
+
 func (n *IpfsNode) setupIpnsRepublisher() error {
 	cfg, err := n.Repo.Config()
 	if err != nil {
 		return err
 	}
 
-	n.IpnsRepub = ipnsrp.NewRepublisher(n.Routing, n.Repo.Datastore(), n.Peerstore)
+	n.IpnsRepub = ipnsrp.NewRepublisher(n.Routing, n.Repo.Datastore(), n.Peerstore, n.Repo.Keystore())
 	n.IpnsRepub.AddName(n.Identity)
 
 	if cfg.Ipns.RepublishPeriod != "" {
@@ -261,7 +410,7 @@ func (n *IpfsNode) setupIpnsRepublisher() error {
 	}
 
 	if cfg.Ipns.RecordLifetime != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RepublishPeriod)
+		d, err := time.ParseDuration(cfg.Ipns.RecordLifetime)
 		if err != nil {
 			return fmt.Errorf("failure to parse config setting IPNS.RecordLifetime: %s", err)
 		}
@@ -269,11 +418,80 @@ func (n *IpfsNode) setupIpnsRepublisher() error {
 		n.IpnsRepub.RecordLifetime = d
 	}
 
+	// announce the names published while offline once connected
+	n.IpnsRepub.WatchNetwork(n.PeerHost.Network())
 	n.Process().Go(n.IpnsRepub.Run)
 
 	return nil
 }
 
+// setupReprovider announces the blocks chosen by the reprovider strategy
+// again every Reprovider.Interval. It needs the pinner, so runs once the
+// node is set up.
+func (n *IpfsNode) setupReprovider(ctx context.Context) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+
+	strategy := cfg.Reprovider.Strategy
+	if strategy == "" {
+		strategy = cfg.Bitswap.ProvideStrategy
+	}
+	kp, err := rp.KeyProviderByName(strategy, n.Pinning, n.Blockstore)
+	if err != nil {
+		return err
+	}
+
+	interval := kReprovideFrequency
+	if cfg.Reprovider.Interval != "" {
+		interval, err = time.ParseDuration(cfg.Reprovider.Interval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Reprovider.Interval: %s", err)
+		}
+	}
+
+	n.Reprovider = rp.NewReprovider(n.Routing, kp)
+	go n.Reprovider.ProvideEvery(ctx, interval)
+	return nil
+}
+
+// filesRootKey is the datastore key holding the hash of the files root
+var filesRootKey = ds.NewKey("/local/filesroot")
+
+// setupFilesRoot loads the root of the files commands, starting from an
+// empty directory, and stores its hash again whenever it changes.
+func (n *IpfsNode) setupFilesRoot(ctx context.Context) error {
+	dstore := n.Repo.Datastore()
+
+	var nd *merkledag.Node
+	val, err := dstore.Get(filesRootKey)
+	switch {
+	case err == ds.ErrNotFound:
+		nd = &merkledag.Node{Data: ft.FolderPBData()}
+		if _, err := n.DAG.Add(nd); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		b, ok := val.([]byte)
+		if !ok {
+			return fmt.Errorf("invalid files root in the datastore")
+		}
+		nd, err = n.DAG.Get(ctx, key.Key(b))
+		if err != nil {
+			return fmt.Errorf("failure to load the files root %s: %s", key.Key(b), err)
+		}
+	}
+
+	publish := func(ctx context.Context, k key.Key) error {
+		return dstore.Put(filesRootKey, []byte(k))
+	}
+	n.FilesRoot, err = mfs.NewRoot(ctx, n.DAG, n.Pinning, nd, publish)
+	return err
+}
+
 // Process returns the Process object
 func (n *IpfsNode) Process() goprocess.Process {
 	return n.proc
@@ -298,9 +516,7 @@ func (n *IpfsNode) teardown() error {
 	log.Debug("core is shutting down...")
 	// owned objects are closed in this teardown to ensure that they're closed
 	// regardless of which constructor was used to add them to the node.
-	closers := []io.Closer{
-		n.Repo,
-	}
+	var closers []io.Closer
 
 	if n.Exchange != nil {
 		closers = append(closers, n.Exchange)
@@ -312,12 +528,22 @@ func (n *IpfsNode) teardown() error {
 	if n.Mounts.Ipns != nil {
 		closers = append(closers, mount.Closer(n.Mounts.Ipns))
 	}
+	if n.Mounts.Files != nil {
+		closers = append(closers, mount.Closer(n.Mounts.Files))
+	}
 
 	// Filesystem needs to be closed before network, dht, and blockservice
 	// so it can use them as its shutting down
 	if n.IpnsFs != nil {
 		closers = append(closers, n.IpnsFs)
 	}
+	if n.FilesRoot != nil {
+		closers = append(closers, n.FilesRoot)
+	}
+
+	if n.Prefetcher != nil {
+		closers = append(closers, n.Prefetcher)
+	}
 
 	if n.Blocks != nil {
 		closers = append(closers, n.Blocks)
@@ -327,14 +553,21 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Bootstrapper)
 	}
 
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
-		closers = append(closers, dht.Process())
+	if n.DHT != nil {
+		closers = append(closers, n.DHT.Process())
 	}
 
 	if n.PeerHost != nil {
 		closers = append(closers, n.PeerHost)
 	}
 
+	// the block stats are saved to the repo once nothing else can write
+	// blocks, and the repo itself is closed last.
+	if n.BlockStats != nil {
+		closers = append(closers, n.BlockStats)
+	}
+	closers = append(closers, n.Repo)
+
 	var errs []error
 	for _, closer := range closers {
 		if err := closer.Close(); err != nil {
@@ -432,6 +665,12 @@ func (n *IpfsNode) LoadPrivateKey() error {
 	return nil
 }
 
+// Keystore returns the keys of the repo, along with the node's own key as
+// keystore.SelfKey once it is loaded.
+func (n *IpfsNode) Keystore() keystore.Keystore {
+	return keystore.WithSelf(n.Repo.Keystore(), n.PrivateKey)
+}
+
 func (n *IpfsNode) loadBootstrapPeers() ([]peer.PeerInfo, error) {
 	cfg, err := n.Repo.Config()
 	if err != nil {
@@ -449,20 +688,95 @@ func (n *IpfsNode) loadBootstrapPeers() ([]peer.PeerInfo, error) {
 // uses it to instantiate a routing system in offline mode.
 // This is primarily used for offline ipns modifications.
 func (n *IpfsNode) SetupOfflineRouting() error {
-	err := n.LoadPrivateKey()
+	if n.Routing != nil {
+		// online, or set up already
+		return nil
+	}
+
+	if n.PrivateKey == nil {
+		if err := n.LoadPrivateKey(); err != nil {
+			return err
+		}
+	}
+
+	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+
+	return n.setupNamesys()
+}
+
+// Offline returns a view of the node which does not use the network, as an
+// offline node: it only reads the blocks stored in the repo, and routes and
+// resolves names with the records stored there, so that looking for content
+// which isn't local fails right away. The view shares the repo and services
+// of n, and is not to be closed.
+func (n *IpfsNode) Offline() (*IpfsNode, error) {
+	view := *n
+	view.mode = offlineMode
+	view.Exchange = offline.Exchange(n.Blockstore)
+	view.Blocks = bserv.New(n.Blockstore, view.Exchange)
+	view.DAG = merkledag.NewDAGService(view.Blocks)
+	view.Resolver = &path.Resolver{DAG: view.DAG}
+	view.Routing = nil
+	view.Namesys = nil
+	if err := view.SetupOfflineRouting(); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// setupNamesys sets up the name system on top of n.Routing.
+func (n *IpfsNode) setupNamesys() error {
+	cfg, err := n.Repo.Config()
 	if err != nil {
 		return err
 	}
 
-	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
+	static, err := staticNames(&cfg.Ipns)
+	if err != nil {
+		return err
+	}
 
-	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore())
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore(), n.Keystore(), namesys.Opts{
+		CacheSize: cfg.Ipns.ResolveCacheSize,
+		LookupTXT: namesys.NewLookupTXT(cfg.DNS.Servers, cfg.DNS.HTTPSEndpoint),
+		Resolvers: cfg.Ipns.Resolvers,
+		Static:    static,
 
+		OfflinePublish: cfg.Ipns.OfflinePublish,
+	})
 	return nil
 }
 
+// staticNames returns the names of the hosts file, and the static names of
+// the config, which take precedence.
+func staticNames(cfg *config.Ipns) (map[string]path.Path, error) {
+	names := make(map[string]path.Path)
+	if cfg.HostsFile != "" {
+		fpath, err := u.TildeExpansion(cfg.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+		names, err = namesys.LoadHostsFile(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("failure to load config setting Ipns.HostsFile: %s", err)
+		}
+	}
+	for name, s := range cfg.StaticNames {
+		p, err := path.ParsePath(s)
+		if err != nil {
+			return nil, fmt.Errorf("failure to parse config setting Ipns.StaticNames.%s: %s", name, err)
+		}
+		names[name] = p
+	}
+	return names, nil
+}
+
 func loadPrivateKey(cfg *config.Identity, id peer.ID) (ic.PrivKey, error) {
-	sk, err := cfg.DecodePrivateKey("passphrase todo!")
+	// encrypted keys are decrypted by the repo, given the passphrase
+	sk, err := cfg.DecodePrivateKey("")
+	if err == config.ErrKeyEncrypted {
+		return nil, errors.New("the private key is encrypted and no passphrase was given to decrypt it")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -550,6 +864,22 @@ func constructDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.Threa
 	return dhtRouting, nil
 }
 
+// findDHT returns the DHT of the routing system r, which may be composed of
+// several, or nil.
+func findDHT(r routing.IpfsRouting) *dht.IpfsDHT {
+	switch r := r.(type) {
+	case *dht.IpfsDHT:
+		return r
+	case compose.Composition:
+		for _, sub := range r.Routers() {
+			if d := findDHT(sub); d != nil {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
 type RoutingOption func(context.Context, p2phost.Host, ds.ThreadSafeDatastore) (routing.IpfsRouting, error)
 
 type DiscoveryOption func(p2phost.Host) (discovery.Service, error)
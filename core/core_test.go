@@ -4,9 +4,8 @@ import (
 	"testing"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
-	"github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
-	"github.com/ipfs/go-ipfs/util/testutil"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
 )
 
 func TestInitialization(t *testing.T) {
@@ -44,10 +43,7 @@ func TestInitialization(t *testing.T) {
 	}
 
 	for i, c := range good {
-		r := &repo.Mock{
-			C: *c,
-			D: testutil.ThreadSafeCloserMapDatastore(),
-		}
+		r := mockrepo.New(*c)
 		n, err := NewNode(ctx, &BuildCfg{Repo: r})
 		if n == nil || err != nil {
 			t.Error("Should have constructed.", i, err)
@@ -55,10 +51,7 @@ func TestInitialization(t *testing.T) {
 	}
 
 	for i, c := range bad {
-		r := &repo.Mock{
-			C: *c,
-			D: testutil.ThreadSafeCloserMapDatastore(),
-		}
+		r := mockrepo.New(*c)
 		n, err := NewNode(ctx, &BuildCfg{Repo: r})
 		if n != nil || err == nil {
 			t.Error("Should have failed to construct.", i)
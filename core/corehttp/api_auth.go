@@ -0,0 +1,83 @@
+package corehttp
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// apiAuth lets the requests through to next only if they carry one of the
+// API.Authorizations of the config, and use a path it allows.
+type apiAuth struct {
+	next http.Handler
+
+	mu    sync.RWMutex
+	creds []apiCredential
+}
+
+type apiCredential struct {
+	name   string
+	header string // the expected Authorization header
+	config.APIAuthorization
+}
+
+func newAPIAuth(next http.Handler, auths map[string]config.APIAuthorization) (*apiAuth, error) {
+	a := &apiAuth{next: next}
+	if err := a.setAuthorizations(auths); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *apiAuth) setAuthorizations(auths map[string]config.APIAuthorization) error {
+	var creds []apiCredential
+	for name, auth := range auths {
+		h, err := config.AuthorizationHeader(auth.AuthSecret)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting API.Authorizations.%s.AuthSecret: %s", name, err)
+		}
+		creds = append(creds, apiCredential{name, h, auth})
+	}
+
+	a.mu.Lock()
+	a.creds = creds
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *apiAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	creds := a.creds
+	a.mu.RUnlock()
+
+	// preflight requests carry no credentials, and run no command
+	if len(creds) == 0 || r.Method == "OPTIONS" {
+		a.next.ServeHTTP(w, r)
+		return
+	}
+
+	var cred *apiCredential
+	h := []byte(r.Header.Get("Authorization"))
+	for i := range creds {
+		if subtle.ConstantTimeCompare(h, []byte(creds[i].header)) == 1 {
+			cred = &creds[i]
+			break
+		}
+	}
+	if cred == nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ipfs api"`)
+		http.Error(w, "401 - Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if hasPathPrefix(r.URL.Path, cred.DeniedPaths) ||
+		(len(cred.AllowedPaths) > 0 && !hasPathPrefix(r.URL.Path, cred.AllowedPaths)) {
+		log.Infof("API authorization %s denied access to %s", cred.name, r.URL.Path)
+		http.Error(w, "403 - Forbidden", http.StatusForbidden)
+		return
+	}
+	a.next.ServeHTTP(w, r)
+}
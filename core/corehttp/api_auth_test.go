@@ -0,0 +1,62 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestAPIAuth(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	a, err := newAPIAuth(next, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic, err := config.AuthorizationHeader("basic:alice:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(method, path, authorization string, status int) {
+		r, err := http.NewRequest(method, "http://localhost"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("%s %s with %q: got %d, expected %d", method, path, authorization, w.Code, status)
+		}
+	}
+
+	// open without authorizations
+	check("POST", "/api/v0/add", "", http.StatusOK)
+
+	err = a.setAuthorizations(map[string]config.APIAuthorization{
+		"admin":  {AuthSecret: "bearer:t0ken"},
+		"reader": {AuthSecret: "basic:alice:secret", AllowedPaths: []string{"/api/v0/cat", "/api/v0/name"}, DeniedPaths: []string{"/api/v0/name/publish"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check("POST", "/api/v0/add", "", http.StatusUnauthorized)
+	check("POST", "/api/v0/add", "Bearer wrong", http.StatusUnauthorized)
+	check("OPTIONS", "/api/v0/add", "", http.StatusOK)
+	check("POST", "/api/v0/add", "Bearer t0ken", http.StatusOK)
+	check("POST", "/api/v0/cat", basic, http.StatusOK)
+	check("POST", "/api/v0/name/resolve", basic, http.StatusOK)
+	check("POST", "/api/v0/name/publish", basic, http.StatusForbidden)
+	check("POST", "/api/v0/catalog", basic, http.StatusForbidden)
+	check("POST", "/api/v0/add", basic, http.StatusForbidden)
+
+	if err := a.setAuthorizations(map[string]config.APIAuthorization{"bad": {AuthSecret: "t0ken"}}); err == nil {
+		t.Fatal("expected an error for a secret without a scheme")
+	}
+	check("POST", "/api/v0/add", "Bearer t0ken", http.StatusOK)
+}
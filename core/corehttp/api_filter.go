@@ -0,0 +1,60 @@
+package corehttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
+	corecommands "github.com/ipfs/go-ipfs/core/commands"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// apiFilter lets through to next only the requests for the read-only
+// commands the Gateway.APICommands of the config allow, and none of them
+// with Gateway.NoAPI.
+type apiFilter struct {
+	next http.Handler
+
+	mu       sync.RWMutex
+	disabled bool
+	paths    []string // API paths of the allowed commands, all if empty
+}
+
+func newAPIFilter(next http.Handler, gw config.Gateway) (*apiFilter, error) {
+	f := &apiFilter{next: next}
+	if err := f.setConfig(gw); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *apiFilter) setConfig(gw config.Gateway) error {
+	var paths []string
+	for _, cmd := range gw.APICommands {
+		cmd = strings.Trim(cmd, "/")
+		if _, err := corecommands.RootRO.Get(strings.Split(cmd, "/")); err != nil {
+			return fmt.Errorf("failure to parse config setting Gateway.APICommands: %q is not a read-only command", cmd)
+		}
+		paths = append(paths, cmdsHttp.ApiPath+"/"+cmd)
+	}
+
+	f.mu.Lock()
+	f.disabled = gw.NoAPI
+	f.paths = paths
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *apiFilter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.RLock()
+	disabled, paths := f.disabled, f.paths
+	f.mu.RUnlock()
+
+	if disabled || (len(paths) > 0 && !hasPathPrefix(r.URL.Path, paths)) {
+		http.Error(w, "404 - the command is not served on the gateway", http.StatusNotFound)
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
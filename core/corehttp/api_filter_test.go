@@ -0,0 +1,52 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+func TestAPIFilter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	f, err := newAPIFilter(next, config.Gateway{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(path string, status int) {
+		r, err := http.NewRequest("GET", "http://localhost"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("%s: got %d, expected %d", path, w.Code, status)
+		}
+	}
+
+	// all the read-only commands by default
+	check("/api/v0/cat", http.StatusOK)
+	check("/api/v0/object/stat", http.StatusOK)
+
+	if err := f.setConfig(config.Gateway{APICommands: []string{"cat", "object/get"}}); err != nil {
+		t.Fatal(err)
+	}
+	check("/api/v0/cat", http.StatusOK)
+	check("/api/v0/object/get", http.StatusOK)
+	check("/api/v0/object/stat", http.StatusNotFound)
+	check("/api/v0/catalog", http.StatusNotFound)
+	check("/api/v0/ls", http.StatusNotFound)
+
+	if err := f.setConfig(config.Gateway{NoAPI: true}); err != nil {
+		t.Fatal(err)
+	}
+	check("/api/v0/cat", http.StatusNotFound)
+
+	if err := f.setConfig(config.Gateway{APICommands: []string{"add"}}); err == nil {
+		t.Fatal("expected an error for a command that is not read-only")
+	}
+	check("/api/v0/cat", http.StatusNotFound)
+}
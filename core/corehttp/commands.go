@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -11,6 +12,7 @@ import (
 	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
 	core "github.com/ipfs/go-ipfs/core"
 	corecommands "github.com/ipfs/go-ipfs/core/commands"
+	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
@@ -46,22 +48,32 @@ func addCORSFromEnv(c *cmdsHttp.ServerConfig) {
 	}
 }
 
+// addHeadersFromConfig applies the API.HTTPHeaders of the config. It runs
+// again whenever they change.
 func addHeadersFromConfig(c *cmdsHttp.ServerConfig, nc *config.Config) {
 	log.Info("Using API.HTTPHeaders:", nc.API.HTTPHeaders)
 
-	if acao := nc.API.HTTPHeaders[cmdsHttp.ACAOrigin]; acao != nil {
+	addCORSFromHeaders(c, nc.API.HTTPHeaders)
+	c.SetHeaders(nc.API.HTTPHeaders)
+}
+
+// addCORSFromHeaders sets the CORS options given as Access-Control-Allow-*
+// headers.
+func addCORSFromHeaders(c *cmdsHttp.ServerConfig, headers map[string][]string) {
+	if acao := headers[cmdsHttp.ACAOrigin]; acao != nil {
 		c.SetAllowedOrigins(acao...)
 	}
-	if acam := nc.API.HTTPHeaders[cmdsHttp.ACAMethods]; acam != nil {
+	if acam := headers[cmdsHttp.ACAMethods]; acam != nil {
 		c.SetAllowedMethods(acam...)
 	}
-	if acac := nc.API.HTTPHeaders[cmdsHttp.ACACredentials]; acac != nil {
+	if acah := headers[cmdsHttp.ACAHeaders]; acah != nil {
+		c.SetAllowedHeaders(acah...)
+	}
+	if acac := headers[cmdsHttp.ACACredentials]; acac != nil {
 		for _, v := range acac {
 			c.SetAllowCredentials(strings.ToLower(v) == "true")
 		}
 	}
-
-	c.Headers = nc.API.HTTPHeaders
 }
 
 func addCORSDefaults(c *cmdsHttp.ServerConfig) {
@@ -99,7 +111,9 @@ func patchCORSVars(c *cmdsHttp.ServerConfig, addr net.Addr) {
 	c.SetAllowedOrigins(origins...)
 }
 
-func commandsOption(cctx commands.Context, command *commands.Command) ServeOption {
+// commandsOption serves command under the API path. With authenticate,
+// the requests are checked against the API.Authorizations of the config.
+func commandsOption(cctx commands.Context, command *commands.Command, authenticate bool) ServeOption {
 	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 
 		cfg := cmdsHttp.NewServerConfig()
@@ -114,16 +128,79 @@ func commandsOption(cctx commands.Context, command *commands.Command) ServeOptio
 		addCORSDefaults(cfg)
 		patchCORSVars(cfg, l.Addr())
 
-		cmdHandler := cmdsHttp.NewHandler(cctx, command, cfg)
+		repo.NotifyConfig(n.Repo, func(old, updated *config.Config) {
+			if reflect.DeepEqual(old.API.HTTPHeaders, updated.API.HTTPHeaders) {
+				return
+			}
+			log.Info("API.HTTPHeaders changed, applying them")
+			// start over, so removed headers don't linger
+			cfg.SetAllowedOrigins()
+			cfg.SetAllowedMethods("GET", "POST", "PUT")
+			cfg.SetAllowedHeaders()
+			cfg.SetAllowCredentials(false)
+			addHeadersFromConfig(cfg, updated)
+			addCORSFromEnv(cfg)
+			addCORSDefaults(cfg)
+			patchCORSVars(cfg, l.Addr())
+		})
+
+		var cmdHandler http.Handler = cmdsHttp.NewHandler(cctx, command, cfg)
+		if authenticate {
+			auth, err := newAPIAuth(cmdHandler, rcfg.API.Authorizations)
+			if err != nil {
+				return nil, err
+			}
+			repo.NotifyConfig(n.Repo, func(old, updated *config.Config) {
+				if reflect.DeepEqual(old.API.Authorizations, updated.API.Authorizations) {
+					return
+				}
+				log.Info("API.Authorizations changed, applying them")
+				if err := auth.setAuthorizations(updated.API.Authorizations); err != nil {
+					log.Errorf("keeping the API authorizations: %s", err)
+				}
+			})
+			cmdHandler = auth
+		}
 		mux.Handle(cmdsHttp.ApiPath+"/", cmdHandler)
 		return mux, nil
 	}
 }
 
+// CommandsOption serves all the commands, to the holders of the
+// API.Authorizations of the config if it has any.
 func CommandsOption(cctx commands.Context) ServeOption {
-	return commandsOption(cctx, corecommands.Root)
+	return commandsOption(cctx, corecommands.Root, true)
 }
 
+// CommandsROOption serves the read-only commands to anyone, as the gateway
+// does, limited to those allowed by Gateway.APICommands, and to none with
+// Gateway.NoAPI.
 func CommandsROOption(cctx commands.Context) ServeOption {
-	return commandsOption(cctx, corecommands.RootRO)
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		cmdMux := http.NewServeMux()
+		if _, err := commandsOption(cctx, corecommands.RootRO, false)(n, l, cmdMux); err != nil {
+			return nil, err
+		}
+		filter, err := newAPIFilter(cmdMux, cfg.Gateway)
+		if err != nil {
+			return nil, err
+		}
+		repo.NotifyConfig(n.Repo, func(old, updated *config.Config) {
+			if old.Gateway.NoAPI == updated.Gateway.NoAPI &&
+				reflect.DeepEqual(old.Gateway.APICommands, updated.Gateway.APICommands) {
+				return
+			}
+			log.Info("Gateway.APICommands changed, applying them")
+			if err := filter.setConfig(updated.Gateway); err != nil {
+				log.Errorf("keeping the gateway API commands: %s", err)
+			}
+		})
+		mux.Handle(cmdsHttp.ApiPath+"/", filter)
+		return mux, nil
+	}
 }
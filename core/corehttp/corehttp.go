@@ -8,11 +8,9 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"time"
 
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
-	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
 	core "github.com/ipfs/go-ipfs/core"
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
@@ -64,50 +62,18 @@ func ListenAndServe(n *core.IpfsNode, listeningMultiAddr string, options ...Serv
 	return Serve(n, list.NetListener(), options...)
 }
 
+// Serve serves on lis until the node closes, which drains the requests in
+// flight, or until serving fails.
 func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error {
-	handler, err := makeHandler(node, lis, options...)
-	if err != nil {
+	s := NewServer(node, options...)
+	if err := s.Serve(lis); err != nil {
 		return err
 	}
 
-	addr, err := manet.FromNetAddr(lis.Addr())
+	// nil once the server is shut down
+	err := <-s.Errors()
 	if err != nil {
-		return err
+		s.Shutdown(ShutdownTimeout)
 	}
-
-	// if the server exits beforehand
-	var serverError error
-	serverExited := make(chan struct{})
-
-	node.Process().Go(func(p goprocess.Process) {
-		serverError = http.Serve(lis, handler)
-		close(serverExited)
-	})
-
-	// wait for server to exit.
-	select {
-	case <-serverExited:
-
-	// if node being closed before server exits, close server
-	case <-node.Process().Closing():
-		log.Infof("server at %s terminating...", addr)
-
-		lis.Close()
-
-	outer:
-		for {
-			// wait until server exits
-			select {
-			case <-serverExited:
-				// if the server exited as we are closing, we really dont care about errors
-				serverError = nil
-				break outer
-			case <-time.After(5 * time.Second):
-				log.Infof("waiting for server at %s to terminate...", addr)
-			}
-		}
-	}
-
-	log.Infof("server at %s terminated", addr)
-	return serverError
+	return err
 }
@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"sync"
 
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
 	core "github.com/ipfs/go-ipfs/core"
 	id "github.com/ipfs/go-ipfs/p2p/protocol/identify"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
 // Gateway should be instantiated using NewGateway
@@ -41,8 +44,32 @@ func (g *Gateway) ServeOption() ServeOption {
 		if err != nil {
 			return nil, err
 		}
-		mux.Handle("/ipfs/", gateway)
-		mux.Handle("/ipns/", gateway)
+		listing, err := loadListingTemplate(cfg.Gateway.DirIndexTemplate)
+		if err != nil {
+			return nil, err
+		}
+		gateway.setListingTemplate(listing)
+
+		repo.NotifyConfig(n.Repo, func(old, updated *config.Config) {
+			gateway.setUserHeaders(updated.Gateway.HTTPHeaders)
+			gateway.setCORS(updated.Gateway.HTTPHeaders)
+			if old.Gateway.DirIndexTemplate == updated.Gateway.DirIndexTemplate {
+				return
+			}
+			listing, err := loadListingTemplate(updated.Gateway.DirIndexTemplate)
+			if err != nil {
+				log.Errorf("keeping the directory listing template: %s", err)
+				return
+			}
+			gateway.setListingTemplate(listing)
+		})
+		metrics := &gatewayMetrics{node: n, next: cmdsHttp.NewCORSHandler(gateway.cors, gateway)}
+		metrics.setAccessLog(cfg.Gateway.AccessLog)
+		repo.NotifyConfig(n.Repo, func(_, updated *config.Config) {
+			metrics.setAccessLog(updated.Gateway.AccessLog)
+		})
+		mux.Handle("/ipfs/", metrics)
+		mux.Handle("/ipns/", metrics)
 		return mux, nil
 	}
 }
@@ -1,22 +1,31 @@
 package corehttp
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	gopath "path"
 	"strings"
+	"sync"
 	"time"
 
 	humanize "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/dustin/go-humanize"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	cmdsHttp "github.com/ipfs/go-ipfs/commands/http"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/importer"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
 	path "github.com/ipfs/go-ipfs/path"
 	"github.com/ipfs/go-ipfs/routing"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
@@ -25,6 +34,8 @@ import (
 const (
 	ipfsPathPrefix = "/ipfs/"
 	ipnsPathPrefix = "/ipns/"
+
+	emptyDirHash = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
 )
 
 // gatewayHandler is a HTTP handler that serves IPFS objects (accessible by default at /ipfs/<path>)
@@ -32,16 +43,60 @@ const (
 type gatewayHandler struct {
 	node   *core.IpfsNode
 	config GatewayConfig
+
+	// headersMu guards config.Headers, which change with the repo config
+	headersMu sync.RWMutex
+
+	// listingMu guards listing, which changes with the repo config too
+	listingMu sync.RWMutex
+	listing   *template.Template
+
+	// cors are the CORS options, from the Access-Control-Allow-* headers
+	// of the config
+	cors *cmdsHttp.ServerConfig
 }
 
 func newGatewayHandler(node *core.IpfsNode, conf GatewayConfig) (*gatewayHandler, error) {
 	i := &gatewayHandler{
-		node:   node,
-		config: conf,
+		node:    node,
+		config:  conf,
+		listing: defaultListingTemplate,
+		cors:    cmdsHttp.NewServerConfig(),
 	}
+	i.setCORS(conf.Headers)
 	return i, nil
 }
 
+// setCORS applies the CORS options of headers. Without allowed origins, the
+// content can be read from any origin, but only written from the gateway's
+// own.
+func (i *gatewayHandler) setCORS(headers map[string][]string) {
+	i.cors.SetAllowedOrigins()
+	i.cors.SetAllowedHeaders()
+	i.cors.SetAllowCredentials(false)
+	if i.config.Writable {
+		i.cors.SetAllowedMethods("GET", "HEAD", "POST", "PUT", "DELETE")
+	} else {
+		i.cors.SetAllowedMethods("GET", "HEAD")
+	}
+	addCORSFromHeaders(i.cors, headers)
+}
+
+// allowWrite guards the writable gateway against CSRF: browsers send the
+// Origin or Referer of cross site requests, which must be allowed by the
+// config, or be the gateway itself.
+func (i *gatewayHandler) allowWrite(r *http.Request) bool {
+	if cmdsHttp.AllowedOrigin(r, i.cors) {
+		return true
+	}
+	from := r.Header.Get("Origin")
+	if from == "" {
+		from = r.Referer()
+	}
+	u, err := url.Parse(from)
+	return err == nil && u.Host == r.Host
+}
+
 // TODO(cryptix):  find these helpers somewhere else
 func (i *gatewayHandler) newDagFromReader(r io.Reader) (*dag.Node, error) {
 	// TODO(cryptix): change and remove this helper once PR1136 is merged
@@ -55,6 +110,15 @@ func (i *gatewayHandler) newDagFromReader(r io.Reader) (*dag.Node, error) {
 // TODO(btc): break this apart into separate handlers using a more expressive muxer
 func (i *gatewayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if i.config.Writable {
+		switch r.Method {
+		case "POST", "PUT", "DELETE":
+			if !i.allowWrite(r) {
+				log.Warningf("gateway blocked %s request to %s. (possible CSRF)", r.Method, r.URL)
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("403 - Forbidden"))
+				return
+			}
+		}
 		switch r.Method {
 		case "POST":
 			i.postHandler(w, r)
@@ -89,6 +153,10 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 	ctx, cancel := context.WithCancel(i.node.Context())
 	defer cancel()
 
+	// the path and the file it leads to are fetched within one session
+	ctx, closeSes := bserv.WithSession(ctx)
+	defer closeSes()
+
 	urlPath := r.URL.Path
 
 	// IPNSHostnameOption might have constructed an IPNS path using the Host header.
@@ -116,8 +184,16 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	etag := gopath.Base(urlPath)
-	if r.Header.Get("If-None-Match") == etag {
+	k, err := nd.Key()
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	// the content hash identifies the response, whichever path led to it
+	etag := `"` + k.B58String() + `"`
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("Etag", etag)
 		w.WriteHeader(http.StatusNotModified)
 		return
 	}
@@ -147,11 +223,11 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 
 	// set these headers _after_ the error, for we may just not have it
 	// and dont want the client to cache a 500 response...
-	// and only if it's /ipfs!
+	// and cache for long only if it's /ipfs!
 	// TODO: break this out when we split /ipfs /ipns routes.
+	w.Header().Set("Etag", etag)
 	modtime := time.Now()
 	if strings.HasPrefix(urlPath, ipfsPathPrefix) {
-		w.Header().Set("Etag", etag)
 		w.Header().Set("Cache-Control", "public, max-age=29030400")
 
 		// set modtime to a really long time ago, since files are immutable and should stay cached
@@ -159,18 +235,38 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if err == nil {
+		// ServeContent answers Range and If-Range requests by seeking the
+		// reader, and sets Accept-Ranges and Content-Length
 		defer dr.Close()
 		_, name := gopath.Split(urlPath)
+		query := r.URL.Query()
+		if fn := query.Get("filename"); fn != "" {
+			name = fn
+		}
+
+		ctype, err := contentType(name, dr)
+		if err != nil {
+			internalWebError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+		if query.Get("filename") != "" || query.Get("download") == "true" {
+			w.Header().Set("Content-Disposition", contentDisposition(name, query.Get("download") == "true"))
+		}
 		http.ServeContent(w, r, name, modtime, dr)
 		return
 	}
 
+	// the listing depends on the Accept header, so caches must key on it
+	w.Header().Add("Vary", "Accept")
+	wantJSON := acceptsJSON(r.Header.Get("Accept"))
+
 	// storage for directory listing
 	var dirListing []directoryItem
 	// loop through files
 	foundIndex := false
 	for _, link := range nd.Links {
-		if link.Name == "index.html" {
+		if link.Name == "index.html" && !wantJSON {
 			log.Debugf("found index.html link for %s", urlPath)
 			foundIndex = true
 
@@ -194,19 +290,45 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 			}
 			defer dr.Close()
 
-			// write to request
-			if r.Method != "HEAD" {
-				io.Copy(w, dr)
-			}
+			// the listing's etag stands for the index page too, as the
+			// page cannot change without changing the directory
+			http.ServeContent(w, r, "index.html", modtime, dr)
 			break
 		}
 
 		// See comment above where originalUrlPath is declared.
-		di := directoryItem{humanize.Bytes(link.Size), link.Name, gopath.Join(originalUrlPath, link.Name)}
+		di := directoryItem{
+			Size:  humanize.Bytes(link.Size),
+			Name:  link.Name,
+			Path:  gopath.Join(originalUrlPath, link.Name),
+			Hash:  key.Key(link.Hash).B58String(),
+			bytes: link.Size,
+		}
 		dirListing = append(dirListing, di)
 	}
 
 	if !foundIndex {
+		// the entries of a listing are likely to be requested next
+		go i.prefetchLinks(nd.Links)
+
+		sortBy := r.URL.Query().Get("sort")
+		sortListing(dirListing, sortBy)
+
+		if wantJSON {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == "HEAD" {
+				return
+			}
+			out := listingJSON{Path: originalUrlPath, Hash: k.B58String(), Entries: []listingEntryJSON{}}
+			for _, di := range dirListing {
+				out.Entries = append(out.Entries, listingEntryJSON{Name: di.Name, Hash: di.Hash, Size: di.bytes})
+			}
+			if err := json.NewEncoder(w).Encode(out); err != nil {
+				internalWebError(w, err)
+			}
+			return
+		}
+
 		if r.Method != "HEAD" {
 			// construct the correct back link
 			// https://github.com/ipfs/go-ipfs/issues/1365
@@ -242,11 +364,14 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 
 			// See comment above where originalUrlPath is declared.
 			tplData := listingTemplateData{
-				Listing:  dirListing,
-				Path:     originalUrlPath,
-				BackLink: backLink,
+				Listing:     dirListing,
+				Path:        originalUrlPath,
+				Hash:        k.B58String(),
+				BackLink:    backLink,
+				Breadcrumbs: breadcrumbs(originalUrlPath, ipnsHostname),
+				Sort:        sortBy,
 			}
-			err := listingTemplate.Execute(w, tplData)
+			err := i.listingTemplate().Execute(w, tplData)
 			if err != nil {
 				internalWebError(w, err)
 				return
@@ -273,34 +398,62 @@ func (i *gatewayHandler) postHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, ipfsPathPrefix+k.String(), http.StatusCreated)
 }
 
-func (i *gatewayHandler) putEmptyDirHandler(w http.ResponseWriter, r *http.Request) {
-	newnode := uio.NewEmptyDirectory()
+// writableRoot resolves the root of the /ipfs/ or /ipns/ path urlPath, and
+// returns it with the components of the path under it.
+func (i *gatewayHandler) writableRoot(ctx context.Context, urlPath string) (*dag.Node, []string, error) {
+	parts := path.Path(urlPath).Segments()
+	if len(parts) < 2 {
+		return nil, nil, path.ErrNoComponents
+	}
 
-	key, err := i.node.DAG.Add(newnode)
-	if err != nil {
-		webError(w, "Could not recursively add new node", err, http.StatusInternalServerError)
-		return
+	// the empty directory is where new trees start, it need not be stored
+	var root *dag.Node
+	if parts[0] == "ipfs" && parts[1] == emptyDirHash {
+		root = uio.NewEmptyDirectory()
+	} else {
+		var err error
+		root, err = core.Resolve(ctx, i.node, path.Path("/"+parts[0]+"/"+parts[1]))
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	i.addUserHeaders(w) // ok, _now_ write user's headers.
-	w.Header().Set("IPFS-Hash", key.String())
-	http.Redirect(w, r, ipfsPathPrefix+key.String()+"/", http.StatusCreated)
+	var components []string
+	for _, c := range parts[2:] {
+		if c != "" {
+			components = append(components, c)
+		}
+	}
+	return root, components, nil
 }
 
+// putHandler adds the request body, or an empty directory when the path ends
+// with a slash, at the path under the root, creating the directories on the
+// way. It redirects to the same path under the new root.
 func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO(cryptix): either ask mildred about the flow of this or rewrite it
-	webErrorWithCode(w, "Sorry, PUT is bugged right now, closing request", errors.New("handler disabled"), http.StatusInternalServerError)
-	return
+	ctx, cancel := context.WithTimeout(i.node.Context(), time.Minute)
+	defer cancel()
+
 	urlPath := r.URL.Path
-	pathext := urlPath[5:]
-	var err error
-	if urlPath == ipfsPathPrefix+"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn/" {
-		i.putEmptyDirHandler(w, r)
+	rootnd, components, err := i.writableRoot(ctx, urlPath)
+	if err != nil {
+		webError(w, "Could not resolve root object", err, http.StatusBadRequest)
+		return
+	}
+
+	isDir := strings.HasSuffix(urlPath, "/")
+	if len(components) == 0 {
+		// putting a directory at its own root leaves it as it is
+		if !isDir {
+			webError(w, "http gateway", errors.New("cannot override existing object"), http.StatusBadRequest)
+			return
+		}
+		i.writeCreated(w, r, rootnd, nil, true)
 		return
 	}
 
 	var newnode *dag.Node
-	if pathext[len(pathext)-1] == '/' {
+	if isDir {
 		newnode = uio.NewEmptyDirectory()
 	} else {
 		newnode, err = i.newDagFromReader(r.Body)
@@ -310,156 +463,114 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	ctx, cancel := context.WithCancel(i.node.Context())
-	defer cancel()
-
-	ipfsNode, err := core.Resolve(ctx, i.node, path.Path(urlPath))
-	if err != nil {
-		// FIXME HTTP error code
-		webError(w, "Could not resolve name", err, http.StatusInternalServerError)
-		return
-	}
-
-	k, err := ipfsNode.Key()
+	e := dagutils.NewDagEditor(i.node.DAG, rootnd.Copy())
+	err = e.InsertNodeAtPath(ctx, strings.Join(components, "/"), newnode, uio.NewEmptyDirectory)
 	if err != nil {
-		webError(w, "Could not get key from resolved node", err, http.StatusInternalServerError)
+		webError(w, "Could not insert the new node", err, http.StatusInternalServerError)
 		return
 	}
 
-	h, components, err := path.SplitAbsPath(path.FromKey(k))
-	if err != nil {
-		webError(w, "Could not split path", err, http.StatusInternalServerError)
-		return
-	}
-
-	if len(components) < 1 {
-		err = fmt.Errorf("Cannot override existing object")
-		webError(w, "http gateway", err, http.StatusBadRequest)
-		return
-	}
+	i.writeCreated(w, r, e.GetNode(), components, isDir)
+}
 
-	tctx, cancel := context.WithTimeout(ctx, time.Minute)
+// deleteHandler removes the link at the path under the root, and redirects
+// to its parent under the new root.
+func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(i.node.Context(), time.Minute)
 	defer cancel()
-	// TODO(cryptix): could this be core.Resolve() too?
-	rootnd, err := i.node.Resolver.DAG.Get(tctx, key.Key(h))
+
+	rootnd, components, err := i.writableRoot(ctx, r.URL.Path)
 	if err != nil {
 		webError(w, "Could not resolve root object", err, http.StatusBadRequest)
 		return
 	}
-
-	// resolving path components into merkledag nodes. if a component does not
-	// resolve, create empty directories (which will be linked and populated below.)
-	pathNodes, err := i.node.Resolver.ResolveLinks(tctx, rootnd, components[:len(components)-1])
-	if _, ok := err.(path.ErrNoLink); ok {
-		// Create empty directories, links will be made further down the code
-		for len(pathNodes) < len(components) {
-			pathNodes = append(pathNodes, uio.NewDirectory(i.node.DAG).GetNode())
-		}
-	} else if err != nil {
-		webError(w, "Could not resolve parent object", err, http.StatusBadRequest)
+	if len(components) == 0 {
+		webError(w, "http gateway", errors.New("cannot delete the root object"), http.StatusBadRequest)
 		return
 	}
 
-	for i := len(pathNodes) - 1; i >= 0; i-- {
-		newnode, err = pathNodes[i].UpdateNodeLink(components[i], newnode)
-		if err != nil {
-			webError(w, "Could not update node links", err, http.StatusInternalServerError)
-			return
-		}
-	}
-
-	if err := i.node.DAG.AddRecursive(newnode); err != nil {
-		webError(w, "Could not add recursively new node", err, http.StatusInternalServerError)
+	e := dagutils.NewDagEditor(i.node.DAG, rootnd.Copy())
+	err = e.RmLink(ctx, strings.Join(components, "/"))
+	if err == dag.ErrNotFound {
+		webErrorWithCode(w, "Could not delete link", err, http.StatusNotFound)
 		return
-	}
-
-	// Redirect to new path
-	key, err := newnode.Key()
-	if err != nil {
-		webError(w, "Could not get key of new node", err, http.StatusInternalServerError)
+	} else if err != nil {
+		webError(w, "Could not delete link", err, http.StatusInternalServerError)
 		return
 	}
 
-	i.addUserHeaders(w) // ok, _now_ write user's headers.
-	w.Header().Set("IPFS-Hash", key.String())
-	http.Redirect(w, r, ipfsPathPrefix+key.String()+"/"+strings.Join(components, "/"), http.StatusCreated)
+	i.writeCreated(w, r, e.GetNode(), components[:len(components)-1], true)
 }
 
-func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
-	urlPath := r.URL.Path
-	ctx, cancel := context.WithCancel(i.node.Context())
-	defer cancel()
-
-	ipfsNode, err := core.Resolve(ctx, i.node, path.Path(urlPath))
-	if err != nil {
-		// FIXME HTTP error code
-		webError(w, "Could not resolve name", err, http.StatusInternalServerError)
+// writeCreated answers a write with the hash of the new root, redirecting
+// to the components under it.
+func (i *gatewayHandler) writeCreated(w http.ResponseWriter, r *http.Request, root *dag.Node, components []string, isDir bool) {
+	if _, err := i.node.DAG.Add(root); err != nil {
+		webError(w, "Could not add the new root", err, http.StatusInternalServerError)
 		return
 	}
-
-	k, err := ipfsNode.Key()
+	k, err := root.Key()
 	if err != nil {
-		webError(w, "Could not get key from resolved node", err, http.StatusInternalServerError)
+		webError(w, "Could not get key of new node", err, http.StatusInternalServerError)
 		return
 	}
 
-	h, components, err := path.SplitAbsPath(path.FromKey(k))
-	if err != nil {
-		webError(w, "Could not split path", err, http.StatusInternalServerError)
-		return
+	location := ipfsPathPrefix + k.String()
+	if len(components) > 0 {
+		location += "/" + strings.Join(components, "/")
 	}
-
-	tctx, cancel := context.WithTimeout(ctx, time.Minute)
-	defer cancel()
-	rootnd, err := i.node.Resolver.DAG.Get(tctx, key.Key(h))
-	if err != nil {
-		webError(w, "Could not resolve root object", err, http.StatusBadRequest)
-		return
+	if isDir {
+		location += "/"
 	}
 
-	pathNodes, err := i.node.Resolver.ResolveLinks(tctx, rootnd, components[:len(components)-1])
-	if err != nil {
-		webError(w, "Could not resolve parent object", err, http.StatusBadRequest)
-		return
-	}
+	i.addUserHeaders(w) // ok, _now_ write user's headers.
+	w.Header().Set("IPFS-Hash", k.String())
+	http.Redirect(w, r, location, http.StatusCreated)
+}
 
-	// TODO(cyrptix): assumes len(pathNodes) > 1 - not found is an error above?
-	err = pathNodes[len(pathNodes)-1].RemoveNodeLink(components[len(components)-1])
-	if err != nil {
-		webError(w, "Could not delete link", err, http.StatusBadRequest)
+// prefetchLinks queues the targets of links to be fetched in the background.
+// It gives up on the rest if the prefetch queue stays full for a second.
+func (i *gatewayHandler) prefetchLinks(links []*dag.Link) {
+	if i.node.Prefetcher == nil {
 		return
 	}
 
-	newnode := pathNodes[len(pathNodes)-1]
-	for i := len(pathNodes) - 2; i >= 0; i-- {
-		newnode, err = pathNodes[i].UpdateNodeLink(components[i], newnode)
-		if err != nil {
-			webError(w, "Could not update node links", err, http.StatusInternalServerError)
+	ctx, cancel := context.WithTimeout(i.node.Context(), time.Second)
+	defer cancel()
+	for _, link := range links {
+		if err := i.node.Prefetcher.Prefetch(ctx, key.Key(link.Hash), 0); err != nil {
 			return
 		}
 	}
+}
 
-	if err := i.node.DAG.AddRecursive(newnode); err != nil {
-		webError(w, "Could not add recursively new node", err, http.StatusInternalServerError)
-		return
+func (i *gatewayHandler) addUserHeaders(w http.ResponseWriter) {
+	i.headersMu.RLock()
+	headers := i.config.Headers
+	i.headersMu.RUnlock()
+	for k, v := range headers {
+		if !cmdsHttp.IsCORSHeader(k) {
+			w.Header()[k] = v
+		}
 	}
+}
 
-	// Redirect to new path
-	key, err := newnode.Key()
-	if err != nil {
-		webError(w, "Could not get key of new node", err, http.StatusInternalServerError)
-		return
-	}
+func (i *gatewayHandler) setUserHeaders(headers map[string][]string) {
+	i.headersMu.Lock()
+	i.config.Headers = headers
+	i.headersMu.Unlock()
+}
 
-	i.addUserHeaders(w) // ok, _now_ write user's headers.
-	w.Header().Set("IPFS-Hash", key.String())
-	http.Redirect(w, r, ipfsPathPrefix+key.String()+"/"+strings.Join(components[:len(components)-1], "/"), http.StatusCreated)
+func (i *gatewayHandler) listingTemplate() *template.Template {
+	i.listingMu.RLock()
+	defer i.listingMu.RUnlock()
+	return i.listing
 }
 
-func (i *gatewayHandler) addUserHeaders(w http.ResponseWriter) {
-	for k, v := range i.config.Headers {
-		w.Header()[k] = v
-	}
+func (i *gatewayHandler) setListingTemplate(t *template.Template) {
+	i.listingMu.Lock()
+	i.listing = t
+	i.listingMu.Unlock()
 }
 
 func webError(w http.ResponseWriter, message string, err error, defaultCode int) {
@@ -484,3 +595,59 @@ func webErrorWithCode(w http.ResponseWriter, message string, err error, code int
 func internalWebError(w http.ResponseWriter, err error) {
 	webErrorWithCode(w, "internalWebError", err, http.StatusInternalServerError)
 }
+
+// etagMatches reports whether the If-None-Match header value inm lists
+// etag, or is "*". Weak tags are compared weakly, as RFC 7232 asks for
+// If-None-Match.
+func etagMatches(inm, etag string) bool {
+	for _, t := range strings.Split(inm, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// contentType returns the type of the file name by its extension, or else
+// by sniffing its first bytes from r, which is seeked back to the start.
+func contentType(name string, r io.ReadSeeker) (string, error) {
+	if ctype := mime.TypeByExtension(gopath.Ext(name)); ctype != "" {
+		return ctype, nil
+	}
+
+	// DetectContentType considers at most the first 512 bytes
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := r.Seek(0, os.SEEK_SET); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// contentDisposition returns the Content-Disposition header value telling
+// browsers to save the content as name when download is set, or to show it
+// otherwise. Names outside of ASCII are sent as RFC 5987 extended values.
+func contentDisposition(name string, download bool) string {
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+
+	ascii := true
+	for _, c := range name {
+		if c < 0x20 || c > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		name = strings.Replace(strings.Replace(name, `\`, `\\`, -1), `"`, `\"`, -1)
+		return fmt.Sprintf(`%s; filename="%s"`, disposition, name)
+	}
+	escaped := strings.Replace(url.QueryEscape(name), "+", "%20", -1)
+	return fmt.Sprintf(`%s; filename*=UTF-8''%s`, disposition, escaped)
+}
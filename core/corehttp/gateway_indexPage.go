@@ -1,27 +1,67 @@
 package corehttp
 
 import (
+	"fmt"
 	"html/template"
+	"io/ioutil"
 	"path"
+	"sort"
 	"strings"
 
 	"github.com/ipfs/go-ipfs/assets"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
 // structs for directory listing
 type listingTemplateData struct {
-	Listing  []directoryItem
-	Path     string
-	BackLink string
+	Listing     []directoryItem
+	Path        string
+	Hash        string
+	BackLink    string
+	Breadcrumbs []breadcrumb
+	Sort        string
 }
 
 type directoryItem struct {
 	Size string
 	Name string
 	Path string
+	Hash string
+
+	bytes uint64
+}
+
+// breadcrumb is a component of the listed path, linking to the directory
+// up to it. The namespace (ipfs or ipns) has no Path.
+type breadcrumb struct {
+	Name string
+	Path string
+}
+
+// listingJSON is the listing sent to clients that accept application/json
+type listingJSON struct {
+	Path    string
+	Hash    string
+	Entries []listingEntryJSON
+}
+
+type listingEntryJSON struct {
+	Name string
+	Hash string
+	Size uint64
 }
 
-var listingTemplate *template.Template
+// the vendored page predates breadcrumbs; they are added under its heading
+// when it is parsed
+const (
+	defaultHeading     = "<strong>Index of {{ .Path }}</strong>"
+	breadcrumbsHeading = defaultHeading + `
+        <div class="breadcrumbs">{{ range .Breadcrumbs }}/{{ if .Path }}<a href="{{ .Path }}/">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }}{{ else }}/{{ end }}</div>`
+)
+
+var defaultListingTemplate *template.Template
+
+var listingFuncs template.FuncMap
 
 func init() {
 	assetPath := "../vendor/dir-index-html-v1.0.0/"
@@ -44,6 +84,9 @@ func init() {
 		}
 		return "ipfs-" + ext[1:] // slice of the first dot
 	}
+	listingFuncs = template.FuncMap{
+		"iconFromExt": iconFromExt,
+	}
 
 	// Directory listing template
 	dirIndexBytes, err := assets.Asset(assetPath + "dir-index.html")
@@ -51,7 +94,93 @@ func init() {
 		panic(err)
 	}
 
-	listingTemplate = template.Must(template.New("dir").Funcs(template.FuncMap{
-		"iconFromExt": iconFromExt,
-	}).Parse(string(dirIndexBytes)))
+	dirIndex := strings.Replace(string(dirIndexBytes), defaultHeading, breadcrumbsHeading, 1)
+	defaultListingTemplate = template.Must(parseListingTemplate(dirIndex))
+}
+
+func parseListingTemplate(text string) (*template.Template, error) {
+	return template.New("dir").Funcs(listingFuncs).Parse(text)
+}
+
+// loadListingTemplate parses the directory listing template in the file
+// fpath, or returns the default one when fpath is empty.
+func loadListingTemplate(fpath string) (*template.Template, error) {
+	if fpath == "" {
+		return defaultListingTemplate, nil
+	}
+	fpath, err := u.TildeExpansion(fpath)
+	if err != nil {
+		return nil, err
+	}
+	text, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("failure to load config setting Gateway.DirIndexTemplate: %s", err)
+	}
+	t, err := parseListingTemplate(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failure to parse config setting Gateway.DirIndexTemplate: %s", err)
+	}
+	return t, nil
+}
+
+// breadcrumbs splits urlPath into its components. The first two components
+// of /ipfs/ and /ipns/ paths are the namespace and the root, unless the path
+// was rewritten by IPNSHostnameOption.
+func breadcrumbs(urlPath string, ipnsHostname bool) []breadcrumb {
+	var crumbs []breadcrumb
+	cur := ""
+	for i, name := range strings.Split(strings.Trim(urlPath, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		cur += "/" + name
+		if i == 0 && !ipnsHostname {
+			crumbs = append(crumbs, breadcrumb{Name: name})
+			continue
+		}
+		crumbs = append(crumbs, breadcrumb{Name: name, Path: cur})
+	}
+	return crumbs
+}
+
+// sortListing sorts the listing by name, or by size when by is "size". A
+// leading "-" reverses the order.
+func sortListing(items []directoryItem, by string) {
+	s := listingSorter{items, func(a, b directoryItem) bool { return a.Name < b.Name }}
+	if strings.TrimPrefix(by, "-") == "size" {
+		s.less = func(a, b directoryItem) bool {
+			if a.bytes == b.bytes {
+				return a.Name < b.Name
+			}
+			return a.bytes < b.bytes
+		}
+	}
+	if strings.HasPrefix(by, "-") {
+		sort.Sort(sort.Reverse(s))
+	} else {
+		sort.Sort(s)
+	}
+}
+
+type listingSorter struct {
+	items []directoryItem
+	less  func(a, b directoryItem) bool
+}
+
+func (s listingSorter) Len() int           { return len(s.items) }
+func (s listingSorter) Swap(i, j int)      { s.items[i], s.items[j] = s.items[j], s.items[i] }
+func (s listingSorter) Less(i, j int) bool { return s.less(s.items[i], s.items[j]) }
+
+// acceptsJSON reports whether the client asked for application/json rather
+// than a page.
+func acceptsJSON(accept string) bool {
+	for _, t := range strings.Split(accept, ",") {
+		if i := strings.Index(t, ";"); i >= 0 {
+			t = t[:i]
+		}
+		if strings.TrimSpace(t) == "application/json" {
+			return true
+		}
+	}
+	return false
 }
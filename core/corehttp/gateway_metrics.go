@@ -0,0 +1,113 @@
+package corehttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/prometheus/client_golang/prometheus"
+
+	core "github.com/ipfs/go-ipfs/core"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var accessLog = logging.Logger("gateway/access")
+
+var gatewayRequests = prom.NewCounterVec(prom.CounterOpts{
+	Namespace: "ipfs",
+	Subsystem: "http_gw",
+	Name:      "requests_total",
+	Help:      "Number of requests to the gateway, by method and status code",
+}, []string{"method", "code"})
+
+var gatewayResponseBytes = prom.NewCounterVec(prom.CounterOpts{
+	Namespace: "ipfs",
+	Subsystem: "http_gw",
+	Name:      "response_bytes_total",
+	Help:      "Bytes of content sent by the gateway, by namespace",
+}, []string{"namespace"})
+
+var gatewayDuration = prom.NewHistogramVec(prom.HistogramOpts{
+	Namespace: "ipfs",
+	Subsystem: "http_gw",
+	Name:      "request_duration_seconds",
+	Help:      "Time taken to answer the requests to the gateway, by namespace",
+	Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60},
+}, []string{"namespace"})
+
+func init() {
+	prom.MustRegisterOrGet(gatewayRequests)
+	prom.MustRegisterOrGet(gatewayResponseBytes)
+	prom.MustRegisterOrGet(gatewayDuration)
+}
+
+// gatewayMetrics counts the requests to next in the gateway metrics, and
+// logs an access event for each of them while accessLog is set.
+type gatewayMetrics struct {
+	node      *core.IpfsNode
+	next      http.Handler
+	accessLog int32 // atomic
+}
+
+func (m *gatewayMetrics) setAccessLog(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&m.accessLog, v)
+}
+
+func (m *gatewayMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	urlPath := r.URL.Path
+	rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+	m.next.ServeHTTP(rw, r)
+	elapsed := time.Since(start)
+
+	ns := "other"
+	if parts := strings.SplitN(urlPath, "/", 3); len(parts) >= 2 && (parts[1] == "ipfs" || parts[1] == "ipns") {
+		ns = parts[1]
+	}
+	gatewayRequests.WithLabelValues(r.Method, strconv.Itoa(rw.status)).Inc()
+	gatewayResponseBytes.WithLabelValues(ns).Add(float64(rw.bytes))
+	gatewayDuration.WithLabelValues(ns).Observe(elapsed.Seconds())
+
+	if atomic.LoadInt32(&m.accessLog) == 0 {
+		return
+	}
+	accessLog.Event(m.node.Context(), "gatewayRequest", logging.LoggableMap{
+		"method":  r.Method,
+		"path":    urlPath,
+		"hash":    strings.Trim(rw.Header().Get("Etag"), `"`),
+		"status":  rw.status,
+		"bytes":   rw.bytes,
+		"latency": elapsed.String(),
+		"remote":  r.RemoteAddr,
+	})
+}
+
+// recordingWriter remembers the status and the size of a response.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
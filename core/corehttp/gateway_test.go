@@ -1,23 +1,27 @@
 package corehttp
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	prom "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/prometheus/client_golang/prometheus"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	core "github.com/ipfs/go-ipfs/core"
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	path "github.com/ipfs/go-ipfs/path"
-	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
-	testutil "github.com/ipfs/go-ipfs/util/testutil"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
 )
 
 type mockNamesys map[string]path.Path
@@ -42,16 +46,21 @@ func (m mockNamesys) PublishWithEOL(ctx context.Context, name ci.PrivKey, value
 	return errors.New("not implemented for mockNamesys")
 }
 
+func (m mockNamesys) PublishWithTTL(ctx context.Context, name ci.PrivKey, value path.Path, _ time.Time, _ time.Duration) error {
+	return errors.New("not implemented for mockNamesys")
+}
+
+func (m mockNamesys) PublishWithKey(ctx context.Context, keyName string, value path.Path) error {
+	return errors.New("not implemented for mockNamesys")
+}
+
 func newNodeWithMockNamesys(ns mockNamesys) (*core.IpfsNode, error) {
 	c := config.Config{
 		Identity: config.Identity{
 			PeerID: "Qmfoo", // required by offline node
 		},
 	}
-	r := &repo.Mock{
-		C: c,
-		D: testutil.ThreadSafeCloserMapDatastore(),
-	}
+	r := mockrepo.New(c)
 	n, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
 	if err != nil {
 		return nil, err
@@ -83,6 +92,10 @@ func doWithoutRedirect(req *http.Request) (*http.Response, error) {
 }
 
 func newTestServerAndNode(t *testing.T, ns mockNamesys) (*httptest.Server, *core.IpfsNode) {
+	return newTestServerAndNodeWritable(t, ns, false)
+}
+
+func newTestServerAndNodeWritable(t *testing.T, ns mockNamesys, writable bool) (*httptest.Server, *core.IpfsNode) {
 	n, err := newNodeWithMockNamesys(ns)
 	if err != nil {
 		t.Fatal(err)
@@ -96,7 +109,7 @@ func newTestServerAndNode(t *testing.T, ns mockNamesys) (*httptest.Server, *core
 	dh.Handler, err = makeHandler(n,
 		ts.Listener,
 		IPNSHostnameOption(),
-		GatewayOption(false),
+		GatewayOption(writable),
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -159,6 +172,464 @@ func TestGatewayGet(t *testing.T) {
 	}
 }
 
+func TestGatewayRangeAndEtag(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	// large enough to span several blocks
+	data := make([]byte, 600*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	k, err := coreunix.Add(n, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := ts.URL + "/ipfs/" + k
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Accept-Ranges is %q, expected bytes", res.Header.Get("Accept-Ranges"))
+	}
+	if res.ContentLength != int64(len(data)) {
+		t.Errorf("Content-Length is %d, expected %d", res.ContentLength, len(data))
+	}
+	etag := res.Header.Get("Etag")
+	if etag != `"`+k+`"` {
+		t.Errorf("Etag is %s, expected the quoted hash %s", etag, k)
+	}
+
+	for _, rng := range []struct{ start, end int }{{0, 9}, {300000, 300099}, {len(data) - 10, len(data) - 1}} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rng.start, rng.end))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != http.StatusPartialContent {
+			t.Errorf("got %d, expected %d for range %d-%d", res.StatusCode, http.StatusPartialContent, rng.start, rng.end)
+			continue
+		}
+		if !bytes.Equal(body, data[rng.start:rng.end+1]) {
+			t.Errorf("wrong content for range %d-%d", rng.start, rng.end)
+		}
+	}
+
+	for _, inm := range []string{etag, `W/"foo", ` + etag, "*"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", inm)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotModified {
+			t.Errorf("got %d, expected %d for If-None-Match %s", res.StatusCode, http.StatusNotModified, inm)
+		}
+	}
+}
+
+func TestGatewayContentType(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 1000)...)
+	k, err := coreunix.Add(n, bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		query       string
+		ctype       string
+		disposition string
+	}{
+		{"", "image/png", ""},
+		{"?filename=notes.txt", "text/plain; charset=utf-8", `inline; filename="notes.txt"`},
+		{"?filename=a%22b.png&download=true", "image/png", `attachment; filename="a\"b.png"`},
+		{"?filename=%C3%A9t%C3%A9.png", "image/png", "inline; filename*=UTF-8''%C3%A9t%C3%A9.png"},
+		{"?download=true", "image/png", `attachment; filename="` + k + `"`},
+	} {
+		res, err := http.Get(ts.URL + "/ipfs/" + k + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(body, png) {
+			t.Errorf("%s: the content should be served from its start", test.query)
+		}
+		if ctype := res.Header.Get("Content-Type"); ctype != test.ctype {
+			t.Errorf("%s: Content-Type is %q, expected %q", test.query, ctype, test.ctype)
+		}
+		if disp := res.Header.Get("Content-Disposition"); disp != test.disposition {
+			t.Errorf("%s: Content-Disposition is %q, expected %q", test.query, disp, test.disposition)
+		}
+	}
+}
+
+func TestGatewayDirListing(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	// create /ipfs/<dir>/sub/ with files of different sizes
+	_, dir, err := coreunix.AddWrapped(n, strings.NewReader("a"), "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sub, err := coreunix.AddWrapped(n, strings.NewReader("bbbbbbbbbb"), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, c, err := coreunix.AddWrapped(n, strings.NewReader("ccccc"), "c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.AddNodeLink("c.txt", c.Links[0].Node); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.AddNodeLink("b.txt", dir.Links[0].Node); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddNodeLink("sub", sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.DAG.AddRecursive(dir); err != nil {
+		t.Fatal(err)
+	}
+	k, err := dir.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subPath := "/ipfs/" + k.B58String() + "/sub/"
+
+	get := func(url, accept string) string {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("got %d from %s", res.StatusCode, url)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	s := get(ts.URL+subPath, "")
+	if !strings.Contains(s, `<a href="/ipfs/`+k.B58String()+`/">`+k.B58String()+`</a>/<a href="/ipfs/`+k.B58String()+`/sub/">sub</a>`) {
+		t.Errorf("expected breadcrumbs in directory listing: %s", s)
+	}
+	a, b := strings.Index(s, "a.txt"), strings.Index(s, "b.txt")
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("expected the listing to be sorted by name")
+	}
+
+	var listing listingJSON
+	err = json.Unmarshal([]byte(get(ts.URL+subPath+"?sort=-size", "application/json")), &listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range listing.Entries {
+		names = append(names, e.Name)
+	}
+	if strings.Join(names, " ") != "a.txt c.txt b.txt" {
+		t.Errorf("expected the entries by decreasing size, got %v", names)
+	}
+	if listing.Hash == "" || listing.Entries[0].Hash == "" {
+		t.Errorf("expected the hashes in the listing: %v", listing)
+	}
+
+	tmpl, err := ioutil.TempFile("", "dir-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpl.Name())
+	fmt.Fprint(tmpl, "{{ range .Listing }}{{ .Name }} {{ end }}")
+	tmpl.Close()
+	if err := n.Repo.SetConfigKey("Gateway.DirIndexTemplate", tmpl.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if s := get(ts.URL+subPath+"?sort=size", ""); s != "b.txt c.txt a.txt " {
+		t.Errorf("expected the listing from the configured template, got %q", s)
+	}
+}
+
+func TestGatewayWritable(t *testing.T) {
+	ns := mockNamesys{}
+	ts, _ := newTestServerAndNodeWritable(t, ns, true)
+	defer ts.Close()
+
+	// do sends a request and returns the new root from its Location
+	do := func(method, urlPath, body string, status int) string {
+		req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Fatalf("%s %s: got %d, expected %d", method, urlPath, res.StatusCode, status)
+		}
+		if status != http.StatusCreated {
+			return ""
+		}
+		if !strings.HasPrefix(res.Header.Get("Location"), "/ipfs/"+res.Header.Get("IPFS-Hash")) {
+			t.Fatalf("%s %s: Location %s does not start with the new hash", method, urlPath, res.Header.Get("Location"))
+		}
+		return res.Header.Get("IPFS-Hash")
+	}
+	get := func(urlPath string) (int, string) {
+		res, err := http.Get(ts.URL + urlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(body)
+	}
+
+	empty := emptyDirHash
+	if k := do("PUT", "/ipfs/"+empty+"/", "", http.StatusCreated); k != empty {
+		t.Fatalf("putting the empty directory gave %s", k)
+	}
+	root := do("PUT", "/ipfs/"+empty+"/a/b.txt", "fnord", http.StatusCreated)
+	root = do("PUT", "/ipfs/"+root+"/c.txt", "ipsum", http.StatusCreated)
+	if status, body := get("/ipfs/" + root + "/a/b.txt"); status != http.StatusOK || body != "fnord" {
+		t.Fatalf("got %d %q for the new file", status, body)
+	}
+
+	root = do("DELETE", "/ipfs/"+root+"/a/b.txt", "", http.StatusCreated)
+	if status, _ := get("/ipfs/" + root + "/a/b.txt"); status != http.StatusNotFound {
+		t.Fatalf("got %d for the deleted file", status)
+	}
+	if status, body := get("/ipfs/" + root + "/c.txt"); status != http.StatusOK || body != "ipsum" {
+		t.Fatalf("got %d %q for the kept file", status, body)
+	}
+	do("DELETE", "/ipfs/"+root+"/nothere", "", http.StatusNotFound)
+	do("PUT", "/ipfs/"+root, "", http.StatusBadRequest)
+
+	k := do("POST", "/ipfs/", "new", http.StatusCreated)
+	if status, body := get("/ipfs/" + k); status != http.StatusOK || body != "new" {
+		t.Fatalf("got %d %q for the posted file", status, body)
+	}
+}
+
+func TestHostnameOption(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.PublicGateways = map[string]config.GatewaySpec{
+		"gw.example.com":   {UseSubdomains: true, Paths: []string{"/ipfs", "/ipns"}},
+		"site.example.com": {RootPath: "/ipfs/" + k},
+	}
+	if err := n.Repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	defer ts.Close()
+	dh.Handler, err = makeHandler(n, ts.Listener, HostnameOption(), VersionOption(), GatewayOption(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label := subdomainLabel(k)
+	if label != strings.ToLower(label) || subdomainName(label) != k {
+		t.Fatalf("subdomain label %s does not round trip to %s", label, k)
+	}
+
+	for _, test := range []struct {
+		host     string
+		path     string
+		status   int
+		text     string
+		location string
+	}{
+		{"gw.example.com", "/ipfs/" + k, http.StatusMovedPermanently, "", "http://" + label + ".ipfs.gw.example.com/"},
+		{"gw.example.com:8080", "/ipfs/" + k + "/a/b?c=d", http.StatusMovedPermanently, "", "http://" + label + ".ipfs.gw.example.com:8080/a/b?c=d"},
+		{"gw.example.com", "/version", http.StatusNotFound, "404 page not found\n", ""},
+		{label + ".ipfs.gw.example.com", "/", http.StatusOK, "fnord", ""},
+		{"site.example.com", "/", http.StatusOK, "fnord", ""},
+		{"other.example.com", "/ipfs/" + k, http.StatusOK, "fnord", ""},
+	} {
+		req, err := http.NewRequest("GET", ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		urlstr := "http://" + test.host + test.path
+		if res.StatusCode != test.status {
+			t.Errorf("got %d, expected %d from %s", res.StatusCode, test.status, urlstr)
+			continue
+		}
+		if test.location != "" {
+			if res.Header.Get("Location") != test.location {
+				t.Errorf("got redirected to %s, expected %s from %s", res.Header.Get("Location"), test.location, urlstr)
+			}
+			continue
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != test.text {
+			t.Errorf("unexpected response body from %s: expected %q; got %q", urlstr, test.text, body)
+		}
+	}
+}
+
+func TestGatewayCORS(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNodeWritable(t, ns, true)
+	defer ts.Close()
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, origin string) *http.Response {
+		req, err := http.NewRequest(method, ts.URL+"/ipfs/"+k, strings.NewReader("new"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	// the content can be read from anywhere, but only written from the
+	// gateway itself
+	if res := do("GET", "http://app.example"); res.Header.Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("expected CORS headers on reads from any origin")
+	}
+	if res := do("POST", "http://app.example"); res.StatusCode != http.StatusForbidden {
+		t.Errorf("got %d for a write from another origin, expected %d", res.StatusCode, http.StatusForbidden)
+	}
+	if res := do("POST", ts.URL); res.StatusCode != http.StatusCreated {
+		t.Errorf("got %d for a write from the gateway, expected %d", res.StatusCode, http.StatusCreated)
+	}
+	if res := do("POST", ""); res.StatusCode != http.StatusCreated {
+		t.Errorf("got %d for a write from outside a browser, expected %d", res.StatusCode, http.StatusCreated)
+	}
+
+	err = n.Repo.SetConfigKey("Gateway.HTTPHeaders", map[string][]string{
+		"Access-Control-Allow-Origin": {"http://app.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := do("POST", "http://app.example"); res.StatusCode != http.StatusCreated {
+		t.Errorf("got %d for a write from an allowed origin, expected %d", res.StatusCode, http.StatusCreated)
+	}
+	if res := do("GET", "http://other.example"); res.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected no CORS headers for an origin that is not allowed")
+	}
+	if res := do("GET", "http://app.example"); res.Header.Get("Access-Control-Allow-Origin") != "http://app.example" {
+		t.Errorf("expected CORS headers for the allowed origin, got %q", res.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestGatewayMetrics(t *testing.T) {
+	ns := mockNamesys{}
+	ts, n := newTestServerAndNode(t, ns)
+	defer ts.Close()
+
+	k, err := coreunix.Add(n, strings.NewReader("fnord"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Repo.SetConfigKey("Gateway.AccessLog", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/ipfs/" + k, "/ipfs/nothash"} {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	metrics := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/debug/metrics/prometheus", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prom.UninstrumentedHandler().ServeHTTP(metrics, req)
+	for _, m := range []string{
+		`ipfs_http_gw_requests_total{code="200",method="GET"}`,
+		`ipfs_http_gw_requests_total{code="400",method="GET"}`,
+		`ipfs_http_gw_response_bytes_total{namespace="ipfs"}`,
+		`ipfs_http_gw_request_duration_seconds_count{namespace="ipfs"}`,
+	} {
+		if !strings.Contains(metrics.Body.String(), m) {
+			t.Errorf("expected the metric %s", m)
+		}
+	}
+}
+
 func TestIPNSHostnameRedirect(t *testing.T) {
 	ns := mockNamesys{}
 	ts, n := newTestServerAndNode(t, ns)
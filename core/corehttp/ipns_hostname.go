@@ -1,13 +1,18 @@
 package corehttp
 
 import (
+	"encoding/base32"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 
 	isd "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-is-domain"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	"github.com/ipfs/go-ipfs/core"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
 // IPNSHostnameOption rewrites an incoming request if its Host: header contains
@@ -17,19 +22,185 @@ func IPNSHostnameOption() ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 		childMux := http.NewServeMux()
 		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithCancel(n.Context())
-			defer cancel()
-
-			host := strings.SplitN(r.Host, ":", 2)[0]
-			if len(host) > 0 && isd.IsDomain(host) {
-				name := "/ipns/" + host
-				if _, err := n.Namesys.Resolve(ctx, name); err == nil {
-					r.Header["X-IPNS-Original-Path"] = []string{r.URL.Path}
-					r.URL.Path = name + r.URL.Path
-				}
-			}
+			rewriteDNSLink(n, r, requestHost(r))
 			childMux.ServeHTTP(w, r)
 		})
 		return childMux, nil
 	}
 }
+
+// HostnameOption serves the hostnames of Gateway.PublicGateways: their
+// subdomains and root paths. The requests for other hosts are rewritten
+// like IPNSHostnameOption does.
+func HostnameOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		childMux := http.NewServeMux()
+		h := &hostnameHandler{
+			node:     n,
+			gateways: cfg.Gateway.PublicGateways,
+			next:     childMux,
+		}
+		repo.NotifyConfig(n.Repo, func(_, updated *config.Config) {
+			h.setGateways(updated.Gateway.PublicGateways)
+		})
+		mux.Handle("/", h)
+		return childMux, nil
+	}
+}
+
+type hostnameHandler struct {
+	node *core.IpfsNode
+	next http.Handler
+
+	mu       sync.RWMutex
+	gateways map[string]config.GatewaySpec
+}
+
+func (h *hostnameHandler) setGateways(gateways map[string]config.GatewaySpec) {
+	h.mu.Lock()
+	h.gateways = gateways
+	h.mu.Unlock()
+}
+
+func (h *hostnameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	gateways := h.gateways
+	h.mu.RUnlock()
+
+	host := requestHost(r)
+	if gw, ok := gateways[host]; ok {
+		h.serveGateway(w, r, gw)
+		return
+	}
+
+	if ns, name, ok := gatewaySubdomain(host, gateways); ok {
+		rewritePath(r, "/"+ns+"/"+name)
+	} else {
+		rewriteDNSLink(h.node, r, host)
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// serveGateway serves a request for the hostname of gw itself.
+func (h *hostnameHandler) serveGateway(w http.ResponseWriter, r *http.Request, gw config.GatewaySpec) {
+	if gw.RootPath != "" {
+		rewritePath(r, strings.TrimSuffix(gw.RootPath, "/"))
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if len(gw.Paths) > 0 && !hasPathPrefix(r.URL.Path, gw.Paths) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if gw.UseSubdomains {
+		// /<ns>/<name>/rest is moved to <name>.<ns>.<hostname>/rest
+		parts := strings.SplitN(r.URL.Path, "/", 4)
+		if len(parts) >= 3 && (parts[1] == "ipfs" || parts[1] == "ipns") && parts[2] != "" {
+			rest := "/"
+			if len(parts) == 4 {
+				rest += parts[3]
+			}
+			u := *r.URL
+			u.Scheme = "http"
+			if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+				u.Scheme = "https"
+			}
+			u.Host = subdomainLabel(parts[2]) + "." + parts[1] + "." + r.Host
+			u.Path = rest
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// requestHost returns the lowercased host of r, without the port.
+func requestHost(r *http.Request) string {
+	return strings.ToLower(strings.SplitN(r.Host, ":", 2)[0])
+}
+
+// rewritePath moves r under root, keeping the original path for the links
+// of the gateway handler.
+func rewritePath(r *http.Request, root string) {
+	r.Header["X-IPNS-Original-Path"] = []string{r.URL.Path}
+	r.URL.Path = root + r.URL.Path
+}
+
+func rewriteDNSLink(n *core.IpfsNode, r *http.Request, host string) {
+	if len(host) == 0 || !isd.IsDomain(host) {
+		return
+	}
+	ctx, cancel := context.WithCancel(n.Context())
+	defer cancel()
+
+	name := "/ipns/" + host
+	if _, err := n.Namesys.Resolve(ctx, name); err == nil {
+		rewritePath(r, name)
+	}
+}
+
+func hasPathPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewaySubdomain splits a host of the form <name>.<ns>.<hostname>, where
+// hostname is a gateway using subdomains, into the namespace (ipfs or ipns)
+// and the name.
+func gatewaySubdomain(host string, gateways map[string]config.GatewaySpec) (ns, name string, ok bool) {
+	for hostname, gw := range gateways {
+		if !gw.UseSubdomains || !strings.HasSuffix(host, "."+hostname) {
+			continue
+		}
+		sub := strings.TrimSuffix(host, "."+hostname)
+		i := strings.LastIndex(sub, ".")
+		if i <= 0 {
+			continue
+		}
+		ns, label := sub[i+1:], sub[:i]
+		if ns != "ipfs" && ns != "ipns" {
+			continue
+		}
+		return ns, subdomainName(label), true
+	}
+	return "", "", false
+}
+
+// hostnames are case insensitive, so hashes are put in subdomains in
+// lowercase base32 rather than base58
+var subdomainEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// subdomainLabel returns the label for name in a subdomain: the base32
+// encoding of the hash it is, or name itself.
+func subdomainLabel(name string) string {
+	h, err := mh.FromB58String(name)
+	if err != nil {
+		return name
+	}
+	return strings.ToLower(subdomainEncoding.EncodeToString(h))
+}
+
+// subdomainName reverses subdomainLabel.
+func subdomainName(label string) string {
+	b, err := subdomainEncoding.DecodeString(strings.ToUpper(label))
+	if err != nil {
+		return label
+	}
+	h, err := mh.Cast(b)
+	if err != nil {
+		return label
+	}
+	return h.B58String()
+}
@@ -0,0 +1,320 @@
+package corehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+// ShutdownTimeout is how long the requests in flight are given to finish
+// when the node serving them closes.
+var ShutdownTimeout = 10 * time.Second
+
+var ErrServerClosed = errors.New("http server is shut down")
+
+// Server serves the handler made of its ServeOptions on a set of listeners,
+// which can be added and removed while it runs. The listeners it removes,
+// and all of them when it shuts down, stop accepting connections but let the
+// requests in flight finish, up to a deadline.
+//
+// A Server shuts down when its node closes.
+type Server struct {
+	node *core.IpfsNode
+
+	mu        sync.Mutex
+	options   []ServeOption
+	listeners map[string]*serverListener // by multiaddr
+	closed    bool
+
+	closing chan struct{}
+	errc    chan error
+	wg      sync.WaitGroup
+}
+
+func NewServer(node *core.IpfsNode, options ...ServeOption) *Server {
+	s := &Server{
+		node:      node,
+		options:   options,
+		listeners: make(map[string]*serverListener),
+		closing:   make(chan struct{}),
+		errc:      make(chan error),
+	}
+	node.Process().Go(func(p goprocess.Process) {
+		select {
+		case <-p.Closing():
+			if err := s.Shutdown(ShutdownTimeout); err != nil {
+				log.Error(err)
+			}
+		case <-s.closing:
+		}
+	})
+	return s
+}
+
+// Listen listens at addr and serves on it. It returns the address listened
+// at, which tells the port picked for /tcp/0.
+func (s *Server) Listen(addr ma.Multiaddr) (ma.Multiaddr, error) {
+	lis, err := manet.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Serve(lis.NetListener()); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis.Multiaddr(), nil
+}
+
+// Serve serves on lis, in the background. The errors which stop it before
+// it is removed are sent on Errors.
+func (s *Server) Serve(lis net.Listener) error {
+	addr, err := manet.FromNetAddr(lis.Addr())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	if _, ok := s.listeners[addr.String()]; ok {
+		return fmt.Errorf("already serving on %s", addr)
+	}
+
+	handler, err := makeHandler(s.node, lis, s.options...)
+	if err != nil {
+		return err
+	}
+	l := newServerListener(addr, lis, handler)
+	s.listeners[addr.String()] = l
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := l.serve(); err != nil {
+			select {
+			case s.errc <- fmt.Errorf("server at %s: %s", addr, err):
+			case <-s.closing:
+			}
+		}
+	}()
+	return nil
+}
+
+// Remove stops serving on addr, giving the requests in flight timeout to
+// finish before their connections are closed.
+func (s *Server) Remove(addr ma.Multiaddr, timeout time.Duration) error {
+	s.mu.Lock()
+	l, ok := s.listeners[addr.String()]
+	delete(s.listeners, addr.String())
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("not serving on %s", addr)
+	}
+	return l.shutdown(timeout)
+}
+
+// Addrs returns the addresses served on, sorted.
+func (s *Server) Addrs() []ma.Multiaddr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for k := range s.listeners {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	addrs := make([]ma.Multiaddr, len(keys))
+	for i, k := range keys {
+		addrs[i] = s.listeners[k].addr
+	}
+	return addrs
+}
+
+// Restart replaces the options of the server, and the handlers of all its
+// listeners with ones made of them. The requests in flight finish on the
+// handlers they started on. If a handler can't be made, nothing changes.
+//
+// The options of the replaced handlers stay subscribed to the config
+// changes, so restarts should be kept to the changes they can't follow.
+func (s *Server) Restart(options ...ServeOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+
+	handlers := make(map[*serverListener]http.Handler, len(s.listeners))
+	for _, l := range s.listeners {
+		h, err := makeHandler(s.node, l.lis, options...)
+		if err != nil {
+			return err
+		}
+		handlers[l] = h
+	}
+	for l, h := range handlers {
+		l.setHandler(h)
+	}
+	s.options = options
+	return nil
+}
+
+// Shutdown removes all the listeners, giving the requests in flight timeout
+// to finish, and closes Errors.
+func (s *Server) Shutdown(timeout time.Duration) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.closing)
+	listeners := s.listeners
+	s.listeners = make(map[string]*serverListener)
+	s.mu.Unlock()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *serverListener) {
+			log.Infof("server at %s terminating...", l.addr)
+			errs <- l.shutdown(timeout)
+			log.Infof("server at %s terminated", l.addr)
+		}(l)
+	}
+	var err error
+	for range listeners {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	s.wg.Wait()
+	close(s.errc)
+	return err
+}
+
+// Errors returns the channel of the errors that stop listeners before they
+// are removed. It is closed when the server shuts down.
+func (s *Server) Errors() <-chan error {
+	return s.errc
+}
+
+// serverListener serves one listener of a Server, keeping track of its
+// connections to drain them.
+type serverListener struct {
+	addr ma.Multiaddr
+	lis  net.Listener
+	srv  *http.Server
+
+	mu      sync.Mutex
+	handler http.Handler
+	conns   map[net.Conn]http.ConnState
+	closing bool
+}
+
+func newServerListener(addr ma.Multiaddr, lis net.Listener, handler http.Handler) *serverListener {
+	l := &serverListener{
+		addr:    addr,
+		lis:     lis,
+		handler: handler,
+		conns:   make(map[net.Conn]http.ConnState),
+	}
+	l.srv = &http.Server{
+		Handler:   l,
+		ConnState: l.trackConn,
+	}
+	return l
+}
+
+func (l *serverListener) setHandler(h http.Handler) {
+	l.mu.Lock()
+	l.handler = h
+	l.mu.Unlock()
+}
+
+func (l *serverListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	h := l.handler
+	l.mu.Unlock()
+	h.ServeHTTP(w, r)
+}
+
+func (l *serverListener) trackConn(c net.Conn, state http.ConnState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(l.conns, c)
+	case http.StateIdle:
+		if l.closing {
+			c.Close()
+		}
+		l.conns[c] = state
+	default:
+		l.conns[c] = state
+	}
+}
+
+// serve serves until the listener is closed, and returns nil if it was by
+// shutdown.
+func (l *serverListener) serve() error {
+	err := l.srv.Serve(l.lis)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing {
+		return nil
+	}
+	return err
+}
+
+// shutdown closes the listener and the idle connections, and waits for the
+// others to finish their requests. Those still busy after timeout are
+// closed.
+func (l *serverListener) shutdown(timeout time.Duration) error {
+	l.mu.Lock()
+	l.closing = true
+	l.mu.Unlock()
+
+	l.srv.SetKeepAlivesEnabled(false)
+	err := l.lis.Close()
+
+	deadline := time.After(timeout)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		l.mu.Lock()
+		for c, state := range l.conns {
+			if state == http.StateIdle {
+				c.Close()
+			}
+		}
+		busy := len(l.conns)
+		l.mu.Unlock()
+		if busy == 0 {
+			return err
+		}
+
+		select {
+		case <-tick.C:
+		case <-deadline:
+			l.mu.Lock()
+			for c := range l.conns {
+				c.Close()
+			}
+			l.mu.Unlock()
+			return fmt.Errorf("server at %s: closed %d connections before their requests finished", l.addr, busy)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package corehttp
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+func textOption(text string, block chan struct{}) ServeOption {
+	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if block != nil && r.URL.Path == "/slow" {
+				<-block
+			}
+			w.Write([]byte(text))
+		})
+		return mux, nil
+	}
+}
+
+func TestServer(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	block := make(chan struct{})
+	s := NewServer(n, textOption("first", block))
+
+	get := func(addr ma.Multiaddr, p string) (string, error) {
+		_, host, err := manet.DialArgs(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.Get("http://" + host + p)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		return string(b), err
+	}
+
+	local := ma.StringCast("/ip4/127.0.0.1/tcp/0")
+	a1, err := s.Listen(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, err := s.Listen(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Addrs()) != 2 {
+		t.Fatalf("expected two addresses, got %v", s.Addrs())
+	}
+	for _, a := range []ma.Multiaddr{a1, a2} {
+		if body, err := get(a, "/"); err != nil || body != "first" {
+			t.Fatalf("%s: expected first, got %q (%v)", a, body, err)
+		}
+	}
+
+	if err := s.Restart(textOption("second", block)); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := get(a1, "/"); err != nil || body != "second" {
+		t.Fatalf("expected the restarted handler, got %q (%v)", body, err)
+	}
+
+	// a request in flight finishes after its listener is removed
+	slow := make(chan string)
+	go func() {
+		body, err := get(a1, "/slow")
+		if err != nil {
+			body = err.Error()
+		}
+		slow <- body
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	removed := make(chan error)
+	go func() {
+		removed <- s.Remove(a1, 5*time.Second)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := get(a1, "/"); err == nil {
+		t.Fatal("the removed address should not be served")
+	}
+	close(block)
+	if body := <-slow; body != "second" {
+		t.Fatalf("the request in flight should finish, got %q", body)
+	}
+	if err := <-removed; err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err := get(a2, "/"); err != nil || body != "second" {
+		t.Fatalf("the other address should still be served, got %q (%v)", body, err)
+	}
+
+	if err := s.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-s.Errors(); ok {
+		t.Fatal("Errors should be closed by Shutdown")
+	}
+	if _, err := get(a2, "/"); err == nil {
+		t.Fatal("nothing should be served after Shutdown")
+	}
+	if _, err := s.Listen(local); err != ErrServerClosed {
+		t.Fatalf("expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestServerShutdownDeadline(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+	s := NewServer(n, textOption("text", block))
+	addr, err := s.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, host, err := manet.DialArgs(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		res, err := http.Get("http://" + host + "/slow")
+		if err == nil {
+			_, err = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := s.Shutdown(100 * time.Millisecond); err == nil {
+		t.Fatal("expected an error for the request cut by the deadline")
+	}
+	if err := <-done; err == nil {
+		t.Fatal("the request should have been cut")
+	}
+}
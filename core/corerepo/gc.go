@@ -1,28 +1,135 @@
 package corerepo
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	humanize "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/dustin/go-humanize"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	"github.com/ipfs/go-ipfs/core"
+	config "github.com/ipfs/go-ipfs/repo/config"
 
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
 
 var log = logging.Logger("corerepo")
 
+// ErrMaxStorageExceeded is returned when data doesn't fit under
+// Datastore.StorageMax, even after garbage collection.
+var ErrMaxStorageExceeded = errors.New("maximum storage limit exceeded, maybe unpin some files?")
+
+const (
+	defaultStorageGCWatermark = 90
+	defaultGCPeriod           = time.Hour
+)
+
 type KeyRemoved struct {
 	Key key.Key
 }
 
+// bestEffortSet returns the keys of all locally stored blocks reachable
+// from best effort pins. Blocks missing from the blockstore are skipped
+// rather than fetched, along with everything below them.
+func bestEffortSet(n *core.IpfsNode, ctx context.Context) (map[key.Key]struct{}, error) {
+	keep := make(map[key.Key]struct{})
+
+	var visit func(k key.Key) error
+	visit = func(k key.Key) error {
+		if _, ok := keep[k]; ok {
+			return nil
+		}
+
+		has, err := n.Blockstore.Has(k)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return nil
+		}
+		keep[k] = struct{}{}
+
+		nd, err := n.DAG.Get(ctx, k)
+		if err != nil {
+			return err
+		}
+		for _, l := range nd.Links {
+			if err := visit(key.Key(l.Hash)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, k := range n.Pinning.BestEffortKeys() {
+		if err := visit(k); err != nil {
+			return nil, err
+		}
+	}
+	return keep, nil
+}
+
+// ColoredSet returns the keys of all blocks that garbage collection must
+// keep: every pinned block, and the locally stored parts of best effort pins.
+func ColoredSet(n *core.IpfsNode, ctx context.Context) (map[key.Key]struct{}, error) {
+	keep, err := bestEffortSet(n, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range n.Pinning.RecursiveKeys() {
+		keep[k] = struct{}{}
+	}
+	for _, k := range n.Pinning.DirectKeys() {
+		keep[k] = struct{}{}
+	}
+	indirect, err := n.Pinning.IndirectKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k := range indirect {
+		keep[k] = struct{}{}
+	}
+	return keep, nil
+}
+
+// isPinned returns whether the garbage collector must keep the given block.
+// Blocks outside the colored set are checked against the pinner once more,
+// so an incomplete set never causes a pinned block to be removed.
+func isPinned(ctx context.Context, n *core.IpfsNode, keep map[key.Key]struct{}, k key.Key) (bool, error) {
+	if _, ok := keep[k]; ok {
+		return true, nil
+	}
+	return n.Pinning.IsPinned(ctx, k)
+}
+
+// GarbageCollect removes all blocks that are not pinned. Collection happens
+// in two phases: the set of blocks to keep is marked first, then every other
+// block is swept. Both phases run under the blockstore's GC lock, so blocks
+// that are being added and pinned concurrently are never swept.
 func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // in case error occurs during operation
+
+	unlocker := n.Blockstore.GCLock()
+	defer unlocker.Unlock()
+
+	keep, err := ColoredSet(n, ctx)
+	if err != nil {
+		return err
+	}
+
 	keychan, err := n.Blockstore.AllKeysChan(ctx)
 	if err != nil {
 		return err
 	}
 	for k := range keychan { // rely on AllKeysChan to close chan
-		if !n.Pinning.IsPinned(k) {
+		pinned, err := isPinned(ctx, n, keep, k)
+		if err != nil {
+			return err
+		}
+		if !pinned {
 			err := n.Blockstore.DeleteBlock(k)
 			if err != nil {
 				return err
@@ -32,23 +139,39 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	return nil
 }
 
+// GarbageCollectAsync is like GarbageCollect, but streams the keys of the
+// removed blocks. The GC lock is held until the returned channel is closed.
 func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemoved, error) {
+	unlocker := n.Blockstore.GCLock()
+
+	keep, err := ColoredSet(n, ctx)
+	if err != nil {
+		unlocker.Unlock()
+		return nil, err
+	}
 
 	keychan, err := n.Blockstore.AllKeysChan(ctx)
 	if err != nil {
+		unlocker.Unlock()
 		return nil, err
 	}
 
 	output := make(chan *KeyRemoved)
 	go func() {
 		defer close(output)
+		defer unlocker.Unlock()
 		for {
 			select {
 			case k, ok := <-keychan:
 				if !ok {
 					return
 				}
-				if !n.Pinning.IsPinned(k) {
+				pinned, err := isPinned(ctx, n, keep, k)
+				if err != nil {
+					log.Errorf("stopping garbage collection: %s", err)
+					return
+				}
+				if !pinned {
 					err := n.Blockstore.DeleteBlock(k)
 					if err != nil {
 						log.Debugf("Error removing key from blockstore: %s", err)
@@ -66,3 +189,123 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) (<-chan *KeyRemo
 	}()
 	return output, nil
 }
+
+// storageLimits returns the repo size limit set in the config, and the size
+// past which garbage collection runs. A zero limit means none.
+func storageLimits(cfg *config.Datastore) (max, watermark uint64, err error) {
+	if cfg.StorageMax == "" {
+		return 0, 0, nil
+	}
+	max, err = humanize.ParseBytes(cfg.StorageMax)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failure to parse config setting Datastore.StorageMax: %s", err)
+	}
+
+	percent := cfg.StorageGCWatermark
+	if percent == 0 {
+		percent = defaultStorageGCWatermark
+	}
+	if percent < 0 || percent > 100 {
+		return 0, 0, fmt.Errorf("config setting Datastore.StorageGCWatermark must be a percentage, not %d", percent)
+	}
+	return max, max / 100 * uint64(percent), nil
+}
+
+// ConditionalGC runs garbage collection if storing offset more bytes would
+// take the repo past the GC watermark, and returns ErrMaxStorageExceeded if
+// they still wouldn't fit under Datastore.StorageMax.
+func ConditionalGC(ctx context.Context, n *core.IpfsNode, offset uint64) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	max, watermark, err := storageLimits(&cfg.Datastore)
+	if err != nil || max == 0 {
+		return err
+	}
+
+	usage, err := n.Repo.GetStorageUsage()
+	if err != nil {
+		return err
+	}
+	if usage+offset <= watermark {
+		return nil
+	}
+
+	log.Infof("repo is using %s of %s, collecting garbage", humanize.Bytes(usage), humanize.Bytes(max))
+	if err := GarbageCollect(n, ctx); err != nil {
+		return err
+	}
+
+	usage, err = n.Repo.GetStorageUsage()
+	if err != nil {
+		return err
+	}
+	if usage+offset > max {
+		return ErrMaxStorageExceeded
+	}
+	return nil
+}
+
+// FreeStorage returns how many more bytes fit in the repo under
+// Datastore.StorageMax, and false if the config sets no limit.
+func FreeStorage(n *core.IpfsNode) (uint64, bool, error) {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return 0, false, err
+	}
+	max, _, err := storageLimits(&cfg.Datastore)
+	if err != nil || max == 0 {
+		return 0, false, err
+	}
+
+	usage, err := n.Repo.GetStorageUsage()
+	if err != nil {
+		return 0, false, err
+	}
+	if usage >= max {
+		return 0, true, nil
+	}
+	return max - usage, true, nil
+}
+
+// PeriodicGC checks the repo size every Datastore.GCPeriod, and collects
+// garbage when it is past the GC watermark, until ctx is done. It returns
+// straight away if the config sets no Datastore.StorageMax.
+func PeriodicGC(ctx context.Context, n *core.IpfsNode) error {
+	cfg, err := n.Repo.Config()
+	if err != nil {
+		return err
+	}
+	max, _, err := storageLimits(&cfg.Datastore)
+	if err != nil || max == 0 {
+		return err
+	}
+
+	period := defaultGCPeriod
+	if cfg.Datastore.GCPeriod != "" {
+		period, err = time.ParseDuration(cfg.Datastore.GCPeriod)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Datastore.GCPeriod: %s", err)
+		}
+		if period <= 0 {
+			return fmt.Errorf("config setting Datastore.GCPeriod must be positive, not %s", period)
+		}
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := ConditionalGC(ctx, n, 0)
+			if err == ErrMaxStorageExceeded {
+				log.Errorf("repo is over Datastore.StorageMax (%s) after garbage collection", cfg.Datastore.StorageMax)
+			} else if err != nil {
+				log.Error("periodic garbage collection failed: ", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
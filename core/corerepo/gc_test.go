@@ -0,0 +1,147 @@
+package corerepo
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/merkledag"
+	"github.com/ipfs/go-ipfs/repo/config"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
+)
+
+// gcTestNode returns an offline node with the given datastore config, a
+// pinned block and a 20kB unpinned block.
+func gcTestNode(ctx context.Context, t *testing.T, dcfg config.Datastore) (n *core.IpfsNode, pinned, unpinned key.Key) {
+	r := mockrepo.New(config.Config{
+		Identity: config.Identity{
+			PeerID: "Qmfoo", // required by offline node
+		},
+		Datastore: dcfg,
+	})
+	n, err := core.NewNode(ctx, &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &merkledag.Node{Data: []byte("gc pinned")}
+	pinned, err = n.DAG.Add(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Pinning.Pin(ctx, p, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Pinning.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	unpinned, err = n.DAG.Add(&merkledag.Node{Data: bytes.Repeat([]byte("u"), 20000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, pinned, unpinned
+}
+
+func hasBlock(t *testing.T, n *core.IpfsNode, k key.Key) bool {
+	has, err := n.Blockstore.Has(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return has
+}
+
+func TestConditionalGC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// without a limit, nothing is collected and anything fits
+	n, _, unpinned := gcTestNode(ctx, t, config.Datastore{})
+	if err := ConditionalGC(ctx, n, 1<<40); err != nil {
+		t.Fatal(err)
+	}
+	if !hasBlock(t, n, unpinned) {
+		t.Fatal("collected garbage without a storage limit")
+	}
+
+	// the watermark is at 90kB
+	n, pinned, unpinned := gcTestNode(ctx, t, config.Datastore{StorageMax: "100kB"})
+
+	// under the watermark, nothing is collected
+	if err := ConditionalGC(ctx, n, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !hasBlock(t, n, unpinned) {
+		t.Fatal("collected garbage under the watermark")
+	}
+
+	// past the watermark, the garbage is collected to make room
+	if err := ConditionalGC(ctx, n, 75000); err != nil {
+		t.Fatal(err)
+	}
+	if hasBlock(t, n, unpinned) {
+		t.Fatal("did not collect garbage past the watermark")
+	}
+	if !hasBlock(t, n, pinned) {
+		t.Fatal("collected a pinned block")
+	}
+
+	// what does not fit under the limit is refused
+	if err := ConditionalGC(ctx, n, 200000); err != ErrMaxStorageExceeded {
+		t.Fatalf("expected %s, got %v", ErrMaxStorageExceeded, err)
+	}
+
+	n, _, _ = gcTestNode(ctx, t, config.Datastore{StorageMax: "lots"})
+	if err := ConditionalGC(ctx, n, 0); err == nil {
+		t.Fatal("expected an invalid StorageMax to fail")
+	}
+}
+
+func TestPeriodicGC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// without a limit, it returns straight away
+	n, _, _ := gcTestNode(ctx, t, config.Datastore{GCPeriod: "10ms"})
+	if err := PeriodicGC(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+
+	n, _, _ = gcTestNode(ctx, t, config.Datastore{StorageMax: "10kB", GCPeriod: "-1s"})
+	if err := PeriodicGC(ctx, n); err == nil {
+		t.Fatal("expected a negative GCPeriod to fail")
+	}
+
+	// past the watermark, the garbage is collected on the next tick
+	n, pinned, unpinned := gcTestNode(ctx, t, config.Datastore{StorageMax: "10kB", GCPeriod: "10ms"})
+	gcctx, gccancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- PeriodicGC(gcctx, n)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hasBlock(t, n, unpinned) {
+		if time.Now().After(deadline) {
+			t.Fatal("did not collect garbage past the watermark")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !hasBlock(t, n, pinned) {
+		t.Fatal("collected a pinned block")
+	}
+
+	gccancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not stop once its context was done")
+	}
+}
@@ -22,9 +22,19 @@ import (
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	pin "github.com/ipfs/go-ipfs/pin"
 )
 
 func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool) ([]key.Key, error) {
+	return PinWithInfo(n, ctx, paths, recursive, nil)
+}
+
+// PinWithInfo pins the objects at the given paths, recording the given
+// metadata for each of the pins.
+func PinWithInfo(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool, info *pin.PinInfo) ([]key.Key, error) {
+	// blocks fetched while resolving and pinning must survive until pinned
+	defer n.Blockstore.PinLock().Unlock()
+
 	dagnodes := make([]*merkledag.Node, 0)
 	for _, fpath := range paths {
 		dagnode, err := core.Resolve(ctx, n, path.Path(fpath))
@@ -43,7 +53,7 @@ func Pin(n *core.IpfsNode, ctx context.Context, paths []string, recursive bool)
 
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
-		err = n.Pinning.Pin(ctx, dagnode, recursive)
+		err = n.Pinning.PinWithInfo(ctx, dagnode, recursive, info)
 		if err != nil {
 			return nil, fmt.Errorf("pin: %s", err)
 		}
@@ -0,0 +1,107 @@
+package corerepo
+
+import (
+	"fmt"
+	"strings"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// RefsOptions selects the refs Refs enumerates, and how FormatRef formats
+// them.
+type RefsOptions struct {
+	// Recursive enumerates the refs of the child objects too.
+	Recursive bool
+	// Unique omits the refs already enumerated, across all the objects.
+	Unique bool
+	// MaxDepth limits the recursion to the given depth, when above zero.
+	MaxDepth int
+
+	// Edges formats the refs as "<src> -> <dst>".
+	Edges bool
+	// Format formats the refs with the tokens <src>, <dst> and <linkname>,
+	// and overrides Edges.
+	Format string
+}
+
+// Refs resolves the objects at paths and streams their refs, the objects
+// one after the other. An error ends the stream, sent as the Err of its
+// last Ref. The stream stops when ctx is done.
+func Refs(ctx context.Context, n *core.IpfsNode, paths []string, opts RefsOptions) (<-chan *merkledag.Ref, error) {
+	objs := make([]*merkledag.Node, len(paths))
+	for i, p := range paths {
+		o, err := core.Resolve(ctx, n, path.Path(p))
+		if err != nil {
+			return nil, fmt.Errorf("refs: %s", err)
+		}
+		objs[i] = o
+	}
+
+	out := make(chan *merkledag.Ref)
+	go func() {
+		defer close(out)
+
+		send := func(r *merkledag.Ref) bool {
+			select {
+			case out <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// refs are only unique per object, keep them unique across all
+		// the objects too.
+		seen := make(map[key.Key]struct{})
+		for _, o := range objs {
+			ctx, cancel := context.WithCancel(ctx)
+			refs := merkledag.EnumerateRefs(ctx, n.DAG, o, merkledag.RefsOptions{
+				Recursive: opts.Recursive,
+				Unique:    opts.Unique,
+				MaxDepth:  opts.MaxDepth,
+			})
+			for r := range refs {
+				if r.Err == nil && opts.Unique {
+					if _, ok := seen[r.Dst]; ok {
+						continue
+					}
+					seen[r.Dst] = struct{}{}
+				}
+				if !send(r) || r.Err != nil {
+					cancel()
+					return
+				}
+			}
+			cancel()
+		}
+	}()
+	return out, nil
+}
+
+// FormatRef formats r as selected by opts, by default as the key it points
+// to.
+func FormatRef(r *merkledag.Ref, opts RefsOptions) string {
+	switch {
+	case opts.Format != "":
+		s := opts.Format
+		s = strings.Replace(s, "<src>", r.Src.Pretty(), -1)
+		s = strings.Replace(s, "<dst>", r.Dst.Pretty(), -1)
+		s = strings.Replace(s, "<linkname>", r.LinkName, -1)
+		return s
+	case opts.Edges:
+		return r.Src.Pretty() + " -> " + r.Dst.Pretty()
+	default:
+		return r.Dst.Pretty()
+	}
+}
+
+// LocalRefs streams the keys of all the blocks of the local blockstore. The
+// stream stops when ctx is done.
+func LocalRefs(ctx context.Context, n *core.IpfsNode) (<-chan key.Key, error) {
+	return n.Blockstore.AllKeysChan(ctx)
+}
@@ -0,0 +1,106 @@
+package corerepo
+
+import (
+	"sort"
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/merkledag"
+	"github.com/ipfs/go-ipfs/repo/config"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
+)
+
+func TestRefs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := mockrepo.New(config.Config{
+		Identity: config.Identity{
+			PeerID: "Qmfoo", // required by offline node
+		},
+	})
+	n, err := core.NewNode(ctx, &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a -> c, b -> c, b -> d
+	c := &merkledag.Node{Data: []byte("refs c")}
+	d := &merkledag.Node{Data: []byte("refs d")}
+	a := &merkledag.Node{Data: []byte("refs a")}
+	b := &merkledag.Node{Data: []byte("refs b")}
+	if err := a.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddNodeLink("d", d); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, nd := range []*merkledag.Node{a, b, c, d} {
+		k, err := n.DAG.Add(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k.Pretty())
+	}
+	ka, kb, kc, kd := keys[0], keys[1], keys[2], keys[3]
+
+	refs := func(opts RefsOptions) []string {
+		out, err := Refs(ctx, n, []string{"/ipfs/" + ka, "/ipfs/" + kb}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for r := range out {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			lines = append(lines, FormatRef(r, opts))
+		}
+		return lines
+	}
+	equal := func(got []string, expect ...string) {
+		if len(got) != len(expect) {
+			t.Fatalf("got %q, expected %q", got, expect)
+		}
+		for i := range got {
+			if got[i] != expect[i] {
+				t.Fatalf("got %q, expected %q", got, expect)
+			}
+		}
+	}
+
+	equal(refs(RefsOptions{}), kc, kc, kd)
+	equal(refs(RefsOptions{Unique: true}), kc, kd)
+	equal(refs(RefsOptions{Unique: true, Edges: true}), ka+" -> "+kc, kb+" -> "+kd)
+	equal(refs(RefsOptions{Format: "<linkname> <dst>"}), "c "+kc, "c "+kc, "d "+kd)
+
+	if _, err := Refs(ctx, n, []string{"/ipfs/" + ka + "/nope"}, RefsOptions{}); err == nil {
+		t.Fatal("expected an error for a path which does not resolve")
+	}
+
+	local, err := LocalRefs(ctx, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for k := range local {
+		found[key.Key(k).Pretty()] = true
+	}
+	for _, k := range keys {
+		if !found[k] {
+			var all []string
+			for k := range found {
+				all = append(all, k)
+			}
+			sort.Strings(all)
+			t.Fatalf("%s is not in the local refs %q", k, all)
+		}
+	}
+}
@@ -2,15 +2,26 @@ package corerouting
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
 	core "github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/p2p/host"
 	"github.com/ipfs/go-ipfs/p2p/peer"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	routing "github.com/ipfs/go-ipfs/routing"
+	compose "github.com/ipfs/go-ipfs/routing/compose"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	local "github.com/ipfs/go-ipfs/routing/local"
+	static "github.com/ipfs/go-ipfs/routing/static"
 	supernode "github.com/ipfs/go-ipfs/routing/supernode"
 	gcproxy "github.com/ipfs/go-ipfs/routing/supernode/proxy"
+	ipfsaddr "github.com/ipfs/go-ipfs/util/ipfsaddr"
 )
 
 // NB: DHT option is included in the core to avoid 1) because it's a sane
@@ -54,3 +65,136 @@ func SupernodeClient(remotes ...peer.PeerInfo) core.RoutingOption {
 		return supernode.NewClient(proxy, ph, ph.Peerstore(), ph.ID())
 	}
 }
+
+// DelegatedClient returns a configuration for a routing client that sends
+// its queries to the API of other nodes, the routers, at the given addresses
+// such as "127.0.0.1:5001". authorization is sent along when set.
+func DelegatedClient(routers []string, authorization string) core.RoutingOption {
+	return func(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+		return delegated.NewClient(ph, routers, authorization)
+	}
+}
+
+// DelegatedRouters returns the API addresses of the Routing.Delegated
+// routers of rc, as host:port, and the Authorization header to send them.
+func DelegatedRouters(rc config.Routing) ([]string, string, error) {
+	if len(rc.Delegated) == 0 {
+		return nil, "", errors.New("delegated routing requires Routing.Delegated routers in the config")
+	}
+	var routers []string
+	for _, s := range rc.Delegated {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("Routing.Delegated: %s", err)
+		}
+		_, host, err := manet.DialArgs(a)
+		if err != nil {
+			return nil, "", fmt.Errorf("Routing.Delegated: %s", err)
+		}
+		routers = append(routers, host)
+	}
+
+	var auth string
+	if rc.DelegatedAuth != "" {
+		var err error
+		auth, err = config.AuthorizationHeader(rc.DelegatedAuth)
+		if err != nil {
+			return nil, "", fmt.Errorf("Routing.DelegatedAuth: %s", err)
+		}
+	}
+	return routers, auth, nil
+}
+
+// Composed returns a configuration for the composition of routing systems
+// described by the Routing.Routers of rc.
+func Composed(rc config.Routing) (core.RoutingOption, error) {
+	if rc.Routers == nil {
+		return nil, errors.New("composed routing requires Routing.Routers in the config")
+	}
+	return func(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+		return composeRouter(ctx, ph, dstore, rc, rc.Routers)
+	}, nil
+}
+
+func composeRouter(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore, rc config.Routing, r *config.Router) (routing.IpfsRouting, error) {
+	var router routing.IpfsRouting
+	switch r.Type {
+	case "dht":
+		var err error
+		router, err = core.DHTOption(ctx, ph, dstore)
+		if err != nil {
+			return nil, err
+		}
+	case "delegated":
+		routers, auth, err := DelegatedRouters(rc)
+		if err != nil {
+			return nil, err
+		}
+		router, err = delegated.NewClient(ph, routers, auth)
+		if err != nil {
+			return nil, err
+		}
+	case "local":
+		router = local.NewRouter(ph)
+	case "static":
+		var err error
+		router, err = staticRouter(r.Providers)
+		if err != nil {
+			return nil, err
+		}
+	case "parallel", "sequential", "tiered":
+		routers := make([]routing.IpfsRouting, len(r.Routers))
+		for i := range r.Routers {
+			var err error
+			routers[i], err = composeRouter(ctx, ph, dstore, rc, &r.Routers[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		switch r.Type {
+		case "parallel":
+			router = compose.Parallel(routers)
+		case "sequential":
+			router = compose.Sequential(routers)
+		default:
+			router = compose.Tiered(routers)
+		}
+	default:
+		return nil, fmt.Errorf("unknown router type %q", r.Type)
+	}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("router timeout: %s", err)
+		}
+		router = compose.WithTimeout(router, timeout)
+	}
+	return router, nil
+}
+
+// staticRouter returns the router of the static provider lists, from base58
+// keys, or "*" for every key, to provider addresses.
+func staticRouter(lists map[string][]string) (routing.IpfsRouting, error) {
+	providers := make(map[key.Key][]peer.PeerInfo)
+	var all []peer.PeerInfo
+	for k, addrs := range lists {
+		var pis []peer.PeerInfo
+		for _, s := range addrs {
+			addr, err := ipfsaddr.ParseString(s)
+			if err != nil {
+				return nil, fmt.Errorf("static provider %q: %s", s, err)
+			}
+			pis = append(pis, peer.PeerInfo{
+				ID:    addr.ID(),
+				Addrs: []ma.Multiaddr{addr.Transport()},
+			})
+		}
+		if k == "*" {
+			all = append(all, pis...)
+		} else {
+			providers[key.B58KeyDecode(k)] = pis
+		}
+	}
+	return static.NewRouter(providers, all), nil
+}
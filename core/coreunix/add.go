@@ -24,6 +24,7 @@ var log = logging.Logger("coreunix")
 // datastore. Returns a key representing the root node.
 func Add(n *core.IpfsNode, r io.Reader) (string, error) {
 	// TODO more attractive function signature importer.BuildDagFromReader
+	defer n.Blockstore.PinLock().Unlock()
 
 	dagNode, err := importer.BuildDagFromReader(
 		n.DAG,
@@ -43,6 +44,8 @@ func Add(n *core.IpfsNode, r io.Reader) (string, error) {
 
 // AddR recursively adds files in |path|.
 func AddR(n *core.IpfsNode, root string) (key string, err error) {
+	defer n.Blockstore.PinLock().Unlock()
+
 	stat, err := os.Lstat(root)
 	if err != nil {
 		return "", err
@@ -77,6 +80,8 @@ func AddR(n *core.IpfsNode, root string) (key string, err error) {
 // Returns the path of the added file ("<dir hash>/filename"), the DAG node of
 // the directory, and and error if any.
 func AddWrapped(n *core.IpfsNode, r io.Reader, filename string) (string, *merkledag.Node, error) {
+	defer n.Blockstore.PinLock().Unlock()
+
 	file := files.NewReaderFile(filename, filename, ioutil.NopCloser(r), nil)
 	dir := files.NewSliceFile("", "", []files.File{file})
 	dagnode, err := addDir(n, dir)
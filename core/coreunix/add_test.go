@@ -7,9 +7,8 @@ import (
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	"github.com/ipfs/go-ipfs/core"
-	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/config"
-	"github.com/ipfs/go-ipfs/util/testutil"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
 )
 
 func TestAddRecursive(t *testing.T) {
@@ -17,14 +16,11 @@ func TestAddRecursive(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	r := &repo.Mock{
-		C: config.Config{
-			Identity: config.Identity{
-				PeerID: "Qmfoo", // required by offline node
-			},
+	r := mockrepo.New(config.Config{
+		Identity: config.Identity{
+			PeerID: "Qmfoo", // required by offline node
 		},
-		D: testutil.ThreadSafeCloserMapDatastore(),
-	}
+	})
 	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,38 @@
+package coreunix
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	core "github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uarchive "github.com/ipfs/go-ipfs/unixfs/archive"
+)
+
+var ErrCompressDir = errors.New("a directory can only be compressed as an archive")
+
+// Get returns the file or directory at the ipfs or ipns path pstr as a tar
+// stream, which keeps the directory structure, compressed with gzip at the
+// given level unless it is gzip.NoCompression. When compressing without
+// archive, a file is compressed on its own rather than in a tar.
+func Get(ctx context.Context, n *core.IpfsNode, pstr string, archive bool, compression int) (io.Reader, error) {
+	p := path.Path(pstr)
+	dn, err := core.Resolve(ctx, n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if !archive && compression != gzip.NoCompression {
+		pbn, err := ft.FromBytes(dn.Data)
+		if err != nil {
+			return nil, err
+		}
+		if pbn.GetType() == ft.TDirectory {
+			return nil, ErrCompressDir
+		}
+	}
+	return uarchive.DagArchive(ctx, dn, p.String(), n.DAG, archive, compression)
+}
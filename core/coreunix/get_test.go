@@ -0,0 +1,93 @@
+package coreunix
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/repo/config"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
+)
+
+func TestGet(t *testing.T) {
+	here, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mockrepo.New(config.Config{
+		Identity: config.Identity{
+			PeerID: "Qmfoo", // required by offline node
+		},
+	})
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := AddR(node, path.Join(here, "test_data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	p := "/ipfs/" + k
+
+	out, err := Get(ctx, node, p, true, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzr, err := gzip.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gzr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	expect := []string{k, k + "/colors", k + "/colors/orange", k + "/corps", k + "/corps/apple", k + "/fruits", k + "/fruits/apple", k + "/fruits/orange"}
+	if len(names) != len(expect) {
+		t.Fatalf("got %q, expected %q", names, expect)
+	}
+	for i := range names {
+		if names[i] != expect[i] {
+			t.Fatalf("got %q, expected %q", names, expect)
+		}
+	}
+
+	if _, err := Get(ctx, node, p, false, gzip.BestSpeed); err != ErrCompressDir {
+		t.Fatalf("expected ErrCompressDir, got %v", err)
+	}
+
+	out, err = Get(ctx, node, p+"/colors/orange", false, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gzr, err = gzip.NewReader(out); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gzr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orange, err := ioutil.ReadFile(path.Join(here, "test_data", "colors", "orange"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(orange) {
+		t.Fatalf("got %q, expected %q", b, orange)
+	}
+}
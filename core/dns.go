@@ -0,0 +1,28 @@
+package core
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// ResolveDNS resolves the DNS link of domain, looking its TXT records up
+// with lookup, as the name system of a node built with the same DNS
+// settings does for the gateway. A nil lookup uses the system resolver.
+// Unless recursive, only the link of domain is followed, so the result may
+// be another /ipns/ or /dns/ path.
+func ResolveDNS(ctx context.Context, lookup namesys.LookupTXTFunc, domain string, recursive bool) (path.Path, error) {
+	resolver := namesys.NewDNSResolverWithLookup(lookup)
+
+	depth := 1
+	if recursive {
+		depth = namesys.DefaultDepthLimit
+	}
+	p, err := namesys.Resolve(ctx, resolver, domain, namesys.ResolveOpts{Depth: depth})
+	if err == namesys.ErrResolveRecursion && !recursive {
+		// stopping after the first link is what was asked for
+		err = nil
+	}
+	return p, err
+}
@@ -0,0 +1,45 @@
+package core_test
+
+import (
+	"fmt"
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	core "github.com/ipfs/go-ipfs/core"
+)
+
+func TestResolveDNS(t *testing.T) {
+	entries := map[string][]string{
+		"ipfs.example.com": {"dnslink=/ipns/example.com"},
+		"example.com":      {"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"},
+	}
+	lookup := func(ctx context.Context, name string) ([]string, error) {
+		txt, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("no TXT entry for %s", name)
+		}
+		return txt, nil
+	}
+	ctx := context.Background()
+
+	p, err := core.ResolveDNS(ctx, lookup, "ipfs.example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "/ipns/example.com" {
+		t.Fatalf("expected the first link only, got %s", p)
+	}
+
+	p, err = core.ResolveDNS(ctx, lookup, "ipfs.example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD" {
+		t.Fatalf("expected the ipfs path, got %s", p)
+	}
+
+	if _, err := core.ResolveDNS(ctx, lookup, "missing.example.com", true); err == nil {
+		t.Fatal("expected resolving a name without a link to fail")
+	}
+}
@@ -3,8 +3,6 @@ package coremock
 import (
 	"net"
 
-	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
-	syncds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	commands "github.com/ipfs/go-ipfs/commands"
@@ -13,9 +11,8 @@ import (
 	host "github.com/ipfs/go-ipfs/p2p/host"
 	mocknet "github.com/ipfs/go-ipfs/p2p/net/mock"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
-	"github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
-	ds2 "github.com/ipfs/go-ipfs/util/datastore2"
+	mockrepo "github.com/ipfs/go-ipfs/repo/mock"
 	testutil "github.com/ipfs/go-ipfs/util/testutil"
 )
 
@@ -50,10 +47,7 @@ func MockCmdsCtx() (commands.Context, error) {
 		},
 	}
 
-	r := &repo.Mock{
-		D: ds2.CloserWrap(syncds.MutexWrap(datastore.NewMapDatastore())),
-		C: conf,
-	}
+	r := mockrepo.New(conf)
 
 	node, err := core.NewNode(context.Background(), &core.BuildCfg{
 		Repo: r,
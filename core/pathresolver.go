@@ -7,6 +7,7 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
 )
 
@@ -22,36 +23,46 @@ var ErrNoNamesys = errors.New(
 func Resolve(ctx context.Context, n *IpfsNode, p path.Path) (*merkledag.Node, error) {
 	if strings.HasPrefix(p.String(), "/ipns/") {
 		// resolve ipns paths
-
-		// TODO(cryptix): we sould be able to query the local cache for the path
 		if n.Namesys == nil {
 			return nil, ErrNoNamesys
 		}
 
-		seg := p.Segments()
-
-		if len(seg) < 2 || seg[1] == "" { // just "/<protocol/>" without further segments
-			return nil, path.ErrNoComponents
-		}
-
-		extensions := seg[2:]
-		resolvable, err := path.FromSegments("/", seg[0], seg[1])
+		var err error
+		p, err = namesys.Resolve(ctx, n.Namesys, p.String(), namesys.ResolveOpts{})
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		respath, err := n.Namesys.Resolve(ctx, resolvable.String())
-		if err != nil {
-			return nil, err
-		}
+	// ok, we have an ipfs path now (or what we'll treat as one)
+	return n.Resolver.ResolvePath(ctx, p)
+}
 
-		segments := append(respath.Segments(), extensions...)
-		p, err = path.FromSegments("/", segments...)
-		if err != nil {
-			return nil, err
+// ResolvePath resolves the /ipfs/ or /ipns/ path p to the /ipfs/ path of
+// the object it names. Unless recursive, an /ipns/ path is only resolved
+// one name further, to whatever that name points at, which may be another
+// /ipns/ path. Resolving without the cache is up to ctx, see
+// namesys.WithoutCache.
+func ResolvePath(ctx context.Context, n *IpfsNode, p path.Path, recursive bool) (path.Path, error) {
+	if strings.HasPrefix(p.String(), "/ipns/") && !recursive {
+		if n.Namesys == nil {
+			return "", ErrNoNamesys
+		}
+		np, err := namesys.Resolve(ctx, n.Namesys, p.String(), namesys.ResolveOpts{Depth: 1})
+		if err == namesys.ErrResolveRecursion {
+			// stopping after one name is what was asked for
+			err = nil
 		}
+		return np, err
 	}
 
-	// ok, we have an ipfs path now (or what we'll treat as one)
-	return n.Resolver.ResolvePath(ctx, p)
+	nd, err := Resolve(ctx, n, p)
+	if err != nil {
+		return "", err
+	}
+	k, err := nd.Key()
+	if err != nil {
+		return "", err
+	}
+	return path.FromKey(k), nil
 }
@@ -5,6 +5,7 @@ import (
 
 	core "github.com/ipfs/go-ipfs/core"
 	coremock "github.com/ipfs/go-ipfs/core/mock"
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 )
 
@@ -29,3 +30,37 @@ func TestResolveNoComponents(t *testing.T) {
 		t.Fatal("Should error with invalid path.", err)
 	}
 }
+
+func TestResolvePath(t *testing.T) {
+	n, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child := &merkledag.Node{Data: []byte("child")}
+	root := new(merkledag.Node)
+	if err := root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*merkledag.Node{child, root} {
+		if _, err := n.DAG.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rk, err := root.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck, err := child.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := core.ResolvePath(n.Context(), n, path.Path(path.FromKey(rk).String()+"/child"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != path.FromKey(ck) {
+		t.Fatalf("expected %s, got %s", path.FromKey(ck), p)
+	}
+}
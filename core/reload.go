@@ -0,0 +1,65 @@
+package core
+
+import (
+	"reflect"
+
+	mamask "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/whyrusleeping/multiaddr-filter"
+	swarm "github.com/ipfs/go-ipfs/p2p/net/swarm"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// watchConfig applies the changes to the config that can take effect while
+// the node runs, for as long as it runs: the swarm address filters, and the
+// bitswap limits and strategy.
+func (n *IpfsNode) watchConfig() {
+	cancel := repo.NotifyConfig(n.Repo, n.configChanged)
+	go func() {
+		<-n.proc.Closing()
+		cancel()
+	}()
+}
+
+func (n *IpfsNode) configChanged(old, updated *config.Config) {
+	if !reflect.DeepEqual(old.Swarm.AddrFilters, updated.Swarm.AddrFilters) {
+		n.updateAddrFilters(old.Swarm.AddrFilters, updated.Swarm.AddrFilters)
+	}
+	if !reflect.DeepEqual(old.Bitswap, updated.Bitswap) {
+		log.Info("Bitswap config changed, applying it")
+		if err := n.setupBitswap(); err != nil {
+			log.Errorf("applying the Bitswap config: %s", err)
+		}
+	}
+}
+
+// updateAddrFilters removes the swarm's filters that were dropped from the
+// config, and adds the new ones. The filters added with 'ipfs swarm filters
+// add' are kept.
+func (n *IpfsNode) updateAddrFilters(old, updated []string) {
+	snet, ok := n.PeerHost.Network().(*swarm.Network)
+	if !ok {
+		return
+	}
+	log.Info("Swarm.AddrFilters changed, applying them")
+
+	keep := make(map[string]bool)
+	for _, s := range updated {
+		keep[s] = true
+	}
+	for _, s := range old {
+		if keep[s] {
+			continue
+		}
+		if f, err := mamask.NewMask(s); err == nil {
+			snet.Filters.Remove(f)
+		}
+	}
+	for _, s := range updated {
+		f, err := mamask.NewMask(s)
+		if err != nil {
+			log.Errorf("incorrectly formatted address filter in config: %s", s)
+			continue
+		}
+		snet.Filters.AddDialFilter(f)
+	}
+}
@@ -80,6 +80,9 @@ func New(parent context.Context, p peer.ID, network bsnet.BitSwapNetwork,
 		newBlocks:     make(chan *blocks.Block, HasBlockBufferSize),
 		provideKeys:   make(chan key.Key, provideKeysBufferSize),
 		wm:            NewWantManager(ctx, network),
+		sessions:      make(map[*session]struct{}),
+		limiter:       newRateLimiter(),
+		wantRefs:      make(map[key.Key]int),
 	}
 	go bs.wm.Run()
 	network.SetDelegate(bs)
@@ -128,9 +131,18 @@ type Bitswap struct {
 
 	provideKeys chan key.Key
 
+	limiter *rateLimiter
+
+	wantLk   sync.Mutex
+	wantRefs map[key.Key]int // number of requests waiting on each key
+
+	sessLk   sync.Mutex
+	sessions map[*session]struct{}
+
 	counterLk      sync.Mutex
 	blocksRecvd    int
 	dupBlocksRecvd int
+	dataRecvd      uint64
 	dupDataRecvd   uint64
 }
 
@@ -189,6 +201,30 @@ func (bs *Bitswap) WantlistForPeer(p peer.ID) []key.Key {
 	return out
 }
 
+// LedgerForPeer returns a summary of the data exchanged with p, or nil if
+// there has been no exchange with p.
+func (bs *Bitswap) LedgerForPeer(p peer.ID) *decision.Receipt {
+	return bs.engine.LedgerForPeer(p)
+}
+
+// SetStrategy changes the strategy bitswap uses to share its upload among
+// the peers asking it for blocks.
+func (bs *Bitswap) SetStrategy(s decision.Strategy) {
+	bs.engine.SetStrategy(s)
+}
+
+// PartnerWantlists returns the wantlists of all partners that want at least
+// one block from the local peer.
+func (bs *Bitswap) PartnerWantlists() map[peer.ID][]key.Key {
+	out := make(map[peer.ID][]key.Key)
+	for _, p := range bs.engine.Peers() {
+		if wl := bs.WantlistForPeer(p); len(wl) > 0 {
+			out[p] = wl
+		}
+	}
+	return out
+}
+
 // GetBlocks returns a channel where the caller may receive blocks that
 // correspond to the provided |keys|. Returns an error if BitSwap is unable to
 // begin this request within the deadline enforced by the context.
@@ -208,6 +244,7 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []key.Key) (<-chan *block
 		log.Event(ctx, "Bitswap.GetBlockRequest.Start", &k)
 	}
 
+	bs.addWantRefs(keys)
 	bs.wm.WantBlocks(keys)
 
 	req := &blockRequest{
@@ -216,10 +253,62 @@ func (bs *Bitswap) GetBlocks(ctx context.Context, keys []key.Key) (<-chan *block
 	}
 	select {
 	case bs.findKeys <- req:
-		return promise, nil
 	case <-ctx.Done():
+		bs.releaseWants(keys)
 		return nil, ctx.Err()
 	}
+	return bs.releaseWhenDone(ctx, keys, promise), nil
+}
+
+// addWantRefs records that a request is waiting on ks.
+func (bs *Bitswap) addWantRefs(ks []key.Key) {
+	bs.wantLk.Lock()
+	defer bs.wantLk.Unlock()
+	for _, k := range ks {
+		bs.wantRefs[k]++
+	}
+}
+
+// releaseWants records that a request is no longer waiting on ks, and
+// cancels the wants no other request is waiting on, so peers stop working on
+// blocks nobody will read.
+func (bs *Bitswap) releaseWants(ks []key.Key) {
+	var cancel []key.Key
+	bs.wantLk.Lock()
+	for _, k := range ks {
+		bs.wantRefs[k]--
+		if bs.wantRefs[k] > 0 {
+			continue
+		}
+		delete(bs.wantRefs, k)
+		if _, ok := bs.wm.wl.Contains(k); ok {
+			cancel = append(cancel, k)
+		}
+	}
+	bs.wantLk.Unlock()
+
+	if len(cancel) > 0 {
+		log.Debugf("cancelling %d abandoned wants", len(cancel))
+		bs.wm.CancelWants(cancel)
+	}
+}
+
+// releaseWhenDone forwards the blocks from promise, and releases the wants
+// for ks once they have all arrived or ctx is cancelled.
+func (bs *Bitswap) releaseWhenDone(ctx context.Context, ks []key.Key, promise <-chan *blocks.Block) <-chan *blocks.Block {
+	out := make(chan *blocks.Block)
+	go func() {
+		defer close(out)
+		defer bs.releaseWants(ks)
+		for blk := range promise {
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
 }
 
 // CancelWant removes a given key from the wantlist
@@ -304,6 +393,15 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 		return
 	}
 
+	// hold off reading more from p until the blocks fit the download limits
+	var size int
+	for _, b := range iblocks {
+		size += len(b.Data)
+	}
+	if err := bs.limiter.wait(ctx, p, size, false); err != nil {
+		return
+	}
+
 	// quickly send out cancels, reduces chances of duplicate block receives
 	var keys []key.Key
 	for _, block := range iblocks {
@@ -314,6 +412,7 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 		keys = append(keys, block.Key())
 	}
 	bs.wm.CancelWants(keys)
+	bs.sessionsReceived(p, keys)
 
 	wg := sync.WaitGroup{}
 	for _, block := range iblocks {
@@ -337,6 +436,19 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 	wg.Wait()
 }
 
+// sessionsReceived lets the open sessions know p sent the given blocks.
+func (bs *Bitswap) sessionsReceived(p peer.ID, ks []key.Key) {
+	if len(ks) == 0 {
+		return
+	}
+
+	bs.sessLk.Lock()
+	defer bs.sessLk.Unlock()
+	for s := range bs.sessions {
+		s.receivedFrom(p, ks)
+	}
+}
+
 var ErrAlreadyHaveBlock = errors.New("already have block")
 
 func (bs *Bitswap) updateReceiveCounters(b *blocks.Block) error {
@@ -348,10 +460,12 @@ func (bs *Bitswap) updateReceiveCounters(b *blocks.Block) error {
 		log.Infof("blockstore.Has error: %s", err)
 		return err
 	}
+	bs.dataRecvd += uint64(len(b.Data))
 	if err == nil && has {
 		bs.dupBlocksRecvd++
 		bs.dupDataRecvd += uint64(len(b.Data))
 	}
+	bs.wm.dups.record(has)
 
 	if has {
 		return ErrAlreadyHaveBlock
@@ -368,6 +482,7 @@ func (bs *Bitswap) PeerConnected(p peer.ID) {
 func (bs *Bitswap) PeerDisconnected(p peer.ID) {
 	bs.wm.Disconnected(p)
 	bs.engine.PeerDisconnected(p)
+	bs.limiter.forget(p)
 }
 
 func (bs *Bitswap) ReceiveError(err error) {
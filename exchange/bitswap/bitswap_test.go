@@ -283,3 +283,46 @@ func TestBasicBitswap(t *testing.T) {
 		}
 	}
 }
+
+func TestCancelledRequestCancelsWants(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(2)
+	a, b := instances[0], instances[1]
+	blks := bg.Blocks(1)
+	k := blks[0].Key()
+
+	// two requests for the same block; the want lasts as long as either
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	if _, err := a.Exchange.GetBlocks(ctx1, []key.Key{k}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Exchange.GetBlocks(ctx2, []key.Key{k}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor := func(want bool) {
+		for i := 0; i < 100; i++ {
+			if wantlistHas(b.Exchange.WantlistForPeer(a.Peer), k) == want {
+				return
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		t.Fatalf("expected peer's wantlist to contain the key: %v", want)
+	}
+
+	waitFor(true)
+	cancel1()
+	time.Sleep(time.Millisecond * 50)
+	waitFor(true)
+	cancel2()
+	waitFor(false)
+
+	if wantlistHas(a.Exchange.GetWantlist(), k) {
+		t.Fatal("cancelled want is still on the local wantlist")
+	}
+}
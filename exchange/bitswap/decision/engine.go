@@ -108,6 +108,36 @@ func (e *Engine) WantlistForPeer(p peer.ID) (out []wl.Entry) {
 	return out
 }
 
+// Receipt summarizes the data exchanged with a partner.
+type Receipt struct {
+	Peer      string
+	Value     float64 // ratio of bytes sent to bytes received
+	Sent      uint64
+	Recv      uint64
+	Exchanged uint64 // number of blocks exchanged either way
+	Wants     int    // number of keys on the partner's wantlist
+}
+
+// LedgerForPeer returns a summary of the ledger kept for p, or nil if no data
+// has been exchanged with p.
+func (e *Engine) LedgerForPeer(p peer.ID) *Receipt {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	l, ok := e.ledgerMap[p]
+	if !ok {
+		return nil
+	}
+	return &Receipt{
+		Peer:      p.Pretty(),
+		Value:     l.Accounting.Value(),
+		Sent:      l.Accounting.BytesSent,
+		Recv:      l.Accounting.BytesRecv,
+		Exchanged: l.ExchangeCount(),
+		Wants:     l.wantList.Len(),
+	}
+}
+
 func (e *Engine) taskWorker(ctx context.Context) {
 	defer close(e.outbox) // because taskWorker uses the channel exclusively
 	for {
@@ -229,10 +259,12 @@ func (e *Engine) MessageReceived(p peer.ID, m bsmsg.BitSwapMessage) error {
 		for _, l := range e.ledgerMap {
 			if entry, ok := l.WantListContains(block.Key()); ok {
 				e.peerRequestQueue.Push(entry, l.Partner)
+				e.updateQueueLedger(l)
 				newWorkExists = true
 			}
 		}
 	}
+	e.updateQueueLedger(l)
 	return nil
 }
 
@@ -252,10 +284,23 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) error {
 		l.wantList.Remove(block.Key())
 		e.peerRequestQueue.Remove(block.Key(), p)
 	}
+	e.updateQueueLedger(l)
 
 	return nil
 }
 
+// updateQueueLedger lets the request queue's strategy know how much has been
+// exchanged with the partner of l. must be called with the lock held.
+func (e *Engine) updateQueueLedger(l *ledger) {
+	e.peerRequestQueue.UpdateLedger(l.Partner, l.Accounting.BytesSent, l.Accounting.BytesRecv)
+}
+
+// SetStrategy changes the strategy used to decide which partner is sent
+// blocks next, and whether they are sent what they ask for.
+func (e *Engine) SetStrategy(s Strategy) {
+	e.peerRequestQueue.SetStrategy(s)
+}
+
 func (e *Engine) PeerDisconnected(p peer.ID) {
 	// TODO: release ledger
 }
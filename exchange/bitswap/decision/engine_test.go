@@ -66,6 +66,40 @@ func TestConsistentAccounting(t *testing.T) {
 	}
 }
 
+func TestLedgerForPeer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sender := newEngine(ctx, "Ernie")
+	receiver := newEngine(ctx, "Bert")
+
+	if r := sender.Engine.LedgerForPeer(receiver.Peer); r != nil {
+		t.Fatalf("expected no ledger before any exchange, got %v", r)
+	}
+
+	m := message.New(false)
+	m.AddBlock(blocks.NewBlock([]byte("hello")))
+	m.AddBlock(blocks.NewBlock([]byte("world!")))
+	sender.Engine.MessageSent(receiver.Peer, m)
+
+	want := message.New(false)
+	want.AddEntry(blocks.NewBlock([]byte("wanted")).Key(), 1)
+	sender.Engine.MessageReceived(receiver.Peer, want)
+
+	r := sender.Engine.LedgerForPeer(receiver.Peer)
+	if r == nil {
+		t.Fatal("expected a ledger")
+	}
+	if r.Sent != 11 || r.Recv != 0 {
+		t.Fatalf("expected 11 bytes sent and none received, got %d and %d", r.Sent, r.Recv)
+	}
+	if r.Exchanged != 2 {
+		t.Fatalf("expected 2 exchanges, got %d", r.Exchanged)
+	}
+	if r.Wants != 1 {
+		t.Fatalf("expected 1 key on the partner's wantlist, got %d", r.Wants)
+	}
+}
+
 func TestPeerIsAddedToPeersWhenMessageReceivedOrSent(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
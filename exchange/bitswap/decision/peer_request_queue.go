@@ -15,29 +15,40 @@ type peerRequestQueue interface {
 	Pop() *peerRequestTask
 	Push(entry wantlist.Entry, to peer.ID)
 	Remove(k key.Key, p peer.ID)
+	// UpdateLedger records the bytes exchanged with p, for the strategy.
+	UpdateLedger(p peer.ID, sent, recv uint64)
+	// SetStrategy changes the strategy used to order the partners.
+	SetStrategy(s Strategy)
 	// NB: cannot expose simply expose taskQueue.Len because trashed elements
 	// may exist. These trashed elements should not contribute to the count.
 }
 
 func newPRQ() peerRequestQueue {
-	return &prq{
+	return newPRQWithStrategy(RoundRobin)
+}
+
+func newPRQWithStrategy(s Strategy) *prq {
+	tl := &prq{
 		taskMap:  make(map[string]*peerRequestTask),
 		partners: make(map[peer.ID]*activePartner),
-		pQueue:   pq.New(partnerCompare),
+		strategy: s,
 	}
+	tl.pQueue = pq.New(tl.partnerCompare)
+	return tl
 }
 
 // verify interface implementation
 var _ peerRequestQueue = &prq{}
 
-// TODO: at some point, the strategy needs to plug in here
-// to help decide how to sort tasks (on add) and how to select
-// tasks (on getnext). For now, we are assuming a dumb/nice strategy.
+// prq orders tasks by partner, leaving the choice of which partner to serve
+// next, and whether to send it what it asked for, to a Strategy.
 type prq struct {
 	lock     sync.Mutex
 	pQueue   pq.PQ
 	taskMap  map[string]*peerRequestTask
 	partners map[peer.ID]*activePartner
+	strategy Strategy
+	served   uint64 // number of tasks handed out
 }
 
 // Push currently adds a new peerRequestTask to the end of the list
@@ -46,7 +57,7 @@ func (tl *prq) Push(entry wantlist.Entry, to peer.ID) {
 	defer tl.lock.Unlock()
 	partner, ok := tl.partners[to]
 	if !ok {
-		partner = newActivePartner()
+		partner = newActivePartner(to)
 		tl.pQueue.Push(partner)
 		tl.partners[to] = partner
 	}
@@ -86,27 +97,68 @@ func (tl *prq) Push(entry wantlist.Entry, to peer.ID) {
 func (tl *prq) Pop() *peerRequestTask {
 	tl.lock.Lock()
 	defer tl.lock.Unlock()
-	if tl.pQueue.Len() == 0 {
-		return nil
+
+	// each partner is tried at most once, in case the strategy declines to
+	// send anything to the first
+	for i := tl.pQueue.Len(); i > 0; i-- {
+		partner := tl.pQueue.Pop().(*activePartner)
+		out := tl.popTask(partner)
+		tl.pQueue.Push(partner)
+		if out != nil {
+			return out
+		}
 	}
-	partner := tl.pQueue.Pop().(*activePartner)
+	return nil
+}
 
-	var out *peerRequestTask
+// popTask returns the next task for partner that the strategy agrees to
+// send, or nil if there is none. partner must be out of the pQueue.
+func (tl *prq) popTask(partner *activePartner) *peerRequestTask {
 	for partner.taskQueue.Len() > 0 {
-		out = partner.taskQueue.Pop().(*peerRequestTask)
+		out := partner.taskQueue.Pop().(*peerRequestTask)
 		delete(tl.taskMap, out.Key())
 		if out.trash {
-			out = nil
 			continue // discarding tasks that have been removed
 		}
 
-		partner.StartTask(out.Entry.Key)
 		partner.requests--
-		break // and return |out|
+		if !tl.strategy.ShouldSend(partner.info()) {
+			log.Debugf("strategy declined to send %s to %s", out.Entry.Key, partner.peer)
+			continue
+		}
+
+		partner.StartTask(out.Entry.Key)
+		tl.served++
+		partner.lastServed = tl.served
+		return out
+	}
+	return nil
+}
+
+// UpdateLedger records the bytes exchanged with p, for the strategy.
+func (tl *prq) UpdateLedger(p peer.ID, sent, recv uint64) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	partner, ok := tl.partners[p]
+	if !ok {
+		return
 	}
+	partner.sent, partner.recv = sent, recv
+	tl.pQueue.Update(partner.Index())
+}
+
+// SetStrategy changes the strategy, reordering the partners by it.
+func (tl *prq) SetStrategy(s Strategy) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
 
-	tl.pQueue.Push(partner)
-	return out
+	tl.strategy = s
+	old := tl.pQueue
+	tl.pQueue = pq.New(tl.partnerCompare)
+	for old.Len() > 0 {
+		tl.pQueue.Push(old.Pop())
+	}
 }
 
 // Remove removes a task from the queue
@@ -180,6 +232,14 @@ func wrapCmp(f func(a, b *peerRequestTask) bool) func(a, b pq.Elem) bool {
 }
 
 type activePartner struct {
+	peer peer.ID
+
+	// bytes exchanged with the peer, as last recorded by the engine
+	sent, recv uint64
+
+	// lastServed is the value of the queue's served count when a task was
+	// last handed out for this peer
+	lastServed uint64
 
 	// Active is the number of blocks this peer is currently being sent
 	// active must be locked around as it will be updated externally
@@ -200,15 +260,16 @@ type activePartner struct {
 	taskQueue pq.PQ
 }
 
-func newActivePartner() *activePartner {
+func newActivePartner(p peer.ID) *activePartner {
 	return &activePartner{
+		peer:         p,
 		taskQueue:    pq.New(wrapCmp(V1)),
 		activeBlocks: make(map[key.Key]struct{}),
 	}
 }
 
 // partnerCompare implements pq.ElemComparator
-func partnerCompare(a, b pq.Elem) bool {
+func (tl *prq) partnerCompare(a, b pq.Elem) bool {
 	pa := a.(*activePartner)
 	pb := b.(*activePartner)
 
@@ -220,13 +281,19 @@ func partnerCompare(a, b pq.Elem) bool {
 	if pb.requests == 0 {
 		return true
 	}
-	if pa.active == pb.active {
-		// sorting by taskQueue.Len() aids in cleaning out trash entries faster
-		// if we sorted instead by requests, one peer could potentially build up
-		// a huge number of cancelled entries in the queue resulting in a memory leak
-		return pa.taskQueue.Len() > pb.taskQueue.Len()
+	return tl.strategy.Less(pa.info(), pb.info())
+}
+
+// info describes the partner for the strategy.
+func (p *activePartner) info() Partner {
+	return Partner{
+		Peer:       p.peer,
+		Active:     p.active,
+		Requests:   p.requests,
+		Sent:       p.sent,
+		Recv:       p.recv,
+		LastServed: p.lastServed,
 	}
-	return pa.active < pb.active
 }
 
 // StartTask signals that a task was started for this partner
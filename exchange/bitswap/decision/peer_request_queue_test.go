@@ -1,6 +1,7 @@
 package decision
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 	"sort"
@@ -117,3 +118,56 @@ func TestPeerRepeats(t *testing.T) {
 		}
 	}
 }
+
+func TestDebtRatioStrategy(t *testing.T) {
+	prq := newPRQWithStrategy(DebtRatio)
+	generous := testutil.RandPeerIDFatal(t)
+	leech := testutil.RandPeerIDFatal(t)
+
+	for i := 0; i < 5; i++ {
+		prq.Push(wantlist.Entry{Key: key.Key(fmt.Sprint(i))}, leech)
+		prq.Push(wantlist.Entry{Key: key.Key(fmt.Sprint(i))}, generous)
+	}
+	prq.UpdateLedger(leech, 1000, 0)
+	prq.UpdateLedger(generous, 1000, 1000)
+
+	// the leech is far enough in debt never to be sent anything
+	for i := 0; i < 5; i++ {
+		task := prq.Pop()
+		if task == nil || task.Target != generous {
+			t.Fatalf("expected a task for the generous peer, got %v", task)
+		}
+		task.Done()
+	}
+	if task := prq.Pop(); task != nil {
+		t.Fatalf("expected the leech's tasks to be dropped, got %v", task)
+	}
+}
+
+func TestSetStrategy(t *testing.T) {
+	prq := newPRQ()
+	a := testutil.RandPeerIDFatal(t)
+	b := testutil.RandPeerIDFatal(t)
+
+	prq.Push(wantlist.Entry{Key: "1"}, a)
+	prq.Push(wantlist.Entry{Key: "1"}, b)
+	prq.UpdateLedger(a, 100, 0)
+	prq.UpdateLedger(b, 0, 100)
+
+	prq.SetStrategy(DebtRatio)
+	if task := prq.Pop(); task == nil || task.Target != b {
+		t.Fatalf("expected the peer with the lower debt ratio first, got %v", task)
+	}
+}
+
+func TestStrategyByName(t *testing.T) {
+	if s, err := StrategyByName(""); err != nil || s != RoundRobin {
+		t.Fatal("expected round robin by default")
+	}
+	if s, err := StrategyByName("debtratio"); err != nil || s != DebtRatio {
+		t.Fatal("expected the debt ratio strategy")
+	}
+	if _, err := StrategyByName("greedy"); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
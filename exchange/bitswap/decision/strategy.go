@@ -0,0 +1,102 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+// Partner describes a peer with blocks queued for it, as seen by a Strategy.
+type Partner struct {
+	Peer     peer.ID
+	Active   int    // number of blocks being sent to the peer
+	Requests int    // number of blocks queued for the peer
+	Sent     uint64 // bytes sent to the peer
+	Recv     uint64 // bytes received from the peer
+
+	// LastServed orders the partners by when a block was last taken from
+	// their queue. Larger is more recent, zero is never.
+	LastServed uint64
+}
+
+// DebtRatio is the ratio of bytes sent to the peer to bytes received from
+// it.
+func (p Partner) DebtRatio() float64 {
+	return float64(p.Sent) / float64(p.Recv+1)
+}
+
+// Strategy decides how the engine shares its upload among partners.
+type Strategy interface {
+	// Less returns whether a should be served before b.
+	Less(a, b Partner) bool
+
+	// ShouldSend returns whether to send the next block queued for p.
+	// Blocks that aren't sent are dropped from the queue; the partner will
+	// ask for them again with its next full wantlist.
+	ShouldSend(p Partner) bool
+}
+
+// RoundRobin serves the partners in turn, favoring those with the fewest
+// blocks in flight, and sends every block asked for.
+var RoundRobin Strategy = roundRobin{}
+
+type roundRobin struct{}
+
+func (roundRobin) Less(a, b Partner) bool {
+	if a.Active != b.Active {
+		return a.Active < b.Active
+	}
+	return a.LastServed < b.LastServed
+}
+
+func (roundRobin) ShouldSend(p Partner) bool {
+	return true
+}
+
+// DebtRatio serves the partners that have sent the most in return for what
+// they were sent first. It always sends blocks to partners that have sent
+// as much as they were sent, and becomes less likely to the further in debt
+// a partner gets.
+var DebtRatio Strategy = debtRatioStrategy{}
+
+type debtRatioStrategy struct{}
+
+func (debtRatioStrategy) Less(a, b Partner) bool {
+	ra, rb := a.DebtRatio(), b.DebtRatio()
+	if ra != rb {
+		return ra < rb
+	}
+	return RoundRobin.Less(a, b)
+}
+
+func (debtRatioStrategy) ShouldSend(p Partner) bool {
+	r := p.DebtRatio()
+	if r <= 1 {
+		return true
+	}
+	// a sigmoid that is still close to 1 at a ratio of one, and falls off
+	// sharply after two
+	chance := 1 - (1 / (1 + math.Exp(6-3*r)))
+	return rand.Float64() < chance
+}
+
+// Strategies lists the strategies by the names used to configure them.
+var Strategies = map[string]Strategy{
+	"roundrobin": RoundRobin,
+	"debtratio":  DebtRatio,
+}
+
+// StrategyByName returns the named strategy. The empty name selects
+// RoundRobin.
+func StrategyByName(name string) (Strategy, error) {
+	if name == "" {
+		return RoundRobin, nil
+	}
+	s, ok := Strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bitswap strategy: %q", name)
+	}
+	return s, nil
+}
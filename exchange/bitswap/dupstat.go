@@ -0,0 +1,74 @@
+package bitswap
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+const (
+	// dupRateWeight is the weight of each received block in the moving
+	// average of the duplicate rate.
+	dupRateWeight = 0.05
+
+	// minWantFanout is the fewest peers new wants are broadcast to, when
+	// that many are connected.
+	minWantFanout = 3
+)
+
+// dupTracker keeps a moving average of how many of the blocks received were
+// duplicates, to adapt how widely wants are broadcast. When most blocks
+// arrive from several peers at once, the content is popular enough that
+// asking fewer peers still finds it, with less wasted bandwidth.
+type dupTracker struct {
+	lk   sync.Mutex
+	rate float64
+}
+
+// record adds a received block to the average.
+func (d *dupTracker) record(dup bool) {
+	v := 0.0
+	if dup {
+		v = 1
+	}
+
+	d.lk.Lock()
+	d.rate += dupRateWeight * (v - d.rate)
+	d.lk.Unlock()
+}
+
+// Rate returns the recent fraction of received blocks that were duplicates.
+func (d *dupTracker) Rate() float64 {
+	d.lk.Lock()
+	defer d.lk.Unlock()
+	return d.rate
+}
+
+// fanout returns how many of n peers new wants should be sent to.
+func (d *dupTracker) fanout(n int) int {
+	f := int(math.Ceil(float64(n) * (1 - d.Rate())))
+	if f < minWantFanout {
+		f = minWantFanout
+	}
+	if f > n {
+		f = n
+	}
+	return f
+}
+
+// pickPeers returns a random subset of the given peers of the size fanout
+// calls for.
+func (d *dupTracker) pickPeers(peers []peer.ID) []peer.ID {
+	f := d.fanout(len(peers))
+	if f == len(peers) {
+		return peers
+	}
+
+	out := make([]peer.ID, 0, f)
+	for _, i := range rand.Perm(len(peers))[:f] {
+		out = append(out, peers[i])
+	}
+	return out
+}
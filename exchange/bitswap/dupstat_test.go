@@ -0,0 +1,54 @@
+package bitswap
+
+import (
+	"testing"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+func TestDupTrackerFanout(t *testing.T) {
+	d := new(dupTracker)
+	if f := d.fanout(20); f != 20 {
+		t.Fatalf("expected wants to go to every peer without duplicates, got %d", f)
+	}
+
+	for i := 0; i < 200; i++ {
+		d.record(true)
+	}
+	if r := d.Rate(); r < 0.99 {
+		t.Fatalf("expected a duplicate rate close to 1, got %f", r)
+	}
+	if f := d.fanout(20); f != minWantFanout {
+		t.Fatalf("expected the minimum fanout, got %d", f)
+	}
+	if f := d.fanout(2); f != 2 {
+		t.Fatalf("fanout can't exceed the number of peers, got %d", f)
+	}
+
+	for i := 0; i < 200; i++ {
+		d.record(false)
+	}
+	if f := d.fanout(20); f != 20 {
+		t.Fatalf("expected the fanout to recover, got %d", f)
+	}
+}
+
+func TestDupTrackerPickPeers(t *testing.T) {
+	d := &dupTracker{rate: 0.5}
+	var peers []peer.ID
+	for _, p := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		peers = append(peers, peer.ID(p))
+	}
+
+	picked := d.pickPeers(peers)
+	if len(picked) != 4 {
+		t.Fatalf("expected half the peers, got %d", len(picked))
+	}
+	seen := make(map[peer.ID]bool)
+	for _, p := range picked {
+		if seen[p] {
+			t.Fatalf("peer %s picked twice", p)
+		}
+		seen[p] = true
+	}
+}
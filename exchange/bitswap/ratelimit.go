@@ -0,0 +1,151 @@
+package bitswap
+
+import (
+	"sync"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+// RateLimits caps the bandwidth bitswap spends on blocks, in bytes per
+// second. A limit of zero means unlimited.
+type RateLimits struct {
+	Upload       uint64 // blocks sent to all peers
+	Download     uint64 // blocks received from all peers
+	PeerUpload   uint64 // blocks sent to each peer
+	PeerDownload uint64 // blocks received from each peer
+}
+
+// bucket is a token bucket refilled at rate bytes per second, holding at
+// most a second's worth of tokens. Taking more tokens than the bucket holds
+// leaves it in debt, which later takers wait to be repaid.
+type bucket struct {
+	lk     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate uint64) *bucket {
+	return &bucket{rate: float64(rate), last: time.Now()}
+}
+
+// take removes n tokens and returns how long to wait before using them.
+func (b *bucket) take(n int) time.Duration {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if b.rate == 0 {
+		return 0
+	}
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+var unlimited = newBucket(0)
+
+// rateLimiter applies RateLimits to the blocks sent and received.
+type rateLimiter struct {
+	lk     sync.Mutex
+	limits RateLimits
+	up     *bucket
+	down   *bucket
+	peerUp map[peer.ID]*bucket
+	peerDn map[peer.ID]*bucket
+}
+
+func newRateLimiter() *rateLimiter {
+	r := new(rateLimiter)
+	r.setLimits(RateLimits{})
+	return r
+}
+
+func (r *rateLimiter) setLimits(l RateLimits) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.limits = l
+	r.up = newBucket(l.Upload)
+	r.down = newBucket(l.Download)
+	r.peerUp = make(map[peer.ID]*bucket)
+	r.peerDn = make(map[peer.ID]*bucket)
+}
+
+func (r *rateLimiter) getLimits() RateLimits {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.limits
+}
+
+// buckets returns the global bucket and the bucket for p in one direction.
+func (r *rateLimiter) buckets(p peer.ID, upload bool) (*bucket, *bucket) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	global, peers, rate := r.down, r.peerDn, r.limits.PeerDownload
+	if upload {
+		global, peers, rate = r.up, r.peerUp, r.limits.PeerUpload
+	}
+
+	if rate == 0 {
+		return global, unlimited
+	}
+	pb, ok := peers[p]
+	if !ok {
+		pb = newBucket(rate)
+		peers[p] = pb
+	}
+	return global, pb
+}
+
+// wait blocks until n bytes may be sent to (or received from) p, or ctx is
+// cancelled.
+func (r *rateLimiter) wait(ctx context.Context, p peer.ID, n int, upload bool) error {
+	global, pb := r.buckets(p, upload)
+	d := global.take(n)
+	if pd := pb.take(n); pd > d {
+		d = pd
+	}
+	if d == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// forget drops the per-peer state kept for p.
+func (r *rateLimiter) forget(p peer.ID) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	delete(r.peerUp, p)
+	delete(r.peerDn, p)
+}
+
+// SetRateLimits changes the bandwidth limits, taking effect immediately.
+func (bs *Bitswap) SetRateLimits(l RateLimits) {
+	bs.limiter.setLimits(l)
+}
+
+// RateLimits returns the current bandwidth limits.
+func (bs *Bitswap) RateLimits() RateLimits {
+	return bs.limiter.getLimits()
+}
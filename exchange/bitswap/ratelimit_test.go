@@ -0,0 +1,54 @@
+package bitswap
+
+import (
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+func TestBucket(t *testing.T) {
+	b := newBucket(1000)
+
+	// the bucket starts empty
+	if d := b.take(500); d < 400*time.Millisecond || d > 500*time.Millisecond {
+		t.Fatalf("expected to wait about half a second, got %s", d)
+	}
+	if d := b.take(500); d < 900*time.Millisecond || d > time.Second {
+		t.Fatalf("expected to wait about a second, got %s", d)
+	}
+
+	if d := newBucket(0).take(1 << 30); d != 0 {
+		t.Fatalf("unlimited bucket should never wait, got %s", d)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	r := newRateLimiter()
+	ctx := context.Background()
+	a, b := peer.ID("a"), peer.ID("b")
+
+	start := time.Now()
+	if err := r.wait(ctx, a, 1<<20, true); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("no limits should not wait")
+	}
+
+	r.setLimits(RateLimits{PeerUpload: 100})
+	if r.getLimits().PeerUpload != 100 {
+		t.Fatal("limits were not set")
+	}
+
+	// a peer over its limit does not hold up the others
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := r.wait(ctx, a, 100, true); err != context.DeadlineExceeded {
+		t.Fatalf("expected to wait past the deadline, got %v", err)
+	}
+	if err := r.wait(ctx, b, 1, false); err != nil {
+		t.Fatal(err)
+	}
+}
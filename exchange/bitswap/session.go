@@ -0,0 +1,241 @@
+package bitswap
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+const (
+	// maxSessionPeers is the number of peers a session sends its wants to
+	// before falling back to a broadcast.
+	maxSessionPeers = 8
+)
+
+// sessionWantTimeout is how long a session waits on its peers for a block
+// before asking every peer and looking for providers.
+var sessionWantTimeout = time.Second * 2
+
+// session fetches blocks on behalf of a single traversal, such as the walk
+// of one dag. Blocks of the same dag tend to be held by the same peers, so a
+// session remembers which peers sent it blocks and sends its later wants to
+// them alone. Wants they don't answer within sessionWantTimeout are
+// broadcast as usual.
+type session struct {
+	bs     *Bitswap
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lk       sync.Mutex
+	peers    []peer.ID // most recently useful last
+	interest map[key.Key]struct{}
+}
+
+// NewSession returns a session that lasts until it is closed or ctx is
+// cancelled.
+func (bs *Bitswap) NewSession(ctx context.Context) exchange.Session {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &session{
+		bs:       bs,
+		ctx:      ctx,
+		cancel:   cancel,
+		interest: make(map[key.Key]struct{}),
+	}
+
+	bs.sessLk.Lock()
+	bs.sessions[s] = struct{}{}
+	bs.sessLk.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.remove()
+	}()
+	return s
+}
+
+// Close ends the session: it stops hearing about received blocks, and its
+// requests still open are cancelled.
+func (s *session) Close() error {
+	s.remove()
+	s.cancel()
+	return nil
+}
+
+func (s *session) remove() {
+	s.bs.sessLk.Lock()
+	delete(s.bs.sessions, s)
+	s.bs.sessLk.Unlock()
+}
+
+// receivedFrom records that p sent the given blocks, making p one of the
+// peers asked first if the session wanted any of them.
+func (s *session) receivedFrom(p peer.ID, ks []key.Key) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	useful := false
+	for _, k := range ks {
+		if _, ok := s.interest[k]; ok {
+			delete(s.interest, k)
+			useful = true
+		}
+	}
+	if !useful {
+		return
+	}
+
+	for i, sp := range s.peers {
+		if sp == p {
+			s.peers = append(s.peers[:i], s.peers[i+1:]...)
+			break
+		}
+	}
+	s.peers = append(s.peers, p)
+	if len(s.peers) > maxSessionPeers {
+		s.peers = s.peers[len(s.peers)-maxSessionPeers:]
+	}
+}
+
+func (s *session) addInterest(ks []key.Key) []peer.ID {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for _, k := range ks {
+		s.interest[k] = struct{}{}
+	}
+	return append([]peer.ID(nil), s.peers...)
+}
+
+func (s *session) removeInterest(ks []key.Key) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	for _, k := range ks {
+		delete(s.interest, k)
+	}
+}
+
+// GetBlock attempts to retrieve a particular block within the session.
+func (s *session) GetBlock(parent context.Context, k key.Key) (*blocks.Block, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	promise, err := s.GetBlocks(ctx, []key.Key{k})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case block, ok := <-promise:
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+				return nil, errors.New("promise channel was closed")
+			}
+		}
+		return block, nil
+	case <-parent.Done():
+		return nil, parent.Err()
+	}
+}
+
+// GetBlocks returns a channel where the caller may receive the blocks for
+// the given keys, fetched within the session. As with Bitswap.GetBlocks, the
+// request remains open until ctx is cancelled, or the session ends.
+func (s *session) GetBlocks(ctx context.Context, keys []key.Key) (<-chan *blocks.Block, error) {
+	ctx = s.bind(ctx)
+	peers := s.addInterest(keys)
+	if len(peers) == 0 {
+		// nobody to ask first, so look for the blocks the usual way
+		return s.bs.GetBlocks(ctx, keys)
+	}
+
+	select {
+	case <-s.bs.process.Closing():
+		return nil, errors.New("bitswap is closed")
+	default:
+	}
+	promise := s.bs.notifications.Subscribe(ctx, keys...)
+	s.bs.addWantRefs(keys)
+	s.bs.wm.WantBlocksFrom(keys, peers)
+
+	out := make(chan *blocks.Block)
+	go func() {
+		defer close(out)
+		defer s.bs.releaseWants(keys)
+
+		remaining := make(map[key.Key]struct{})
+		for _, k := range keys {
+			remaining[k] = struct{}{}
+		}
+		defer func() {
+			var left []key.Key
+			for k := range remaining {
+				left = append(left, k)
+			}
+			s.removeInterest(left)
+		}()
+
+		timeout := time.NewTimer(sessionWantTimeout)
+		defer timeout.Stop()
+		for {
+			select {
+			case blk, ok := <-promise:
+				if !ok {
+					return
+				}
+				delete(remaining, blk.Key())
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+					return
+				}
+			case <-timeout.C:
+				s.broadcast(ctx, remaining)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// bind returns a context cancelled along with ctx, or when the session ends.
+func (s *session) bind(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx
+}
+
+// broadcast asks every peer for the given keys, and looks for providers of
+// them, for when the session's peers don't have them.
+func (s *session) broadcast(ctx context.Context, remaining map[key.Key]struct{}) {
+	var ks []key.Key
+	for k := range remaining {
+		ks = append(ks, k)
+	}
+	if len(ks) == 0 {
+		return
+	}
+
+	log.Debugf("session peers did not send %d blocks, broadcasting", len(ks))
+	s.bs.wm.WantBlocks(ks)
+	select {
+	case s.bs.findKeys <- &blockRequest{keys: ks, ctx: ctx}:
+	case <-ctx.Done():
+	}
+}
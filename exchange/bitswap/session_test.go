@@ -0,0 +1,116 @@
+package bitswap
+
+import (
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	blocksutil "github.com/ipfs/go-ipfs/blocks/blocksutil"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	tn "github.com/ipfs/go-ipfs/exchange/bitswap/testnet"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	delay "github.com/ipfs/go-ipfs/thirdparty/delay"
+)
+
+func wantlistHas(wl []key.Key, k key.Key) bool {
+	for _, w := range wl {
+		if w == k {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSessionAsksPeersThatSentBlocks(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	instances := sg.Instances(3)
+	requester, holder, other := instances[0], instances[1], instances[2]
+
+	blks := bg.Blocks(3)
+	if err := holder.Exchange.HasBlock(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Exchange.HasBlock(blks[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	ses := requester.Exchange.NewSession(ctx)
+
+	// the first block is looked for everywhere
+	if _, err := ses.GetBlock(ctx, blks[0].Key()); err != nil {
+		t.Fatal(err)
+	}
+
+	// later wants go to the peer that sent the first block only
+	missing := blks[1].Key()
+	wctx, wcancel := context.WithTimeout(ctx, time.Millisecond*500)
+	defer wcancel()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ses.GetBlock(wctx, missing)
+		errs <- err
+	}()
+
+	// the want is cancelled once it times out, look for it before
+	sent := false
+	for !sent && wctx.Err() == nil {
+		sent = wantlistHas(holder.Exchange.WantlistForPeer(requester.Peer), missing)
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !sent {
+		t.Fatal("session peer was not sent the want")
+	}
+	if wantlistHas(other.Exchange.WantlistForPeer(requester.Peer), missing) {
+		t.Fatal("want was broadcast before the session timeout")
+	}
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Fatalf("expected the want to time out, got %v", err)
+	}
+
+	// blocks the session peers don't have are still found
+	if _, err := ses.GetBlock(ctx, blks[2].Key()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	sg := NewTestSessionGenerator(net)
+	defer sg.Close()
+	bg := blocksutil.NewBlockGenerator()
+
+	bs := sg.Instances(1)[0].Exchange
+	ses := bs.NewSession(context.Background())
+
+	// a request still open when the session is closed is cancelled
+	promise, err := ses.GetBlocks(context.Background(), []key.Key{bg.Next().Key()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ses.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bs.sessLk.Lock()
+	open := len(bs.sessions)
+	bs.sessLk.Unlock()
+	if open != 0 {
+		t.Fatalf("expected the closed session to be forgotten, %d sessions open", open)
+	}
+
+	select {
+	case _, ok := <-promise:
+		if ok {
+			t.Fatal("expected no block")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request was not cancelled by closing the session")
+	}
+}
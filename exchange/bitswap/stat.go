@@ -11,7 +11,12 @@ type Stat struct {
 	Peers           []string
 	BlocksReceived  int
 	DupBlksReceived int
+	DataReceived    uint64
 	DupDataReceived uint64
+
+	// DupRate is the recent fraction of received blocks that were
+	// duplicates. The higher it is, the fewer peers new wants are sent to.
+	DupRate float64
 }
 
 func (bs *Bitswap) Stat() (*Stat, error) {
@@ -21,8 +26,10 @@ func (bs *Bitswap) Stat() (*Stat, error) {
 	bs.counterLk.Lock()
 	st.BlocksReceived = bs.blocksRecvd
 	st.DupBlksReceived = bs.dupBlocksRecvd
+	st.DataReceived = bs.dataRecvd
 	st.DupDataReceived = bs.dupDataRecvd
 	bs.counterLk.Unlock()
+	st.DupRate = bs.wm.dups.Rate()
 
 	for _, p := range bs.engine.Peers() {
 		st.Peers = append(st.Peers, p.Pretty())
@@ -2,6 +2,7 @@ package bitswap
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
@@ -15,7 +16,7 @@ import (
 
 type WantManager struct {
 	// sync channels for Run loop
-	incoming   chan []*bsmsg.Entry
+	incoming   chan *wantSet
 	connect    chan peer.ID // notification channel for new peers connecting
 	disconnect chan peer.ID // notification channel for peers disconnecting
 
@@ -23,22 +24,36 @@ type WantManager struct {
 	peers map[peer.ID]*msgQueue
 	wl    *wantlist.ThreadSafe
 
+	// dups tracks the duplicate blocks received, to adapt how many peers
+	// new wants are broadcast to
+	dups *dupTracker
+
+	wantSeq uint32 // number of keys wanted, for nextPriority
+
 	network bsnet.BitSwapNetwork
 	ctx     context.Context
 }
 
 func NewWantManager(ctx context.Context, network bsnet.BitSwapNetwork) *WantManager {
 	return &WantManager{
-		incoming:   make(chan []*bsmsg.Entry, 10),
+		incoming:   make(chan *wantSet, 10),
 		connect:    make(chan peer.ID, 10),
 		disconnect: make(chan peer.ID, 10),
 		peers:      make(map[peer.ID]*msgQueue),
 		wl:         wantlist.NewThreadSafe(),
+		dups:       new(dupTracker),
 		network:    network,
 		ctx:        ctx,
 	}
 }
 
+// wantSet is a batch of wantlist changes. If targets is empty, the changes
+// are sent to every peer.
+type wantSet struct {
+	entries []*bsmsg.Entry
+	targets []peer.ID
+}
+
 type msgPair struct {
 	to  peer.ID
 	msg bsmsg.BitSwapMessage
@@ -64,30 +79,45 @@ type msgQueue struct {
 
 func (pm *WantManager) WantBlocks(ks []key.Key) {
 	log.Infof("want blocks: %s", ks)
-	pm.addEntries(ks, false)
+	pm.addEntries(ks, nil, false)
+}
+
+// WantBlocksFrom adds ks to the wantlist, but only tells the given peers
+// about them. Other peers learn of them with the next full wantlist.
+func (pm *WantManager) WantBlocksFrom(ks []key.Key, peers []peer.ID) {
+	log.Infof("want blocks from %d peers: %s", len(peers), ks)
+	pm.addEntries(ks, peers, false)
 }
 
 func (pm *WantManager) CancelWants(ks []key.Key) {
-	pm.addEntries(ks, true)
+	pm.addEntries(ks, nil, true)
 }
 
-func (pm *WantManager) addEntries(ks []key.Key, cancel bool) {
+func (pm *WantManager) addEntries(ks []key.Key, targets []peer.ID, cancel bool) {
 	var entries []*bsmsg.Entry
-	for i, k := range ks {
+	for _, k := range ks {
 		entries = append(entries, &bsmsg.Entry{
 			Cancel: cancel,
 			Entry: wantlist.Entry{
 				Key:      k,
-				Priority: kMaxPriority - i,
+				Priority: pm.nextPriority(),
 			},
 		})
 	}
 	select {
-	case pm.incoming <- entries:
+	case pm.incoming <- &wantSet{entries: entries, targets: targets}:
 	case <-pm.ctx.Done():
 	}
 }
 
+// nextPriority returns the priority of the next key wanted. Keys are
+// prioritized in the order they are wanted, across requests, so peers serve
+// earlier requests first.
+func (pm *WantManager) nextPriority() int {
+	seq := atomic.AddUint32(&pm.wantSeq, 1)
+	return kMaxPriority - int(seq%kMaxPriority)
+}
+
 func (pm *WantManager) SendBlock(ctx context.Context, env *engine.Envelope) {
 	// Blocks need to be sent synchronously to maintain proper backpressure
 	// throughout the network stack
@@ -209,10 +239,10 @@ func (pm *WantManager) Run() {
 	defer tock.Stop()
 	for {
 		select {
-		case entries := <-pm.incoming:
+		case ws := <-pm.incoming:
 
 			// add changes to our wantlist
-			for _, e := range entries {
+			for _, e := range ws.entries {
 				if e.Cancel {
 					pm.wl.Remove(e.Key)
 				} else {
@@ -221,8 +251,8 @@ func (pm *WantManager) Run() {
 			}
 
 			// broadcast those wantlist changes
-			for _, p := range pm.peers {
-				p.addMessage(entries)
+			for _, p := range pm.targetsFor(ws) {
+				p.addMessage(ws.entries)
 			}
 
 		case <-tock.C:
@@ -248,6 +278,30 @@ func (pm *WantManager) Run() {
 	}
 }
 
+// targetsFor returns the peers a batch of wantlist changes is sent to.
+// Cancels go to every peer. New wants go to only as many peers as the recent
+// duplicate rate calls for; the others hear of them with the next full
+// wantlist. must be called from the Run loop.
+func (pm *WantManager) targetsFor(ws *wantSet) []*msgQueue {
+	ids := ws.targets
+	if len(ids) == 0 {
+		for p := range pm.peers {
+			ids = append(ids, p)
+		}
+		if len(ws.entries) > 0 && !ws.entries[0].Cancel {
+			ids = pm.dups.pickPeers(ids)
+		}
+	}
+
+	var out []*msgQueue
+	for _, p := range ids {
+		if mq, ok := pm.peers[p]; ok {
+			out = append(out, mq)
+		}
+	}
+	return out
+}
+
 func (wm *WantManager) newMsgQueue(p peer.ID) *msgQueue {
 	mq := new(msgQueue)
 	mq.done = make(chan struct{})
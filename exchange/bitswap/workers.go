@@ -61,6 +61,10 @@ func (bs *Bitswap) taskWorker(ctx context.Context, id int) {
 					"Block":  envelope.Block.Multihash.B58String(),
 				})
 
+				if err := bs.limiter.wait(ctx, envelope.Peer, len(envelope.Block.Data), true); err != nil {
+					envelope.Sent()
+					return
+				}
 				bs.wm.SendBlock(ctx, envelope)
 			case <-ctx.Done():
 				return
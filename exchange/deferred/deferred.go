@@ -0,0 +1,152 @@
+// package deferred implements an exchange for intermittently connected
+// nodes. Blocks asked for while offline are recorded in a persistent queue,
+// and fetched once the node is next online.
+package deferred
+
+import (
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("deferred")
+
+var wantsDatastoreKey = ds.NewKey("/local/wants")
+
+// Queue is the persistent set of keys waiting to be fetched.
+type Queue struct {
+	d ds.Datastore
+}
+
+func NewQueue(d ds.Datastore) *Queue {
+	return &Queue{d: d}
+}
+
+func (q *Queue) dsKey(k key.Key) ds.Key {
+	return wantsDatastoreKey.ChildString(k.B58String())
+}
+
+// Add queues k to be fetched. Adding a key already queued does nothing.
+func (q *Queue) Add(k key.Key) error {
+	return q.d.Put(q.dsKey(k), []byte{})
+}
+
+// Remove drops k from the queue.
+func (q *Queue) Remove(k key.Key) error {
+	err := q.d.Delete(q.dsKey(k))
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Keys returns the queued keys.
+func (q *Queue) Keys() ([]key.Key, error) {
+	res, err := q.d.Query(dsq.Query{Prefix: wantsDatastoreKey.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	ks := make([]key.Key, 0, len(entries))
+	for _, e := range entries {
+		ks = append(ks, key.B58KeyDecode(ds.NewKey(e.Key).BaseNamespace()))
+	}
+	return ks, nil
+}
+
+// Drain fetches the queued keys through ex, removing each from the queue
+// once it arrives. Keys not fetched by the time ctx is done stay queued for
+// the next time.
+func (q *Queue) Drain(ctx context.Context, ex exchange.Interface) error {
+	ks, err := q.Keys()
+	if err != nil {
+		return err
+	}
+	if len(ks) == 0 {
+		return nil
+	}
+	log.Debugf("fetching %d deferred blocks", len(ks))
+
+	blks, err := ex.GetBlocks(ctx, ks)
+	if err != nil {
+		return err
+	}
+	for b := range blks {
+		if err := q.Remove(b.Key()); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Exchange returns an exchange that serves blocks from bs alone, like the
+// offline exchange, and queues those it doesn't have in q.
+func Exchange(bs blockstore.Blockstore, q *Queue) exchange.Interface {
+	return &deferredExchange{bs: bs, q: q}
+}
+
+type deferredExchange struct {
+	bs blockstore.Blockstore
+	q  *Queue
+}
+
+func (e *deferredExchange) get(k key.Key) (*blocks.Block, error) {
+	blk, err := e.bs.Get(k)
+	if err == blockstore.ErrNotFound {
+		if err := e.q.Add(k); err != nil {
+			log.Errorf("queueing %s: %s", k, err)
+		}
+	}
+	return blk, err
+}
+
+// GetBlock returns the block from the blockstore, or queues k and returns
+// an error if it isn't there.
+func (e *deferredExchange) GetBlock(_ context.Context, k key.Key) (*blocks.Block, error) {
+	return e.get(k)
+}
+
+func (e *deferredExchange) GetBlocks(ctx context.Context, ks []key.Key) (<-chan *blocks.Block, error) {
+	out := make(chan *blocks.Block)
+	go func() {
+		defer close(out)
+		for _, k := range ks {
+			hit, err := e.get(k)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					continue
+				}
+			}
+			select {
+			case out <- hit:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// HasBlock stores the block, and drops it from the queue if it was there.
+func (e *deferredExchange) HasBlock(b *blocks.Block) error {
+	if err := e.bs.Put(b); err != nil {
+		return err
+	}
+	return e.q.Remove(b.Key())
+}
+
+func (_ *deferredExchange) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package deferred
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	ds_sync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	"github.com/ipfs/go-ipfs/blocks/blocksutil"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+)
+
+func TestMissesAreQueued(t *testing.T) {
+	d := ds_sync.MutexWrap(ds.NewMapDatastore())
+	q := NewQueue(d)
+	ex := Exchange(blockstore.NewBlockstore(d), q)
+	g := blocksutil.NewBlockGenerator()
+	blks := g.Blocks(3)
+
+	if err := ex.HasBlock(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ex.GetBlock(context.Background(), blks[0].Key()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ex.GetBlock(context.Background(), blks[1].Key()); err == nil {
+		t.Fatal("expected an error for a missing block")
+	}
+	out, err := ex.GetBlocks(context.Background(), []key.Key{blks[1].Key(), blks[2].Key()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _ = range out {
+		t.Fatal("got a block that isn't stored")
+	}
+
+	ks, err := q.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 2 {
+		t.Fatalf("expected 2 queued keys, got %d", len(ks))
+	}
+	for _, k := range ks {
+		if k != blks[1].Key() && k != blks[2].Key() {
+			t.Fatalf("unexpected key queued: %s", k)
+		}
+	}
+
+	if err := ex.HasBlock(blks[1]); err != nil {
+		t.Fatal(err)
+	}
+	ks, err = q.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 1 || ks[0] != blks[2].Key() {
+		t.Fatalf("expected only %s queued, got %v", blks[2].Key(), ks)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	q := NewQueue(ds_sync.MutexWrap(ds.NewMapDatastore()))
+	g := blocksutil.NewBlockGenerator()
+	blks := g.Blocks(3)
+	for _, b := range blks {
+		if err := q.Add(b.Key()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the network only has the first two
+	remote := offline.Exchange(blockstore.NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore())))
+	for _, b := range blks[:2] {
+		if err := remote.HasBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.Drain(context.Background(), remote); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := q.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != 1 || ks[0] != blks[2].Key() {
+		t.Fatalf("expected only %s to stay queued, got %v", blks[2].Key(), ks)
+	}
+}
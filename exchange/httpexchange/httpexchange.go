@@ -0,0 +1,187 @@
+// package httpexchange implements an exchange that fetches blocks over plain
+// HTTP from gateways or block archives, for nodes that can't reach other
+// peers directly.
+package httpexchange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("httpexchange")
+
+var ErrNotFound = errors.New("block not found at any url")
+var ErrHashMismatch = errors.New("block data does not match the requested key")
+
+// KeyPlaceholder marks where the key goes in a url. Urls without it have the
+// key appended as the last path component.
+const KeyPlaceholder = "{key}"
+
+// MaxBlockSize is the largest response accepted as a block.
+var MaxBlockSize int64 = 4 << 20
+
+// fetchWorkers bounds the number of concurrent requests made by GetBlocks.
+const fetchWorkers = 8
+
+// Exchange fetches blocks from a list of urls, trying each in order, and
+// verifies them against their keys before storing them. It never serves
+// blocks to others.
+type Exchange struct {
+	bs     blockstore.Blockstore
+	urls   []string
+	client http.Client
+}
+
+// New returns an exchange fetching blocks from the given urls, such as
+// "https://gateway.example.com/api/v0/block/get?arg={key}" or
+// "https://archive.example.com/blocks", into bs.
+func New(bs blockstore.Blockstore, urls []string) *Exchange {
+	return &Exchange{bs: bs, urls: urls}
+}
+
+func (e *Exchange) blockURL(base string, k key.Key) string {
+	if strings.Contains(base, KeyPlaceholder) {
+		return strings.Replace(base, KeyPlaceholder, k.B58String(), -1)
+	}
+	return strings.TrimRight(base, "/") + "/" + k.B58String()
+}
+
+// GetBlock fetches the block for k from the first url that has it.
+func (e *Exchange) GetBlock(ctx context.Context, k key.Key) (*blocks.Block, error) {
+	if blk, err := e.bs.Get(k); err == nil {
+		return blk, nil
+	}
+
+	for _, base := range e.urls {
+		blk, err := e.fetch(ctx, e.blockURL(base, k), k)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+			log.Debugf("fetching %s from %s: %s", k, base, err)
+			continue
+		}
+
+		if err := e.bs.Put(blk); err != nil {
+			return nil, err
+		}
+		return blk, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (e *Exchange) fetch(ctx context.Context, url string, k key.Key) (*blocks.Block, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Cancel = ctx.Done()
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > MaxBlockSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", MaxBlockSize)
+	}
+
+	if err := verify(data, k); err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithHash(data, mh.Multihash(k))
+}
+
+// verify checks that data hashes to k, with the hash function k was made
+// with.
+func verify(data []byte, k key.Key) error {
+	dec, err := mh.Decode([]byte(k))
+	if err != nil {
+		return err
+	}
+	chk, err := mh.Sum(data, dec.Code, dec.Length)
+	if err != nil {
+		return err
+	}
+	if string(chk) != string(k) {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// GetBlocks fetches the blocks for ks concurrently, sending those found on
+// the returned channel. Blocks that can't be found are left out.
+func (e *Exchange) GetBlocks(ctx context.Context, ks []key.Key) (<-chan *blocks.Block, error) {
+	out := make(chan *blocks.Block)
+	keys := make(chan key.Key)
+
+	var wg sync.WaitGroup
+	for i := 0; i < fetchWorkers && i < len(ks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range keys {
+				blk, err := e.GetBlock(ctx, k)
+				if err != nil {
+					continue
+				}
+				select {
+				case out <- blk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(out)
+		}()
+		defer close(keys)
+		for _, k := range ks {
+			select {
+			case keys <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// HasBlock stores the block. Blocks are not announced to anyone.
+func (e *Exchange) HasBlock(b *blocks.Block) error {
+	return e.bs.Put(b)
+}
+
+func (e *Exchange) Close() error {
+	return nil
+}
+
+var _ exchange.Interface = (*Exchange)(nil)
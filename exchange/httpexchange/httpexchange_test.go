@@ -0,0 +1,111 @@
+package httpexchange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+)
+
+func bstore() blockstore.Blockstore {
+	return blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+}
+
+// blockServer serves the given blocks at /<key>, and bad data for the key
+// of corrupt.
+func blockServer(blks []*blocks.Block, corrupt key.Key) *httptest.Server {
+	data := make(map[string][]byte)
+	for _, b := range blks {
+		data[b.Key().B58String()] = b.Data
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := strings.TrimPrefix(r.URL.Path, "/")
+		if k == corrupt.B58String() {
+			w.Write([]byte("not the right data"))
+			return
+		}
+		d, ok := data[k]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(d)
+	}))
+}
+
+func TestGetBlock(t *testing.T) {
+	good := blocks.NewBlock([]byte("some block"))
+	bad := blocks.NewBlock([]byte("another block"))
+	missing := blocks.NewBlock([]byte("missing block"))
+
+	srv := blockServer([]*blocks.Block{good}, bad.Key())
+	defer srv.Close()
+
+	bs := bstore()
+	ex := New(bs, []string{srv.URL + "/nothing-here/{key}", srv.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	blk, err := ex.GetBlock(ctx, good.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blk.Data) != string(good.Data) {
+		t.Fatal("got the wrong data")
+	}
+	if has, _ := bs.Has(good.Key()); !has {
+		t.Fatal("fetched block was not stored")
+	}
+
+	if _, err := ex.GetBlock(ctx, bad.Key()); err != ErrNotFound {
+		t.Fatalf("expected a block with bad data not to be found, got %v", err)
+	}
+	if _, err := ex.GetBlock(ctx, missing.Key()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLayered(t *testing.T) {
+	var blks []*blocks.Block
+	var ks []key.Key
+	for _, s := range []string{"a", "b", "c"} {
+		b := blocks.NewBlock([]byte(s))
+		blks = append(blks, b)
+		ks = append(ks, b.Key())
+	}
+
+	srv := blockServer(blks[1:], "")
+	defer srv.Close()
+
+	bs := bstore()
+	if err := bs.Put(blks[0]); err != nil {
+		t.Fatal(err)
+	}
+	ex := NewLayered(offline.Exchange(bs), New(bs, []string{srv.URL}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	ch, err := ex.GetBlocks(ctx, ks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[key.Key]bool)
+	for b := range ch {
+		got[b.Key()] = true
+	}
+	for _, k := range ks {
+		if !got[k] {
+			t.Fatalf("block %s was not found", k)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package httpexchange
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	exchange "github.com/ipfs/go-ipfs/exchange"
+)
+
+// HTTPDelay is how long a Layered exchange waits on its primary exchange
+// before also looking for a block over HTTP.
+var HTTPDelay = time.Second
+
+// Layered is an exchange that looks for blocks through a primary exchange,
+// such as bitswap, and over HTTP for those the primary doesn't find within
+// HTTPDelay. Blocks found over HTTP are handed to the primary exchange as if
+// they had been added locally, so it can serve them to others.
+type Layered struct {
+	Primary exchange.Interface
+	HTTP    *Exchange
+}
+
+// NewLayered returns an exchange that falls back on h for blocks primary
+// doesn't find quickly.
+func NewLayered(primary exchange.Interface, h *Exchange) *Layered {
+	return &Layered{Primary: primary, HTTP: h}
+}
+
+func (l *Layered) GetBlock(ctx context.Context, k key.Key) (*blocks.Block, error) {
+	return l.getBlock(ctx, l.Primary, k)
+}
+
+func (l *Layered) GetBlocks(ctx context.Context, ks []key.Key) (<-chan *blocks.Block, error) {
+	return l.getBlocks(ctx, l.Primary, ks)
+}
+
+func (l *Layered) HasBlock(b *blocks.Block) error {
+	return l.Primary.HasBlock(b)
+}
+
+func (l *Layered) Close() error {
+	return l.Primary.Close()
+}
+
+// NewSession returns a session of the primary exchange, if it supports
+// them, that still falls back on HTTP.
+func (l *Layered) NewSession(ctx context.Context) exchange.Session {
+	sx, ok := l.Primary.(exchange.SessionExchange)
+	if !ok {
+		return &layeredSession{l: l, f: l.Primary}
+	}
+	ses := sx.NewSession(ctx)
+	return &layeredSession{l: l, f: ses, c: ses}
+}
+
+type layeredSession struct {
+	l *Layered
+	f exchange.Fetcher
+	c io.Closer // the primary's session, if any
+}
+
+func (s *layeredSession) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
+
+func (s *layeredSession) GetBlock(ctx context.Context, k key.Key) (*blocks.Block, error) {
+	return s.l.getBlock(ctx, s.f, k)
+}
+
+func (s *layeredSession) GetBlocks(ctx context.Context, ks []key.Key) (<-chan *blocks.Block, error) {
+	return s.l.getBlocks(ctx, s.f, ks)
+}
+
+func (l *Layered) getBlock(parent context.Context, primary exchange.Fetcher, k key.Key) (*blocks.Block, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	promise, err := l.getBlocks(ctx, primary, []key.Key{k})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case blk, ok := <-promise:
+		if !ok {
+			select {
+			case <-parent.Done():
+				return nil, parent.Err()
+			default:
+				return nil, errors.New("promise channel was closed")
+			}
+		}
+		return blk, nil
+	case <-parent.Done():
+		return nil, parent.Err()
+	}
+}
+
+func (l *Layered) getBlocks(ctx context.Context, primary exchange.Fetcher, ks []key.Key) (<-chan *blocks.Block, error) {
+	pch, err := primary.GetBlocks(ctx, ks)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *blocks.Block)
+	go func() {
+		defer close(out)
+
+		remaining := make(map[key.Key]struct{})
+		for _, k := range ks {
+			remaining[k] = struct{}{}
+		}
+		deliver := func(b *blocks.Block) bool {
+			if _, ok := remaining[b.Key()]; !ok {
+				return true
+			}
+			delete(remaining, b.Key())
+			select {
+			case out <- b:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		timer := time.NewTimer(HTTPDelay)
+		defer timer.Stop()
+
+		var hch <-chan *blocks.Block
+		httpStarted := false
+		startHTTP := func() {
+			httpStarted = true
+			var left []key.Key
+			for k := range remaining {
+				left = append(left, k)
+			}
+			hch, _ = l.HTTP.GetBlocks(ctx, left)
+		}
+
+		for len(remaining) > 0 {
+			if pch == nil && hch == nil {
+				if httpStarted {
+					return
+				}
+				startHTTP()
+			}
+
+			select {
+			case b, ok := <-pch:
+				if !ok {
+					pch = nil
+					continue
+				}
+				if !deliver(b) {
+					return
+				}
+			case <-timer.C:
+				if !httpStarted {
+					startHTTP()
+				}
+			case b, ok := <-hch:
+				if !ok {
+					hch = nil
+					continue
+				}
+				if err := l.Primary.HasBlock(b); err != nil {
+					log.Debugf("handing %s to the primary exchange: %s", b.Key(), err)
+				}
+				if !deliver(b) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -23,3 +23,30 @@ type Interface interface {
 
 	io.Closer
 }
+
+// Fetcher is an object that can be used to retrieve blocks.
+type Fetcher interface {
+	// GetBlock returns the block associated with a given key.
+	GetBlock(context.Context, key.Key) (*blocks.Block, error)
+
+	GetBlocks(context.Context, []key.Key) (<-chan *blocks.Block, error)
+}
+
+// SessionExchange is an exchange that can group related requests, such as
+// those made while walking a single dag, into sessions. Blocks fetched within
+// a session may be found faster than with separate requests.
+type SessionExchange interface {
+	Interface
+
+	// NewSession returns a Session lasting until it is closed or ctx is
+	// cancelled.
+	NewSession(context.Context) Session
+}
+
+// Session is a Fetcher whose requests belong to a single session.
+type Session interface {
+	Fetcher
+
+	// Close ends the session, cancelling the requests still open.
+	io.Closer
+}
@@ -0,0 +1,139 @@
+// package provider decides which of the blocks a node stores it announces
+// to the routing system. Announcing every block received or added can
+// overwhelm the routing system on nodes holding many blocks; a strategy
+// lets such nodes announce only the blocks others are likely to look for.
+package provider
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	pin "github.com/ipfs/go-ipfs/pin"
+	routing "github.com/ipfs/go-ipfs/routing"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("provider")
+
+// provideTimeout bounds each announcement of a pinned block.
+var provideTimeout = time.Second * 15
+
+// Strategy chooses which blocks the node announces.
+type Strategy int
+
+const (
+	// All announces every block, as it is added or received.
+	All Strategy = iota
+
+	// Roots announces only the roots of pins, once pinned: the keys passed
+	// to ipfs pin add, or added with ipfs add. Those who have the root will
+	// usually ask the same peers for the rest of the dag.
+	Roots
+
+	// Pinned announces the blocks that are pinned, directly or as part of
+	// a pinned dag, once pinned.
+	Pinned
+
+	// None announces nothing. Others can still find the node's blocks by
+	// asking it directly, through bitswap.
+	None
+)
+
+// ParseStrategy returns the strategy with the given name, one of "all",
+// "roots", "pinned" or "none". The empty name selects All.
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", "all":
+		return All, nil
+	case "roots":
+		return Roots, nil
+	case "pinned":
+		return Pinned, nil
+	case "none":
+		return None, nil
+	default:
+		return All, fmt.Errorf("unknown provider strategy: %q", name)
+	}
+}
+
+// RootKeys returns the roots of the pins of p: the keys of its recursive,
+// direct and best effort pins.
+func RootKeys(p pin.Pinner) []key.Key {
+	var ks []key.Key
+	ks = append(ks, p.RecursiveKeys()...)
+	ks = append(ks, p.DirectKeys()...)
+	ks = append(ks, p.BestEffortKeys()...)
+	return ks
+}
+
+// Filter is a routing system that only announces the keys its strategy
+// allows, and otherwise passes everything through to the routing system it
+// wraps. Under the Roots and Pinned strategies, blocks are announced once
+// pinned: the Filter is the pinner's Announcer.
+type Filter struct {
+	routing.IpfsRouting
+
+	lk       sync.RWMutex
+	strategy Strategy
+}
+
+var _ pin.Announcer = (*Filter)(nil)
+
+// NewFilter wraps r with a Filter that announces all keys until given
+// another strategy.
+func NewFilter(r routing.IpfsRouting) *Filter {
+	return &Filter{IpfsRouting: r, strategy: All}
+}
+
+// SetStrategy changes the strategy used for the following announcements.
+func (f *Filter) SetStrategy(s Strategy) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.strategy = s
+}
+
+func (f *Filter) getStrategy() Strategy {
+	f.lk.RLock()
+	defer f.lk.RUnlock()
+	return f.strategy
+}
+
+// Provide announces k under the All strategy, and silently drops it
+// otherwise.
+func (f *Filter) Provide(ctx context.Context, k key.Key) error {
+	if f.getStrategy() != All {
+		log.Debugf("not providing %s", k)
+		return nil
+	}
+	return f.IpfsRouting.Provide(ctx, k)
+}
+
+// AnnouncePinned announces the roots of new pins under the Roots strategy,
+// and the blocks below them too under the Pinned one. The announcements are
+// made in the background.
+func (f *Filter) AnnouncePinned(roots, below []key.Key) {
+	var ks []key.Key
+	switch f.getStrategy() {
+	case Roots:
+		ks = roots
+	case Pinned:
+		ks = append(append(ks, roots...), below...)
+	}
+	if len(ks) == 0 {
+		return
+	}
+	go f.announce(ks)
+}
+
+func (f *Filter) announce(ks []key.Key) {
+	for _, k := range ks {
+		ctx, cancel := context.WithTimeout(context.Background(), provideTimeout)
+		if err := f.IpfsRouting.Provide(ctx, k); err != nil {
+			log.Warningf("announcing pinned block %s: %s", k, err)
+		}
+		cancel()
+	}
+}
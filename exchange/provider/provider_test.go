@@ -0,0 +1,134 @@
+package provider
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	pin "github.com/ipfs/go-ipfs/pin"
+	routing "github.com/ipfs/go-ipfs/routing"
+)
+
+type countingRouting struct {
+	routing.IpfsRouting
+
+	lk       sync.Mutex
+	provided []key.Key
+}
+
+func (r *countingRouting) Provide(_ context.Context, k key.Key) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.provided = append(r.provided, k)
+	return nil
+}
+
+// waitProvided returns the keys provided once there are n of them, or after
+// a while.
+func (r *countingRouting) waitProvided(n int) []key.Key {
+	for i := 0; i < 100; i++ {
+		r.lk.Lock()
+		got := len(r.provided)
+		r.lk.Unlock()
+		if got >= n {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	ks := append([]key.Key(nil), r.provided...)
+	sort.Sort(key.KeySlice(ks))
+	return ks
+}
+
+func TestStrategies(t *testing.T) {
+	ctx := context.Background()
+
+	child := &dag.Node{Data: []byte("child")}
+	root := &dag.Node{Data: []byte("root")}
+	if err := root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	rk, _ := root.Key()
+	ck, _ := child.Key()
+
+	cases := map[string]struct {
+		// announced as bitswap adds them, and once pinned
+		added, pinned []key.Key
+	}{
+		"":       {added: []key.Key{rk, ck}},
+		"all":    {added: []key.Key{rk, ck}},
+		"roots":  {pinned: []key.Key{rk}},
+		"pinned": {pinned: []key.Key{rk, ck}},
+		"none":   {},
+	}
+	for name, want := range cases {
+		s, err := ParseStrategy(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dserv := mdtest.Mock()
+		pinner := pin.NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dserv)
+		r := new(countingRouting)
+		f := NewFilter(r)
+		f.SetStrategy(s)
+		pinner.SetAnnouncer(f)
+
+		for _, nd := range []*dag.Node{child, root} {
+			k, err := dserv.Add(nd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Provide(ctx, k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := r.waitProvided(len(want.added)); !equalKeys(got, want.added) {
+			t.Errorf("strategy %q: provided %v as added, want %v", name, got, want.added)
+		}
+
+		r.lk.Lock()
+		r.provided = nil
+		r.lk.Unlock()
+		if err := pinner.Pin(ctx, root, true); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.waitProvided(0); len(got) != 0 {
+			t.Errorf("strategy %q: provided %v before the pin was flushed", name, got)
+		}
+		if err := pinner.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.waitProvided(len(want.pinned)); !equalKeys(got, want.pinned) {
+			t.Errorf("strategy %q: provided %v once pinned, want %v", name, got, want.pinned)
+		}
+	}
+
+	if _, err := ParseStrategy("bogus"); err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
+
+func equalKeys(got, want []key.Key) bool {
+	want = append([]key.Key(nil), want...)
+	sort.Sort(key.KeySlice(want))
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
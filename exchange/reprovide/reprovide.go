@@ -6,51 +6,89 @@ import (
 
 	backoff "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/cenkalti/backoff"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
-	blocks "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
 	routing "github.com/ipfs/go-ipfs/routing"
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
 
 var log = logging.Logger("reprovider")
 
+// KeyChanFunc returns the keys a reprovide announces, see strategies.go.
+type KeyChanFunc func(context.Context) (<-chan key.Key, error)
+
 type Reprovider struct {
 	// The routing system to provide values through
 	rsys routing.IpfsRouting
 
-	// The backing store for blocks to be provided
-	bstore blocks.Blockstore
+	// The keys to be provided
+	keyProvider KeyChanFunc
+
+	// Trigger requests, answered once their reprovide is done
+	trigger chan chan error
 }
 
-func NewReprovider(rsys routing.IpfsRouting, bstore blocks.Blockstore) *Reprovider {
+func NewReprovider(rsys routing.IpfsRouting, keyProvider KeyChanFunc) *Reprovider {
 	return &Reprovider{
-		rsys:   rsys,
-		bstore: bstore,
+		rsys:        rsys,
+		keyProvider: keyProvider,
+		trigger:     make(chan chan error),
 	}
 }
 
+// ProvideEvery reprovides every tick, and whenever Trigger is called, until
+// ctx is done. With a zero tick, it only reprovides when triggered.
 func (rp *Reprovider) ProvideEvery(ctx context.Context, tick time.Duration) {
 	// dont reprovide immediately.
 	// may have just started the daemon and shutting it down immediately.
 	// probability( up another minute | uptime ) increases with uptime.
-	after := time.After(time.Minute)
+	var after <-chan time.Time
+	if tick > 0 {
+		after = time.After(time.Minute)
+	}
 	for {
+		var done chan error
 		select {
 		case <-ctx.Done():
 			return
+		case done = <-rp.trigger:
 		case <-after:
-			err := rp.Reprovide(ctx)
-			if err != nil {
-				log.Debug(err)
-			}
+		}
+
+		err := rp.Reprovide(ctx)
+		if err != nil {
+			log.Debug(err)
+		}
+		if done != nil {
+			done <- err
+		}
+		if tick > 0 {
 			after = time.After(tick)
 		}
 	}
 }
 
+// Trigger makes ProvideEvery reprovide now, after the reprovide under way
+// if there is one, and returns its error once it is done.
+func (rp *Reprovider) Trigger(ctx context.Context) error {
+	done := make(chan error, 1)
+	select {
+	case rp.trigger <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (rp *Reprovider) Reprovide(ctx context.Context) error {
-	keychan, err := rp.bstore.AllKeysChan(ctx)
+	keychan, err := rp.keyProvider(ctx)
 	if err != nil {
-		return fmt.Errorf("Failed to get key chan from blockstore: %s", err)
+		return fmt.Errorf("Failed to get key chan: %s", err)
 	}
 	for k := range keychan {
 		op := func() error {
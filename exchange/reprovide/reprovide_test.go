@@ -8,6 +8,11 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
 	mock "github.com/ipfs/go-ipfs/routing/mock"
 	testutil "github.com/ipfs/go-ipfs/util/testutil"
 
@@ -31,7 +36,7 @@ func TestReprovide(t *testing.T) {
 	blk := blocks.NewBlock([]byte("this is a test"))
 	bstore.Put(blk)
 
-	reprov := NewReprovider(clA, bstore)
+	reprov := NewReprovider(clA, NewBlockstoreProvider(bstore))
 	err := reprov.Reprovide(ctx)
 	if err != nil {
 		t.Fatal(err)
@@ -50,3 +55,94 @@ func TestReprovide(t *testing.T) {
 		t.Fatal("Somehow got the wrong peer back as a provider.")
 	}
 }
+
+func TestReprovideStrategies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bserv.New(bstore, offline.Exchange(bstore)))
+	pinning := pin.NewPinner(dstore, dserv)
+
+	// a pinned root with a child, and an unpinned block
+	child := &mdag.Node{Data: []byte("child")}
+	root := &mdag.Node{Data: []byte("root")}
+	if err := root.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*mdag.Node{child, root} {
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pinning.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+	loose := blocks.NewBlock([]byte("not pinned"))
+	if err := bstore.Put(loose); err != nil {
+		t.Fatal(err)
+	}
+
+	rk, _ := root.Key()
+	ck, _ := child.Key()
+	for strategy, expected := range map[string][]key.Key{
+		"all":    {rk, ck, loose.Key()},
+		"pinned": {rk, ck},
+		"roots":  {rk},
+		"none":   nil,
+	} {
+		kp, err := KeyProviderByName(strategy, pinning, bstore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := kp(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[key.Key]bool)
+		for k := range keys {
+			got[k] = true
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("%s: expected %d keys, got %d", strategy, len(expected), len(got))
+		}
+		for _, k := range expected {
+			if !got[k] {
+				t.Fatalf("%s: %s was not provided", strategy, k)
+			}
+		}
+	}
+
+	if _, err := KeyProviderByName("some", pinning, bstore); err == nil {
+		t.Fatal("expected an unknown strategy to fail")
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mrserv := mock.NewServer()
+	clA := mrserv.Client(testutil.RandIdentityOrFatal(t))
+	clB := mrserv.Client(testutil.RandIdentityOrFatal(t))
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	blk := blocks.NewBlock([]byte("triggered"))
+	bstore.Put(blk)
+
+	// without a tick, only triggers reprovide
+	reprov := NewReprovider(clA, NewBlockstoreProvider(bstore))
+	go reprov.ProvideEvery(ctx, 0)
+
+	if err := reprov.Trigger(ctx); err != nil {
+		t.Fatal(err)
+	}
+	provs, err := clB.FindProviders(ctx, blk.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provs) == 0 {
+		t.Fatal("Should have gotten a provider")
+	}
+}
@@ -0,0 +1,96 @@
+package reprovide
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	provider "github.com/ipfs/go-ipfs/exchange/provider"
+	pin "github.com/ipfs/go-ipfs/pin"
+)
+
+// NewBlockstoreProvider returns every key of the blockstore.
+func NewBlockstoreProvider(bstore blocks.Blockstore) KeyChanFunc {
+	return func(ctx context.Context) (<-chan key.Key, error) {
+		return bstore.AllKeysChan(ctx)
+	}
+}
+
+// NewPinnedProvider returns the keys of the pinned blocks in the
+// blockstore: the roots of pins, and the blocks of the recursive ones.
+func NewPinnedProvider(pinning pin.Pinner, bstore blocks.Blockstore) KeyChanFunc {
+	return func(ctx context.Context) (<-chan key.Key, error) {
+		indirect, err := pinning.IndirectKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ks := provider.RootKeys(pinning)
+		for k := range indirect {
+			ks = append(ks, k)
+		}
+		return storedKeys(ctx, bstore, ks), nil
+	}
+}
+
+// NewRootsProvider returns the keys of the roots of pins in the blockstore.
+// Those who find the root will usually ask the same peers for the rest of
+// the dag.
+func NewRootsProvider(pinning pin.Pinner, bstore blocks.Blockstore) KeyChanFunc {
+	return func(ctx context.Context) (<-chan key.Key, error) {
+		return storedKeys(ctx, bstore, provider.RootKeys(pinning)), nil
+	}
+}
+
+// NewNoneProvider returns no keys.
+func NewNoneProvider() KeyChanFunc {
+	return func(context.Context) (<-chan key.Key, error) {
+		out := make(chan key.Key)
+		close(out)
+		return out, nil
+	}
+}
+
+// KeyProviderByName returns the key provider of the provider strategy with
+// the given name, see provider.ParseStrategy.
+func KeyProviderByName(name string, pinning pin.Pinner, bstore blocks.Blockstore) (KeyChanFunc, error) {
+	s, err := provider.ParseStrategy(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s {
+	case provider.Pinned:
+		return NewPinnedProvider(pinning, bstore), nil
+	case provider.Roots:
+		return NewRootsProvider(pinning, bstore), nil
+	case provider.None:
+		return NewNoneProvider(), nil
+	default:
+		return NewBlockstoreProvider(bstore), nil
+	}
+}
+
+// storedKeys sends the keys of ks the blockstore has, once each. Best
+// effort pins may miss blocks, which the node cannot provide.
+func storedKeys(ctx context.Context, bstore blocks.Blockstore, ks []key.Key) <-chan key.Key {
+	out := make(chan key.Key)
+	go func() {
+		defer close(out)
+		seen := make(map[key.Key]struct{}, len(ks))
+		for _, k := range ks {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+
+			if has, err := bstore.Has(k); err != nil || !has {
+				continue
+			}
+			select {
+			case out <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
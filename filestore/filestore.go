@@ -0,0 +1,285 @@
+// package filestore implements a blockstore that can keep the data of
+// leaf blocks in the files it was added from, storing only a reference to
+// the file in the repo instead of a copy of the data.
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsns "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/namespace"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	pb "github.com/ipfs/go-ipfs/unixfs/pb"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("filestore")
+
+// FilestorePrefix namespaces the file references in the datastore.
+var FilestorePrefix = ds.NewKey("filestore")
+
+var (
+	ErrFileMissing = errors.New("filestore: referenced file is missing")
+	ErrFileChanged = errors.New("filestore: referenced file has changed")
+	ErrNotLeaf     = errors.New("filestore: only leaf nodes can be stored as references")
+)
+
+// DataObj is a reference to the data of a leaf node, held in a file outside
+// the repo.
+type DataObj struct {
+	FilePath string
+	Offset   uint64
+	Size     uint64
+
+	// Type is the unixfs type of the leaf node, needed to rebuild it from
+	// the data.
+	Type pb.Data_DataType
+}
+
+// Filestore is a blockstore that serves blocks from an underlying
+// blockstore, and from file references added with PutRef. Blocks read from
+// references are rebuilt from the file data and verified against their key.
+type Filestore struct {
+	bs   bstore.Blockstore
+	refs ds.Datastore
+}
+
+var _ bstore.Blockstore = (*Filestore)(nil)
+
+// NewFilestore returns a filestore backed by bs, which keeps its references
+// in d.
+func NewFilestore(bs bstore.Blockstore, d ds.Datastore) *Filestore {
+	return &Filestore{
+		bs:   bs,
+		refs: dsns.Wrap(d, FilestorePrefix),
+	}
+}
+
+// PutRef stores the leaf node nd as a reference to its data, found at the
+// given offset of the file at path. The path should be absolute. The data
+// is read back from the file and checked against nd, so a reference is
+// never stored to bytes that don't make up the node.
+func (f *Filestore) PutRef(nd *dag.Node, path string, offset uint64) error {
+	if len(nd.Links) > 0 {
+		return ErrNotLeaf
+	}
+	fsn, err := ft.FSNodeFromBytes(nd.Data)
+	if err != nil {
+		return err
+	}
+	if fsn.NumChildren() > 0 {
+		return ErrNotLeaf
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		return err
+	}
+
+	obj := &DataObj{
+		FilePath: path,
+		Offset:   offset,
+		Size:     uint64(len(fsn.Data)),
+		Type:     fsn.Type,
+	}
+	if _, err := readRef(k, obj); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return f.refs.Put(k.DsKey(), buf)
+}
+
+func (f *Filestore) getRef(k key.Key) (*DataObj, error) {
+	v, err := f.refs.Get(k.DsKey())
+	if err != nil {
+		return nil, err
+	}
+	buf, ok := v.([]byte)
+	if !ok {
+		return nil, bstore.ValueTypeMismatch
+	}
+
+	obj := new(DataObj)
+	if err := json.Unmarshal(buf, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// readRef rebuilds the block referenced by obj, and checks it against k.
+func readRef(k key.Key, obj *DataObj) (*blocks.Block, error) {
+	fi, err := os.Open(obj.FilePath)
+	if os.IsNotExist(err) {
+		return nil, ErrFileMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	data := make([]byte, obj.Size)
+	_, err = fi.ReadAt(data, int64(obj.Offset))
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrFileChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fsn := &ft.FSNode{Type: obj.Type, Data: data}
+	pbdata, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+	nd := &dag.Node{Data: pbdata}
+	nk, err := nd.Key()
+	if err != nil {
+		return nil, err
+	}
+	if nk != k {
+		return nil, ErrFileChanged
+	}
+
+	enc, err := nd.Encoded(false)
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithHash(enc, mh.Multihash(k))
+}
+
+func (f *Filestore) Get(k key.Key) (*blocks.Block, error) {
+	blk, err := f.bs.Get(k)
+	if err != bstore.ErrNotFound {
+		return blk, err
+	}
+
+	obj, err := f.getRef(k)
+	if err == ds.ErrNotFound {
+		return nil, bstore.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blk, err = readRef(k, obj)
+	if err != nil {
+		log.Errorf("cannot read %s from %s: %s", k, obj.FilePath, err)
+		return nil, err
+	}
+	return blk, nil
+}
+
+func (f *Filestore) Has(k key.Key) (bool, error) {
+	has, err := f.bs.Has(k)
+	if err != nil || has {
+		return has, err
+	}
+	return f.refs.Has(k.DsKey())
+}
+
+func (f *Filestore) Put(b *blocks.Block) error {
+	return f.bs.Put(b)
+}
+
+func (f *Filestore) PutMany(bs []*blocks.Block) error {
+	return f.bs.PutMany(bs)
+}
+
+// DeleteBlock removes the block from the underlying blockstore, or drops
+// its file reference. The referenced file is left untouched.
+func (f *Filestore) DeleteBlock(k key.Key) error {
+	err := f.bs.DeleteBlock(k)
+	if err != bstore.ErrNotFound && err != ds.ErrNotFound {
+		return err
+	}
+
+	err = f.refs.Delete(k.DsKey())
+	if err == ds.ErrNotFound {
+		return bstore.ErrNotFound
+	}
+	return err
+}
+
+// AllKeysChan returns the keys of the underlying blockstore, followed by
+// the keys held as file references.
+func (f *Filestore) AllKeysChan(ctx context.Context) (<-chan key.Key, error) {
+	bskeys, err := f.bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make(chan key.Key)
+	go func() {
+		defer close(output)
+		for k := range bskeys {
+			select {
+			case output <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		err := f.eachRef(ctx, true, func(k key.Key, _ []byte) error {
+			select {
+			case output <- k:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && err != ctx.Err() {
+			log.Errorf("error listing file references: %s", err)
+		}
+	}()
+	return output, nil
+}
+
+// eachRef calls fn with the key, and unless keysOnly is set the encoded
+// DataObj, of every file reference.
+func (f *Filestore) eachRef(ctx context.Context, keysOnly bool, fn func(key.Key, []byte) error) error {
+	// datastore/namespace does *NOT* fix up Query.Prefix
+	res, err := f.refs.Query(dsq.Query{Prefix: FilestorePrefix.String(), KeysOnly: keysOnly})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	for {
+		select {
+		case e, ok := <-res.Next():
+			if !ok {
+				return nil
+			}
+			if e.Error != nil {
+				return e.Error
+			}
+
+			var buf []byte
+			if !keysOnly {
+				buf, ok = e.Value.([]byte)
+				if !ok {
+					return bstore.ValueTypeMismatch
+				}
+			}
+			if err := fn(key.KeyFromDsKey(ds.NewKey(e.Key)), buf); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
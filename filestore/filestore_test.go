@@ -0,0 +1,158 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	importer "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+func setup(t *testing.T) (*Filestore, dag.DAGService, string) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	fs := NewFilestore(bstore.NewBlockstore(d), d)
+	dserv := dag.NewDAGService(bserv.New(fs, offline.Exchange(fs)))
+
+	dir, err := ioutil.TempDir("", "filestore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs, dserv, dir
+}
+
+func addFile(t *testing.T, fs *Filestore, dserv dag.DAGService, fpath string, data []byte) *dag.Node {
+	if err := ioutil.WriteFile(fpath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	nd, err := importer.BuildDagFromReaderNoCopy(dserv, fs, fpath, chunk.NewSizeSplitter(bytes.NewReader(data), 1024), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func verifyStatuses(t *testing.T, fs *Filestore) map[Status]int {
+	statuses, err := fs.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[Status]int)
+	for st := range statuses {
+		counts[st.Status]++
+	}
+	return counts
+}
+
+func TestAddNoCopy(t *testing.T) {
+	fs, dserv, dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 10*1024+17)
+	rand.Read(data)
+	fpath := filepath.Join(dir, "file")
+	nd := addFile(t, fs, dserv, fpath, data)
+
+	// only the root, which links the leaves, is copied into the repo
+	keys, err := fs.bs.AllKeysChan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := 0
+	for range keys {
+		copied++
+	}
+	if copied != 1 {
+		t.Fatalf("expected only the root to be stored, found %d blocks", copied)
+	}
+
+	r, err := uio.NewDagReader(context.Background(), nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("data read back does not match")
+	}
+
+	if c := verifyStatuses(t, fs); c[StatusOK] != 11 || len(c) != 1 {
+		t.Fatalf("expected 11 ok references, got %v", c)
+	}
+}
+
+func TestPutRefMismatch(t *testing.T) {
+	fs, _, dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	fpath := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(fpath, []byte("some other data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nd := &dag.Node{Data: ft.FilePBData([]byte("the node's data"), 15)}
+	if err := fs.PutRef(nd, fpath, 0); err != ErrFileChanged {
+		t.Fatalf("expected %s, got %v", ErrFileChanged, err)
+	}
+	k, _ := nd.Key()
+	if has, _ := fs.Has(k); has {
+		t.Fatal("expected the mismatching reference not to be stored")
+	}
+}
+
+func TestVerifyAndClean(t *testing.T) {
+	fs, dserv, dir := setup(t)
+	defer os.RemoveAll(dir)
+
+	changed := filepath.Join(dir, "changed")
+	nd := addFile(t, fs, dserv, changed, bytes.Repeat([]byte("a"), 2048))
+	addFile(t, fs, dserv, filepath.Join(dir, "missing"), bytes.Repeat([]byte("b"), 1024))
+	addFile(t, fs, dserv, filepath.Join(dir, "intact"), bytes.Repeat([]byte("c"), 1024))
+
+	if err := ioutil.WriteFile(changed, bytes.Repeat([]byte("z"), 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "missing")); err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := nd.Links[0].GetNode(context.Background(), dserv)
+	if err == nil {
+		t.Fatalf("expected an error reading a changed file, got node %v", leaf)
+	}
+
+	c := verifyStatuses(t, fs)
+	// both leaves of the changed file are identical, and share a reference
+	if c[StatusOK] != 1 || c[StatusChanged] != 1 || c[StatusMissing] != 1 {
+		t.Fatalf("unexpected statuses %v", c)
+	}
+
+	removed, err := fs.Clean(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range removed {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 references removed, got %d", n)
+	}
+
+	if c := verifyStatuses(t, fs); c[StatusOK] != 1 || len(c) != 1 {
+		t.Fatalf("expected only the intact reference to remain, got %v", c)
+	}
+}
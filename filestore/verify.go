@@ -0,0 +1,109 @@
+package filestore
+
+import (
+	"encoding/json"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// Status is the state of a file reference, as found by Verify.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusChanged Status = "changed"
+	StatusMissing Status = "missing"
+	StatusError   Status = "error"
+)
+
+// RefStatus describes a single file reference, and whether the data it
+// points to still matches its key.
+type RefStatus struct {
+	Key      key.Key
+	Status   Status
+	FilePath string
+	Offset   uint64
+	Size     uint64
+	Error    string `json:",omitempty"`
+}
+
+// Verify checks every file reference in the filestore, sending the status
+// of each on the returned channel. The channel is closed when all references
+// have been checked, or ctx is cancelled.
+func (f *Filestore) Verify(ctx context.Context) (<-chan *RefStatus, error) {
+	out := make(chan *RefStatus)
+	go func() {
+		defer close(out)
+		err := f.eachRef(ctx, false, func(k key.Key, buf []byte) error {
+			select {
+			case out <- verifyRef(k, buf):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && err != ctx.Err() {
+			log.Errorf("error listing file references: %s", err)
+		}
+	}()
+	return out, nil
+}
+
+func verifyRef(k key.Key, buf []byte) *RefStatus {
+	st := &RefStatus{Key: k}
+
+	var obj DataObj
+	if err := json.Unmarshal(buf, &obj); err != nil {
+		st.Status = StatusError
+		st.Error = err.Error()
+		return st
+	}
+	st.FilePath = obj.FilePath
+	st.Offset = obj.Offset
+	st.Size = obj.Size
+
+	_, err := readRef(k, &obj)
+	switch err {
+	case nil:
+		st.Status = StatusOK
+	case ErrFileMissing:
+		st.Status = StatusMissing
+	case ErrFileChanged:
+		st.Status = StatusChanged
+	default:
+		st.Status = StatusError
+		st.Error = err.Error()
+	}
+	return st
+}
+
+// Clean removes the references to files that are missing or have changed,
+// sending each removed reference on the returned channel. References that
+// could not be checked for other reasons, such as permissions, are kept.
+func (f *Filestore) Clean(ctx context.Context) (<-chan *RefStatus, error) {
+	statuses, err := f.Verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *RefStatus)
+	go func() {
+		defer close(out)
+		for st := range statuses {
+			if st.Status != StatusMissing && st.Status != StatusChanged {
+				continue
+			}
+			if err := f.refs.Delete(st.Key.DsKey()); err != nil {
+				log.Errorf("failed to remove reference to %s: %s", st.Key, err)
+				continue
+			}
+			select {
+			case out <- st:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,43 @@
+// +build !nofuse
+
+package ipns
+
+import (
+	"errors"
+
+	fs "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	mfs "github.com/ipfs/go-ipfs/mfs"
+)
+
+// FilesFileSystem is the readwrite Fuse Filesystem of an mfs root, such as
+// the files root of a node. Changes are published by the root itself.
+type FilesFileSystem struct {
+	root *mfs.Root
+	dir  *mfs.Directory
+}
+
+// NewFilesFileSystem constructs the filesystem of the given root, which has
+// to be a directory.
+func NewFilesFileSystem(root *mfs.Root) (*FilesFileSystem, error) {
+	dir, ok := root.GetValue().(*mfs.Directory)
+	if !ok {
+		return nil, errors.New("files root is not a directory")
+	}
+	return &FilesFileSystem{root: root, dir: dir}, nil
+}
+
+// Root returns the directory at the root of the tree.
+func (f *FilesFileSystem) Root() (fs.Node, error) {
+	return &Directory{dir: f.dir, root: f.root}, nil
+}
+
+// Destroy publishes the changes left when the filesystem is unmounted.
+func (f *FilesFileSystem) Destroy() {
+	if err := f.root.Publish(context.Background()); err != nil {
+		log.Errorf("Error publishing the files root: %s", err)
+	}
+}
+
+var _ fs.FSDestroyer = (*FilesFileSystem)(nil)
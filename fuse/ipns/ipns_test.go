@@ -17,8 +17,12 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	core "github.com/ipfs/go-ipfs/core"
 	nsfs "github.com/ipfs/go-ipfs/ipnsfs"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
 	namesys "github.com/ipfs/go-ipfs/namesys"
+	path "github.com/ipfs/go-ipfs/path"
 	offroute "github.com/ipfs/go-ipfs/routing/offline"
+	ft "github.com/ipfs/go-ipfs/unixfs"
 	u "github.com/ipfs/go-ipfs/util"
 	ci "github.com/ipfs/go-ipfs/util/testutil/ci"
 )
@@ -113,7 +117,7 @@ func setupIpnsTest(t *testing.T, node *core.IpfsNode) (*core.IpfsNode, *fstest.M
 		}
 
 		node.Routing = offroute.NewOfflineRouter(node.Repo.Datastore(), node.PrivateKey)
-		node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore())
+		node.Namesys = namesys.NewNameSystem(node.Routing, node.Repo.Datastore(), node.Keystore(), namesys.Opts{})
 
 		ipnsfs, err := nsfs.NewFilesystem(context.Background(), node.DAG, node.Namesys, node.Pinning, node.PrivateKey)
 		if err != nil {
@@ -581,3 +585,192 @@ func TestMultiWrite(t *testing.T) {
 		t.Fatal("File on disk did not match bytes written")
 	}
 }
+
+// Test renaming files, over existing entries and across directories
+func TestRename(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	_, mnt := setupIpnsTest(t, nil)
+	defer mnt.Close()
+
+	base := mnt.Dir + "/local"
+	mkdir(t, base+"/dir")
+	dataA := writeFile(t, 300, base+"/a")
+	writeFile(t, 200, base+"/b")
+
+	err := os.Rename(base+"/a", base+"/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(base + "/a"); !os.IsNotExist(err) {
+		t.Fatal("old name still exists after rename:", err)
+	}
+	verifyFile(t, base+"/b", dataA)
+
+	err = os.Rename(base+"/b", base+"/dir/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyFile(t, base+"/dir/c", dataA)
+}
+
+// Test that only empty directories can be removed
+func TestRemoveDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	_, mnt := setupIpnsTest(t, nil)
+	defer mnt.Close()
+
+	dir := mnt.Dir + "/local/dir"
+	mkdir(t, dir)
+	writeFile(t, 100, dir+"/file")
+
+	if err := os.Remove(dir); err == nil {
+		t.Fatal("removed a directory that is not empty")
+	}
+
+	if err := os.Remove(dir + "/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("directory still exists after removal:", err)
+	}
+}
+
+// Test that fsync publishes the changes right away
+func TestFsyncPublishes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	node, mnt := setupIpnsTest(t, nil)
+	defer mnt.Close()
+
+	fi, err := os.Create(mnt.Dir + "/local/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fi.Close()
+
+	if _, err := fi.Write(randBytes(100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fi.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := node.IpnsFs.GetRoot(node.Identity.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd, err := root.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := nd.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := node.Namesys.Resolve(context.Background(), "/ipns/"+node.Identity.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != path.FromKey(k) {
+		t.Fatalf("published %s, expected the root %s", p, path.FromKey(k))
+	}
+}
+
+// Test that the files root can be worked on through its mount
+func TestFilesMount(t *testing.T) {
+	maybeSkipFuseTests(t)
+
+	node, err := core.NewNode(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewFilesFileSystem(node.FilesRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnt, err := fstest.MountedT(t, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mnt.Close()
+
+	mkdir(t, mnt.Dir+"/dir")
+	data := writeFile(t, 1000, mnt.Dir+"/dir/file")
+
+	nd, err := mfs.Lookup(node.FilesRoot, "/dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, ok := nd.(*mfs.File)
+	if !ok {
+		t.Fatal("expected a file in the files root")
+	}
+	size, err := fi.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Fatalf("files root has %d bytes, wrote %d", size, len(data))
+	}
+}
+
+// Test listing a directory larger than a single read of the kernel
+func TestLargeDirListing(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	node, mnt := setupIpnsTest(t, nil)
+	defer mnt.Close()
+
+	root, err := node.IpnsFs.GetRoot(node.Identity.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.Mkdir(root.Root, "/big", false); err != nil {
+		t.Fatal(err)
+	}
+
+	nentries := 1000
+	for i := 0; i < nentries; i++ {
+		var nd *dag.Node
+		if i%10 == 0 {
+			nd = &dag.Node{Data: ft.FolderPBData()}
+		} else {
+			nd = &dag.Node{Data: ft.FilePBData(nil, 0)}
+		}
+		if err := mfs.PutNode(root.Root, fmt.Sprintf("/big/entry%d", i), nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(mnt.Dir + "/local/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != nentries {
+		t.Fatalf("listed %d entries, expected %d", len(infos), nentries)
+	}
+	seen := make(map[string]bool)
+	for _, fi := range infos {
+		if seen[fi.Name()] {
+			t.Fatalf("%s listed twice", fi.Name())
+		}
+		seen[fi.Name()] = true
+
+		var i int
+		fmt.Sscanf(fi.Name(), "entry%d", &i)
+		if fi.IsDir() != (i%10 == 0) {
+			t.Fatalf("%s has the wrong type", fi.Name())
+		}
+	}
+}
@@ -1,7 +1,8 @@
 // +build !nofuse
 
 // package fuse/ipns implements a fuse filesystem that interfaces
-// with ipns, the naming system for ipfs.
+// with ipns, the naming system for ipfs. The same filesystem also mounts
+// the files root of a node, the tree the files commands work on.
 package ipns
 
 import (
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"syscall"
 
 	fuse "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
 	fs "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
@@ -19,6 +21,7 @@ import (
 	core "github.com/ipfs/go-ipfs/core"
 	nsfs "github.com/ipfs/go-ipfs/ipnsfs"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	mfs "github.com/ipfs/go-ipfs/mfs"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 )
@@ -85,10 +88,10 @@ func CreateRoot(ipfs *core.IpfsNode, keys []ci.PrivKey, ipfspath, ipnspath strin
 		roots[name] = root
 
 		switch val := root.GetValue().(type) {
-		case *nsfs.Directory:
-			ldirs[name] = &Directory{dir: val}
-		case *nsfs.File:
-			ldirs[name] = &File{fi: val}
+		case *mfs.Directory:
+			ldirs[name] = &Directory{dir: val, root: root}
+		case *mfs.File:
+			ldirs[name] = &File{fi: val, root: root}
 		default:
 			return nil, errors.New("unrecognized type")
 		}
@@ -202,16 +205,28 @@ func (r *Root) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	return listing, nil
 }
 
+// publisher is the root of the tree a node is in, either the root of a
+// keyspace or the files root.
+type publisher interface {
+	Publish(ctx context.Context) error
+}
+
 // Directory is wrapper over an ipnsfs directory to satisfy the fuse fs interface
 type Directory struct {
-	dir *nsfs.Directory
+	dir *mfs.Directory
+
+	// root is the tree the directory is in, published on fsync
+	root publisher
 
 	fs.NodeRef
 }
 
 // File is wrapper over an ipnsfs file to satisfy the fuse fs interface
 type File struct {
-	fi *nsfs.File
+	fi *mfs.File
+
+	// root is the tree the file is in, published on fsync
+	root publisher
 
 	fs.NodeRef
 }
@@ -220,7 +235,7 @@ type File struct {
 func (d *Directory) Attr(ctx context.Context, a *fuse.Attr) error {
 	log.Debug("Directory Attr")
 	*a = fuse.Attr{
-		Mode: os.ModeDir | 0555,
+		Mode: os.ModeDir | 0755,
 		Uid:  uint32(os.Getuid()),
 		Gid:  uint32(os.Getgid()),
 	}
@@ -253,10 +268,10 @@ func (s *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	}
 
 	switch child := child.(type) {
-	case *nsfs.Directory:
-		return &Directory{dir: child}, nil
-	case *nsfs.File:
-		return &File{fi: child}, nil
+	case *mfs.Directory:
+		return &Directory{dir: child, root: s.root}, nil
+	case *mfs.File:
+		return &File{fi: child, root: s.root}, nil
 	default:
 		// NB: if this happens, we do not want to continue, unpredictable behaviour
 		// may occur.
@@ -264,32 +279,33 @@ func (s *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	}
 }
 
-// ReadDirAll reads the link structure as directory entries
-func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	var entries []fuse.Dirent
-	for _, name := range dir.dir.List() {
-		dirent := fuse.Dirent{Name: name}
+// readdirPage is the number of entries listed at once when reading a
+// directory.
+const readdirPage = 64
 
-		// TODO: make dir.dir.List() return dirinfos
-		child, err := dir.dir.Child(name)
+// ReadDirAll reads the link structure as directory entries, a page at a
+// time, so the children of a large directory are fetched in batches rather
+// than one by one. fuse serves the kernel's reads of the directory from the
+// returned entries.
+func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var dirents []fuse.Dirent
+	for {
+		entries, err := dir.dir.ListEntries(ctx, len(dirents), readdirPage)
 		if err != nil {
 			return nil, err
 		}
-
-		switch child.Type() {
-		case nsfs.TDir:
-			dirent.Type = fuse.DT_Dir
-		case nsfs.TFile:
-			dirent.Type = fuse.DT_File
+		if len(entries) == 0 {
+			return dirents, nil
 		}
 
-		entries = append(entries, dirent)
-	}
-
-	if len(entries) > 0 {
-		return entries, nil
+		for _, e := range entries {
+			d := fuse.Dirent{Name: e.Name, Type: fuse.DT_File}
+			if e.Type == mfs.TDir {
+				d.Type = fuse.DT_Dir
+			}
+			dirents = append(dirents, d)
+		}
 	}
-	return nil, fuse.ENOENT
 }
 
 func (fi *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
@@ -339,6 +355,11 @@ func (fi *File) Flush(ctx context.Context, req *fuse.FlushRequest) error {
 }
 
 func (fi *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if !req.Valid.Size() {
+		// only truncation is supported, the other attributes are fixed
+		return nil
+	}
+
 	cursize, err := fi.fi.Size()
 	if err != nil {
 		return err
@@ -352,12 +373,17 @@ func (fi *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fus
 	return nil
 }
 
-// Fsync flushes the content in the file to disk, but does not
-// update the dag tree internally
+// Fsync propagates the changes to the file up the tree, and publishes
+// the new root of its tree right away instead of waiting for the
+// republisher.
 func (fi *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
 	errs := make(chan error, 1)
 	go func() {
-		errs <- fi.fi.Sync()
+		if err := fi.fi.Close(); err != nil {
+			errs <- err
+			return
+		}
+		errs <- fi.root.Publish(ctx)
 	}()
 	select {
 	case err := <-errs:
@@ -367,6 +393,12 @@ func (fi *File) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
 	}
 }
 
+// Fsync publishes the new root of the tree of the directory right away
+// instead of waiting for the republisher.
+func (dir *Directory) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	return dir.root.Publish(ctx)
+}
+
 func (fi *File) Forget() {
 	err := fi.fi.Sync()
 	if err != nil {
@@ -380,7 +412,7 @@ func (dir *Directory) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Nod
 		return nil, err
 	}
 
-	return &Directory{dir: child}, nil
+	return &Directory{dir: child, root: dir.root}, nil
 }
 
 func (fi *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
@@ -421,54 +453,75 @@ func (dir *Directory) Create(ctx context.Context, req *fuse.CreateRequest, resp
 		return nil, nil, err
 	}
 
-	fi, ok := child.(*nsfs.File)
+	fi, ok := child.(*mfs.File)
 	if !ok {
 		return nil, nil, errors.New("child creation failed")
 	}
 
-	nodechild := &File{fi: fi}
+	nodechild := &File{fi: fi, root: dir.root}
 	return nodechild, nodechild, nil
 }
 
 func (dir *Directory) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
-	err := dir.dir.Unlink(req.Name)
+	child, err := dir.dir.Child(req.Name)
 	if err != nil {
 		return fuse.ENOENT
 	}
-	return nil
+
+	if req.Dir {
+		cdir, ok := child.(*mfs.Directory)
+		if !ok {
+			return fuse.Errno(syscall.ENOTDIR)
+		}
+		if len(cdir.List()) > 0 {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	}
+
+	return dir.dir.Unlink(req.Name)
 }
 
-// Rename implements NodeRenamer
+// Rename implements NodeRenamer. Like rename(2), it replaces an existing
+// entry of the new name.
 func (dir *Directory) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	target, ok := newDir.(*Directory)
+	if !ok {
+		log.Error("Unknown node type for rename target dir!")
+		return fuse.Errno(syscall.ENOTDIR)
+	}
+	if target.dir == dir.dir && req.OldName == req.NewName {
+		return nil
+	}
+
 	cur, err := dir.dir.Child(req.OldName)
 	if err != nil {
-		return err
+		return fuse.ENOENT
 	}
 
-	err = dir.dir.Unlink(req.OldName)
+	// get the pending writes of an open file into its node before moving it
+	if fi, ok := cur.(*mfs.File); ok {
+		if err := fi.Close(); err != nil {
+			return err
+		}
+	}
+
+	nd, err := cur.GetNode()
 	if err != nil {
 		return err
 	}
 
-	switch newDir := newDir.(type) {
-	case *Directory:
-		nd, err := cur.GetNode()
-		if err != nil {
+	if _, err := target.dir.Child(req.NewName); err == nil {
+		if err := target.dir.Unlink(req.NewName); err != nil {
 			return err
 		}
+	}
 
-		err = newDir.dir.AddChild(req.NewName, nd)
-		if err != nil {
-			return err
-		}
-	case *File:
-		log.Error("Cannot move node into a file!")
-		return fuse.EPERM
-	default:
-		log.Error("Unknown node type for rename target dir!")
-		return errors.New("Unknown fs node type!")
+	err = target.dir.AddChild(req.NewName, nd.Copy())
+	if err != nil {
+		return err
 	}
-	return nil
+
+	return dir.dir.Unlink(req.OldName)
 }
 
 func min(a, b int) int {
@@ -495,6 +548,7 @@ type ipnsDirectory interface {
 	fs.NodeRemover
 	fs.NodeRenamer
 	fs.NodeStringLookuper
+	fs.NodeFsyncer
 }
 
 var _ ipnsDirectory = (*Directory)(nil)
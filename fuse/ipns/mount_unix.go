@@ -4,6 +4,8 @@
 package ipns
 
 import (
+	"errors"
+
 	core "github.com/ipfs/go-ipfs/core"
 	mount "github.com/ipfs/go-ipfs/fuse/mount"
 	ipnsfs "github.com/ipfs/go-ipfs/ipnsfs"
@@ -33,3 +35,23 @@ func Mount(ipfs *core.IpfsNode, ipnsmp, ipfsmp string) (mount.Mount, error) {
 
 	return mount.NewMount(ipfs.Process(), fsys, ipnsmp, allow_other)
 }
+
+// MountFiles mounts the files root of ipfs at a given location, and returns
+// a mount.Mount instance.
+func MountFiles(ipfs *core.IpfsNode, mountpoint string) (mount.Mount, error) {
+	cfg, err := ipfs.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if ipfs.FilesRoot == nil {
+		return nil, errors.New("node has no files root")
+	}
+
+	fsys, err := NewFilesFileSystem(ipfs.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return mount.NewMount(ipfs.Process(), fsys, mountpoint, cfg.Mounts.FuseAllowOther)
+}
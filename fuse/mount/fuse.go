@@ -64,6 +64,10 @@ func (m *mount) mount() error {
 		if err != nil {
 			errs <- err
 		}
+
+		// the filesystem is not served anymore, whether we unmounted it or
+		// it was unmounted from outside, so the mount is over
+		m.proc.Close()
 	}()
 
 	// wait for the mount process to be done, or timed out.
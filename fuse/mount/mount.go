@@ -4,8 +4,10 @@ package mount
 import (
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"runtime"
+	"syscall"
 	"time"
 
 	goprocess "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
@@ -31,9 +33,14 @@ type Mount interface {
 }
 
 // ForceUnmount attempts to forcibly unmount a given mount.
-// It does so by calling diskutil or fusermount directly.
+// It does so by calling diskutil, fusermount or net use directly.
 func ForceUnmount(m Mount) error {
-	point := m.MountPoint()
+	return ForceUnmountPath(m.MountPoint())
+}
+
+// ForceUnmountPath attempts to forcibly unmount whatever is mounted at
+// point, such as a mount left over by a process that crashed.
+func ForceUnmountPath(point string) error {
 	log.Warningf("Force-Unmounting %s...", point)
 
 	var cmd *exec.Cmd
@@ -42,6 +49,8 @@ func ForceUnmount(m Mount) error {
 		cmd = exec.Command("diskutil", "umount", "force", point)
 	case "linux":
 		cmd = exec.Command("fusermount", "-u", point)
+	case "windows":
+		cmd = exec.Command("net", "use", point, "/delete", "/y")
 	default:
 		return fmt.Errorf("unmount: unimplemented")
 	}
@@ -67,6 +76,45 @@ func ForceUnmount(m Mount) error {
 	}
 }
 
+// IsStale reports whether point is a mountpoint left over by a process that
+// is gone. Until it is unmounted, the kernel answers for it with "transport
+// endpoint is not connected", or "device not configured" on OS X.
+func IsStale(point string) bool {
+	_, err := os.Stat(point)
+	if perr, ok := err.(*os.PathError); ok {
+		return perr.Err == syscall.ENOTCONN || perr.Err == syscall.ENXIO
+	}
+	return false
+}
+
+// Check returns an error unless m is still mounted and answering: it was not
+// unmounted, and its mountpoint can be stat'ed within timeout. A filesystem
+// that hangs blocks the stat, which is why it runs aside.
+func Check(m Mount, timeout time.Duration) error {
+	point := m.MountPoint()
+	select {
+	case <-m.Process().Closed():
+		return fmt.Errorf("%s is not mounted anymore", point)
+	default:
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(point)
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			return fmt.Errorf("%s does not answer: %s", point, err)
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%s did not answer within %s", point, timeout)
+	}
+}
+
 // ForceUnmountManyTimes attempts to forcibly unmount a given mount,
 // many times. It does so by calling diskutil or fusermount directly.
 // Attempts a given number of times.
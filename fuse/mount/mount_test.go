@@ -0,0 +1,41 @@
+package mount
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	goprocess "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
+)
+
+type dirMount struct {
+	dir  string
+	proc goprocess.Process
+}
+
+func (m *dirMount) MountPoint() string         { return m.dir }
+func (m *dirMount) Unmount() error             { return m.proc.Close() }
+func (m *dirMount) Process() goprocess.Process { return m.proc }
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-mount-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if IsStale(dir) {
+		t.Fatalf("%s is a plain directory, not a stale mountpoint", dir)
+	}
+
+	m := &dirMount{dir: dir, proc: goprocess.WithParent(goprocess.Background())}
+	if err := Check(m, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Unmount()
+	if err := Check(m, time.Second); err == nil {
+		t.Fatal("expected the check of an unmounted mount to fail")
+	}
+}
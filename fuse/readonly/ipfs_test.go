@@ -263,3 +263,35 @@ func TestFileSizeReporting(t *testing.T) {
 		t.Fatal("Read incorrect size from stat!")
 	}
 }
+
+// Test reading a file out of order through a single handle
+func TestIpfsSeekRead(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	nd, mnt := setupIpfsTest(t, nil)
+	defer mnt.Close()
+
+	fi, data := randObj(t, nd, 1000000)
+	k, err := fi.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path.Join(mnt.Dir, k.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 5000)
+	for _, off := range []int64{500000, 0, 995000, 262144, 262144 - 100} {
+		n, err := f.ReadAt(buf, off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], data[off:off+int64(n)]) {
+			t.Fatalf("wrong data read at offset %d", off)
+		}
+	}
+}
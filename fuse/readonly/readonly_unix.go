@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"syscall"
+	"time"
 
 	fuse "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse"
 	fs "github.com/ipfs/go-ipfs/Godeps/_workspace/src/bazil.org/fuse/fs"
@@ -24,24 +26,43 @@ import (
 
 var log = logging.Logger("fuse/ipfs")
 
+const (
+	// dagCacheSize is the number of nodes the mount keeps in memory,
+	// shared by all the files open under it.
+	dagCacheSize = 256
+
+	// cacheValid is how long the kernel may cache the entries and the
+	// attributes of the mount. What is under /ipfs never changes.
+	cacheValid = time.Minute
+)
+
 // FileSystem is the readonly Ipfs Fuse Filesystem.
 type FileSystem struct {
 	Ipfs *core.IpfsNode
+
+	dag mdag.DAGService
 }
 
 // NewFileSystem constructs new fs using given core.IpfsNode instance.
 func NewFileSystem(ipfs *core.IpfsNode) *FileSystem {
-	return &FileSystem{Ipfs: ipfs}
+	dag, err := mdag.NewCachedDAGService(ipfs.DAG, dagCacheSize)
+	if err != nil {
+		log.Errorf("readonly: not caching nodes: %s", err)
+		dag = ipfs.DAG
+	}
+	return &FileSystem{Ipfs: ipfs, dag: dag}
 }
 
 // Root constructs the Root of the filesystem, a Root object.
 func (f FileSystem) Root() (fs.Node, error) {
-	return &Root{Ipfs: f.Ipfs}, nil
+	return &Root{Ipfs: f.Ipfs, dag: f.dag}, nil
 }
 
 // Root is the root object of the filesystem tree.
 type Root struct {
 	Ipfs *core.IpfsNode
+
+	dag mdag.DAGService
 }
 
 // Attr returns file attributes.
@@ -51,7 +72,8 @@ func (*Root) Attr(ctx context.Context, a *fuse.Attr) error {
 }
 
 // Lookup performs a lookup under this node.
-func (s *Root) Lookup(ctx context.Context, name string) (fs.Node, error) {
+func (s *Root) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	name := req.Name
 	log.Debugf("Root Lookup: '%s'", name)
 	switch name {
 	case "mach_kernel", ".hidden", "._.":
@@ -59,13 +81,15 @@ func (s *Root) Lookup(ctx context.Context, name string) (fs.Node, error) {
 		return nil, fuse.ENOENT
 	}
 
-	nd, err := s.Ipfs.Resolver.ResolvePath(ctx, path.Path(name))
+	resolver := &path.Resolver{DAG: s.dag}
+	nd, err := resolver.ResolvePath(ctx, path.Path(name))
 	if err != nil {
 		// todo: make this error more versatile.
 		return nil, fuse.ENOENT
 	}
 
-	return &Node{Ipfs: s.Ipfs, Nd: nd}, nil
+	resp.EntryValid = cacheValid
+	return &Node{Ipfs: s.Ipfs, Nd: nd, dag: s.dag}, nil
 }
 
 // ReadDirAll reads a particular directory. Disallowed for root.
@@ -78,8 +102,9 @@ func (*Root) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 type Node struct {
 	Ipfs   *core.IpfsNode
 	Nd     *mdag.Node
-	fd     *uio.DagReader
 	cached *ftpb.Data
+
+	dag mdag.DAGService
 }
 
 func (s *Node) loadData() error {
@@ -95,6 +120,7 @@ func (s *Node) Attr(ctx context.Context, a *fuse.Attr) error {
 			return fmt.Errorf("readonly: loadData() failed: %s", err)
 		}
 	}
+	a.Valid = cacheValid
 	switch s.cached.GetType() {
 	case ftpb.Data_Directory:
 		a.Mode = os.ModeDir | 0555
@@ -126,15 +152,17 @@ func (s *Node) Attr(ctx context.Context, a *fuse.Attr) error {
 }
 
 // Lookup performs a lookup under this node.
-func (s *Node) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	log.Debugf("Lookup '%s'", name)
-	nodes, err := s.Ipfs.Resolver.ResolveLinks(ctx, s.Nd, []string{name})
+func (s *Node) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	log.Debugf("Lookup '%s'", req.Name)
+	resolver := &path.Resolver{DAG: s.dag}
+	nodes, err := resolver.ResolveLinks(ctx, s.Nd, []string{req.Name})
 	if err != nil {
 		// todo: make this error more versatile.
 		return nil, fuse.ENOENT
 	}
 
-	return &Node{Ipfs: s.Ipfs, Nd: nodes[len(nodes)-1]}, nil
+	resp.EntryValid = cacheValid
+	return &Node{Ipfs: s.Ipfs, Nd: nodes[len(nodes)-1], dag: s.dag}, nil
 }
 
 // ReadDirAll reads the link structure as directory entries
@@ -162,9 +190,39 @@ func (s *Node) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string,
 	return string(s.cached.GetData()), nil
 }
 
-func (s *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+// Open opens a file for reading. Every open file keeps its own reader,
+// which fetches the blocks of the file ahead of the reads, so sequential
+// reads do not walk the dag again for every request.
+func (s *Node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if req.Dir {
+		return s, nil
+	}
+
+	// the reader outlives the open request, so it gets the context of the node
+	rctx, cancel := context.WithCancel(s.Ipfs.Context())
+	r, err := uio.NewDagReader(rctx, s.Nd, s.dag)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the content of a file never changes, the kernel may keep what it read
+	resp.Flags |= fuse.OpenKeepCache
+	return &fileHandle{node: s, r: r, cancel: cancel}, nil
+}
+
+// fileHandle is an open file, reading through a single DagReader.
+type fileHandle struct {
+	node   *Node
+	cancel func()
+
+	lk     sync.Mutex
+	r      *uio.DagReader
+	offset int64
+}
 
-	k, err := s.Nd.Key()
+func (h *fileHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	k, err := h.node.Nd.Key()
 	if err != nil {
 		return err
 	}
@@ -177,18 +235,29 @@ func (s *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	lm["req_size"] = req.Size
 	defer log.EventBegin(ctx, "fuseRead", lm).Done()
 
-	r, err := uio.NewDagReader(ctx, s.Nd, s.Ipfs.DAG)
-	if err != nil {
-		return err
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	size := int64(h.r.Size())
+	if req.Offset >= size {
+		resp.Data = resp.Data[:0]
+		return nil
 	}
-	o, err := r.Seek(req.Offset, os.SEEK_SET)
-	lm["res_offset"] = o
-	if err != nil {
-		return err
+
+	// only seek when the reads are not sequential, seeking drops
+	// what the reader fetched ahead
+	if req.Offset != h.offset {
+		o, err := h.r.Seek(req.Offset, os.SEEK_SET)
+		lm["res_offset"] = o
+		if err != nil {
+			return err
+		}
+		h.offset = o
 	}
 
-	buf := resp.Data[:min(req.Size, int(int64(r.Size())-req.Offset))]
-	n, err := io.ReadFull(r, buf)
+	buf := resp.Data[:min(req.Size, int(size-req.Offset))]
+	n, err := h.r.CtxReadFull(ctx, buf)
+	h.offset += int64(n)
 	if err != nil && err != io.EOF {
 		return err
 	}
@@ -197,25 +266,37 @@ func (s *Node) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	return nil // may be non-nil / not succeeded
 }
 
+func (h *fileHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	h.cancel()
+	return nil
+}
+
 // to check that out Node implements all the interfaces we want
 type roRoot interface {
 	fs.Node
 	fs.HandleReadDirAller
-	fs.NodeStringLookuper
+	fs.NodeRequestLookuper
 }
 
 var _ roRoot = (*Root)(nil)
 
 type roNode interface {
 	fs.HandleReadDirAller
-	fs.HandleReader
 	fs.Node
-	fs.NodeStringLookuper
+	fs.NodeOpener
+	fs.NodeRequestLookuper
 	fs.NodeReadlinker
 }
 
 var _ roNode = (*Node)(nil)
 
+type roFileHandle interface {
+	fs.HandleReader
+	fs.HandleReleaser
+}
+
+var _ roFileHandle = (*fileHandle)(nil)
+
 func min(a, b int) int {
 	if a < b {
 		return a
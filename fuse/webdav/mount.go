@@ -0,0 +1,86 @@
+package webdav
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+
+	goprocess "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mount "github.com/ipfs/go-ipfs/fuse/mount"
+)
+
+// davMount is a WebDAV share served on a local port, mapped to a drive.
+type davMount struct {
+	mpoint   string
+	listener net.Listener
+	proc     goprocess.Process
+}
+
+// Mount serves the namespace prefix of ipfs, /ipfs or /ipns, on a local
+// port, and maps the share to the drive mountpoint, such as "I:". The
+// drive is unmapped and the server stopped with the node.
+func Mount(ipfs *core.IpfsNode, prefix, mountpoint string) (mount.Mount, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := http.Serve(l, NewHandler(ipfs, prefix))
+		log.Debugf("webdav: serving %s returned: %s", mountpoint, err)
+	}()
+
+	share := fmt.Sprintf("http://localhost:%d/", l.Addr().(*net.TCPAddr).Port)
+	if err := netUse(mountpoint, share, "/persistent:no"); err != nil {
+		l.Close()
+		return nil, err
+	}
+	log.Infof("Mounted %s at %s", share, mountpoint)
+
+	m := &davMount{
+		mpoint:   mountpoint,
+		listener: l,
+		proc:     goprocess.WithParent(ipfs.Process()),
+	}
+	m.proc.SetTeardown(m.unmount)
+	return m, nil
+}
+
+func (m *davMount) unmount() error {
+	err := netUse(m.mpoint, "/delete", "/y")
+	if cerr := m.listener.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (m *davMount) MountPoint() string {
+	return m.mpoint
+}
+
+func (m *davMount) Unmount() error {
+	return m.proc.Close()
+}
+
+func (m *davMount) Process() goprocess.Process {
+	return m.proc
+}
+
+// netUse runs "net use" with the given arguments, which maps and unmaps
+// the network drives of Windows.
+func netUse(args ...string) error {
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("webdav: mapping drives is only supported on windows")
+	}
+
+	out, err := exec.Command("net", append([]string{"use"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("net use %s: %s: %s", args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
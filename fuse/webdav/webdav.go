@@ -0,0 +1,263 @@
+// package fuse/webdav serves the ipfs and ipns namespaces as read-only
+// WebDAV shares, to mount them where fuse is not available, as on Windows.
+package webdav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	gopath "path"
+	"strings"
+	"time"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/gogo/protobuf/proto"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+
+	core "github.com/ipfs/go-ipfs/core"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
+)
+
+var log = logging.Logger("fuse/webdav")
+
+// allowedMethods are the methods of a read-only share.
+const allowedMethods = "OPTIONS, GET, HEAD, PROPFIND"
+
+// modTime is the modification time reported for every entry. Objects are
+// immutable, and have no time of their own.
+var modTime = time.Unix(0, 0).UTC()
+
+// Handler serves the objects under a namespace, /ipfs or /ipns, over
+// WebDAV. Like the fuse mounts, the root is not listable, objects are
+// reached by their path under it.
+type Handler struct {
+	node   *core.IpfsNode
+	prefix string
+}
+
+// NewHandler returns a Handler for the namespace prefix of the node.
+func NewHandler(node *core.IpfsNode, prefix string) *Handler {
+	return &Handler{node: node, prefix: prefix}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1")
+	w.Header().Set("MS-Author-Via", "DAV")
+
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("Allow", allowedMethods)
+	case "GET", "HEAD":
+		h.serveGet(w, r)
+	case "PROPFIND":
+		h.servePropfind(w, r)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "read-only filesystem", http.StatusMethodNotAllowed)
+	}
+}
+
+// resolve returns the node at the path p of the request, relative to the
+// namespace of the handler.
+func (h *Handler) resolve(ctx context.Context, p string) (*mdag.Node, error) {
+	return core.Resolve(ctx, h.node, path.Path(h.prefix+p))
+}
+
+func (h *Handler) serveGet(w http.ResponseWriter, r *http.Request) {
+	p := gopath.Clean(r.URL.Path)
+	if p == "/" {
+		http.Error(w, "the root is not listable", http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(h.node.Context())
+	defer cancel()
+
+	nd, err := h.resolve(ctx, p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	dr, err := uio.NewDagReader(ctx, nd, h.node.DAG)
+	if err == uio.ErrIsDir {
+		http.Error(w, "is a directory", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer dr.Close()
+
+	k, err := nd.Key()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Etag", `"`+k.String()+`"`)
+	http.ServeContent(w, r, gopath.Base(p), modTime, dagReadSeeker{dr})
+}
+
+// dagReadSeeker answers the seek to the end http.ServeContent makes to learn
+// the size, without moving the reader past the last block.
+type dagReadSeeker struct {
+	*uio.DagReader
+}
+
+func (r dagReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == os.SEEK_END && offset == 0 {
+		return int64(r.Size()), nil
+	}
+	return r.DagReader.Seek(offset, whence)
+}
+
+func (h *Handler) servePropfind(w http.ResponseWriter, r *http.Request) {
+	p := gopath.Clean(r.URL.Path)
+	depth := r.Header.Get("Depth")
+
+	ms := &multistatus{XMLNS: "DAV:"}
+	if p == "/" {
+		// the root only lists itself
+		ms.Responses = append(ms.Responses, collectionResponse("/"))
+		writeMultistatus(w, ms)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(h.node.Context())
+	defer cancel()
+
+	nd, err := h.resolve(ctx, p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	res, dir, err := nodeResponse(p, nd)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ms.Responses = append(ms.Responses, res)
+
+	if dir && depth != "0" {
+		// fetch the children at once, instead of one after the other
+		for i, promise := range h.node.DAG.GetDAG(ctx, nd) {
+			child, err := promise.Get(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res, _, err := nodeResponse(gopath.Join(p, nd.Links[i].Name), child)
+			if err != nil {
+				log.Debugf("webdav: skipping %s: %s", nd.Links[i].Name, err)
+				continue
+			}
+			ms.Responses = append(ms.Responses, res)
+		}
+	}
+	writeMultistatus(w, ms)
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	XMLNS     string     `xml:"xmlns:D,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+type response struct {
+	Href     string   `xml:"D:href"`
+	Propstat propstat `xml:"D:propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"D:prop"`
+	Status string `xml:"D:status"`
+}
+
+type prop struct {
+	DisplayName   string       `xml:"D:displayname"`
+	ResourceType  resourceType `xml:"D:resourcetype"`
+	ContentLength *uint64      `xml:"D:getcontentlength,omitempty"`
+	LastModified  string       `xml:"D:getlastmodified"`
+	ETag          string       `xml:"D:getetag,omitempty"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+// href returns the escaped URL path of p, with a trailing slash for
+// collections.
+func href(p string, dir bool) string {
+	if dir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return (&url.URL{Path: p}).String()
+}
+
+func collectionResponse(p string) response {
+	return response{
+		Href: href(p, true),
+		Propstat: propstat{
+			Prop: prop{
+				DisplayName:  gopath.Base(p),
+				ResourceType: resourceType{Collection: &struct{}{}},
+				LastModified: modTime.Format(http.TimeFormat),
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+// nodeResponse describes the unixfs node nd at the path p, and whether it
+// is a directory.
+func nodeResponse(p string, nd *mdag.Node) (response, bool, error) {
+	pb := new(ftpb.Data)
+	if err := proto.Unmarshal(nd.Data, pb); err != nil {
+		return response{}, false, err
+	}
+
+	var size uint64
+	switch pb.GetType() {
+	case ftpb.Data_Directory:
+		return collectionResponse(p), true, nil
+	case ftpb.Data_File:
+		size = pb.GetFilesize()
+	case ftpb.Data_Raw, ftpb.Data_Symlink:
+		size = uint64(len(pb.GetData()))
+	default:
+		return response{}, false, fmt.Errorf("unsupported unixfs type %s", pb.GetType())
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		return response{}, false, err
+	}
+	return response{
+		Href: href(p, false),
+		Propstat: propstat{
+			Prop: prop{
+				DisplayName:   gopath.Base(p),
+				ContentLength: &size,
+				LastModified:  modTime.Format(http.TimeFormat),
+				ETag:          `"` + k.String() + `"`,
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}, false, nil
+}
+
+func writeMultistatus(w http.ResponseWriter, ms *multistatus) {
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(207) // Multi-Status
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(ms); err != nil {
+		log.Debugf("webdav: writing multistatus: %s", err)
+	}
+}
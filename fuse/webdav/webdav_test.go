@@ -0,0 +1,101 @@
+package webdav
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	coremock "github.com/ipfs/go-ipfs/core/mock"
+	importer "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+func TestHandler(t *testing.T) {
+	nd, err := coremock.NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 300000)
+	u.NewTimeSeededRand().Read(data)
+	fi, err := importer.BuildDagFromReader(nd.DAG, chunk.DefaultSplitter(bytes.NewReader(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fk, err := fi.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := uio.NewDirectory(nd.DAG)
+	if err := db.AddChild(nd.Context(), "a file", fk); err != nil {
+		t.Fatal(err)
+	}
+	dk, err := nd.DAG.Add(db.GetNode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(NewHandler(nd, "/ipfs"))
+	defer srv.Close()
+
+	do := func(method, p string, header map[string]string) (*http.Response, string) {
+		req, err := http.NewRequest(method, srv.URL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := do("PROPFIND", "/"+dk.String(), map[string]string{"Depth": "1"})
+	if resp.StatusCode != 207 {
+		t.Fatalf("PROPFIND: status %d: %s", resp.StatusCode, body)
+	}
+	for _, want := range []string{
+		"<D:href>/" + dk.String() + "/</D:href>",
+		"<D:href>/" + dk.String() + "/a%20file</D:href>",
+		"<D:collection></D:collection>",
+		"<D:getcontentlength>300000</D:getcontentlength>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("PROPFIND: %q not in %s", want, body)
+		}
+	}
+
+	resp, body = do("GET", "/"+dk.String()+"/a%20file", nil)
+	if resp.StatusCode != http.StatusOK || body != string(data) {
+		t.Fatalf("GET: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	resp, body = do("GET", "/"+fk.String(), map[string]string{"Range": "bytes=262100-262199"})
+	if resp.StatusCode != http.StatusPartialContent || body != string(data[262100:262200]) {
+		t.Fatalf("GET range: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	resp, body = do("PROPFIND", "/", map[string]string{"Depth": "1"})
+	if resp.StatusCode != 207 || strings.Count(body, "<D:response>") != 1 {
+		t.Fatalf("PROPFIND of the root: status %d: %s", resp.StatusCode, body)
+	}
+
+	resp, _ = do("PUT", "/"+fk.String(), nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT: status %d, expected the share to be read-only", resp.StatusCode)
+	}
+}
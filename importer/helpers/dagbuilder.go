@@ -26,6 +26,10 @@ type DagBuilderHelper struct {
 	ncb      NodeCB
 
 	batch *dag.Batch
+
+	refs   RefStore
+	fpath  string
+	offset uint64 // of the next data in the file
 }
 
 type DagBuilderParams struct {
@@ -37,6 +41,12 @@ type DagBuilderParams struct {
 
 	// Callback for each block added
 	NodeCB NodeCB
+
+	// RefStore, if set, stores the leaf nodes as references to their data
+	// in the file at FilePath, which the input must be read from, instead
+	// of the leaves being added to Dagserv.
+	RefStore RefStore
+	FilePath string
 }
 
 // Generate a new DagBuilderHelper from the given params, using 'in' as a
@@ -54,6 +64,8 @@ func (dbp *DagBuilderParams) New(in <-chan []byte, errs <-chan error) *DagBuilde
 		maxlinks: dbp.Maxlinks,
 		ncb:      ncb,
 		batch:    dbp.Dagserv.Batch(),
+		refs:     dbp.RefStore,
+		fpath:    dbp.FilePath,
 	}
 }
 
@@ -136,6 +148,11 @@ func (db *DagBuilderHelper) FillNodeWithData(node *UnixfsNode) error {
 	}
 
 	node.SetData(data)
+	if db.refs != nil {
+		node.offset = db.offset
+		node.isFileRef = true
+	}
+	db.offset += uint64(len(data))
 	return nil
 }
 
@@ -145,7 +162,11 @@ func (db *DagBuilderHelper) Add(node *UnixfsNode) (*dag.Node, error) {
 		return nil, err
 	}
 
-	_, err = db.dserv.Add(dn)
+	if node.isFileRef {
+		err = db.refs.PutRef(dn, db.fpath, node.offset)
+	} else {
+		_, err = db.dserv.Add(dn)
+	}
 	if err != nil {
 		return nil, err
 	}
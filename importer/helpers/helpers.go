@@ -36,11 +36,21 @@ var DefaultLinksPerBlock = (roughLinkBlockSize / roughLinkSize)
 // ErrSizeLimitExceeded signals that a block is larger than BlockSizeLimit.
 var ErrSizeLimitExceeded = fmt.Errorf("object size limit exceeded")
 
+// RefStore stores leaf nodes as references to the file their data was
+// read from, rather than copying the data.
+type RefStore interface {
+	PutRef(nd *dag.Node, path string, offset uint64) error
+}
+
 // UnixfsNode is a struct created to aid in the generation
 // of unixfs DAG trees
 type UnixfsNode struct {
 	node *dag.Node
 	ufmt *ft.FSNode
+
+	// set for leaves stored as references into the imported file
+	isFileRef bool
+	offset    uint64
 }
 
 // NewUnixfsNode creates a new Unixfs node to represent a file
@@ -103,7 +113,11 @@ func (n *UnixfsNode) AddChild(child *UnixfsNode, db *DagBuilderHelper) error {
 		return err
 	}
 
-	_, err = db.batch.Add(childnode)
+	if child.isFileRef {
+		err = db.refs.PutRef(childnode, db.fpath, child.offset)
+	} else {
+		_, err = db.batch.Add(childnode)
+	}
 	if err != nil {
 		return err
 	}
@@ -124,7 +138,7 @@ func (n *UnixfsNode) RemoveChild(index int, dbh *DagBuilderHelper) {
 		dbh.mp.RemovePinWithMode(k, pin.Indirect)
 	}
 	n.ufmt.RemoveBlockSize(index)
-	n.node.Links = append(n.node.Links[:index], n.node.Links[index+1:]...)
+	n.node.SetLinks(append(n.node.Links[:index], n.node.Links[index+1:]...))
 }
 
 func (n *UnixfsNode) SetData(data []byte) {
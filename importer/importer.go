@@ -65,6 +65,40 @@ func BuildTrickleDagFromReader(ds dag.DAGService, spl chunk.Splitter, ncb h.Node
 	return trickle.TrickleLayout(dbp.New(blkch, errch))
 }
 
+// BuildDagFromReaderNoCopy is like BuildDagFromReader, but instead of
+// copying the data of the file at fpath into ds, stores the leaf nodes in
+// refs as references into the file. spl must read the whole file from its
+// start.
+func BuildDagFromReaderNoCopy(ds dag.DAGService, refs h.RefStore, fpath string, spl chunk.Splitter, ncb h.NodeCB) (*dag.Node, error) {
+	blkch, errch := chunk.Chan(spl)
+
+	dbp := h.DagBuilderParams{
+		Dagserv:  ds,
+		Maxlinks: h.DefaultLinksPerBlock,
+		NodeCB:   ncb,
+		RefStore: refs,
+		FilePath: fpath,
+	}
+
+	return bal.BalancedLayout(dbp.New(blkch, errch))
+}
+
+// BuildTrickleDagFromReaderNoCopy is the trickle-dag counterpart of
+// BuildDagFromReaderNoCopy.
+func BuildTrickleDagFromReaderNoCopy(ds dag.DAGService, refs h.RefStore, fpath string, spl chunk.Splitter, ncb h.NodeCB) (*dag.Node, error) {
+	blkch, errch := chunk.Chan(spl)
+
+	dbp := h.DagBuilderParams{
+		Dagserv:  ds,
+		Maxlinks: h.DefaultLinksPerBlock,
+		NodeCB:   ncb,
+		RefStore: refs,
+		FilePath: fpath,
+	}
+
+	return trickle.TrickleLayout(dbp.New(blkch, errch))
+}
+
 func BasicPinnerCB(p pin.ManualPinner) h.NodeCB {
 	return func(n *dag.Node, last bool) error {
 		k, err := n.Key()
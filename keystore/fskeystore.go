@@ -0,0 +1,119 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+// FSKeystore keeps each key in a file named after it, readable only by
+// its owner.
+type FSKeystore struct {
+	dir string
+}
+
+var _ Keystore = (*FSKeystore)(nil)
+
+// NewFSKeystore returns a keystore keeping its keys in dir, which is
+// created if needed.
+func NewFSKeystore(dir string) (*FSKeystore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FSKeystore{dir: dir}, nil
+}
+
+func (ks *FSKeystore) path(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(ks.dir, name), nil
+}
+
+func (ks *FSKeystore) Has(name string) (bool, error) {
+	p, err := ks.path(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (ks *FSKeystore) Put(name string, k ci.PrivKey) error {
+	p, err := ks.path(name)
+	if err != nil {
+		return err
+	}
+	b, err := ci.MarshalPrivateKey(k)
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL so concurrent puts can't overwrite each other
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if os.IsExist(err) {
+		return ErrKeyExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(p)
+		return err
+	}
+	return f.Close()
+}
+
+func (ks *FSKeystore) Get(name string) (ci.PrivKey, error) {
+	p, err := ks.path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNoSuchKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ci.UnmarshalPrivateKey(b)
+}
+
+func (ks *FSKeystore) Delete(name string) error {
+	p, err := ks.path(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return ErrNoSuchKey
+	}
+	return err
+}
+
+func (ks *FSKeystore) List() ([]string, error) {
+	d, err := os.Open(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, n := range names {
+		if !strings.HasPrefix(n, ".") {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+// Package keystore stores the named keypairs a node has besides its
+// identity, such as those it publishes extra IPNS names with.
+package keystore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+// SelfKey names the node's own identity, which is kept in the config and
+// not in the keystore.
+const SelfKey = "self"
+
+var (
+	// ErrNoSuchKey is returned when there is no key with the given name.
+	ErrNoSuchKey = errors.New("no key by the given name was found")
+
+	// ErrKeyExists is returned when a key with the given name already
+	// exists.
+	ErrKeyExists = errors.New("key by that name already exists, refusing to overwrite")
+)
+
+// Keystore stores private keys by name.
+type Keystore interface {
+	// Has returns whether a key with the given name exists.
+	Has(name string) (bool, error)
+	// Put stores a key under the given name, which must not be taken.
+	Put(name string, k ci.PrivKey) error
+	// Get returns the key with the given name.
+	Get(name string) (ci.PrivKey, error)
+	// Delete removes the key with the given name.
+	Delete(name string) error
+	// List returns the names of all the keys.
+	List() ([]string, error)
+}
+
+// ValidateName returns an error if name can't name a key. Names can't be
+// empty, start with a dot, hold a slash, or be SelfKey.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("key names must not be empty")
+	case name == SelfKey:
+		return fmt.Errorf("the key name %q is reserved for the node's identity", SelfKey)
+	case strings.HasPrefix(name, "."):
+		return errors.New("key names must not start with '.'")
+	case strings.ContainsAny(name, "/\\"):
+		return errors.New("key names must not contain slashes")
+	}
+	return nil
+}
+
+// Create generates a keypair of the given type and size, and stores it
+// under name.
+func Create(ks Keystore, name string, typ, bits int) (ci.PrivKey, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if has, err := ks.Has(name); err != nil {
+		return nil, err
+	} else if has {
+		return nil, ErrKeyExists
+	}
+
+	sk, _, err := ci.GenerateKeyPair(typ, bits)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Put(name, sk); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+// Rename moves the key named oldName to newName, which must not be taken.
+func Rename(ks Keystore, oldName, newName string) error {
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+	sk, err := ks.Get(oldName)
+	if err != nil {
+		return err
+	}
+	if err := ks.Put(newName, sk); err != nil {
+		return err
+	}
+	return ks.Delete(oldName)
+}
@@ -0,0 +1,112 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+func testKeystore(t *testing.T, ks Keystore) {
+	a, err := Create(ks, "a", ci.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(ks, "a", ci.RSA, 512); err != ErrKeyExists {
+		t.Fatalf("expected ErrKeyExists, got %v", err)
+	}
+	for _, bad := range []string{"", SelfKey, ".hidden", "a/b"} {
+		if _, err := Create(ks, bad, ci.RSA, 512); err == nil {
+			t.Errorf("created a key named %q", bad)
+		}
+	}
+
+	if err := Rename(ks, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := ks.Has("a"); has {
+		t.Fatal("renamed key still has its old name")
+	}
+	b, err := ks.Get("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equals(a) {
+		t.Fatal("renamed key changed")
+	}
+
+	if _, err := Create(ks, "c", ci.RSA, 512); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Fatalf("wrong key names: %v", names)
+	}
+
+	if err := ks.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Delete("b"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+	if _, err := ks.Get("b"); err != ErrNoSuchKey {
+		t.Fatalf("expected ErrNoSuchKey, got %v", err)
+	}
+}
+
+func TestFSKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks, err := NewFSKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKeystore(t, ks)
+}
+
+func TestMemKeystore(t *testing.T) {
+	testKeystore(t, NewMemKeystore())
+}
+
+func TestWithSelf(t *testing.T) {
+	self, _, err := ci.GenerateKeyPair(ci.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := WithSelf(NewMemKeystore(), self)
+
+	sk, err := ks.Get(SelfKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.Equals(self) {
+		t.Fatal("got the wrong self key")
+	}
+	if err := ks.Put(SelfKey, self); err == nil {
+		t.Fatal("replaced the self key")
+	}
+	if err := ks.Delete(SelfKey); err == nil {
+		t.Fatal("removed the self key")
+	}
+
+	if _, err := Create(ks, "other", ci.RSA, 512); err != nil {
+		t.Fatal(err)
+	}
+	names, err := ks.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != SelfKey || names[1] != "other" {
+		t.Fatalf("wrong key names: %v", names)
+	}
+}
@@ -0,0 +1,70 @@
+package keystore
+
+import (
+	"sync"
+
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+// MemKeystore keeps keys in memory, for tests.
+type MemKeystore struct {
+	lk   sync.Mutex
+	keys map[string]ci.PrivKey
+}
+
+var _ Keystore = (*MemKeystore)(nil)
+
+// NewMemKeystore returns an empty in-memory keystore.
+func NewMemKeystore() *MemKeystore {
+	return &MemKeystore{keys: make(map[string]ci.PrivKey)}
+}
+
+func (ks *MemKeystore) Has(name string) (bool, error) {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	_, ok := ks.keys[name]
+	return ok, nil
+}
+
+func (ks *MemKeystore) Put(name string, k ci.PrivKey) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	if _, ok := ks.keys[name]; ok {
+		return ErrKeyExists
+	}
+	ks.keys[name] = k
+	return nil
+}
+
+func (ks *MemKeystore) Get(name string) (ci.PrivKey, error) {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	k, ok := ks.keys[name]
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+	return k, nil
+}
+
+func (ks *MemKeystore) Delete(name string) error {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	if _, ok := ks.keys[name]; !ok {
+		return ErrNoSuchKey
+	}
+	delete(ks.keys, name)
+	return nil
+}
+
+func (ks *MemKeystore) List() ([]string, error) {
+	ks.lk.Lock()
+	defer ks.lk.Unlock()
+	names := make([]string, 0, len(ks.keys))
+	for n := range ks.keys {
+		names = append(names, n)
+	}
+	return names, nil
+}
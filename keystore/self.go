@@ -0,0 +1,64 @@
+package keystore
+
+import (
+	"fmt"
+
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+// WithSelf returns a keystore holding the keys of ks, and the node's own
+// key sk as SelfKey, which can't be changed. A nil ks holds no other keys.
+func WithSelf(ks Keystore, sk ci.PrivKey) Keystore {
+	if ks == nil {
+		ks = NewMemKeystore()
+	}
+	return &selfKeystore{Keystore: ks, self: sk}
+}
+
+type selfKeystore struct {
+	Keystore
+	self ci.PrivKey
+}
+
+func (ks *selfKeystore) Has(name string) (bool, error) {
+	if name == SelfKey {
+		return ks.self != nil, nil
+	}
+	return ks.Keystore.Has(name)
+}
+
+func (ks *selfKeystore) Put(name string, k ci.PrivKey) error {
+	if name == SelfKey {
+		return fmt.Errorf("cannot replace the %q key", SelfKey)
+	}
+	return ks.Keystore.Put(name, k)
+}
+
+func (ks *selfKeystore) Get(name string) (ci.PrivKey, error) {
+	if name == SelfKey {
+		if ks.self == nil {
+			return nil, ErrNoSuchKey
+		}
+		return ks.self, nil
+	}
+	return ks.Keystore.Get(name)
+}
+
+func (ks *selfKeystore) Delete(name string) error {
+	if name == SelfKey {
+		return fmt.Errorf("cannot remove the %q key", SelfKey)
+	}
+	return ks.Keystore.Delete(name)
+}
+
+// List returns SelfKey first, followed by the names of the other keys.
+func (ks *selfKeystore) List() ([]string, error) {
+	names, err := ks.Keystore.List()
+	if err != nil {
+		return nil, err
+	}
+	if ks.self == nil {
+		return names, nil
+	}
+	return append([]string{SelfKey}, names...), nil
+}
@@ -0,0 +1,138 @@
+package merkledag
+
+import (
+	lru "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/hashicorp/golang-lru"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// NewCachedDAGService returns a DAGService that keeps up to |size| decoded
+// nodes in memory, avoiding repeated fetching and decoding of frequently
+// accessed nodes.
+func NewCachedDAGService(ds DAGService, size int) (DAGService, error) {
+	c, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedDagService{DAGService: ds, cache: c}, nil
+}
+
+type cachedDagService struct {
+	DAGService
+	cache *lru.Cache // pointer b/c Cache contains a Mutex as value (complicates copying)
+}
+
+func (c *cachedDagService) Add(nd DAGNode) (key.Key, error) {
+	k, err := c.DAGService.Add(nd)
+	if err != nil {
+		return "", err
+	}
+	c.cache.Remove(k)
+	return k, nil
+}
+
+// Get returns a copy of the cached node if present, so callers are free to
+// modify the returned node without corrupting the cache.
+func (c *cachedDagService) Get(ctx context.Context, k key.Key) (*Node, error) {
+	if v, ok := c.cache.Get(k); ok {
+		return v.(*Node).Copy(), nil
+	}
+
+	nd, err := c.DAGService.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Add(k, nd.Copy())
+	return nd, nil
+}
+
+// GetDAG returns the promises of the children of root, as GetNodes does.
+func (c *cachedDagService) GetDAG(ctx context.Context, root DAGNode) []NodeGetter {
+	var keys []key.Key
+	for _, lnk := range root.GetLinks() {
+		keys = append(keys, key.Key(lnk.Hash))
+	}
+	return c.GetNodes(ctx, keys)
+}
+
+// GetNodes serves the cached nodes right away, and only fetches the others,
+// caching them as they are received.
+func (c *cachedDagService) GetNodes(ctx context.Context, keys []key.Key) []NodeGetter {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	promises := make([]NodeGetter, len(keys))
+	var missing []key.Key
+	var missingIdx []int
+	for i, k := range keys {
+		if v, ok := c.cache.Get(k); ok {
+			promises[i] = &cachedGetter{nd: v.(*Node).Copy()}
+			continue
+		}
+		missing = append(missing, k)
+		missingIdx = append(missingIdx, i)
+	}
+
+	fetched := c.DAGService.GetNodes(ctx, missing)
+	for j, i := range missingIdx {
+		promises[i] = &cachingGetter{NodeGetter: fetched[j], k: missing[j], cache: c}
+	}
+	return promises
+}
+
+// cachedGetter is the promise of a node taken from the cache.
+type cachedGetter struct {
+	nd *Node
+}
+
+func (g *cachedGetter) Get(context.Context) (*Node, error) {
+	return g.nd, nil
+}
+
+// cachingGetter adds the node of its promise to the cache once received.
+type cachingGetter struct {
+	NodeGetter
+	k     key.Key
+	cache *cachedDagService
+}
+
+func (g *cachingGetter) Get(ctx context.Context) (*Node, error) {
+	nd, err := g.NodeGetter.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.cache.cache.Add(g.k, nd.Copy())
+	return nd, nil
+}
+
+func (c *cachedDagService) Session(ctx context.Context) *Session {
+	return newSession(ctx, c)
+}
+
+func (c *cachedDagService) Remove(nd DAGNode) error {
+	if err := c.invalidate(nd); err != nil {
+		return err
+	}
+	return c.DAGService.Remove(nd)
+}
+
+// invalidate drops the given node, and any in-memory children that will be
+// removed along with it, from the cache.
+func (c *cachedDagService) invalidate(nd DAGNode) error {
+	for _, l := range nd.GetLinks() {
+		if l.Node != nil {
+			if err := c.invalidate(l.Node); err != nil {
+				return err
+			}
+		}
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		return err
+	}
+	c.cache.Remove(k)
+	return nil
+}
@@ -32,6 +32,7 @@ func (n *Node) Unmarshal(encoded []byte) error {
 		n.Links[i].Hash = h
 	}
 	sort.Stable(LinkSlice(n.Links)) // keep links sorted
+	n.invalidateLinkIndex()
 
 	n.Data = pbn.GetData()
 	return nil
@@ -15,18 +15,33 @@ import (
 var log = logging.Logger("merkledag")
 var ErrNotFound = fmt.Errorf("merkledag: not found")
 
+// ErrNodeTooLarge is returned when trying to add a node whose encoded size
+// exceeds MaxNodeSize.
+var ErrNodeTooLarge = fmt.Errorf("merkledag: node exceeds maximum size")
+
+// MaxNodeSize is the maximum encoded size of a node the DAGService will
+// accept. Larger nodes cannot be reliably transferred to other peers.
+var MaxNodeSize = 2 << 20 // 2MB
+
 // DAGService is an IPFS Merkle DAG service.
+//
+// Nodes of any format are stored and walked through the DAGNode interface.
+// Nodes are fetched as *Node, the only format that can be decoded yet.
 type DAGService interface {
-	Add(*Node) (key.Key, error)
-	AddRecursive(*Node) error
+	Add(DAGNode) (key.Key, error)
+	AddRecursive(DAGNode) error
 	Get(context.Context, key.Key) (*Node, error)
-	Remove(*Node) error
+	Remove(DAGNode) error
 
 	// GetDAG returns, in order, all the single leve child
 	// nodes of the passed in node.
-	GetDAG(context.Context, *Node) []NodeGetter
+	GetDAG(context.Context, DAGNode) []NodeGetter
 	GetNodes(context.Context, []key.Key) []NodeGetter
 
+	// Session returns a Session whose fetches share state for as long as
+	// the given context is alive.
+	Session(context.Context) *Session
+
 	Batch() *Batch
 }
 
@@ -44,48 +59,58 @@ type dagService struct {
 }
 
 // Add adds a node to the dagService, storing the block in the BlockService
-func (n *dagService) Add(nd *Node) (key.Key, error) {
+func (n *dagService) Add(nd DAGNode) (key.Key, error) {
 	if n == nil { // FIXME remove this assertion. protect with constructor invariant
 		return "", fmt.Errorf("dagService is nil")
 	}
 
-	d, err := nd.Encoded(false)
+	b, err := nodeToBlock(nd)
 	if err != nil {
 		return "", err
 	}
 
+	return n.Blocks.AddBlock(b)
+}
+
+// nodeToBlock encodes the given node into a block, ensuring it does not
+// exceed MaxNodeSize.
+func nodeToBlock(nd DAGNode) (*blocks.Block, error) {
+	d, err := nd.Encoded(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d) > MaxNodeSize {
+		return nil, ErrNodeTooLarge
+	}
+
 	b := new(blocks.Block)
 	b.Data = d
 	b.Multihash, err = nd.Multihash()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return n.Blocks.AddBlock(b)
+	return b, nil
+}
+
+func (n *dagService) Session(ctx context.Context) *Session {
+	return newSession(ctx, n)
 }
 
 func (n *dagService) Batch() *Batch {
 	return &Batch{ds: n, MaxSize: 8 * 1024 * 1024}
 }
 
-// AddRecursive adds the given node and all child nodes to the BlockService
-func (n *dagService) AddRecursive(nd *Node) error {
-	_, err := n.Add(nd)
-	if err != nil {
+// AddRecursive adds the given node and all child nodes to the BlockService,
+// writing them out in batches.
+func (n *dagService) AddRecursive(nd DAGNode) error {
+	b := n.Batch()
+	if err := b.addRecursive(nd); err != nil {
 		log.Info("AddRecursive Error: %s\n", err)
 		return err
 	}
-
-	for _, link := range nd.Links {
-		if link.Node != nil {
-			err := n.AddRecursive(link.Node)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	return b.Commit()
 }
 
 // Get retrieves a node from the dagService, fetching the block in the BlockService
@@ -108,8 +133,8 @@ func (n *dagService) Get(ctx context.Context, k key.Key) (*Node, error) {
 }
 
 // Remove deletes the given node and all of its children from the BlockService
-func (n *dagService) Remove(nd *Node) error {
-	for _, l := range nd.Links {
+func (n *dagService) Remove(nd DAGNode) error {
+	for _, l := range nd.GetLinks() {
 		if l.Node != nil {
 			n.Remove(l.Node)
 		}
@@ -122,40 +147,34 @@ func (n *dagService) Remove(nd *Node) error {
 }
 
 // FetchGraph asynchronously fetches all nodes that are children of the given
-// node, and returns a channel that may be waited upon for the fetch to complete
-func FetchGraph(ctx context.Context, root *Node, serv DAGService) chan struct{} {
-	log.Warning("Untested.")
-	var wg sync.WaitGroup
+// node, within a single session, and returns a channel that is closed once
+// the fetch is complete
+func FetchGraph(ctx context.Context, root DAGNode, serv DAGService) chan struct{} {
 	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ses := serv.Session(ctx)
+		defer ses.Close()
+		fetchGraph(ctx, ses, root)
+	}()
+	return done
+}
 
-	for _, l := range root.Links {
+func fetchGraph(ctx context.Context, ses *Session, root DAGNode) {
+	var wg sync.WaitGroup
+	for _, ng := range ses.GetDAG(root) {
 		wg.Add(1)
-		go func(lnk *Link) {
-
-			// Signal child is done on way out
+		go func(ng NodeGetter) {
 			defer wg.Done()
-			select {
-			case <-ctx.Done():
-				return
-			}
-
-			nd, err := lnk.GetNode(ctx, serv)
+			nd, err := ng.Get(ctx)
 			if err != nil {
 				log.Debug(err)
 				return
 			}
-
-			// Wait for children to finish
-			<-FetchGraph(ctx, nd, serv)
-		}(l)
+			fetchGraph(ctx, ses, nd)
+		}(ng)
 	}
-
-	go func() {
-		wg.Wait()
-		done <- struct{}{}
-	}()
-
-	return done
+	wg.Wait()
 }
 
 // FindLinks searches this nodes links for the given key,
@@ -173,9 +192,9 @@ func FindLinks(links []key.Key, k key.Key, start int) []int {
 // GetDAG will fill out all of the links of the given Node.
 // It returns a channel of nodes, which the caller can receive
 // all the child nodes of 'root' on, in proper order.
-func (ds *dagService) GetDAG(ctx context.Context, root *Node) []NodeGetter {
+func (ds *dagService) GetDAG(ctx context.Context, root DAGNode) []NodeGetter {
 	var keys []key.Key
-	for _, lnk := range root.Links {
+	for _, lnk := range root.GetLinks() {
 		keys = append(keys, key.Key(lnk.Hash))
 	}
 
@@ -202,6 +221,14 @@ func (ds *dagService) GetNodes(ctx context.Context, keys []key.Key) []NodeGetter
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
+		// nodes that were not sent by the time we return could not be
+		// fetched, closing their channels fails the promises waiting on them
+		defer func() {
+			for _, ch := range sendChans {
+				close(ch)
+			}
+		}()
+
 		blkchan := ds.Blocks.GetBlocks(ctx, dedupedKeys)
 
 		for count := 0; count < len(keys); {
@@ -271,7 +298,10 @@ func (np *nodePromise) Get(ctx context.Context) (*Node, error) {
 	}
 
 	select {
-	case blk := <-np.recv:
+	case blk, ok := <-np.recv:
+		if !ok {
+			return nil, ErrNotFound
+		}
 		np.cache = blk
 	case <-np.ctx.Done():
 		return nil, np.ctx.Err()
@@ -289,15 +319,8 @@ type Batch struct {
 	MaxSize int
 }
 
-func (t *Batch) Add(nd *Node) (key.Key, error) {
-	d, err := nd.Encoded(false)
-	if err != nil {
-		return "", err
-	}
-
-	b := new(blocks.Block)
-	b.Data = d
-	b.Multihash, err = nd.Multihash()
+func (t *Batch) Add(nd DAGNode) (key.Key, error) {
+	b, err := nodeToBlock(nd)
 	if err != nil {
 		return "", err
 	}
@@ -312,7 +335,27 @@ func (t *Batch) Add(nd *Node) (key.Key, error) {
 	return k, nil
 }
 
+func (t *Batch) addRecursive(nd DAGNode) error {
+	if _, err := t.Add(nd); err != nil {
+		return err
+	}
+
+	for _, link := range nd.GetLinks() {
+		if link.Node != nil {
+			if err := t.addRecursive(link.Node); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Commit writes all nodes added since the last commit in a single batch.
 func (t *Batch) Commit() error {
+	if len(t.blocks) == 0 {
+		return nil
+	}
+
 	_, err := t.ds.Blocks.AddBlocks(t.blocks)
 	t.blocks = nil
 	t.size = 0
@@ -8,9 +8,11 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	bstore "github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
@@ -286,3 +288,288 @@ func TestCantGet(t *testing.T) {
 		t.Fatal("expected err not found, got: ", err)
 	}
 }
+
+func TestAddNodeTooLarge(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+
+	nd := &Node{Data: make([]byte, MaxNodeSize+1)}
+	_, err := dsp.ds.Add(nd)
+	if err != ErrNodeTooLarge {
+		t.Fatalf("expected ErrNodeTooLarge, got %v", err)
+	}
+
+	_, err = dsp.ds.Batch().Add(nd)
+	if err != ErrNodeTooLarge {
+		t.Fatalf("expected ErrNodeTooLarge from batch, got %v", err)
+	}
+
+	_, err = dsp.ds.Add(&Node{Data: make([]byte, 1024)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rawNode is a node of another format than the protobuf one: its block is
+// its data, and it has no links.
+type rawNode []byte
+
+func (r rawNode) GetLinks() []*Link                { return nil }
+func (r rawNode) GetData() []byte                  { return r }
+func (r rawNode) Encoded(bool) ([]byte, error)     { return r, nil }
+func (r rawNode) Multihash() (mh.Multihash, error) { return u.Hash(r), nil }
+func (r rawNode) Key() (key.Key, error)            { return key.Key(u.Hash(r)), nil }
+func (r rawNode) Size() (uint64, error)            { return uint64(len(r)), nil }
+
+func (r rawNode) Stat() (*NodeStat, error) {
+	return &NodeStat{BlockSize: len(r), DataSize: len(r)}, nil
+}
+
+func TestAddOtherFormat(t *testing.T) {
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	raw := rawNode("not a protobuf")
+	k, err := dserv.Add(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := bs.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blk.Data, raw) {
+		t.Fatalf("expected the block to hold the raw data, got %q", blk.Data)
+	}
+
+	// a protobuf node can link to it, and both are stored together
+	root := &Node{Data: []byte("root")}
+	root.Links = append(root.Links, &Link{Name: "raw", Hash: mh.Multihash(k), Size: uint64(len(raw))})
+	if err := dserv.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dserv.Remove(raw); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := bs.Has(k); has {
+		t.Fatal("expected the raw block to be removed")
+	}
+}
+
+func TestCachedDAGService(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+	cds, err := NewCachedDAGService(dsp.ds, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd := &Node{Data: []byte("cache me")}
+	k, err := cds.Add(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := cds.Get(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// modifying the returned node must not affect the cached copy
+	out.Data = []byte("changed")
+
+	again, err := cds.Get(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again.Data) != "cache me" {
+		t.Fatal("cached node was modified by caller")
+	}
+
+	err = cds.Remove(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = cds.Get(ctx, k)
+	if err == nil {
+		t.Fatal("expected removed node to not be returned from cache")
+	}
+}
+
+func TestCachedGetDAG(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+	cds, err := NewCachedDAGService(dsp.ds, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &Node{Data: []byte("child a")}
+	b := &Node{Data: []byte("child b")}
+	root := &Node{Data: []byte("parent")}
+	for _, c := range []*Node{a, b, a} {
+		if err := root.AddNodeLink("c", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cds.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func() {
+		for i, p := range cds.GetDAG(context.Background(), root) {
+			nd, err := p.Get(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(nd.Data, root.Links[i].Node.Data) {
+				t.Fatalf("child %d: got %q", i, nd.Data)
+			}
+		}
+	}
+	check()
+
+	// the second time, the children come from the cache
+	if err := dsp.ds.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
+
+func TestEnumerateRefs(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+
+	leaf := &Node{Data: []byte("leaf")}
+	mid := &Node{Data: []byte("mid")}
+	if err := mid.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	root := &Node{Data: []byte("root")}
+	if err := root.AddNodeLink("mid", mid); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	if err := dsp.ds.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(opts RefsOptions) int {
+		var n int
+		for r := range EnumerateRefs(context.Background(), dsp.ds, root, opts) {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+			n++
+		}
+		return n
+	}
+
+	if c := count(RefsOptions{}); c != 2 {
+		t.Fatalf("expected 2 direct refs, got %d", c)
+	}
+	if c := count(RefsOptions{Recursive: true}); c != 3 {
+		t.Fatalf("expected 3 recursive refs, got %d", c)
+	}
+	if c := count(RefsOptions{Recursive: true, Unique: true}); c != 2 {
+		t.Fatalf("expected 2 unique refs, got %d", c)
+	}
+	if c := count(RefsOptions{Recursive: true, MaxDepth: 1}); c != 2 {
+		t.Fatalf("expected 2 refs at depth 1, got %d", c)
+	}
+}
+
+func TestSessionGet(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+
+	nd := &Node{Data: []byte("session")}
+	k, err := dsp.ds.Add(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ses := dsp.ds.Session(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := ses.Get(context.Background(), k)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(out.Data) != "session" {
+				t.Error("got wrong node back from session")
+			}
+		}()
+	}
+	wg.Wait()
+
+	cancel()
+	_, err = ses.Get(context.Background(), key.Key("notthere"))
+	if err == nil {
+		t.Fatal("expected error from cancelled session")
+	}
+}
+
+func TestSessionGetNodes(t *testing.T) {
+	dsp := getDagservAndPinner(t)
+
+	root := &Node{Data: []byte("root")}
+	for i := 0; i < 3; i++ {
+		if err := root.AddNodeLink(fmt.Sprint(i), &Node{Data: []byte(fmt.Sprint(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same child twice
+	root.Links = append(root.Links, root.Links[0])
+	if err := dsp.ds.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+
+	ses := dsp.ds.Session(context.Background())
+	defer ses.Close()
+
+	getters := ses.GetDAG(root)
+	if len(getters) != len(root.Links) {
+		t.Fatalf("expected %d promises, got %d", len(root.Links), len(getters))
+	}
+	for i, ng := range getters {
+		nd, err := ng.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := root.Links[i].Name; string(nd.Data) != want {
+			t.Fatalf("expected %q at %d, got %q", want, i, nd.Data)
+		}
+	}
+
+	ses.Close()
+	if _, err := ses.GetNodes([]key.Key{"notthere"})[0].Get(context.Background()); err == nil {
+		t.Fatal("expected an error from a closed session")
+	}
+}
+
+func TestFetchGraph(t *testing.T) {
+	bs := bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	dserv := NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	read := io.LimitReader(u.NewTimeSeededRand(), 1024*32)
+	root, err := imp.BuildDagFromReader(dserv, chunk.NewSizeSplitter(read, 512), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	select {
+	case <-FetchGraph(ctx, root, dserv):
+	case <-ctx.Done():
+		t.Fatal("FetchGraph did not complete")
+	}
+}
@@ -2,6 +2,7 @@ package merkledag
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
@@ -9,6 +10,29 @@ import (
 	key "github.com/ipfs/go-ipfs/blocks/key"
 )
 
+// DAGNode is the interface implemented by all node formats stored in the
+// dag. The DAGService stores, removes and walks nodes through it, so other
+// formats only need to implement it to be added. *Node, the protobuf encoded
+// format, is currently the only implementation.
+type DAGNode interface {
+	// GetLinks returns the links to other nodes contained in this node.
+	GetLinks() []*Link
+
+	// GetData returns the opaque data contained in this node.
+	GetData() []byte
+
+	// Encoded returns the serialized form of the node, as it is stored in
+	// a block.
+	Encoded(force bool) ([]byte, error)
+
+	Multihash() (mh.Multihash, error)
+	Key() (key.Key, error)
+	Size() (uint64, error)
+	Stat() (*NodeStat, error)
+}
+
+var _ DAGNode = (*Node)(nil)
+
 // Node represents a node in the IPFS Merkle DAG.
 // nodes have opaque data and a set of navigable links.
 type Node struct {
@@ -19,8 +43,18 @@ type Node struct {
 	encoded []byte
 
 	cached mh.Multihash
+
+	// lazily built index of link names, used to speed up lookups on
+	// nodes with many links, see findLink
+	linkLk       sync.Mutex
+	linkIndex    map[string]*Link
+	linkIndexLen int
 }
 
+// linkIndexThreshold is the number of links a node must have before
+// GetNodeLink maintains an index instead of scanning the links.
+const linkIndexThreshold = 64
+
 // NodeStat is a statistics object for a Node. Mostly sizes.
 type NodeStat struct {
 	Hash           string
@@ -116,12 +150,22 @@ func (n *Node) AddNodeLinkClean(name string, that *Node) error {
 // AddRawLink adds a copy of a link to this node
 func (n *Node) AddRawLink(name string, l *Link) error {
 	n.encoded = nil
-	n.Links = append(n.Links, &Link{
+	lnk := &Link{
 		Name: name,
 		Size: l.Size,
 		Hash: l.Hash,
 		Node: l.Node,
-	})
+	}
+	n.Links = append(n.Links, lnk)
+
+	n.linkLk.Lock()
+	if n.linkIndex != nil && n.linkIndexLen == len(n.Links)-1 {
+		if _, ok := n.linkIndex[name]; !ok {
+			n.linkIndex[name] = lnk
+		}
+		n.linkIndexLen = len(n.Links)
+	}
+	n.linkLk.Unlock()
 
 	return nil
 }
@@ -141,6 +185,13 @@ func (n *Node) RemoveNodeLink(name string) error {
 	}
 	n.Links = good
 
+	n.linkLk.Lock()
+	if n.linkIndex != nil {
+		delete(n.linkIndex, name)
+		n.linkIndexLen = len(n.Links)
+	}
+	n.linkLk.Unlock()
+
 	if !found {
 		return ErrNotFound
 	}
@@ -150,17 +201,68 @@ func (n *Node) RemoveNodeLink(name string) error {
 
 // Return a copy of the link with given name
 func (n *Node) GetNodeLink(name string) (*Link, error) {
+	l := n.findLink(name)
+	if l == nil {
+		return nil, ErrNotFound
+	}
+
+	return &Link{
+		Name: l.Name,
+		Size: l.Size,
+		Hash: l.Hash,
+		Node: l.Node,
+	}, nil
+}
+
+// findLink returns the first link with the given name, or nil if there is
+// none. Nodes with many links use an index of link names, built on the first
+// lookup and kept up to date by the methods changing the links. Code that
+// assigns Links directly must use SetLinks, so the index is rebuilt.
+func (n *Node) findLink(name string) *Link {
+	if len(n.Links) < linkIndexThreshold {
+		for _, l := range n.Links {
+			if l.Name == name {
+				return l
+			}
+		}
+		return nil
+	}
+
+	n.linkLk.Lock()
+	defer n.linkLk.Unlock()
+	if n.linkIndex == nil || n.linkIndexLen != len(n.Links) {
+		n.buildLinkIndex()
+	}
+	return n.linkIndex[name]
+}
+
+// buildLinkIndex indexes the links by name. The index holds the links
+// themselves rather than their positions, as encoding sorts the links. It
+// must be called with linkLk held.
+func (n *Node) buildLinkIndex() {
+	n.linkIndex = make(map[string]*Link, len(n.Links))
 	for _, l := range n.Links {
-		if l.Name == name {
-			return &Link{
-				Name: l.Name,
-				Size: l.Size,
-				Hash: l.Hash,
-				Node: l.Node,
-			}, nil
+		if _, ok := n.linkIndex[l.Name]; !ok {
+			n.linkIndex[l.Name] = l
 		}
 	}
-	return nil, ErrNotFound
+	n.linkIndexLen = len(n.Links)
+}
+
+// invalidateLinkIndex drops the index of the links, for the next lookup to
+// rebuild it.
+func (n *Node) invalidateLinkIndex() {
+	n.linkLk.Lock()
+	n.linkIndex = nil
+	n.linkLk.Unlock()
+}
+
+// SetLinks replaces the links of the node. Assigning Links directly leaves
+// the cached encoding and the index of link names out of date.
+func (n *Node) SetLinks(links []*Link) {
+	n.encoded = nil
+	n.Links = links
+	n.invalidateLinkIndex()
 }
 
 func (n *Node) GetLinkedNode(ctx context.Context, ds DAGService, name string) (*Node, error) {
@@ -172,6 +274,16 @@ func (n *Node) GetLinkedNode(ctx context.Context, ds DAGService, name string) (*
 	return lnk.GetNode(ctx, ds)
 }
 
+// GetLinks returns the links of this node.
+func (n *Node) GetLinks() []*Link {
+	return n.Links
+}
+
+// GetData returns the data of this node.
+func (n *Node) GetData() []byte {
+	return n.Data
+}
+
 // Copy returns a copy of the node.
 // NOTE: does not make copies of Node objects in the links.
 func (n *Node) Copy() *Node {
@@ -1,6 +1,8 @@
 package merkledag
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -52,3 +54,109 @@ func TestRemoveLink(t *testing.T) {
 		t.Fatal("link order wrong")
 	}
 }
+
+func TestGetNodeLinkLargeNode(t *testing.T) {
+	nd := new(Node)
+	for i := 0; i < linkIndexThreshold*2; i++ {
+		child := &Node{Data: []byte(fmt.Sprint(i))}
+		if err := nd.AddNodeLinkClean(fmt.Sprintf("link%d", i), child); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lnk, err := nd.GetNodeLink("link7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lnk.Name != "link7" {
+		t.Fatal("got wrong link back")
+	}
+
+	// index must stay consistent as links are added and removed
+	if err := nd.AddNodeLinkClean("extra", &Node{Data: []byte("extra")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nd.GetNodeLink("extra"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nd.RemoveNodeLink("link7"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nd.GetNodeLink("link7"); err != ErrNotFound {
+		t.Fatal("expected removed link to not be found")
+	}
+
+	// encoding sorts the links, which must not confuse the index
+	if _, err := nd.Encoded(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"extra", "link0", "link100"} {
+		lnk, err := nd.GetNodeLink(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lnk.Name != name {
+			t.Fatalf("expected link %s, got %s", name, lnk.Name)
+		}
+	}
+}
+
+func TestGetNodeLinkAfterSetLinks(t *testing.T) {
+	nd := &Node{}
+	for i := 0; i < linkIndexThreshold*2; i++ {
+		if err := nd.AddRawLink(fmt.Sprintf("link%d", i), &Link{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := nd.GetNodeLink("link0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the last link and add another, leaving as many links as indexed
+	nd.SetLinks(nd.Links[:len(nd.Links)-1])
+	if err := nd.AddRawLink("new", &Link{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nd.GetNodeLink("new"); err != nil {
+		t.Fatal("expected the new link to be found")
+	}
+	if _, err := nd.GetNodeLink(fmt.Sprintf("link%d", linkIndexThreshold*2-1)); err != ErrNotFound {
+		t.Fatal("expected the dropped link to not be found")
+	}
+}
+
+func TestGetNodeLinkConcurrent(t *testing.T) {
+	nd := &Node{}
+	for i := 0; i < linkIndexThreshold*2; i++ {
+		if err := nd.AddRawLink(fmt.Sprintf("link%d", i), &Link{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := nd.GetNodeLink(fmt.Sprintf("link%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNodeImplementsDAGNode(t *testing.T) {
+	var dn DAGNode = &Node{
+		Data:  []byte("data"),
+		Links: []*Link{&Link{Name: "a"}},
+	}
+
+	if string(dn.GetData()) != "data" {
+		t.Fatal("wrong data returned")
+	}
+	if len(dn.GetLinks()) != 1 || dn.GetLinks()[0].Name != "a" {
+		t.Fatal("wrong links returned")
+	}
+}
@@ -0,0 +1,152 @@
+package merkledag
+
+import (
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// RefsOptions controls how EnumerateRefs walks a dag.
+type RefsOptions struct {
+	// Recursive enumerates the refs of child nodes as well as the root.
+	Recursive bool
+
+	// Unique omits refs that have already been emitted, and does not
+	// descend into them again.
+	Unique bool
+
+	// MaxDepth limits how many levels below the root are enumerated when
+	// Recursive is set. Zero means no limit.
+	MaxDepth int
+}
+
+// Ref is a single edge in a dag, as emitted by EnumerateRefs. If Err is set,
+// enumeration stopped early because of it and no more refs will follow.
+type Ref struct {
+	Src      key.Key
+	Dst      key.Key
+	LinkName string
+	Depth    int
+	Err      error
+}
+
+// EnumerateRefs walks the dag rooted at root and sends every edge it
+// encounters on the returned channel, which is closed once the walk is done.
+func EnumerateRefs(ctx context.Context, ds DAGService, root DAGNode, opts RefsOptions) <-chan *Ref {
+	out := make(chan *Ref)
+	go func() {
+		defer close(out)
+
+		e := &refEnumerator{
+			ctx:  ctx,
+			ses:  ds.Session(ctx),
+			opts: opts,
+			out:  out,
+			seen: make(map[key.Key]struct{}),
+		}
+		defer e.ses.Close()
+
+		if err := e.enumerate(root); err != nil {
+			select {
+			case out <- &Ref{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
+type refEnumerator struct {
+	ctx  context.Context
+	ses  *Session
+	opts RefsOptions
+	out  chan<- *Ref
+	seen map[key.Key]struct{}
+}
+
+func (e *refEnumerator) enumerate(root DAGNode) error {
+	if e.opts.Recursive {
+		return e.enumerateRecursive(root, 1)
+	}
+
+	rkey, err := root.Key()
+	if err != nil {
+		return err
+	}
+
+	if e.skip(rkey) {
+		return nil
+	}
+
+	for _, l := range root.GetLinks() {
+		lk := key.Key(l.Hash)
+		if e.skip(lk) {
+			continue
+		}
+
+		if err := e.send(rkey, lk, l.Name, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *refEnumerator) enumerateRecursive(n DAGNode, depth int) error {
+	nkey, err := n.Key()
+	if err != nil {
+		return err
+	}
+
+	// don't bother fetching children we won't descend into
+	atMax := e.opts.MaxDepth > 0 && depth >= e.opts.MaxDepth
+	var getters []NodeGetter
+	if !atMax {
+		getters = e.ses.GetDAG(n)
+	}
+
+	for i, l := range n.GetLinks() {
+		lk := key.Key(l.Hash)
+		if e.skip(lk) {
+			continue
+		}
+
+		if err := e.send(nkey, lk, l.Name, depth); err != nil {
+			return err
+		}
+
+		if atMax {
+			continue
+		}
+
+		nd, err := getters[i].Get(e.ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := e.enumerateRecursive(nd, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip returns whether to skip a key
+func (e *refEnumerator) skip(k key.Key) bool {
+	if !e.opts.Unique {
+		return false
+	}
+
+	_, found := e.seen[k]
+	if !found {
+		e.seen[k] = struct{}{}
+	}
+	return found
+}
+
+func (e *refEnumerator) send(src, dst key.Key, name string, depth int) error {
+	select {
+	case e.out <- &Ref{Src: src, Dst: dst, LinkName: name, Depth: depth}:
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	}
+}
@@ -0,0 +1,149 @@
+package merkledag
+
+import (
+	"sync"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+)
+
+// Session fetches nodes on behalf of a single traversal. All requests made
+// through a session are bound to its context, so abandoning a traversal
+// cancels every outstanding want at once, and concurrent requests for the
+// same key share a single fetch. Blocks are fetched within a single exchange
+// session, see blockservice.WithSession. A session must be closed once the
+// traversal is done.
+type Session struct {
+	ctx    context.Context
+	cancel func()
+	ds     DAGService
+
+	lk       sync.Mutex
+	inflight map[key.Key]*sessionFetch
+}
+
+type sessionFetch struct {
+	done chan struct{}
+	nd   *Node
+	err  error
+}
+
+func newSession(ctx context.Context, ds DAGService) *Session {
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, closeSes := bserv.WithSession(ctx)
+	return &Session{
+		ctx: ctx,
+		cancel: func() {
+			closeSes()
+			cancel()
+		},
+		ds:       ds,
+		inflight: make(map[key.Key]*sessionFetch),
+	}
+}
+
+// Close ends the session, cancelling the fetches still running.
+func (s *Session) Close() {
+	s.cancel()
+}
+
+// Get retrieves the node for the given key. The fetch itself lives as long
+// as the session, ctx only bounds how long this call waits for it.
+func (s *Session) Get(ctx context.Context, k key.Key) (*Node, error) {
+	s.lk.Lock()
+	f, ok := s.inflight[k]
+	if !ok {
+		f = &sessionFetch{done: make(chan struct{})}
+		s.inflight[k] = f
+		go func() {
+			nd, err := s.ds.Get(s.ctx, k)
+			s.complete(k, f, nd, err)
+		}()
+	}
+	s.lk.Unlock()
+
+	return s.wait(ctx, f)
+}
+
+// wait returns the node of f once fetched.
+func (s *Session) wait(ctx context.Context, f *sessionFetch) (*Node, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	// callers may modify the node they get back
+	return f.nd.Copy(), nil
+}
+
+func (s *Session) complete(k key.Key, f *sessionFetch, nd *Node, err error) {
+	f.nd, f.err = nd, err
+
+	s.lk.Lock()
+	delete(s.inflight, k)
+	s.lk.Unlock()
+
+	close(f.done)
+}
+
+// GetDAG returns, in order, all the single level child nodes of the passed
+// in node, fetched within the session.
+func (s *Session) GetDAG(root DAGNode) []NodeGetter {
+	var keys []key.Key
+	for _, lnk := range root.GetLinks() {
+		keys = append(keys, key.Key(lnk.Hash))
+	}
+	return s.GetNodes(keys)
+}
+
+// GetNodes returns promises for the given keys, fetched within the session.
+// The keys the session is already fetching are not fetched again, the others
+// are fetched together.
+func (s *Session) GetNodes(keys []key.Key) []NodeGetter {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	promises := make([]NodeGetter, len(keys))
+	var fetch []key.Key
+	var fetches []*sessionFetch
+
+	s.lk.Lock()
+	for i, k := range keys {
+		f, ok := s.inflight[k]
+		if !ok {
+			f = &sessionFetch{done: make(chan struct{})}
+			s.inflight[k] = f
+			fetch = append(fetch, k)
+			fetches = append(fetches, f)
+		}
+		promises[i] = &sessionPromise{s: s, f: f}
+	}
+	s.lk.Unlock()
+
+	for i, ng := range s.ds.GetNodes(s.ctx, fetch) {
+		go func(k key.Key, f *sessionFetch, ng NodeGetter) {
+			nd, err := ng.Get(s.ctx)
+			s.complete(k, f, nd, err)
+		}(fetch[i], fetches[i], ng)
+	}
+	return promises
+}
+
+// sessionPromise is the promise of a node fetched within a session.
+type sessionPromise struct {
+	s *Session
+	f *sessionFetch
+}
+
+func (p *sessionPromise) Get(ctx context.Context) (*Node, error) {
+	return p.s.wait(ctx, p.f)
+}
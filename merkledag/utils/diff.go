@@ -16,6 +16,8 @@ const (
 	Mod
 )
 
+// Change represents a single modification to a dag, described by the path it
+// occurred at and the keys of the nodes before and after the change.
 type Change struct {
 	Type   int
 	Path   string
@@ -36,6 +38,8 @@ func (c *Change) String() string {
 	}
 }
 
+// ApplyChange applies the given changes to the dag rooted at nd, returning
+// the new root.
 func ApplyChange(ctx context.Context, ds dag.DAGService, nd *dag.Node, cs []*Change) (*dag.Node, error) {
 	e := NewDagEditor(ds, nd)
 	for _, c := range cs {
@@ -74,17 +78,29 @@ func ApplyChange(ctx context.Context, ds dag.DAGService, nd *dag.Node, cs []*Cha
 	return e.GetNode(), nil
 }
 
-func Diff(ctx context.Context, ds dag.DAGService, a, b *dag.Node) []*Change {
+// Diff returns the set of changes required to turn the dag rooted at a into
+// the dag rooted at b. Paths in the returned changes are relative to the
+// given roots.
+func Diff(ctx context.Context, ds dag.DAGService, a, b *dag.Node) ([]*Change, error) {
 	if len(a.Links) == 0 && len(b.Links) == 0 {
-		ak, _ := a.Key()
-		bk, _ := b.Key()
+		ak, err := a.Key()
+		if err != nil {
+			return nil, err
+		}
+		bk, err := b.Key()
+		if err != nil {
+			return nil, err
+		}
+		if ak == bk {
+			return nil, nil
+		}
 		return []*Change{
 			&Change{
 				Type:   Mod,
 				Before: ak,
 				After:  bk,
 			},
-		}
+		}, nil
 	}
 
 	var out []*Change
@@ -98,9 +114,20 @@ func Diff(ctx context.Context, ds dag.DAGService, a, b *dag.Node) []*Change {
 			if bytes.Equal(l.Hash, lnk.Hash) {
 				// no change... ignore it
 			} else {
-				anode, _ := lnk.GetNode(ctx, ds)
-				bnode, _ := l.GetNode(ctx, ds)
-				sub := Diff(ctx, ds, anode, bnode)
+				anode, err := lnk.GetNode(ctx, ds)
+				if err != nil {
+					return nil, err
+				}
+
+				bnode, err := l.GetNode(ctx, ds)
+				if err != nil {
+					return nil, err
+				}
+
+				sub, err := Diff(ctx, ds, anode, bnode)
+				if err != nil {
+					return nil, err
+				}
 
 				for _, subc := range sub {
 					subc.Path = path.Join(lnk.Name, subc.Path)
@@ -127,14 +154,19 @@ func Diff(ctx context.Context, ds dag.DAGService, a, b *dag.Node) []*Change {
 		})
 	}
 
-	return out
+	return out, nil
 }
 
+// Conflict represents two incompatible changes made to the same path.
 type Conflict struct {
 	A *Change
 	B *Change
 }
 
+// MergeDiffs combines two sets of changes made against a common base into a
+// single set of changes. Changes from a and b that touch the same path are
+// returned as conflicts, unless they are identical, in which case they are
+// only applied once.
 func MergeDiffs(a, b []*Change) ([]*Change, []Conflict) {
 	var out []*Change
 	var conflicts []Conflict
@@ -143,8 +175,16 @@ func MergeDiffs(a, b []*Change) ([]*Change, []Conflict) {
 		paths[c.Path] = c
 	}
 
+	for _, c := range a {
+		out = append(out, c)
+	}
+
 	for _, c := range b {
 		if ca, ok := paths[c.Path]; ok {
+			if ca.Type == c.Type && ca.Before == c.Before && ca.After == c.After {
+				// both sides made the same change
+				continue
+			}
 			conflicts = append(conflicts, Conflict{
 				A: ca,
 				B: c,
@@ -153,8 +193,5 @@ func MergeDiffs(a, b []*Change) ([]*Change, []Conflict) {
 			out = append(out, c)
 		}
 	}
-	for _, c := range paths {
-		out = append(out, c)
-	}
 	return out, conflicts
 }
@@ -0,0 +1,133 @@
+package dagutils
+
+import (
+	"testing"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+func buildTestDag(t *testing.T, ds dag.DAGService, entries map[string]string) *dag.Node {
+	e := NewDagEditor(ds, new(dag.Node))
+	for p, data := range entries {
+		err := e.InsertNodeAtPath(context.Background(), p, &dag.Node{Data: []byte(data)}, func() *dag.Node {
+			return new(dag.Node)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e.GetNode()
+}
+
+func TestDiffAndApply(t *testing.T) {
+	ds := mdtest.Mock()
+	a := buildTestDag(t, ds, map[string]string{
+		"a":     "foo",
+		"b/c":   "bar",
+		"b/d/e": "baz",
+	})
+	b := buildTestDag(t, ds, map[string]string{
+		"b/c":   "changed",
+		"b/d/e": "baz",
+		"f":     "new",
+	})
+
+	changes, err := Diff(context.Background(), ds, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]int)
+	for _, c := range changes {
+		found[c.Path] = c.Type
+	}
+
+	exp := map[string]int{
+		"a":   Remove,
+		"b/c": Mod,
+		"f":   Add,
+	}
+	if len(found) != len(exp) {
+		t.Fatalf("expected %d changes, got %d", len(exp), len(found))
+	}
+	for p, typ := range exp {
+		if found[p] != typ {
+			t.Fatalf("expected change of type %d at %s", typ, p)
+		}
+	}
+
+	out, err := ApplyChange(context.Background(), ds, a, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outk, err := out.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bk, err := b.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outk != bk {
+		t.Fatal("applying diff did not produce target dag")
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	ds := mdtest.Mock()
+	a := buildTestDag(t, ds, map[string]string{"a/b": "foo"})
+
+	changes, err := Diff(context.Background(), ds, a, a.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %d", len(changes))
+	}
+}
+
+func TestMergeDiffs(t *testing.T) {
+	ds := mdtest.Mock()
+	base := buildTestDag(t, ds, map[string]string{
+		"a": "foo",
+		"b": "bar",
+	})
+	left := buildTestDag(t, ds, map[string]string{
+		"a": "left",
+		"b": "bar",
+		"c": "same",
+	})
+	right := buildTestDag(t, ds, map[string]string{
+		"a": "right",
+		"c": "same",
+	})
+
+	da, err := Diff(context.Background(), ds, base, left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Diff(context.Background(), ds, base, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, conflicts := MergeDiffs(da, db)
+	if len(conflicts) != 1 || conflicts[0].A.Path != "a" {
+		t.Fatalf("expected a single conflict at 'a', got %d", len(conflicts))
+	}
+
+	paths := make(map[string]int)
+	for _, c := range merged {
+		paths[c.Path]++
+	}
+	if paths["b"] != 1 {
+		t.Fatal("expected removal of 'b' in merged changes")
+	}
+	if paths["c"] != 1 {
+		t.Fatal("expected identical changes to 'c' to be merged once")
+	}
+}
@@ -147,6 +147,74 @@ func rmLink(ctx context.Context, ds dag.DAGService, root *dag.Node, path []strin
 	return root, nil
 }
 
+// SetDataAtPath replaces the data of the node at the given path, updating
+// every node on the way up to the root. An empty path refers to the root.
+func (e *Editor) SetDataAtPath(ctx context.Context, path string, data []byte) error {
+	return e.updateDataAtPath(ctx, path, func([]byte) []byte { return data })
+}
+
+// AppendDataAtPath appends data to the data of the node at the given path,
+// the same way as SetDataAtPath.
+func (e *Editor) AppendDataAtPath(ctx context.Context, path string, data []byte) error {
+	return e.updateDataAtPath(ctx, path, func(old []byte) []byte {
+		return append(old, data...)
+	})
+}
+
+func (e *Editor) updateDataAtPath(ctx context.Context, path string, update func([]byte) []byte) error {
+	var splpath []string
+	if path != "" {
+		splpath = strings.Split(path, "/")
+	}
+
+	nd, err := setDataAtPath(ctx, e.ds, e.root, splpath, update)
+	if err != nil {
+		return err
+	}
+	e.root = nd
+	return nil
+}
+
+// setDataAtPath works on copies of the nodes along the path, the nodes
+// passed in may be shared with the caller or cached by the DAGService.
+func setDataAtPath(ctx context.Context, ds dag.DAGService, root *dag.Node, path []string, update func([]byte) []byte) (*dag.Node, error) {
+	root = root.Copy()
+
+	if len(path) == 0 {
+		root.Data = update(root.Data)
+
+		_, err := ds.Add(root)
+		if err != nil {
+			return nil, err
+		}
+
+		return root, nil
+	}
+
+	nd, err := root.GetLinkedNode(ctx, ds, path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	nnode, err := setDataAtPath(ctx, ds, nd, path[1:], update)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = root.RemoveNodeLink(path[0])
+	err = root.AddNodeLinkClean(path[0], nnode)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ds.Add(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
 func (e *Editor) WriteOutputTo(ds dag.DAGService) error {
 	return copyDag(e.GetNode(), e.ds, ds)
 }
@@ -1,6 +1,7 @@
 package dagutils
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -122,3 +123,63 @@ func testInsert(t *testing.T, e *Editor, path, data string, create bool, experr
 
 	assertNodeAtPath(t, e.ds, e.root, path, ck)
 }
+
+func TestSetDataAtPath(t *testing.T) {
+	ds := mdtest.Mock()
+	e := NewDagEditor(ds, new(dag.Node))
+
+	testInsert(t, e, "a/b/c", "before", true, "")
+
+	orig := e.root
+	origa, err := orig.GetNodeLink("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = e.SetDataAtPath(context.Background(), "a/b/c", []byte("after"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err := (&dag.Node{Data: []byte("after")}).Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertNodeAtPath(t, ds, e.GetNode(), "a/b/c", exp)
+
+	err = e.SetDataAtPath(context.Background(), "", []byte("rootdata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(e.GetNode().Data) != "rootdata" {
+		t.Fatal("root data not set")
+	}
+
+	err = e.AppendDataAtPath(context.Background(), "a/b/c", []byte("wards"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, err = (&dag.Node{Data: []byte("afterwards")}).Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertNodeAtPath(t, ds, e.GetNode(), "a/b/c", exp)
+
+	// the nodes passed to the editor are left untouched
+	lnk, err := orig.GetNodeLink("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(lnk.Hash, origa.Hash) || orig.Data != nil {
+		t.Fatal("original root was modified")
+	}
+
+	err = e.SetDataAtPath(context.Background(), "a/nope", []byte("x"))
+	if err != dag.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
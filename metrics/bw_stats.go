@@ -1,9 +1,11 @@
 package metrics
 
 import (
-	gm "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/whyrusleeping/go-metrics"
+	"strings"
 	"sync"
 
+	gm "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/whyrusleeping/go-metrics"
+
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	protocol "github.com/ipfs/go-ipfs/p2p/protocol"
 )
@@ -40,43 +42,91 @@ func (bwc *BandwidthCounter) LogRecvMessage(size int64) {
 }
 
 func (bwc *BandwidthCounter) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
-	meter := gm.GetOrRegisterMeter("/peer/out/"+string(p), bwc.reg)
+	meter := gm.GetOrRegisterMeter(peerOutPrefix+string(p), bwc.reg)
 	meter.Mark(size)
 
-	pmeter := gm.GetOrRegisterMeter("/proto/out/"+string(proto), bwc.reg)
+	pmeter := gm.GetOrRegisterMeter(protoOutPrefix+string(proto), bwc.reg)
 	pmeter.Mark(size)
 }
 
 func (bwc *BandwidthCounter) LogRecvMessageStream(size int64, proto protocol.ID, p peer.ID) {
-	meter := gm.GetOrRegisterMeter("/peer/in/"+string(p), bwc.reg)
+	meter := gm.GetOrRegisterMeter(peerInPrefix+string(p), bwc.reg)
 	meter.Mark(size)
 
-	pmeter := gm.GetOrRegisterMeter("/proto/in/"+string(proto), bwc.reg)
+	pmeter := gm.GetOrRegisterMeter(protoInPrefix+string(proto), bwc.reg)
 	pmeter.Mark(size)
 }
 
+const (
+	peerInPrefix   = "/peer/in/"
+	peerOutPrefix  = "/peer/out/"
+	protoInPrefix  = "/proto/in/"
+	protoOutPrefix = "/proto/out/"
+)
+
 func (bwc *BandwidthCounter) GetBandwidthForPeer(p peer.ID) (out Stats) {
-	inMeter := gm.GetOrRegisterMeter("/peer/in/"+string(p), bwc.reg).Snapshot()
-	outMeter := gm.GetOrRegisterMeter("/peer/out/"+string(p), bwc.reg).Snapshot()
+	return bwc.stats(peerInPrefix+string(p), peerOutPrefix+string(p))
+}
 
-	return Stats{
-		TotalIn:  inMeter.Count(),
-		TotalOut: outMeter.Count(),
-		RateIn:   inMeter.RateFine(),
-		RateOut:  outMeter.RateFine(),
+func (bwc *BandwidthCounter) GetBandwidthForProtocol(proto protocol.ID) (out Stats) {
+	return bwc.stats(protoInPrefix+string(proto), protoOutPrefix+string(proto))
+}
+
+// GetBandwidthByPeer returns the stats of every peer traffic was logged for.
+func (bwc *BandwidthCounter) GetBandwidthByPeer() map[peer.ID]Stats {
+	out := make(map[peer.ID]Stats)
+	for _, name := range bwc.names(peerInPrefix, peerOutPrefix) {
+		out[peer.ID(name)] = bwc.GetBandwidthForPeer(peer.ID(name))
 	}
+	return out
 }
 
-func (bwc *BandwidthCounter) GetBandwidthForProtocol(proto protocol.ID) (out Stats) {
-	inMeter := gm.GetOrRegisterMeter(string("/proto/in/"+proto), bwc.reg).Snapshot()
-	outMeter := gm.GetOrRegisterMeter(string("/proto/out/"+proto), bwc.reg).Snapshot()
+// GetBandwidthByProtocol returns the stats of every protocol traffic was
+// logged for.
+func (bwc *BandwidthCounter) GetBandwidthByProtocol() map[protocol.ID]Stats {
+	out := make(map[protocol.ID]Stats)
+	for _, name := range bwc.names(protoInPrefix, protoOutPrefix) {
+		out[protocol.ID(name)] = bwc.GetBandwidthForProtocol(protocol.ID(name))
+	}
+	return out
+}
 
-	return Stats{
-		TotalIn:  inMeter.Count(),
-		TotalOut: outMeter.Count(),
-		RateIn:   inMeter.RateFine(),
-		RateOut:  outMeter.RateFine(),
+// stats returns the stats of the meters named in and out. The meters which
+// were never marked count as zero, and are not registered, so that asking
+// for them does not list them.
+func (bwc *BandwidthCounter) stats(in, out string) Stats {
+	var s Stats
+	if m, ok := bwc.reg.Get(in).(gm.Meter); ok {
+		snap := m.Snapshot()
+		s.TotalIn = snap.Count()
+		s.RateIn = snap.RateFine()
+	}
+	if m, ok := bwc.reg.Get(out).(gm.Meter); ok {
+		snap := m.Snapshot()
+		s.TotalOut = snap.Count()
+		s.RateOut = snap.RateFine()
 	}
+	return s
+}
+
+// names returns the names of the meters registered under the in and out
+// prefixes, without them.
+func (bwc *BandwidthCounter) names(in, out string) []string {
+	seen := make(map[string]struct{})
+	var names []string
+	bwc.reg.Each(func(name string, _ interface{}) {
+		for _, prefix := range []string{in, out} {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			key := name[len(prefix):]
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				names = append(names, key)
+			}
+		}
+	})
+	return names
 }
 
 func (bwc *BandwidthCounter) GetBandwidthTotals() (out Stats) {
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	protocol "github.com/ipfs/go-ipfs/p2p/protocol"
+)
+
+func TestBandwidthCounter(t *testing.T) {
+	bwc := NewBandwidthCounter()
+
+	bwc.LogSentMessage(30)
+	bwc.LogRecvMessage(70)
+	bwc.LogSentMessageStream(10, protocol.ID("/a"), peer.ID("p1"))
+	bwc.LogSentMessageStream(20, protocol.ID("/b"), peer.ID("p2"))
+	bwc.LogRecvMessageStream(30, protocol.ID("/a"), peer.ID("p2"))
+	bwc.LogRecvMessageStream(40, protocol.ID("/b"), peer.ID("p1"))
+
+	if s := bwc.GetBandwidthTotals(); s.TotalOut != 30 || s.TotalIn != 70 {
+		t.Fatalf("unexpected totals %+v", s)
+	}
+	if s := bwc.GetBandwidthForPeer(peer.ID("p1")); s.TotalOut != 10 || s.TotalIn != 40 {
+		t.Fatalf("unexpected stats for p1 %+v", s)
+	}
+	if s := bwc.GetBandwidthForProtocol(protocol.ID("/a")); s.TotalOut != 10 || s.TotalIn != 30 {
+		t.Fatalf("unexpected stats for /a %+v", s)
+	}
+
+	// asking for unknown peers does not list them
+	if s := bwc.GetBandwidthForPeer(peer.ID("p3")); s.TotalOut != 0 || s.TotalIn != 0 {
+		t.Fatalf("unexpected stats for p3 %+v", s)
+	}
+
+	byPeer := bwc.GetBandwidthByPeer()
+	if len(byPeer) != 2 {
+		t.Fatalf("expected two peers, got %v", byPeer)
+	}
+	if s := byPeer[peer.ID("p2")]; s.TotalOut != 20 || s.TotalIn != 30 {
+		t.Fatalf("unexpected stats for p2 %+v", s)
+	}
+
+	byProto := bwc.GetBandwidthByProtocol()
+	if len(byProto) != 2 {
+		t.Fatalf("expected two protocols, got %v", byProto)
+	}
+	if s := byProto[protocol.ID("/b")]; s.TotalOut != 20 || s.TotalIn != 40 {
+		t.Fatalf("unexpected stats for /b %+v", s)
+	}
+}
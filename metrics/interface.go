@@ -16,4 +16,6 @@ type Reporter interface {
 	GetBandwidthForPeer(peer.ID) Stats
 	GetBandwidthForProtocol(protocol.ID) Stats
 	GetBandwidthTotals() Stats
+	GetBandwidthByPeer() map[peer.ID]Stats
+	GetBandwidthByProtocol() map[protocol.ID]Stats
 }
@@ -1,4 +1,4 @@
-package ipnsfs
+package mfs
 
 import (
 	"errors"
@@ -8,6 +8,7 @@ import (
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
+	key "github.com/ipfs/go-ipfs/blocks/key"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	ufspb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -17,7 +18,7 @@ var ErrNotYetImplemented = errors.New("not yet implemented")
 var ErrInvalidChild = errors.New("invalid child node")
 
 type Directory struct {
-	fs     *Filesystem
+	root   *Root
 	parent childCloser
 
 	childDirs map[string]*Directory
@@ -30,10 +31,10 @@ type Directory struct {
 	name string
 }
 
-func NewDirectory(ctx context.Context, name string, node *dag.Node, parent childCloser, fs *Filesystem) *Directory {
+func NewDirectory(ctx context.Context, name string, node *dag.Node, parent childCloser, root *Root) *Directory {
 	return &Directory{
 		ctx:       ctx,
-		fs:        fs,
+		root:      root,
 		name:      name,
 		node:      node,
 		parent:    parent,
@@ -45,7 +46,7 @@ func NewDirectory(ctx context.Context, name string, node *dag.Node, parent child
 // closeChild updates the child by the given name to the dag node 'nd'
 // and changes its own dag node, then propogates the changes upward
 func (d *Directory) closeChild(name string, nd *dag.Node) error {
-	_, err := d.fs.dserv.Add(nd)
+	_, err := d.root.dserv.Add(nd)
 	if err != nil {
 		return err
 	}
@@ -89,7 +90,7 @@ func (d *Directory) childFile(name string) (*File, error) {
 	case ufspb.Data_Directory:
 		return nil, ErrIsDirectory
 	case ufspb.Data_File:
-		nfi, err := NewFile(name, nd, d, d.fs)
+		nfi, err := NewFile(name, nd, d, d.root)
 		if err != nil {
 			return nil, err
 		}
@@ -122,7 +123,7 @@ func (d *Directory) childDir(name string) (*Directory, error) {
 
 	switch i.GetType() {
 	case ufspb.Data_Directory:
-		ndir := NewDirectory(d.ctx, name, nd, d, d.fs)
+		ndir := NewDirectory(d.ctx, name, nd, d, d.root)
 		d.childDirs[name] = ndir
 		return ndir, nil
 	case ufspb.Data_File:
@@ -139,7 +140,7 @@ func (d *Directory) childDir(name string) (*Directory, error) {
 func (d *Directory) childFromDag(name string) (*dag.Node, error) {
 	for _, lnk := range d.node.Links {
 		if lnk.Name == name {
-			return lnk.GetNode(d.ctx, d.fs.dserv)
+			return lnk.GetNode(d.ctx, d.root.dserv)
 		}
 	}
 
@@ -179,6 +180,64 @@ func (d *Directory) List() []string {
 	return out
 }
 
+// NodeListing is an entry of a directory listing
+type NodeListing struct {
+	Name string
+	Type NodeType
+}
+
+// ListEntries returns up to n entries of the directory, from the entry at
+// offset on, in the order of its links, so large directories can be listed
+// a part at a time. Only the children of the returned entries are fetched,
+// to learn their type. An offset past the last entry returns no entries.
+func (d *Directory) ListEntries(ctx context.Context, offset, n int) ([]NodeListing, error) {
+	d.lock.Lock()
+	if offset >= len(d.node.Links) {
+		d.lock.Unlock()
+		return nil, nil
+	}
+	links := d.node.Links[offset:]
+	if len(links) > n {
+		links = links[:n]
+	}
+
+	out := make([]NodeListing, len(links))
+	var keys []key.Key
+	var missing []int
+	for i, lnk := range links {
+		out[i].Name = lnk.Name
+		if _, ok := d.childDirs[lnk.Name]; ok {
+			out[i].Type = TDir
+			continue
+		}
+		if _, ok := d.files[lnk.Name]; ok {
+			out[i].Type = TFile
+			continue
+		}
+		keys = append(keys, key.Key(lnk.Hash))
+		missing = append(missing, i)
+	}
+	d.lock.Unlock()
+
+	// the other children are fetched together
+	for j, p := range d.root.dserv.GetNodes(ctx, keys) {
+		nd, err := p.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pbn, err := ft.FromBytes(nd.Data)
+		if err != nil {
+			return nil, err
+		}
+		if pbn.GetType() == ft.TDirectory {
+			out[missing[j]].Type = TDir
+		} else {
+			out[missing[j]].Type = TFile
+		}
+	}
+	return out, nil
+}
+
 func (d *Directory) Mkdir(name string) (*Directory, error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -193,6 +252,11 @@ func (d *Directory) Mkdir(name string) (*Directory, error) {
 	}
 
 	ndir := &dag.Node{Data: ft.FolderPBData()}
+	_, err = d.root.dserv.Add(ndir)
+	if err != nil {
+		return nil, err
+	}
+
 	err = d.node.AddNodeLinkClean(name, ndir)
 	if err != nil {
 		return nil, err
@@ -235,6 +299,11 @@ func (d *Directory) AddChild(name string, nd *dag.Node) error {
 		return errors.New("directory already has entry by that name")
 	}
 
+	_, err = d.root.dserv.Add(nd)
+	if err != nil {
+		return err
+	}
+
 	err = d.node.AddNodeLinkClean(name, nd)
 	if err != nil {
 		return err
@@ -242,9 +311,9 @@ func (d *Directory) AddChild(name string, nd *dag.Node) error {
 
 	switch pbn.GetType() {
 	case ft.TDirectory:
-		d.childDirs[name] = NewDirectory(d.ctx, name, nd, d, d.fs)
+		d.childDirs[name] = NewDirectory(d.ctx, name, nd, d, d.root)
 	case ft.TFile, ft.TMetadata, ft.TRaw:
-		nfi, err := NewFile(name, nd, d, d.fs)
+		nfi, err := NewFile(name, nd, d, d.root)
 		if err != nil {
 			return err
 		}
@@ -1,4 +1,4 @@
-package ipnsfs
+package mfs
 
 import (
 	"sync"
@@ -12,7 +12,7 @@ import (
 
 type File struct {
 	parent childCloser
-	fs     *Filesystem
+	root   *Root
 
 	name       string
 	hasChanges bool
@@ -22,14 +22,14 @@ type File struct {
 }
 
 // NewFile returns a NewFile object with the given parameters
-func NewFile(name string, node *dag.Node, parent childCloser, fs *Filesystem) (*File, error) {
-	dmod, err := mod.NewDagModifier(context.Background(), node, fs.dserv, fs.pins.GetManual(), chunk.DefaultSplitter)
+func NewFile(name string, node *dag.Node, parent childCloser, root *Root) (*File, error) {
+	dmod, err := mod.NewDagModifier(context.Background(), node, root.dserv, root.pins.GetManual(), chunk.DefaultSplitter)
 	if err != nil {
 		return nil, err
 	}
 
 	return &File{
-		fs:     fs,
+		root:   root,
 		parent: parent,
 		name:   name,
 		mod:    dmod,
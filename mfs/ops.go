@@ -0,0 +1,180 @@
+package mfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// splitPath cleans the absolute path p, and splits it into its components.
+func splitPath(p string) ([]string, error) {
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("paths must start with a leading slash: %q", p)
+	}
+	p = strings.Trim(gopath.Clean(p), "/")
+	if p == "" {
+		return nil, nil
+	}
+	return strings.Split(p, "/"), nil
+}
+
+// rootDir returns the root directory of r.
+func rootDir(r *Root) (*Directory, error) {
+	dir, ok := r.GetValue().(*Directory)
+	if !ok {
+		return nil, errors.New("root is not a directory")
+	}
+	return dir, nil
+}
+
+// Lookup returns the node at the path p of the tree of r.
+func Lookup(r *Root, p string) (FSNode, error) {
+	parts, err := splitPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var cur FSNode = r.GetValue()
+	for i, name := range parts {
+		dir, ok := cur.(*Directory)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a directory", gopath.Join(parts[:i]...))
+		}
+		cur, err = dir.Child(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cur, nil
+}
+
+// lookupDir returns the directory at the path p of the tree of r.
+func lookupDir(r *Root, p string) (*Directory, error) {
+	nd, err := Lookup(r, p)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := nd.(*Directory)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", p)
+	}
+	return dir, nil
+}
+
+// Mkdir creates a directory at the path p of the tree of r. With parents,
+// the missing directories above it are created too, and an existing
+// directory at p is not an error.
+func Mkdir(r *Root, p string, parents bool) error {
+	parts, err := splitPath(p)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		if parents {
+			return nil
+		}
+		return os.ErrExist
+	}
+
+	cur, err := rootDir(r)
+	if err != nil {
+		return err
+	}
+	for i, name := range parts {
+		last := i == len(parts)-1
+		child, err := cur.Child(name)
+		switch {
+		case err == os.ErrNotExist && (parents || last):
+			cur, err = cur.Mkdir(name)
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			dir, ok := child.(*Directory)
+			if !ok {
+				return fmt.Errorf("%s is not a directory", gopath.Join(parts[:i+1]...))
+			}
+			if last && !parents {
+				return os.ErrExist
+			}
+			cur = dir
+		}
+	}
+	return nil
+}
+
+// PutNode adds nd at the path p of the tree of r. The parent directory of
+// p has to exist, and p must not.
+func PutNode(r *Root, p string, nd *dag.Node) error {
+	dirp, name := gopath.Split(p)
+	if name == "" {
+		return fmt.Errorf("cannot put a node at %q", p)
+	}
+	dir, err := lookupDir(r, dirp)
+	if err != nil {
+		return err
+	}
+	return dir.AddChild(name, nd)
+}
+
+// Remove unlinks the node at the path p of the tree of r. Directories are
+// only removed with recursive.
+func Remove(r *Root, p string, recursive bool) error {
+	dirp, name := gopath.Split(gopath.Clean(p))
+	if name == "" {
+		return errors.New("cannot remove the root")
+	}
+	dir, err := lookupDir(r, dirp)
+	if err != nil {
+		return err
+	}
+	child, err := dir.Child(name)
+	if err != nil {
+		return err
+	}
+	if child.Type() == TDir && !recursive {
+		return fmt.Errorf("%s is a directory, use -r to remove directories", p)
+	}
+	return dir.Unlink(name)
+}
+
+// Mv moves the node at src to dst. When dst is an existing directory, the
+// node is moved into it.
+func Mv(r *Root, src, dst string) error {
+	srcDirp, srcName := gopath.Split(gopath.Clean(src))
+	if srcName == "" {
+		return errors.New("cannot move the root")
+	}
+	srcDir, err := lookupDir(r, srcDirp)
+	if err != nil {
+		return err
+	}
+	child, err := srcDir.Child(srcName)
+	if err != nil {
+		return err
+	}
+	nd, err := child.GetNode()
+	if err != nil {
+		return err
+	}
+
+	if d, err := lookupDir(r, dst); err == nil {
+		dst = gopath.Join(dst, srcName)
+		if d == srcDir {
+			return nil
+		}
+	}
+	if strings.HasPrefix(gopath.Clean(dst)+"/", gopath.Clean(src)+"/") {
+		return fmt.Errorf("cannot move %s into itself", src)
+	}
+	if err := PutNode(r, gopath.Clean(dst), nd.Copy()); err != nil {
+		return err
+	}
+	return srcDir.Unlink(srcName)
+}
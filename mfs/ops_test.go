@@ -0,0 +1,215 @@
+package mfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	pin "github.com/ipfs/go-ipfs/pin"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+func setupRoot(t *testing.T, ctx context.Context, pf PubFunc) *Root {
+	dserv := mdtest.Mock()
+	pins := pin.NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dserv)
+	root, err := NewRoot(ctx, dserv, pins, &dag.Node{Data: ft.FolderPBData()}, pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func writeTestFile(t *testing.T, r *Root, p string, data []byte) {
+	if err := PutNode(r, p, &dag.Node{Data: ft.FilePBData(nil, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	fsn, err := Lookup(r, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := fsn.(*File)
+	if _, err := fi.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, r *Root, p string) []byte {
+	fsn, err := Lookup(r, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, ok := fsn.(*File)
+	if !ok {
+		t.Fatalf("%s is not a file", p)
+	}
+	if _, err := fi.Seek(0, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(fi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestOps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := setupRoot(t, ctx, nil)
+
+	if err := Mkdir(r, "/a/b", false); err != os.ErrNotExist {
+		t.Fatalf("expected ErrNotExist without parents, got %v", err)
+	}
+	if err := Mkdir(r, "/a/b", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(r, "/a", false); err != os.ErrExist {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+	if err := Mkdir(r, "/a", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(r, "a", true); err == nil {
+		t.Fatal("relative paths should be refused")
+	}
+
+	data := []byte("hello mfs")
+	writeTestFile(t, r, "/a/b/f", data)
+	if b := readTestFile(t, r, "/a/b/f"); !bytes.Equal(b, data) {
+		t.Fatalf("read %q, expected %q", b, data)
+	}
+	if err := Mkdir(r, "/a/b/f/c", true); err == nil {
+		t.Fatal("a directory should not be made under a file")
+	}
+
+	if err := Mv(r, "/a/b/f", "/a/g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lookup(r, "/a/b/f"); err != os.ErrNotExist {
+		t.Fatalf("the moved file should be gone, got %v", err)
+	}
+	if b := readTestFile(t, r, "/a/g"); !bytes.Equal(b, data) {
+		t.Fatalf("read %q, expected %q", b, data)
+	}
+
+	// moving into a directory keeps the name
+	if err := Mv(r, "/a/g", "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if b := readTestFile(t, r, "/a/b/g"); !bytes.Equal(b, data) {
+		t.Fatalf("read %q, expected %q", b, data)
+	}
+	if err := Mv(r, "/a", "/a/b"); err == nil {
+		t.Fatal("a directory should not be moved into itself")
+	}
+
+	if err := Remove(r, "/a", false); err == nil {
+		t.Fatal("a directory should not be removed without recursive")
+	}
+	if err := Remove(r, "/a/b/g", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(r, "/a", true); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := rootDir(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := dir.List(); len(l) != 0 {
+		t.Fatalf("expected an empty root, got %v", l)
+	}
+}
+
+func TestRootPublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	published := make(chan key.Key, 1)
+	r := setupRoot(t, ctx, func(_ context.Context, k key.Key) error {
+		select {
+		case published <- k:
+		default:
+		}
+		return nil
+	})
+
+	if err := Mkdir(r, "/d", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Publish(ctx); err != nil {
+		t.Fatal(err)
+	}
+	k := <-published
+
+	nd, err := r.GetValue().GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nk, _ := nd.Key(); nk != k {
+		t.Fatalf("published %s, expected %s", k, nk)
+	}
+	if _, err := nd.GetNodeLink("d"); err != nil {
+		t.Fatal("the published root should link to the new directory")
+	}
+}
+
+func TestListEntries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := setupRoot(t, ctx, nil)
+
+	if err := Mkdir(r, "/a", false); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, r, "/b", []byte("b"))
+	if err := Mkdir(r, "/c", false); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := rootDir(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh directory, with none of the children loaded yet
+	nd, err := dir.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh := NewDirectory(ctx, "fresh", nd.Copy(), r, r)
+
+	for _, d := range []*Directory{dir, fresh} {
+		var got []NodeListing
+		for offset := 0; ; offset += 2 {
+			ents, err := d.ListEntries(ctx, offset, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ents) == 0 {
+				break
+			}
+			got = append(got, ents...)
+		}
+
+		want := []NodeListing{{"a", TDir}, {"b", TFile}, {"c", TDir}}
+		if len(got) != len(want) {
+			t.Fatalf("listed %v, expected %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("listed %v, expected %v", got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// package mfs implements an in memory model of a mutable unixfs filesystem,
+// on which the ipns fuse filesystem and the files commands are built.
+//
+// It consists of three main structs:
+// 1) Roots
+//        A Root holds the top of a tree, and publishes the new hash of the
+//        tree after changes, with the PubFunc it was given
+// 2) Directories
+// 3) Files
+package mfs
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("mfs")
+
+var ErrIsDirectory = errors.New("error: is a directory")
+
+type childCloser interface {
+	closeChild(string, *dag.Node) error
+}
+
+type NodeType int
+
+const (
+	TFile NodeType = iota
+	TDir
+)
+
+// FSNode represents any node (directory, root, or file) in the mfs filesystem
+type FSNode interface {
+	GetNode() (*dag.Node, error)
+	Type() NodeType
+	Lock()
+	Unlock()
+}
+
+// PubFunc is called with the hash of the root of a tree after it changed
+type PubFunc func(context.Context, key.Key) error
+
+// Root represents the root of a filesystem tree
+type Root struct {
+	dserv dag.DAGService
+	pins  pin.Pinner
+
+	// val represents the node at the root. It can either be a File or a Directory
+	val FSNode
+
+	repub *Republisher
+	pf    PubFunc
+
+	// pinned is the root last pinned. The root is pinned best effort, so
+	// garbage collection keeps the parts of the tree that are stored
+	// locally without requiring all of it to be.
+	pinLk  sync.Mutex
+	pinned key.Key
+}
+
+// NewRoot creates a new Root for the tree of node, and starts up a
+// republisher routine that calls pf after changes. pf may be nil.
+func NewRoot(parent context.Context, ds dag.DAGService, pins pin.Pinner, node *dag.Node, pf PubFunc) (*Root, error) {
+	root := &Root{
+		dserv: ds,
+		pins:  pins,
+		pf:    pf,
+	}
+
+	mk, err := node.Key()
+	if err != nil {
+		return nil, err
+	}
+	if err := root.pinRoot(mk); err != nil {
+		return nil, err
+	}
+
+	root.repub = NewRepublisher(root, time.Millisecond*300, time.Second*3)
+	go root.repub.Run(parent)
+
+	pbn, err := ft.FromBytes(node.Data)
+	if err != nil {
+		log.Error("root was not unixfs node")
+		return nil, err
+	}
+
+	switch pbn.GetType() {
+	case ft.TDirectory:
+		root.val = NewDirectory(parent, mk.String(), node, root, root)
+	case ft.TFile, ft.TMetadata, ft.TRaw:
+		fi, err := NewFile(mk.String(), node, root, root)
+		if err != nil {
+			return nil, err
+		}
+		root.val = fi
+	default:
+		return nil, ErrInvalidChild
+	}
+	return root, nil
+}
+
+func (kr *Root) GetValue() FSNode {
+	return kr.val
+}
+
+// closeChild implements the childCloser interface, and signals to the publisher that
+// there are changes ready to be published
+func (kr *Root) closeChild(name string, nd *dag.Node) error {
+	kr.repub.Touch()
+	return nil
+}
+
+// Publish stores the current root, and passes its hash to the PubFunc
+func (kr *Root) Publish(ctx context.Context) error {
+	nd, err := kr.val.GetNode()
+	if err != nil {
+		return err
+	}
+
+	// Holding this lock so our child doesnt change out from under us
+	kr.val.Lock()
+	k, err := kr.dserv.Add(nd)
+	kr.val.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := kr.pinRoot(k); err != nil {
+		return err
+	}
+
+	if kr.pf == nil {
+		return nil
+	}
+	// Dont want to hold the lock while we publish
+	// otherwise we are holding the lock through a costly
+	// network operation
+	return kr.pf(ctx, k)
+}
+
+// Close publishes the pending changes.
+func (kr *Root) Close() error {
+	return kr.Publish(context.Background())
+}
+
+// pinRoot moves the best effort pin of this root to the given key.
+func (kr *Root) pinRoot(k key.Key) error {
+	kr.pinLk.Lock()
+	defer kr.pinLk.Unlock()
+
+	if k == kr.pinned {
+		return nil
+	}
+
+	mp := kr.pins.GetManual()
+	mp.PinWithMode(k, pin.BestEffort)
+	if kr.pinned != "" {
+		mp.RemovePinWithMode(kr.pinned, pin.BestEffort)
+	}
+	kr.pinned = k
+	return kr.pins.Flush()
+}
+
+// Republisher manages when to publish a given root
+type Republisher struct {
+	TimeoutLong  time.Duration
+	TimeoutShort time.Duration
+	Publish      chan struct{}
+	root         *Root
+}
+
+// NewRepublisher creates a new Republisher object to republish the given root
+// using the given short and long time intervals
+func NewRepublisher(root *Root, tshort, tlong time.Duration) *Republisher {
+	return &Republisher{
+		TimeoutShort: tshort,
+		TimeoutLong:  tlong,
+		Publish:      make(chan struct{}, 1),
+		root:         root,
+	}
+}
+
+// Touch signals that an update has occurred since the last publish.
+// Multiple consecutive touches may extend the time period before
+// the next Publish occurs in order to more efficiently batch updates
+func (np *Republisher) Touch() {
+	select {
+	case np.Publish <- struct{}{}:
+	default:
+	}
+}
+
+// Run is the main republisher loop
+func (np *Republisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-np.Publish:
+			quick := time.After(np.TimeoutShort)
+			longer := time.After(np.TimeoutLong)
+
+		wait:
+			select {
+			case <-ctx.Done():
+				return
+			case <-np.Publish:
+				quick = time.After(np.TimeoutShort)
+				goto wait
+			case <-quick:
+			case <-longer:
+			}
+
+			log.Info("Publishing Changes!")
+			err := np.root.Publish(ctx)
+			if err != nil {
+				log.Errorf("republishRoot error: %s", err)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
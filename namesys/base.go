@@ -52,3 +52,81 @@ func resolve(ctx context.Context, r resolver, name string, depth int, prefixes .
 		}
 	}
 }
+
+// ResolveOpts are the options of Resolve. The zero value resolves up to
+// DefaultDepthLimit names in a row, using the cache.
+type ResolveOpts struct {
+	// Depth is the most names to resolve in a row. Zero means
+	// DefaultDepthLimit, and a negative depth means no limit.
+	Depth int
+
+	// NoCache resolves the names from their records, see WithoutCache.
+	NoCache bool
+}
+
+// Resolve resolves the IPNS path name, such as /ipns/<name>/a/b, with r,
+// following the names it resolves to until it gets to an IPFS path, or
+// reaches the depth limit. The trailing path of name, and of the values of
+// the names on the way, is kept, so the example resolves to
+// /ipfs/<hash>/a/b. Like ResolveN, it returns ErrResolveRecursion along
+// with the path it got to when it reaches the depth limit. The /ipns/
+// prefix of name may be left out; IPFS paths are returned as is.
+func Resolve(ctx context.Context, r Resolver, name string, opts ResolveOpts) (path.Path, error) {
+	if strings.HasPrefix(name, "/ipfs/") {
+		return path.ParsePath(name)
+	}
+	if opts.NoCache {
+		ctx = WithoutCache(ctx)
+	}
+	depth := opts.Depth
+	if depth == 0 {
+		depth = DefaultDepthLimit
+	}
+
+	cur := strings.TrimPrefix(name, "/ipns/")
+	var rest string
+	for {
+		// split off the trailing path, which goes before the trailing
+		// path of the previous steps
+		if i := strings.Index(cur, "/"); i >= 0 {
+			rest = cur[i:] + rest
+			cur = cur[:i]
+		}
+		if cur == "" {
+			return "", path.ErrNoComponents
+		}
+
+		p, err := resolveStep(ctx, r, cur)
+		if err != nil {
+			log.Warningf("Could not resolve %s", cur)
+			return "", err
+		}
+		log.Debugf("Resolved %s to %s", cur, p)
+
+		res := path.Path(strings.TrimSuffix(p.String(), "/") + rest)
+		if !strings.HasPrefix(p.String(), "/ipns/") {
+			// we've bottomed out with an IPFS path, or something that
+			// we don't know how to resolve
+			return res, nil
+		}
+		if depth == 1 {
+			return res, ErrResolveRecursion
+		}
+		if depth > 1 {
+			depth--
+		}
+		cur = strings.TrimPrefix(p.String(), "/ipns/")
+	}
+}
+
+// resolveStep resolves the bare name once with r.
+func resolveStep(ctx context.Context, r Resolver, name string) (path.Path, error) {
+	if once, ok := r.(resolver); ok {
+		return once.resolveOnce(ctx, name)
+	}
+	p, err := r.ResolveN(ctx, "/ipns/"+name, 1)
+	if err == ErrResolveRecursion {
+		err = nil
+	}
+	return p, err
+}
@@ -0,0 +1,83 @@
+package namesys
+
+import (
+	"time"
+
+	lru "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/hashicorp/golang-lru"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// DefaultResolverCacheTTL is how long a resolved name is cached when its
+// record doesn't say.
+var DefaultResolverCacheTTL = time.Minute
+
+// resolveCache remembers the values names resolved to, until the ttl of
+// their records, or their end of life, whichever comes first.
+type resolveCache struct {
+	lru *lru.Cache // of name string -> cacheEntry
+}
+
+type cacheEntry struct {
+	val path.Path
+	eol time.Time
+}
+
+// newResolveCache returns a cache of size names, or nil when size is zero.
+// A nil cache holds nothing.
+func newResolveCache(size int) *resolveCache {
+	if size <= 0 {
+		return nil
+	}
+	c, err := lru.New(size)
+	if err != nil {
+		panic(err) // only fails on sizes we don't pass
+	}
+	return &resolveCache{lru: c}
+}
+
+func (c *resolveCache) get(ctx context.Context, name string) (path.Path, bool) {
+	if c == nil || skipCache(ctx) {
+		return "", false
+	}
+	v, ok := c.lru.Get(name)
+	if !ok {
+		return "", false
+	}
+	e := v.(cacheEntry)
+	if time.Now().After(e.eol) {
+		c.lru.Remove(name)
+		return "", false
+	}
+	return e.val, true
+}
+
+// put caches val for name, for ttl, or DefaultResolverCacheTTL if ttl is
+// zero, but not past eol.
+func (c *resolveCache) put(name string, val path.Path, ttl time.Duration, eol time.Time) {
+	if c == nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = DefaultResolverCacheTTL
+	}
+	if until := time.Now().Add(ttl); until.Before(eol) {
+		eol = until
+	}
+	c.lru.Add(name, cacheEntry{val: val, eol: eol})
+}
+
+type noCacheKey struct{}
+
+// WithoutCache returns a context under which names are resolved from their
+// records, as with 'ipfs name resolve --nocache'. The values found are
+// still cached for later resolutions.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func skipCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(noCacheKey{}).(bool)
+	return skip
+}
@@ -2,7 +2,6 @@ package namesys
 
 import (
 	"errors"
-	"net"
 	"strings"
 
 	isd "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-is-domain"
@@ -11,7 +10,8 @@ import (
 	path "github.com/ipfs/go-ipfs/path"
 )
 
-type LookupTXTFunc func(name string) (txt []string, err error)
+// LookupTXTFunc looks up the TXT records of a DNS name.
+type LookupTXTFunc func(ctx context.Context, name string) (txt []string, err error)
 
 // DNSResolver implements a Resolver on DNS domains
 type DNSResolver struct {
@@ -20,15 +20,26 @@ type DNSResolver struct {
 	// cache would need a timeout
 }
 
-// NewDNSResolver constructs a name resolver using DNS TXT records.
+// NewDNSResolver constructs a name resolver using DNS TXT records, looked up
+// with the system resolver.
 func NewDNSResolver() Resolver {
-	return &DNSResolver{lookupTXT: net.LookupTXT}
+	return NewDNSResolverWithLookup(nil)
+}
+
+// NewDNSResolverWithLookup constructs a name resolver using the DNS TXT
+// records found with lookup, see NewLookupTXT. A nil lookup uses the system
+// resolver.
+func NewDNSResolverWithLookup(lookup LookupTXTFunc) Resolver {
+	return newDNSResolver(lookup)
 }
 
 // newDNSResolver constructs a name resolver using DNS TXT records,
 // returning a resolver instead of NewDNSResolver's Resolver.
-func newDNSResolver() resolver {
-	return &DNSResolver{lookupTXT: net.LookupTXT}
+func newDNSResolver(lookup LookupTXTFunc) *DNSResolver {
+	if lookup == nil {
+		lookup = systemLookupTXT
+	}
+	return &DNSResolver{lookupTXT: lookup}
 }
 
 // Resolve implements Resolver.
@@ -43,14 +54,24 @@ func (r *DNSResolver) ResolveN(ctx context.Context, name string, depth int) (pat
 
 // resolveOnce implements resolver.
 // TXT records for a given domain name should contain a b58
-// encoded multihash.
+// encoded multihash. The records of the _dnslink subdomain of the name
+// are preferred to those of the name itself, so that the name can keep
+// TXT records, or a CNAME, of its own.
 func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
 	if !isd.IsDomain(name) {
 		return "", errors.New("not a valid domain name")
 	}
 
 	log.Infof("DNSResolver resolving %s", name)
-	txt, err := r.lookupTXT(name)
+	if p, err := r.lookupEntry(ctx, "_dnslink."+name); err == nil {
+		return p, nil
+	}
+	return r.lookupEntry(ctx, name)
+}
+
+// lookupEntry returns the first valid entry in the TXT records of name.
+func (r *DNSResolver) lookupEntry(ctx context.Context, name string) (path.Path, error) {
+	txt, err := r.lookupTXT(ctx, name)
 	if err != nil {
 		return "", err
 	}
@@ -2,14 +2,18 @@ package namesys
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 )
 
 type mockDNS struct {
 	entries map[string][]string
 }
 
-func (m *mockDNS) lookupTXT(name string) (txt []string, err error) {
+func (m *mockDNS) lookupTXT(ctx context.Context, name string) (txt []string, err error) {
 	txt, ok := m.entries[name]
 	if !ok {
 		return nil, fmt.Errorf("No TXT entry for %s", name)
@@ -86,6 +90,21 @@ func newMockDNS() *mockDNS {
 			"bad.example.com": []string{
 				"dnslink=",
 			},
+			"_dnslink.sub.example.com": []string{
+				"dnslink=/ipns/dns2.example.com",
+			},
+			"sub.example.com": []string{
+				"dnslink=/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
+			},
+			"_dnslink.subonly.example.com": []string{
+				"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD",
+			},
+			"_dnslink.badsub.example.com": []string{
+				"dnslink=",
+			},
+			"badsub.example.com": []string{
+				"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD",
+			},
 		},
 	}
 }
@@ -109,4 +128,44 @@ func TestDNSResolution(t *testing.T) {
 	testResolution(t, r, "loop1.example.com", 3, "/ipns/loop2.example.com", ErrResolveRecursion)
 	testResolution(t, r, "loop1.example.com", DefaultDepthLimit, "/ipns/loop1.example.com", ErrResolveRecursion)
 	testResolution(t, r, "bad.example.com", DefaultDepthLimit, "", ErrResolveFailed)
+	testResolution(t, r, "sub.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+	testResolution(t, r, "sub.example.com", 1, "/ipns/dns2.example.com", ErrResolveRecursion)
+	testResolution(t, r, "subonly.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+	testResolution(t, r, "badsub.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+}
+
+func TestUnquoteTXT(t *testing.T) {
+	cases := map[string]string{
+		`dnslink=/ipfs/Qm`:       "dnslink=/ipfs/Qm",
+		`"dnslink=/ipfs/Qm"`:     "dnslink=/ipfs/Qm",
+		`"dnslink=/ip" "fs/Qm"`:  "dnslink=/ipfs/Qm",
+		`"dnslink=/ipfs/\"Qm\""`: `dnslink=/ipfs/"Qm"`,
+	}
+	for in, out := range cases {
+		if got := unquoteTXT(in); got != out {
+			t.Errorf("unquoteTXT(%s) = %q, expected %q", in, got, out)
+		}
+	}
+}
+
+func TestDoHLookupTXT(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("type") != "TXT" {
+			http.Error(w, "bad type", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get("name") {
+		case "_dnslink.example.com":
+			fmt.Fprint(w, `{"Status":0,"Answer":[{"type":5,"data":"other.example.com."},{"type":16,"data":"\"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD\""}]}`)
+		default:
+			fmt.Fprint(w, `{"Status":3}`)
+		}
+	}))
+	defer ts.Close()
+
+	r := NewDNSResolverWithLookup(NewLookupTXT(nil, ts.URL))
+	testResolution(t, r, "example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+	if _, err := r.Resolve(context.Background(), "missing.example.com"); err == nil {
+		t.Fatal("resolved a missing name")
+	}
 }
@@ -0,0 +1,226 @@
+package namesys
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	dns "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/miekg/dns"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+// errNoSuchHost is the Err of the net.DNSError returned for names that
+// don't exist, the same as the system resolver's.
+const errNoSuchHost = "no such host"
+
+// NewLookupTXT returns a LookupTXTFunc asking the DNS over HTTPS server at
+// httpsEndpoint if it is set, or else the DNS servers in servers, in order,
+// given as host or host:port. Without either, it uses the system resolver.
+func NewLookupTXT(servers []string, httpsEndpoint string) LookupTXTFunc {
+	switch {
+	case httpsEndpoint != "":
+		return dohLookupTXT(httpsEndpoint)
+	case len(servers) > 0:
+		return serversLookupTXT(servers)
+	default:
+		return systemLookupTXT
+	}
+}
+
+type txtResult struct {
+	txt []string
+	err error
+}
+
+// lookupWithContext runs lookup, returning early when ctx is done. The
+// lookup itself can't be cancelled, it finishes in the background.
+func lookupWithContext(ctx context.Context, lookup func() ([]string, error)) ([]string, error) {
+	res := make(chan txtResult, 1)
+	go func() {
+		txt, err := lookup()
+		res <- txtResult{txt, err}
+	}()
+	select {
+	case r := <-res:
+		return r.txt, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func systemLookupTXT(ctx context.Context, name string) ([]string, error) {
+	return lookupWithContext(ctx, func() ([]string, error) {
+		return net.LookupTXT(name)
+	})
+}
+
+func serversLookupTXT(servers []string) LookupTXTFunc {
+	addrs := make([]string, len(servers))
+	for i, s := range servers {
+		addrs[i] = s
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			addrs[i] = net.JoinHostPort(s, "53")
+		}
+	}
+
+	return func(ctx context.Context, name string) ([]string, error) {
+		var err error
+		for _, addr := range addrs {
+			var txt []string
+			txt, err = lookupWithContext(ctx, func() ([]string, error) {
+				return exchangeTXT(addr, name)
+			})
+			if err == nil {
+				return txt, nil
+			}
+			if err == ctx.Err() {
+				return nil, err
+			}
+			// an answer that the name doesn't exist is final, only try
+			// the next server when this one didn't answer
+			if dnserr, ok := err.(*net.DNSError); ok && dnserr.Err == errNoSuchHost {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+}
+
+// exchangeTXT asks the DNS server at addr for the TXT records of name,
+// over UDP, and over TCP again if the answer was truncated.
+func exchangeTXT(addr, name string) ([]string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeTXT)
+
+	c := new(dns.Client)
+	r, _, err := c.Exchange(m, addr)
+	if err == nil && r.Truncated {
+		c.Net = "tcp"
+		r, _, err = c.Exchange(m, addr)
+	}
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: addr}
+	}
+	switch r.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, &net.DNSError{Err: errNoSuchHost, Name: name, Server: addr}
+	default:
+		return nil, &net.DNSError{Err: dns.RcodeToString[r.Rcode], Name: name, Server: addr}
+	}
+
+	var txt []string
+	for _, rr := range r.Answer {
+		if t, ok := rr.(*dns.TXT); ok {
+			txt = append(txt, strings.Join(t.Txt, ""))
+		}
+	}
+	return txt, nil
+}
+
+// dohResponse is the JSON answer of a DNS over HTTPS server, as described
+// at https://developers.google.com/speed/public-dns/docs/doh/json
+type dohResponse struct {
+	Status int
+	Answer []struct {
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	}
+}
+
+const (
+	dnsTypeTXT     = 16
+	dnsRcodeNXName = 3
+)
+
+func dohLookupTXT(endpoint string) LookupTXTFunc {
+	return func(ctx context.Context, name string) ([]string, error) {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("name", name)
+		q.Set("type", "TXT")
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Cancel = ctx.Done()
+		req.Header.Set("Accept", "application/dns-json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("DNS over HTTPS lookup of %s: %s", name, resp.Status)
+		}
+
+		var dr dohResponse
+		if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+			return nil, fmt.Errorf("DNS over HTTPS lookup of %s: %s", name, err)
+		}
+		switch dr.Status {
+		case 0:
+		case dnsRcodeNXName:
+			return nil, &net.DNSError{Err: errNoSuchHost, Name: name}
+		default:
+			return nil, fmt.Errorf("DNS over HTTPS lookup of %s: rcode %d", name, dr.Status)
+		}
+
+		var txt []string
+		for _, a := range dr.Answer {
+			if a.Type == dnsTypeTXT {
+				txt = append(txt, unquoteTXT(a.Data))
+			}
+		}
+		return txt, nil
+	}
+}
+
+// unquoteTXT joins the quoted strings making up the data of a TXT record,
+// as in `"dnslink=/ipfs/Qm" "..."`. Data that isn't quoted is kept as is.
+func unquoteTXT(data string) string {
+	if !strings.HasPrefix(data, `"`) {
+		return data
+	}
+	var out string
+	for rest := strings.TrimSpace(data); rest != ""; rest = strings.TrimSpace(rest) {
+		n := quotedLen(rest)
+		if n < 0 {
+			return data
+		}
+		uq, err := strconv.Unquote(rest[:n])
+		if err != nil {
+			return data
+		}
+		out += uq
+		rest = rest[n:]
+	}
+	return out
+}
+
+// quotedLen returns the length of the double quoted string s starts with,
+// quotes included, or -1 if it doesn't start with one.
+func quotedLen(s string) int {
+	if !strings.HasPrefix(s, `"`) {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -47,6 +47,10 @@ const (
 	// trust resolution to eventually complete and can't put an upper
 	// limit on how many steps it will take.
 	UnlimitedDepth = 0
+
+	// DefaultRecordLifetime is how long published records are valid for,
+	// unless told otherwise.
+	DefaultRecordLifetime = time.Hour * 24
 )
 
 // ErrResolveFailed signals an error when attempting to resolve.
@@ -110,4 +114,12 @@ type Publisher interface {
 	// TODO: to be replaced by a more generic 'PublishWithValidity' type
 	// call once the records spec is implemented
 	PublishWithEOL(ctx context.Context, name ci.PrivKey, value path.Path, eol time.Time) error
+
+	// PublishWithTTL is PublishWithEOL for a record that resolvers may
+	// cache for ttl. A zero ttl leaves it up to the resolvers.
+	PublishWithTTL(ctx context.Context, name ci.PrivKey, value path.Path, eol time.Time, ttl time.Duration) error
+
+	// PublishWithKey publishes value under the name of the keystore key
+	// keyName, which resolves as /ipns/<peer id of the key>.
+	PublishWithKey(ctx context.Context, keyName string, value path.Path) error
 }
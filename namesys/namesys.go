@@ -6,6 +6,7 @@ import (
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	path "github.com/ipfs/go-ipfs/path"
 	routing "github.com/ipfs/go-ipfs/routing"
@@ -17,26 +18,86 @@ import (
 // (a) ipfs routing naming: SFS-like PKI names.
 // (b) dns domains: resolves using links in DNS TXT records
 // (c) proquints: interprets string as the raw byte data.
+// (d) static names: looks them up in a fixed table.
+//
+// It tries them in a configurable order, see Opts.Resolvers.
 //
 // It can only publish to: (a) ipfs routing naming.
 //
 type mpns struct {
-	resolvers  map[string]resolver
+	resolvers  []namedResolver
 	publishers map[string]Publisher
 }
 
-// NewNameSystem will construct the IPFS naming system based on Routing
-func NewNameSystem(r routing.IpfsRouting, ds ds.Datastore) NameSystem {
-	return &mpns{
-		resolvers: map[string]resolver{
-			"dns":      newDNSResolver(),
-			"proquint": new(ProquintResolver),
-			"dht":      newRoutingResolver(r),
-		},
+type namedResolver struct {
+	name string
+	resolver
+}
+
+// DefaultResolvers are the resolvers a NameSystem tries, in order, unless
+// told otherwise.
+var DefaultResolvers = []string{"static", "proquint", "dns", "dht"}
+
+// Opts are the settings of a NameSystem. The zero value caches nothing and
+// looks DNS names up with the system resolver.
+type Opts struct {
+	// CacheSize is the number of resolved names to cache, see
+	// NewRoutingResolver.
+	CacheSize int
+
+	// LookupTXT looks up the TXT records of DNS names, see NewLookupTXT.
+	LookupTXT LookupTXTFunc
+
+	// Resolvers are the resolvers to try, in order, out of "static",
+	// "proquint", "dns" and "dht". Empty means DefaultResolvers.
+	Resolvers []string
+
+	// Static is the table of the "static" resolver, see
+	// NewStaticResolver.
+	Static map[string]path.Path
+
+	// OfflinePublish makes publishing succeed when there are no peers to
+	// put the record to, keeping it in the local datastore only, from
+	// where it is served and republished. Otherwise such a publish fails.
+	OfflinePublish bool
+}
+
+// NewNameSystem will construct the IPFS naming system based on Routing.
+// The keys of ks, if any, can be published with PublishWithKey.
+func NewNameSystem(r routing.IpfsRouting, ds ds.Datastore, ks keystore.Keystore, opts Opts) NameSystem {
+	cache := newResolveCache(opts.CacheSize)
+	pub := NewRoutingPublisher(r, ds)
+	pub.keys = ks
+	pub.cache = cache
+	pub.offline = opts.OfflinePublish
+	ns := &mpns{
 		publishers: map[string]Publisher{
-			"/ipns/": NewRoutingPublisher(r, ds),
+			"/ipns/": pub,
 		},
 	}
+
+	names := opts.Resolvers
+	if len(names) == 0 {
+		names = DefaultResolvers
+	}
+	for _, name := range names {
+		var res resolver
+		switch name {
+		case "static":
+			res = NewStaticResolver(opts.Static)
+		case "proquint":
+			res = new(ProquintResolver)
+		case "dns":
+			res = newDNSResolver(opts.LookupTXT)
+		case "dht":
+			res = newRoutingResolver(r, cache)
+		default:
+			log.Warningf("unknown resolver %q", name)
+			continue
+		}
+		ns.resolvers = append(ns.resolvers, namedResolver{name, res})
+	}
+	return ns
 }
 
 // Resolve implements Resolver.
@@ -68,9 +129,9 @@ func (ns *mpns) resolveOnce(ctx context.Context, name string) (path.Path, error)
 		return "", ErrResolveFailed
 	}
 
-	for protocol, resolver := range ns.resolvers {
-		log.Debugf("Attempting to resolve %s with %s", name, protocol)
-		p, err := resolver.resolveOnce(ctx, segments[2])
+	for _, r := range ns.resolvers {
+		log.Debugf("Attempting to resolve %s with %s", name, r.name)
+		p, err := r.resolveOnce(ctx, segments[2])
 		if err == nil {
 			return p, err
 		}
@@ -87,3 +148,11 @@ func (ns *mpns) Publish(ctx context.Context, name ci.PrivKey, value path.Path) e
 func (ns *mpns) PublishWithEOL(ctx context.Context, name ci.PrivKey, val path.Path, eol time.Time) error {
 	return ns.publishers["/ipns/"].PublishWithEOL(ctx, name, val, eol)
 }
+
+func (ns *mpns) PublishWithTTL(ctx context.Context, name ci.PrivKey, val path.Path, eol time.Time, ttl time.Duration) error {
+	return ns.publishers["/ipns/"].PublishWithTTL(ctx, name, val, eol, ttl)
+}
+
+func (ns *mpns) PublishWithKey(ctx context.Context, keyName string, val path.Path) error {
+	return ns.publishers["/ipns/"].PublishWithKey(ctx, keyName, val)
+}
@@ -2,11 +2,15 @@ package namesys
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	path "github.com/ipfs/go-ipfs/path"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
 )
 
 type mockResolver struct {
@@ -51,9 +55,9 @@ func mockResolverTwo() *mockResolver {
 
 func TestNamesysResolution(t *testing.T) {
 	r := &mpns{
-		resolvers: map[string]resolver{
-			"one": mockResolverOne(),
-			"two": mockResolverTwo(),
+		resolvers: []namedResolver{
+			{"one", mockResolverOne()},
+			{"two", mockResolverTwo()},
 		},
 	}
 
@@ -69,3 +73,86 @@ func TestNamesysResolution(t *testing.T) {
 	testResolution(t, r, "/ipns/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", 2, "/ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n", ErrResolveRecursion)
 	testResolution(t, r, "/ipns/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", 3, "/ipns/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy", ErrResolveRecursion)
 }
+
+func TestResolverOrder(t *testing.T) {
+	static := map[string]path.Path{
+		"/ipns/ipfs.io": path.FromString("/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD"),
+		"local.example": path.FromString("/ipns/ipfs.io"),
+	}
+	mock := newMockDNS()
+	mock.entries["ipfs.io"] = []string{"dnslink=/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"}
+	r := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+
+	ns := NewNameSystem(r, ds.NewMapDatastore(), nil, Opts{
+		LookupTXT: mock.lookupTXT,
+		Resolvers: []string{"static", "dns"},
+		Static:    static,
+	})
+	testResolution(t, ns, "/ipns/local.example", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+	testResolution(t, ns, "/ipns/dns1.example.com", DefaultDepthLimit, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD", nil)
+
+	ns = NewNameSystem(r, ds.NewMapDatastore(), nil, Opts{
+		LookupTXT: mock.lookupTXT,
+		Resolvers: []string{"dns", "static"},
+		Static:    static,
+	})
+	testResolution(t, ns, "/ipns/ipfs.io", DefaultDepthLimit, "/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn", nil)
+
+	ns = NewNameSystem(r, ds.NewMapDatastore(), nil, Opts{
+		LookupTXT: mock.lookupTXT,
+		Resolvers: []string{"static"},
+		Static:    static,
+	})
+	testResolution(t, ns, "/ipns/dns1.example.com", DefaultDepthLimit, "", ErrResolveFailed)
+}
+
+func TestReadHostsFile(t *testing.T) {
+	names, err := ReadHostsFile(strings.NewReader(`
+# the site
+example.com   /ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD
+docs.example.com	/ipns/example.com # moved
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names["docs.example.com"] != path.FromString("/ipns/example.com") {
+		t.Fatalf("wrong names: %v", names)
+	}
+
+	if _, err := ReadHostsFile(strings.NewReader("example.com\n")); err == nil {
+		t.Fatal("read a line without a path")
+	}
+	if _, err := ReadHostsFile(strings.NewReader("example.com /bad/path\n")); err == nil {
+		t.Fatal("read a line with an invalid path")
+	}
+}
+
+func TestResolveWithPath(t *testing.T) {
+	mock := newMockDNS()
+	mock.entries["path.example.com"] = []string{"dnslink=/ipns/sub.example.com/docs"}
+	mock.entries["file.example.com"] = []string{"dnslink=/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/index.html"}
+	r := NewDNSResolverWithLookup(mock.lookupTXT)
+
+	cases := []struct {
+		name     string
+		opts     ResolveOpts
+		expected string
+		err      error
+	}{
+		{"/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/a", ResolveOpts{}, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/a", nil},
+		{"/ipns/ipfs.example.com/a/b", ResolveOpts{}, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/a/b", nil},
+		{"dns1.example.com/a", ResolveOpts{}, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/a", nil},
+		{"/ipns/path.example.com/a", ResolveOpts{}, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/docs/a", nil},
+		{"/ipns/path.example.com/a", ResolveOpts{Depth: 1}, "/ipns/sub.example.com/docs/a", ErrResolveRecursion},
+		{"/ipns/path.example.com/a", ResolveOpts{Depth: 2}, "/ipns/dns2.example.com/docs/a", ErrResolveRecursion},
+		{"/ipns/file.example.com", ResolveOpts{}, "/ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD/index.html", nil},
+		{"/ipns/loop1.example.com/a", ResolveOpts{}, "/ipns/loop1.example.com/a", ErrResolveRecursion},
+		{"/ipns/bad.example.com/a", ResolveOpts{}, "", ErrResolveFailed},
+	}
+	for _, c := range cases {
+		p, err := Resolve(context.Background(), r, c.name, c.opts)
+		if err != c.err || p.String() != c.expected {
+			t.Errorf("%s with %+v resolved to %q, %v, expected %q, %v", c.name, c.opts, p, err, c.expected, c.err)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pb "github.com/ipfs/go-ipfs/namesys/pb"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
@@ -19,6 +20,8 @@ import (
 	pin "github.com/ipfs/go-ipfs/pin"
 	routing "github.com/ipfs/go-ipfs/routing"
 	dhtpb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	kb "github.com/ipfs/go-ipfs/routing/kbucket"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
 	record "github.com/ipfs/go-ipfs/routing/record"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	u "github.com/ipfs/go-ipfs/util"
@@ -32,6 +35,10 @@ var ErrExpiredRecord = errors.New("expired record")
 // unknown validity type.
 var ErrUnrecognizedValidity = errors.New("unrecognized validity type")
 
+// ErrNoKeystore is returned by PublishWithKey when the publisher has no
+// keystore to look the key up in.
+var ErrNoKeystore = errors.New("no keystore to publish with")
+
 var PublishPutValTimeout = time.Minute
 
 // ipnsPublisher is capable of publishing and resolving names to the IPFS
@@ -39,6 +46,12 @@ var PublishPutValTimeout = time.Minute
 type ipnsPublisher struct {
 	routing routing.IpfsRouting
 	ds      ds.Datastore
+	keys    keystore.Keystore
+	cache   *resolveCache
+
+	// offline is whether records that cannot be put to any peer are kept
+	// in the local datastore only, see Opts.OfflinePublish.
+	offline bool
 }
 
 // NewRoutingPublisher constructs a publisher for the IPFS Routing name system.
@@ -53,12 +66,30 @@ func NewRoutingPublisher(route routing.IpfsRouting, ds ds.Datastore) *ipnsPublis
 // and publishes it out to the routing system
 func (p *ipnsPublisher) Publish(ctx context.Context, k ci.PrivKey, value path.Path) error {
 	log.Debugf("Publish %s", value)
-	return p.PublishWithEOL(ctx, k, value, time.Now().Add(time.Hour*24))
+	return p.PublishWithEOL(ctx, k, value, time.Now().Add(DefaultRecordLifetime))
+}
+
+// PublishWithKey implements Publisher. It publishes value with the key
+// named keyName in the publisher's keystore.
+func (p *ipnsPublisher) PublishWithKey(ctx context.Context, keyName string, value path.Path) error {
+	if p.keys == nil {
+		return ErrNoKeystore
+	}
+	k, err := p.keys.Get(keyName)
+	if err != nil {
+		return fmt.Errorf("key %q: %s", keyName, err)
+	}
+	return p.Publish(ctx, k, value)
 }
 
 // PublishWithEOL is a temporary stand in for the ipns records implementation
 // see here for more details: https://github.com/ipfs/specs/tree/master/records
 func (p *ipnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time) error {
+	return p.PublishWithTTL(ctx, k, value, eol, 0)
+}
+
+// PublishWithTTL implements Publisher.
+func (p *ipnsPublisher) PublishWithTTL(ctx context.Context, k ci.PrivKey, value path.Path, eol time.Time, ttl time.Duration) error {
 
 	id, err := peer.IDFromPrivateKey(k)
 	if err != nil {
@@ -76,7 +107,22 @@ func (p *ipnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value
 	// increment it
 	seqnum++
 
-	return PutRecordToRouting(ctx, k, value, seqnum, eol, p.routing, id)
+	err = PutRecordToRouting(ctx, k, value, seqnum, eol, ttl, p.routing, id)
+	if err == kb.ErrLookupFailure && p.offline {
+		// there are no peers to publish to: keep the record in the local
+		// datastore, where it is served from, and where the republisher
+		// takes it from to announce it once the node is connected again
+		log.Infof("no peers to publish %s to, storing the record locally", id)
+		offline := offroute.NewOfflineRouter(p.ds, k)
+		err = PutRecordToRouting(ctx, k, value, seqnum, eol, ttl, offline, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	// resolve to the new value at once
+	p.cache.put(id.Pretty(), value, ttl, eol)
+	return nil
 }
 
 func (p *ipnsPublisher) getPreviousSeqNo(ctx context.Context, ipnskey key.Key) (uint64, error) {
@@ -121,12 +167,18 @@ func (p *ipnsPublisher) getPreviousSeqNo(ctx context.Context, ipnskey key.Key) (
 	return e.GetSequence(), nil
 }
 
-func PutRecordToRouting(ctx context.Context, k ci.PrivKey, value path.Path, seqnum uint64, eol time.Time, r routing.IpfsRouting, id peer.ID) error {
+// PutRecordToRouting signs a record for value, valid until eol, and puts it
+// and the public key in the routing system. A non-zero ttl tells resolvers
+// how long to cache the record.
+func PutRecordToRouting(ctx context.Context, k ci.PrivKey, value path.Path, seqnum uint64, eol time.Time, ttl time.Duration, r routing.IpfsRouting, id peer.ID) error {
 	namekey, ipnskey := IpnsKeysForID(id)
 	entry, err := CreateRoutingEntryData(k, value, seqnum, eol)
 	if err != nil {
 		return err
 	}
+	if ttl > 0 {
+		entry.Ttl = proto.Uint64(uint64(ttl.Nanoseconds()))
+	}
 
 	err = PublishEntry(ctx, r, ipnskey, entry)
 	if err != nil {
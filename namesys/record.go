@@ -0,0 +1,111 @@
+package namesys
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/gogo/protobuf/proto"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	path "github.com/ipfs/go-ipfs/path"
+	routing "github.com/ipfs/go-ipfs/routing"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// Record is the signed IPNS record of a name, as found in the routing
+// system.
+type Record struct {
+	// Name is the peer id of the key the record is signed with.
+	Name peer.ID
+
+	Value    path.Path
+	Sequence uint64
+
+	// EOL is when the record stops being valid.
+	EOL time.Time
+
+	// TTL is how long resolvers may cache the record, zero if the
+	// publisher left it up to them.
+	TTL time.Duration
+
+	PublicKey ci.PubKey
+
+	// Entry is the record as published.
+	Entry *pb.IpnsEntry
+}
+
+// Expired reports whether the record is past its end of life.
+func (r *Record) Expired() bool {
+	return time.Now().After(r.EOL)
+}
+
+// GetRecord fetches the IPNS record of name, given as a peer id with or
+// without the /ipns/ prefix, from the routing system, and checks that it is
+// signed by the key of the name. Unlike resolving the name, it returns
+// records that have expired, to tell why a name resolves the way it does.
+func GetRecord(ctx context.Context, r routing.IpfsRouting, name string) (*Record, error) {
+	hash, err := mh.FromB58String(strings.TrimPrefix(name, "/ipns/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPNS name %q: %s", name, err)
+	}
+	id := peer.ID(hash)
+
+	_, ipnskey := IpnsKeysForID(id)
+	val, err := r.GetValue(ctx, ipnskey)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, entry); err != nil {
+		return nil, err
+	}
+
+	// name should be a public key retrievable from ipfs
+	pubkey, err := routing.GetPublicKey(r, ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// check sig with pk
+	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
+		return nil, fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
+	}
+
+	rec := &Record{
+		Name:      id,
+		Sequence:  entry.GetSequence(),
+		TTL:       time.Duration(entry.GetTtl()),
+		PublicKey: pubkey,
+		Entry:     entry,
+	}
+	if entry.GetValidityType() == pb.IpnsEntry_EOL {
+		rec.EOL, err = u.ParseRFC3339(string(entry.GetValidity()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of life in record: %s", err)
+		}
+	} else {
+		return nil, ErrUnrecognizedValidity
+	}
+
+	// check for old style record:
+	valh, err := mh.Cast(entry.GetValue())
+	if err != nil {
+		// Not a multihash, probably a new record
+		rec.Value, err = path.ParsePath(string(entry.GetValue()))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Its an old style multihash record
+		log.Warning("Detected old style multihash record")
+		rec.Value = path.FromKey(key.Key(valh))
+	}
+	return rec, nil
+}
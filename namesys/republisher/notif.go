@@ -0,0 +1,51 @@
+package republisher
+
+import (
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+
+	inet "github.com/ipfs/go-ipfs/p2p/net"
+)
+
+// WatchNetwork kicks the republisher whenever the node connects to a peer
+// after having had none, so that the names published while it was offline
+// are announced.
+func (rp *Republisher) WatchNetwork(n inet.Network) {
+	rp.netlock.Lock()
+	rp.offline = len(n.Peers()) == 0
+	rp.netlock.Unlock()
+	n.Notify((*netNotifiee)(rp))
+}
+
+type netNotifiee Republisher
+
+func (nn *netNotifiee) republisher() *Republisher {
+	return (*Republisher)(nn)
+}
+
+func (nn *netNotifiee) Connected(n inet.Network, v inet.Conn) {
+	rp := nn.republisher()
+	rp.netlock.Lock()
+	reconnected := rp.offline
+	rp.offline = false
+	rp.netlock.Unlock()
+
+	if reconnected {
+		log.Debug("connected to the network, republishing soon")
+		rp.Kick()
+	}
+}
+
+func (nn *netNotifiee) Disconnected(n inet.Network, v inet.Conn) {
+	if len(n.Peers()) > 0 {
+		return
+	}
+	rp := nn.republisher()
+	rp.netlock.Lock()
+	rp.offline = true
+	rp.netlock.Unlock()
+}
+
+func (nn *netNotifiee) OpenedStream(n inet.Network, v inet.Stream) {}
+func (nn *netNotifiee) ClosedStream(n inet.Network, v inet.Stream) {}
+func (nn *netNotifiee) Listen(n inet.Network, a ma.Multiaddr)      {}
+func (nn *netNotifiee) ListenClose(n inet.Network, a ma.Multiaddr) {}
@@ -2,12 +2,15 @@ package republisher
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	path "github.com/ipfs/go-ipfs/path"
 	"github.com/ipfs/go-ipfs/routing"
@@ -27,29 +30,57 @@ var log = logging.Logger("ipns-repub")
 
 var DefaultRebroadcastInterval = time.Hour * 4
 
-const DefaultRecordLifetime = time.Hour * 24
+// ReconnectRebroadcastDelay is how long the republisher waits after the
+// node connects to its first peer before it republishes, so that the
+// records published while the node was offline get out.
+var ReconnectRebroadcastDelay = time.Second * 10
 
+// InitialRebroadcastDelay is how long the republisher waits after it starts
+// before its first round, so that the records which expired while the node
+// was offline come back soon.
+var InitialRebroadcastDelay = time.Minute
+
+const DefaultRecordLifetime = namesys.DefaultRecordLifetime
+
+// Republisher periodically re-signs the last records published for the
+// names the node owns, with a new end of life, and puts them back to the
+// routing system. The names are those added with AddName, whose keys are in
+// the peerstore, and those of all the keys in the keystore.
 type Republisher struct {
 	r  routing.IpfsRouting
 	ds ds.Datastore
 	ps peer.Peerstore
+	ks keystore.Keystore
 
 	Interval time.Duration
 
+	// how long to wait for the first round
+	InitialDelay time.Duration
+
 	// how long records that are republished should be valid for
 	RecordLifetime time.Duration
 
 	entrylock sync.Mutex
 	entries   map[peer.ID]struct{}
+
+	kick chan struct{}
+
+	netlock sync.Mutex
+	offline bool
 }
 
-func NewRepublisher(r routing.IpfsRouting, ds ds.Datastore, ps peer.Peerstore) *Republisher {
+// NewRepublisher returns a republisher for the names added with AddName and
+// those of the keys in ks, which may be nil.
+func NewRepublisher(r routing.IpfsRouting, ds ds.Datastore, ps peer.Peerstore, ks keystore.Keystore) *Republisher {
 	return &Republisher{
 		r:              r,
 		ps:             ps,
 		ds:             ds,
+		ks:             ks,
 		entries:        make(map[peer.ID]struct{}),
+		kick:           make(chan struct{}, 1),
 		Interval:       DefaultRebroadcastInterval,
+		InitialDelay:   InitialRebroadcastDelay,
 		RecordLifetime: DefaultRecordLifetime,
 	}
 }
@@ -61,70 +92,136 @@ func (rp *Republisher) AddName(id peer.ID) {
 }
 
 func (rp *Republisher) Run(proc goprocess.Process) {
-	tick := time.NewTicker(rp.Interval)
-	defer tick.Stop()
+	timer := time.NewTimer(rp.InitialDelay)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-tick.C:
+		case <-timer.C:
 			err := rp.republishEntries(proc)
 			if err != nil {
 				log.Error("Republisher failed to republish: ", err)
 			}
+			timer.Reset(rp.Interval)
+		case <-rp.kick:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(ReconnectRebroadcastDelay)
 		case <-proc.Closing():
 			return
 		}
 	}
 }
 
+// Kick makes the republisher run its next round soon, rather than at the
+// end of its interval.
+func (rp *Republisher) Kick() {
+	select {
+	case rp.kick <- struct{}{}:
+	default:
+	}
+}
+
+// keys returns the private keys of the names to republish, by the ids of
+// the names. The keystore is listed on every round, so the keys created
+// while the node runs are picked up.
+func (rp *Republisher) keys() (map[peer.ID]ci.PrivKey, error) {
+	keys := make(map[peer.ID]ci.PrivKey)
+
+	rp.entrylock.Lock()
+	for id := range rp.entries {
+		if priv := rp.ps.PrivKey(id); priv != nil {
+			keys[id] = priv
+		}
+	}
+	rp.entrylock.Unlock()
+
+	if rp.ks == nil {
+		return keys, nil
+	}
+	names, err := rp.ks.List()
+	if err != nil {
+		return keys, err
+	}
+	for _, name := range names {
+		priv, err := rp.ks.Get(name)
+		if err != nil {
+			return keys, err
+		}
+		id, err := peer.IDFromPrivateKey(priv)
+		if err != nil {
+			return keys, err
+		}
+		keys[id] = priv
+	}
+	return keys, nil
+}
+
 func (rp *Republisher) republishEntries(p goprocess.Process) error {
 	ctx, cancel := context.WithCancel(gpctx.OnClosingContext(p))
 	defer cancel()
 
-	for id, _ := range rp.entries {
-		log.Debugf("republishing ipns entry for %s", id)
-		priv := rp.ps.PrivKey(id)
+	keys, err := rp.keys()
+	if err != nil {
+		// republish what we have anyway
+		log.Error("listing the keys to republish: ", err)
+	}
 
-		// Look for it locally only
-		_, ipnskey := namesys.IpnsKeysForID(id)
-		p, seq, err := rp.getLastVal(ipnskey)
-		if err != nil {
-			if err == errNoEntry {
-				continue
-			}
-			return err
+	// a failure to republish a name doesn't hold the others back
+	var failed int
+	for id, priv := range keys {
+		if err := rp.republish(ctx, id, priv); err != nil {
+			log.Errorf("republishing ipns entry for %s: %s", id, err)
+			failed++
 		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to republish %d of %d names", failed, len(keys))
+	}
+	return nil
+}
 
-		// update record with same sequence number
-		eol := time.Now().Add(rp.RecordLifetime)
-		err = namesys.PutRecordToRouting(ctx, priv, p, seq, eol, rp.r, id)
-		if err != nil {
-			return err
+func (rp *Republisher) republish(ctx context.Context, id peer.ID, priv ci.PrivKey) error {
+	// Look for it locally only
+	_, ipnskey := namesys.IpnsKeysForID(id)
+	e, err := rp.getLastVal(ipnskey)
+	if err != nil {
+		if err == errNoEntry {
+			return nil
 		}
+		return err
 	}
+	log.Debugf("republishing ipns entry for %s", id)
 
-	return nil
+	// update record with same sequence number and ttl
+	eol := time.Now().Add(rp.RecordLifetime)
+	ttl := time.Duration(e.GetTtl())
+	return namesys.PutRecordToRouting(ctx, priv, path.Path(e.Value), e.GetSequence(), eol, ttl, rp.r, id)
 }
 
-func (rp *Republisher) getLastVal(k key.Key) (path.Path, uint64, error) {
+func (rp *Republisher) getLastVal(k key.Key) (*pb.IpnsEntry, error) {
 	ival, err := rp.ds.Get(k.DsKey())
 	if err != nil {
 		// not found means we dont have a previously published entry
-		return "", 0, errNoEntry
+		return nil, errNoEntry
 	}
 
 	val := ival.([]byte)
 	dhtrec := new(dhtpb.Record)
 	err = proto.Unmarshal(val, dhtrec)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
 	// extract published data from record
 	e := new(pb.IpnsEntry)
 	err = proto.Unmarshal(dhtrec.GetValue(), e)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
-	return path.Path(e.Value), e.GetSequence(), nil
+	return e, nil
 }
@@ -10,8 +10,10 @@ import (
 
 	"github.com/ipfs/go-ipfs/core"
 	mock "github.com/ipfs/go-ipfs/core/mock"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	. "github.com/ipfs/go-ipfs/namesys/republisher"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	mocknet "github.com/ipfs/go-ipfs/p2p/net/mock"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	path "github.com/ipfs/go-ipfs/path"
@@ -21,37 +23,72 @@ func TestRepublish(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// create network
-	mn := mocknet.New(ctx)
+	nodes := makeNetwork(ctx, t)
 
-	var nodes []*core.IpfsNode
-	for i := 0; i < 10; i++ {
-		nd, err := core.NewNode(ctx, &core.BuildCfg{
-			Online: true,
-			Host:   mock.MockHostOption(mn),
-		})
-		if err != nil {
+	// have one node publish records that are valid for 1 second, for its
+	// own name and for the name of a key in its keystore
+	publisher := nodes[3]
+	p := path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn") // does not need to be valid
+	rp := namesys.NewRoutingPublisher(publisher.Routing, publisher.Repo.Datastore())
+	err := rp.PublishWithEOL(ctx, publisher.PrivateKey, p, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sk, err := keystore.Create(publisher.Repo.Keystore(), "other", ci.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rp.PublishWithEOL(ctx, sk, p, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"/ipns/" + publisher.Identity.Pretty(), "/ipns/" + skid.Pretty()}
+	for _, name := range names {
+		if err := verifyResolution(nodes, name, p); err != nil {
 			t.Fatal(err)
 		}
+	}
 
-		nodes = append(nodes, nd)
+	// Now wait a second, the records will be invalid and we should fail to resolve
+	time.Sleep(time.Second)
+	for _, name := range names {
+		if err := verifyResolutionFails(nodes, name); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	mn.LinkAll()
+	// The republishers that are contained within the nodes have their timeout set
+	// to 12 hours. Instead of trying to tweak those, we're just going to pretend
+	// they dont exist and make our own.
+	repub := NewRepublisher(publisher.Routing, publisher.Repo.Datastore(), publisher.Peerstore, publisher.Repo.Keystore())
+	repub.Interval = time.Second
+	repub.InitialDelay = time.Second
+	repub.RecordLifetime = time.Second * 5
+	repub.AddName(publisher.Identity)
 
-	bsinf := core.BootstrapConfigWithPeers(
-		[]peer.PeerInfo{
-			nodes[0].Peerstore.PeerInfo(nodes[0].Identity),
-		},
-	)
+	proc := goprocess.Go(repub.Run)
+	defer proc.Close()
 
-	for _, n := range nodes[1:] {
-		if err := n.Bootstrap(bsinf); err != nil {
+	// we should be able to resolve them once it fires
+	for _, name := range names {
+		if err := waitForResolution(nodes, name, p, time.Second*10); err != nil {
 			t.Fatal(err)
 		}
 	}
+}
+
+func TestRepublishOnKick(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodes := makeNetwork(ctx, t)
 
-	// have one node publish a record that is valid for 1 second
 	publisher := nodes[3]
 	p := path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn") // does not need to be valid
 	rp := namesys.NewRoutingPublisher(publisher.Routing, publisher.Repo.Datastore())
@@ -59,38 +96,65 @@ func TestRepublish(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	time.Sleep(time.Second)
 
 	name := "/ipns/" + publisher.Identity.Pretty()
-	if err := verifyResolution(nodes, name, p); err != nil {
-		t.Fatal(err)
-	}
-
-	// Now wait a second, the records will be invalid and we should fail to resolve
-	time.Sleep(time.Second)
 	if err := verifyResolutionFails(nodes, name); err != nil {
 		t.Fatal(err)
 	}
 
-	// The republishers that are contained within the nodes have their timeout set
-	// to 12 hours. Instead of trying to tweak those, we're just going to pretend
-	// they dont exist and make our own.
-	repub := NewRepublisher(publisher.Routing, publisher.Repo.Datastore(), publisher.Peerstore)
-	repub.Interval = time.Second
+	// a republisher that would wait for an hour republishes soon when kicked
+	defer func(d time.Duration) { ReconnectRebroadcastDelay = d }(ReconnectRebroadcastDelay)
+	ReconnectRebroadcastDelay = 0
+
+	repub := NewRepublisher(publisher.Routing, publisher.Repo.Datastore(), publisher.Peerstore, nil)
+	repub.Interval = time.Hour
+	repub.InitialDelay = time.Hour
 	repub.RecordLifetime = time.Second * 5
 	repub.AddName(publisher.Identity)
 
 	proc := goprocess.Go(repub.Run)
 	defer proc.Close()
 
-	// now wait a couple seconds for it to fire
-	time.Sleep(time.Second * 2)
-
-	// we should be able to resolve them now
-	if err := verifyResolution(nodes, name, p); err != nil {
+	repub.Kick()
+	if err := waitForResolution(nodes, name, p, time.Second*5); err != nil {
 		t.Fatal(err)
 	}
 }
 
+// makeNetwork returns ten connected nodes, bootstrapped off the first.
+func makeNetwork(ctx context.Context, t *testing.T) []*core.IpfsNode {
+	mn := mocknet.New(ctx)
+
+	var nodes []*core.IpfsNode
+	for i := 0; i < 10; i++ {
+		nd, err := core.NewNode(ctx, &core.BuildCfg{
+			Online: true,
+			Host:   mock.MockHostOption(mn),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nodes = append(nodes, nd)
+	}
+
+	mn.LinkAll()
+
+	bsinf := core.BootstrapConfigWithPeers(
+		[]peer.PeerInfo{
+			nodes[0].Peerstore.PeerInfo(nodes[0].Identity),
+		},
+	)
+
+	for _, n := range nodes[1:] {
+		if err := n.Bootstrap(bsinf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nodes
+}
+
 func verifyResolution(nodes []*core.IpfsNode, key string, exp path.Path) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -107,6 +171,19 @@ func verifyResolution(nodes []*core.IpfsNode, key string, exp path.Path) error {
 	return nil
 }
 
+// waitForResolution retries verifyResolution until it succeeds or timeout
+// passes.
+func waitForResolution(nodes []*core.IpfsNode, key string, exp path.Path, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := verifyResolution(nodes, key, exp)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
 func verifyResolutionFails(nodes []*core.IpfsNode, key string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -5,12 +5,19 @@ import (
 	"testing"
 	"time"
 
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/gogo/protobuf/proto"
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	pb "github.com/ipfs/go-ipfs/namesys/pb"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	path "github.com/ipfs/go-ipfs/path"
+	routing "github.com/ipfs/go-ipfs/routing"
+	kb "github.com/ipfs/go-ipfs/routing/kbucket"
 	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
 	u "github.com/ipfs/go-ipfs/util"
 	testutil "github.com/ipfs/go-ipfs/util/testutil"
 )
@@ -19,7 +26,7 @@ func TestRoutingResolve(t *testing.T) {
 	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
 	dstore := ds.NewMapDatastore()
 
-	resolver := NewRoutingResolver(d)
+	resolver := NewRoutingResolver(d, 0)
 	publisher := NewRoutingPublisher(d, dstore)
 
 	privk, pubk, err := testutil.RandTestKeyPair(512)
@@ -53,7 +60,7 @@ func TestPrexistingExpiredRecord(t *testing.T) {
 	dstore := ds.NewMapDatastore()
 	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
 
-	resolver := NewRoutingResolver(d)
+	resolver := NewRoutingResolver(d, 0)
 	publisher := NewRoutingPublisher(d, dstore)
 
 	privk, pubk, err := testutil.RandTestKeyPair(512)
@@ -69,7 +76,7 @@ func TestPrexistingExpiredRecord(t *testing.T) {
 	// Make an expired record and put it in the datastore
 	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
 	eol := time.Now().Add(time.Hour * -1)
-	err = PutRecordToRouting(context.Background(), privk, h, 0, eol, d, id)
+	err = PutRecordToRouting(context.Background(), privk, h, 0, eol, 0, d, id)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,7 +97,7 @@ func TestPrexistingRecord(t *testing.T) {
 	dstore := ds.NewMapDatastore()
 	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
 
-	resolver := NewRoutingResolver(d)
+	resolver := NewRoutingResolver(d, 0)
 	publisher := NewRoutingPublisher(d, dstore)
 
 	privk, pubk, err := testutil.RandTestKeyPair(512)
@@ -106,7 +113,7 @@ func TestPrexistingRecord(t *testing.T) {
 	// Make a good record and put it in the datastore
 	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
 	eol := time.Now().Add(time.Hour)
-	err = PutRecordToRouting(context.Background(), privk, h, 0, eol, d, id)
+	err = PutRecordToRouting(context.Background(), privk, h, 0, eol, 0, d, id)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -135,3 +142,216 @@ func verifyCanResolve(r Resolver, name string, exp path.Path) error {
 
 	return nil
 }
+
+func TestPublishWithTTL(t *testing.T) {
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	publisher := NewRoutingPublisher(d, ds.NewMapDatastore())
+
+	privk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(privk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	eol := time.Now().Add(time.Hour * 72)
+	err = publisher.PublishWithTTL(context.Background(), privk, h, eol, time.Minute*5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ipnskey := IpnsKeysForID(id)
+	val, err := d.GetValue(context.Background(), ipnskey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, e); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(e.GetTtl()) != time.Minute*5 {
+		t.Fatalf("expected a ttl of 5m, got %s", time.Duration(e.GetTtl()))
+	}
+	if string(e.GetValidity()) != u.FormatRFC3339(eol) {
+		t.Fatalf("expected the record to be valid until %s, got %s", u.FormatRFC3339(eol), e.GetValidity())
+	}
+}
+
+func TestPublishWithKey(t *testing.T) {
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	ks := keystore.NewMemKeystore()
+	ns := NewNameSystem(d, ds.NewMapDatastore(), ks, Opts{})
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := ns.PublishWithKey(context.Background(), "other", h); err == nil {
+		t.Fatal("published with a missing key")
+	}
+
+	sk, err := keystore.Create(ks, "other", ci.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.PublishWithKey(context.Background(), "other", h); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ns.Resolve(context.Background(), "/ipns/"+id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != h {
+		t.Fatalf("expected %s, got %s", h, res)
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	dstore := ds.NewMapDatastore()
+	ns := NewNameSystem(d, dstore, nil, Opts{CacheSize: 10})
+	publisher := NewRoutingPublisher(d, dstore)
+
+	privk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(privk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "/ipns/" + id.Pretty()
+
+	h1 := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	h2 := path.FromString("/ipfs/QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	eol := time.Now().Add(time.Hour)
+	if err := publisher.PublishWithTTL(context.Background(), privk, h1, eol, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := ns.Resolve(context.Background(), name); err != nil || res != h1 {
+		t.Fatalf("expected %s, got %s, %v", h1, res, err)
+	}
+
+	// publishing elsewhere doesn't reach the cache until the ttl is over
+	if err := publisher.PublishWithTTL(context.Background(), privk, h2, eol, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := ns.Resolve(context.Background(), name); err != nil || res != h1 {
+		t.Fatalf("expected the cached %s, got %s, %v", h1, res, err)
+	}
+	if res, err := ns.Resolve(WithoutCache(context.Background()), name); err != nil || res != h2 {
+		t.Fatalf("expected %s without the cache, got %s, %v", h2, res, err)
+	}
+
+	// publishing through the name system updates the cache at once
+	if err := ns.PublishWithTTL(context.Background(), privk, h1, eol, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := ns.Resolve(context.Background(), name); err != nil || res != h1 {
+		t.Fatalf("expected %s, got %s, %v", h1, res, err)
+	}
+	if err := publisher.PublishWithTTL(context.Background(), privk, h2, eol, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if res, err := ns.Resolve(context.Background(), name); err != nil || res != h2 {
+		t.Fatalf("expected %s once the ttl is over, got %s, %v", h2, res, err)
+	}
+}
+
+func TestGetRecord(t *testing.T) {
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	publisher := NewRoutingPublisher(d, ds.NewMapDatastore())
+
+	privk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(privk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetRecord(context.Background(), d, "not a name"); err == nil {
+		t.Fatal("got a record for an invalid name")
+	}
+
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	eol := time.Now().Add(time.Millisecond * 100)
+	for i := 0; i < 2; i++ {
+		err = publisher.PublishWithTTL(context.Background(), privk, h, eol, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec, err := GetRecord(context.Background(), d, "/ipns/"+id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != id || rec.Value != h || rec.Sequence != 2 || rec.TTL != time.Minute {
+		t.Fatalf("wrong record: %+v", rec)
+	}
+	if rec.EOL.Sub(eol) > time.Millisecond || eol.Sub(rec.EOL) > time.Millisecond {
+		t.Fatalf("expected the record to be valid until %s, got %s", eol, rec.EOL)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	rec, err = GetRecord(context.Background(), d, id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Expired() {
+		t.Fatal("the record should have expired")
+	}
+}
+
+// disconnectedRouting fails to put values as a DHT without peers does.
+type disconnectedRouting struct {
+	routing.IpfsRouting
+}
+
+func (disconnectedRouting) PutValue(context.Context, key.Key, []byte) error {
+	return kb.ErrLookupFailure
+}
+
+func TestPublishDisconnected(t *testing.T) {
+	dstore := ds.NewMapDatastore()
+	d := mockrouting.NewServer().ClientWithDatastore(context.Background(), testutil.RandIdentityOrFatal(t), dstore)
+	publisher := NewRoutingPublisher(disconnectedRouting{d}, dstore)
+
+	privk, _, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(privk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without peers, publishing fails unless told to publish offline
+	h := path.FromString("/ipfs/QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN")
+	if err := publisher.Publish(context.Background(), privk, h); err != kb.ErrLookupFailure {
+		t.Fatalf("expected %s, got %v", kb.ErrLookupFailure, err)
+	}
+
+	publisher.offline = true
+	if err := publisher.Publish(context.Background(), privk, h); err != nil {
+		t.Fatal(err)
+	}
+
+	// the record is in the local datastore
+	local := offroute.NewOfflineRouter(dstore, privk)
+	rec, err := GetRecord(context.Background(), local, id.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Value != h {
+		t.Fatalf("expected %s, got %s", h, rec.Value)
+	}
+}
@@ -1,14 +1,8 @@
 package namesys
 
 import (
-	"fmt"
-
-	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/gogo/protobuf/proto"
-	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
-	key "github.com/ipfs/go-ipfs/blocks/key"
-	pb "github.com/ipfs/go-ipfs/namesys/pb"
 	path "github.com/ipfs/go-ipfs/path"
 	routing "github.com/ipfs/go-ipfs/routing"
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
@@ -19,25 +13,28 @@ var log = logging.Logger("namesys")
 // routingResolver implements NSResolver for the main IPFS SFS-like naming
 type routingResolver struct {
 	routing routing.IpfsRouting
+	cache   *resolveCache
 }
 
 // NewRoutingResolver constructs a name resolver using the IPFS Routing system
-// to implement SFS-like naming on top.
-func NewRoutingResolver(route routing.IpfsRouting) Resolver {
+// to implement SFS-like naming on top. It keeps the values of up to
+// cachesize names for as long as their records allow, zero disables the
+// cache.
+func NewRoutingResolver(route routing.IpfsRouting, cachesize int) Resolver {
 	if route == nil {
 		panic("attempt to create resolver with nil routing system")
 	}
 
-	return &routingResolver{routing: route}
+	return &routingResolver{routing: route, cache: newResolveCache(cachesize)}
 }
 
 // newRoutingResolver returns a resolver instead of a Resolver.
-func newRoutingResolver(route routing.IpfsRouting) resolver {
+func newRoutingResolver(route routing.IpfsRouting, cache *resolveCache) resolver {
 	if route == nil {
 		panic("attempt to create resolver with nil routing system")
 	}
 
-	return &routingResolver{routing: route}
+	return &routingResolver{routing: route, cache: cache}
 }
 
 // Resolve implements Resolver.
@@ -54,54 +51,18 @@ func (r *routingResolver) ResolveN(ctx context.Context, name string, depth int)
 // resolve SFS-like names.
 func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
 	log.Debugf("RoutingResolve: '%s'", name)
-	hash, err := mh.FromB58String(name)
-	if err != nil {
-		log.Warning("RoutingResolve: bad input hash: [%s]\n", name)
-		return "", err
-	}
-	// name should be a multihash. if it isn't, error out here.
-
-	// use the routing system to get the name.
-	// /ipns/<name>
-	h := []byte("/ipns/" + string(hash))
-
-	ipnsKey := key.Key(h)
-	val, err := r.routing.GetValue(ctx, ipnsKey)
-	if err != nil {
-		log.Warning("RoutingResolve get failed.")
-		return "", err
-	}
-
-	entry := new(pb.IpnsEntry)
-	err = proto.Unmarshal(val, entry)
-	if err != nil {
-		return "", err
+	if p, ok := r.cache.get(ctx, name); ok {
+		log.Debugf("RoutingResolve: '%s' found in the cache", name)
+		return p, nil
 	}
 
-	// name should be a public key retrievable from ipfs
-	pubkey, err := routing.GetPublicKey(r.routing, ctx, hash)
+	rec, err := GetRecord(ctx, r.routing, name)
 	if err != nil {
+		log.Warningf("RoutingResolve of %s failed: %s", name, err)
 		return "", err
 	}
 
-	hsh, _ := pubkey.Hash()
-	log.Debugf("pk hash = %s", key.Key(hsh))
-
-	// check sig with pk
-	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
-		return "", fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
-	}
-
 	// ok sig checks out. this is a valid name.
-
-	// check for old style record:
-	valh, err := mh.Cast(entry.GetValue())
-	if err != nil {
-		// Not a multihash, probably a new record
-		return path.ParsePath(string(entry.GetValue()))
-	} else {
-		// Its an old style multihash record
-		log.Warning("Detected old style multihash record")
-		return path.FromKey(key.Key(valh)), nil
-	}
+	r.cache.put(name, rec.Value, rec.TTL, rec.EOL)
+	return rec.Value, nil
 }
@@ -0,0 +1,95 @@
+package namesys
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+// StaticResolver resolves names from a fixed table, so that names can be
+// resolved without the network, e.g. in air-gapped deployments.
+type StaticResolver struct {
+	names map[string]path.Path
+}
+
+// NewStaticResolver constructs a resolver for the names of the table, which
+// may be written with or without their /ipns/ prefix.
+func NewStaticResolver(names map[string]path.Path) *StaticResolver {
+	r := &StaticResolver{names: make(map[string]path.Path, len(names))}
+	for name, p := range names {
+		r.names[strings.TrimPrefix(name, "/ipns/")] = p
+	}
+	return r
+}
+
+// Resolve implements Resolver.
+func (r *StaticResolver) Resolve(ctx context.Context, name string) (path.Path, error) {
+	return r.ResolveN(ctx, name, DefaultDepthLimit)
+}
+
+// ResolveN implements Resolver.
+func (r *StaticResolver) ResolveN(ctx context.Context, name string, depth int) (path.Path, error) {
+	return resolve(ctx, r, name, depth, "/ipns/")
+}
+
+// resolveOnce implements resolver. Looks the name up in the table.
+func (r *StaticResolver) resolveOnce(ctx context.Context, name string) (path.Path, error) {
+	p, ok := r.names[name]
+	if !ok {
+		return "", ErrResolveFailed
+	}
+	return p, nil
+}
+
+// ReadHostsFile reads a table of names for NewStaticResolver. Every line
+// holds a name and the path it resolves to, separated by spaces; empty
+// lines and the text after a # are ignored:
+//
+//	# the site
+//	example.com   /ipfs/QmY3hE8xgFCjGcz6PHgnvJz5HZi1BaKRfPkn1ghZUcYMjD
+//	docs.example.com  /ipns/example.com/docs
+func ReadHostsFile(r io.Reader) (map[string]path.Path, error) {
+	names := make(map[string]path.Path)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a name and a path", n)
+		}
+		p, err := path.ParsePath(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		names[fields[0]] = p
+	}
+	return names, s.Err()
+}
+
+// LoadHostsFile reads the table of names in the file at fpath, see
+// ReadHostsFile.
+func LoadHostsFile(fpath string) (map[string]path.Path, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := ReadHostsFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fpath, err)
+	}
+	return names, nil
+}
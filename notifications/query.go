@@ -2,6 +2,8 @@ package notifications
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
@@ -18,20 +20,54 @@ const (
 	QueryError
 	Provider
 	Value
+	// QueryDone ends a query, with the closest peers that answered it as
+	// Responses and how long the query ran as Duration.
+	QueryDone
 )
 
+var queryEventTypeNames = map[QueryEventType]string{
+	SendingQuery: "SendingQuery",
+	PeerResponse: "PeerResponse",
+	FinalPeer:    "FinalPeer",
+	QueryError:   "QueryError",
+	Provider:     "Provider",
+	Value:        "Value",
+	QueryDone:    "QueryDone",
+}
+
+func (t QueryEventType) String() string {
+	if s, ok := queryEventTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("QueryEventType(%d)", int(t))
+}
+
 type QueryEvent struct {
 	ID        peer.ID
 	Type      QueryEventType
 	Responses []*peer.PeerInfo
 	Extra     string
+
+	// Time is when the event happened.
+	Time time.Time
+	// Duration is how long the peer took to answer, for the PeerResponse
+	// and QueryError events of a peer, or how long the query ran, for
+	// QueryDone.
+	Duration time.Duration
 }
 
+// RegisterForQueryEvents returns a context under which the routing queries
+// report their progress on ch, for tracing them. The sends block, ch has
+// to be read until the query returns.
 func RegisterForQueryEvents(ctx context.Context, ch chan<- *QueryEvent) context.Context {
 	return context.WithValue(ctx, RoutingQueryKey, ch)
 }
 
 func PublishQueryEvent(ctx context.Context, ev *QueryEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
 	ich := ctx.Value(RoutingQueryKey)
 	if ich == nil {
 		return
@@ -54,6 +90,8 @@ func (qe *QueryEvent) MarshalJSON() ([]byte, error) {
 	out["Type"] = int(qe.Type)
 	out["Responses"] = qe.Responses
 	out["Extra"] = qe.Extra
+	out["Time"] = qe.Time
+	out["Duration"] = int64(qe.Duration)
 	return json.Marshal(out)
 }
 
@@ -63,6 +101,8 @@ func (qe *QueryEvent) UnmarshalJSON(b []byte) error {
 		Type      int
 		Responses []*peer.PeerInfo
 		Extra     string
+		Time      time.Time
+		Duration  int64
 	}{}
 	err := json.Unmarshal(b, &temp)
 	if err != nil {
@@ -78,5 +118,7 @@ func (qe *QueryEvent) UnmarshalJSON(b []byte) error {
 	qe.Type = QueryEventType(temp.Type)
 	qe.Responses = temp.Responses
 	qe.Extra = temp.Extra
+	qe.Time = temp.Time
+	qe.Duration = time.Duration(temp.Duration)
 	return nil
 }
@@ -0,0 +1,265 @@
+// package dialback lets a node find out whether it is reachable from the
+// internet, by asking the peers it is connected to to dial it back.
+package dialback
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	host "github.com/ipfs/go-ipfs/p2p/host"
+	inet "github.com/ipfs/go-ipfs/p2p/net"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	protocol "github.com/ipfs/go-ipfs/p2p/protocol"
+	identify "github.com/ipfs/go-ipfs/p2p/protocol/identify"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("dialback")
+
+const ID protocol.ID = "/ipfs/dialback"
+
+// DialTimeout bounds the dials made on behalf of a peer.
+var DialTimeout = 10 * time.Second
+
+// CheckPeers is the number of peers Reachable asks to dial back.
+var CheckPeers = 3
+
+// maxAddrs is the number of addresses a peer may ask to be dialed back on.
+const maxAddrs = 8
+
+// ErrNoAnswer is returned by Reachable when no peer answered the request.
+var ErrNoAnswer = errors.New("dialback: no peer answered")
+
+type request struct {
+	Addrs []string
+}
+
+type response struct {
+	Reachable []string
+}
+
+// Service answers the dialback requests of peers, and makes its own.
+type Service struct {
+	Host host.Host
+}
+
+func NewService(h host.Host) *Service {
+	s := &Service{Host: h}
+	h.SetStreamHandler(ID, s.handleRequest)
+	return s
+}
+
+// handleRequest dials the peer back on the addresses it sent, and answers
+// those that took the connection. Only the addresses at the IP address the
+// peer connected from are dialed, never a third party.
+func (s *Service) handleRequest(st inet.Stream) {
+	defer st.Close()
+
+	var req request
+	if err := json.NewDecoder(io.LimitReader(st, inet.MessageSizeMax)).Decode(&req); err != nil {
+		log.Debugf("dialback: reading request: %s", err)
+		return
+	}
+	if len(req.Addrs) > maxAddrs {
+		req.Addrs = req.Addrs[:maxAddrs]
+	}
+
+	from, err := addrIP(st.Conn().RemoteMultiaddr())
+	if err != nil {
+		log.Debugf("dialback: %s", err)
+		return
+	}
+
+	var res response
+	for _, addr := range req.Addrs {
+		a, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			continue
+		}
+		ip, err := addrIP(a)
+		if err != nil || !ip.Equal(from) {
+			continue
+		}
+		if dialable(a) {
+			res.Reachable = append(res.Reachable, addr)
+		}
+	}
+
+	if err := json.NewEncoder(st).Encode(&res); err != nil {
+		log.Debugf("dialback: writing response: %s", err)
+	}
+}
+
+// dialable returns whether a connection to a can be opened. Only tcp
+// dials tell whether someone listens, other addresses are not dialable.
+func dialable(a ma.Multiaddr) bool {
+	na, err := manet.ToNetAddr(a)
+	if err != nil {
+		return false
+	}
+	if _, ok := na.(*net.TCPAddr); !ok {
+		return false
+	}
+	c, err := net.DialTimeout("tcp", na.String(), DialTimeout)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+// Check asks the peer p to dial us back on addrs, and returns the
+// addresses it could connect to.
+func (s *Service) Check(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) ([]ma.Multiaddr, error) {
+	st, err := s.Host.NewStream(ID, p)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	// closing the stream unblocks the exchange when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			st.Close()
+		case <-done:
+		}
+	}()
+
+	var req request
+	for _, a := range addrs {
+		req.Addrs = append(req.Addrs, a.String())
+	}
+	if err := json.NewEncoder(st).Encode(&req); err != nil {
+		return nil, err
+	}
+
+	var res response
+	if err := json.NewDecoder(io.LimitReader(st, inet.MessageSizeMax)).Decode(&res); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	var reachable []ma.Multiaddr
+	for _, addr := range res.Reachable {
+		a, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		reachable = append(reachable, a)
+	}
+	return reachable, nil
+}
+
+// Reachable asks up to CheckPeers of the connected peers to dial us back
+// on our public addresses, and returns whether one of them could. A node
+// without public addresses is not reachable.
+func (s *Service) Reachable(ctx context.Context) (bool, error) {
+	var addrs []ma.Multiaddr
+	for _, a := range s.Host.Addrs() {
+		if IsPublicAddr(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return false, nil
+	}
+
+	peers := s.Host.Network().Peers()
+	answered := 0
+	for _, i := range rand.Perm(len(peers)) {
+		if answered >= CheckPeers {
+			break
+		}
+		p := peers[i]
+		if speaks, known := identify.Speaks(s.Host.Peerstore(), p, ID); known && !speaks {
+			continue
+		}
+
+		reachable, err := s.Check(ctx, p, addrs)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			log.Debugf("dialback: asking %s: %s", p, err)
+			continue
+		}
+		answered++
+		if len(reachable) > 0 {
+			log.Debugf("dialback: %s reached us at %s", p, reachable)
+			return true, nil
+		}
+	}
+	if answered == 0 {
+		return false, ErrNoAnswer
+	}
+	return false, nil
+}
+
+// privateNets are the ranges of addresses not routed on the internet.
+var privateNets = parseNets(
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+func parseNets(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// IsPublicAddr returns whether a is an address peers on the internet could
+// dial, as opposed to a loopback, link-local or private one.
+func IsPublicAddr(a ma.Multiaddr) bool {
+	ip, err := addrIP(a)
+	if err != nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// addrIP returns the IP address of the thin waist address a.
+func addrIP(a ma.Multiaddr) (net.IP, error) {
+	na, err := manet.ToNetAddr(a)
+	if err != nil {
+		return nil, err
+	}
+	switch na := na.(type) {
+	case *net.TCPAddr:
+		return na.IP, nil
+	case *net.UDPAddr:
+		return na.IP, nil
+	case *net.IPAddr:
+		return na.IP, nil
+	}
+	return nil, errors.New("dialback: no IP address in " + a.String())
+}
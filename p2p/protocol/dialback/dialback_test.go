@@ -0,0 +1,90 @@
+package dialback
+
+import (
+	"net"
+	"testing"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	netutil "github.com/ipfs/go-ipfs/p2p/test/util"
+)
+
+func TestCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h1 := netutil.GenHostSwarm(t, ctx)
+	h2 := netutil.GenHostSwarm(t, ctx)
+
+	err := h1.Connect(ctx, peer.PeerInfo{
+		ID:    h2.ID(),
+		Addrs: h2.Addrs(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1 := NewService(h1)
+	NewService(h2)
+
+	// a port nobody listens on anymore
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := manet.FromNetAddr(l.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	// a third party, never dialed
+	other, err := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/4001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs := append([]ma.Multiaddr{closed, other}, h1.Addrs()...)
+	reachable, err := s1.Check(ctx, h2.ID(), addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reachable) != len(h1.Addrs()) {
+		t.Fatalf("expected %s to be reachable, got %s", h1.Addrs(), reachable)
+	}
+	for i, a := range reachable {
+		if !a.Equal(h1.Addrs()[i]) {
+			t.Fatalf("expected %s to be reachable, got %s", h1.Addrs(), reachable)
+		}
+	}
+
+	// loopback addresses are not public, so not reachable from the internet
+	ok, err := s1.Reachable(ctx)
+	if err != nil || ok {
+		t.Fatalf("expected a node on loopback addresses to be unreachable, got %t, %v", ok, err)
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for s, public := range map[string]bool{
+		"/ip4/8.8.8.8/tcp/4001":     true,
+		"/ip6/2001:db8::1/tcp/4001": true,
+		"/ip4/127.0.0.1/tcp/4001":   false,
+		"/ip4/10.1.2.3/tcp/4001":    false,
+		"/ip4/172.20.0.1/tcp/4001":  false,
+		"/ip4/192.168.1.2/tcp/4001": false,
+		"/ip4/100.64.0.1/tcp/4001":  false,
+		"/ip6/::1/tcp/4001":         false,
+		"/ip6/fe80::1/tcp/4001":     false,
+		"/ip6/fd00::1/tcp/4001":     false,
+	} {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if IsPublicAddr(a) != public {
+			t.Errorf("IsPublicAddr(%s) should be %t", s, public)
+		}
+	}
+}
@@ -30,6 +30,28 @@ const ID protocol.ID = "/ipfs/identify"
 const IpfsVersion = "ipfs/0.1.0"
 const ClientVersion = "go-ipfs/" + config.CurrentVersionNumber
 
+// protocolsKey is the peerstore key of the protocols a peer handles.
+const protocolsKey = "Protocols"
+
+// Speaks returns whether the peer p handles the protocol proto, as it last
+// told us, and whether we know it: peers not identified yet are unknown.
+func Speaks(ps peer.Peerstore, p peer.ID, proto protocol.ID) (speaks, known bool) {
+	v, err := ps.Get(p, protocolsKey)
+	if err != nil {
+		return false, false
+	}
+	protos, ok := v.([]string)
+	if !ok {
+		return false, false
+	}
+	for _, pr := range protos {
+		if pr == string(proto) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
 // IDService is a structure that implements ProtocolIdentify.
 // It is a trivial service that gives the other peer some
 // useful information about the local peer. A sort of hello.
@@ -38,6 +60,7 @@ const ClientVersion = "go-ipfs/" + config.CurrentVersionNumber
 //  * Our IPFS Protocol Version
 //  * Our IPFS Agent Version
 //  * Our public Listen Addresses
+//  * The protocols we handle
 type IDService struct {
 	Host host.Host
 
@@ -173,6 +196,9 @@ func (ids *IDService) consumeMessage(mes *pb.Identify, c inet.Conn) {
 	p := c.RemotePeer()
 
 	// mes.Protocols
+	if protos := mes.GetProtocols(); len(protos) > 0 {
+		ids.Host.Peerstore().Put(p, protocolsKey, protos)
+	}
 
 	// mes.ObservedAddr
 	ids.consumeObservedAddress(mes.GetObservedAddr(), c)
@@ -40,6 +40,7 @@ func subtestIDService(t *testing.T, postDialWait time.Duration) {
 	// what we should see now is that both peers know about each others listen addresses.
 	testKnowsAddrs(t, h1, h2p, h2.Peerstore().Addrs(h2p)) // has them
 	testHasProtocolVersions(t, h1, h2p)
+	testSpeaks(t, h1, h2p)
 
 	// now, this wait we do have to do. it's the wait for the Listening side
 	// to be done identifying the connection.
@@ -52,6 +53,16 @@ func subtestIDService(t *testing.T, postDialWait time.Duration) {
 	// and the protocol versions.
 	testKnowsAddrs(t, h2, h1p, h1.Peerstore().Addrs(h1p)) // has them
 	testHasProtocolVersions(t, h2, h1p)
+	testSpeaks(t, h2, h1p)
+}
+
+func testSpeaks(t *testing.T, h host.Host, p peer.ID) {
+	if speaks, known := identify.Speaks(h.Peerstore(), p, identify.ID); !speaks || !known {
+		t.Errorf("%s should know %s handles %s", h.ID(), p, identify.ID)
+	}
+	if speaks, known := identify.Speaks(h.Peerstore(), p, "/nonexistent"); speaks || !known {
+		t.Errorf("%s should know %s doesn't handle /nonexistent", h.ID(), p)
+	}
 }
 
 func testKnowsAddrs(t *testing.T, h host.Host, p peer.ID, expected []ma.Multiaddr) {
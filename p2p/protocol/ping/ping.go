@@ -58,6 +58,7 @@ func (ps *PingService) Ping(ctx context.Context, p peer.ID) (<-chan time.Duratio
 	out := make(chan time.Duration)
 	go func() {
 		defer close(out)
+		defer s.Close()
 		for {
 			select {
 			case <-ctx.Done():
@@ -69,6 +70,9 @@ func (ps *PingService) Ping(ctx context.Context, p peer.ID) (<-chan time.Duratio
 					return
 				}
 
+				// keep the latency for routing to pick its closest peers
+				ps.Host.Peerstore().RecordLatency(p, t)
+
 				select {
 				case out <- t:
 				case <-ctx.Done():
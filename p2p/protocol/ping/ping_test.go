@@ -48,4 +48,8 @@ func testPing(t *testing.T, ps *PingService, p peer.ID) {
 		}
 	}
 
+	if ps.Host.Peerstore().LatencyEWMA(p) == 0 {
+		t.Fatal("latency was not recorded in the peerstore")
+	}
+
 }
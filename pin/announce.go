@@ -0,0 +1,48 @@
+package pin
+
+import (
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// Announcer is told of the blocks that became pinned, once the pins are
+// flushed, so that they can be announced to the network. Announcing blocks
+// once they are pinned, rather than as they are added or fetched, lets a
+// node announce just the dags it keeps.
+type Announcer interface {
+	// AnnouncePinned is called with the roots of the new direct, recursive
+	// and best effort pins, and the blocks that became pinned below them.
+	AnnouncePinned(roots, below []key.Key)
+}
+
+// SetAnnouncer sets the Announcer told of the pins flushed from now on.
+func (p *pinner) SetAnnouncer(a Announcer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.announcer = a
+	p.newRoots = nil
+	p.newBelow = nil
+}
+
+// announceRoot records that k became the root of a pin, to be announced
+// once flushed. must be called with the lock held.
+func (p *pinner) announceRoot(k key.Key) {
+	if p.announcer != nil {
+		p.newRoots = append(p.newRoots, k)
+	}
+}
+
+// announceBelow records that the given blocks became pinned below a root,
+// to be announced once flushed. must be called with the lock held.
+func (p *pinner) announceBelow(ks ...key.Key) {
+	if p.announcer != nil {
+		p.newBelow = append(p.newBelow, ks...)
+	}
+}
+
+// takeAnnouncements returns the announcer and the blocks it is to be told
+// about, clearing them. must be called with the lock held.
+func (p *pinner) takeAnnouncements() (a Announcer, roots, below []key.Key) {
+	a, roots, below = p.announcer, p.newRoots, p.newBelow
+	p.newRoots, p.newBelow = nil, nil
+	return a, roots, below
+}
@@ -0,0 +1,84 @@
+package pin
+
+import (
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// PinWithExpiry pins the given node, optionally recursive, until the given
+// time, after which the pin is removed by SweepExpired. Any other metadata
+// already recorded for the pin is kept.
+func (p *pinner) PinWithExpiry(ctx context.Context, node *mdag.Node, recurse bool, expires time.Time) error {
+	k, err := node.Key()
+	if err != nil {
+		return err
+	}
+
+	info := new(PinInfo)
+	if old, ok := p.GetPinInfo(k); ok {
+		*info = *old
+	}
+	info.Expires = &expires
+
+	return p.PinWithInfo(ctx, node, recurse, info)
+}
+
+// SweepExpired removes all pins whose expiry time has passed, returning the
+// keys that were unpinned. Pins that fail to be removed are logged and
+// skipped, their errors are returned together once the others are removed.
+func (p *pinner) SweepExpired(ctx context.Context) ([]key.Key, error) {
+	now := time.Now()
+
+	p.lock.RLock()
+	var expired []key.Key
+	for k, info := range p.pinInfo {
+		if info.Expired(now) {
+			expired = append(expired, k)
+		}
+	}
+	p.lock.RUnlock()
+
+	var removed []key.Key
+	var merr u.MultiErr
+	for _, k := range expired {
+		if err := p.Unpin(ctx, k, true); err != nil {
+			log.Errorf("failed to remove expired pin %s: %s", k, err)
+			merr = append(merr, err)
+			continue
+		}
+		removed = append(removed, k)
+	}
+
+	if len(removed) > 0 {
+		if err := p.Flush(); err != nil {
+			merr = append(merr, err)
+		}
+	}
+	if len(merr) > 0 {
+		return removed, merr
+	}
+	return removed, nil
+}
+
+// SweepExpiredEvery removes expired pins from the given pinner every tick,
+// until the context is cancelled.
+func SweepExpiredEvery(ctx context.Context, p Pinner, tick time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tick):
+			removed, err := p.SweepExpired(ctx)
+			if err != nil {
+				log.Errorf("failed to remove expired pins: %s", err)
+			}
+			for _, k := range removed {
+				log.Debugf("removed expired pin %s", k)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package pin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+// Pin sets are exported as plain text, one pin per line:
+//
+//	<key> <mode> [name]
+//
+// where mode is one of "recursive", "direct" or "besteffort", and the optional name runs
+// to the end of the line. Blank lines and lines starting with '#' are
+// ignored on import.
+const (
+	exportRecursive  = "recursive"
+	exportDirect     = "direct"
+	exportBestEffort = "besteffort"
+)
+
+// Export writes the direct, recursive and best effort pins, along with their names, to w.
+// Indirect pins are not exported, as they follow from the recursive ones.
+func (p *pinner) Export(w io.Writer) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	write := func(keys []key.Key, mode string) error {
+		for _, k := range keys {
+			line := k.B58String() + " " + mode
+			if info, ok := p.pinInfo[k]; ok && info.Name != "" {
+				line += " " + info.Name
+			}
+			if _, err := fmt.Fprintln(bw, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := write(p.recursePin.GetKeys(), exportRecursive); err != nil {
+		return err
+	}
+	if err := write(p.directPin.GetKeys(), exportDirect); err != nil {
+		return err
+	}
+	if err := write(p.bestEffort.GetKeys(), exportBestEffort); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import reads pins in the format written by Export and adds them to the
+// pinner. The pinned dags are not fetched or walked; blocks missing from the
+// local repo can be found with Verify. Nothing is pinned if any line of the
+// input is malformed.
+func (p *pinner) Import(r io.Reader) error {
+	type importedPin struct {
+		k    key.Key
+		mode string
+		name string
+	}
+
+	var pins []importedPin
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 2 {
+			return fmt.Errorf("line %d: expected '<key> <mode> [name]'", n)
+		}
+
+		h, err := mh.FromB58String(parts[0])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid key '%s': %s", n, parts[0], err)
+		}
+		k := key.Key(h)
+
+		mode := parts[1]
+		switch mode {
+		case exportRecursive, exportDirect, exportBestEffort:
+		default:
+			return fmt.Errorf("line %d: invalid pin mode '%s'", n, mode)
+		}
+
+		ip := importedPin{k: k, mode: mode}
+		if len(parts) == 3 {
+			ip.name = strings.TrimSpace(parts[2])
+		}
+		pins = append(pins, ip)
+	}
+	if err := scan.Err(); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, ip := range pins {
+		switch ip.mode {
+		case exportRecursive:
+			if p.directPin.HasKey(ip.k) {
+				p.removeDirect(ip.k)
+			}
+			if !p.recursePin.HasKey(ip.k) {
+				p.addRecursive(ip.k)
+			}
+		case exportDirect:
+			if p.recursePin.HasKey(ip.k) {
+				continue
+			}
+			p.addDirect(ip.k)
+		case exportBestEffort:
+			p.addBestEffort(ip.k)
+		}
+
+		if ip.name == "" {
+			continue
+		}
+		info := new(PinInfo)
+		if old, ok := p.pinInfo[ip.k]; ok {
+			*info = *old
+		}
+		info.Name = ip.name
+		p.setPinInfo(ip.k, info)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package pin
+
+import (
+	"fmt"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+)
+
+// Indirect pins are not tracked as reference counts that are adjusted on
+// every pin and unpin, as those can drift out of sync with the recursive
+// pins they derive from. Instead, the set of blocks reachable from the
+// recursive pins is kept in an index that is recomputed from the roots
+// whenever it may be out of date.
+//
+// The index is tagged with the generation of the recursive pin set it was
+// computed for. Adding a recursive pin extends the index with the refs that
+// were walked to pin it, and removing one subtracts the refs of its dag, so
+// the index is normally kept current without walking the other roots. Only
+// changes made without walking the dag, through the ManualPinner or when the
+// dag of an unpinned root is incomplete, leave the index a stale superset of
+// the truth. Queries rebuild a stale index before answering, so they are
+// always consistent with the current roots.
+type pinIndex struct {
+	gen  uint64
+	refs map[key.Key]int
+}
+
+func newPinIndex(gen uint64) *pinIndex {
+	return &pinIndex{gen: gen, refs: make(map[key.Key]int)}
+}
+
+func (i *pinIndex) has(k key.Key) bool {
+	_, ok := i.refs[k]
+	return ok
+}
+
+// indexCurrent returns whether the index reflects the current set of
+// recursive pins. must be called with the lock held.
+func (p *pinner) indexCurrent() bool {
+	return p.index != nil && p.index.gen == p.rootsGen
+}
+
+// updateIndex applies refs, multiplied by sign, to the index after the
+// recursive pins have changed. If the index was current before the change
+// the result is exact and the index stays current. Otherwise only additions
+// are applied, keeping the stale index a superset until it is rebuilt.
+// must be called with the lock held.
+func (p *pinner) updateIndex(refs map[key.Key]int, sign int, wasCurrent bool) {
+	if p.index == nil {
+		return
+	}
+
+	for k, c := range refs {
+		c *= sign
+		if c < 0 && !wasCurrent {
+			continue
+		}
+
+		n := p.index.refs[k] + c
+		if n > 0 {
+			p.index.refs[k] = n
+		} else {
+			delete(p.index.refs, k)
+		}
+	}
+
+	if wasCurrent {
+		p.index.gen = p.rootsGen
+	}
+}
+
+// ensureIndex rebuilds the index from the recursive pins if it is stale.
+// The dags are walked without holding the pinner lock; if the recursive
+// pins change during the walk, the walk is started over. Recursively pinned
+// dags are stored locally, so blocks are never fetched from the network.
+func (p *pinner) ensureIndex(ctx context.Context) error {
+	p.lock.RLock()
+	current := p.indexCurrent()
+	p.lock.RUnlock()
+	if current {
+		return nil
+	}
+
+	ctx = bserv.LocalOnly(ctx)
+	p.rebuildLock.Lock()
+	defer p.rebuildLock.Unlock()
+
+	ses := p.dserv.Session(ctx)
+	defer ses.Close()
+
+	for {
+		p.lock.RLock()
+		if p.indexCurrent() {
+			p.lock.RUnlock()
+			return nil
+		}
+		gen := p.rootsGen
+		roots := p.recursePin.GetKeys()
+		p.lock.RUnlock()
+
+		index := newPinIndex(gen)
+		for _, k := range roots {
+			node, err := ses.Get(ctx, k)
+			if err != nil {
+				return err
+			}
+
+			if err := p.collectLinks(ctx, ses, node, index.refs); err != nil {
+				return err
+			}
+		}
+
+		p.lock.Lock()
+		if p.rootsGen == gen {
+			p.index = index
+			p.lock.Unlock()
+			return nil
+		}
+		p.lock.Unlock()
+		log.Debug("recursive pins changed while rebuilding index, retrying")
+	}
+}
+
+// rootRefs walks the locally stored dag of the recursive pin k, counting
+// how many times each descendant is referenced, see collectRefs.
+func (p *pinner) rootRefs(ctx context.Context, k key.Key) (map[key.Key]int, error) {
+	ctx = bserv.LocalOnly(ctx)
+	node, err := p.dserv.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return p.collectRefs(ctx, node)
+}
+
+// isIndirect returns whether k is reachable from a recursive pin, or pinned
+// indirectly by hand. It fails if the index can't be rebuilt, rather than
+// guessing.
+func (p *pinner) isIndirect(ctx context.Context, k key.Key) (bool, error) {
+	if err := p.ensureIndex(ctx); err != nil {
+		return false, fmt.Errorf("failed to rebuild indirect pin index: %s", err)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.indirPin.HasKey(k) || p.index.has(k), nil
+}
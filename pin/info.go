@@ -0,0 +1,67 @@
+package pin
+
+import (
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+var pinInfoDatastoreKey = ds.NewKey("/local/pins/info")
+
+// PinInfo holds optional user supplied metadata about a pin, so users can
+// remember why a key is pinned.
+type PinInfo struct {
+	Name   string            `json:",omitempty"`
+	Labels map[string]string `json:",omitempty"`
+
+	// Expires is the time after which the pin is removed automatically.
+	// Pins without one never expire.
+	Expires *time.Time `json:",omitempty"`
+}
+
+// Expired returns whether the pin has an expiry time that has passed.
+func (pi *PinInfo) Expired(now time.Time) bool {
+	return pi.Expires != nil && now.After(*pi.Expires)
+}
+
+// Matches returns whether the pin info has the given name, if any, and all
+// of the given labels.
+func (pi *PinInfo) Matches(name string, labels map[string]string) bool {
+	if name != "" && pi.Name != name {
+		return false
+	}
+	for k, v := range labels {
+		if pi.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func loadPinInfo(d ds.Datastore, k ds.Key) (map[key.Key]*PinInfo, error) {
+	var piStore map[string]*PinInfo
+	err := loadSet(d, k, &piStore)
+	switch err {
+	case nil:
+	case ds.ErrNotFound:
+		// repos created before pin metadata existed have none stored
+		return make(map[key.Key]*PinInfo), nil
+	default:
+		return nil, err
+	}
+
+	infos := make(map[key.Key]*PinInfo)
+	for encK, pi := range piStore {
+		infos[key.B58KeyDecode(encK)] = pi
+	}
+	return infos, nil
+}
+
+func storePinInfo(d ds.Datastore, k ds.Key, infos map[key.Key]*PinInfo) error {
+	piStore := make(map[string]*PinInfo)
+	for k, pi := range infos {
+		piStore[key.B58KeyEncode(k)] = pi
+	}
+	return storeSet(d, k, piStore)
+}
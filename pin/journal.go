@@ -0,0 +1,319 @@
+package pin
+
+import (
+	"encoding/json"
+	"strconv"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	"github.com/ipfs/go-ipfs/blocks/set"
+)
+
+// Rather than rewriting every pin set on each Flush, changes made since the
+// last flush are appended to a journal. Once the journal grows past
+// journalCompactThreshold records, a full snapshot of the pin sets is written
+// in the background and the journal records it covers are deleted.
+var pinJournalDatastoreKey = ds.NewKey("/local/pins/journal")
+var pinSnapshotSeqDatastoreKey = ds.NewKey("/local/pins/snapshotseq")
+
+// journalCompactThreshold is the number of journal records after which the
+// pin sets are compacted into a new snapshot.
+var journalCompactThreshold = uint64(128)
+
+const (
+	journalRecursive  = "recursive"
+	journalDirect     = "direct"
+	journalIndirect   = "indirect"
+	journalBestEffort = "besteffort"
+	journalInfo       = "info"
+)
+
+// journalEntry records the state of a single key after a change. Entries
+// hold absolute values rather than deltas, so replaying an entry that is
+// already reflected in the snapshot is harmless.
+type journalEntry struct {
+	Op    string
+	Key   string
+	Count int      `json:",omitempty"`
+	Info  *PinInfo `json:",omitempty"`
+}
+
+func journalRecordKey(seq uint64) ds.Key {
+	return pinJournalDatastoreKey.ChildString(strconv.FormatUint(seq, 10))
+}
+
+// record adds an entry describing the current state of k to the journal.
+// must be called with the lock held.
+func (p *pinner) record(op string, k key.Key) {
+	e := journalEntry{Op: op, Key: key.B58KeyEncode(k)}
+	switch op {
+	case journalRecursive:
+		if p.recursePin.HasKey(k) {
+			e.Count = 1
+		}
+	case journalDirect:
+		if p.directPin.HasKey(k) {
+			e.Count = 1
+		}
+	case journalIndirect:
+		e.Count = p.indirPin.GetRefs()[k]
+	case journalBestEffort:
+		if p.bestEffort.HasKey(k) {
+			e.Count = 1
+		}
+	case journalInfo:
+		e.Info = p.pinInfo[k]
+	}
+	p.pending = append(p.pending, e)
+}
+
+func (p *pinner) addRecursive(k key.Key) {
+	p.recursePin.AddBlock(k)
+	p.rootsGen++
+	p.record(journalRecursive, k)
+	p.announceRoot(k)
+}
+
+func (p *pinner) removeRecursive(k key.Key) {
+	p.recursePin.RemoveBlock(k)
+	p.rootsGen++
+	p.record(journalRecursive, k)
+}
+
+func (p *pinner) addDirect(k key.Key) {
+	p.directPin.AddBlock(k)
+	p.record(journalDirect, k)
+	p.announceRoot(k)
+}
+
+func (p *pinner) removeDirect(k key.Key) {
+	p.directPin.RemoveBlock(k)
+	p.record(journalDirect, k)
+}
+
+func (p *pinner) addBestEffort(k key.Key) {
+	p.bestEffort.AddBlock(k)
+	p.record(journalBestEffort, k)
+	p.announceRoot(k)
+}
+
+func (p *pinner) removeBestEffort(k key.Key) {
+	p.bestEffort.RemoveBlock(k)
+	p.record(journalBestEffort, k)
+}
+
+func (p *pinner) incrementIndirect(k key.Key) {
+	p.indirPin.Increment(k)
+	p.record(journalIndirect, k)
+	p.announceBelow(k)
+}
+
+func (p *pinner) decrementIndirect(k key.Key) {
+	p.indirPin.Decrement(k)
+	p.record(journalIndirect, k)
+}
+
+func (p *pinner) removeIndirect(k key.Key) {
+	p.indirPin.blockset.RemoveBlock(k)
+	delete(p.indirPin.refCounts, k)
+	p.record(journalIndirect, k)
+}
+
+func (p *pinner) setPinInfo(k key.Key, info *PinInfo) {
+	p.pinInfo[k] = info
+	p.record(journalInfo, k)
+}
+
+func (p *pinner) removePinInfo(k key.Key) {
+	if _, ok := p.pinInfo[k]; !ok {
+		return
+	}
+	delete(p.pinInfo, k)
+	p.record(journalInfo, k)
+}
+
+// apply replays a journal entry onto the in memory pin sets.
+func (p *pinner) apply(e journalEntry) {
+	k := key.B58KeyDecode(e.Key)
+	switch e.Op {
+	case journalRecursive:
+		applyToSet(p.recursePin, k, e.Count)
+	case journalDirect:
+		applyToSet(p.directPin, k, e.Count)
+	case journalBestEffort:
+		applyToSet(p.bestEffort, k, e.Count)
+	case journalIndirect:
+		if e.Count > 0 {
+			if !p.indirPin.HasKey(k) {
+				p.indirPin.blockset.AddBlock(k)
+			}
+			p.indirPin.refCounts[k] = e.Count
+		} else {
+			p.indirPin.blockset.RemoveBlock(k)
+			delete(p.indirPin.refCounts, k)
+		}
+	case journalInfo:
+		if e.Info != nil {
+			p.pinInfo[k] = e.Info
+		} else {
+			delete(p.pinInfo, k)
+		}
+	default:
+		log.Warningf("unknown pin journal entry: %s", e.Op)
+	}
+}
+
+func applyToSet(s set.BlockSet, k key.Key, count int) {
+	if count > 0 {
+		s.AddBlock(k)
+	} else {
+		s.RemoveBlock(k)
+	}
+}
+
+// loadJournal replays all journal records written after the last snapshot.
+func (p *pinner) loadJournal(d ds.Datastore) error {
+	var base uint64
+	err := loadSet(d, pinSnapshotSeqDatastoreKey, &base)
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+
+	seq := base
+	for {
+		var entries []journalEntry
+		err := loadSet(d, journalRecordKey(seq+1), &entries)
+		if err == ds.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			p.apply(e)
+		}
+		seq++
+	}
+
+	p.snapshotSeq = base
+	p.journalSeq = seq
+	return nil
+}
+
+// writeJournal appends all pending entries to the journal as a new record.
+// must be called with the lock held.
+func (p *pinner) writeJournal() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	buf, err := json.Marshal(p.pending)
+	if err != nil {
+		return err
+	}
+
+	seq := p.journalSeq + 1
+	if err := p.dstore.Put(journalRecordKey(seq), buf); err != nil {
+		return err
+	}
+
+	p.journalSeq = seq
+	p.pending = nil
+	return nil
+}
+
+// pinSnapshot is a consistent copy of the pin sets, taken under the lock so
+// it can be written out without holding it.
+type pinSnapshot struct {
+	seq        uint64
+	direct     []key.Key
+	recursive  []key.Key
+	bestEffort []key.Key
+	indirect   *indirectPin
+	info       map[key.Key]*PinInfo
+}
+
+// takeSnapshot must be called with the lock held.
+func (p *pinner) takeSnapshot() *pinSnapshot {
+	refs := make(map[key.Key]int)
+	for k, v := range p.indirPin.GetRefs() {
+		refs[k] = v
+	}
+
+	info := make(map[key.Key]*PinInfo)
+	for k, v := range p.pinInfo {
+		info[k] = v
+	}
+
+	return &pinSnapshot{
+		seq:        p.journalSeq,
+		direct:     p.directPin.GetKeys(),
+		recursive:  p.recursePin.GetKeys(),
+		bestEffort: p.bestEffort.GetKeys(),
+		indirect:   &indirectPin{refCounts: refs},
+		info:       info,
+	}
+}
+
+// writeSnapshot stores the snapshot, then drops the journal records it
+// covers. journal records are only removed once the snapshot sequence number
+// has been written, so a crash part way through loses nothing.
+func writeSnapshot(d ds.Datastore, s *pinSnapshot, prevSeq uint64) error {
+	err := storeSet(d, directPinDatastoreKey, s.direct)
+	if err != nil {
+		return err
+	}
+
+	err = storeSet(d, recursePinDatastoreKey, s.recursive)
+	if err != nil {
+		return err
+	}
+
+	err = storeSet(d, bestEffortPinDatastoreKey, s.bestEffort)
+	if err != nil {
+		return err
+	}
+
+	err = storeIndirPin(d, indirectPinDatastoreKey, s.indirect)
+	if err != nil {
+		return err
+	}
+
+	err = storePinInfo(d, pinInfoDatastoreKey, s.info)
+	if err != nil {
+		return err
+	}
+
+	err = storeSet(d, pinSnapshotSeqDatastoreKey, s.seq)
+	if err != nil {
+		return err
+	}
+
+	for seq := prevSeq + 1; seq <= s.seq; seq++ {
+		err := d.Delete(journalRecordKey(seq))
+		if err != nil && err != ds.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// compact writes a new snapshot of the pin sets in the background.
+func (p *pinner) compact() {
+	p.lock.Lock()
+	s := p.takeSnapshot()
+	prev := p.snapshotSeq
+	p.lock.Unlock()
+
+	err := writeSnapshot(p.dstore, s, prev)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.compacting = false
+	if err != nil {
+		log.Errorf("failed to compact pin journal: %s", err)
+		return
+	}
+	p.snapshotSeq = s.seq
+}
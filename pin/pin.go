@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	nsds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/namespace"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	"github.com/ipfs/go-ipfs/blocks/set"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
@@ -21,6 +24,7 @@ var log = logging.Logger("pin")
 var recursePinDatastoreKey = ds.NewKey("/local/pins/recursive/keys")
 var directPinDatastoreKey = ds.NewKey("/local/pins/direct/keys")
 var indirectPinDatastoreKey = ds.NewKey("/local/pins/indirect/keys")
+var bestEffortPinDatastoreKey = ds.NewKey("/local/pins/besteffort/keys")
 
 type PinMode int
 
@@ -28,18 +32,59 @@ const (
 	Recursive PinMode = iota
 	Direct
 	Indirect
+
+	// BestEffort pins keep whatever blocks of a dag happen to be stored
+	// locally, without requiring the whole dag to be present. Only the root
+	// is reported by IsPinned; the garbage collector protects the rest.
+	BestEffort
 	NotPinned
 )
 
 type Pinner interface {
-	IsPinned(key.Key) bool
+	// IsPinned returns whether the given key is pinned in any mode. It may
+	// need to walk the recursive pins to answer, and fails if they can't be.
+	IsPinned(context.Context, key.Key) (bool, error)
 	Pin(context.Context, *mdag.Node, bool) error
 	Unpin(context.Context, key.Key, bool) error
+
+	// PinWithInfo pins the given node like Pin, and records the given
+	// metadata alongside the pin.
+	PinWithInfo(context.Context, *mdag.Node, bool, *PinInfo) error
+
+	// PinWithExpiry pins the given node like Pin, until the given time.
+	PinWithExpiry(context.Context, *mdag.Node, bool, time.Time) error
+
+	// SweepExpired removes all pins whose expiry time has passed.
+	SweepExpired(context.Context) ([]key.Key, error)
+
+	// GetPinInfo returns the metadata recorded for a direct or recursive
+	// pin, if there is any.
+	GetPinInfo(key.Key) (*PinInfo, bool)
+
+	// Update replaces the recursive pin on the first key with a recursive
+	// pin on the second, only walking the parts of the dags that differ.
+	Update(context.Context, key.Key, key.Key) error
+
+	// Verify checks that all blocks referenced by recursive pins are
+	// present in the given blockstore, streaming a report per pin.
+	Verify(context.Context, blockstore.Blockstore, VerifyOptions) <-chan *PinStatus
+
+	// Export writes the direct, recursive and best effort pins to the given
+	// writer, in a format that Import can read back.
+	Export(io.Writer) error
+
+	// Import adds the pins written by Export to the pinner.
+	Import(io.Reader) error
+
 	Flush() error
+	// SetAnnouncer sets the Announcer told of the pins flushed from now on.
+	SetAnnouncer(Announcer)
+
 	GetManual() ManualPinner
 	DirectKeys() []key.Key
-	IndirectKeys() map[key.Key]int
+	IndirectKeys(context.Context) (map[key.Key]int, error)
 	RecursiveKeys() []key.Key
+	BestEffortKeys() []key.Key
 }
 
 // ManualPinner is for manually editing the pin structure
@@ -56,9 +101,31 @@ type pinner struct {
 	lock       sync.RWMutex
 	recursePin set.BlockSet
 	directPin  set.BlockSet
-	indirPin   *indirectPin
+	bestEffort set.BlockSet
+	pinInfo    map[key.Key]*PinInfo
 	dserv      mdag.DAGService
 	dstore     ds.ThreadSafeDatastore
+
+	// indirect pins made by hand through the ManualPinner, protecting blocks
+	// that are not (yet) reachable from a recursive pin.
+	indirPin *indirectPin
+
+	// blocks reachable from the recursive pins, see index.go
+	index       *pinIndex
+	rootsGen    uint64
+	rebuildLock sync.Mutex
+
+	// blocks pinned since the last flush, see announce.go
+	announcer Announcer
+	newRoots  []key.Key
+	newBelow  []key.Key
+
+	// journal state, see journal.go
+	pending     []journalEntry
+	journalSeq  uint64
+	snapshotSeq uint64
+	hasSnapshot bool
+	compacting  bool
 }
 
 // NewPinner creates a new pinner using the given datastore as a backend
@@ -71,11 +138,17 @@ func NewPinner(dstore ds.ThreadSafeDatastore, serv mdag.DAGService) Pinner {
 	dirds := nsds.Wrap(dstore, directPinDatastoreKey)
 	dirset := set.NewDBWrapperSet(dirds, set.NewSimpleBlockSet())
 
+	beds := nsds.Wrap(dstore, bestEffortPinDatastoreKey)
+	beset := set.NewDBWrapperSet(beds, set.NewSimpleBlockSet())
+
 	nsdstore := nsds.Wrap(dstore, indirectPinDatastoreKey)
 	return &pinner{
 		recursePin: rcset,
 		directPin:  dirset,
+		bestEffort: beset,
 		indirPin:   NewIndirectPin(nsdstore),
+		index:      newPinIndex(0),
+		pinInfo:    make(map[key.Key]*PinInfo),
 		dserv:      serv,
 		dstore:     dstore,
 	}
@@ -83,108 +156,183 @@ func NewPinner(dstore ds.ThreadSafeDatastore, serv mdag.DAGService) Pinner {
 
 // Pin the given node, optionally recursive
 func (p *pinner) Pin(ctx context.Context, node *mdag.Node, recurse bool) error {
-	p.lock.Lock()
-	defer p.lock.Unlock()
+	return p.PinWithInfo(ctx, node, recurse, nil)
+}
+
+// PinWithInfo pins the given node, optionally recursive, and records the
+// given metadata for it. A nil info leaves existing metadata untouched.
+//
+// The dag is walked without holding the pinner lock, so a long recursive pin
+// does not block other pinning operations. The lock is only taken to apply
+// the results of the walk.
+func (p *pinner) PinWithInfo(ctx context.Context, node *mdag.Node, recurse bool, info *PinInfo) error {
 	k, err := node.Key()
 	if err != nil {
 		return err
 	}
 
 	if recurse {
-		if p.recursePin.HasKey(k) {
-			return nil
-		}
+		err = p.pinRecursive(ctx, node, k)
+	} else {
+		err = p.pinDirect(ctx, k)
+	}
+	if err != nil {
+		return err
+	}
 
-		if p.directPin.HasKey(k) {
-			p.directPin.RemoveBlock(k)
-		}
+	if info != nil {
+		p.lock.Lock()
+		p.setPinInfo(k, info)
+		p.lock.Unlock()
+	}
+	return nil
+}
 
-		err := p.pinLinks(ctx, node)
-		if err != nil {
-			return err
-		}
+func (p *pinner) pinRecursive(ctx context.Context, node *mdag.Node, k key.Key) error {
+	p.lock.RLock()
+	pinned := p.recursePin.HasKey(k)
+	p.lock.RUnlock()
+	if pinned {
+		return nil
+	}
 
-		p.recursePin.AddBlock(k)
-	} else {
-		if _, err := p.dserv.Get(ctx, k); err != nil {
-			return err
-		}
+	refs, err := p.collectRefs(ctx, node)
+	if err != nil {
+		return err
+	}
 
-		if p.recursePin.HasKey(k) {
-			return fmt.Errorf("%s already pinned recursively", k.B58String())
-		}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// the key may have been pinned while we were walking the dag
+	if p.recursePin.HasKey(k) {
+		return nil
+	}
 
-		p.directPin.AddBlock(k)
+	if p.directPin.HasKey(k) {
+		p.removeDirect(k)
+	}
+
+	current := p.indexCurrent()
+	p.addRecursive(k)
+	p.updateIndex(refs, 1, current)
+	for rk := range refs {
+		p.announceBelow(rk)
 	}
 	return nil
 }
 
-// Unpin a given key
-func (p *pinner) Unpin(ctx context.Context, k key.Key, recursive bool) error {
+func (p *pinner) pinDirect(ctx context.Context, k key.Key) error {
+	if _, err := p.dserv.Get(ctx, k); err != nil {
+		return err
+	}
+
 	p.lock.Lock()
 	defer p.lock.Unlock()
+
 	if p.recursePin.HasKey(k) {
-		if recursive {
-			p.recursePin.RemoveBlock(k)
-			node, err := p.dserv.Get(ctx, k)
-			if err != nil {
-				return err
-			}
-
-			return p.unpinLinks(ctx, node)
-		} else {
+		return fmt.Errorf("%s already pinned recursively", k.B58String())
+	}
+
+	p.addDirect(k)
+	return nil
+}
+
+// Unpin a given key
+func (p *pinner) Unpin(ctx context.Context, k key.Key, recursive bool) error {
+	p.lock.Lock()
+	switch {
+	case p.recursePin.HasKey(k):
+		p.lock.Unlock()
+		if !recursive {
 			return fmt.Errorf("%s is pinned recursively", k)
 		}
-	} else if p.directPin.HasKey(k) {
-		p.directPin.RemoveBlock(k)
+		return p.unpinRecursive(ctx, k)
+	case p.directPin.HasKey(k):
+		defer p.lock.Unlock()
+		p.removeDirect(k)
+		p.removePinInfo(k)
 		return nil
-	} else if p.indirPin.HasKey(k) {
+	case p.bestEffort.HasKey(k):
+		defer p.lock.Unlock()
+		p.removeBestEffort(k)
+		return nil
+	}
+	p.lock.Unlock()
+
+	indirect, err := p.isIndirect(ctx, k)
+	if err != nil {
+		return err
+	}
+	if indirect {
 		return fmt.Errorf("%s is pinned indirectly. indirect pins cannot be removed directly", k)
-	} else {
-		return fmt.Errorf("%s is not pinned", k)
 	}
+	return fmt.Errorf("%s is not pinned", k)
 }
 
-func (p *pinner) unpinLinks(ctx context.Context, node *mdag.Node) error {
-	for _, l := range node.Links {
-		node, err := l.GetNode(ctx, p.dserv)
-		if err != nil {
-			return err
+// unpinRecursive removes the recursive pin on k, subtracting the refs of its
+// dag from the index. The dag is walked without holding the lock. If it
+// can't be walked, the pin is removed anyway and the index is left stale, to
+// be rebuilt from the remaining roots when it is next needed.
+func (p *pinner) unpinRecursive(ctx context.Context, k key.Key) error {
+	refs, err := p.rootRefs(ctx, k)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		log.Warningf("unpinning %s without walking its dag: %s", k, err)
+	}
 
-		k, err := node.Key()
-		if err != nil {
-			return err
-		}
+	p.lock.Lock()
+	defer p.lock.Unlock()
 
-		p.indirPin.Decrement(k)
+	// the key may have been unpinned while we were walking the dag
+	if !p.recursePin.HasKey(k) {
+		return fmt.Errorf("%s is not pinned", k)
+	}
 
-		err = p.unpinLinks(ctx, node)
-		if err != nil {
-			return err
-		}
+	current := p.indexCurrent()
+	p.removeRecursive(k)
+	p.removePinInfo(k)
+	if err == nil {
+		p.updateIndex(refs, -1, current)
 	}
 	return nil
 }
 
-func (p *pinner) pinIndirectRecurse(ctx context.Context, node *mdag.Node) error {
-	k, err := node.Key()
-	if err != nil {
-		return err
-	}
+// collectRefs walks the dag below node, counting how many times each
+// descendant is referenced. It does not touch any pinner state, so it must
+// not be called with the lock held.
+func (p *pinner) collectRefs(ctx context.Context, node *mdag.Node) (map[key.Key]int, error) {
+	ses := p.dserv.Session(ctx)
+	defer ses.Close()
 
-	p.indirPin.Increment(k)
-	return p.pinLinks(ctx, node)
+	refs := make(map[key.Key]int)
+	if err := p.collectLinks(ctx, ses, node, refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
 }
 
-func (p *pinner) pinLinks(ctx context.Context, node *mdag.Node) error {
-	for _, ng := range p.dserv.GetDAG(ctx, node) {
+func (p *pinner) collectLinks(ctx context.Context, ses *mdag.Session, node *mdag.Node, refs map[key.Key]int) error {
+	pt := progressFromContext(ctx)
+	for _, ng := range ses.GetDAG(node) {
 		subnode, err := ng.Get(ctx)
 		if err != nil {
 			// TODO: Maybe just log and continue?
 			return err
 		}
-		err = p.pinIndirectRecurse(ctx, subnode)
+		if pt != nil {
+			pt.increment()
+		}
+
+		k, err := subnode.Key()
+		if err != nil {
+			return err
+		}
+		refs[k]++
+
+		err = p.collectLinks(ctx, ses, subnode, refs)
 		if err != nil {
 			return err
 		}
@@ -193,12 +341,16 @@ func (p *pinner) pinLinks(ctx context.Context, node *mdag.Node) error {
 }
 
 // IsPinned returns whether or not the given key is pinned
-func (p *pinner) IsPinned(key key.Key) bool {
+func (p *pinner) IsPinned(ctx context.Context, key key.Key) (bool, error) {
 	p.lock.RLock()
-	defer p.lock.RUnlock()
-	return p.recursePin.HasKey(key) ||
+	pinned := p.recursePin.HasKey(key) ||
 		p.directPin.HasKey(key) ||
-		p.indirPin.HasKey(key)
+		p.bestEffort.HasKey(key)
+	p.lock.RUnlock()
+	if pinned {
+		return true, nil
+	}
+	return p.isIndirect(ctx, key)
 }
 
 func (p *pinner) RemovePinWithMode(key key.Key, mode PinMode) {
@@ -206,11 +358,15 @@ func (p *pinner) RemovePinWithMode(key key.Key, mode PinMode) {
 	defer p.lock.Unlock()
 	switch mode {
 	case Direct:
-		p.directPin.RemoveBlock(key)
+		p.removeDirect(key)
+		p.removePinInfo(key)
 	case Indirect:
-		p.indirPin.Decrement(key)
+		p.decrementIndirect(key)
+	case BestEffort:
+		p.removeBestEffort(key)
 	case Recursive:
-		p.recursePin.RemoveBlock(key)
+		p.removeRecursive(key)
+		p.removePinInfo(key)
 	default:
 		// programmer error, panic OK
 		panic("unrecognized pin type")
@@ -237,7 +393,16 @@ func LoadPinner(d ds.ThreadSafeDatastore, dserv mdag.DAGService) (Pinner, error)
 		p.directPin = set.SimpleSetFromKeys(directKeys)
 	}
 
-	{ // load indirect set
+	{ // load best effort set
+		var bestEffortKeys []key.Key
+		err := loadSet(d, bestEffortPinDatastoreKey, &bestEffortKeys)
+		if err != nil && err != ds.ErrNotFound {
+			return nil, err
+		}
+		p.bestEffort = set.SimpleSetFromKeys(bestEffortKeys)
+	}
+
+	{ // load indirect pins made through the ManualPinner
 		var err error
 		p.indirPin, err = loadIndirPin(d, indirectPinDatastoreKey)
 		if err != nil {
@@ -245,6 +410,20 @@ func LoadPinner(d ds.ThreadSafeDatastore, dserv mdag.DAGService) (Pinner, error)
 		}
 	}
 
+	{ // load pin metadata
+		var err error
+		p.pinInfo, err = loadPinInfo(d, pinInfoDatastoreKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// replay changes made since the sets were last written in full
+	if err := p.loadJournal(d); err != nil {
+		return nil, err
+	}
+	p.hasSnapshot = true
+
 	// assign services
 	p.dserv = dserv
 	p.dstore = d
@@ -254,38 +433,93 @@ func LoadPinner(d ds.ThreadSafeDatastore, dserv mdag.DAGService) (Pinner, error)
 
 // DirectKeys returns a slice containing the directly pinned keys
 func (p *pinner) DirectKeys() []key.Key {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.directPin.GetKeys()
 }
 
-// IndirectKeys returns a slice containing the indirectly pinned keys
-func (p *pinner) IndirectKeys() map[key.Key]int {
-	return p.indirPin.GetRefs()
+// IndirectKeys returns a copy of the indirectly pinned keys and their
+// reference counts
+func (p *pinner) IndirectKeys(ctx context.Context) (map[key.Key]int, error) {
+	if err := p.ensureIndex(ctx); err != nil {
+		return nil, fmt.Errorf("failed to rebuild indirect pin index: %s", err)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	refs := make(map[key.Key]int)
+	for k, v := range p.index.refs {
+		refs[k] = v
+	}
+	for k, v := range p.indirPin.GetRefs() {
+		refs[k] += v
+	}
+	return refs, nil
+}
+
+// GetPinInfo returns the metadata recorded for the given pin
+func (p *pinner) GetPinInfo(k key.Key) (*PinInfo, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	pi, ok := p.pinInfo[k]
+	return pi, ok
 }
 
 // RecursiveKeys returns a slice containing the recursively pinned keys
 func (p *pinner) RecursiveKeys() []key.Key {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.recursePin.GetKeys()
 }
 
-// Flush encodes and writes pinner keysets to the datastore
+// BestEffortKeys returns a slice containing the roots of best effort pins
+func (p *pinner) BestEffortKeys() []key.Key {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.bestEffort.GetKeys()
+}
+
+// Flush writes changes made to the pinner keysets since the last flush to
+// the datastore, then tells the announcer, if any, of the new pins.
 func (p *pinner) Flush() error {
 	p.lock.Lock()
-	defer p.lock.Unlock()
+	err := p.flush()
+	var a Announcer
+	var roots, below []key.Key
+	if err == nil {
+		a, roots, below = p.takeAnnouncements()
+	}
+	p.lock.Unlock()
 
-	err := storeSet(p.dstore, directPinDatastoreKey, p.directPin.GetKeys())
-	if err != nil {
-		return err
+	if a != nil && (len(roots) > 0 || len(below) > 0) {
+		a.AnnouncePinned(roots, below)
 	}
+	return err
+}
 
-	err = storeSet(p.dstore, recursePinDatastoreKey, p.recursePin.GetKeys())
-	if err != nil {
-		return err
+// flush writes the changes to the datastore. must be called with the lock
+// held.
+func (p *pinner) flush() error {
+	if !p.hasSnapshot {
+		// the journal is only meaningful on top of a full snapshot
+		s := p.takeSnapshot()
+		if err := writeSnapshot(p.dstore, s, p.snapshotSeq); err != nil {
+			return err
+		}
+		p.snapshotSeq = s.seq
+		p.hasSnapshot = true
+		p.pending = nil
+		return nil
 	}
 
-	err = storeIndirPin(p.dstore, indirectPinDatastoreKey, p.indirPin)
-	if err != nil {
+	if err := p.writeJournal(); err != nil {
 		return err
 	}
+
+	if p.journalSeq-p.snapshotSeq >= journalCompactThreshold && !p.compacting {
+		p.compacting = true
+		go p.compact()
+	}
 	return nil
 }
 
@@ -319,11 +553,13 @@ func (p *pinner) PinWithMode(k key.Key, mode PinMode) {
 	defer p.lock.Unlock()
 	switch mode {
 	case Recursive:
-		p.recursePin.AddBlock(k)
+		p.addRecursive(k)
 	case Direct:
-		p.directPin.AddBlock(k)
+		p.addDirect(k)
 	case Indirect:
-		p.indirPin.Increment(k)
+		p.incrementIndirect(k)
+	case BestEffort:
+		p.addBestEffort(k)
 	}
 }
 
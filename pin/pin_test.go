@@ -1,6 +1,10 @@
 package pin
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -8,6 +12,8 @@ import (
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	blocks "github.com/ipfs/go-ipfs/blocks"
 	"github.com/ipfs/go-ipfs/blocks/blockstore"
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	bs "github.com/ipfs/go-ipfs/blockservice"
@@ -24,6 +30,22 @@ func randNode() (*mdag.Node, key.Key) {
 	return nd, k
 }
 
+func isPinned(t *testing.T, p Pinner, k key.Key) bool {
+	pinned, err := p.IsPinned(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pinned
+}
+
+func indirectKeys(t *testing.T, p Pinner) map[key.Key]int {
+	refs, err := p.IndirectKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
 func TestPinnerBasic(t *testing.T) {
 	ctx := context.Background()
 
@@ -48,7 +70,7 @@ func TestPinnerBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !p.IsPinned(ak) {
+	if !isPinned(t, p, ak) {
 		t.Fatal("Failed to find key")
 	}
 
@@ -82,12 +104,12 @@ func TestPinnerBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !p.IsPinned(ck) {
+	if !isPinned(t, p, ck) {
 		t.Fatal("Child of recursively pinned node not found")
 	}
 
 	bk, _ := b.Key()
-	if !p.IsPinned(bk) {
+	if !isPinned(t, p, bk) {
 		t.Fatal("Recursively pinned node not found..")
 	}
 
@@ -110,12 +132,12 @@ func TestPinnerBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if !p.IsPinned(ek) {
+	if !isPinned(t, p, ek) {
 		t.Fatal(err)
 	}
 
 	dk, _ := d.Key()
-	if !p.IsPinned(dk) {
+	if !isPinned(t, p, dk) {
 		t.Fatal("pinned node not found.")
 	}
 
@@ -126,7 +148,7 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// c should still be pinned under b
-	if !p.IsPinned(ck) {
+	if !isPinned(t, p, ck) {
 		t.Fatal("Recursive / indirect unpin fail.")
 	}
 
@@ -141,17 +163,17 @@ func TestPinnerBasic(t *testing.T) {
 	}
 
 	// Test directly pinned
-	if !np.IsPinned(ak) {
+	if !isPinned(t, np, ak) {
 		t.Fatal("Could not find pinned node!")
 	}
 
 	// Test indirectly pinned
-	if !np.IsPinned(ck) {
+	if !isPinned(t, np, ck) {
 		t.Fatal("could not find indirectly pinned node")
 	}
 
 	// Test recursively pinned
-	if !np.IsPinned(bk) {
+	if !isPinned(t, np, bk) {
 		t.Fatal("could not find recursively pinned node")
 	}
 }
@@ -228,3 +250,783 @@ func TestPinRecursiveFail(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPinInfo(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	_, err := dserv.Add(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &PinInfo{
+		Name:   "photos",
+		Labels: map[string]string{"owner": "alice"},
+	}
+	err = p.PinWithInfo(ctx, a, true, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := np.GetPinInfo(ak)
+	if !ok {
+		t.Fatal("pin info was not persisted")
+	}
+	if !got.Matches("photos", map[string]string{"owner": "alice"}) {
+		t.Fatal("pin info did not match")
+	}
+	if got.Matches("videos", nil) {
+		t.Fatal("pin info matched wrong name")
+	}
+
+	err = np.Unpin(ctx, ak, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := np.GetPinInfo(ak); ok {
+		t.Fatal("pin info should be removed along with the pin")
+	}
+}
+
+func TestPinJournal(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []key.Key
+	for i := 0; i < int(journalCompactThreshold)+10; i++ {
+		nd, k := randNode()
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Pin(ctx, nd, i%2 == 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+
+	// unpin one, and make sure that is persisted too
+	if err := p.Unpin(ctx, keys[0], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for background compaction to finish
+	for i := 0; ; i++ {
+		pp := p.(*pinner)
+		pp.lock.RLock()
+		done := !pp.compacting && pp.snapshotSeq > 0
+		pp.lock.RUnlock()
+		if done {
+			break
+		}
+		if i > 100 {
+			t.Fatal("compaction never happened")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isPinned(t, np, keys[0]) {
+		t.Fatal("unpinned key was pinned after reload")
+	}
+	for _, k := range keys[1:] {
+		if !isPinned(t, np, k) {
+			t.Fatal("pin lost after reload")
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	b, _ := randNode()
+	if err := b.AddNodeLink("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.AddRecursive(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var res []*PinStatus
+	for st := range p.Verify(ctx, bstore, VerifyOptions{IncludeOk: true, VerifyHashes: true}) {
+		res = append(res, st)
+	}
+	if len(res) != 1 || !res[0].Ok {
+		t.Fatal("expected single intact pin")
+	}
+
+	if err := bstore.DeleteBlock(ak); err != nil {
+		t.Fatal(err)
+	}
+
+	res = nil
+	for st := range p.Verify(ctx, bstore, VerifyOptions{}) {
+		res = append(res, st)
+	}
+	if len(res) != 1 || res[0].Ok {
+		t.Fatal("expected single broken pin")
+	}
+	if len(res[0].BadNodes) != 1 || res[0].BadNodes[0].Key != ak {
+		t.Fatal("expected missing block to be reported")
+	}
+}
+
+func TestVerifyOtherHash(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	// a child hashed with another function than the default one
+	a, _ := randNode()
+	data, err := a.Encoded(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := mh.Sum(data, mh.SHA2_512, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(&blocks.Block{Data: data, Multihash: h}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := randNode()
+	if err := b.AddRawLink("a", &mdag.Link{Hash: h}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func() []*PinStatus {
+		var res []*PinStatus
+		for st := range p.Verify(ctx, bstore, VerifyOptions{VerifyHashes: true}) {
+			res = append(res, st)
+		}
+		return res
+	}
+	if res := verify(); len(res) != 0 {
+		t.Fatalf("expected the pin to be intact, got %v", res[0].BadNodes)
+	}
+
+	// the child is corrupted
+	if err := bstore.DeleteBlock(key.Key(h)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(&blocks.Block{Data: []byte("corrupt"), Multihash: h}); err != nil {
+		t.Fatal(err)
+	}
+	res := verify()
+	if len(res) != 1 || len(res[0].BadNodes) != 1 || res[0].BadNodes[0].Key != key.Key(h) {
+		t.Fatal("expected the corrupted child to be reported")
+	}
+}
+
+type recordingAnnouncer struct {
+	roots, below []key.Key
+}
+
+func (a *recordingAnnouncer) AnnouncePinned(roots, below []key.Key) {
+	a.roots = append(a.roots, roots...)
+	a.below = append(a.below, below...)
+}
+
+func TestAnnounceOnFlush(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	dserv := mdag.NewDAGService(bs.New(bstore, offline.Exchange(bstore)))
+
+	p := NewPinner(dstore, dserv)
+	a := new(recordingAnnouncer)
+	p.SetAnnouncer(a)
+
+	// pins made the way ipfs add makes them
+	_, rk := randNode()
+	_, ck := randNode()
+	mp := p.GetManual()
+	mp.PinWithMode(ck, Indirect)
+	mp.PinWithMode(rk, Recursive)
+	if len(a.roots) != 0 || len(a.below) != 0 {
+		t.Fatal("expected nothing to be announced before the flush")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.roots) != 1 || a.roots[0] != rk || len(a.below) != 1 || a.below[0] != ck {
+		t.Fatalf("expected the root and the block below it to be announced, got %v and %v", a.roots, a.below)
+	}
+
+	// only new pins are announced
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.roots) != 1 || len(a.below) != 1 {
+		t.Fatal("expected the pins to be announced once")
+	}
+}
+
+func TestPinUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	shared, _ := randNode()
+	leaf, _ := randNode()
+
+	oldSub, _ := randNode()
+	if err := oldSub.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	oldRoot, _ := randNode()
+	oldRoot.AddNodeLink("shared", shared)
+	oldRoot.AddNodeLink("sub", oldSub)
+	if err := dserv.AddRecursive(oldRoot); err != nil {
+		t.Fatal(err)
+	}
+	oldk, _ := oldRoot.Key()
+
+	extra, _ := randNode()
+	newSub, _ := randNode()
+	newSub.AddNodeLink("leaf", leaf)
+	newSub.AddNodeLink("extra", extra)
+	newRoot, _ := randNode()
+	newRoot.AddNodeLink("shared", shared)
+	newRoot.AddNodeLink("sub", newSub)
+	if err := dserv.AddRecursive(newRoot); err != nil {
+		t.Fatal(err)
+	}
+	newk, _ := newRoot.Key()
+
+	p := NewPinner(dstore, dserv)
+	if err := p.Pin(ctx, oldRoot, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Update(ctx, oldk, newk); err != nil {
+		t.Fatal(err)
+	}
+
+	if isPinned(t, p, oldk) {
+		t.Fatal("old root still pinned")
+	}
+	if !isPinned(t, p, newk) {
+		t.Fatal("new root not pinned")
+	}
+
+	// the result should be the same as pinning the new root from scratch
+	exp := NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dserv)
+	if err := exp.Pin(ctx, newRoot, true); err != nil {
+		t.Fatal(err)
+	}
+
+	got := indirectKeys(t, p)
+	want := indirectKeys(t, exp)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d indirect pins, got %d", len(want), len(got))
+	}
+	for k, c := range want {
+		if got[k] != c {
+			t.Fatalf("wrong refcount for %s: %d != %d", k, got[k], c)
+		}
+	}
+}
+
+func TestPinExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	b, bk := randNode()
+	for _, nd := range []*mdag.Node{a, b} {
+		if _, err := dserv.Add(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.PinWithExpiry(ctx, a, true, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PinWithExpiry(ctx, b, false, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := p.SweepExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || removed[0] != ak {
+		t.Fatal("expected only the expired pin to be removed")
+	}
+	if isPinned(t, p, ak) {
+		t.Fatal("expired pin still pinned")
+	}
+	if !isPinned(t, p, bk) {
+		t.Fatal("unexpired pin was removed")
+	}
+}
+
+func TestPinExpiryUnpinError(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, ak := randNode()
+	if _, err := dserv.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Second)
+	if err := p.PinWithExpiry(ctx, a, true, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// an expired pin that can't be unpinned doesn't stop the sweep
+	_, bk := randNode()
+	p.(*pinner).pinInfo[bk] = &PinInfo{Expires: &expired}
+
+	removed, err := p.SweepExpired(ctx)
+	if err == nil {
+		t.Fatal("expected the unpin error to be returned")
+	}
+	if len(removed) != 1 || removed[0] != ak {
+		t.Fatal("expected the other expired pin to be removed")
+	}
+	if isPinned(t, p, ak) {
+		t.Fatal("expired pin still pinned")
+	}
+}
+
+func TestConcurrentPinning(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	root, _ := randNode()
+	for i := 0; i < 10; i++ {
+		c, _ := randNode()
+		if err := root.AddNodeLink(fmt.Sprint(i), c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dserv.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+	rk, _ := root.Key()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := p.Pin(ctx, root, true); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := p.IsPinned(ctx, rk); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// pinning the same root concurrently must only count it once
+	for k, c := range indirectKeys(t, p) {
+		if c != 1 {
+			t.Fatalf("expected refcount of 1 for %s, got %d", k, c)
+		}
+	}
+
+	if err := p.Unpin(ctx, rk, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(indirectKeys(t, p)) != 0 {
+		t.Fatal("expected no indirect pins after unpinning")
+	}
+}
+
+func TestUnpinUpdatesIndex(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv).(*pinner)
+
+	shared, sk := randNode()
+	only, ok := randNode()
+	a, _ := randNode()
+	b, _ := randNode()
+	if err := a.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddNodeLink("only", only); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*mdag.Node{a, b} {
+		if err := dserv.AddRecursive(nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, nd := range []*mdag.Node{a, b} {
+		if err := p.Pin(ctx, nd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bk, _ := b.Key()
+	if err := p.Unpin(ctx, bk, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the refs of b were subtracted, without rebuilding the index
+	if !p.indexCurrent() {
+		t.Fatal("expected the index to stay current after a recursive unpin")
+	}
+	if !isPinned(t, p, sk) {
+		t.Fatal("expected the child shared with a to stay pinned")
+	}
+	if isPinned(t, p, ok) {
+		t.Fatal("expected the child only b linked to to be unpinned")
+	}
+}
+
+func TestIsPinnedIncompleteDag(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	root, _ := randNode()
+	c, ck := randNode()
+	if err := root.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.DeleteBlock(ck); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index of the loaded pinner can't be built, which must not be
+	// mistaken for any block being pinned, or not
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other := randNode()
+	if _, err := np.IsPinned(ctx, other); err == nil {
+		t.Fatal("expected an error with a block of a recursive pin missing")
+	}
+	if _, err := np.IndirectKeys(ctx); err == nil {
+		t.Fatal("expected an error with a block of a recursive pin missing")
+	}
+}
+
+func TestPinProgress(t *testing.T) {
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	root, _ := randNode()
+	for i := 0; i < 5; i++ {
+		c, _ := randNode()
+		g, _ := randNode()
+		if err := c.AddNodeLink("g", g); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLink(fmt.Sprint(i), c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dserv.AddRecursive(root); err != nil {
+		t.Fatal(err)
+	}
+
+	pt := new(ProgressTracker)
+	ctx := ContextWithProgress(context.Background(), pt)
+	if err := p.Pin(ctx, root, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if pt.Value() != 10 {
+		t.Fatalf("expected 10 nodes fetched, got %d", pt.Value())
+	}
+}
+
+func TestIndirectSharedSubtree(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	shared, _ := randNode()
+	leaf, _ := randNode()
+	if err := shared.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	sk, _ := shared.Key()
+
+	a, _ := randNode()
+	b, _ := randNode()
+	only, onlyk := randNode()
+	for _, n := range []*mdag.Node{a, b} {
+		if err := n.AddNodeLink("shared", shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.AddNodeLink("only", only); err != nil {
+		t.Fatal(err)
+	}
+	ak, _ := a.Key()
+	for _, n := range []*mdag.Node{a, b} {
+		if err := dserv.AddRecursive(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, n := range []*mdag.Node{a, b} {
+		wg.Add(1)
+		go func(n *mdag.Node) {
+			defer wg.Done()
+			if err := p.Pin(ctx, n, true); err != nil {
+				t.Error(err)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if err := p.Unpin(ctx, ak, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isPinned(t, p, sk) {
+		t.Fatal("shared subtree should still be pinned through the other root")
+	}
+	if isPinned(t, p, onlyk) {
+		t.Fatal("subtree only reachable from the unpinned root should not be pinned")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isPinned(t, np, sk) || isPinned(t, np, onlyk) {
+		t.Fatal("reloaded pinner disagrees about indirect pins")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+
+	a, _ := randNode()
+	b, bk := randNode()
+	c, _ := randNode()
+	if err := a.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	ak, _ := a.Key()
+	ck, _ := c.Key()
+	if err := dserv.AddRecursive(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dserv.Add(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.PinWithInfo(ctx, a, true, &PinInfo{Name: "my photos"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := p.Export(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	np := NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dserv)
+	if err := np.Import(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !isPinned(t, np, ak) || !isPinned(t, np, bk) || !isPinned(t, np, ck) {
+		t.Fatal("imported pins missing")
+	}
+	if len(np.RecursiveKeys()) != 1 || len(np.DirectKeys()) != 1 {
+		t.Fatal("imported pins have wrong modes")
+	}
+	info, ok := np.GetPinInfo(ak)
+	if !ok || info.Name != "my photos" {
+		t.Fatal("pin name was not imported")
+	}
+
+	err := np.Import(strings.NewReader("notakey recursive\n"))
+	if err == nil {
+		t.Fatal("expected malformed import to fail")
+	}
+}
+
+func TestBestEffortPin(t *testing.T) {
+	ctx := context.Background()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p := NewPinner(dstore, dserv)
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the child is never added, best effort pins don't need it
+	a, _ := randNode()
+	c, ck := randNode()
+	if err := a.AddNodeLinkClean("c", c); err != nil {
+		t.Fatal(err)
+	}
+	ak, _ := a.Key()
+
+	p.GetManual().PinWithMode(ak, BestEffort)
+	if !isPinned(t, p, ak) {
+		t.Fatal("best effort root should be pinned")
+	}
+	if isPinned(t, p, ck) {
+		t.Fatal("children of best effort pins are not reported as pinned")
+	}
+
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	np, err := LoadPinner(dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := np.BestEffortKeys(); len(keys) != 1 || keys[0] != ak {
+		t.Fatal("best effort pin was not persisted")
+	}
+
+	if err := np.Unpin(ctx, ak, true); err != nil {
+		t.Fatal(err)
+	}
+	if isPinned(t, np, ak) {
+		t.Fatal("best effort pin should have been removed")
+	}
+}
@@ -0,0 +1,36 @@
+package pin
+
+import (
+	"sync/atomic"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+// ProgressTracker counts the nodes fetched while walking a dag for a
+// recursive pin. Attach one to the context passed to Pin with
+// ContextWithProgress, and poll it with Value while the pin is running.
+type ProgressTracker struct {
+	nodes int64
+}
+
+func (pt *ProgressTracker) increment() {
+	atomic.AddInt64(&pt.nodes, 1)
+}
+
+// Value returns the number of nodes fetched so far.
+func (pt *ProgressTracker) Value() int {
+	return int(atomic.LoadInt64(&pt.nodes))
+}
+
+type progressCtxKey struct{}
+
+// ContextWithProgress returns a context that reports pinning progress to
+// the given tracker.
+func ContextWithProgress(ctx context.Context, pt *ProgressTracker) context.Context {
+	return context.WithValue(ctx, progressCtxKey{}, pt)
+}
+
+func progressFromContext(ctx context.Context) *ProgressTracker {
+	pt, _ := ctx.Value(progressCtxKey{}).(*ProgressTracker)
+	return pt
+}
@@ -0,0 +1,203 @@
+// package remote implements a client for remote pinning services, allowing a
+// node to delegate keeping objects available to a third party.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+)
+
+var ErrNotFound = errors.New("pin request not found")
+var ErrUnauthorized = errors.New("access token rejected by pinning service")
+
+// Status is the state of a pin request on the remote service.
+type Status string
+
+const (
+	Queued  Status = "queued"
+	Pinning Status = "pinning"
+	Pinned  Status = "pinned"
+	Failed  Status = "failed"
+)
+
+// PinStatus describes a single pin request held by the remote service.
+type PinStatus struct {
+	RequestID string
+	Key       key.Key
+	Name      string
+	Status    Status
+	Created   time.Time
+}
+
+// ListOptions filters the pin requests returned by ListRemote. Zero values
+// match everything.
+type ListOptions struct {
+	Name   string
+	Status []Status
+	Limit  int
+}
+
+// Client talks to a remote pinning service over its HTTP API.
+type Client struct {
+	endpoint   string
+	token      string
+	httpClient http.Client
+}
+
+// NewClient returns a client for the pinning service at the given url,
+// authenticating with the given access token.
+func NewClient(endpoint, token string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		token:    token,
+	}
+}
+
+// pinObject is the wire format of a pin request.
+type pinObject struct {
+	Cid  string `json:"cid"`
+	Name string `json:"name,omitempty"`
+}
+
+type pinStatusObject struct {
+	RequestID string    `json:"requestid"`
+	Status    Status    `json:"status"`
+	Created   time.Time `json:"created"`
+	Pin       pinObject `json:"pin"`
+}
+
+type listResponse struct {
+	Count   int               `json:"count"`
+	Results []pinStatusObject `json:"results"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	} `json:"error"`
+}
+
+// PinRemote asks the service to pin the given key, returning the status of
+// the new pin request.
+func (c *Client) PinRemote(ctx context.Context, k key.Key, name string) (*PinStatus, error) {
+	body, err := json.Marshal(pinObject{Cid: k.B58String(), Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	var out pinStatusObject
+	if err := c.do(ctx, "POST", "/pins", bytes.NewReader(body), &out); err != nil {
+		return nil, err
+	}
+	return out.toStatus(), nil
+}
+
+// StatusRemote returns the current status of the given pin request.
+func (c *Client) StatusRemote(ctx context.Context, requestID string) (*PinStatus, error) {
+	var out pinStatusObject
+	err := c.do(ctx, "GET", "/pins/"+url.QueryEscape(requestID), nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.toStatus(), nil
+}
+
+// ListRemote returns the pin requests held by the service that match the
+// given options.
+func (c *Client) ListRemote(ctx context.Context, opts ListOptions) ([]*PinStatus, error) {
+	q := url.Values{}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+	if len(opts.Status) > 0 {
+		var st []string
+		for _, s := range opts.Status {
+			st = append(st, string(s))
+		}
+		q.Set("status", strings.Join(st, ","))
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", fmt.Sprint(opts.Limit))
+	}
+
+	path := "/pins"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var out listResponse
+	if err := c.do(ctx, "GET", path, nil, &out); err != nil {
+		return nil, err
+	}
+
+	pins := make([]*PinStatus, 0, len(out.Results))
+	for _, r := range out.Results {
+		pins = append(pins, r.toStatus())
+	}
+	return pins, nil
+}
+
+// UnpinRemote asks the service to drop the given pin request.
+func (c *Client) UnpinRemote(ctx context.Context, requestID string) error {
+	return c.do(ctx, "DELETE", "/pins/"+url.QueryEscape(requestID), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Cancel = ctx.Done()
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode >= 300:
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error.Reason == "" {
+			return fmt.Errorf("pinning service returned %s", resp.Status)
+		}
+		if e.Error.Details != "" {
+			return fmt.Errorf("pinning service: %s: %s", e.Error.Reason, e.Error.Details)
+		}
+		return fmt.Errorf("pinning service: %s", e.Error.Reason)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p pinStatusObject) toStatus() *PinStatus {
+	return &PinStatus{
+		RequestID: p.RequestID,
+		Key:       key.B58KeyDecode(p.Pin.Cid),
+		Name:      p.Pin.Name,
+		Status:    p.Status,
+		Created:   p.Created,
+	}
+}
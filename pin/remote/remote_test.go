@@ -0,0 +1,126 @@
+package remote
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// fakeService is a minimal in memory pinning service.
+type fakeService struct {
+	lk   sync.Mutex
+	pins map[string]pinStatusObject
+	next int
+}
+
+func (s *fakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, "/pins/")
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/pins":
+		var p pinObject
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.next++
+		st := pinStatusObject{
+			RequestID: string('a' + rune(s.next)),
+			Status:    Queued,
+			Created:   time.Now(),
+			Pin:       p,
+		}
+		s.pins[st.RequestID] = st
+		json.NewEncoder(w).Encode(st)
+	case r.Method == "GET" && r.URL.Path == "/pins":
+		var res listResponse
+		for _, st := range s.pins {
+			if name := r.URL.Query().Get("name"); name != "" && st.Pin.Name != name {
+				continue
+			}
+			res.Results = append(res.Results, st)
+		}
+		res.Count = len(res.Results)
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "GET":
+		st, ok := s.pins[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(st)
+	case r.Method == "DELETE":
+		if _, ok := s.pins[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.pins, id)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestRemotePinning(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(&fakeService{pins: make(map[string]pinStatusObject)})
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", "secret")
+	k := key.Key(u.Hash([]byte("beep boop")))
+
+	st, err := c.PinRemote(ctx, k, "beep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Key != k || st.Status != Queued {
+		t.Fatal("unexpected pin status", st)
+	}
+
+	got, err := c.StatusRemote(ctx, st.RequestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Key != k || got.Name != "beep" {
+		t.Fatal("status did not match pin request")
+	}
+
+	if _, err := c.PinRemote(ctx, key.Key(u.Hash([]byte("other"))), "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	pins, err := c.ListRemote(ctx, ListOptions{Name: "beep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[0].Key != k {
+		t.Fatal("expected list to be filtered by name")
+	}
+
+	if err := c.UnpinRemote(ctx, st.RequestID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.StatusRemote(ctx, st.RequestID); err != ErrNotFound {
+		t.Fatal("expected removed pin to be gone, got", err)
+	}
+
+	bad := NewClient(srv.URL, "wrong")
+	if _, err := bad.ListRemote(ctx, ListOptions{}); err != ErrUnauthorized {
+		t.Fatal("expected bad token to be rejected, got", err)
+	}
+}
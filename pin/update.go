@@ -0,0 +1,171 @@
+package pin
+
+import (
+	"fmt"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// Update replaces the recursive pin on from with a recursive pin on to.
+// Only the parts of the two dags that differ are walked; subdags shared
+// between them stay pinned without being traversed. Any metadata recorded
+// for the old pin is carried over to the new one.
+func (p *pinner) Update(ctx context.Context, from, to key.Key) error {
+	p.lock.RLock()
+	fromPinned := p.recursePin.HasKey(from)
+	toPinned := p.recursePin.HasKey(to)
+	p.lock.RUnlock()
+
+	if !fromPinned {
+		return fmt.Errorf("'from' key %s is not pinned recursively", from)
+	}
+
+	if from == to {
+		return nil
+	}
+
+	if toPinned {
+		// nothing new to pin, just drop the old pin
+		return p.Unpin(ctx, from, true)
+	}
+
+	ses := p.dserv.Session(ctx)
+	defer ses.Close()
+
+	fromNode, err := ses.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	toNode, err := ses.Get(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	delta := make(map[key.Key]int)
+	if err := p.updateLinks(ctx, ses, fromNode, toNode, delta); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	if !p.recursePin.HasKey(from) {
+		p.lock.Unlock()
+		return fmt.Errorf("'from' key %s is not pinned recursively", from)
+	}
+	if p.recursePin.HasKey(to) {
+		// pinned while we were walking the dags
+		p.lock.Unlock()
+		return p.Unpin(ctx, from, true)
+	}
+	defer p.lock.Unlock()
+
+	if p.directPin.HasKey(to) {
+		p.removeDirect(to)
+	}
+
+	current := p.indexCurrent()
+	p.addRecursive(to)
+	p.removeRecursive(from)
+	p.updateIndex(delta, 1, current)
+	for k, c := range delta {
+		if c > 0 {
+			p.announceBelow(k)
+		}
+	}
+
+	if info, ok := p.pinInfo[from]; ok {
+		p.removePinInfo(from)
+		p.setPinInfo(to, info)
+	}
+	return nil
+}
+
+// updateLinks records in delta the changes to indirect pin counts of the
+// children of from and to, as if from had been unpinned and to pinned. Links
+// pointing at the same node in both cancel out, and links with the same
+// name are compared recursively, so only the differences between the dags
+// are visited.
+func (p *pinner) updateLinks(ctx context.Context, ses *mdag.Session, from, to *mdag.Node, delta map[key.Key]int) error {
+	// links to identical nodes in both dags need no changes
+	unmatched := make(map[key.Key]int)
+	for _, l := range from.Links {
+		unmatched[key.Key(l.Hash)]++
+	}
+
+	var added []*mdag.Link
+	for _, l := range to.Links {
+		k := key.Key(l.Hash)
+		if unmatched[k] > 0 {
+			unmatched[k]--
+			continue
+		}
+		added = append(added, l)
+	}
+
+	// removed links are indexed by name, so they can be paired up with
+	// added links of the same name. unnamed or duplicate names can't be.
+	removed := make(map[string]*mdag.Link)
+	var unpaired []*mdag.Link
+	for _, l := range from.Links {
+		k := key.Key(l.Hash)
+		if unmatched[k] == 0 {
+			continue
+		}
+		unmatched[k]--
+		if _, ok := removed[l.Name]; ok || l.Name == "" {
+			unpaired = append(unpaired, l)
+			continue
+		}
+		removed[l.Name] = l
+	}
+
+	for _, l := range added {
+		child, err := ses.Get(ctx, key.Key(l.Hash))
+		if err != nil {
+			return err
+		}
+		delta[key.Key(l.Hash)]++
+
+		old, ok := removed[l.Name]
+		if !ok || l.Name == "" {
+			if err := p.collectLinks(ctx, ses, child, delta); err != nil {
+				return err
+			}
+			continue
+		}
+		delete(removed, l.Name)
+
+		// the same name points at a different node, walk both
+		oldChild, err := ses.Get(ctx, key.Key(old.Hash))
+		if err != nil {
+			return err
+		}
+		delta[key.Key(old.Hash)]--
+
+		if err := p.updateLinks(ctx, ses, oldChild, child, delta); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range removed {
+		unpaired = append(unpaired, l)
+	}
+	for _, l := range unpaired {
+		child, err := ses.Get(ctx, key.Key(l.Hash))
+		if err != nil {
+			return err
+		}
+		delta[key.Key(l.Hash)]--
+
+		refs := make(map[key.Key]int)
+		if err := p.collectLinks(ctx, ses, child, refs); err != nil {
+			return err
+		}
+		for k, c := range refs {
+			delta[k] -= c
+		}
+	}
+	return nil
+}
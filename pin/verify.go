@@ -0,0 +1,102 @@
+package pin
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/ipfs/go-ipfs/blocks/blockstore"
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+)
+
+// VerifyOptions controls how thoroughly Verify checks pins.
+type VerifyOptions struct {
+	// VerifyHashes rehashes the data of every block, detecting blocks that
+	// are present but corrupted.
+	VerifyHashes bool
+
+	// IncludeOk also reports pins that were found to be intact.
+	IncludeOk bool
+}
+
+// BadNode is a node in a pinned dag that is missing or corrupt.
+type BadNode struct {
+	Key key.Key
+	Err error
+}
+
+// PinStatus is the result of verifying a single recursive pin.
+type PinStatus struct {
+	Key      key.Key
+	Ok       bool
+	BadNodes []BadNode
+}
+
+// Verify walks every recursive pin and checks that all the blocks it
+// references are present in the given blockstore. Blocks are never fetched
+// from the network. Results are streamed on the returned channel, which is
+// closed once all pins have been checked.
+func (p *pinner) Verify(ctx context.Context, bs blockstore.Blockstore, opts VerifyOptions) <-chan *PinStatus {
+	out := make(chan *PinStatus)
+	go func() {
+		defer close(out)
+
+		if opts.VerifyHashes {
+			bs = blockstore.NewVerifyingBlockstore(bs)
+		}
+		v := &verifier{
+			bs:   bs,
+			opts: opts,
+			seen: make(map[key.Key][]BadNode),
+		}
+
+		for _, k := range p.RecursiveKeys() {
+			bad := v.check(k)
+			if len(bad) == 0 && !opts.IncludeOk {
+				continue
+			}
+
+			select {
+			case out <- &PinStatus{Key: k, Ok: len(bad) == 0, BadNodes: bad}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type verifier struct {
+	bs   blockstore.Blockstore
+	opts VerifyOptions
+
+	// results of previously checked subdags, so dags shared between
+	// pins are only walked once.
+	seen map[key.Key][]BadNode
+}
+
+func (v *verifier) check(k key.Key) []BadNode {
+	if bad, ok := v.seen[k]; ok {
+		return bad
+	}
+
+	bad := v.checkNode(k)
+	v.seen[k] = bad
+	return bad
+}
+
+func (v *verifier) checkNode(k key.Key) []BadNode {
+	blk, err := v.bs.Get(k)
+	if err != nil {
+		return []BadNode{{Key: k, Err: err}}
+	}
+
+	nd, err := mdag.Decoded(blk.Data)
+	if err != nil {
+		return []BadNode{{Key: k, Err: err}}
+	}
+
+	var bad []BadNode
+	for _, l := range nd.Links {
+		bad = append(bad, v.check(key.Key(l.Hash))...)
+	}
+	return bad
+}
@@ -1,5 +1,50 @@
 package config
 
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
 type API struct {
 	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+
+	// Authorizations are the credentials the API accepts, by name. When
+	// there are none, the API is open to anyone who can reach it.
+	Authorizations map[string]APIAuthorization `json:",omitempty"`
+}
+
+// APIAuthorization is a credential for the API and the paths it can use.
+type APIAuthorization struct {
+	// AuthSecret is "bearer:<token>" or "basic:<user>:<password>".
+	AuthSecret string
+
+	// AllowedPaths are the API paths the credential can use, such as
+	// "/api/v0/cat", with the paths under them. All paths are allowed if
+	// empty.
+	AllowedPaths []string `json:",omitempty"`
+
+	// DeniedPaths are the API paths the credential cannot use, even if
+	// they are under an allowed path.
+	DeniedPaths []string `json:",omitempty"`
+}
+
+// AuthorizationHeader returns the value of the Authorization header that
+// sends the AuthSecret secret.
+func AuthorizationHeader(secret string) (string, error) {
+	parts := strings.SplitN(secret, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", errors.New(`auth secret must be "bearer:<token>" or "basic:<user>:<password>"`)
+	}
+	switch strings.ToLower(parts[0]) {
+	case "bearer":
+		return "Bearer " + parts[1], nil
+	case "basic":
+		if !strings.Contains(parts[1], ":") {
+			return "", errors.New(`basic auth secret must be "basic:<user>:<password>"`)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(parts[1])), nil
+	default:
+		return "", errors.New(`auth secret must be "bearer:<token>" or "basic:<user>:<password>"`)
+	}
 }
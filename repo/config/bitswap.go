@@ -0,0 +1,20 @@
+package config
+
+// Bitswap configures the block exchange. Rate limits are sizes per second,
+// such as "500kB" or "2MiB"; an empty limit means unlimited.
+type Bitswap struct {
+	// Strategy decides which peers are sent the blocks they ask for first:
+	// "roundrobin" (the default) or "debtratio".
+	Strategy string `json:",omitempty"`
+
+	// ProvideStrategy decides which blocks the node announces to the
+	// routing system: "all" (the default), "roots" for the roots of pins,
+	// "pinned" for all pinned blocks, or "none". Under "roots" and
+	// "pinned", blocks are announced once they are pinned.
+	ProvideStrategy string `json:",omitempty"`
+
+	UploadLimit       string `json:",omitempty"` // to all peers
+	DownloadLimit     string `json:",omitempty"` // from all peers
+	PeerUploadLimit   string `json:",omitempty"` // to each peer
+	PeerDownloadLimit string `json:",omitempty"` // from each peer
+}
@@ -24,11 +24,17 @@ type Config struct {
 	Version          Version               // local node's version management
 	Discovery        Discovery             // local node's discovery mechanisms
 	Ipns             Ipns                  // Ipns settings
+	DNS              DNS                   // DNSLink lookup settings
 	Bootstrap        []string              // local nodes's bootstrap peer addresses
 	Tour             Tour                  // local node's tour position
 	Gateway          Gateway               // local node's gateway server options
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
+	Routing          Routing               // local node's routing system
 	API              API                   // local node's API settings
+	Bitswap          Bitswap               // local node's block exchange settings
+	Reprovider       Reprovider            // local node's reannouncing of its blocks
+	HTTPRetrieval    HTTPRetrieval         // local node's http block sources
+	Offline          Offline               // local node's offline behavior
 	Swarm            SwarmConfig
 	Log              Log
 }
@@ -112,3 +118,16 @@ func ToMap(conf *Config) (map[string]interface{}, error) {
 	}
 	return m, nil
 }
+
+// Clone returns a deep copy of the config.
+func (c *Config) Clone() (*Config, error) {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(c); err != nil {
+		return nil, err
+	}
+	var conf Config
+	if err := json.NewDecoder(buf).Decode(&conf); err != nil {
+		return nil, fmt.Errorf("Failure to decode config: %s", err)
+	}
+	return &conf, nil
+}
@@ -7,6 +7,59 @@ const DefaultDataStoreDirectory = "datastore"
 type Datastore struct {
 	Type string
 	Path string
+
+	// Mounts lists the datastores making up the repo, and the key prefixes
+	// they are mounted at. When empty, blocks are stored in flatfs and
+	// everything else in leveldb.
+	Mounts []DatastoreMount `json:",omitempty"`
+
+	// BloomFilterSize is the size in bytes of the bloom filter used to
+	// answer Has calls for blocks that are not stored without going to
+	// disk. Zero disables the filter.
+	BloomFilterSize int `json:",omitempty"`
+
+	// HasLRUSize is the number of keys whose presence in the blockstore is
+	// kept in an LRU cache in memory. Zero disables the cache.
+	HasLRUSize int `json:",omitempty"`
+
+	// HashOnRead makes the blockstore re-hash every block it reads from
+	// disk, and fail reads of blocks whose data no longer matches. Useful
+	// when the storage can't be trusted to keep data intact.
+	HashOnRead bool `json:",omitempty"`
+
+	// BlockCompression is the codec new blocks are compressed with:
+	// "snappy", "zlib", or "none". Blocks keep their keys, and those
+	// already stored are read back whatever the setting.
+	BlockCompression string `json:",omitempty"`
+
+	// StorageMax is the size the repo may grow to, e.g. "10GB". Garbage
+	// collection runs when the repo nears it, and adding data that would
+	// take the repo past it fails. Empty means no limit.
+	StorageMax string `json:",omitempty"`
+
+	// StorageGCWatermark is the percentage of StorageMax past which garbage
+	// collection runs. Defaults to 90.
+	StorageGCWatermark int64 `json:",omitempty"`
+
+	// GCPeriod is how often the daemon checks the repo size against
+	// StorageMax, e.g. "30m". Defaults to an hour.
+	GCPeriod string `json:",omitempty"`
+}
+
+// DatastoreMount describes a single datastore mounted into the repo.
+type DatastoreMount struct {
+	// Prefix is the key prefix the datastore is mounted at, e.g. "/blocks".
+	Prefix string
+
+	// Type is the kind of datastore: "leveldb", "flatfs" or "mem".
+	Type string
+
+	// Path is where the datastore keeps its data, relative to the repo
+	// root. Unused for in-memory datastores.
+	Path string `json:",omitempty"`
+
+	// Params holds settings specific to the datastore type.
+	Params map[string]string `json:",omitempty"`
 }
 
 // DataStorePath returns the default data store path given a configuration root
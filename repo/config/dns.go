@@ -0,0 +1,13 @@
+package config
+
+// DNS configures how the DNSLink records of domain names are looked up.
+type DNS struct {
+	// Servers are the DNS servers to ask, as host or host:port, in order.
+	// When empty, the system resolver is used.
+	Servers []string `json:",omitempty"`
+
+	// HTTPSEndpoint is the URL of a DNS over HTTPS server answering JSON
+	// queries, such as "https://cloudflare-dns.com/dns-query". When set,
+	// it is used instead of Servers.
+	HTTPSEndpoint string `json:",omitempty"`
+}
@@ -5,4 +5,39 @@ type Gateway struct {
 	HTTPHeaders  map[string][]string // HTTP headers to return with the gateway
 	RootRedirect string
 	Writable     bool
+
+	// DirIndexTemplate is a file with the html/template used for directory
+	// listings, instead of the default page.
+	DirIndexTemplate string `json:",omitempty"`
+
+	// PublicGateways configures the hostnames the gateway is reached at,
+	// by hostname. Requests for other hosts are looked up as dnslink names.
+	PublicGateways map[string]GatewaySpec `json:",omitempty"`
+
+	// AccessLog logs a gatewayRequest event for every request, with its
+	// path, hash, status, size and latency.
+	AccessLog bool `json:",omitempty"`
+
+	// NoAPI stops serving the read-only API under /api/v0 on the gateway.
+	NoAPI bool `json:",omitempty"`
+
+	// APICommands restricts the read-only API of the gateway to these
+	// commands, given as paths such as "cat" or "object/get". All of the
+	// read-only commands are served if empty.
+	APICommands []string `json:",omitempty"`
+}
+
+// GatewaySpec configures the gateway for one hostname.
+type GatewaySpec struct {
+	// Paths are the path prefixes served on the hostname, such as "/ipfs"
+	// and "/ipns". All of them are served if empty.
+	Paths []string `json:",omitempty"`
+
+	// UseSubdomains serves /ipfs/<hash> at <hash>.ipfs.<hostname> and
+	// /ipns/<name> at <name>.ipns.<hostname>, so that each gets its own
+	// origin in browsers. Paths on the hostname itself are redirected there.
+	UseSubdomains bool `json:",omitempty"`
+
+	// RootPath is served at the root of the hostname, as a virtual host.
+	RootPath string `json:",omitempty"`
 }
@@ -0,0 +1,14 @@
+package config
+
+// HTTPRetrieval configures fetching blocks over plain HTTP, for nodes that
+// can't reach other peers directly.
+type HTTPRetrieval struct {
+	// URLs lists where to fetch blocks from, tried in order. "{key}" in a
+	// url is replaced by the key of the block; urls without it have the key
+	// appended to their path.
+	URLs []string `json:",omitempty"`
+
+	// Only fetches blocks over HTTP alone, without bitswap. Otherwise HTTP
+	// is used for blocks bitswap doesn't find quickly.
+	Only bool `json:",omitempty"`
+}
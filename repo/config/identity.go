@@ -1,24 +1,192 @@
 package config
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
 	ic "github.com/ipfs/go-ipfs/p2p/crypto"
 )
 
+// KeyEncryptionV1 encrypts the private key with AES-256-GCM, under a key
+// derived from the passphrase with PBKDF2-HMAC-SHA256. The encrypted key is
+// stored as the salt, the nonce and the sealed key, in that order.
+const KeyEncryptionV1 = "aes-256-gcm/pbkdf2-sha256"
+
+const (
+	keySaltSize   = 16
+	keyIterations = 1 << 16
+)
+
+var (
+	// ErrKeyEncrypted is returned when decoding an encrypted private key
+	// without a passphrase.
+	ErrKeyEncrypted = errors.New("private key is encrypted, a passphrase is needed to decrypt it")
+
+	// ErrBadPassphrase is returned when the passphrase doesn't decrypt the
+	// private key.
+	ErrBadPassphrase = errors.New("wrong passphrase for the private key")
+)
+
 // Identity tracks the configuration of the local node's identity.
 type Identity struct {
 	PeerID  string
 	PrivKey string
+
+	// PrivKeyEncryption is how PrivKey is encrypted, or empty if it is
+	// stored in the clear.
+	PrivKeyEncryption string `json:",omitempty"`
 }
 
-// DecodePrivateKey is a helper to decode the users PrivateKey
+// Encrypted returns whether the private key is encrypted.
+func (i *Identity) Encrypted() bool {
+	return i.PrivKeyEncryption != ""
+}
+
+// DecodePrivateKey is a helper to decode the users PrivateKey. The
+// passphrase is only used when the key is encrypted.
 func (i *Identity) DecodePrivateKey(passphrase string) (ic.PrivKey, error) {
 	pkb, err := base64.StdEncoding.DecodeString(i.PrivKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// currently storing key unencrypted. in the future we need to encrypt it.
-	// TODO(security)
+	if i.Encrypted() {
+		if passphrase == "" {
+			return nil, ErrKeyEncrypted
+		}
+		pkb, err = openKey(i.PrivKeyEncryption, pkb, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return ic.UnmarshalPrivateKey(pkb)
 }
+
+// EncryptPrivateKey encrypts the private key with the passphrase. The key
+// must be stored in the clear.
+func (i *Identity) EncryptPrivateKey(passphrase string) error {
+	if i.Encrypted() {
+		return errors.New("private key is already encrypted")
+	}
+	if passphrase == "" {
+		return errors.New("cannot encrypt the private key with an empty passphrase")
+	}
+	pkb, err := base64.StdEncoding.DecodeString(i.PrivKey)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealKey(pkb, passphrase)
+	if err != nil {
+		return err
+	}
+	i.PrivKey = base64.StdEncoding.EncodeToString(sealed)
+	i.PrivKeyEncryption = KeyEncryptionV1
+	return nil
+}
+
+// DecryptPrivateKey replaces the encrypted private key with the key in the
+// clear.
+func (i *Identity) DecryptPrivateKey(passphrase string) error {
+	if !i.Encrypted() {
+		return nil
+	}
+	sk, err := i.DecodePrivateKey(passphrase)
+	if err != nil {
+		return err
+	}
+	pkb, err := sk.Bytes()
+	if err != nil {
+		return err
+	}
+	i.PrivKey = base64.StdEncoding.EncodeToString(pkb)
+	i.PrivKeyEncryption = ""
+	return nil
+}
+
+func sealKey(key []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, keySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := keyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(salt, nonce...)
+	return aead.Seal(out, nonce, key, nil), nil
+}
+
+func openKey(encryption string, sealed []byte, passphrase string) ([]byte, error) {
+	if encryption != KeyEncryptionV1 {
+		return nil, fmt.Errorf("unknown private key encryption %q", encryption)
+	}
+	if len(sealed) < keySaltSize {
+		return nil, errors.New("encrypted private key is truncated")
+	}
+	aead, err := keyCipher(passphrase, sealed[:keySaltSize])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[keySaltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted private key is truncated")
+	}
+
+	key, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return key, nil
+}
+
+func keyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2([]byte(passphrase), salt, keyIterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2 derives a key of keyLen bytes from the password, as in RFC 2898,
+// with HMAC-SHA256.
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	ic "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// from RFC 7914, section 11
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	dk := pbkdf2([]byte("passwd"), []byte("salt"), 1, 64)
+	if hex.EncodeToString(dk) != expected {
+		t.Fatalf("got %x", dk)
+	}
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	sk, _, err := ic.GenerateKeyPair(ic.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skb, err := sk.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := Identity{PrivKey: base64.StdEncoding.EncodeToString(skb)}
+
+	if err := id.EncryptPrivateKey("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if !id.Encrypted() {
+		t.Fatal("key should be encrypted")
+	}
+	if _, err := id.DecodePrivateKey(""); err != ErrKeyEncrypted {
+		t.Fatalf("expected ErrKeyEncrypted, got %v", err)
+	}
+	if _, err := id.DecodePrivateKey("hunter3"); err != ErrBadPassphrase {
+		t.Fatalf("expected ErrBadPassphrase, got %v", err)
+	}
+
+	decoded, err := id.DecodePrivateKey("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equals(sk) {
+		t.Fatal("decrypted key doesn't match")
+	}
+
+	if err := id.DecryptPrivateKey("hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if id.Encrypted() || id.PrivKey != base64.StdEncoding.EncodeToString(skb) {
+		t.Fatal("key should be back in the clear")
+	}
+}
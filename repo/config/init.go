@@ -34,25 +34,16 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 	conf := &Config{
 
 		// setup the node's default addresses.
-		// Note: two swarm listen addrs, one tcp, one utp.
-		Addresses: Addresses{
-			Swarm: []string{
-				"/ip4/0.0.0.0/tcp/4001",
-				// "/ip4/0.0.0.0/udp/4002/utp", // disabled for now.
-				"/ip6/::/tcp/4001",
-			},
-			API:     "/ip4/127.0.0.1/tcp/5001",
-			Gateway: "/ip4/127.0.0.1/tcp/8080",
-		},
+		Addresses: defaultAddresses(),
 
 		Bootstrap:        BootstrapPeerStrings(bootstrapPeers),
 		SupernodeRouting: *snr,
 		Datastore:        *ds,
 		Identity:         identity,
-		Discovery: Discovery{MDNS{
-			Enabled:  true,
-			Interval: 10,
-		}},
+		Discovery:        defaultDiscovery(),
+		Ipns: Ipns{
+			ResolveCacheSize: defaultResolveCacheSize,
+		},
 		Log: Log{
 			MaxSizeMB:  250,
 			MaxBackups: 1,
@@ -77,14 +68,44 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 	return conf, nil
 }
 
+// defaultAddresses returns the node's default addresses.
+func defaultAddresses() Addresses {
+	// Note: two swarm listen addrs, one tcp, one utp.
+	return Addresses{
+		Swarm: []string{
+			"/ip4/0.0.0.0/tcp/4001",
+			// "/ip4/0.0.0.0/udp/4002/utp", // disabled for now.
+			"/ip6/::/tcp/4001",
+		},
+		API:     "/ip4/127.0.0.1/tcp/5001",
+		Gateway: "/ip4/127.0.0.1/tcp/8080",
+	}
+}
+
+func defaultDiscovery() Discovery {
+	return Discovery{MDNS{
+		Enabled:  true,
+		Interval: 10,
+	}}
+}
+
+const (
+	defaultBloomFilterSize = 512 << 10
+	defaultHasLRUSize      = 64 << 10
+
+	defaultResolveCacheSize = 128
+)
+
 func datastoreConfig() (*Datastore, error) {
 	dspath, err := DataStorePath("")
 	if err != nil {
 		return nil, err
 	}
 	return &Datastore{
-		Path: dspath,
-		Type: "leveldb",
+		Path:            dspath,
+		Type:            "leveldb",
+		BloomFilterSize: defaultBloomFilterSize,
+		HasLRUSize:      defaultHasLRUSize,
 	}, nil
 }
 
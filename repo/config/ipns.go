@@ -3,4 +3,28 @@ package config
 type Ipns struct {
 	RepublishPeriod string
 	RecordLifetime  string
+
+	// RecordTTL is how long resolvers may cache the published records.
+	RecordTTL string `json:",omitempty"`
+
+	// ResolveCacheSize is the number of resolved names kept in memory, for
+	// as long as their records allow. Zero disables the cache.
+	ResolveCacheSize int `json:",omitempty"`
+
+	// Resolvers are the resolvers names are looked up with, in order, out
+	// of "static", "proquint", "dns" and "dht". Leaving one out disables
+	// it. Empty means all of them, in that order.
+	Resolvers []string `json:",omitempty"`
+
+	// StaticNames maps names to the paths the "static" resolver resolves
+	// them to.
+	StaticNames map[string]string `json:",omitempty"`
+
+	// HostsFile is a file of more names for the "static" resolver, with a
+	// name and a path on every line.
+	HostsFile string `json:",omitempty"`
+
+	// OfflinePublish makes 'ipfs name publish' succeed while the daemon
+	// has no peers, keeping the record locally until it is connected.
+	OfflinePublish bool `json:",omitempty"`
 }
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// splitKey splits a config key into its parts. Keys are either dotted, as in
+// "Datastore.Path", or json pointers, as in "/Datastore/Path".
+func splitKey(key string) []string {
+	if strings.HasPrefix(key, "/") {
+		return strings.Split(key[1:], "/")
+	}
+	return strings.Split(key, ".")
+}
+
+// lookup returns the key of m matching name, ignoring case like decoding
+// the config does.
+func lookup(m map[string]interface{}, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return name, false
+}
+
+// fieldType returns the type of the config value named by the given key
+// parts, or nil if Config doesn't describe it, as for keys added by hand.
+func fieldType(parts []string) reflect.Type {
+	t := reflect.TypeOf(Config{})
+	for _, part := range parts {
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := t.FieldByNameFunc(func(n string) bool {
+				return strings.EqualFold(n, part)
+			})
+			if !ok {
+				return nil
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return t
+}
+
+// GetKey returns the value of the given key in the serialized config m.
+func GetKey(m map[string]interface{}, key string) (interface{}, error) {
+	parts := splitKey(key)
+	var cursor interface{} = m
+	for i, part := range parts {
+		mcursor, ok := cursor.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s key is not a map", strings.Join(parts[:i], "."))
+		}
+		k, ok := lookup(mcursor, part)
+		if !ok {
+			return nil, fmt.Errorf("%s key has no attributes", strings.Join(parts[:i], "."))
+		}
+		cursor = mcursor[k]
+	}
+	return cursor, nil
+}
+
+// SetKey sets the given key in the serialized config m. The value is
+// checked against the type the Config struct has at that key: strings are
+// parsed into it, and other values must already fit. Values of keys Config
+// doesn't describe are guessed from strings, or set as given.
+func SetKey(m map[string]interface{}, key string, value interface{}) error {
+	parts := splitKey(key)
+
+	value, err := convertValue(fieldType(parts), value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %s", key, err)
+	}
+
+	cursor := m
+	for i, part := range parts {
+		k, _ := lookup(cursor, part)
+		if i == len(parts)-1 {
+			cursor[k] = value
+			break
+		}
+
+		next, ok := cursor[k].(map[string]interface{})
+		if !ok {
+			if cursor[k] != nil {
+				return fmt.Errorf("%s key is not a map", strings.Join(parts[:i+1], "."))
+			}
+			// create the map if it is missing or null
+			next = make(map[string]interface{})
+			cursor[k] = next
+		}
+		cursor = next
+	}
+	return nil
+}
+
+// convertValue returns value as it should be serialized for a config field
+// of type t.
+func convertValue(t reflect.Type, value interface{}) (interface{}, error) {
+	s, isString := value.(string)
+	if t == nil || t.Kind() == reflect.Interface {
+		if isString {
+			return guessValue(s), nil
+		}
+		return value, nil
+	}
+
+	var data []byte
+	if isString && t.Kind() != reflect.String {
+		// strings given for other types are their json encoding, which is
+		// also how numbers and booleans are written on the command line
+		data = []byte(s)
+	} else {
+		var err error
+		data, err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	typed := reflect.New(t)
+	if err := json.Unmarshal(data, typed.Interface()); err != nil {
+		return nil, fmt.Errorf("expected %s", t)
+	}
+
+	// round trip to the generic form the rest of the map is in
+	data, err := json.Marshal(typed.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func guessValue(s string) interface{} {
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestGetSetKey(t *testing.T) {
+	cfg := new(Config)
+	cfg.Datastore.Path = "/old"
+	m, err := ToMap(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"Datastore.Path", "datastore.path", "/Datastore/Path"} {
+		v, err := GetKey(m, key)
+		if err != nil {
+			t.Fatalf("%s: %s", key, err)
+		}
+		if v != "/old" {
+			t.Fatalf("%s: got %v", key, v)
+		}
+	}
+
+	sets := []struct {
+		key   string
+		value interface{}
+	}{
+		{"datastore.path", "/new"},
+		{"Datastore.BloomFilterSize", "1024"},
+		{"Discovery.MDNS.Enabled", "true"},
+		{"Bootstrap", `["/ip4/1.2.3.4/tcp/4001/ipfs/QmFoo"]`},
+		{"/Gateway/Writable", true},
+	}
+	for _, s := range sets {
+		if err := SetKey(m, s.key, s.value); err != nil {
+			t.Fatalf("setting %s: %s", s.key, err)
+		}
+	}
+
+	cfg, err = FromMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Datastore.Path != "/new" ||
+		cfg.Datastore.BloomFilterSize != 1024 ||
+		!cfg.Discovery.MDNS.Enabled ||
+		len(cfg.Bootstrap) != 1 ||
+		!cfg.Gateway.Writable {
+		t.Fatalf("config not updated as expected: %+v", cfg)
+	}
+	if _, ok := m["datastore"]; ok {
+		t.Fatal("setting a key with different case added a new key")
+	}
+}
+
+func TestSetKeyValidatesType(t *testing.T) {
+	m, err := ToMap(new(Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := []struct {
+		key   string
+		value interface{}
+	}{
+		{"Datastore.BloomFilterSize", "lots"},
+		{"Discovery.MDNS.Enabled", "maybe"},
+		{"Datastore.Path", 12},
+		{"Bootstrap", "/ip4/1.2.3.4"},
+	}
+	for _, b := range bad {
+		if err := SetKey(m, b.key, b.value); err == nil {
+			t.Errorf("setting %s to %v should have failed", b.key, b.value)
+		}
+	}
+
+	// keys the config doesn't know about are kept, with a guessed type
+	if err := SetKey(m, "Custom.Count", "3"); err != nil {
+		t.Fatal(err)
+	}
+	v, err := GetKey(m, "Custom.Count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != float64(3) {
+		t.Fatalf("expected 3, got %#v", v)
+	}
+}
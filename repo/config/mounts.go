@@ -4,5 +4,15 @@ package config
 type Mounts struct {
 	IPFS           string
 	IPNS           string
+	Files          string // the files root is only mounted when set
 	FuseAllowOther bool
+
+	// AutoMount mounts the mountpoints when the daemon starts, as
+	// 'ipfs daemon --mount' does.
+	AutoMount bool `json:",omitempty"`
+
+	// CheckPeriod is how often the daemon checks that its mounts still
+	// answer, mounting them again when they do not, e.g. "30s". Defaults
+	// to a minute, "0s" disables the checks.
+	CheckPeriod string `json:",omitempty"`
 }
@@ -0,0 +1,8 @@
+package config
+
+// Offline configures how the node behaves when it runs without the daemon.
+type Offline struct {
+	// DeferWants records the blocks asked for but not found while offline,
+	// and fetches them the next time the daemon runs.
+	DeferWants bool `json:",omitempty"`
+}
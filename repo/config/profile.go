@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Profile changes a group of settings to suit a kind of deployment, and can
+// set them back to their defaults.
+type Profile struct {
+	Description string
+	Apply       func(*Config) error
+	Revert      func(*Config) error
+}
+
+// Profiles are the profiles that can be applied by name.
+var Profiles = map[string]*Profile{
+	"server": {
+		Description: `Disables local discovery, and filters out connections to
+private networks, for nodes run by hosting providers who may flag
+such connections as port scanning.`,
+		Apply: func(c *Config) error {
+			c.Discovery.MDNS.Enabled = false
+			c.Swarm.AddrFilters = appendMissing(c.Swarm.AddrFilters, privateNetworks)
+			return nil
+		},
+		Revert: func(c *Config) error {
+			c.Discovery.MDNS.Enabled = true
+			c.Swarm.AddrFilters = removeAll(c.Swarm.AddrFilters, privateNetworks)
+			return nil
+		},
+	},
+
+	"lowpower": {
+		Description: `Reduces the work the node does in the background, for
+devices on batteries or with little memory: local discovery runs less
+often, only the roots of pins are announced to the network, and the
+blockstore caches are smaller.`,
+		Apply: func(c *Config) error {
+			c.Discovery.MDNS.Interval = 60
+			c.Bitswap.ProvideStrategy = "roots"
+			c.Datastore.BloomFilterSize = defaultBloomFilterSize / 8
+			c.Datastore.HasLRUSize = defaultHasLRUSize / 8
+			return nil
+		},
+		Revert: func(c *Config) error {
+			c.Discovery.MDNS.Interval = defaultDiscovery().MDNS.Interval
+			c.Bitswap.ProvideStrategy = ""
+			c.Datastore.BloomFilterSize = defaultBloomFilterSize
+			c.Datastore.HasLRUSize = defaultHasLRUSize
+			return nil
+		},
+	},
+
+	"test": {
+		Description: `Isolates the node for tests: it listens on random local
+ports, and doesn't bootstrap or discover other nodes.`,
+		Apply: func(c *Config) error {
+			c.Addresses = Addresses{
+				Swarm:   []string{"/ip4/127.0.0.1/tcp/0"},
+				API:     "/ip4/127.0.0.1/tcp/0",
+				Gateway: "/ip4/127.0.0.1/tcp/0",
+			}
+			c.Bootstrap = []string{}
+			c.Discovery.MDNS.Enabled = false
+			return nil
+		},
+		Revert: func(c *Config) error {
+			c.Addresses = defaultAddresses()
+			c.Bootstrap = append([]string(nil), DefaultBootstrapAddresses...)
+			c.Discovery.MDNS.Enabled = true
+			return nil
+		},
+	},
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func ProfileNames() []string {
+	var names []string
+	for n := range Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfiles applies the named profiles to c, in order.
+func ApplyProfiles(c *Config, names ...string) error {
+	for _, n := range names {
+		p, ok := Profiles[n]
+		if !ok {
+			return fmt.Errorf("unknown config profile %q", n)
+		}
+		if err := p.Apply(c); err != nil {
+			return fmt.Errorf("applying profile %s: %s", n, err)
+		}
+	}
+	return nil
+}
+
+// RevertProfiles reverts the named profiles in c, in reverse order.
+func RevertProfiles(c *Config, names ...string) error {
+	for i := len(names) - 1; i >= 0; i-- {
+		n := names[i]
+		p, ok := Profiles[n]
+		if !ok {
+			return fmt.Errorf("unknown config profile %q", n)
+		}
+		if err := p.Revert(c); err != nil {
+			return fmt.Errorf("reverting profile %s: %s", n, err)
+		}
+	}
+	return nil
+}
+
+// privateNetworks are the address filters of the server profile.
+var privateNetworks = []string{
+	"/ip4/10.0.0.0/ipcidr/8",
+	"/ip4/100.64.0.0/ipcidr/10",
+	"/ip4/169.254.0.0/ipcidr/16",
+	"/ip4/172.16.0.0/ipcidr/12",
+	"/ip4/192.0.0.0/ipcidr/24",
+	"/ip4/192.0.0.0/ipcidr/29",
+	"/ip4/192.0.0.8/ipcidr/32",
+	"/ip4/192.0.0.170/ipcidr/32",
+	"/ip4/192.0.0.171/ipcidr/32",
+	"/ip4/192.0.2.0/ipcidr/24",
+	"/ip4/192.168.0.0/ipcidr/16",
+	"/ip4/198.18.0.0/ipcidr/15",
+	"/ip4/198.51.100.0/ipcidr/24",
+	"/ip4/203.0.113.0/ipcidr/24",
+	"/ip4/240.0.0.0/ipcidr/4",
+}
+
+func appendMissing(list, add []string) []string {
+	have := make(map[string]bool)
+	for _, s := range list {
+		have[s] = true
+	}
+	for _, s := range add {
+		if !have[s] {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func removeAll(list, remove []string) []string {
+	drop := make(map[string]bool)
+	for _, s := range remove {
+		drop[s] = true
+	}
+	var out []string
+	for _, s := range list {
+		if !drop[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func defaultTestConfig() *Config {
+	return &Config{
+		Addresses: defaultAddresses(),
+		Discovery: defaultDiscovery(),
+		Bootstrap: append([]string(nil), DefaultBootstrapAddresses...),
+		Datastore: Datastore{
+			BloomFilterSize: defaultBloomFilterSize,
+			HasLRUSize:      defaultHasLRUSize,
+		},
+		Swarm: SwarmConfig{AddrFilters: []string{"/ip4/1.2.3.0/ipcidr/24"}},
+	}
+}
+
+func TestProfilesRevert(t *testing.T) {
+	for _, name := range ProfileNames() {
+		c := defaultTestConfig()
+		if err := ApplyProfiles(c, name); err != nil {
+			t.Fatal(err)
+		}
+		if reflect.DeepEqual(c, defaultTestConfig()) {
+			t.Errorf("profile %s changed nothing", name)
+		}
+
+		// applying twice is the same as applying once
+		once := *c
+		if err := ApplyProfiles(c, name); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, &once) {
+			t.Errorf("applying profile %s twice changed the config again", name)
+		}
+
+		if err := RevertProfiles(c, name); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, defaultTestConfig()) {
+			t.Errorf("reverting profile %s did not restore the defaults:\n%+v\n%+v", name, c, defaultTestConfig())
+		}
+	}
+}
+
+func TestServerProfile(t *testing.T) {
+	c := defaultTestConfig()
+	if err := ApplyProfiles(c, "server"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Discovery.MDNS.Enabled {
+		t.Error("server profile should disable mdns")
+	}
+	if len(c.Swarm.AddrFilters) != len(privateNetworks)+1 {
+		t.Errorf("expected %d address filters, got %d", len(privateNetworks)+1, len(c.Swarm.AddrFilters))
+	}
+
+	if err := ApplyProfiles(c, "bogus"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
@@ -0,0 +1,14 @@
+package config
+
+// Reprovider configures how the node announces again the blocks it
+// provides, as the provider records it put in the routing system expire.
+type Reprovider struct {
+	// Interval is the time between reprovides, "12h" by default. "0"
+	// disables them, only 'ipfs bitswap reprovide' runs one then.
+	Interval string `json:",omitempty"`
+
+	// Strategy decides which blocks are announced again: "all", "pinned"
+	// for the pinned blocks, "roots" for the roots of pins, or "none".
+	// It defaults to Bitswap.ProvideStrategy.
+	Strategy string `json:",omitempty"`
+}
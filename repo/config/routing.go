@@ -0,0 +1,49 @@
+package config
+
+// Routing configures how the node finds providers, peers and values.
+type Routing struct {
+	// Type is the routing system: "dht", the default, "supernode", through
+	// the SupernodeRouting servers, "delegated", through the API of the
+	// Delegated routers, or "composed", the composition of Routers.
+	// 'ipfs daemon --routing' overrides it.
+	Type string `json:",omitempty"`
+
+	// Delegated lists the API addresses of the nodes the "delegated"
+	// routing sends its queries to, such as "/ip4/10.0.0.1/tcp/5001",
+	// tried in order.
+	Delegated []string `json:",omitempty"`
+
+	// DelegatedAuth is the AuthSecret of an API.Authorizations entry of the
+	// delegated routers, when they require one.
+	DelegatedAuth string `json:",omitempty"`
+
+	// DHTMode is the part the node takes in the DHT: "server", the default,
+	// answering the queries of peers, "client", only sending queries, or
+	// "auto", a client until peers confirm they can dial the node back.
+	DHTMode string `json:",omitempty"`
+
+	// Routers is the composition of routing systems the "composed" routing
+	// goes through.
+	Routers *Router `json:",omitempty"`
+}
+
+// Router is a routing system of a composition.
+type Router struct {
+	// Type is "dht", "delegated", through the Routing.Delegated routers,
+	// "local", the peers the node is connected to, "static", the Providers
+	// lists, or a composition of the Routers: "parallel", all queried at
+	// once, "sequential", queried in turn until one finds something, or
+	// "tiered", queried in turn until enough is found.
+	Type string
+
+	// Timeout bounds the queries to the router, e.g. "10s".
+	Timeout string `json:",omitempty"`
+
+	// Routers are the routers of a composition.
+	Routers []Router `json:",omitempty"`
+
+	// Providers are the lists of a static router, from base58 keys, or "*"
+	// for every key, to the addresses of their providers, such as
+	// "/ip4/10.0.0.2/tcp/4001/ipfs/<peer id>".
+	Providers map[string][]string `json:",omitempty"`
+}
@@ -0,0 +1,351 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	humanize "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/dustin/go-humanize"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	iaddr "github.com/ipfs/go-ipfs/util/ipfsaddr"
+)
+
+// ValidationError is a problem with the config value at Path, a dotted key
+// with the indexes of list elements, such as "Addresses.Swarm[1]".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors are all the problems found in a config.
+type ValidationErrors []*ValidationError
+
+func (es ValidationErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return "invalid config:\n  " + strings.Join(msgs, "\n  ")
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) multiaddr(path, s string) ma.Multiaddr {
+	a, err := ma.NewMultiaddr(s)
+	if err != nil {
+		v.errorf(path, "invalid multiaddr %q: %s", s, err)
+		return nil
+	}
+	return a
+}
+
+// listenAddr checks an address that an HTTP server listens on.
+func (v *validator) listenAddr(path, s string) {
+	a := v.multiaddr(path, s)
+	if a == nil {
+		return
+	}
+	for _, p := range a.Protocols() {
+		if p.Code == ma.P_TCP {
+			return
+		}
+	}
+	v.errorf(path, "%q has no tcp port", s)
+}
+
+func (v *validator) peerAddr(path, s string) {
+	if _, err := iaddr.ParseString(s); err != nil {
+		v.errorf(path, "invalid peer address %q: %s", s, err)
+	}
+}
+
+func (v *validator) duration(path, s string) {
+	if s == "" {
+		return
+	}
+	if d, err := time.ParseDuration(s); err != nil {
+		v.errorf(path, "invalid duration %q, expected e.g. \"1h30m\"", s)
+	} else if d < 0 {
+		v.errorf(path, "duration %q is negative", s)
+	}
+}
+
+func (v *validator) size(path, s string) {
+	if s == "" {
+		return
+	}
+	if _, err := humanize.ParseBytes(s); err != nil {
+		v.errorf(path, "invalid size %q, expected e.g. \"10GB\"", s)
+	}
+}
+
+func (v *validator) oneOf(path, s string, allowed ...string) {
+	for _, a := range allowed {
+		if s == a {
+			return
+		}
+	}
+	v.errorf(path, "%q is not one of %q", s, allowed)
+}
+
+func (v *validator) nonNegative(path string, n int64) {
+	if n < 0 {
+		v.errorf(path, "must not be negative, is %d", n)
+	}
+}
+
+// router checks the router r of a composition, and the routers it is made
+// of, counting the dht routers in dhts.
+func (v *validator) router(path string, r *Router, rc *Routing, dhts *int) {
+	v.oneOf(path+".Type", r.Type, "dht", "delegated", "local", "static", "parallel", "sequential", "tiered")
+	v.duration(path+".Timeout", r.Timeout)
+
+	switch r.Type {
+	case "dht":
+		*dhts++
+	case "delegated":
+		if len(rc.Delegated) == 0 {
+			v.errorf(path, "a delegated router, but Routing.Delegated is missing")
+		}
+	case "parallel", "sequential", "tiered":
+		if len(r.Routers) == 0 {
+			v.errorf(path+".Routers", "missing, but %s is a composition", path)
+		}
+	}
+	if len(r.Routers) > 0 {
+		switch r.Type {
+		case "parallel", "sequential", "tiered":
+		default:
+			v.errorf(path+".Routers", "only compositions have routers, not %q", r.Type)
+		}
+	}
+	for i := range r.Routers {
+		v.router(fmt.Sprintf("%s.Routers[%d]", path, i), &r.Routers[i], rc, dhts)
+	}
+
+	for k, addrs := range r.Providers {
+		if k != "*" {
+			if _, err := mh.FromB58String(k); err != nil {
+				v.errorf(path+".Providers", "invalid key %q", k)
+			}
+		}
+		for i, a := range addrs {
+			v.peerAddr(fmt.Sprintf("%s.Providers.%s[%d]", path, k, i), a)
+		}
+	}
+}
+
+// Validate checks the values of the config, returning ValidationErrors
+// naming every invalid one. Empty values, meaning the defaults, are valid.
+func Validate(c *Config) error {
+	var v validator
+
+	id := c.Identity
+	switch {
+	case id.PeerID == "" && id.PrivKey != "":
+		v.errorf("Identity.PeerID", "missing, but Identity.PrivKey is set")
+	case id.PeerID != "" && id.PrivKey == "":
+		v.errorf("Identity.PrivKey", "missing, but Identity.PeerID is set")
+	}
+	if id.PeerID != "" {
+		if _, err := mh.FromB58String(id.PeerID); err != nil {
+			v.errorf("Identity.PeerID", "invalid peer id %q", id.PeerID)
+		}
+	}
+	if _, err := base64.StdEncoding.DecodeString(id.PrivKey); err != nil {
+		v.errorf("Identity.PrivKey", "not base64 encoded")
+	}
+	v.oneOf("Identity.PrivKeyEncryption", id.PrivKeyEncryption, "", KeyEncryptionV1)
+
+	for i, a := range c.Addresses.Swarm {
+		v.multiaddr(fmt.Sprintf("Addresses.Swarm[%d]", i), a)
+	}
+	if c.Addresses.API != "" {
+		v.listenAddr("Addresses.API", c.Addresses.API)
+	}
+	if c.Addresses.Gateway != "" {
+		v.listenAddr("Addresses.Gateway", c.Addresses.Gateway)
+	}
+	for name, a := range c.API.Authorizations {
+		path := "API.Authorizations." + name
+		if _, err := AuthorizationHeader(a.AuthSecret); err != nil {
+			v.errorf(path+".AuthSecret", "%s", err)
+		}
+		for i, p := range a.AllowedPaths {
+			if !strings.HasPrefix(p, "/") {
+				v.errorf(fmt.Sprintf("%s.AllowedPaths[%d]", path, i), "must start with /, is %q", p)
+			}
+		}
+		for i, p := range a.DeniedPaths {
+			if !strings.HasPrefix(p, "/") {
+				v.errorf(fmt.Sprintf("%s.DeniedPaths[%d]", path, i), "must start with /, is %q", p)
+			}
+		}
+	}
+	for host, gw := range c.Gateway.PublicGateways {
+		path := "Gateway.PublicGateways." + host
+		if host == "" || strings.ContainsAny(host, ":/") {
+			v.errorf(path, "invalid hostname %q", host)
+		}
+		for i, p := range gw.Paths {
+			if !strings.HasPrefix(p, "/") {
+				v.errorf(fmt.Sprintf("%s.Paths[%d]", path, i), "must start with /, is %q", p)
+			}
+		}
+		if gw.RootPath != "" && !strings.HasPrefix(gw.RootPath, "/ipfs/") && !strings.HasPrefix(gw.RootPath, "/ipns/") {
+			v.errorf(path+".RootPath", "must be an /ipfs/ or /ipns/ path, is %q", gw.RootPath)
+		}
+		if gw.RootPath != "" && gw.UseSubdomains {
+			v.errorf(path, "cannot have both a RootPath and UseSubdomains")
+		}
+	}
+	for i, cmd := range c.Gateway.APICommands {
+		if cmd == "" || strings.HasPrefix(cmd, "/") {
+			v.errorf(fmt.Sprintf("Gateway.APICommands[%d]", i), "must be a command path such as object/get, is %q", cmd)
+		}
+	}
+	for i, a := range c.Bootstrap {
+		v.peerAddr(fmt.Sprintf("Bootstrap[%d]", i), a)
+	}
+	for i, a := range c.SupernodeRouting.Servers {
+		v.peerAddr(fmt.Sprintf("SupernodeRouting.Servers[%d]", i), a)
+	}
+	v.oneOf("Routing.Type", c.Routing.Type, "", "dht", "supernode", "delegated", "composed")
+	if c.Routing.Type == "delegated" && len(c.Routing.Delegated) == 0 {
+		v.errorf("Routing.Delegated", "missing, but Routing.Type is delegated")
+	}
+	if c.Routing.Type == "composed" && c.Routing.Routers == nil {
+		v.errorf("Routing.Routers", "missing, but Routing.Type is composed")
+	}
+	if c.Routing.Routers != nil {
+		var dhts int
+		v.router("Routing.Routers", c.Routing.Routers, &c.Routing, &dhts)
+		if dhts > 1 {
+			v.errorf("Routing.Routers", "has %d dht routers, the node runs one DHT", dhts)
+		}
+	}
+	for i, a := range c.Routing.Delegated {
+		v.listenAddr(fmt.Sprintf("Routing.Delegated[%d]", i), a)
+	}
+	if c.Routing.DelegatedAuth != "" {
+		if _, err := AuthorizationHeader(c.Routing.DelegatedAuth); err != nil {
+			v.errorf("Routing.DelegatedAuth", "%s", err)
+		}
+	}
+	v.oneOf("Routing.DHTMode", c.Routing.DHTMode, "", "server", "client", "auto")
+
+	d := c.Datastore
+	for i, m := range d.Mounts {
+		path := fmt.Sprintf("Datastore.Mounts[%d]", i)
+		if !strings.HasPrefix(m.Prefix, "/") {
+			v.errorf(path+".Prefix", "must start with /, is %q", m.Prefix)
+		}
+		if m.Type == "" {
+			v.errorf(path+".Type", "missing")
+		}
+		if m.Path == "" && m.Type != "mem" {
+			v.errorf(path+".Path", "missing")
+		}
+	}
+	v.nonNegative("Datastore.BloomFilterSize", int64(d.BloomFilterSize))
+	v.nonNegative("Datastore.HasLRUSize", int64(d.HasLRUSize))
+	v.oneOf("Datastore.BlockCompression", d.BlockCompression, "", "none", "snappy", "zlib")
+	v.size("Datastore.StorageMax", d.StorageMax)
+	if d.StorageGCWatermark < 0 || d.StorageGCWatermark > 100 {
+		v.errorf("Datastore.StorageGCWatermark", "must be a percentage, is %d", d.StorageGCWatermark)
+	}
+	v.duration("Datastore.GCPeriod", d.GCPeriod)
+	v.duration("Mounts.CheckPeriod", c.Mounts.CheckPeriod)
+
+	v.nonNegative("Discovery.MDNS.Interval", int64(c.Discovery.MDNS.Interval))
+	v.duration("Ipns.RepublishPeriod", c.Ipns.RepublishPeriod)
+	v.duration("Ipns.RecordLifetime", c.Ipns.RecordLifetime)
+	v.duration("Ipns.RecordTTL", c.Ipns.RecordTTL)
+	v.nonNegative("Ipns.ResolveCacheSize", int64(c.Ipns.ResolveCacheSize))
+	for i, r := range c.Ipns.Resolvers {
+		v.oneOf(fmt.Sprintf("Ipns.Resolvers[%d]", i), r, "static", "proquint", "dns", "dht")
+	}
+
+	for i, s := range c.DNS.Servers {
+		if s == "" {
+			v.errorf(fmt.Sprintf("DNS.Servers[%d]", i), "missing")
+		}
+	}
+	if e := c.DNS.HTTPSEndpoint; e != "" {
+		if u, err := url.Parse(e); err != nil || u.Scheme != "https" || u.Host == "" {
+			v.errorf("DNS.HTTPSEndpoint", "invalid https url %q", e)
+		}
+	}
+
+	b := c.Bitswap
+	v.oneOf("Bitswap.Strategy", b.Strategy, "", "roundrobin", "debtratio")
+	v.oneOf("Bitswap.ProvideStrategy", b.ProvideStrategy, "", "all", "roots", "pinned", "none")
+	v.size("Bitswap.UploadLimit", b.UploadLimit)
+	v.size("Bitswap.DownloadLimit", b.DownloadLimit)
+	v.size("Bitswap.PeerUploadLimit", b.PeerUploadLimit)
+	v.size("Bitswap.PeerDownloadLimit", b.PeerDownloadLimit)
+
+	v.duration("Reprovider.Interval", c.Reprovider.Interval)
+	v.oneOf("Reprovider.Strategy", c.Reprovider.Strategy, "", "all", "roots", "pinned", "none")
+
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// UnknownKeys returns the keys of the serialized config m that Config has
+// no field for, sorted. They are kept when the config is written, but
+// usually are misspellings.
+func UnknownKeys(m map[string]interface{}) []string {
+	var unknown []string
+	unknownKeys(reflect.TypeOf(Config{}), m, "", &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+func unknownKeys(t reflect.Type, v interface{}, path string, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, fv := range m {
+			f, ok := t.FieldByNameFunc(func(n string) bool {
+				return strings.EqualFold(n, k)
+			})
+			if !ok {
+				*unknown = append(*unknown, path+k)
+				continue
+			}
+			unknownKeys(f.Type, fv, path+k+".", unknown)
+		}
+	case reflect.Slice:
+		l, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for i, e := range l {
+			unknownKeys(t.Elem(), e, fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i), unknown)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(defaultTestConfig()); err != nil {
+		t.Fatal("default config should be valid:", err)
+	}
+
+	c := defaultTestConfig()
+	c.Addresses.API = "/ip4/127.0.0.1/udp/5001"
+	c.Addresses.Swarm = append(c.Addresses.Swarm, "/ip4/127.0.0.1/tcp/70000")
+	c.API.Authorizations = map[string]APIAuthorization{"app": {AuthSecret: "token:foo", AllowedPaths: []string{"/api/v0/cat"}}}
+	c.Gateway.PublicGateways = map[string]GatewaySpec{"example.com": {RootPath: "Qmfoo"}}
+	c.Gateway.APICommands = []string{"cat", "/ls"}
+	c.Bootstrap = []string{"/ip4/1.2.3.4/tcp/4001"}
+	c.Routing.Type = "delegated"
+	c.Routing.Routers = &Router{Type: "tiered", Routers: []Router{
+		{Type: "dht"},
+		{Type: "static", Timeout: "soon", Providers: map[string][]string{"*": {"/ip4/10.0.0.2/tcp/4001"}}},
+	}}
+	c.Routing.DHTMode = "nat"
+	c.Datastore.StorageGCWatermark = 120
+	c.Ipns.RepublishPeriod = "daily"
+	c.DNS.HTTPSEndpoint = "http://dns.example.com/dns-query"
+	c.Bitswap.ProvideStrategy = "some"
+	c.Reprovider.Interval = "-1h"
+
+	err := Validate(c)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	expected := []string{
+		"Addresses.Swarm[2]",
+		"Addresses.API",
+		"API.Authorizations.app.AuthSecret",
+		"Gateway.PublicGateways.example.com.RootPath",
+		"Gateway.APICommands[1]",
+		"Bootstrap[0]",
+		"Routing.Delegated",
+		"Routing.Routers.Routers[1].Timeout",
+		"Routing.Routers.Routers[1].Providers.*[0]",
+		"Routing.DHTMode",
+		"Datastore.StorageGCWatermark",
+		"Ipns.RepublishPeriod",
+		"DNS.HTTPSEndpoint",
+		"Bitswap.ProvideStrategy",
+		"Reprovider.Interval",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected errors at %v, got:\n%s", expected, err)
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	m, err := ToMap(defaultTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unknown := UnknownKeys(m); len(unknown) != 0 {
+		t.Fatalf("expected no unknown keys, got %v", unknown)
+	}
+
+	if err := SetKey(m, "Discovery.MDNS.Interval", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetKey(m, "Discovery.Mdns.Intervall", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetKey(m, "Gatway.Writable", true); err != nil {
+		t.Fatal(err)
+	}
+	m["Datastore"].(map[string]interface{})["Mounts"] = []interface{}{
+		map[string]interface{}{"Prefix": "/", "Type": "mem", "Size": 1},
+	}
+
+	expected := []string{"Datastore.Mounts[0].Size", "Discovery.MDNS.Intervall", "Gatway"}
+	if unknown := UnknownKeys(m); !reflect.DeepEqual(unknown, expected) {
+		t.Fatalf("expected %v, got %v", expected, unknown)
+	}
+}
@@ -0,0 +1,164 @@
+package fsrepo
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	levelds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/leveldb"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/measure"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/mount"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	ldbopts "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/syndtr/goleveldb/leveldb/opt"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/repo/fsrepo/flatfs"
+)
+
+// 4TB of 256kB objects ~=17M objects, splitting that 256-way
+// leads to ~66k objects per dir, splitting 256*256-way leads to
+// only 256.
+//
+// The keys seen by the block store have predictable prefixes,
+// including "/" from datastore.Key and 2 bytes from multihash. To
+// reach a uniform 256-way split, we need approximately 4 bytes of
+// prefix.
+const defaultFlatfsPrefixLen = 4
+
+// defaultDatastoreMounts is the layout used by repos that don't configure
+// their own.
+func defaultDatastoreMounts() []config.DatastoreMount {
+	return []config.DatastoreMount{
+		{
+			Prefix: "/blocks",
+			Type:   "flatfs",
+			Path:   flatfsDirectory,
+			Params: map[string]string{"prefixLen": strconv.Itoa(defaultFlatfsPrefixLen)},
+		},
+		{
+			Prefix: "/",
+			Type:   "leveldb",
+			Path:   leveldbDirectory,
+		},
+	}
+}
+
+func datastoreMounts(c *config.Config) []config.DatastoreMount {
+	if len(c.Datastore.Mounts) == 0 {
+		return defaultDatastoreMounts()
+	}
+	return c.Datastore.Mounts
+}
+
+// constructor builds a datastore of a given type, stored under the given
+// absolute path.
+type constructor func(dspath string, params map[string]string) (ds.Batching, error)
+
+var datastoreConstructors = map[string]constructor{
+	"leveldb": openLeveldb,
+	"flatfs":  openFlatfs,
+	"mem":     openMem,
+}
+
+func openLeveldb(dspath string, params map[string]string) (ds.Batching, error) {
+	opts := &levelds.Options{Compression: ldbopts.NoCompression}
+	switch params["compression"] {
+	case "", "none":
+	case "snappy":
+		opts.Compression = ldbopts.SnappyCompression
+	default:
+		return nil, fmt.Errorf("unknown leveldb compression '%s'", params["compression"])
+	}
+
+	d, err := levelds.NewDatastore(dspath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open leveldb datastore: %s", err)
+	}
+	return d, nil
+}
+
+func openFlatfs(dspath string, params map[string]string) (ds.Batching, error) {
+	shard, err := flatfsShardFunc(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// the repo is locked, so the datastore can be resharded in place if
+	// its sharding function was changed in the config
+	d, err := flatfs.CreateOrOpen(dspath, shard)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open flatfs datastore: %s", err)
+	}
+	return d, nil
+}
+
+// flatfsShardFunc returns the sharding function set by the "shardFunc"
+// param, e.g. "/repo/flatfs/shard/v1/next-to-last/2", or else a prefix of
+// "prefixLen" bytes of the keys.
+func flatfsShardFunc(params map[string]string) (*flatfs.ShardIdV1, error) {
+	if s, ok := params["shardFunc"]; ok {
+		return flatfs.ParseShardFunc(s)
+	}
+
+	prefixLen := defaultFlatfsPrefixLen
+	if s, ok := params["prefixLen"]; ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid flatfs prefixLen '%s'", s)
+		}
+		prefixLen = n
+	}
+	// the prefix is taken from the hex encoded keys
+	return flatfs.Prefix(prefixLen * 2), nil
+}
+
+func openMem(string, map[string]string) (ds.Batching, error) {
+	return dssync.MutexWrap(ds.NewMapDatastore()), nil
+}
+
+// openMounts builds all the datastores described by the given mounts, and
+// mounts them together into a single datastore. metricsPrefix is prepended
+// to the name of the metrics collected for each datastore. readOnly
+// datastores reject writes, and those that can't be opened fail every
+// operation instead of failing the open.
+func openMounts(repoPath string, mounts []config.DatastoreMount, metricsPrefix string, readOnly bool) (*mount.Datastore, error) {
+	var built []mount.Mount
+	closeAll := func() {
+		for _, m := range built {
+			m.Datastore.(io.Closer).Close()
+		}
+	}
+
+	for _, m := range mounts {
+		ctor, ok := datastoreConstructors[m.Type]
+		if !ok {
+			closeAll()
+			return nil, fmt.Errorf("unknown datastore type '%s' for %s", m.Type, m.Prefix)
+		}
+
+		d, err := ctor(path.Join(repoPath, m.Path), m.Params)
+		switch {
+		case err != nil && readOnly:
+			log.Warningf("datastore %s unavailable read-only: %s", m.Prefix, err)
+			d = unavailableDatastore{err: err}
+		case err != nil:
+			closeAll()
+			return nil, err
+		case readOnly:
+			d = readOnlyDatastore{d}
+		}
+
+		name := strings.Trim(m.Prefix, "/")
+		if name == "" {
+			name = m.Type
+		}
+
+		built = append(built, mount.Mount{
+			Prefix:    ds.NewKey(m.Prefix),
+			Datastore: measure.New(metricsPrefix+name, d),
+		})
+	}
+	return mount.New(built), nil
+}
@@ -0,0 +1,244 @@
+package fsrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	serialize "github.com/ipfs/go-ipfs/repo/fsrepo/serialize"
+)
+
+// The archives written by Export are tar files holding, in order, the repo
+// version, the config, every key of the keystore under keystorePrefix, and
+// every datastore entry under datastorePrefix followed by its key. Pins and
+// IPNS records live in the datastore next to the blocks, and the private
+// key in the config.
+const (
+	versionEntry    = "version"
+	configEntry     = "config"
+	keystorePrefix  = "keystore/"
+	datastorePrefix = "datastore"
+)
+
+// Export writes an archive of the repo at the given path to w, from which
+// Import rebuilds it. The repo must not be open, so the archive is
+// consistent.
+func Export(repoPath string, w io.Writer) error {
+	rr, err := open(repoPath, Options{})
+	if err != nil {
+		return fmt.Errorf("cannot export the repo: %s (is the daemon running?)", err)
+	}
+	defer rr.Close()
+	r := rr.(*FSRepo)
+
+	configFilename, err := config.Filename(r.path)
+	if err != nil {
+		return err
+	}
+	conf, err := ioutil.ReadFile(configFilename)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeEntry(tw, versionEntry, []byte(RepoVersion)); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, configEntry, conf); err != nil {
+		return err
+	}
+	if err := exportKeys(tw, r.keys); err != nil {
+		return err
+	}
+
+	// the mounted datastore can only list one mount at a time, and some
+	// datastores only list keys
+	for _, m := range datastoreMounts(r.config) {
+		res, err := r.ds.Query(dsq.Query{Prefix: m.Prefix, KeysOnly: true})
+		if err != nil {
+			return err
+		}
+		err = exportEntries(tw, r.ds, res)
+		res.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func exportKeys(tw *tar.Writer, ks keystore.Keystore) error {
+	names, err := ks.List()
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		sk, err := ks.Get(n)
+		if err != nil {
+			return err
+		}
+		b, err := ci.MarshalPrivateKey(sk)
+		if err != nil {
+			return err
+		}
+		if err := writeEntry(tw, keystorePrefix+n, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportEntries(tw *tar.Writer, d ds.Datastore, res dsq.Results) error {
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		v, err := d.Get(ds.NewKey(e.Key))
+		if err != nil {
+			return err
+		}
+		b, ok := v.([]byte)
+		if !ok {
+			return fmt.Errorf("datastore value for %s is not bytes, cannot export it", e.Key)
+		}
+		if err := writeEntry(tw, datastorePrefix+e.Key, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0600,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import creates a repo at the given path from an archive written by
+// Export. There must not be a repo there already.
+func Import(repoPath string, rd io.Reader) (err error) {
+	if IsInitialized(repoPath) {
+		return fmt.Errorf("cannot import into %s, a repo already exists there", repoPath)
+	}
+	if _, statErr := os.Stat(repoPath); os.IsNotExist(statErr) {
+		// don't leave half a repo behind
+		defer func() {
+			if err != nil {
+				os.RemoveAll(repoPath)
+			}
+		}()
+	}
+
+	tr := tar.NewReader(rd)
+	ver, err := readEntry(tr, versionEntry)
+	if err != nil {
+		return err
+	}
+	if string(ver) != RepoVersion {
+		return fmt.Errorf("the archive holds a version %s repo, expected version %s", ver, RepoVersion)
+	}
+	conf, err := readEntry(tr, configEntry)
+	if err != nil {
+		return err
+	}
+	if err := importConfig(repoPath, conf); err != nil {
+		return err
+	}
+
+	rr, err := open(repoPath, Options{})
+	if err != nil {
+		return err
+	}
+	defer rr.Close()
+	d := rr.Datastore()
+	ks := rr.Keystore()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.HasPrefix(hdr.Name, datastorePrefix+"/"):
+			err = d.Put(ds.NewKey(strings.TrimPrefix(hdr.Name, datastorePrefix)), v)
+		case strings.HasPrefix(hdr.Name, keystorePrefix):
+			var sk ci.PrivKey
+			if sk, err = ci.UnmarshalPrivateKey(v); err == nil {
+				err = ks.Put(strings.TrimPrefix(hdr.Name, keystorePrefix), sk)
+			}
+		default:
+			err = fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readEntry reads the next entry of the archive, which must have the given
+// name.
+func readEntry(tr *tar.Reader, name string) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, errors.New("the archive is empty or truncated")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Name != name {
+		return nil, fmt.Errorf("expected the %s in the archive, found %q", name, hdr.Name)
+	}
+	return ioutil.ReadAll(tr)
+}
+
+// importConfig initializes the repo with the config from the archive,
+// keeping the keys Config doesn't know about.
+func importConfig(repoPath string, data []byte) error {
+	var mapconf map[string]interface{}
+	if err := json.NewDecoder(bytes.NewReader(serialize.StripComments(data))).Decode(&mapconf); err != nil {
+		return fmt.Errorf("the config in the archive is invalid: %s", err)
+	}
+	conf, err := config.FromMap(mapconf)
+	if err != nil {
+		return fmt.Errorf("the config in the archive is invalid: %s", err)
+	}
+
+	configFilename, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
+		return err
+	}
+	// the config written above is kept
+	return Init(repoPath, conf)
+}
@@ -0,0 +1,122 @@
+package flatfs
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-os-rename"
+)
+
+// Reshard lays out the flatfs directory at the given path according to a new
+// sharding function, moving every data file into place and recording the
+// new function in the SHARDING file. Files are moved without being copied,
+// so the directory must not be in use while it is being resharded.
+//
+// Files are found by scanning all shard directories, whatever the old
+// sharding was, so an interrupted migration is completed by running it
+// again with the same function. Until then the directory can't be opened
+// with either function, as the SHARDING file is only updated at the end.
+func Reshard(dir string, shard *ShardIdV1) error {
+	root, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	shards, err := root.Readdir(0)
+	root.Close()
+	if err != nil {
+		return err
+	}
+
+	var emptied []string
+	for _, fi := range shards {
+		if !fi.IsDir() || fi.Name()[0] == '.' {
+			continue
+		}
+		moved, err := reshardDir(dir, fi.Name(), shard)
+		if err != nil {
+			return err
+		}
+		if moved {
+			emptied = append(emptied, fi.Name())
+		}
+	}
+
+	// old shard directories that aren't used by the new layout are left
+	// empty, remove them.
+	for _, name := range emptied {
+		if err := removeIfEmpty(path.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	if err := syncDir(dir); err != nil {
+		return err
+	}
+	return WriteShardFunc(dir, shard)
+}
+
+// reshardDir moves the data files in the given shard directory to where the
+// new sharding function places them, and returns whether any were moved.
+func reshardDir(root, name string, shard *ShardIdV1) (bool, error) {
+	d, err := os.Open(path.Join(root, name))
+	if err != nil {
+		return false, err
+	}
+	files, err := d.Readdir(0)
+	d.Close()
+	if err != nil {
+		return false, err
+	}
+
+	moved := false
+	dirs := make(map[string]struct{})
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(fi.Name(), extension) {
+			continue
+		}
+		safe := strings.TrimSuffix(fi.Name(), extension)
+		if _, err := hex.DecodeString(safe); err != nil {
+			continue
+		}
+
+		target := shard.fun(safe)
+		if target == name {
+			continue
+		}
+
+		newDir := path.Join(root, target)
+		if err := os.Mkdir(newDir, 0777); err != nil && !os.IsExist(err) {
+			return false, err
+		}
+		err := osrename.Rename(path.Join(root, name, fi.Name()), path.Join(newDir, fi.Name()))
+		if err != nil {
+			return false, err
+		}
+		moved = true
+		dirs[newDir] = struct{}{}
+	}
+
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return false, err
+		}
+	}
+	return moved, nil
+}
+
+func removeIfEmpty(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	_, err = d.Readdirnames(1)
+	d.Close()
+	if err != io.EOF {
+		// not empty, or unreadable
+		return err
+	}
+	return os.Remove(dir)
+}
@@ -0,0 +1,151 @@
+package flatfs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-os-rename"
+)
+
+// ShardingFile is the name of the file, kept in the root of a flatfs
+// directory, that records the sharding function the directory was laid out
+// with.
+const ShardingFile = "SHARDING"
+
+const shardPrefix = "/repo/flatfs/shard/v1/"
+
+var ErrNoSharding = errors.New("flatfs directory has no " + ShardingFile + " file")
+
+// ShardFunc maps the hex encoded name of a key to the directory holding it.
+type ShardFunc func(name string) string
+
+// ShardIdV1 identifies a sharding function, and serializes as a path such as
+// "/repo/flatfs/shard/v1/next-to-last/2".
+type ShardIdV1 struct {
+	funName string
+	param   int
+	fun     ShardFunc
+}
+
+// Prefix shards by the first n characters of the encoded key.
+func Prefix(n int) *ShardIdV1 {
+	padding := strings.Repeat("_", n)
+	return &ShardIdV1{
+		funName: "prefix",
+		param:   n,
+		fun: func(name string) string {
+			return (name + padding)[:n]
+		},
+	}
+}
+
+// Suffix shards by the last n characters of the encoded key.
+func Suffix(n int) *ShardIdV1 {
+	padding := strings.Repeat("_", n)
+	return &ShardIdV1{
+		funName: "suffix",
+		param:   n,
+		fun: func(name string) string {
+			name = padding + name
+			return name[len(name)-n:]
+		},
+	}
+}
+
+// NextToLast shards by the n characters preceding the last character of
+// the encoded key. As multihashes end in uniformly distributed bytes, this
+// spreads keys evenly however predictable their beginnings are.
+func NextToLast(n int) *ShardIdV1 {
+	padding := strings.Repeat("_", n+1)
+	return &ShardIdV1{
+		funName: "next-to-last",
+		param:   n,
+		fun: func(name string) string {
+			name = padding + name
+			end := len(name) - 1
+			return name[end-n : end]
+		},
+	}
+}
+
+func (f *ShardIdV1) String() string {
+	return shardPrefix + f.funName + "/" + strconv.Itoa(f.param)
+}
+
+// Func returns the sharding function.
+func (f *ShardIdV1) Func() ShardFunc {
+	return f.fun
+}
+
+// ParseShardFunc parses the string form of a sharding function, as written
+// by String.
+func ParseShardFunc(s string) (*ShardIdV1, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, shardPrefix) {
+		return nil, fmt.Errorf("invalid or unsupported sharding function '%s'", s)
+	}
+
+	parts := strings.Split(s[len(shardPrefix):], "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid sharding function '%s'", s)
+	}
+	param, err := strconv.Atoi(parts[1])
+	if err != nil || param <= 0 || param > maxPrefixLen*2 {
+		return nil, fmt.Errorf("invalid sharding function parameter '%s'", parts[1])
+	}
+
+	switch parts[0] {
+	case "prefix":
+		return Prefix(param), nil
+	case "suffix":
+		return Suffix(param), nil
+	case "next-to-last":
+		return NextToLast(param), nil
+	default:
+		return nil, fmt.Errorf("unknown sharding function '%s'", parts[0])
+	}
+}
+
+// ReadShardFunc reads the sharding function recorded in the given flatfs
+// directory, returning ErrNoSharding if none is.
+func ReadShardFunc(dir string) (*ShardIdV1, error) {
+	buf, err := ioutil.ReadFile(path.Join(dir, ShardingFile))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSharding
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseShardFunc(string(buf))
+}
+
+// WriteShardFunc records the sharding function of the given flatfs
+// directory.
+func WriteShardFunc(dir string, id *ShardIdV1) error {
+	tmp, err := ioutil.TempFile(dir, "sharding-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(id.String() + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := osrename.Rename(tmp.Name(), path.Join(dir, ShardingFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,148 @@
+package flatfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	"github.com/ipfs/go-ipfs/repo/fsrepo/flatfs"
+)
+
+func tempdir(t testing.TB) (path string, cleanup func()) {
+	path, err := ioutil.TempDir("", "test-datastore-flatfs-")
+	if err != nil {
+		t.Fatalf("cannot create temp directory: %v", err)
+	}
+
+	cleanup = func() {
+		if err := os.RemoveAll(path); err != nil {
+			t.Errorf("tempdir cleanup failed: %v", err)
+		}
+	}
+	return path, cleanup
+}
+
+func TestShardFuncs(t *testing.T) {
+	const name = "71757578"
+	cases := []struct {
+		id   *flatfs.ShardIdV1
+		str  string
+		want string
+	}{
+		{flatfs.Prefix(4), "/repo/flatfs/shard/v1/prefix/4", "7175"},
+		{flatfs.Prefix(10), "/repo/flatfs/shard/v1/prefix/10", "71757578__"},
+		{flatfs.Suffix(2), "/repo/flatfs/shard/v1/suffix/2", "78"},
+		{flatfs.NextToLast(2), "/repo/flatfs/shard/v1/next-to-last/2", "57"},
+	}
+	for _, c := range cases {
+		if c.id.String() != c.str {
+			t.Errorf("expected %s, got %s", c.str, c.id)
+		}
+		parsed, err := flatfs.ParseShardFunc(c.str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parsed.Func()(name); got != c.want {
+			t.Errorf("%s: expected shard %q, got %q", c.str, c.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "prefix/2", "/repo/flatfs/shard/v1/prefix/0", "/repo/flatfs/shard/v1/middle/2"} {
+		if _, err := flatfs.ParseShardFunc(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestShardingFile(t *testing.T) {
+	temp, cleanup := tempdir(t)
+	defer cleanup()
+
+	if _, err := flatfs.Open(temp); err != flatfs.ErrNoSharding {
+		t.Fatalf("expected ErrNoSharding, got %v", err)
+	}
+
+	if _, err := flatfs.CreateOrOpen(temp, flatfs.NextToLast(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := flatfs.Open(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fs.ShardFunc().String(); s != flatfs.NextToLast(2).String() {
+		t.Fatalf("opened with wrong sharding %s", s)
+	}
+}
+
+func TestReshard(t *testing.T) {
+	temp, cleanup := tempdir(t)
+	defer cleanup()
+
+	fs, err := flatfs.CreateOrOpen(temp, flatfs.Prefix(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"quux", "quuy", "foo", "bar"}
+	for _, k := range keys {
+		if err := fs.Put(datastore.NewKey(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := flatfs.Reshard(temp, flatfs.NextToLast(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = flatfs.Open(temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		v, err := fs.Get(datastore.NewKey(k))
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		if string(v.([]byte)) != k {
+			t.Fatalf("%s: wrong value %q", k, v)
+		}
+	}
+
+	// the old prefix directory of "quux" and "quuy" is gone
+	if _, err := os.Stat(filepath.Join(temp, "7175")); !os.IsNotExist(err) {
+		t.Fatalf("expected old shard directory to be removed, got %v", err)
+	}
+}
+
+func TestCreateOrOpenReshards(t *testing.T) {
+	temp, cleanup := tempdir(t)
+	defer cleanup()
+
+	// a directory laid out before the sharding was recorded
+	if err := os.MkdirAll(filepath.Join(temp, "666f"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(filepath.Join(temp, "666f", "666f6f.data"), []byte("foo"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, shard := range []*flatfs.ShardIdV1{flatfs.NextToLast(2), flatfs.Prefix(4)} {
+		fs, err := flatfs.CreateOrOpen(temp, shard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := fs.Get(datastore.NewKey("foo"))
+		if err != nil {
+			t.Fatalf("%s: %v", shard, err)
+		}
+		if string(v.([]byte)) != "foo" {
+			t.Fatalf("%s: wrong value %q", shard, v)
+		}
+		if cur, err := flatfs.ReadShardFunc(temp); err != nil || cur.String() != shard.String() {
+			t.Fatalf("expected %s to be recorded, got %v, %v", shard, cur, err)
+		}
+	}
+}
@@ -6,18 +6,13 @@ import (
 	"io"
 	"os"
 	"path"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
-	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/flatfs"
-	levelds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/leveldb"
-	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/measure"
-	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/mount"
-	ldbopts "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/syndtr/goleveldb/leveldb/opt"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	repo "github.com/ipfs/go-ipfs/repo"
-	"github.com/ipfs/go-ipfs/repo/common"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
 	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
@@ -29,6 +24,8 @@ import (
 	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
 
+var log = logging.Logger("fsrepo")
+
 // version number that we are currently expecting to see
 var RepoVersion = "2"
 
@@ -56,9 +53,10 @@ func (err NoRepoError) Error() string {
 }
 
 const (
-	leveldbDirectory = "datastore"
-	flatfsDirectory  = "blocks"
-	apiFile          = "api"
+	leveldbDirectory  = "datastore"
+	flatfsDirectory   = "blocks"
+	keystoreDirectory = "keystore"
+	apiFile           = "api"
 )
 
 var (
@@ -94,20 +92,50 @@ type FSRepo struct {
 	lockfile io.Closer
 	config   *config.Config
 	ds       ds.ThreadSafeDatastore
+	keys     keystore.Keystore
+
+	// overrides are applied on top of the config file, see overrides.go
+	overrides []Override
+	// readOnly repos are opened without the lock, see readonly.go
+	readOnly bool
+	// sealedIdentity is the encrypted identity in the config file, when
+	// config holds it decrypted, see passphrase.go
+	sealedIdentity *config.Identity
+	// listeners are told about config changes, see notify.go
+	listeners    map[int]repo.ConfigListener
+	nextListener int
+	// notified is a copy of the config as the listeners last saw it
+	notified *config.Config
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
 
+// Options are the settings of an opened FSRepo that are not kept in it.
+type Options struct {
+	// Overrides are config values that take precedence over the config
+	// file, and over the environment, see overrides.go.
+	Overrides []Override
+	// KeyPassphrase decrypts the private key, when the config file keeps it
+	// encrypted. The opened config holds it in the clear.
+	KeyPassphrase string
+}
+
 // Open the FSRepo at path. Returns an error if the repo is not
 // initialized.
 func Open(repoPath string) (repo.Repo, error) {
+	return OpenWithOptions(repoPath, Options{})
+}
+
+// OpenWithOptions opens the FSRepo at path with opts. When the repo is open
+// already in this process, that repo is returned, and opts are ignored.
+func OpenWithOptions(repoPath string, opts Options) (repo.Repo, error) {
 	fn := func() (repo.Repo, error) {
-		return open(repoPath)
+		return open(repoPath, opts)
 	}
 	return onlyOne.Open(repoPath, fn)
 }
 
-func open(repoPath string) (repo.Repo, error) {
+func open(repoPath string, opts Options) (repo.Repo, error) {
 	packageLock.Lock()
 	defer packageLock.Unlock()
 
@@ -123,6 +151,9 @@ func open(repoPath string) (repo.Repo, error) {
 
 	r.lockfile, err = lockfile.Lock(r.path)
 	if err != nil {
+		if pid, ok := lockfile.Owner(r.path); ok {
+			return nil, fmt.Errorf("repo is in use by process %d: %s", pid, err)
+		}
 		return nil, err
 	}
 	keepLocked := false
@@ -143,7 +174,9 @@ func open(repoPath string) (repo.Repo, error) {
 	}
 
 	if ver != RepoVersion {
-		return nil, fmt.Errorf(errIncorrectRepoFmt, ver, RepoVersion)
+		if err := upgrade(r.path, ver); err != nil {
+			return nil, err
+		}
 	}
 
 	// check repo path, then check all constituent parts.
@@ -151,7 +184,7 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
-	if err := r.openConfig(); err != nil {
+	if err := r.openConfig(opts); err != nil {
 		return nil, err
 	}
 
@@ -159,6 +192,10 @@ func open(repoPath string) (repo.Repo, error) {
 		return nil, err
 	}
 
+	if err := r.openKeystore(); err != nil {
+		return nil, err
+	}
+
 	// setup eventlogger
 	configureEventLoggerAtRepoPath(r.config, r.path)
 
@@ -199,7 +236,33 @@ func ConfigAt(repoPath string) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	return serialize.Load(configFilename)
+	cfg, err := serialize.Load(configFilename)
+	if err != nil {
+		return nil, err
+	}
+	return withOverrides(cfg, overrides(nil))
+}
+
+// RestoreConfigBackup replaces the config of the FSRepo at the given path
+// with the backup of the previous config, kept each time it is written. The
+// repo must not be open in another process, which would overwrite it.
+func RestoreConfigBackup(repoPath string) error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	locked, err := lockfile.Locked(repoPath)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return errors.New("cannot restore the config while the repo is in use (is the daemon running?)")
+	}
+
+	configFilename, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	return serialize.RestoreBackup(configFilename)
 }
 
 // configIsInitialized returns true if the repo is initialized at
@@ -233,7 +296,6 @@ func initConfig(path string, conf *config.Config) error {
 }
 
 // Init initializes a new FSRepo at the given path with the provided config.
-// TODO add support for custom datastores.
 func Init(repoPath string, conf *config.Config) error {
 
 	// packageLock must be held to ensure that the repo is not initialized more
@@ -256,9 +318,13 @@ func Init(repoPath string, conf *config.Config) error {
 		return fmt.Errorf("datastore: %s", err)
 	}
 
-	flatfsPath := path.Join(repoPath, flatfsDirectory)
-	if err := dir.Writable(flatfsPath); err != nil {
-		return fmt.Errorf("datastore: %s", err)
+	for _, m := range datastoreMounts(conf) {
+		if m.Type == "mem" {
+			continue
+		}
+		if err := dir.Writable(path.Join(repoPath, m.Path)); err != nil {
+			return fmt.Errorf("datastore: %s", err)
+		}
 	}
 
 	if err := dir.Writable(path.Join(repoPath, "logs")); err != nil {
@@ -319,6 +385,9 @@ func APIAddr(repoPath string) (string, error) {
 
 // SetAPIAddr writes the API Addr to the /api file.
 func (r *FSRepo) SetAPIAddr(addr string) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
 	f, err := os.Create(path.Join(r.path, apiFile))
 	if err != nil {
 		return err
@@ -330,7 +399,7 @@ func (r *FSRepo) SetAPIAddr(addr string) error {
 }
 
 // openConfig returns an error if the config file is not present.
-func (r *FSRepo) openConfig() error {
+func (r *FSRepo) openConfig(opts Options) error {
 	configFilename, err := config.Filename(r.path)
 	if err != nil {
 		return err
@@ -339,35 +408,16 @@ func (r *FSRepo) openConfig() error {
 	if err != nil {
 		return err
 	}
-	r.config = conf
-	return nil
+	r.overrides = overrides(opts.Overrides)
+	r.config, err = withOverrides(conf, r.overrides)
+	if err != nil {
+		return err
+	}
+	return r.decryptIdentity(opts.KeyPassphrase)
 }
 
 // openDatastore returns an error if the config file is not present.
 func (r *FSRepo) openDatastore() error {
-	leveldbPath := path.Join(r.path, leveldbDirectory)
-	var err error
-	// save leveldb reference so it can be neatly closed afterward
-	leveldbDS, err := levelds.NewDatastore(leveldbPath, &levelds.Options{
-		Compression: ldbopts.NoCompression,
-	})
-	if err != nil {
-		return errors.New("unable to open leveldb datastore")
-	}
-
-	// 4TB of 256kB objects ~=17M objects, splitting that 256-way
-	// leads to ~66k objects per dir, splitting 256*256-way leads to
-	// only 256.
-	//
-	// The keys seen by the block store have predictable prefixes,
-	// including "/" from datastore.Key and 2 bytes from multihash. To
-	// reach a uniform 256-way split, we need approximately 4 bytes of
-	// prefix.
-	blocksDS, err := flatfs.New(path.Join(r.path, flatfsDirectory), 4)
-	if err != nil {
-		return errors.New("unable to open flatfs datastore")
-	}
-
 	// Add our PeerID to metrics paths to keep them unique
 	//
 	// As some tests just pass a zero-value Config to fsrepo.Init,
@@ -378,29 +428,31 @@ func (r *FSRepo) openDatastore() error {
 		id = fmt.Sprintf("uninitialized_%p", r)
 	}
 	prefix := "fsrepo." + id + ".datastore."
-	metricsBlocks := measure.New(prefix+"blocks", blocksDS)
-	metricsLevelDB := measure.New(prefix+"leveldb", leveldbDS)
-	mountDS := mount.New([]mount.Mount{
-		{
-			Prefix:    ds.NewKey("/blocks"),
-			Datastore: metricsBlocks,
-		},
-		{
-			Prefix:    ds.NewKey("/"),
-			Datastore: metricsLevelDB,
-		},
-	})
+
+	mountDS, err := openMounts(r.path, datastoreMounts(r.config), prefix, r.readOnly)
+	if err != nil {
+		return err
+	}
+
 	// Make sure it's ok to claim the virtual datastore from mount as
 	// threadsafe. There's no clean way to make mount itself provide
 	// this information without copy-pasting the code into two
 	// variants. This is the same dilemma as the `[].byte` attempt at
-	// introducing const types to Go.
-	var _ ds.ThreadSafeDatastore = blocksDS
-	var _ ds.ThreadSafeDatastore = leveldbDS
+	// introducing const types to Go. All the datastores we construct
+	// are threadsafe.
 	r.ds = ds2.ClaimThreadSafe{mountDS}
 	return nil
 }
 
+func (r *FSRepo) openKeystore() error {
+	ks, err := keystore.NewFSKeystore(path.Join(r.path, keystoreDirectory))
+	if err != nil {
+		return err
+	}
+	r.keys = ks
+	return nil
+}
+
 func configureEventLoggerAtRepoPath(c *config.Config, repoPath string) {
 	logging.Configure(logging.LevelInfo)
 	logging.Configure(logging.LdJSONFormatter)
@@ -454,6 +506,9 @@ func (r *FSRepo) Config() (*config.Config, error) {
 
 // setConfigUnsynced is for private use.
 func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
+	if err := config.Validate(updated); err != nil {
+		return err
+	}
 	configFilename, err := config.Filename(r.path)
 	if err != nil {
 		return err
@@ -469,24 +524,45 @@ func (r *FSRepo) setConfigUnsynced(updated *config.Config) error {
 	if err != nil {
 		return err
 	}
+	if len(r.overrides) > 0 {
+		// keep the overridden values out of the file
+		effective, err := config.ToMap(r.config)
+		if err != nil {
+			return err
+		}
+		if err := removeOverrides(m, mapconf, effective, r.overrides); err != nil {
+			return err
+		}
+	}
+	identity, err := r.sealIdentity(m, updated)
+	if err != nil {
+		return err
+	}
 	for k, v := range m {
 		mapconf[k] = v
 	}
 	if err := serialize.WriteConfigFile(configFilename, mapconf); err != nil {
 		return err
 	}
+	withIdentity := *updated
+	withIdentity.Identity = identity
+	updated, err = withOverrides(&withIdentity, r.overrides)
+	if err != nil {
+		return err
+	}
 	*r.config = *updated // copy so caller cannot modify this private config
 	return nil
 }
 
 // SetConfig updates the FSRepo's config.
 func (r *FSRepo) SetConfig(updated *config.Config) error {
-
-	// packageLock is held to provide thread-safety.
-	packageLock.Lock()
-	defer packageLock.Unlock()
-
-	return r.setConfigUnsynced(updated)
+	// packageLock is held by changeConfig to provide thread-safety.
+	return r.changeConfig(func() error {
+		if r.readOnly {
+			return ErrReadOnly
+		}
+		return r.setConfigUnsynced(updated)
+	})
 }
 
 // GetConfigKey retrieves only the value of a particular key.
@@ -506,17 +582,26 @@ func (r *FSRepo) GetConfigKey(key string) (interface{}, error) {
 	if err := serialize.ReadConfigFile(filename, &cfg); err != nil {
 		return nil, err
 	}
-	return common.MapGetKV(cfg, key)
+	if err := applyOverrides(cfg, r.overrides); err != nil {
+		return nil, err
+	}
+	return config.GetKey(cfg, key)
 }
 
 // SetConfigKey writes the value of a particular key.
 func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
-	packageLock.Lock()
-	defer packageLock.Unlock()
+	return r.changeConfig(func() error {
+		return r.setConfigKeyUnsynced(key, value)
+	})
+}
 
+func (r *FSRepo) setConfigKeyUnsynced(key string, value interface{}) error {
 	if r.closed {
 		return errors.New("repo is closed")
 	}
+	if r.readOnly {
+		return ErrReadOnly
+	}
 
 	filename, err := config.Filename(r.path)
 	if err != nil {
@@ -527,44 +612,7 @@ func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 		return err
 	}
 
-	// Get the type of the value associated with the key
-	oldValue, err := common.MapGetKV(mapconf, key)
-	ok := true
-	if err != nil {
-		// key-value does not exist yet
-		switch v := value.(type) {
-		case string:
-			value, err = strconv.ParseBool(v)
-			if err != nil {
-				value, err = strconv.Atoi(v)
-				if err != nil {
-					value, err = strconv.ParseFloat(v, 32)
-					if err != nil {
-						value = v
-					}
-				}
-			}
-		default:
-		}
-	} else {
-		switch oldValue.(type) {
-		case bool:
-			value, ok = value.(bool)
-		case int:
-			value, ok = value.(int)
-		case float32:
-			value, ok = value.(float32)
-		case string:
-			value, ok = value.(string)
-		default:
-			value = value
-		}
-		if !ok {
-			return fmt.Errorf("Wrong config type, expected %T", oldValue)
-		}
-	}
-
-	if err := common.MapSetKV(mapconf, key, value); err != nil {
+	if err := config.SetKey(mapconf, key, value); err != nil {
 		return err
 	}
 
@@ -589,6 +637,29 @@ func (r *FSRepo) Datastore() ds.ThreadSafeDatastore {
 	return d
 }
 
+// GetStorageUsage returns the size of the files in the repo directory.
+func (r *FSRepo) GetStorageUsage() (uint64, error) {
+	var du uint64
+	err := filepath.Walk(r.path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed while walking
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			du += uint64(fi.Size())
+		}
+		return nil
+	})
+	return du, err
+}
+
+// Keystore returns the repo's keystore.
+func (r *FSRepo) Keystore() keystore.Keystore {
+	return r.keys
+}
+
 var _ io.Closer = &FSRepo{}
 var _ repo.Repo = &FSRepo{}
 
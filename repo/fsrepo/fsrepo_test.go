@@ -2,10 +2,14 @@ package fsrepo
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"testing"
 
 	datastore "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	repo "github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/config"
 	"github.com/ipfs/go-ipfs/thirdparty/assert"
 )
@@ -116,3 +120,274 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestConfiguredDatastoreMounts(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("mounts", t)
+
+	conf := &config.Config{}
+	conf.Datastore.Mounts = []config.DatastoreMount{
+		{Prefix: "/blocks", Type: "mem"},
+		{Prefix: "/", Type: "leveldb", Path: "store", Params: map[string]string{"compression": "snappy"}},
+	}
+	assert.Nil(Init(path, conf), t)
+
+	r1, err := Open(path)
+	assert.Nil(err, t)
+	blockKey := datastore.NewKey("/blocks/foo")
+	otherKey := datastore.NewKey("/bar")
+	assert.Nil(r1.Datastore().Put(blockKey, []byte("foo")), t)
+	assert.Nil(r1.Datastore().Put(otherKey, []byte("bar")), t)
+	assert.Nil(r1.Close(), t)
+
+	r2, err := Open(path)
+	assert.Nil(err, t)
+	_, err = r2.Datastore().Get(blockKey)
+	assert.True(err == datastore.ErrNotFound, t, "blocks should not outlive the in-memory datastore")
+	_, err = r2.Datastore().Get(otherKey)
+	assert.Nil(err, t, "keys outside /blocks should persist in leveldb")
+	assert.Nil(r2.Close(), t)
+}
+
+func TestUnknownDatastoreType(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("badmount", t)
+
+	conf := &config.Config{}
+	conf.Datastore.Mounts = []config.DatastoreMount{
+		{Prefix: "/", Type: "nosuchstore", Path: "store"},
+	}
+	assert.Nil(Init(path, conf), t)
+
+	_, err := Open(path)
+	assert.Err(err, t, "opening a repo with an unknown datastore type should fail")
+}
+
+func TestFlatfsReshard(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("sharding", t)
+	k := datastore.NewKey("foo")
+
+	d, err := openFlatfs(path, map[string]string{"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2"})
+	assert.Nil(err, t)
+	assert.Nil(d.Put(k, []byte("bar")), t)
+	assert.Nil(d.(io.Closer).Close(), t)
+
+	// changing the sharding in the config migrates the datastore
+	d, err = openFlatfs(path, map[string]string{"prefixLen": "4"})
+	assert.Nil(err, t)
+	v, err := d.Get(k)
+	assert.Nil(err, t)
+	assert.True(bytes.Equal(v.([]byte), []byte("bar")), t, "the value should survive resharding")
+	assert.Nil(d.(io.Closer).Close(), t)
+}
+
+func TestConfigOverrides(t *testing.T) {
+	path := testRepoPath("overrides", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+
+	overrides, err := ParseOverrides("Discovery.MDNS.Interval=7; Gateway.Writable=true")
+	assert.Nil(err, t)
+	r, err := OpenWithOptions(path, Options{Overrides: overrides})
+	assert.Nil(err, t)
+	defer r.Close()
+
+	cfg, err := r.Config()
+	assert.Nil(err, t)
+	if cfg.Discovery.MDNS.Interval != 7 || !cfg.Gateway.Writable {
+		t.Fatalf("overrides not applied: %+v", cfg)
+	}
+	v, err := r.GetConfigKey("Gateway.Writable")
+	assert.Nil(err, t)
+	assert.True(v == true, t, "GetConfigKey should see the override")
+
+	// changing another value must not write the overrides to the file
+	updated := *cfg
+	updated.Bootstrap = config.DefaultBootstrapAddresses[:1]
+	assert.Nil(r.SetConfig(&updated), t)
+
+	onDisk, err := ConfigAt(path)
+	assert.Nil(err, t)
+	if onDisk.Discovery.MDNS.Interval != 0 || onDisk.Gateway.Writable {
+		t.Fatalf("overrides were written to the config file: %+v", onDisk)
+	}
+	if len(onDisk.Bootstrap) != 1 {
+		t.Fatal("the change was not written to the config file")
+	}
+
+	cfg, err = r.Config()
+	assert.Nil(err, t)
+	assert.True(cfg.Gateway.Writable, t, "overrides should stay in effect after SetConfig")
+}
+
+func TestEnvOverrides(t *testing.T) {
+	o := EnvOverrides([]string{
+		"HOME=/home/foo",
+		"IPFS_CONFIG_ADDRESSES_API=/ip4/0.0.0.0/tcp/5001",
+		"IPFS_PATH=/tmp/ipfs",
+	})
+	if len(o) != 1 || o[0].Key != "ADDRESSES.API" || o[0].Value != "/ip4/0.0.0.0/tcp/5001" {
+		t.Fatalf("unexpected overrides: %v", o)
+	}
+
+	if _, err := ParseOverrides("Gateway.Writable"); err == nil {
+		t.Fatal("expected an error for an override without a value")
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("test", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+
+	r, err := Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+	k := datastore.NewKey("/blocks/CIQABCDEFGHIJKLMNOP")
+	assert.Nil(r.Datastore().Put(k, []byte("block")), t)
+
+	// the repo is locked, but can still be inspected
+	ro, err := OpenReadOnly(path)
+	assert.Nil(err, t, "should open read-only while locked")
+	v, err := ro.Datastore().Get(k)
+	assert.Nil(err, t, "should read blocks read-only")
+	assert.True(bytes.Equal(v.([]byte), []byte("block")), t, "data should match")
+
+	assert.True(ro.Datastore().Put(k, []byte("other")) != nil, t, "Put should fail read-only")
+	assert.True(ro.Datastore().Delete(k) == ErrReadOnly, t, "Delete should fail read-only")
+	assert.True(ro.SetConfigKey("Datastore.Path", "/x") == ErrReadOnly, t, "config should be read-only")
+	assert.Nil(ro.Close(), t)
+}
+
+func TestEncryptedPrivateKey(t *testing.T) {
+	path := testRepoPath("passphrase", t)
+	cfg, err := config.Init(ioutil.Discard, 1024)
+	assert.Nil(err, t)
+	plain := cfg.Identity
+	assert.Nil(Init(path, cfg), t)
+
+	assert.Nil(ChangeKeyPassphrase(path, "", "hunter2"), t)
+	onDisk, err := ConfigAt(path)
+	assert.Nil(err, t)
+	assert.True(onDisk.Identity.Encrypted(), t, "key should be encrypted on disk")
+	assert.True(ChangeKeyPassphrase(path, "wrong", "other") == config.ErrBadPassphrase, t, "wrong passphrase should fail")
+
+	r, err := OpenWithOptions(path, Options{KeyPassphrase: "hunter2"})
+	assert.Nil(err, t)
+	opened, err := r.Config()
+	assert.Nil(err, t)
+	assert.True(opened.Identity == plain, t, "opened config should hold the decrypted key")
+
+	// writing the config keeps the key encrypted
+	assert.Nil(r.SetConfigKey("Datastore.Path", "/elsewhere"), t)
+	updated := *opened
+	updated.Bootstrap = nil
+	assert.Nil(r.SetConfig(&updated), t)
+	assert.Nil(r.Close(), t)
+
+	onDisk, err = ConfigAt(path)
+	assert.Nil(err, t)
+	assert.True(onDisk.Identity.Encrypted(), t, "key should still be encrypted on disk")
+	assert.True(onDisk.Datastore.Path == "/elsewhere", t, "config change should be written")
+
+	// rotate the passphrase, then store the key in the clear again
+	assert.Nil(ChangeKeyPassphrase(path, "hunter2", "hunter3"), t)
+	assert.Nil(ChangeKeyPassphrase(path, "hunter3", ""), t)
+	onDisk, err = ConfigAt(path)
+	assert.Nil(err, t)
+	assert.True(onDisk.Identity == plain, t, "key should be back in the clear")
+}
+
+func TestStorageUsage(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("usage", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+	r, err := Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+
+	before, err := r.GetStorageUsage()
+	assert.Nil(err, t)
+	data := bytes.Repeat([]byte{'x'}, 64<<10)
+	assert.Nil(r.Datastore().Put(datastore.NewKey("/blocks/CIQUSAGE"), data), t)
+	after, err := r.GetStorageUsage()
+	assert.Nil(err, t)
+	assert.True(after >= before+uint64(len(data)), t, "usage should include the new block")
+}
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("export", t)
+	cfg := &config.Config{}
+	cfg.Gateway.RootRedirect = "/exported"
+	assert.Nil(Init(path, cfg), t)
+
+	entries := map[string]string{
+		"/blocks/CIQEXPORTED": "block",
+		"/local/pins":         "pins",
+	}
+	r, err := Open(path)
+	assert.Nil(err, t)
+	for k, v := range entries {
+		assert.Nil(r.Datastore().Put(datastore.NewKey(k), []byte(v)), t)
+	}
+	sk, err := keystore.Create(r.Keystore(), "extra", ci.RSA, 512)
+	assert.Nil(err, t)
+
+	var archive bytes.Buffer
+	assert.Err(Export(path, &archive), t, "export should fail while the repo is open")
+	assert.Nil(r.Close(), t)
+	archive.Reset()
+	assert.Nil(Export(path, &archive), t)
+
+	imported := testRepoPath("import", t)
+	assert.Nil(Remove(imported), t)
+	assert.Nil(Import(imported, bytes.NewReader(archive.Bytes())), t)
+	assert.Err(Import(imported, bytes.NewReader(archive.Bytes())), t, "import should not overwrite a repo")
+
+	r, err = Open(imported)
+	assert.Nil(err, t)
+	defer r.Close()
+	c, err := r.Config()
+	assert.Nil(err, t)
+	assert.True(c.Gateway.RootRedirect == "/exported", t, "config should be imported")
+	for k, v := range entries {
+		got, err := r.Datastore().Get(datastore.NewKey(k))
+		assert.Nil(err, t, k)
+		assert.True(bytes.Equal(got.([]byte), []byte(v)), t, k, "should be imported")
+	}
+	imp, err := r.Keystore().Get("extra")
+	assert.Nil(err, t)
+	assert.True(imp.Equals(sk), t, "keys should be imported")
+}
+
+func TestNotifyConfig(t *testing.T) {
+	t.Parallel()
+	path := testRepoPath("test", t)
+	assert.Nil(Init(path, &config.Config{}), t)
+
+	r, err := Open(path)
+	assert.Nil(err, t)
+	defer r.Close()
+
+	var calls int
+	var old, updated string
+	cancel := repo.NotifyConfig(r, func(o, u *config.Config) {
+		calls++
+		old, updated = o.Gateway.RootRedirect, u.Gateway.RootRedirect
+	})
+	assert.Nil(r.SetConfigKey("Gateway.RootRedirect", "/first"), t)
+	assert.True(calls == 1, t, "listener should be called on SetConfigKey")
+	assert.True(old == "" && updated == "/first", t, "listener should see the change")
+
+	c, err := r.Config()
+	assert.Nil(err, t)
+	c.Gateway.RootRedirect = "/second"
+	assert.Nil(r.SetConfig(c), t)
+	assert.True(calls == 2, t, "listener should be called on SetConfig")
+	assert.True(old == "/first" && updated == "/second", t, "listener should see the change")
+
+	cancel()
+	assert.Nil(r.SetConfigKey("Gateway.RootRedirect", "/third"), t)
+	assert.True(calls == 2, t, "listener should not be called once cancelled")
+}
@@ -1,28 +1,133 @@
 package lock
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 
 	lock "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/camlistore/lock"
 	"github.com/ipfs/go-ipfs/util"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
 )
 
+var log = logging.Logger("lock")
+
 // LockFile is the filename of the repo lock, relative to config dir
 // TODO rename repo lock and hide name
 const LockFile = "repo.lock"
 
+// OwnerFile records the pid of the process holding the repo lock, so other
+// processes can say who has the repo open. The lock itself doesn't, as
+// fcntl locks require the lock file to stay empty.
+const OwnerFile = LockFile + ".pid"
+
 func errPerm(path string) error {
 	return fmt.Errorf("failed to take lock at %s: permission denied", path)
 }
 
 func Lock(confdir string) (io.Closer, error) {
-	c, err := lock.Lock(path.Join(confdir, LockFile))
-	return c, err
+	p := path.Join(confdir, LockFile)
+
+	// non-empty lock files are checked before locking: a failed lock.Lock
+	// keeps the file marked as locked by this process, so it can't be
+	// retried once a stale lock is removed.
+	if fi, err := os.Stat(p); err == nil && fi.Size() > 0 {
+		if !isStale(p) {
+			return nil, fmt.Errorf("can't Lock file %q: has non-zero size", p)
+		}
+		// the lock file was left behind by a process that is gone
+		log.Warningf("removing stale repo lock at %s", p)
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	c, err := lock.Lock(p)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := path.Join(confdir, OwnerFile)
+	if err := ioutil.WriteFile(owner, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		// only used for error messages, not worth failing over
+		log.Debugf("could not record repo lock owner: %s", err)
+	}
+	return &ownedLock{Closer: c, owner: owner}, nil
+}
+
+// ownedLock removes the owner file when the lock is released.
+type ownedLock struct {
+	io.Closer
+	owner string
+}
+
+func (l *ownedLock) Close() error {
+	os.Remove(l.owner)
+	return l.Closer.Close()
+}
+
+// Owner returns the pid of the running process that holds the repo lock in
+// confdir, if it is known.
+func Owner(confdir string) (int, bool) {
+	data, err := ioutil.ReadFile(path.Join(confdir, OwnerFile))
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || !processAlive(pid) {
+		return 0, false
+	}
+	return pid, true
+}
+
+// lockOwner is what lock files that are not empty hold: on systems without
+// fcntl locks, the lock is the presence of the file, which records the pid
+// of the process that created it.
+type lockOwner struct {
+	OwnerPID int
+}
+
+func isNonZeroSize(err error) bool {
+	return strings.Contains(err.Error(), "has non-zero size")
+}
+
+// isStale returns whether the non-empty lock file at p was left behind by a
+// process that is no longer running. Empty lock files hold fcntl locks, which
+// are released with their process, and must not be checked. Lock files that don't name their owner
+// can't have been written by a lock, and are stale too.
+func isStale(p string) bool {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	var owner lockOwner
+	if err := json.Unmarshal(data, &owner); err != nil || owner.OwnerPID <= 0 {
+		return true
+	}
+	return owner.OwnerPID != os.Getpid() && !processAlive(owner.OwnerPID)
+}
+
+// processAlive returns whether a process with the given pid is running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails there for processes that don't exist
+		return true
+	}
+	// signal 0 checks the process exists without disturbing it; EPERM
+	// means it exists but belongs to someone else
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || strings.Contains(err.Error(), "not permitted")
 }
 
 func Locked(confdir string) (bool, error) {
@@ -37,6 +142,10 @@ func Locked(confdir string) (bool, error) {
 		if strings.Contains(err.Error(), "can't Lock file") {
 			return true, nil
 		}
+		// a lock file that Lock didn't find stale belongs to a live process
+		if isNonZeroSize(err) {
+			return true, nil
+		}
 
 		// lock fails on permissions error
 		if os.IsPermission(err) {
@@ -0,0 +1,66 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"testing"
+)
+
+func TestStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a lock file left by a process that has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("cannot run a process to get a dead pid:", err)
+	}
+	dead := `{"OwnerPID":` + strconv.Itoa(cmd.ProcessState.Pid()) + `}`
+	if err := ioutil.WriteFile(path.Join(dir, LockFile), []byte(dead), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lk, err := Lock(dir)
+	if err != nil {
+		t.Fatal("stale lock was not broken:", err)
+	}
+	pid, ok := Owner(dir)
+	if !ok || pid != os.Getpid() {
+		t.Fatalf("expected to own the lock, got %d %v", pid, ok)
+	}
+	if err := lk.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Owner(dir); ok {
+		t.Fatal("owner should be cleared on close")
+	}
+}
+
+func TestLiveLockNotBroken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	live := `{"OwnerPID":` + strconv.Itoa(os.Getppid()) + `}`
+	if err := ioutil.WriteFile(path.Join(dir, LockFile), []byte(live), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lock(dir); err == nil {
+		t.Fatal("took a lock held by a running process")
+	}
+	locked, err := Locked(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked {
+		t.Fatal("lock held by a running process should be reported locked")
+	}
+}
@@ -0,0 +1,102 @@
+package fsrepo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+)
+
+// migrations lists the changes made to the repo layout, by the version they
+// bring the repo to. Repos older than the first one listed must be upgraded
+// with the external migration tool.
+var migrations []mfsr.Migration
+
+// MigrateOptions modify how Migrate runs.
+type MigrateOptions struct {
+	// DryRun only returns the migrations that would run.
+	DryRun bool
+
+	// Backup copies the repo, apart from its blocks, into a directory inside
+	// it before migrating.
+	Backup bool
+}
+
+// backupDir returns where the repo at version ver is backed up before
+// migrating.
+func backupDir(repoPath string, ver string) string {
+	return filepath.Join(repoPath, "backup-v"+ver)
+}
+
+// Migrate upgrades (or downgrades) the repo at the given path to the given
+// version, and returns the migrations run. The repo must not be open.
+func Migrate(repoPath string, to int, opts MigrateOptions) ([]mfsr.Step, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if !isInitializedUnsynced(repoPath) {
+		return nil, NoRepoError{Path: repoPath}
+	}
+
+	lk, err := lockfile.Lock(repoPath)
+	if err != nil {
+		return nil, errors.New("cannot migrate the repo while it is in use (is the daemon running?)")
+	}
+	defer lk.Close()
+
+	return migrate(repoPath, to, opts)
+}
+
+func migrate(repoPath string, to int, opts MigrateOptions) ([]mfsr.Step, error) {
+	rp := mfsr.RepoPath(repoPath)
+	mopts := mfsr.Options{DryRun: opts.DryRun}
+	if opts.Backup {
+		ver, err := rp.Version()
+		if err != nil {
+			return nil, err
+		}
+		mopts.BackupDir = backupDir(repoPath, ver)
+		mopts.BackupSkip = []string{flatfsDirectory, lockfile.LockFile, lockfile.OwnerFile}
+	}
+
+	steps, err := rp.Migrate(migrations, to, mopts)
+	for _, s := range steps {
+		if opts.DryRun {
+			log.Infof("would migrate repo %s", s)
+		} else {
+			log.Infof("migrated repo %s", s)
+		}
+	}
+	return steps, err
+}
+
+// currentVersion returns RepoVersion as a number.
+func currentVersion() int {
+	v, err := strconv.Atoi(RepoVersion)
+	if err != nil {
+		panic(fmt.Sprintf("invalid RepoVersion %q", RepoVersion))
+	}
+	return v
+}
+
+// upgrade migrates an older repo at version ver, held open by the caller,
+// to RepoVersion, backing it up first. It returns an error explaining what
+// to do when no migrations lead to RepoVersion.
+func upgrade(repoPath string, ver string) error {
+	v, err := strconv.Atoi(ver)
+	if err != nil || v > currentVersion() {
+		return fmt.Errorf(errIncorrectRepoFmt, ver, RepoVersion)
+	}
+	if _, err := mfsr.Plan(migrations, v, currentVersion()); err != nil {
+		return fmt.Errorf(errIncorrectRepoFmt, ver, RepoVersion)
+	}
+
+	log.Infof("upgrading repo from version %s to %s, backing it up to %s", ver, RepoVersion, backupDir(repoPath, ver))
+	if _, err := migrate(repoPath, currentVersion(), MigrateOptions{Backup: true}); err != nil {
+		return fmt.Errorf("upgrading repo from version %s to %s: %s", ver, RepoVersion, err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package mfsr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Migration changes a repo from version To-1 to version To, and back.
+type Migration struct {
+	To          int
+	Description string
+	Apply       func(rp RepoPath) error
+	Revert      func(rp RepoPath) error
+}
+
+// Step is a migration to run, forwards or in reverse.
+type Step struct {
+	*Migration
+	Reverse bool
+}
+
+func (s Step) String() string {
+	if s.Reverse {
+		return fmt.Sprintf("%d -> %d: revert %s", s.To, s.To-1, s.Description)
+	}
+	return fmt.Sprintf("%d -> %d: %s", s.To-1, s.To, s.Description)
+}
+
+func (s Step) run(rp RepoPath) error {
+	if s.Reverse {
+		return s.Revert(rp)
+	}
+	return s.Apply(rp)
+}
+
+func (s Step) undo(rp RepoPath) error {
+	if s.Reverse {
+		return s.Apply(rp)
+	}
+	return s.Revert(rp)
+}
+
+// target is the version the repo is at after the step.
+func (s Step) target() int {
+	if s.Reverse {
+		return s.To - 1
+	}
+	return s.To
+}
+
+// Plan returns the steps that take a repo from version from to version to,
+// using the given migrations.
+func Plan(ms []Migration, from, to int) ([]Step, error) {
+	byTo := make(map[int]*Migration)
+	for i := range ms {
+		byTo[ms[i].To] = &ms[i]
+	}
+
+	var steps []Step
+	for v := from; v < to; v++ {
+		m, ok := byTo[v+1]
+		if !ok {
+			return nil, fmt.Errorf("no migration from repo version %d to %d", v, v+1)
+		}
+		steps = append(steps, Step{Migration: m})
+	}
+	for v := from; v > to; v-- {
+		m, ok := byTo[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration from repo version %d to %d", v, v-1)
+		}
+		steps = append(steps, Step{Migration: m, Reverse: true})
+	}
+	return steps, nil
+}
+
+// Options modify how Migrate runs.
+type Options struct {
+	// DryRun only plans the migrations, without running them.
+	DryRun bool
+
+	// BackupDir, if set, is where the repo is copied before migrating, apart
+	// from the top level entries listed in BackupSkip.
+	BackupDir  string
+	BackupSkip []string
+}
+
+// Migrate runs the migrations taking the repo to version to, and returns
+// the steps run. The version file is updated after each step, so a failed
+// step, which is undone, leaves the repo at the version before it.
+func (rp RepoPath) Migrate(ms []Migration, to int, opts Options) ([]Step, error) {
+	vs, err := rp.Version()
+	if err != nil {
+		return nil, err
+	}
+	from, err := strconv.Atoi(vs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repo version %q", vs)
+	}
+
+	steps, err := Plan(ms, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun || len(steps) == 0 {
+		return steps, nil
+	}
+
+	if opts.BackupDir != "" {
+		if err := rp.backup(opts.BackupDir, opts.BackupSkip); err != nil {
+			return nil, fmt.Errorf("backing up repo: %s", err)
+		}
+	}
+
+	for i, s := range steps {
+		if err := s.run(rp); err != nil {
+			if uerr := s.undo(rp); uerr != nil {
+				return steps[:i], fmt.Errorf("migration %s failed: %s, and undoing it failed: %s", s, err, uerr)
+			}
+			return steps[:i], fmt.Errorf("migration %s failed: %s", s, err)
+		}
+		if err := rp.WriteVersion(strconv.Itoa(s.target())); err != nil {
+			return steps[:i+1], err
+		}
+	}
+	return steps, nil
+}
+
+// backup copies the repo into dir, skipping the named top level entries.
+func (rp RepoPath) backup(dir string, skip []string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	skipped := make(map[string]bool)
+	for _, s := range skip {
+		skipped[s] = true
+	}
+	skipped[filepath.Base(dir)] = true
+
+	root := string(rp)
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && skipped[rel] {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		dst := filepath.Join(dir, rel)
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(dst, fi.Mode().Perm())
+		case fi.Mode().IsRegular():
+			return copyFile(p, dst, fi.Mode().Perm())
+		default:
+			// sockets, symlinks and the like aren't part of the repo's state
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
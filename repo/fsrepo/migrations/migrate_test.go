@@ -0,0 +1,122 @@
+package mfsr
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileMigration returns a migration to version to that creates a file named
+// after it, and removes it again.
+func fileMigration(to int, name string) Migration {
+	return Migration{
+		To:          to,
+		Description: "add " + name,
+		Apply: func(rp RepoPath) error {
+			return ioutil.WriteFile(filepath.Join(string(rp), name), nil, 0644)
+		},
+		Revert: func(rp RepoPath) error {
+			return os.Remove(filepath.Join(string(rp), name))
+		},
+	}
+}
+
+func tempRepo(t *testing.T, version string) RepoPath {
+	dir, err := ioutil.TempDir("", "mfsr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := RepoPath(dir)
+	if err := rp.WriteVersion(version); err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+func exists(rp RepoPath, name string) bool {
+	_, err := os.Stat(filepath.Join(string(rp), name))
+	return err == nil
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	rp := tempRepo(t, "2")
+	defer os.RemoveAll(string(rp))
+	ms := []Migration{fileMigration(3, "three"), fileMigration(4, "four")}
+
+	steps, err := rp.Migrate(ms, 4, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || exists(rp, "three") {
+		t.Fatalf("dry run: got %v steps, and changed the repo: %v", steps, exists(rp, "three"))
+	}
+
+	backup := filepath.Join(string(rp), "backup")
+	if _, err := rp.Migrate(ms, 4, Options{BackupDir: backup}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(rp, "three") || !exists(rp, "four") {
+		t.Fatal("migrations were not applied")
+	}
+	if err := rp.CheckVersion("4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RepoPath(backup).CheckVersion("2"); err != nil {
+		t.Fatalf("backup: %s", err)
+	}
+
+	steps, err = rp.Migrate(ms, 2, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || !steps[0].Reverse || steps[0].To != 4 {
+		t.Fatalf("unexpected steps: %v", steps)
+	}
+	if exists(rp, "three") || exists(rp, "four") {
+		t.Fatal("migrations were not reverted")
+	}
+	if err := rp.CheckVersion("2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateFailureIsUndone(t *testing.T) {
+	rp := tempRepo(t, "2")
+	defer os.RemoveAll(string(rp))
+
+	bad := fileMigration(4, "four")
+	apply := bad.Apply
+	bad.Apply = func(rp RepoPath) error {
+		if err := apply(rp); err != nil {
+			return err
+		}
+		return errors.New("failed halfway")
+	}
+	ms := []Migration{fileMigration(3, "three"), bad}
+
+	steps, err := rp.Migrate(ms, 4, Options{})
+	if err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+	if len(steps) != 1 {
+		t.Fatalf("expected one migration to have run, got %v", steps)
+	}
+	if !exists(rp, "three") || exists(rp, "four") {
+		t.Fatal("the failed migration was not undone")
+	}
+	if err := rp.CheckVersion("3"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanMissingMigration(t *testing.T) {
+	if _, err := Plan([]Migration{fileMigration(4, "four")}, 2, 4); err == nil {
+		t.Fatal("expected an error for a missing migration")
+	}
+	steps, err := Plan(nil, 2, 2)
+	if err != nil || len(steps) != 0 {
+		t.Fatalf("expected no steps, got %v, %v", steps, err)
+	}
+}
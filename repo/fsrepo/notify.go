@@ -0,0 +1,72 @@
+package fsrepo
+
+import (
+	repo "github.com/ipfs/go-ipfs/repo"
+)
+
+// NotifyConfig calls l after every change to the config through this repo,
+// until the returned function is called. Changes to the config file made
+// by other processes, such as 'ipfs config edit', aren't noticed.
+func (r *FSRepo) NotifyConfig(l repo.ConfigListener) (cancel func()) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if r.notified == nil && r.config != nil {
+		// callers change the config returned by Config in place before
+		// setting it, so the listeners are given a copy kept from the last
+		// change as the old config
+		notified, err := r.config.Clone()
+		if err != nil {
+			log.Errorf("cannot copy the config for the listeners: %s", err)
+		}
+		r.notified = notified
+	}
+	if r.listeners == nil {
+		r.listeners = make(map[int]repo.ConfigListener)
+	}
+	id := r.nextListener
+	r.nextListener++
+	r.listeners[id] = l
+
+	return func() {
+		packageLock.Lock()
+		defer packageLock.Unlock()
+		delete(r.listeners, id)
+		if len(r.listeners) == 0 {
+			r.notified = nil
+		}
+	}
+}
+
+// changeConfig runs change, which updates the config, holding packageLock.
+// The listeners are called after it is released, so they can use the repo.
+func (r *FSRepo) changeConfig(change func() error) error {
+	packageLock.Lock()
+	if err := change(); err != nil || len(r.listeners) == 0 {
+		packageLock.Unlock()
+		return err
+	}
+
+	old := r.notified
+	updated, err := r.config.Clone()
+	if err != nil {
+		packageLock.Unlock()
+		return err
+	}
+	r.notified = updated
+	listeners := make([]repo.ConfigListener, 0, len(r.listeners))
+	for _, l := range r.listeners {
+		listeners = append(listeners, l)
+	}
+	packageLock.Unlock()
+
+	if old == nil {
+		old = updated
+	}
+	for _, l := range listeners {
+		l(old, updated)
+	}
+	return nil
+}
+
+var _ repo.ConfigNotifier = (*FSRepo)(nil)
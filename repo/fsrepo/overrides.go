@@ -0,0 +1,135 @@
+package fsrepo
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+// EnvConfigPrefix starts the names of the environment variables that
+// override config values. The rest of the name is the key, with underscores
+// between its parts: IPFS_CONFIG_ADDRESSES_API overrides Addresses.API.
+const EnvConfigPrefix = "IPFS_CONFIG_"
+
+// Override is a config value that takes precedence over the config file,
+// without being written to it.
+type Override struct {
+	Key   string
+	Value string
+}
+
+// EnvOverrides returns the overrides set in the given environment, in the
+// form returned by os.Environ.
+func EnvOverrides(environ []string) []Override {
+	var out []Override
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvConfigPrefix) {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		name := kv[len(EnvConfigPrefix):i]
+		out = append(out, Override{
+			Key:   strings.Replace(name, "_", ".", -1),
+			Value: kv[i+1:],
+		})
+	}
+	return out
+}
+
+// ParseOverrides parses overrides written as "key=value" pairs separated by
+// semicolons, such as "Addresses.API=/ip4/0.0.0.0/tcp/5001;Discovery.MDNS.Enabled=false".
+func ParseOverrides(s string) ([]Override, error) {
+	var out []Override
+	for _, kv := range strings.Split(s, ";") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid config override %q, expected key=value", kv)
+		}
+		out = append(out, Override{Key: strings.TrimSpace(kv[:i]), Value: kv[i+1:]})
+	}
+	return out, nil
+}
+
+// overrides returns the overrides of the environment followed by ovs, later
+// ones taking precedence.
+func overrides(ovs []Override) []Override {
+	return append(EnvOverrides(os.Environ()), ovs...)
+}
+
+// applyOverrides sets the overridden values in the serialized config m.
+func applyOverrides(m map[string]interface{}, ovs []Override) error {
+	for _, o := range ovs {
+		if err := config.SetKey(m, o.Key, o.Value); err != nil {
+			return fmt.Errorf("config override %s: %s", o.Key, err)
+		}
+	}
+	return nil
+}
+
+// withOverrides returns a copy of cfg with the overrides applied.
+func withOverrides(cfg *config.Config, ovs []Override) (*config.Config, error) {
+	if len(ovs) == 0 {
+		return cfg, nil
+	}
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(m, ovs); err != nil {
+		return nil, err
+	}
+	return config.FromMap(m)
+}
+
+// removeOverrides puts back, in the serialized config m about to be written,
+// the values from the file on disk that the overrides hid, unless the
+// caller changed them from the overridden values in effective.
+func removeOverrides(m, disk, effective map[string]interface{}, ovs []Override) error {
+	for _, o := range ovs {
+		v, _ := config.GetKey(m, o.Key)
+		ev, _ := config.GetKey(effective, o.Key)
+		if !reflect.DeepEqual(v, ev) {
+			continue // changed on purpose
+		}
+
+		if dv, err := config.GetKey(disk, o.Key); err == nil {
+			if err := config.SetKey(m, o.Key, dv); err != nil {
+				return err
+			}
+			continue
+		}
+		deleteKey(m, o.Key)
+	}
+	return nil
+}
+
+// deleteKey removes the dotted key from the serialized config m.
+func deleteKey(m map[string]interface{}, key string) {
+	parts := strings.Split(key, ".")
+	parent := m
+	if len(parts) > 1 {
+		p, err := config.GetKey(m, strings.Join(parts[:len(parts)-1], "."))
+		if err != nil {
+			return
+		}
+		var ok bool
+		if parent, ok = p.(map[string]interface{}); !ok {
+			return
+		}
+	}
+	last := parts[len(parts)-1]
+	for k := range parent {
+		if strings.EqualFold(k, last) {
+			delete(parent, k)
+		}
+	}
+}
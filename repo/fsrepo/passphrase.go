@@ -0,0 +1,112 @@
+package fsrepo
+
+import (
+	"errors"
+	"os"
+
+	config "github.com/ipfs/go-ipfs/repo/config"
+	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
+	serialize "github.com/ipfs/go-ipfs/repo/fsrepo/serialize"
+)
+
+// decryptIdentity decrypts the private key of the opened config with
+// passphrase, if it is encrypted and passphrase is not empty, keeping the
+// encrypted key to write back to the file.
+func (r *FSRepo) decryptIdentity(passphrase string) error {
+	if !r.config.Identity.Encrypted() || passphrase == "" {
+		return nil
+	}
+	sealed := r.config.Identity
+	if err := r.config.Identity.DecryptPrivateKey(passphrase); err != nil {
+		return err
+	}
+	r.sealedIdentity = &sealed
+	return nil
+}
+
+// sealIdentity puts the encrypted private key back in the serialized config
+// m about to be written, in place of the key decrypted on open. It returns
+// the identity the repo's config should hold afterwards.
+func (r *FSRepo) sealIdentity(m map[string]interface{}, updated *config.Config) (config.Identity, error) {
+	if r.sealedIdentity == nil {
+		return updated.Identity, nil
+	}
+	plain := r.config.Identity
+	if updated.Identity != plain && updated.Identity != *r.sealedIdentity {
+		// the key was replaced, and is written as given
+		log.Warning("the private key was replaced, it is no longer encrypted with the passphrase")
+		r.sealedIdentity = nil
+		return updated.Identity, nil
+	}
+
+	if err := config.SetKey(m, "Identity.PrivKey", r.sealedIdentity.PrivKey); err != nil {
+		return plain, err
+	}
+	if err := config.SetKey(m, "Identity.PrivKeyEncryption", r.sealedIdentity.PrivKeyEncryption); err != nil {
+		return plain, err
+	}
+	return plain, nil
+}
+
+// ChangeKeyPassphrase encrypts the private key of the repo at the given path
+// with a new passphrase, after decrypting it with the old one if it was
+// encrypted. An empty new passphrase stores the key in the clear. The repo
+// must not be open.
+func ChangeKeyPassphrase(repoPath, oldPassphrase, newPassphrase string) error {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	if !isInitializedUnsynced(repoPath) {
+		return NoRepoError{Path: repoPath}
+	}
+
+	lk, err := lockfile.Lock(repoPath)
+	if err != nil {
+		return errors.New("cannot change the key passphrase while the repo is in use (is the daemon running?)")
+	}
+	defer lk.Close()
+
+	filename, err := config.Filename(repoPath)
+	if err != nil {
+		return err
+	}
+	var mapconf map[string]interface{}
+	if err := serialize.ReadConfigFile(filename, &mapconf); err != nil {
+		return err
+	}
+	cfg, err := config.FromMap(mapconf)
+	if err != nil {
+		return err
+	}
+
+	id := cfg.Identity
+	if err := id.DecryptPrivateKey(oldPassphrase); err != nil {
+		return err
+	}
+	if newPassphrase != "" {
+		if err := id.EncryptPrivateKey(newPassphrase); err != nil {
+			return err
+		}
+	}
+
+	if err := config.SetKey(mapconf, "Identity.PrivKey", id.PrivKey); err != nil {
+		return err
+	}
+	if id.Encrypted() {
+		if err := config.SetKey(mapconf, "Identity.PrivKeyEncryption", id.PrivKeyEncryption); err != nil {
+			return err
+		}
+	} else {
+		deleteKey(mapconf, "Identity.PrivKeyEncryption")
+	}
+	if err := serialize.WriteConfigFile(filename, mapconf); err != nil {
+		return err
+	}
+
+	// the backup holds the key as it was before, which is what changing the
+	// passphrase is meant to retire
+	if err := os.Remove(filename + serialize.BackupSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package fsrepo
+
+import (
+	"errors"
+	"io"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	repo "github.com/ipfs/go-ipfs/repo"
+	lockfile "github.com/ipfs/go-ipfs/repo/fsrepo/lock"
+	mfsr "github.com/ipfs/go-ipfs/repo/fsrepo/migrations"
+)
+
+// ErrReadOnly is returned by the writes to a repo opened with OpenReadOnly.
+var ErrReadOnly = errors.New("repo is open read-only")
+
+// OpenReadOnly opens the FSRepo at path for inspection, without taking the
+// repo lock, so it works while another process, such as the daemon, has
+// the repo open. Writes to the config and the datastore fail with
+// ErrReadOnly. Datastores held open by the other process can't be read, and
+// fail every operation instead.
+func OpenReadOnly(repoPath string) (repo.Repo, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	r.readOnly = true
+	r.lockfile = nopCloser{}
+
+	if err := checkInitialized(r.path); err != nil {
+		return nil, err
+	}
+
+	ver, err := mfsr.RepoPath(r.path).Version()
+	if err != nil {
+		return nil, err
+	}
+	if ver != RepoVersion {
+		// migrating needs the repo lock
+		return nil, errors.New("cannot open a repo that needs migrating read-only, run 'ipfs repo migrate' first")
+	}
+
+	if err := r.openConfig(Options{}); err != nil {
+		return nil, err
+	}
+	if err := r.openDatastore(); err != nil {
+		return nil, err
+	}
+	if err := r.openKeystore(); err != nil {
+		return nil, err
+	}
+	r.keys = readOnlyKeystore{r.keys}
+	return r, nil
+}
+
+// LockOwner returns the pid of the process holding the repo lock at
+// repoPath, when the lock records it.
+func LockOwner(repoPath string) (int, bool) {
+	return lockfile.Owner(repoPath)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// readOnlyDatastore rejects writes to the datastore it wraps.
+type readOnlyDatastore struct {
+	ds.Batching
+}
+
+func (readOnlyDatastore) Put(ds.Key, interface{}) error { return ErrReadOnly }
+
+func (readOnlyDatastore) Delete(ds.Key) error { return ErrReadOnly }
+
+func (readOnlyDatastore) Batch() (ds.Batch, error) { return nil, ErrReadOnly }
+
+func (d readOnlyDatastore) Close() error {
+	if c, ok := d.Batching.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// readOnlyKeystore rejects changes to the keystore it wraps.
+type readOnlyKeystore struct {
+	keystore.Keystore
+}
+
+func (readOnlyKeystore) Put(string, ci.PrivKey) error { return ErrReadOnly }
+
+func (readOnlyKeystore) Delete(string) error { return ErrReadOnly }
+
+// unavailableDatastore stands in for a datastore that couldn't be opened
+// read-only, usually because another process holds it open.
+type unavailableDatastore struct {
+	err error
+}
+
+func (d unavailableDatastore) Put(ds.Key, interface{}) error { return d.err }
+
+func (d unavailableDatastore) Get(ds.Key) (interface{}, error) { return nil, d.err }
+
+func (d unavailableDatastore) Has(ds.Key) (bool, error) { return false, d.err }
+
+func (d unavailableDatastore) Delete(ds.Key) error { return d.err }
+
+func (d unavailableDatastore) Query(dsq.Query) (dsq.Results, error) { return nil, d.err }
+
+func (d unavailableDatastore) Batch() (ds.Batch, error) { return nil, d.err }
+
+func (unavailableDatastore) Close() error { return nil }
@@ -0,0 +1,56 @@
+package fsrepo
+
+// StripComments blanks out the // and /* */ comments in the json config
+// data, outside of strings, so operators can annotate their config. The
+// comments are replaced with spaces, keeping line breaks, so decoding
+// errors still point at the right place in the file.
+func StripComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++ // skip the escaped character
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := i + 2
+			for end+1 < len(out) && !(out[end] == '*' && out[end+1] == '/') {
+				end++
+			}
+			if end+1 >= len(out) {
+				// unterminated, leave it to fail decoding
+				return out
+			}
+			for ; i <= end+1; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
+
+// hasComments returns whether the json config data has comments.
+func hasComments(data []byte) bool {
+	stripped := StripComments(data)
+	for i := range data {
+		if data[i] != stripped[i] {
+			return true
+		}
+	}
+	return false
+}
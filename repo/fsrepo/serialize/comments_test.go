@@ -0,0 +1,68 @@
+package fsrepo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	in := `// the node's config
+{
+	/* where the api listens,
+	   keep it local */
+	"API": "/ip4/127.0.0.1/tcp/5001", // trailing
+	"URL": "http://example.com/a//b /* not a comment */",
+	"Quote": "say \"//hi\""
+}`
+	var m map[string]string
+	if err := json.Unmarshal(StripComments([]byte(in)), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["API"] != "/ip4/127.0.0.1/tcp/5001" ||
+		m["URL"] != "http://example.com/a//b /* not a comment */" ||
+		m["Quote"] != `say "//hi"` {
+		t.Fatalf("comments not stripped correctly: %v", m)
+	}
+	if len(StripComments([]byte(in))) != len(in) {
+		t.Fatal("stripping comments should keep offsets")
+	}
+	if hasComments([]byte(`{"a": "//"}`)) {
+		t.Fatal("no comments expected")
+	}
+}
+
+func TestReadCommentedConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config")
+	data := "{\n  // kept local\n  \"Datastore\": {\"Path\": \"/data\"}\n}\n"
+	if err := ioutil.WriteFile(filename, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Datastore.Path != "/data" {
+		t.Fatalf("wrong config read: %+v", cfg)
+	}
+
+	// writing keeps the commented config as the backup
+	if err := WriteConfigFile(filename, cfg); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := ioutil.ReadFile(filename + BackupSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != data {
+		t.Fatal("the commented config should be backed up")
+	}
+}
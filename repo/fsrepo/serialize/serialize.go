@@ -1,10 +1,11 @@
 package fsrepo
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -16,44 +17,115 @@ import (
 
 var log = logging.Logger("fsrepo")
 
-// ReadConfigFile reads the config from `filename` into `cfg`.
+// ReadConfigFile reads the config from `filename` into `cfg`. The config
+// may have comments, see StripComments.
 func ReadConfigFile(filename string, cfg interface{}) error {
-	f, err := os.Open(filename)
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	if err := json.NewDecoder(f).Decode(cfg); err != nil {
+	if err := json.NewDecoder(bytes.NewReader(StripComments(data))).Decode(cfg); err != nil {
+		if util.FileExists(filename + BackupSuffix) {
+			return fmt.Errorf("Failure to decode config: %s (the previous config can be restored with 'ipfs config restore')", err)
+		}
 		return fmt.Errorf("Failure to decode config: %s", err)
 	}
 	return nil
 }
 
-// WriteConfigFile writes the config from `cfg` into `filename`.
+// BackupSuffix is appended to the config filename to name the copy of the
+// previous config that WriteConfigFile keeps.
+const BackupSuffix = ".bak"
+
+// WriteConfigFile writes the config from `cfg` into `filename`. The new
+// config is written to a temporary file, synced, and renamed over the old
+// one, so a crash leaves either the old or the new config in place. The old
+// config is kept in `filename` + BackupSuffix.
 func WriteConfigFile(filename string, cfg interface{}) error {
 	err := os.MkdirAll(filepath.Dir(filename), 0775)
 	if err != nil {
 		return err
 	}
 
+	// encode before touching the file, so a failure leaves it alone
+	buf, err := config.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := backupConfigFile(filename); err != nil {
+		return err
+	}
+	return writeFileSynced(filename, buf)
+}
+
+// writeFileSynced atomically replaces filename with data, syncing the data
+// and the directory entry to disk.
+func writeFileSynced(filename string, data []byte) error {
 	f, err := atomicfile.New(filename, 0660)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
 
-	return encode(f, cfg)
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
-// encode configuration with JSON
-func encode(w io.Writer, value interface{}) error {
-	// need to prettyprint, hence MarshalIndent, instead of Encoder
-	buf, err := config.Marshal(value)
+// backupConfigFile copies the config at filename to its backup, unless
+// there is no config yet or it can't be decoded, so a good backup is never
+// replaced by a corrupted config.
+func backupConfigFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	_, err = w.Write(buf)
-	return err
+	var v json.RawMessage
+	if err := json.Unmarshal(StripComments(data), &v); err != nil {
+		log.Warningf("not backing up %s: it is not valid json", filename)
+		return nil
+	}
+	if hasComments(data) {
+		log.Warningf("the comments in %s are not kept when it is written, they are in %s", filename, filename+BackupSuffix)
+	}
+	return writeFileSynced(filename+BackupSuffix, data)
+}
+
+// RestoreBackup replaces the config at filename with the backup kept by
+// WriteConfigFile.
+func RestoreBackup(filename string) error {
+	data, err := ioutil.ReadFile(filename + BackupSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no config backup found at %s", filename+BackupSuffix)
+		}
+		return err
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(StripComments(data), &cfg); err != nil {
+		return fmt.Errorf("config backup is corrupted: %s", err)
+	}
+	return writeFileSynced(filename, data)
 }
 
 // Load reads given file and returns the read config, or error.
@@ -68,6 +140,17 @@ func Load(filename string) (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := config.Validate(&cfg); err != nil {
+		return nil, err
+	}
+
+	var mapconf map[string]interface{}
+	if err := ReadConfigFile(filename, &mapconf); err != nil {
+		return nil, err
+	}
+	for _, k := range config.UnknownKeys(mapconf) {
+		log.Warningf("unknown config key %s in %s", k, filename)
+	}
 
 	// tilde expansion on datastore path
 	// TODO why is this here??
@@ -1,7 +1,9 @@
 package fsrepo
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	config "github.com/ipfs/go-ipfs/repo/config"
@@ -32,3 +34,56 @@ func TestConfig(t *testing.T) {
 		t.Errorf("config file should not be executable or accessible to world: %v", g)
 	}
 }
+
+func TestConfigBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-config-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config")
+
+	for _, p := range []string{"/first", "/second"} {
+		cfg := new(config.Config)
+		cfg.Datastore.Path = p
+		if err := WriteConfigFile(filename, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// corrupt the config, as a crash in the middle of a plain write could
+	if err := ioutil.WriteFile(filename, []byte(`{"Datastore": {"Pa`), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(filename); err == nil {
+		t.Fatal("expected an error loading a truncated config")
+	}
+
+	if err := RestoreBackup(filename); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Datastore.Path != "/first" {
+		t.Fatalf("restored the wrong config: datastore path %q", cfg.Datastore.Path)
+	}
+
+	// writing over the corrupted config must not have replaced the backup
+	if err := ioutil.WriteFile(filename, []byte(`{`), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFile(filename, new(config.Config)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBackup(filename); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = Load(filename); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Datastore.Path != "/first" {
+		t.Fatalf("backup was replaced by a corrupted config: datastore path %q", cfg.Datastore.Path)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	"github.com/ipfs/go-ipfs/repo/config"
 )
 
@@ -13,6 +14,7 @@ var errTODO = errors.New("TODO")
 type Mock struct {
 	C config.Config
 	D ds.ThreadSafeDatastore
+	K keystore.Keystore
 }
 
 func (m *Mock) Config() (*config.Config, error) {
@@ -25,15 +27,35 @@ func (m *Mock) SetConfig(updated *config.Config) error {
 }
 
 func (m *Mock) SetConfigKey(key string, value interface{}) error {
-	return errTODO
+	mapconf, err := config.ToMap(&m.C)
+	if err != nil {
+		return err
+	}
+	if err := config.SetKey(mapconf, key, value); err != nil {
+		return err
+	}
+	conf, err := config.FromMap(mapconf)
+	if err != nil {
+		return err
+	}
+	m.C = *conf // FIXME threadsafety
+	return nil
 }
 
 func (m *Mock) GetConfigKey(key string) (interface{}, error) {
-	return nil, errTODO
+	mapconf, err := config.ToMap(&m.C)
+	if err != nil {
+		return nil, err
+	}
+	return config.GetKey(mapconf, key)
 }
 
 func (m *Mock) Datastore() ds.ThreadSafeDatastore { return m.D }
 
+func (m *Mock) GetStorageUsage() (uint64, error) { return 0, nil }
+
+func (m *Mock) Keystore() keystore.Keystore { return m.K }
+
 func (m *Mock) Close() error { return errTODO }
 
 func (m *Mock) SetAPIAddr(addr string) error { return errTODO }
@@ -0,0 +1,220 @@
+// Package mockrepo provides a Repo kept entirely in memory, so tests can
+// build full nodes without a repo on disk.
+package mockrepo
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	syncds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	repo "github.com/ipfs/go-ipfs/repo"
+	config "github.com/ipfs/go-ipfs/repo/config"
+)
+
+var errClosed = errors.New("repo is closed")
+
+// Repo is a Repo whose config, datastore and keystore live in memory. It
+// is safe for use by multiple callers.
+type Repo struct {
+	mu      sync.Mutex
+	closed  bool
+	config  *config.Config
+	ds      ds.ThreadSafeDatastore
+	keys    keystore.Keystore
+	apiAddr string
+
+	listeners    map[int]repo.ConfigListener
+	nextListener int
+	notified     *config.Config
+}
+
+var _ repo.Repo = (*Repo)(nil)
+var _ repo.ConfigNotifier = (*Repo)(nil)
+
+// New returns a repo holding a copy of c, with an empty datastore and an
+// empty keystore.
+func New(c config.Config) *Repo {
+	return NewWithDatastore(c, syncds.MutexWrap(ds.NewMapDatastore()))
+}
+
+// NewWithDatastore returns a repo holding a copy of c, using d as its
+// datastore.
+func NewWithDatastore(c config.Config, d ds.ThreadSafeDatastore) *Repo {
+	return &Repo{
+		config: &c,
+		ds:     d,
+		keys:   keystore.NewMemKeystore(),
+	}
+}
+
+func (r *Repo) Config() (*config.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errClosed
+	}
+	return r.config, nil
+}
+
+func (r *Repo) SetConfig(updated *config.Config) error {
+	return r.changeConfig(func() error {
+		*r.config = *updated // copy so caller cannot modify this private config
+		return nil
+	})
+}
+
+func (r *Repo) SetConfigKey(key string, value interface{}) error {
+	return r.changeConfig(func() error {
+		mapconf, err := config.ToMap(r.config)
+		if err != nil {
+			return err
+		}
+		if err := config.SetKey(mapconf, key, value); err != nil {
+			return err
+		}
+		conf, err := config.FromMap(mapconf)
+		if err != nil {
+			return err
+		}
+		*r.config = *conf
+		return nil
+	})
+}
+
+func (r *Repo) GetConfigKey(key string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, errClosed
+	}
+	mapconf, err := config.ToMap(r.config)
+	if err != nil {
+		return nil, err
+	}
+	return config.GetKey(mapconf, key)
+}
+
+// NotifyConfig calls l after every change to the config, until the
+// returned function is called.
+func (r *Repo) NotifyConfig(l repo.ConfigListener) (cancel func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.notified == nil {
+		r.notified, _ = r.config.Clone()
+	}
+	if r.listeners == nil {
+		r.listeners = make(map[int]repo.ConfigListener)
+	}
+	id := r.nextListener
+	r.nextListener++
+	r.listeners[id] = l
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.listeners, id)
+		if len(r.listeners) == 0 {
+			r.notified = nil
+		}
+	}
+}
+
+// changeConfig runs change, which updates the config, holding r.mu, and
+// then calls the listeners, as the FSRepo does.
+func (r *Repo) changeConfig(change func() error) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errClosed
+	}
+	if err := change(); err != nil || len(r.listeners) == 0 {
+		r.mu.Unlock()
+		return err
+	}
+
+	// the config may have been changed in place, so the old one is the
+	// copy kept from the last change
+	old := r.notified
+	updated, err := r.config.Clone()
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	r.notified = updated
+	listeners := make([]repo.ConfigListener, 0, len(r.listeners))
+	for _, l := range r.listeners {
+		listeners = append(listeners, l)
+	}
+	r.mu.Unlock()
+
+	if old == nil {
+		old = updated
+	}
+	for _, l := range listeners {
+		l(old, updated)
+	}
+	return nil
+}
+
+func (r *Repo) Datastore() ds.ThreadSafeDatastore {
+	return r.ds
+}
+
+func (r *Repo) Keystore() keystore.Keystore {
+	return r.keys
+}
+
+// GetStorageUsage returns the size of the values in the datastore.
+func (r *Repo) GetStorageUsage() (uint64, error) {
+	res, err := r.ds.Query(dsq.Query{})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	var du uint64
+	for e := range res.Next() {
+		if e.Error != nil {
+			return 0, e.Error
+		}
+		if b, ok := e.Value.([]byte); ok {
+			du += uint64(len(b))
+		}
+	}
+	return du, nil
+}
+
+func (r *Repo) SetAPIAddr(addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	r.apiAddr = addr
+	return nil
+}
+
+// APIAddr returns the address set with SetAPIAddr.
+func (r *Repo) APIAddr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.apiAddr
+}
+
+// Close closes the datastore, if it can be closed.
+func (r *Repo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errClosed
+	}
+	r.closed = true
+	if c, ok := r.ds.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package mockrepo
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	keystore "github.com/ipfs/go-ipfs/keystore"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	"github.com/ipfs/go-ipfs/thirdparty/assert"
+)
+
+func TestConfig(t *testing.T) {
+	r := New(config.Config{Gateway: config.Gateway{RootRedirect: "/start"}})
+
+	var updated string
+	cancel := r.NotifyConfig(func(_, u *config.Config) {
+		updated = u.Gateway.RootRedirect
+	})
+	defer cancel()
+
+	assert.Nil(r.SetConfigKey("Gateway.RootRedirect", "/key"), t)
+	v, err := r.GetConfigKey("Gateway.RootRedirect")
+	assert.Nil(err, t)
+	assert.True(v == "/key", t, "the key should be set")
+	assert.True(updated == "/key", t, "listeners should be told about SetConfigKey")
+
+	c, err := r.Config()
+	assert.Nil(err, t)
+	c.Gateway.RootRedirect = "/config"
+	assert.Nil(r.SetConfig(c), t)
+	assert.True(updated == "/config", t, "listeners should be told about SetConfig")
+}
+
+func TestStores(t *testing.T) {
+	r := New(config.Config{})
+
+	assert.Nil(r.Datastore().Put(ds.NewKey("/a"), []byte("four")), t)
+	du, err := r.GetStorageUsage()
+	assert.Nil(err, t)
+	assert.True(du == 4, t, "the usage should count the values")
+
+	_, err = keystore.Create(r.Keystore(), "extra", ci.RSA, 512)
+	assert.Nil(err, t)
+	names, err := r.Keystore().List()
+	assert.Nil(err, t)
+	assert.True(len(names) == 1 && names[0] == "extra", t, "the key should be listed")
+
+	assert.Nil(r.Close(), t)
+	_, err = r.Config()
+	assert.Err(err, t, "the config should not be accessible after Close")
+}
@@ -70,3 +70,8 @@ func (r *ref) Close() error {
 	delete(r.parent.active, r.key)
 	return r.Repo.Close()
 }
+
+// NotifyConfig passes the listener on to the open Repo, see ConfigNotifier.
+func (r *ref) NotifyConfig(l ConfigListener) (cancel func()) {
+	return NotifyConfig(r.Repo, l)
+}
@@ -6,6 +6,7 @@ import (
 
 	datastore "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 
+	keystore "github.com/ipfs/go-ipfs/keystore"
 	config "github.com/ipfs/go-ipfs/repo/config"
 )
 
@@ -22,8 +23,35 @@ type Repo interface {
 
 	Datastore() datastore.ThreadSafeDatastore
 
+	// Keystore returns the keys the node has besides its identity.
+	Keystore() keystore.Keystore
+
+	// GetStorageUsage returns the number of bytes the repo takes on disk.
+	GetStorageUsage() (uint64, error)
+
 	// SetAPIAddr sets the API address in the repo.
 	SetAPIAddr(addr string) error
 
 	io.Closer
 }
+
+// ConfigListener is told about a change to the config of a repo, with the
+// config before and after it. It must not change either.
+type ConfigListener func(old, updated *config.Config)
+
+// ConfigNotifier is implemented by the repos that tell listeners about
+// changes to their config, so they can be applied without a restart.
+type ConfigNotifier interface {
+	// NotifyConfig calls l after every change to the config, until the
+	// returned function is called.
+	NotifyConfig(l ConfigListener) (cancel func())
+}
+
+// NotifyConfig registers l with r, if r tells about config changes. It
+// returns the function that unregisters l.
+func NotifyConfig(r Repo, l ConfigListener) (cancel func()) {
+	if n, ok := r.(ConfigNotifier); ok {
+		return n.NotifyConfig(l)
+	}
+	return func() {}
+}
@@ -0,0 +1,145 @@
+// package compose combines routing systems into one: queried all at once,
+// merging their results, or one after the other.
+package compose
+
+import (
+	"sync"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("routing/compose")
+
+// Composition is a routing system made of others. The node looks through
+// it for the routers it needs, such as its DHT.
+type Composition interface {
+	routing.IpfsRouting
+	Routers() []routing.IpfsRouting
+}
+
+// writeAll runs the write op on every router, one after the other or all
+// at once. It succeeds when one of the routers did, routers not supporting
+// the operation aside.
+func writeAll(routers []routing.IpfsRouting, parallel bool, op func(routing.IpfsRouting) error) error {
+	errs := make([]error, len(routers))
+	if parallel {
+		var wg sync.WaitGroup
+		for i, r := range routers {
+			wg.Add(1)
+			go func(i int, r routing.IpfsRouting) {
+				defer wg.Done()
+				errs[i] = op(r)
+			}(i, r)
+		}
+		wg.Wait()
+	} else {
+		for i, r := range routers {
+			errs[i] = op(r)
+		}
+	}
+
+	err := routing.ErrNotSupported
+	for _, e := range errs {
+		switch e {
+		case nil:
+			return nil
+		case routing.ErrNotSupported:
+		default:
+			if err == routing.ErrNotSupported {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// firstError returns the error to report when no router found anything:
+// the first one that isn't ErrNotFound or ErrNotSupported, if any.
+func firstError(errs []error) error {
+	for _, e := range errs {
+		if e != nil && e != routing.ErrNotFound && e != routing.ErrNotSupported {
+			return e
+		}
+	}
+	return routing.ErrNotFound
+}
+
+// timeoutRouter bounds every query to its router with a timeout.
+type timeoutRouter struct {
+	r       routing.IpfsRouting
+	timeout time.Duration
+}
+
+// WithTimeout returns r with its queries cancelled after timeout, so a slow
+// router doesn't hold up the ones composed with it.
+func WithTimeout(r routing.IpfsRouting, timeout time.Duration) routing.IpfsRouting {
+	if timeout <= 0 {
+		return r
+	}
+	return &timeoutRouter{r: r, timeout: timeout}
+}
+
+func (t *timeoutRouter) Routers() []routing.IpfsRouting {
+	return []routing.IpfsRouting{t.r}
+}
+
+func (t *timeoutRouter) FindProvidersAsync(ctx context.Context, k key.Key, count int) <-chan peer.PeerInfo {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	in := t.r.FindProvidersAsync(ctx, k, count)
+	out := make(chan peer.PeerInfo)
+	go func() {
+		defer cancel()
+		defer close(out)
+		for pi := range in {
+			select {
+			case out <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (t *timeoutRouter) PutValue(ctx context.Context, k key.Key, v []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.r.PutValue(ctx, k, v)
+}
+
+func (t *timeoutRouter) GetValue(ctx context.Context, k key.Key) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.r.GetValue(ctx, k)
+}
+
+func (t *timeoutRouter) GetValues(ctx context.Context, k key.Key, count int) ([]routing.RecvdVal, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.r.GetValues(ctx, k, count)
+}
+
+func (t *timeoutRouter) Provide(ctx context.Context, k key.Key) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.r.Provide(ctx, k)
+}
+
+func (t *timeoutRouter) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.r.FindPeer(ctx, id)
+}
+
+// Bootstrap is not bounded, it only starts the bootstrapping of the router.
+func (t *timeoutRouter) Bootstrap(ctx context.Context) error {
+	return t.r.Bootstrap(ctx)
+}
+
+var _ Composition = &timeoutRouter{}
@@ -0,0 +1,64 @@
+package compose
+
+import (
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	static "github.com/ipfs/go-ipfs/routing/static"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+func providers(r routing.IpfsRouting, k key.Key, count int) []peer.ID {
+	var ids []peer.ID
+	for pi := range r.FindProvidersAsync(context.Background(), k, count) {
+		ids = append(ids, pi.ID)
+	}
+	return ids
+}
+
+func TestCompositions(t *testing.T) {
+	k := key.Key("foo")
+	var pis []peer.PeerInfo
+	for i := 0; i < 3; i++ {
+		pis = append(pis, peer.PeerInfo{ID: testutil.RandPeerIDFatal(t)})
+	}
+
+	first := static.NewRouter(map[key.Key][]peer.PeerInfo{k: pis[:1]}, nil)
+	second := static.NewRouter(map[key.Key][]peer.PeerInfo{k: pis[1:]}, nil)
+	empty := static.NewRouter(nil, nil)
+
+	cases := []struct {
+		name  string
+		r     routing.IpfsRouting
+		count int
+		want  int
+	}{
+		{"sequential", Sequential{first, second}, 10, 1},
+		{"sequential skipping empty", Sequential{empty, second}, 10, 2},
+		{"tiered", Tiered{first, second}, 10, 3},
+		{"tiered enough", Tiered{first, second}, 2, 2},
+		{"parallel", Parallel{first, second}, 10, 3},
+		{"parallel with timeout", Parallel{WithTimeout(first, time.Second), second}, 10, 3},
+	}
+	for _, c := range cases {
+		got := providers(c.r, k, c.count)
+		if len(got) != c.want {
+			t.Errorf("%s: found %d providers, expected %d", c.name, len(got), c.want)
+		}
+	}
+
+	if _, err := (Sequential{empty, first}).FindPeer(context.Background(), pis[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Parallel{empty, first}).FindPeer(context.Background(), pis[2].ID); err != routing.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := (Tiered{empty, first}).PutValue(context.Background(), k, []byte("bar")); err != routing.ErrNotSupported {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
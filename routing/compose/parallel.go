@@ -0,0 +1,157 @@
+package compose
+
+import (
+	"sync"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	pset "github.com/ipfs/go-ipfs/util/peerset"
+)
+
+// Parallel queries all its routers at once. The providers and values they
+// find are merged, and the first peer or value found is taken. Writes go
+// to every router.
+type Parallel []routing.IpfsRouting
+
+func (p Parallel) Routers() []routing.IpfsRouting {
+	return p
+}
+
+func (p Parallel) FindProvidersAsync(ctx context.Context, k key.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	ctx, cancel := context.WithCancel(ctx)
+	ps := pset.NewLimited(count)
+
+	var wg sync.WaitGroup
+	for _, r := range p {
+		wg.Add(1)
+		go func(r routing.IpfsRouting) {
+			defer wg.Done()
+			for pi := range r.FindProvidersAsync(ctx, k, count) {
+				if !ps.TryAdd(pi.ID) {
+					continue
+				}
+				select {
+				case out <- pi:
+				case <-ctx.Done():
+					return
+				}
+				if ps.Size() >= count {
+					// enough, the other routers can stop
+					cancel()
+					return
+				}
+			}
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out
+}
+
+func (p Parallel) PutValue(ctx context.Context, k key.Key, v []byte) error {
+	return writeAll(p, true, func(r routing.IpfsRouting) error {
+		return r.PutValue(ctx, k, v)
+	})
+}
+
+func (p Parallel) GetValue(ctx context.Context, k key.Key) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		val []byte
+		err error
+	}
+	results := make(chan result, len(p))
+	for _, r := range p {
+		go func(r routing.IpfsRouting) {
+			val, err := r.GetValue(ctx, k)
+			results <- result{val, err}
+		}(r)
+	}
+
+	errs := make([]error, 0, len(p))
+	for range p {
+		res := <-results
+		if res.err == nil {
+			return res.val, nil
+		}
+		errs = append(errs, res.err)
+	}
+	return nil, firstError(errs)
+}
+
+func (p Parallel) GetValues(ctx context.Context, k key.Key, count int) ([]routing.RecvdVal, error) {
+	vals := make([][]routing.RecvdVal, len(p))
+	errs := make([]error, len(p))
+	var wg sync.WaitGroup
+	for i, r := range p {
+		wg.Add(1)
+		go func(i int, r routing.IpfsRouting) {
+			defer wg.Done()
+			vals[i], errs[i] = r.GetValues(ctx, k, count)
+		}(i, r)
+	}
+	wg.Wait()
+
+	var all []routing.RecvdVal
+	for _, v := range vals {
+		all = append(all, v...)
+	}
+	if len(all) == 0 {
+		return nil, firstError(errs)
+	}
+	return all, nil
+}
+
+func (p Parallel) Provide(ctx context.Context, k key.Key) error {
+	return writeAll(p, true, func(r routing.IpfsRouting) error {
+		return r.Provide(ctx, k)
+	})
+}
+
+func (p Parallel) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		pi  peer.PeerInfo
+		err error
+	}
+	results := make(chan result, len(p))
+	for _, r := range p {
+		go func(r routing.IpfsRouting) {
+			pi, err := r.FindPeer(ctx, id)
+			results <- result{pi, err}
+		}(r)
+	}
+
+	errs := make([]error, 0, len(p))
+	for range p {
+		res := <-results
+		if res.err == nil {
+			return res.pi, nil
+		}
+		errs = append(errs, res.err)
+	}
+	return peer.PeerInfo{}, firstError(errs)
+}
+
+func (p Parallel) Bootstrap(ctx context.Context) error {
+	var err error
+	for _, r := range p {
+		if e := r.Bootstrap(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+var _ Composition = Parallel{}
@@ -0,0 +1,166 @@
+package compose
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	pset "github.com/ipfs/go-ipfs/util/peerset"
+)
+
+// Sequential queries its routers one after the other, until one finds
+// something: the next router is only asked when the previous ones found
+// nothing. Writes go to every router, in order.
+type Sequential []routing.IpfsRouting
+
+// Tiered queries its routers one after the other, like Sequential, but goes
+// on to the next router until enough providers or values are found,
+// merging them. Cheap, local routers go first, and the expensive ones fill
+// in what they miss.
+type Tiered []routing.IpfsRouting
+
+func (s Sequential) Routers() []routing.IpfsRouting {
+	return s
+}
+
+func (s Sequential) FindProvidersAsync(ctx context.Context, k key.Key, count int) <-chan peer.PeerInfo {
+	return findProvidersInTurn(ctx, s, k, count, false)
+}
+
+func (s Sequential) PutValue(ctx context.Context, k key.Key, v []byte) error {
+	return writeAll(s, false, func(r routing.IpfsRouting) error {
+		return r.PutValue(ctx, k, v)
+	})
+}
+
+func (s Sequential) GetValue(ctx context.Context, k key.Key) ([]byte, error) {
+	return getValueInTurn(ctx, s, k)
+}
+
+func (s Sequential) GetValues(ctx context.Context, k key.Key, count int) ([]routing.RecvdVal, error) {
+	return getValuesInTurn(ctx, s, k, count, false)
+}
+
+func (s Sequential) Provide(ctx context.Context, k key.Key) error {
+	return writeAll(s, false, func(r routing.IpfsRouting) error {
+		return r.Provide(ctx, k)
+	})
+}
+
+func (s Sequential) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	return findPeerInTurn(ctx, s, id)
+}
+
+func (s Sequential) Bootstrap(ctx context.Context) error {
+	return Parallel(s).Bootstrap(ctx)
+}
+
+func (t Tiered) Routers() []routing.IpfsRouting {
+	return t
+}
+
+func (t Tiered) FindProvidersAsync(ctx context.Context, k key.Key, count int) <-chan peer.PeerInfo {
+	return findProvidersInTurn(ctx, t, k, count, true)
+}
+
+func (t Tiered) PutValue(ctx context.Context, k key.Key, v []byte) error {
+	return Sequential(t).PutValue(ctx, k, v)
+}
+
+func (t Tiered) GetValue(ctx context.Context, k key.Key) ([]byte, error) {
+	return getValueInTurn(ctx, t, k)
+}
+
+func (t Tiered) GetValues(ctx context.Context, k key.Key, count int) ([]routing.RecvdVal, error) {
+	return getValuesInTurn(ctx, t, k, count, true)
+}
+
+func (t Tiered) Provide(ctx context.Context, k key.Key) error {
+	return Sequential(t).Provide(ctx, k)
+}
+
+func (t Tiered) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	return findPeerInTurn(ctx, t, id)
+}
+
+func (t Tiered) Bootstrap(ctx context.Context) error {
+	return Parallel(t).Bootstrap(ctx)
+}
+
+// findProvidersInTurn asks the routers for providers one after the other,
+// stopping after the first router that found some, or when merge is set,
+// once count providers were found.
+func findProvidersInTurn(ctx context.Context, routers []routing.IpfsRouting, k key.Key, count int, merge bool) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	go func() {
+		defer close(out)
+		ps := pset.NewLimited(count)
+		for _, r := range routers {
+			for pi := range r.FindProvidersAsync(ctx, k, count-ps.Size()) {
+				if !ps.TryAdd(pi.ID) {
+					continue
+				}
+				select {
+				case out <- pi:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ps.Size() >= count || ps.Size() > 0 && !merge {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func getValueInTurn(ctx context.Context, routers []routing.IpfsRouting, k key.Key) ([]byte, error) {
+	errs := make([]error, 0, len(routers))
+	for _, r := range routers {
+		val, err := r.GetValue(ctx, k)
+		if err == nil {
+			return val, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, firstError(errs)
+}
+
+// getValuesInTurn asks the routers for values one after the other, stopping
+// after the first router that found some, or when merge is set, once count
+// values were found.
+func getValuesInTurn(ctx context.Context, routers []routing.IpfsRouting, k key.Key, count int, merge bool) ([]routing.RecvdVal, error) {
+	var all []routing.RecvdVal
+	errs := make([]error, 0, len(routers))
+	for _, r := range routers {
+		vals, err := r.GetValues(ctx, k, count-len(all))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		all = append(all, vals...)
+		if len(all) >= count || len(all) > 0 && !merge {
+			break
+		}
+	}
+	if len(all) == 0 {
+		return nil, firstError(errs)
+	}
+	return all, nil
+}
+
+func findPeerInTurn(ctx context.Context, routers []routing.IpfsRouting, id peer.ID) (peer.PeerInfo, error) {
+	errs := make([]error, 0, len(routers))
+	for _, r := range routers {
+		pi, err := r.FindPeer(ctx, id)
+		if err == nil {
+			return pi, nil
+		}
+		errs = append(errs, err)
+	}
+	return peer.PeerInfo{}, firstError(errs)
+}
+
+var _ Composition = Sequential{}
+var _ Composition = Tiered{}
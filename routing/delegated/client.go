@@ -0,0 +1,290 @@
+// package delegated implements a routing system that sends its queries to
+// the HTTP API of another ipfs node, which runs them through its own DHT.
+// It lets nodes that can't, or shouldn't, take part in the DHT rely on a
+// router they trust.
+package delegated
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	notif "github.com/ipfs/go-ipfs/notifications"
+	"github.com/ipfs/go-ipfs/p2p/host"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	logging "github.com/ipfs/go-ipfs/vendor/QmTBXYb6y2ZcJmoXVKk3pf9rzSEjbCg7tQaJW7RSuH14nv/go-log"
+)
+
+var log = logging.Logger("routing/delegated")
+
+var errNoRouters = errors.New("delegated routing requires at least 1 router")
+
+// Client is a routing.IpfsRouting running its queries on delegated routers,
+// the API servers of other nodes.
+type Client struct {
+	host          host.Host
+	routers       []string // base urls of the APIs, tried in order
+	authorization string
+	transport     *http.Transport
+}
+
+// NewClient returns a Client for the routers, given as API addresses such
+// as "127.0.0.1:5001". authorization, when set, is sent as the
+// Authorization header of the requests. Providers are announced as h.
+func NewClient(h host.Host, routers []string, authorization string) (*Client, error) {
+	if len(routers) == 0 {
+		return nil, errNoRouters
+	}
+	c := &Client{
+		host:          h,
+		authorization: authorization,
+		transport:     &http.Transport{},
+	}
+	for _, r := range routers {
+		c.routers = append(c.routers, "http://"+r+"/api/v0/")
+	}
+	return c, nil
+}
+
+// query runs the command cmd on the first router answering, with the
+// arguments args and the options opts, and calls handle with each of the
+// query events it streams back. It returns the error of the query.
+func (c *Client) query(ctx context.Context, cmd string, args []string, opts url.Values, handle func(*notif.QueryEvent) error) error {
+	// ends the requests, and what waits on them, with the query
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q := url.Values{}
+	for k, v := range opts {
+		q[k] = v
+	}
+	q["arg"] = args
+	q.Set("encoding", "json")
+	q.Set("stream-channels", "true")
+
+	var err error
+	for _, r := range c.routers {
+		var resp *http.Response
+		resp, err = c.post(ctx, r+cmd+"?"+q.Encode())
+		if err != nil {
+			log.Debugf("delegated routing: %s: %s", r, err)
+			continue
+		}
+		defer resp.Body.Close()
+		return readEvents(resp.Body, handle)
+	}
+	return err
+}
+
+func (c *Client) post(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		var e struct{ Message string }
+		if json.Unmarshal(msg, &e) == nil && e.Message != "" {
+			msg = []byte(e.Message)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// do sends req, cancelling it when ctx is done.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		resp, err := (&http.Client{Transport: c.transport}).Do(req)
+		rc <- result{resp, err}
+	}()
+
+	select {
+	case r := <-rc:
+		if r.err == nil {
+			// reading the body stops with ctx too
+			go func() {
+				<-ctx.Done()
+				c.transport.CancelRequest(req)
+			}()
+		}
+		return r.resp, r.err
+	case <-ctx.Done():
+		c.transport.CancelRequest(req)
+		if r := <-rc; r.err == nil {
+			r.resp.Body.Close()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// readEvents decodes the query events streamed in r. The errors of the
+// single peers of the query are skipped, the query goes on without them,
+// but the error of the query itself is returned.
+func readEvents(r io.Reader, handle func(*notif.QueryEvent) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		ev := new(notif.QueryEvent)
+		err := dec.Decode(ev)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ev.Type == notif.QueryError && ev.ID == "" {
+			return errors.New(ev.Extra)
+		}
+		if err := handle(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// dhtKeyArg returns k as the dht commands take it: base58 encoded, after
+// the namespace of the keys such as /ipns/<key>.
+func dhtKeyArg(k key.Key) string {
+	s := string(k)
+	if strings.HasPrefix(s, "/") {
+		if i := strings.Index(s[1:], "/"); i >= 0 {
+			return s[:i+2] + key.B58KeyEncode(key.Key(s[i+2:]))
+		}
+	}
+	return key.B58KeyEncode(k)
+}
+
+func (c *Client) FindProvidersAsync(ctx context.Context, k key.Key, max int) <-chan peer.PeerInfo {
+	defer log.EventBegin(ctx, "findProviders", &k).Done()
+	ch := make(chan peer.PeerInfo)
+	go func() {
+		defer close(ch)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		found := 0
+		err := c.query(ctx, "dht/findprovs", []string{k.B58String()}, nil, func(ev *notif.QueryEvent) error {
+			if ev.Type != notif.Provider || len(ev.Responses) == 0 {
+				return nil
+			}
+			select {
+			case ch <- *ev.Responses[0]:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			found++
+			if found >= max {
+				return io.EOF
+			}
+			return nil
+		})
+		if err != nil && err != io.EOF {
+			log.Debugf("delegated findprovs %s: %s", k, err)
+		}
+	}()
+	return ch
+}
+
+func (c *Client) PutValue(ctx context.Context, k key.Key, v []byte) error {
+	defer log.EventBegin(ctx, "putValue", &k).Done()
+	return c.query(ctx, "dht/put", []string{dhtKeyArg(k), string(v)}, nil, func(*notif.QueryEvent) error {
+		return nil
+	})
+}
+
+func (c *Client) GetValue(ctx context.Context, k key.Key) ([]byte, error) {
+	vals, err := c.GetValues(ctx, k, 1)
+	if err != nil {
+		return nil, err
+	}
+	return vals[0].Val, nil
+}
+
+// GetValues returns the value the router selected among the ones it found,
+// from the router.
+func (c *Client) GetValues(ctx context.Context, k key.Key, _ int) ([]routing.RecvdVal, error) {
+	defer log.EventBegin(ctx, "getValue", &k).Done()
+	var val []byte
+	opts := url.Values{"value-encoding": {"base64"}}
+	err := c.query(ctx, "dht/get", []string{dhtKeyArg(k)}, opts, func(ev *notif.QueryEvent) error {
+		if ev.Type != notif.Value {
+			return nil
+		}
+		var err error
+		val, err = base64.StdEncoding.DecodeString(ev.Extra)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, routing.ErrNotFound
+	}
+	return []routing.RecvdVal{{Val: val}}, nil
+}
+
+// Provide has the router record this node as a provider of k. The router
+// gives it out to the peers asking it for providers of k, including the
+// other nodes delegating their routing to it.
+func (c *Client) Provide(ctx context.Context, k key.Key) error {
+	defer log.EventBegin(ctx, "provide", &k).Done()
+	addrs := make([]string, 0, len(c.host.Addrs()))
+	for _, a := range c.host.Addrs() {
+		addrs = append(addrs, a.String())
+	}
+	opts := url.Values{
+		"peer":  {peer.IDB58Encode(c.host.ID())},
+		"addrs": {strings.Join(addrs, ",")},
+	}
+	return c.query(ctx, "dht/provide", []string{k.B58String()}, opts, func(*notif.QueryEvent) error {
+		return nil
+	})
+}
+
+func (c *Client) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	defer log.EventBegin(ctx, "findPeer", id).Done()
+	var pi peer.PeerInfo
+	err := c.query(ctx, "dht/findpeer", []string{peer.IDB58Encode(id)}, nil, func(ev *notif.QueryEvent) error {
+		if ev.Type == notif.FinalPeer && len(ev.Responses) > 0 {
+			pi = *ev.Responses[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	if pi.ID != id {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return pi, nil
+}
+
+// Bootstrap does nothing, the routers take care of their own routing.
+func (c *Client) Bootstrap(ctx context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Client{}
@@ -0,0 +1,98 @@
+package delegated
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	notif "github.com/ipfs/go-ipfs/notifications"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+// router answers the dht commands with the events of events, by path.
+func router(t *testing.T, events map[string][]*notif.QueryEvent) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evs, ok := events[strings.TrimPrefix(r.URL.Path, "/api/v0/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("stream-channels") != "true" {
+			t.Errorf("%s: expected the events to be streamed", r.URL)
+		}
+		enc := json.NewEncoder(w)
+		for _, ev := range evs {
+			if err := enc.Encode(ev); err != nil {
+				t.Error(err)
+			}
+		}
+	}))
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	prov := testutil.RandPeerIDFatal(t)
+	other := testutil.RandPeerIDFatal(t)
+	val := []byte{0, 1, 2, 0xff}
+
+	srv := router(t, map[string][]*notif.QueryEvent{
+		"dht/findprovs": {
+			{Type: notif.SendingQuery, ID: other},
+			{Type: notif.QueryError, ID: other, Extra: "dial failed"},
+			{Type: notif.Provider, Responses: []*peer.PeerInfo{{ID: prov}}},
+		},
+		"dht/get": {
+			{Type: notif.Value, Extra: base64.StdEncoding.EncodeToString(val)},
+		},
+		"dht/findpeer": {
+			{Type: notif.QueryError, Extra: "routing: not found"},
+		},
+	})
+	defer srv.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// the first router is down, the queries go to the second
+	c, err := NewClient(nil, []string{strings.TrimPrefix(down.URL, "http://"), strings.TrimPrefix(srv.URL, "http://")}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var provs []peer.ID
+	for pi := range c.FindProvidersAsync(ctx, key.Key("foo"), 10) {
+		provs = append(provs, pi.ID)
+	}
+	if len(provs) != 1 || provs[0] != prov {
+		t.Fatalf("expected %s as the only provider, got %v", prov, provs)
+	}
+
+	got, err := c.GetValue(ctx, key.Key("/ipns/foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(val) {
+		t.Fatalf("expected the value %v, got %v", val, got)
+	}
+
+	if _, err := c.FindPeer(ctx, testutil.RandPeerIDFatal(t)); err == nil || err.Error() != "routing: not found" {
+		t.Fatalf("expected the error of the router, got %v", err)
+	}
+}
+
+func TestDhtKeyArg(t *testing.T) {
+	k := key.Key("\x12\x20binary")
+	if a := dhtKeyArg(k); a != k.B58String() {
+		t.Fatalf("expected %s, got %s", k.B58String(), a)
+	}
+	if a := dhtKeyArg("/ipns/" + k); a != "/ipns/"+k.B58String() {
+		t.Fatalf("expected /ipns/%s, got %s", k.B58String(), a)
+	}
+}
@@ -52,6 +52,9 @@ type IpfsDHT struct {
 	birth    time.Time  // When this peer started up
 	diaglock sync.Mutex // lock to make diagnostics work better
 
+	mode   Mode // server or client, see SetMode
+	modeLk sync.Mutex
+
 	Validator record.Validator // record validator funcs
 	Selector  record.Selector  // record selection funcs
 
@@ -79,7 +82,7 @@ func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *Ip
 	dht.ctx = ctx
 
 	h.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
-	dht.providers = NewProviderManager(dht.ctx, dht.self)
+	dht.providers = NewProviderManager(dht.ctx, dht.self, dstore)
 	dht.proc.AddChild(dht.providers.proc)
 	goprocessctx.CloseAfterContext(dht.proc, ctx)
 
@@ -249,6 +252,10 @@ func (dht *IpfsDHT) putLocal(key key.Key, rec *pb.Record) error {
 // Update signals the routingTable to Update its last-seen status
 // on the given peer.
 func (dht *IpfsDHT) Update(ctx context.Context, p peer.ID) {
+	if !dht.speaksDHT(p) {
+		// clients cannot be queried, keep them out of the table
+		return
+	}
 	log.Event(ctx, "updatePeer", p)
 	dht.routingTable.Update(p)
 }
@@ -15,6 +15,7 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
+	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	netutil "github.com/ipfs/go-ipfs/p2p/test/util"
 	routing "github.com/ipfs/go-ipfs/routing"
@@ -669,6 +670,136 @@ func TestFindPeer(t *testing.T) {
 	}
 }
 
+func TestQueryEvents(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+
+	_, peers, dhts := setupDHTS(ctx, 4, t)
+	defer func() {
+		for i := 0; i < 4; i++ {
+			dhts[i].Close()
+			dhts[i].host.Close()
+		}
+	}()
+
+	connect(t, ctx, dhts[0], dhts[1])
+	connect(t, ctx, dhts[1], dhts[2])
+	connect(t, ctx, dhts[1], dhts[3])
+
+	events := make(chan *notif.QueryEvent)
+	ctxT, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	ctxT = notif.RegisterForQueryEvents(ctxT, events)
+
+	done := make(chan struct{})
+	var got []*notif.QueryEvent
+	go func() {
+		defer close(done)
+		for e := range events {
+			got = append(got, e)
+		}
+	}()
+
+	_, err := dhts[0].FindPeer(ctxT, peers[2])
+	close(events)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent, answered bool
+	for _, e := range got {
+		if e.Time.IsZero() {
+			t.Fatalf("%s event without a time", e.Type)
+		}
+		switch e.Type {
+		case notif.SendingQuery:
+			sent = sent || e.ID == peers[1]
+		case notif.PeerResponse:
+			answered = answered || e.ID == peers[1] && e.Duration > 0
+		}
+	}
+	if !sent || !answered {
+		t.Fatalf("expected %s to be queried and answer, got %v", peers[1], got)
+	}
+
+	last := got[len(got)-1]
+	if last.Type != notif.QueryDone {
+		t.Fatalf("expected the query to end with QueryDone, got %s", last.Type)
+	}
+	if len(last.Responses) == 0 || last.Responses[0].ID != peers[1] {
+		t.Fatalf("expected %s in the closest peers, got %v", peers[1], last.Responses)
+	}
+}
+
+func TestClientMode(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	ctx := context.Background()
+
+	_, peers, dhts := setupDHTS(ctx, 3, t)
+	defer func() {
+		for i := 0; i < 3; i++ {
+			dhts[i].Close()
+			dhts[i].host.Close()
+		}
+	}()
+
+	client := dhts[2]
+	client.SetMode(ModeClient)
+	if client.Mode() != ModeClient {
+		t.Fatalf("expected client mode, got %s", client.Mode())
+	}
+
+	connect(t, ctx, dhts[0], dhts[1])
+	for _, d := range dhts[:2] {
+		client.peerstore.AddAddrs(d.self, d.peerstore.Addrs(d.self), peer.TempAddrTTL)
+		if err := client.host.Connect(ctx, peer.PeerInfo{ID: d.self}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the servers leave the client out of their tables once identified,
+	// while the client keeps them.
+	deadline := time.Now().Add(5 * time.Second)
+	for dhts[0].routingTable.Find(peers[2]) != "" || dhts[1].routingTable.Find(peers[2]) != "" ||
+		client.routingTable.Find(peers[0]) == "" || client.routingTable.Find(peers[1]) == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the servers, and only them, in the routing tables")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	// the client still queries the servers
+	k := key.Key("hello")
+	ctxT, cancel := context.WithTimeout(ctx, time.Second*2)
+	defer cancel()
+	if err := dhts[0].Provide(ctxT, k); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for pi := range client.FindProvidersAsync(ctxT, k, 1) {
+		found = found || pi.ID == peers[0]
+	}
+	if !found {
+		t.Fatalf("expected the client to find %s providing %s", peers[0], k)
+	}
+
+	client.SetMode(ModeServer)
+	var serves bool
+	for _, p := range client.host.Mux().Protocols() {
+		serves = serves || p == ProtocolDHT
+	}
+	if !serves {
+		t.Fatal("expected the node to handle the dht protocol again in server mode")
+	}
+}
+
 func TestFindPeersConnectedToPeer(t *testing.T) {
 	t.Skip("not quite correct (see note)")
 
@@ -3,7 +3,6 @@ package dht
 import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	key "github.com/ipfs/go-ipfs/blocks/key"
-	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	kb "github.com/ipfs/go-ipfs/routing/kbucket"
 	pset "github.com/ipfs/go-ipfs/util/peerset"
@@ -40,16 +39,7 @@ func (dht *IpfsDHT) GetClosestPeers(ctx context.Context, key key.Key) (<-chan pe
 		peerset.Add(p)
 	}
 
-	// since the query doesnt actually pass our context down
-	// we have to hack this here. whyrusleeping isnt a huge fan of goprocess
-	parent := ctx
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		// For DHT query command
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
 		closer, err := dht.closerPeersSingle(ctx, key, p)
 		if err != nil {
 			log.Debugf("error getting closer peers: %s", err)
@@ -68,13 +58,6 @@ func (dht *IpfsDHT) GetClosestPeers(ctx context.Context, key key.Key) (<-chan pe
 			}
 		}
 
-		// For DHT query command
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(filtered),
-		})
-
 		return &dhtQueryResult{closerPeers: filtered}, nil
 	})
 
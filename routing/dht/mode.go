@@ -0,0 +1,108 @@
+package dht
+
+import (
+	"time"
+
+	goprocess "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	identify "github.com/ipfs/go-ipfs/p2p/protocol/identify"
+)
+
+// Mode is the part the node takes in the DHT.
+type Mode int
+
+const (
+	// ModeServer answers the queries of other peers, which keep the node
+	// in their routing tables.
+	ModeServer Mode = iota
+
+	// ModeClient only sends queries. The node doesn't handle the DHT
+	// protocol, so that peers leave it out of their routing tables, as a
+	// node they cannot dial, behind a NAT, degrades the DHT.
+	ModeClient
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeServer:
+		return "server"
+	case ModeClient:
+		return "client"
+	}
+	return "unknown"
+}
+
+// Mode returns the part the node takes in the DHT.
+func (dht *IpfsDHT) Mode() Mode {
+	dht.modeLk.Lock()
+	defer dht.modeLk.Unlock()
+	return dht.mode
+}
+
+// SetMode makes the node a server or a client of the DHT. Peers learn of
+// the change as they identify the node, when they next connect to it.
+func (dht *IpfsDHT) SetMode(m Mode) {
+	dht.modeLk.Lock()
+	defer dht.modeLk.Unlock()
+	if m == dht.mode {
+		return
+	}
+
+	switch m {
+	case ModeServer:
+		dht.host.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
+	case ModeClient:
+		dht.host.RemoveStreamHandler(ProtocolDHT)
+	}
+	dht.mode = m
+	log.Infof("dht: now in %s mode", m)
+}
+
+// AutoModeRetry is how soon AutoMode checks again when a check failed,
+// for instance as the node isn't connected to any peer yet.
+var AutoModeRetry = time.Minute
+
+// autoModeCheckTimeout bounds the reachability checks of AutoMode.
+const autoModeCheckTimeout = time.Minute
+
+// AutoMode starts the node as a client, and makes it a server once
+// reachable says peers can dial it, checking again every period. The
+// node goes back to being a client when it stops being reachable.
+func (dht *IpfsDHT) AutoMode(reachable func(context.Context) (bool, error), period time.Duration) {
+	dht.SetMode(ModeClient)
+	dht.proc.Go(func(proc goprocess.Process) {
+		delay := AutoModeRetry
+		for {
+			select {
+			case <-time.After(delay):
+			case <-proc.Closing():
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(dht.ctx, autoModeCheckTimeout)
+			ok, err := reachable(ctx)
+			cancel()
+			if err != nil {
+				log.Debugf("dht: checking reachability: %s", err)
+				delay = AutoModeRetry
+				continue
+			}
+
+			if ok {
+				dht.SetMode(ModeServer)
+			} else {
+				dht.SetMode(ModeClient)
+			}
+			delay = period
+		}
+	})
+}
+
+// speaksDHT returns whether the peer p serves the DHT. Peers not
+// identified yet are given the benefit of the doubt.
+func (dht *IpfsDHT) speaksDHT(p peer.ID) bool {
+	speaks, known := identify.Speaks(dht.peerstore, p, ProtocolDHT)
+	return speaks || !known
+}
@@ -4,8 +4,15 @@ import (
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 
 	inet "github.com/ipfs/go-ipfs/p2p/net"
+	identify "github.com/ipfs/go-ipfs/p2p/protocol/identify"
 )
 
+// idHost is a host identifying the peers it connects to, as the basic host
+// does.
+type idHost interface {
+	IDService() *identify.IDService
+}
+
 // netNotifiee defines methods to be used with the IpfsDHT
 type netNotifiee IpfsDHT
 
@@ -21,6 +28,19 @@ func (nn *netNotifiee) Connected(n inet.Network, v inet.Conn) {
 	default:
 	}
 	dht.Update(dht.Context(), v.RemotePeer())
+
+	// the peer may turn out to be a client, or a server again, once
+	// identified.
+	if h, ok := dht.host.(idHost); ok {
+		go func() {
+			h.IDService().IdentifyConn(v)
+			if p := v.RemotePeer(); dht.speaksDHT(p) {
+				dht.Update(dht.Context(), p)
+			} else {
+				dht.routingTable.Remove(p)
+			}
+		}()
+	}
 }
 
 func (nn *netNotifiee) Disconnected(n inet.Network, v inet.Conn) {
@@ -1,8 +1,12 @@
 package dht
 
 import (
+	"encoding/binary"
+	"fmt"
 	"time"
 
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess"
 	goprocessctx "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/goprocess/context"
 	key "github.com/ipfs/go-ipfs/blocks/key"
@@ -11,12 +15,33 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 )
 
+// ProvideValidity is how long a provider record is kept after the provider
+// last announced it.
+var ProvideValidity = time.Hour * 24
+
+// MaxProvidersPerKey caps the providers kept for a single key. Past it, the
+// record announced the longest ago is dropped.
+var MaxProvidersPerKey = 256
+
+// MaxProviderKeys caps the keys providers are kept for. While it is reached,
+// records for new keys are dropped, until the cleaner expires old ones.
+var MaxProviderKeys = 100000
+
+// providersCleanupInterval is how often expired records are removed.
+var providersCleanupInterval = time.Hour
+
+// providersKeyPrefix is where provider records are stored in the
+// datastore, as /providers/<key>/<peer>, with the time of the announcement
+// as the value. Both key and peer are base58 encoded.
+var providersKeyPrefix = ds.NewKey("/providers")
+
 type ProviderManager struct {
 	// all non channel fields are meant to be accessed only within
 	// the run method
 	providers map[key.Key]*providerSet
 	local     map[key.Key]struct{}
 	lpeer     peer.ID
+	dstore    ds.Datastore
 
 	getlocal chan chan []key.Key
 	newprovs chan *addProv
@@ -40,13 +65,22 @@ type getProv struct {
 	resp chan []peer.ID
 }
 
-func NewProviderManager(ctx context.Context, local peer.ID) *ProviderManager {
+// NewProviderManager returns a ProviderManager keeping its records in
+// dstore, so they outlive the node. The records already in dstore are
+// loaded, and the expired ones removed.
+func NewProviderManager(ctx context.Context, local peer.ID, dstore ds.Datastore) *ProviderManager {
 	pm := new(ProviderManager)
 	pm.getprovs = make(chan *getProv)
 	pm.newprovs = make(chan *addProv)
 	pm.providers = make(map[key.Key]*providerSet)
 	pm.getlocal = make(chan chan []key.Key)
 	pm.local = make(map[key.Key]struct{})
+	pm.lpeer = local
+	pm.dstore = dstore
+	pm.period = providersCleanupInterval
+	if err := pm.load(); err != nil {
+		log.Errorf("loading provider records: %s", err)
+	}
 	pm.proc = goprocessctx.WithContext(ctx)
 	pm.proc.Go(func(p goprocess.Process) { pm.run() })
 
@@ -54,19 +88,12 @@ func NewProviderManager(ctx context.Context, local peer.ID) *ProviderManager {
 }
 
 func (pm *ProviderManager) run() {
-	tick := time.NewTicker(time.Hour)
+	tick := time.NewTicker(pm.period)
+	defer tick.Stop()
 	for {
 		select {
 		case np := <-pm.newprovs:
-			if np.val == pm.lpeer {
-				pm.local[np.k] = struct{}{}
-			}
-			provs, ok := pm.providers[np.k]
-			if !ok {
-				provs = newProviderSet()
-				pm.providers[np.k] = provs
-			}
-			provs.Add(np.val)
+			pm.addProvider(np.k, np.val, time.Now())
 
 		case gp := <-pm.getprovs:
 			var parr []peer.ID
@@ -85,17 +112,7 @@ func (pm *ProviderManager) run() {
 			lc <- keys
 
 		case <-tick.C:
-			for _, provs := range pm.providers {
-				var filtered []peer.ID
-				for p, t := range provs.set {
-					if time.Now().Sub(t) > time.Hour*24 {
-						delete(provs.set, p)
-					} else {
-						filtered = append(filtered, p)
-					}
-				}
-				provs.providers = filtered
-			}
+			pm.cleanup(time.Now())
 
 		case <-pm.proc.Closing():
 			return
@@ -103,6 +120,102 @@ func (pm *ProviderManager) run() {
 	}
 }
 
+// addProvider records that p provides k, as announced at t, within the
+// size caps.
+func (pm *ProviderManager) addProvider(k key.Key, p peer.ID, t time.Time) {
+	provs, ok := pm.providers[k]
+	if !ok {
+		if len(pm.providers) >= MaxProviderKeys {
+			log.Debugf("dropping provider record for %s: %d keys tracked already", k, len(pm.providers))
+			return
+		}
+		provs = newProviderSet()
+		pm.providers[k] = provs
+	}
+	if p == pm.lpeer {
+		pm.local[k] = struct{}{}
+	}
+	provs.Add(p, t)
+
+	if err := pm.dstore.Put(providerKey(k, p), encodeTime(t)); err != nil {
+		log.Errorf("storing provider record for %s: %s", k, err)
+	}
+
+	for len(provs.providers) > MaxProvidersPerKey {
+		old := provs.Oldest()
+		provs.Remove(old)
+		pm.deleteRecord(k, old)
+	}
+}
+
+// cleanup removes the records announced longer than ProvideValidity
+// before now.
+func (pm *ProviderManager) cleanup(now time.Time) {
+	for k, provs := range pm.providers {
+		for p, t := range provs.set {
+			if now.Sub(t) > ProvideValidity {
+				provs.Remove(p)
+				pm.deleteRecord(k, p)
+			}
+		}
+		if len(provs.providers) == 0 {
+			delete(pm.providers, k)
+		}
+	}
+}
+
+func (pm *ProviderManager) deleteRecord(k key.Key, p peer.ID) {
+	if p == pm.lpeer {
+		delete(pm.local, k)
+	}
+	if err := pm.dstore.Delete(providerKey(k, p)); err != nil && err != ds.ErrNotFound {
+		log.Errorf("deleting provider record for %s: %s", k, err)
+	}
+}
+
+func (pm *ProviderManager) deleteKey(dsk ds.Key) {
+	if err := pm.dstore.Delete(dsk); err != nil {
+		log.Debugf("deleting provider record %s: %s", dsk, err)
+	}
+}
+
+// load reads the provider records stored in the datastore, keeping the
+// ones still valid and deleting the expired or undecodable others.
+func (pm *ProviderManager) load() error {
+	res, err := pm.dstore.Query(dsq.Query{Prefix: providersKeyPrefix.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		dsk := ds.NewKey(e.Key)
+		k, p, err := parseProviderKey(dsk)
+		if err != nil {
+			log.Debugf("dropping provider record %s: %s", dsk, err)
+			pm.deleteKey(dsk)
+			continue
+		}
+		b, ok := e.Value.([]byte)
+		if !ok {
+			log.Debugf("dropping provider record %s: value is not []byte", dsk)
+			pm.deleteKey(dsk)
+			continue
+		}
+		t, err := decodeTime(b)
+		if err != nil || now.Sub(t) > ProvideValidity {
+			pm.deleteKey(dsk)
+			continue
+		}
+		pm.addProvider(k, p, t)
+	}
+	return nil
+}
+
 func (pm *ProviderManager) AddProvider(ctx context.Context, k key.Key, val peer.ID) {
 	prov := &addProv{
 		k:   k,
@@ -138,17 +251,78 @@ func (pm *ProviderManager) GetLocal() []key.Key {
 	return <-resp
 }
 
+// providerKey returns the datastore key of the record of p providing k.
+func providerKey(k key.Key, p peer.ID) ds.Key {
+	return providersKeyPrefix.ChildString(key.B58KeyEncode(k)).ChildString(peer.IDB58Encode(p))
+}
+
+func parseProviderKey(dsk ds.Key) (key.Key, peer.ID, error) {
+	ns := dsk.Namespaces()
+	if len(ns) != 3 {
+		return "", "", fmt.Errorf("malformed provider record key")
+	}
+	k := key.B58KeyDecode(ns[1])
+	if k == "" {
+		return "", "", fmt.Errorf("malformed key %q", ns[1])
+	}
+	p, err := peer.IDB58Decode(ns[2])
+	if err != nil {
+		return "", "", err
+	}
+	return k, p, nil
+}
+
+func encodeTime(t time.Time) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, t.UnixNano())
+	return buf[:n]
+}
+
+func decodeTime(b []byte) (time.Time, error) {
+	nsec, n := binary.Varint(b)
+	if n <= 0 {
+		return time.Time{}, fmt.Errorf("malformed time")
+	}
+	return time.Unix(0, nsec), nil
+}
+
 func newProviderSet() *providerSet {
 	return &providerSet{
 		set: make(map[peer.ID]time.Time),
 	}
 }
 
-func (ps *providerSet) Add(p peer.ID) {
+// Add records p as a provider, announced at t.
+func (ps *providerSet) Add(p peer.ID, t time.Time) {
 	_, found := ps.set[p]
 	if !found {
 		ps.providers = append(ps.providers, p)
 	}
 
-	ps.set[p] = time.Now()
+	ps.set[p] = t
+}
+
+func (ps *providerSet) Remove(p peer.ID) {
+	if _, found := ps.set[p]; !found {
+		return
+	}
+	delete(ps.set, p)
+	for i, q := range ps.providers {
+		if q == p {
+			ps.providers = append(ps.providers[:i:i], ps.providers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Oldest returns the provider announced the longest ago.
+func (ps *providerSet) Oldest() peer.ID {
+	var oldest peer.ID
+	var ot time.Time
+	for p, t := range ps.set {
+		if oldest == "" || t.Before(ot) {
+			oldest, ot = p, t
+		}
+	}
+	return oldest
 }
@@ -1,18 +1,23 @@
 package dht
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
 
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 )
 
 func TestProviderManager(t *testing.T) {
 	ctx := context.Background()
 	mid := peer.ID("testing")
-	p := NewProviderManager(ctx, mid)
+	p := NewProviderManager(ctx, mid, ds.NewMapDatastore())
 	a := key.Key("test")
 	p.AddProvider(ctx, a, peer.ID("testingprovider"))
 	resp := p.GetProviders(ctx, a)
@@ -21,3 +26,95 @@ func TestProviderManager(t *testing.T) {
 	}
 	p.proc.Close()
 }
+
+func TestProvidersPersist(t *testing.T) {
+	ctx := context.Background()
+	dstore := ds.NewMapDatastore()
+	mid := testutil.RandPeerIDFatal(t)
+	a := key.Key("test")
+
+	p := NewProviderManager(ctx, mid, dstore)
+	p.AddProvider(ctx, a, testutil.RandPeerIDFatal(t))
+	p.AddProvider(ctx, a, mid)
+	p.GetProviders(ctx, a) // wait for the adds to be handled
+	p.proc.Close()
+
+	p = NewProviderManager(ctx, mid, dstore)
+	defer p.proc.Close()
+	if resp := p.GetProviders(ctx, a); len(resp) != 2 {
+		t.Fatalf("expected the 2 providers back after a restart, got %d", len(resp))
+	}
+	if local := p.GetLocal(); len(local) != 1 || local[0] != a {
+		t.Fatalf("expected %s to be provided locally, got %v", a, local)
+	}
+}
+
+func TestProvidersExpire(t *testing.T) {
+	ctx := context.Background()
+	dstore := ds.NewMapDatastore()
+	a := key.Key("test")
+	old := testutil.RandPeerIDFatal(t)
+
+	// a record announced too long ago is dropped while loading
+	err := dstore.Put(providerKey(a, old), encodeTime(time.Now().Add(-ProvideValidity-time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProviderManager(ctx, peer.ID("testing"), dstore)
+	defer p.proc.Close()
+	if resp := p.GetProviders(ctx, a); len(resp) != 0 {
+		t.Fatalf("expected the expired record to be dropped, got %v", resp)
+	}
+	if has, _ := dstore.Has(providerKey(a, old)); has {
+		t.Fatal("expected the expired record to be deleted from the datastore")
+	}
+
+	// a record that cannot be decoded is deleted too
+	bad := providersKeyPrefix.ChildString(key.B58KeyEncode(a)).ChildString("notapeerid")
+	if err := dstore.Put(bad, encodeTime(time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	p.proc.Close()
+	p = NewProviderManager(ctx, peer.ID("testing"), dstore)
+	if has, _ := dstore.Has(bad); has {
+		t.Fatal("expected the undecodable record to be deleted from the datastore")
+	}
+
+	// and by the cleaner, once it expires
+	p.AddProvider(ctx, a, old)
+	p.GetProviders(ctx, a)
+	p.proc.Close()
+	p.cleanup(time.Now().Add(ProvideValidity + time.Minute))
+	if _, ok := p.providers[a]; ok {
+		t.Fatal("expected the cleaner to drop the expired record")
+	}
+	if has, _ := dstore.Has(providerKey(a, old)); has {
+		t.Fatal("expected the cleaner to delete the record from the datastore")
+	}
+}
+
+func TestProvidersPerKeyCap(t *testing.T) {
+	ctx := context.Background()
+	dstore := ds.NewMapDatastore()
+	a := key.Key("test")
+
+	p := NewProviderManager(ctx, peer.ID("testing"), dstore)
+	defer p.proc.Close()
+	for i := 0; i < MaxProvidersPerKey+10; i++ {
+		p.AddProvider(ctx, a, peer.ID(fmt.Sprintf("provider%d", i)))
+	}
+	if resp := p.GetProviders(ctx, a); len(resp) != MaxProvidersPerKey {
+		t.Fatalf("expected %d providers, got %d", MaxProvidersPerKey, len(resp))
+	}
+	res, err := dstore.Query(dsq.Query{Prefix: providersKeyPrefix.String(), KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != MaxProvidersPerKey {
+		t.Fatalf("expected %d records in the datastore, got %d", MaxProvidersPerKey, len(entries))
+	}
+}
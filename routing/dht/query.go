@@ -1,13 +1,16 @@
 package dht
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	key "github.com/ipfs/go-ipfs/blocks/key"
 	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	queue "github.com/ipfs/go-ipfs/p2p/peer/queue"
 	"github.com/ipfs/go-ipfs/routing"
+	kb "github.com/ipfs/go-ipfs/routing/kbucket"
 	u "github.com/ipfs/go-ipfs/util"
 	pset "github.com/ipfs/go-ipfs/util/peerset"
 	todoctr "github.com/ipfs/go-ipfs/util/todocounter"
@@ -73,8 +76,13 @@ type dhtQueryRunner struct {
 	peersToQuery   *queue.ChanQueue // peers remaining to be queried
 	peersRemaining todoctr.Counter  // peersToQuery + currently processing
 
-	result *dhtQueryResult // query result
-	errs   u.MultiErr      // result errors. maybe should be a map[peer.ID]error
+	result   *dhtQueryResult // query result
+	errs     u.MultiErr      // result errors. maybe should be a map[peer.ID]error
+	answered []peer.ID       // peers that answered the query
+
+	// parent is the context of the caller, which carries the channel of
+	// the query events, unlike the contexts of the workers.
+	parent context.Context
 
 	rateLimit chan struct{} // processing semaphore
 	log       logging.EventLogger
@@ -98,6 +106,8 @@ func newQueryRunner(q *dhtQuery) *dhtQueryRunner {
 
 func (r *dhtQueryRunner) Run(ctx context.Context, peers []peer.ID) (*dhtQueryResult, error) {
 	r.log = log
+	r.parent = ctx
+	defer r.publishDone(time.Now())
 
 	if len(peers) == 0 {
 		log.Warning("Running query with no peers!")
@@ -156,6 +166,30 @@ func (r *dhtQueryRunner) Run(ctx context.Context, peers []peer.ID) (*dhtQueryRes
 	return nil, err
 }
 
+// publishDone ends the query events, with the closest peers that answered
+// the query, which started at start.
+func (r *dhtQueryRunner) publishDone(start time.Time) {
+	r.RLock()
+	closest := kb.SortClosestPeers(r.answered, kb.ConvertKey(r.query.key))
+	extra := fmt.Sprintf("queried %d peers, %d failed", r.peersSeen.Size(), len(r.errs))
+	r.RUnlock()
+	if len(closest) > KValue {
+		closest = closest[:KValue]
+	}
+
+	responses := make([]*peer.PeerInfo, len(closest))
+	for i, p := range closest {
+		pi := r.query.dht.peerstore.PeerInfo(p)
+		responses[i] = &pi
+	}
+	notif.PublishQueryEvent(r.parent, &notif.QueryEvent{
+		Type:      notif.QueryDone,
+		Responses: responses,
+		Extra:     extra,
+		Duration:  time.Since(start),
+	})
+}
+
 func (r *dhtQueryRunner) addPeerToQuery(next peer.ID) {
 	// if new peer is ourselves...
 	if next == r.query.dht.self {
@@ -218,6 +252,12 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 		r.rateLimit <- struct{}{}
 	}()
 
+	notif.PublishQueryEvent(r.parent, &notif.QueryEvent{
+		Type: notif.SendingQuery,
+		ID:   p,
+	})
+	start := time.Now()
+
 	// make sure we're connected to the peer.
 	// FIXME abstract away into the network layer
 	if conns := r.query.dht.host.Network().ConnsToPeer(p); len(conns) == 0 {
@@ -231,9 +271,11 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 		if err := r.query.dht.host.Connect(ctx, pi); err != nil {
 			log.Debugf("Error connecting: %s", err)
 
-			notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-				Type:  notif.QueryError,
-				Extra: err.Error(),
+			notif.PublishQueryEvent(r.parent, &notif.QueryEvent{
+				Type:     notif.QueryError,
+				ID:       p,
+				Extra:    err.Error(),
+				Duration: time.Since(start),
 			})
 
 			r.Lock()
@@ -249,6 +291,25 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 	// finally, run the query against this peer
 	res, err := r.query.qfunc(ctx, p)
 
+	if err != nil {
+		notif.PublishQueryEvent(r.parent, &notif.QueryEvent{
+			Type:     notif.QueryError,
+			ID:       p,
+			Extra:    err.Error(),
+			Duration: time.Since(start),
+		})
+	} else {
+		notif.PublishQueryEvent(r.parent, &notif.QueryEvent{
+			Type:      notif.PeerResponse,
+			ID:        p,
+			Responses: pointerizePeerInfos(res.closerPeers),
+			Duration:  time.Since(start),
+		})
+		r.Lock()
+		r.answered = append(r.answered, p)
+		r.Unlock()
+	}
+
 	if err != nil {
 		log.Debugf("ERROR worker for: %v %v", p, err)
 		r.Lock()
@@ -161,13 +161,7 @@ func (dht *IpfsDHT) GetValues(ctx context.Context, key key.Key, nvals int) ([]ro
 	}
 
 	// setup the Query
-	parent := ctx
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
 		rec, peers, err := dht.getValueOrPeers(ctx, p, key)
 		if err != nil {
 			return nil, err
@@ -190,12 +184,6 @@ func (dht *IpfsDHT) GetValues(ctx context.Context, key key.Key, nvals int) ([]ro
 			valslock.Unlock()
 		}
 
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(peers),
-		})
-
 		return res, nil
 	})
 
@@ -242,6 +230,17 @@ func (dht *IpfsDHT) Provide(ctx context.Context, key key.Key) error {
 	return nil
 }
 
+// AddProvider records that pi provides key, without announcing it to the
+// network. The record is given out to the peers asking this node for the
+// providers of key, which is how a router provides for the nodes that
+// delegate their routing to it.
+func (dht *IpfsDHT) AddProvider(ctx context.Context, key key.Key, pi peer.PeerInfo) {
+	if pi.ID != dht.self {
+		dht.peerstore.AddAddrs(pi.ID, pi.Addrs, peer.ProviderAddrTTL)
+	}
+	dht.providers.AddProvider(ctx, key, pi.ID)
+}
+
 // FindProviders searches until the context expires.
 func (dht *IpfsDHT) FindProviders(ctx context.Context, key key.Key) ([]peer.PeerInfo, error) {
 	var providers []peer.PeerInfo
@@ -284,12 +283,7 @@ func (dht *IpfsDHT) findProvidersAsyncRoutine(ctx context.Context, key key.Key,
 	}
 
 	// setup the Query
-	parent := ctx
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
 		pmes, err := dht.findProvidersSingle(ctx, p, key)
 		if err != nil {
 			return nil, err
@@ -321,12 +315,6 @@ func (dht *IpfsDHT) findProvidersAsyncRoutine(ctx context.Context, key key.Key,
 		closer := pmes.GetCloserPeers()
 		clpeers := pb.PBPeersToPeerInfos(closer)
 		log.Debugf("got closer peers: %d %s", len(clpeers), clpeers)
-
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(clpeers),
-		})
 		return &dhtQueryResult{closerPeers: clpeers}, nil
 	})
 
@@ -364,13 +352,7 @@ func (dht *IpfsDHT) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, er
 	}
 
 	// setup the Query
-	parent := ctx
 	query := dht.newQuery(key.Key(id), func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
 		pmes, err := dht.findPeerSingle(ctx, p, id)
 		if err != nil {
 			return nil, err
@@ -389,11 +371,6 @@ func (dht *IpfsDHT) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, er
 			}
 		}
 
-		notif.PublishQueryEvent(parent, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			Responses: pointerizePeerInfos(clpeerInfos),
-		})
-
 		return &dhtQueryResult{closerPeers: clpeerInfos}, nil
 	})
 
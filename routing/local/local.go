@@ -0,0 +1,61 @@
+// package local implements a routing system answering from the peers the
+// node is connected to, such as the peers of the local network found by
+// mDNS discovery, without asking anyone.
+package local
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	host "github.com/ipfs/go-ipfs/p2p/host"
+	inet "github.com/ipfs/go-ipfs/p2p/net"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+)
+
+// Router finds the peers connected to its host. It finds no providers or
+// values, and stores nothing: composed before a slower router, it saves
+// the lookups of the peers at hand.
+type Router struct {
+	host host.Host
+}
+
+// NewRouter returns a Router for the peers connected to h.
+func NewRouter(h host.Host) *Router {
+	return &Router{host: h}
+}
+
+func (r *Router) FindProvidersAsync(context.Context, key.Key, int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	close(out)
+	return out
+}
+
+func (r *Router) PutValue(context.Context, key.Key, []byte) error {
+	return routing.ErrNotSupported
+}
+
+func (r *Router) GetValue(context.Context, key.Key) ([]byte, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) GetValues(context.Context, key.Key, int) ([]routing.RecvdVal, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) Provide(context.Context, key.Key) error {
+	return routing.ErrNotSupported
+}
+
+func (r *Router) FindPeer(_ context.Context, id peer.ID) (peer.PeerInfo, error) {
+	if r.host.Network().Connectedness(id) != inet.Connected {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return r.host.Peerstore().PeerInfo(id), nil
+}
+
+func (r *Router) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Router{}
@@ -73,7 +73,8 @@ func (v Validator) IsSigned(k key.Key) (bool, error) {
 // verifies that the passed in record value is the PublicKey
 // that matches the passed in key.
 func ValidatePublicKeyRecord(k key.Key, val []byte) error {
-	keyparts := bytes.Split([]byte(k), []byte("/"))
+	// the hash may itself contain slashes
+	keyparts := bytes.SplitN([]byte(k), []byte("/"), 3)
 	if len(keyparts) < 3 {
 		return errors.New("invalid key")
 	}
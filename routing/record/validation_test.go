@@ -0,0 +1,28 @@
+package record
+
+import (
+	"bytes"
+	"testing"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+func TestValidatePublicKeyRecord(t *testing.T) {
+	// find a value whose hash has a slash in it
+	var val []byte
+	for i := 0; ; i++ {
+		val = []byte{byte(i), byte(i >> 8)}
+		if bytes.IndexByte(u.Hash(val), '/') >= 0 {
+			break
+		}
+	}
+
+	k := key.Key("/pk/" + string(u.Hash(val)))
+	if err := ValidatePublicKeyRecord(k, val); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidatePublicKeyRecord(k, []byte("other")); err == nil {
+		t.Fatal("expected a mismatched public key to be rejected")
+	}
+}
@@ -13,6 +13,10 @@ import (
 // ErrNotFound is returned when a search fails to find anything
 var ErrNotFound = errors.New("routing: not found")
 
+// ErrNotSupported is returned by the routing systems that don't do an
+// operation, such as storing values, which others they are composed with do.
+var ErrNotSupported = errors.New("routing: operation not supported")
+
 // IpfsRouting is the routing module interface
 // It is implemented by things like DHTs, etc.
 type IpfsRouting interface {
@@ -0,0 +1,83 @@
+// package static implements a routing system answering from fixed lists of
+// providers, such as the nodes of a cluster known to have the content.
+package static
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	key "github.com/ipfs/go-ipfs/blocks/key"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+)
+
+// Router gives out its lists of providers. It neither stores nor finds
+// values.
+type Router struct {
+	providers map[key.Key][]peer.PeerInfo
+	all       []peer.PeerInfo
+	peers     map[peer.ID]peer.PeerInfo
+}
+
+// NewRouter returns a Router giving out providers[k] as the providers of k,
+// and all as providers of every key. FindPeer finds the listed peers.
+func NewRouter(providers map[key.Key][]peer.PeerInfo, all []peer.PeerInfo) *Router {
+	r := &Router{
+		providers: providers,
+		all:       all,
+		peers:     make(map[peer.ID]peer.PeerInfo),
+	}
+	for _, pis := range providers {
+		for _, pi := range pis {
+			r.peers[pi.ID] = pi
+		}
+	}
+	for _, pi := range all {
+		r.peers[pi.ID] = pi
+	}
+	return r
+}
+
+func (r *Router) FindProvidersAsync(ctx context.Context, k key.Key, count int) <-chan peer.PeerInfo {
+	var provs []peer.PeerInfo
+	provs = append(provs, r.providers[k]...)
+	provs = append(provs, r.all...)
+	if len(provs) > count {
+		provs = provs[:count]
+	}
+	out := make(chan peer.PeerInfo, len(provs))
+	for _, pi := range provs {
+		out <- pi
+	}
+	close(out)
+	return out
+}
+
+func (r *Router) PutValue(context.Context, key.Key, []byte) error {
+	return routing.ErrNotSupported
+}
+
+func (r *Router) GetValue(context.Context, key.Key) ([]byte, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) GetValues(context.Context, key.Key, int) ([]routing.RecvdVal, error) {
+	return nil, routing.ErrNotFound
+}
+
+func (r *Router) Provide(context.Context, key.Key) error {
+	return routing.ErrNotSupported
+}
+
+func (r *Router) FindPeer(_ context.Context, id peer.ID) (peer.PeerInfo, error) {
+	pi, ok := r.peers[id]
+	if !ok {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return pi, nil
+}
+
+func (r *Router) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Router{}
@@ -59,4 +59,19 @@ test_expect_success "'ipfs commands --flags' output looks good" '
 	grep "ipfs repo gc --quiet / ipfs repo gc -q" commands.txt
 '
 
+test_expect_success "'ipfs commands completion bash' succeeds" '
+	ipfs commands completion bash >completion.bash
+'
+
+test_expect_success "'ipfs commands completion bash' output looks good" '
+	bash -n completion.bash &&
+	grep "complete -o filenames -F _ipfs ipfs" completion.bash &&
+	grep "\"pin\") echo \"add ls rm" completion.bash &&
+	grep "\"pin add\") echo \".*--recursive -r" completion.bash
+'
+
+test_expect_success "'ipfs commands completion' fails for unknown shells" '
+	test_must_fail ipfs commands completion tcsh
+'
+
 test_done
@@ -37,10 +37,31 @@ test_expect_success "mount directories cannot be removed while active" '
 	test_must_fail rmdir ipfs ipns 2>/dev/null
 '
 
+test_expect_success "'ipfs mount --files-path' succeeds" '
+	mkdir files &&
+	ipfs mount --files-path="$(pwd)/files" >actual
+'
+
+test_expect_success "'ipfs mount --files-path' output looks good" '
+	echo "IPFS mounted at: $(pwd)/ipfs" >expected &&
+	echo "IPNS mounted at: $(pwd)/ipns" >>expected &&
+	echo "Files mounted at: $(pwd)/files" >>expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "files written to the mount are in the files root" '
+	mkdir files/dir &&
+	echo "hello files" >files/dir/hello &&
+	ipfs files ls /dir >actual &&
+	echo hello >expected &&
+	test_cmp expected actual
+'
+
 test_kill_ipfs_daemon
 
 test_expect_success "mount directories can be removed after shutdown" '
-	rmdir ipfs ipns
+	rmdir ipfs ipns &&
+	rm -r files
 '
 
 test_done
@@ -237,6 +237,23 @@ test_object_cmd() {
 	test_expect_success "create bad path fails" '
 		test_must_fail ipfs object patch --create $EMPTY add-link / $FILE
 	'
+
+	test_expect_success "'ipfs object diff' succeeds" '
+		DIFF_A=$(ipfs object patch $EMPTY add-link foo $FILE) &&
+		DIFF_B=$(ipfs object patch $EMPTY add-link bar $FILE) &&
+		ipfs object diff $DIFF_A $DIFF_B > diff_out
+	'
+
+	test_expect_success "'ipfs object diff' output looks good" '
+		echo "- foo $FILE" > diff_exp &&
+		echo "+ bar $FILE" >> diff_exp &&
+		test_cmp diff_exp diff_out
+	'
+
+	test_expect_success "'ipfs object diff' of an object with itself is empty" '
+		ipfs object diff $DIFF_A $DIFF_A > diff_same_out &&
+		test_must_be_empty diff_same_out
+	'
 }
 
 # should work offline
@@ -137,4 +137,31 @@ test_expect_success "'ipfs daemon' should be able to run with a pipe attached to
   test_fsh cat stdin_daemon_out || test_fsh cat stdin_daemon_err || test_fsh cat stdin_poll_apiout || test_fsh cat stdin_poll_apierr
 '
 
+test_expect_success "make an empty directory for 'ipfs daemon --init'" '
+  mkdir .ipfs-init-opts
+'
+
+test_expect_success "'ipfs daemon --init' initializes an empty directory with the --init options" '
+  IPFS_PATH="$(pwd)/.ipfs-init-opts" ipfs daemon --init --init-profile=server \
+    --init-api=/ip4/127.0.0.1/tcp/5011 \
+    --init-gateway=/ip4/127.0.0.1/tcp/8091 \
+    --init-swarm=/ip4/0.0.0.0/tcp/4011 >init_opts_out 2>init_opts_err &
+  pollEndpoint -host=/ip4/127.0.0.1/tcp/5011 -ep=/version -v -tout=1s -tries=60 >init_opts_poll 2>&1 &&
+  test_kill_repeat_10_sec $! ||
+  test_fsh cat init_opts_out || test_fsh cat init_opts_err || test_fsh cat init_opts_poll
+'
+
+test_expect_success "the config has the --init addresses" '
+  test "$(IPFS_PATH=.ipfs-init-opts ipfs config Addresses.API)" = "/ip4/127.0.0.1/tcp/5011" &&
+  test "$(IPFS_PATH=.ipfs-init-opts ipfs config Addresses.Gateway)" = "/ip4/127.0.0.1/tcp/8091" &&
+  IPFS_PATH=.ipfs-init-opts ipfs config Addresses.Swarm >init_swarm &&
+  grep "/ip4/0.0.0.0/tcp/4011" init_swarm
+'
+
+test_expect_success "'ipfs daemon --init' refuses an invalid --init address" '
+  test_must_fail env IPFS_PATH="$(pwd)/.ipfs-init-bad" ipfs daemon --init --init-api=notanaddr 2>init_bad_err &&
+  grep "invalid address" init_bad_err &&
+  test ! -e .ipfs-init-bad/config
+'
+
 test_done
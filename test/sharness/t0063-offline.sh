@@ -0,0 +1,56 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the --offline option"
+
+. lib/test-lib.sh
+
+# a hash which no node has
+MISSING=QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG
+
+test_offline_cmds() {
+	test_expect_success "local content can be read offline" '
+		echo "hello offline" >expected &&
+		HASH=$(ipfs add -q expected) &&
+		ipfs cat --offline $HASH >actual &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "missing content fails right away offline" '
+		test_must_fail ipfs cat --offline --timeout=30s $MISSING 2>cat_err &&
+		test_must_fail ipfs refs --offline --timeout=30s $MISSING &&
+		test_must_fail ipfs pin add --offline --timeout=30s $MISSING
+	'
+
+	test_expect_success "the errors are not timeouts" '
+		test_must_fail grep "deadline exceeded" cat_err
+	'
+}
+
+test_init_ipfs
+
+# runs locally, with an offline node
+test_offline_cmds
+
+# runs on the daemon, with an offline view of its node
+test_launch_ipfs_daemon
+test_offline_cmds
+
+test_expect_success "network commands fail offline" '
+	test_must_fail ipfs swarm peers --offline
+'
+test_kill_ipfs_daemon
+
+test_expect_success "'ipfs daemon --offline' runs without the network" '
+	ipfs daemon --offline >offline_daemon_out 2>offline_daemon_err &
+	OFFLINE_PID=$! &&
+	pollEndpoint -ep=/version -v -tout=1s -tries=60 >offline_poll 2>&1 &&
+	grep "Running offline" offline_daemon_out &&
+	ipfs cat $HASH >actual_daemon &&
+	test_cmp expected actual_daemon &&
+	test_kill_repeat_10_sec $OFFLINE_PID
+'
+
+test_done
@@ -0,0 +1,42 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test Datastore.StorageMax"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "go-random is installed" '
+	type random
+'
+
+test_expect_success "set the storage limit 2MB past the repo size" '
+	usage=$(du -sk "$IPFS_PATH" | cut -f1) &&
+	ipfs config Datastore.StorageMax "$((usage + 2048))KiB" &&
+	ipfs config --json Datastore.StorageGCWatermark 100
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs add' of a small file succeeds" '
+	echo "some text" >afile &&
+	ipfs add -q afile
+'
+
+test_expect_success "'ipfs add' past the limit fails through the daemon" '
+	random 5242880 42 >bigfile &&
+	test_must_fail ipfs add -q bigfile >add_out 2>&1 &&
+	grep "maximum storage limit exceeded" add_out
+'
+
+test_kill_ipfs_daemon
+
+test_expect_success "'ipfs add' past the limit fails without the daemon" '
+	test_must_fail ipfs add -q bigfile >add_out 2>&1 &&
+	grep "maximum storage limit exceeded" add_out
+'
+
+test_done
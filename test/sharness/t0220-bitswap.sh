@@ -9,6 +9,17 @@ test_description="test bitswap commands"
 . lib/test-lib.sh
 
 test_init_ipfs
+
+test_expect_success "'ipfs bitswap reprovide' fails offline" '
+	test_expect_code 1 ipfs bitswap reprovide 2>reprovide_err &&
+	grep "online mode" reprovide_err
+'
+
+# the node has no peers to provide to
+test_expect_success "set the reprovider strategy" '
+	ipfs config Reprovider.Strategy none
+'
+
 test_launch_ipfs_daemon
 
 test_expect_success "'ipfs block get' adds hash to wantlist" '
@@ -27,6 +38,10 @@ test_expect_success "hash was removed from wantlist" '
 	test_must_be_empty wantlist_out
 '
 
+test_expect_success "'ipfs bitswap reprovide' succeeds" '
+	ipfs bitswap reprovide
+'
+
 test_kill_ipfs_daemon
 
 test_done
@@ -0,0 +1,73 @@
+// Package passphrase reads passphrases from the terminal or from files.
+package passphrase
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoTerminal is returned when prompting without a terminal to prompt on.
+var ErrNoTerminal = errors.New("no terminal to read a passphrase from")
+
+// Prompt writes prompt to the terminal and reads a passphrase from it,
+// without echoing what is typed.
+func Prompt(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", ErrNoTerminal
+	}
+	defer tty.Close()
+
+	if err := stty(tty, "-echo"); err != nil {
+		return "", ErrNoTerminal
+	}
+	defer func() {
+		stty(tty, "echo")
+		fmt.Fprintln(tty)
+	}()
+
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// PromptNew prompts for a new passphrase twice, and fails if the two
+// don't match.
+func PromptNew(prompt string) (string, error) {
+	p, err := Prompt(prompt)
+	if err != nil {
+		return "", err
+	}
+	again, err := Prompt("Repeat to confirm: ")
+	if err != nil {
+		return "", err
+	}
+	if p != again {
+		return "", errors.New("passphrases don't match")
+	}
+	return p, nil
+}
+
+// ReadFile reads a passphrase from the first line of the file at path.
+func ReadFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line := strings.SplitN(string(data), "\n", 2)[0]
+	return strings.TrimRight(line, "\r"), nil
+}
+
+func stty(tty *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
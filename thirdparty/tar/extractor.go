@@ -39,6 +39,9 @@ func (te *Extractor) Extract(reader io.Reader) error {
 		if header == nil || err == io.EOF {
 			break
 		}
+		if err := checkTarPath(header.Name); err != nil {
+			return err
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -60,6 +63,17 @@ func (te *Extractor) Extract(reader io.Reader) error {
 	return nil
 }
 
+// checkTarPath refuses the paths which would be extracted outside of the
+// output path.
+func checkTarPath(tarPath string) error {
+	for _, elem := range strings.Split(tarPath, "/") {
+		if elem == ".." {
+			return fmt.Errorf("invalid path in archive: %q", tarPath)
+		}
+	}
+	return nil
+}
+
 // outputPath returns the path at whicht o place tarPath
 func (te *Extractor) outputPath(tarPath string) string {
 	elems := strings.Split(tarPath, "/") // break into elems
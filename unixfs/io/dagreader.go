@@ -10,6 +10,7 @@ import (
 	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/gogo/protobuf/proto"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
+	bserv "github.com/ipfs/go-ipfs/blockservice"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
@@ -88,8 +89,12 @@ func NewDagReader(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (*Dag
 	}
 }
 
+// NewDataFileReader returns a reader of the file n. Its blocks are fetched
+// within a single session, shared with the readers of its children, which
+// ends when the reader is closed.
 func NewDataFileReader(ctx context.Context, n *mdag.Node, pb *ftpb.Data, serv mdag.DAGService) *DagReader {
 	fctx, cancel := context.WithCancel(ctx)
+	fctx, closeSes := bserv.WithSession(fctx)
 	promises := serv.GetDAG(fctx, n)
 	return &DagReader{
 		node:     n,
@@ -97,8 +102,11 @@ func NewDataFileReader(ctx context.Context, n *mdag.Node, pb *ftpb.Data, serv md
 		buf:      NewRSNCFromBytes(pb.GetData()),
 		promises: promises,
 		ctx:      fctx,
-		cancel:   cancel,
-		pbdata:   pb,
+		cancel: func() {
+			closeSes()
+			cancel()
+		},
+		pbdata: pb,
 	}
 }
 
@@ -274,7 +282,7 @@ func (dr *DagReader) Seek(offset int64, whence int) (int64, error) {
 		noffset := dr.offset + offset
 		return dr.Seek(noffset, os.SEEK_SET)
 	case os.SEEK_END:
-		noffset := int64(dr.pbdata.GetFilesize()) - offset
+		noffset := int64(dr.pbdata.GetFilesize()) + offset
 		return dr.Seek(noffset, os.SEEK_SET)
 	default:
 		return 0, errors.New("invalid whence")
@@ -491,7 +491,7 @@ func dagTruncate(ctx context.Context, nd *mdag.Node, size uint64, ds mdag.DAGSer
 		return nil, err
 	}
 
-	nd.Links = nd.Links[:end]
+	nd.SetLinks(nd.Links[:end])
 	err = nd.AddNodeLinkClean("", modified)
 	if err != nil {
 		return nil, err
@@ -477,7 +477,11 @@ func basicGC(t *testing.T, bs blockstore.Blockstore, pins pin.ManualPinner) {
 		t.Fatal(err)
 	}
 	for k := range keychan { // rely on AllKeysChan to close chan
-		if !pins.IsPinned(k) {
+		pinned, err := pins.IsPinned(ctx, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pinned {
 			err := bs.DeleteBlock(k)
 			if err != nil {
 				t.Fatal(err)
@@ -566,7 +570,10 @@ func TestCorrectPinning(t *testing.T) {
 		t.Fatal("Incorrect node recursively pinned")
 	}
 
-	indirpins := pins.IndirectKeys()
+	indirpins, err := pins.IndirectKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
 	children := enumerateChildren(t, nd, dserv)
 	if len(indirpins) != len(children) {
 		t.Log(len(indirpins), len(children))